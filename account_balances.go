@@ -0,0 +1,104 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+)
+
+// accountBalancesType is the type of the result of a batch in AccountBalanceMode,
+// the same as the result of scripts.FlowBalances.
+var accountBalancesType = cadence.NewDictionaryType(cadence.TheAddressType, cadence.TheUFix64Type)
+
+// getAccountBalances gets the FLOW balance of each address of the batch with GetAccountAtBlockHeight,
+// and returns them as a `{Address: UFix64}` dictionary.
+// If any of the requests fail, the first error is returned.
+func (r *ScriptRunner) getAccountBalances(
+	ctx context.Context,
+	input AddressBatch,
+) (cadence.Value, error) {
+	pairs, err := r.getAccounts(ctx, input, func(account *flow.Account) cadence.Value {
+		return cadence.UFix64(account.Balance)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cadence.NewDictionary(pairs).WithType(accountBalancesType), nil
+}
+
+// getAccounts gets each address of the batch with GetAccountAtBlockHeight, with at most PerAddressConcurrency
+// requests running concurrently, and returns the value of each account keyed by its address.
+// If any of the requests fail, the first error is returned.
+func (r *ScriptRunner) getAccounts(
+	ctx context.Context,
+	input AddressBatch,
+	value func(account *flow.Account) cadence.Value,
+) ([]cadence.KeyValuePair, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := r.PerAddressConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	pairs := make([]cadence.KeyValuePair, len(input.Addresses))
+	var firstErr error
+	errOnce := sync.Once{}
+	wg := sync.WaitGroup{}
+	for i, address := range input.Addresses {
+		i, address := i, address
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			// one of the requests failed
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			account, err := r.client.GetAccountAtBlockHeight(ctx, address, input.BlockHeight)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = &AddressError{Address: address, Err: fmt.Errorf("could not get account: %w", err)}
+					cancel()
+				})
+				return
+			}
+			pairs[i] = cadence.KeyValuePair{
+				Key:   cadence.Address(address),
+				Value: value(account),
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return pairs, nil
+}
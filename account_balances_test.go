@@ -0,0 +1,79 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+	"github.com/onflow/flow-batch-scan/scripts"
+)
+
+func TestScriptRunner_AccountBalanceMode(t *testing.T) {
+	a1 := flow.HexToAddress("01")
+	a2 := flow.HexToAddress("02")
+	missing := flow.HexToAddress("03")
+
+	c := clienttest.New()
+	c.AddBlock(10)
+	c.HandleScripts(func(uint64, []byte, []cadence.Value) (cadence.Value, error) {
+		require.Fail(t, "no script should run")
+		return nil, nil
+	})
+	c.HandleAccounts(func(height uint64, address flow.Address) (*flow.Account, error) {
+		if address == missing {
+			return nil, status.Error(codes.NotFound, "account not found")
+		}
+		return &flow.Account{Address: address, Balance: uint64(address[flow.AddressLength-1]) * 100}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := DefaultScriptRunnerConfig()
+	config.AccountBalanceMode = true
+	config.PerAddressConcurrency = 1
+	batches := make(chan AddressBatch, 1)
+	results := make(chan ProcessedAddressBatch, 1)
+	runner := NewScriptRunner(c, batches, results, config, zerolog.Nop())
+	<-runner.Start(ctx)
+
+	batches <- NewAddressBatch([]flow.Address{a1, a2}, 10, func() {}, nil)
+	select {
+	case result := <-results:
+		balances, err := scripts.DecodeFlowBalances(result.Result)
+		require.NoError(t, err)
+		require.Equal(t, map[flow.Address]cadence.UFix64{a1: 100, a2: 200}, balances)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "batch was not handled")
+	}
+	require.Equal(t, 2, c.Calls(client.MethodGetAccountAtBlockHeight))
+
+	_, err := runner.getAccountBalances(ctx, NewAddressBatch([]flow.Address{a1, missing, a2}, 10, func() {}, nil))
+	var addressErr *AddressError
+	require.ErrorAs(t, err, &addressErr)
+	require.Equal(t, missing, addressErr.Address)
+	require.Equal(t, ErrAccountNotFound, ClassifyError(err))
+}
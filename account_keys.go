@@ -0,0 +1,105 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+)
+
+// accountKeyType is the type of a key in AccountKeysMode, the KeyInfo struct of scripts.AccountKeys
+// with the sequence number of the key, which scripts can not read.
+var accountKeyType = cadence.NewStructType(
+	nil,
+	"KeyInfo",
+	[]cadence.Field{
+		{Identifier: "keyIndex", Type: cadence.TheIntType},
+		{Identifier: "publicKey", Type: cadence.TheStringType},
+		{Identifier: "signatureAlgorithm", Type: cadence.TheUInt8Type},
+		{Identifier: "hashAlgorithm", Type: cadence.TheUInt8Type},
+		{Identifier: "weight", Type: cadence.TheUFix64Type},
+		{Identifier: "isRevoked", Type: cadence.TheBoolType},
+		{Identifier: "sequenceNumber", Type: cadence.TheUInt64Type},
+	},
+	nil,
+)
+
+// accountKeysType is the type of the result of a batch in AccountKeysMode.
+var accountKeysType = cadence.NewDictionaryType(cadence.TheAddressType, cadence.NewVariableSizedArrayType(accountKeyType))
+
+// getAccountKeys gets the keys of each address of the batch with GetAccountAtBlockHeight,
+// and returns them as a `{Address: [KeyInfo]}` dictionary.
+func (r *ScriptRunner) getAccountKeys(
+	ctx context.Context,
+	input AddressBatch,
+) (cadence.Value, error) {
+	pairs, err := r.getAccounts(ctx, input, func(account *flow.Account) cadence.Value {
+		keys := make([]cadence.Value, 0, len(account.Keys))
+		for _, key := range account.Keys {
+			var publicKey string
+			if key.PublicKey != nil {
+				publicKey = hex.EncodeToString(key.PublicKey.Encode())
+			}
+			keys = append(keys, cadence.NewStruct([]cadence.Value{
+				cadence.NewInt(key.Index),
+				cadence.String(publicKey),
+				cadence.UInt8(signatureAlgorithmRawValue(key.SigAlgo)),
+				cadence.UInt8(hashAlgorithmRawValue(key.HashAlgo)),
+				cadence.UFix64(uint64(key.Weight) * 100_000_000),
+				cadence.Bool(key.Revoked),
+				cadence.UInt64(key.SequenceNumber),
+			}).WithType(accountKeyType))
+		}
+		return cadence.NewArray(keys).WithType(cadence.NewVariableSizedArrayType(accountKeyType))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cadence.NewDictionary(pairs).WithType(accountKeysType), nil
+}
+
+// signatureAlgorithmRawValue is the raw value of the Cadence SignatureAlgorithm of the algorithm,
+// which differs from the numbering of the crypto package.
+func signatureAlgorithmRawValue(algorithm crypto.SignatureAlgorithm) uint8 {
+	switch algorithm {
+	case crypto.ECDSA_P256:
+		return 1
+	case crypto.ECDSA_secp256k1:
+		return 2
+	}
+	return 0
+}
+
+// hashAlgorithmRawValue is the raw value of the Cadence HashAlgorithm of the algorithm,
+// which differs from the numbering of the crypto package: KMAC128_BLS_BLS12_381 is 5 and KECCAK_256 is 6.
+func hashAlgorithmRawValue(algorithm crypto.HashAlgorithm) uint8 {
+	switch algorithm {
+	case crypto.SHA2_256:
+		return 1
+	case crypto.SHA2_384:
+		return 2
+	case crypto.SHA3_256:
+		return 3
+	case crypto.SHA3_384:
+		return 4
+	case crypto.Keccak256:
+		return 6
+	}
+	return 0
+}
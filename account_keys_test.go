@@ -0,0 +1,91 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+	"github.com/onflow/flow-batch-scan/scripts"
+)
+
+func TestScriptRunner_AccountKeysMode(t *testing.T) {
+	a1 := flow.HexToAddress("01")
+	a2 := flow.HexToAddress("02")
+
+	privateKey, err := crypto.GeneratePrivateKey(crypto.ECDSA_secp256k1, make([]byte, crypto.MinSeedLength))
+	require.NoError(t, err)
+	key := flow.NewAccountKey().
+		FromPrivateKey(privateKey).
+		SetHashAlgo(crypto.Keccak256).
+		SetWeight(500)
+	key.Index = 1
+	key.SequenceNumber = 42
+	key.Revoked = true
+
+	c := clienttest.New()
+	c.AddBlock(10)
+	c.HandleScripts(func(uint64, []byte, []cadence.Value) (cadence.Value, error) {
+		require.Fail(t, "no script should run")
+		return nil, nil
+	})
+	c.HandleAccounts(func(height uint64, address flow.Address) (*flow.Account, error) {
+		if address == a2 {
+			return &flow.Account{Address: address}, nil
+		}
+		return &flow.Account{Address: address, Keys: []*flow.AccountKey{key}}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := DefaultScriptRunnerConfig()
+	config.AccountKeysMode = true
+	config.PerAddressConcurrency = 2
+	batches := make(chan AddressBatch, 1)
+	results := make(chan ProcessedAddressBatch, 1)
+	runner := NewScriptRunner(c, batches, results, config, zerolog.Nop())
+	<-runner.Start(ctx)
+
+	batches <- NewAddressBatch([]flow.Address{a1, a2}, 10, func() {}, nil)
+	select {
+	case result := <-results:
+		keys, err := scripts.DecodeAccountKeys(result.Result)
+		require.NoError(t, err)
+		require.Equal(t, map[flow.Address][]scripts.AccountKey{
+			a1: {{
+				KeyIndex:           1,
+				PublicKey:          hex.EncodeToString(privateKey.PublicKey().Encode()),
+				SignatureAlgorithm: 2,
+				HashAlgorithm:      6,
+				Weight:             500_00000000,
+				IsRevoked:          true,
+				SequenceNumber:     42,
+			}},
+			a2: {},
+		}, keys)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "batch was not handled")
+	}
+}
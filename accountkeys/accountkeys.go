@@ -0,0 +1,171 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package accountkeys is a scan preset for the keys of all accounts, e.g. for security audits:
+// the index, weight, signature and hash algorithm, revoked flag and sequence number of every key.
+//
+// The full scan reads the keys with scanner.Config.WithAccountKeysMode,
+// and the incremental scan rescans the accounts that had keys added or removed:
+//
+//	handler := accountkeys.HandlerFunc(func(accounts []accountkeys.Account) error {
+//		...
+//	})
+//	s, err := scanner.NewScanner(flowClient, append(options, accountkeys.Options(handler, logger)...)...)
+package accountkeys
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/candidates"
+	"github.com/onflow/flow-batch-scan/scripts"
+)
+
+// Key is a key of an account.
+type Key struct {
+	Index int `json:"index"`
+	// PublicKey is hex encoded.
+	PublicKey string `json:"public_key"`
+	// SignatureAlgorithm is the name of the Cadence SignatureAlgorithm, e.g. ECDSA_P256.
+	SignatureAlgorithm string `json:"signature_algorithm"`
+	// HashAlgorithm is the name of the Cadence HashAlgorithm, e.g. SHA3_256.
+	HashAlgorithm string `json:"hash_algorithm"`
+	// Weight is the weight of the key, flow.AccountKeyWeightThreshold is the weight needed to sign for the account.
+	Weight  uint64 `json:"weight"`
+	Revoked bool   `json:"revoked"`
+	// SequenceNumber is 0 if the keys were scanned with scripts.AccountKeys instead of the AccountKeysMode.
+	SequenceNumber uint64 `json:"sequence_number"`
+}
+
+// Account are the keys of an account at a block, ordered by key index.
+type Account struct {
+	Address     flow.Address `json:"address"`
+	BlockHeight uint64       `json:"block_height"`
+	BlockID     string       `json:"block_id,omitempty"`
+	Keys        []Key        `json:"keys"`
+}
+
+// SigningWeight is the sum of the weights of the keys that are not revoked.
+// The account can only sign transactions if it is at least flow.AccountKeyWeightThreshold.
+func (a Account) SigningWeight() uint64 {
+	var weight uint64
+	for _, key := range a.Keys {
+		if !key.Revoked {
+			weight += key.Weight
+		}
+	}
+	return weight
+}
+
+// Options are the options of the scan: the AccountKeysMode, the handler of the results,
+// and a candidate scanner for the accounts that had keys added or removed.
+// Use HandlerFunc to handle the decoded accounts.
+func Options(handler scanner.ScriptResultHandler, logger zerolog.Logger) []scanner.Option {
+	return []scanner.Option{
+		scanner.WithAccountKeysMode(),
+		scanner.WithScriptResultHandler(handler),
+		scanner.WithCandidateScanners([]candidates.CandidateScanner{
+			candidates.NewAccountKeyCandidatesScanner(logger),
+		}),
+	}
+}
+
+// HandlerFunc is a ScriptResultHandler that handles the decoded accounts of each batch.
+type HandlerFunc func(accounts []Account) error
+
+var _ scanner.ScriptResultHandler = HandlerFunc(nil)
+
+func (f HandlerFunc) Handle(batch scanner.ProcessedAddressBatch) error {
+	accounts, err := Decode(batch)
+	if err != nil {
+		return err
+	}
+	return f(accounts)
+}
+
+// Decode decodes the accounts of a batch of the AccountKeysMode or of scripts.AccountKeys, ordered by address.
+func Decode(batch scanner.ProcessedAddressBatch) ([]Account, error) {
+	decoded, err := scripts.DecodeAccountKeys(batch.Result)
+	if err != nil {
+		return nil, err
+	}
+
+	var blockID string
+	if batch.BlockID != flow.EmptyID {
+		blockID = batch.BlockID.Hex()
+	}
+	accounts := make([]Account, 0, len(decoded))
+	for address, accountKeys := range decoded {
+		keys := make([]Key, 0, len(accountKeys))
+		for _, key := range accountKeys {
+			keys = append(keys, Key{
+				Index:              key.KeyIndex,
+				PublicKey:          key.PublicKey,
+				SignatureAlgorithm: SignatureAlgorithmName(key.SignatureAlgorithm),
+				HashAlgorithm:      HashAlgorithmName(key.HashAlgorithm),
+				Weight:             uint64(key.Weight) / 100_000_000,
+				Revoked:            key.IsRevoked,
+				SequenceNumber:     key.SequenceNumber,
+			})
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].Index < keys[j].Index
+		})
+		accounts = append(accounts, Account{
+			Address:     address,
+			BlockHeight: batch.BlockHeight,
+			BlockID:     blockID,
+			Keys:        keys,
+		})
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return bytes.Compare(accounts[i].Address[:], accounts[j].Address[:]) < 0
+	})
+	return accounts, nil
+}
+
+var signatureAlgorithmNames = map[uint8]string{
+	1: "ECDSA_P256",
+	2: "ECDSA_secp256k1",
+	3: "BLS_BLS12_381",
+}
+
+var hashAlgorithmNames = map[uint8]string{
+	1: "SHA2_256",
+	2: "SHA2_384",
+	3: "SHA3_256",
+	4: "SHA3_384",
+	5: "KMAC128_BLS_BLS12_381",
+	6: "KECCAK_256",
+}
+
+// SignatureAlgorithmName is the name of the Cadence SignatureAlgorithm with the raw value, or "unknown".
+func SignatureAlgorithmName(rawValue uint8) string {
+	if name, ok := signatureAlgorithmNames[rawValue]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// HashAlgorithmName is the name of the Cadence HashAlgorithm with the raw value, or "unknown".
+func HashAlgorithmName(rawValue uint8) string {
+	if name, ok := hashAlgorithmNames[rawValue]; ok {
+		return name
+	}
+	return "unknown"
+}
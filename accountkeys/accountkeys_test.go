@@ -0,0 +1,82 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accountkeys_test
+
+import (
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/accountkeys"
+)
+
+// keyInfoType is the KeyInfo struct of scripts.AccountKeys.
+var keyInfoType = cadence.NewStructType(nil, "KeyInfo", []cadence.Field{
+	{Identifier: "keyIndex", Type: cadence.TheIntType},
+	{Identifier: "publicKey", Type: cadence.TheStringType},
+	{Identifier: "signatureAlgorithm", Type: cadence.TheUInt8Type},
+	{Identifier: "hashAlgorithm", Type: cadence.TheUInt8Type},
+	{Identifier: "weight", Type: cadence.TheUFix64Type},
+	{Identifier: "isRevoked", Type: cadence.TheBoolType},
+}, nil)
+
+func keyInfo(index int, weight uint64, revoked bool) cadence.Value {
+	return cadence.NewStruct([]cadence.Value{
+		cadence.NewInt(index),
+		cadence.String("abcd"),
+		cadence.UInt8(1),
+		cadence.UInt8(3),
+		cadence.UFix64(weight * 100_000_000),
+		cadence.Bool(revoked),
+	}).WithType(keyInfoType)
+}
+
+func TestHandlerFunc(t *testing.T) {
+	a1 := flow.HexToAddress("02")
+	a2 := flow.HexToAddress("01")
+	batch := scanner.ProcessedAddressBatch{
+		AddressBatch: scanner.AddressBatch{BlockHeight: 10},
+		BlockID:      flow.Identifier{1},
+		Result: cadence.NewDictionary([]cadence.KeyValuePair{
+			{Key: cadence.Address(a1), Value: cadence.NewArray([]cadence.Value{
+				keyInfo(1, 1000, true),
+				keyInfo(0, 500, false),
+			})},
+			{Key: cadence.Address(a2), Value: cadence.NewArray(nil)},
+		}),
+	}
+
+	var handled []accountkeys.Account
+	handler := accountkeys.HandlerFunc(func(accounts []accountkeys.Account) error {
+		handled = accounts
+		return nil
+	})
+	require.NoError(t, handler.Handle(batch))
+
+	require.Equal(t, []accountkeys.Account{
+		{Address: a2, BlockHeight: 10, BlockID: flow.Identifier{1}.Hex(), Keys: []accountkeys.Key{}},
+		{Address: a1, BlockHeight: 10, BlockID: flow.Identifier{1}.Hex(), Keys: []accountkeys.Key{
+			{Index: 0, PublicKey: "abcd", SignatureAlgorithm: "ECDSA_P256", HashAlgorithm: "SHA3_256", Weight: 500},
+			{Index: 1, PublicKey: "abcd", SignatureAlgorithm: "ECDSA_P256", HashAlgorithm: "SHA3_256", Weight: 1000, Revoked: true},
+		}},
+	}, handled)
+	require.Equal(t, uint64(500), handled[1].SigningWeight())
+
+	require.Error(t, handler.Handle(scanner.ProcessedAddressBatch{Result: cadence.String("x")}))
+	require.Equal(t, "unknown", accountkeys.HashAlgorithmName(0))
+}
@@ -0,0 +1,114 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package accounts backfills the accounts created on the chain from the flow.AccountCreated events,
+// with the height they were created at. The accounts can be written to and read from a CSV dataset,
+// and scanned with a full scan that only scans the accounts created after a height:
+//
+//	created, err := accounts.Backfill(ctx, sporkClient, firstHeight, latestHeight, eventfinder.WithSporks(sporks))
+//	...
+//	s, err := scanner.NewScanner(flowClient, scanner.WithAddressProvider(accounts.AddressProvider(created, height)))
+//
+// The accounts created when a network was bootstrapped, e.g. the service account, have no AccountCreated event.
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/candidates"
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/eventfinder"
+)
+
+// Account is an account with the block and transaction it was created in.
+type Account struct {
+	Address        flow.Address
+	CreationHeight uint64
+	CreationTime   time.Time
+	TransactionID  flow.Identifier
+}
+
+var accountAddress = candidates.AddressFromEventField("address")
+
+// Stream passes the accounts created from startHeight to endHeight (inclusive) to handle, in the order they were created.
+// The options are the options of the eventfinder, e.g. eventfinder.WithSporks to backfill across sporks.
+// If handle returns an error, Stream stops and returns it.
+func Stream(
+	ctx context.Context,
+	client client.Client,
+	startHeight uint64,
+	endHeight uint64,
+	handle func(account Account) error,
+	options ...eventfinder.Option,
+) error {
+	return eventfinder.Stream(ctx, client, flow.EventAccountCreated, startHeight, endHeight, func(event eventfinder.Event) error {
+		address, err := accountAddress(event.Value)
+		if err != nil {
+			return fmt.Errorf("failed to get the address of the account created at height %d: %w", event.BlockHeight, err)
+		}
+		return handle(Account{
+			Address:        address,
+			CreationHeight: event.BlockHeight,
+			CreationTime:   event.BlockTimestamp,
+			TransactionID:  event.TransactionID,
+		})
+	}, options...)
+}
+
+// Backfill returns the accounts created from startHeight to endHeight (inclusive), in the order they were created.
+// See Stream.
+func Backfill(
+	ctx context.Context,
+	client client.Client,
+	startHeight uint64,
+	endHeight uint64,
+	options ...eventfinder.Option,
+) ([]Account, error) {
+	var accounts []Account
+	err := Stream(ctx, client, startHeight, endHeight, func(account Account) error {
+		accounts = append(accounts, account)
+		return nil
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// CreatedAfter returns the addresses of the accounts created after the height, up to the height maxHeight (inclusive).
+func CreatedAfter(accounts []Account, height uint64, maxHeight uint64) []flow.Address {
+	var addresses []flow.Address
+	for _, account := range accounts {
+		if account.CreationHeight > height && account.CreationHeight <= maxHeight {
+			addresses = append(addresses, account.Address)
+		}
+	}
+	return addresses
+}
+
+// AddressProvider makes a full scan only scan the accounts created after the height, see scanner.WithAddressProvider.
+// The accounts created after the reference block of the full scan are not scanned, they do not exist at that block.
+func AddressProvider(
+	accounts []Account,
+	height uint64,
+) func(ctx context.Context, blockHeight uint64) (scanner.AddressProvider, error) {
+	return func(_ context.Context, blockHeight uint64) (scanner.AddressProvider, error) {
+		return scanner.NewListAddressProvider(CreatedAfter(accounts, height, blockHeight)), nil
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accounts_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/accounts"
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+	"github.com/onflow/flow-batch-scan/eventfinder"
+)
+
+var accountCreatedType = &cadence.EventType{
+	QualifiedIdentifier: flow.EventAccountCreated,
+	Fields:              []cadence.Field{{Identifier: "address", Type: cadence.AddressType{}}},
+}
+
+func accountCreated(address flow.Address) clienttest.Transaction {
+	return clienttest.Transaction{Events: []flow.Event{{
+		Type:  flow.EventAccountCreated,
+		Value: cadence.NewEvent([]cadence.Value{cadence.NewAddress(address)}).WithType(accountCreatedType),
+	}}}
+}
+
+func TestBackfill(t *testing.T) {
+	a1 := flow.HexToAddress("01")
+	a2 := flow.HexToAddress("02")
+	a3 := flow.HexToAddress("03")
+
+	c := clienttest.New()
+	c.AddBlocks(1, 100)
+	c.AddBlock(10, accountCreated(a1))
+	c.AddBlock(50, accountCreated(a2))
+	c.AddBlock(90, accountCreated(a3))
+	sporks := []client.Spork{
+		{Name: "spork-1", RootHeight: 1},
+		{Name: "spork-2", RootHeight: 40},
+	}
+
+	created, err := accounts.Backfill(context.Background(), c, 1, 100, eventfinder.WithSporks(sporks))
+	require.NoError(t, err)
+	require.Len(t, created, 3)
+	for i, height := range []uint64{10, 50, 90} {
+		require.Equal(t, []flow.Address{a1, a2, a3}[i], created[i].Address)
+		require.Equal(t, height, created[i].CreationHeight)
+	}
+
+	require.Equal(t, []flow.Address{a2}, accounts.CreatedAfter(created, 10, 89))
+
+	provider, err := accounts.AddressProvider(created, 10)(context.Background(), 100)
+	require.NoError(t, err)
+	addresses, done := provider.NextBatch(10)
+	require.True(t, done)
+	require.Equal(t, []flow.Address{a2, a3}, addresses)
+
+	var buf bytes.Buffer
+	require.NoError(t, accounts.WriteCSV(&buf, created))
+	read, err := accounts.ReadCSV(&buf)
+	require.NoError(t, err)
+	require.Len(t, read, 3)
+	for i := range created {
+		require.Equal(t, created[i].Address, read[i].Address)
+		require.Equal(t, created[i].CreationHeight, read[i].CreationHeight)
+		require.Equal(t, created[i].TransactionID, read[i].TransactionID)
+		require.True(t, created[i].CreationTime.Equal(read[i].CreationTime))
+	}
+
+	_, err = accounts.ReadCSV(bytes.NewBufferString("address,height\n"))
+	require.Error(t, err)
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accounts
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+)
+
+var csvHeader = []string{"address", "creation_height", "creation_time", "transaction_id"}
+
+// WriteCSV writes the accounts as a CSV dataset with a header, that can be read back with ReadCSV.
+func WriteCSV(w io.Writer, accounts []Account) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		err := writer.Write([]string{
+			"0x" + account.Address.Hex(),
+			strconv.FormatUint(account.CreationHeight, 10),
+			account.CreationTime.UTC().Format(time.RFC3339Nano),
+			account.TransactionID.Hex(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ReadCSV reads the accounts of a CSV dataset written by WriteCSV.
+func ReadCSV(r io.Reader) ([]Account, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(csvHeader)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the header: %w", err)
+	}
+	for i, column := range csvHeader {
+		if header[i] != column {
+			return nil, fmt.Errorf("unexpected column %q, expected %q", header[i], column)
+		}
+	}
+
+	var accounts []Account
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return accounts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		account := Account{
+			Address: flow.HexToAddress(record[0]),
+		}
+		account.CreationHeight, err = strconv.ParseUint(record[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid creation height: %w", line, err)
+		}
+		account.CreationTime, err = time.Parse(time.RFC3339Nano, record[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid creation time: %w", line, err)
+		}
+		id, err := hex.DecodeString(record[3])
+		if err != nil || len(id) != len(flow.Identifier{}) {
+			return nil, fmt.Errorf("line %d: invalid transaction ID %q", line, record[3])
+		}
+		account.TransactionID = flow.BytesToID(id)
+		accounts = append(accounts, account)
+	}
+}
@@ -15,17 +15,42 @@
 package scanner
 
 import (
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/onflow/cadence"
 	"github.com/onflow/flow-go-sdk"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/onflow/flow-batch-scan/candidates"
 )
 
 // AddressBatch is a batch of addresses that will be the input to the script being run byt the script runner
 // at the given block height.
 type AddressBatch struct {
-	Addresses    []flow.Address
-	BlockHeight  uint64
+	Addresses   []flow.Address
+	BlockHeight uint64
+	// Provenance contains the reasons why addresses in this batch are being scanned.
+	// It is only set for batches from the incremental scanner (and linked addresses).
+	Provenance map[flow.Address][]candidates.Provenance
+	// ScriptName is the name of the script the batch is run against, if multiple Scripts are configured.
+	ScriptName string
+
+	// timeouts is how often the script of the batch timed out
+	timeouts int
+	// retries is how often the script of the batch, or of the batches it was split from, was run again.
+	retries int
+	// errorRetries is how often the batch was retried by ScriptErrorPolicyRetry.
+	errorRetries int
+	// fromFullScan is true for the batches of the full scan, see CompletenessAudit.
+	fromFullScan bool
+	// blockID is the ID of the block at BlockHeight, if the creator of the batch fetched its header.
+	blockID flow.Identifier
+	// spanContext is the span the batch was created in, e.g. the scan of the block range the candidates were found in.
+	// The spans of the batch are children of it.
+	spanContext trace.SpanContext
+
 	doneHandling func()
 	isValid      func() bool
 
@@ -36,6 +61,107 @@ type AddressBatch struct {
 type ProcessedAddressBatch struct {
 	AddressBatch
 	Result cadence.Value
+	// Signature is the signature of the batch if a BatchSigner was configured.
+	// See VerifyProcessedAddressBatch. The batch records of the handlers package contain it.
+	Signature []byte
+	// ScriptDuration is how long it took to run the script.
+	ScriptDuration time.Duration
+	// BlockID is the ID of the block at BlockHeight, the script ran against its execution state.
+	// It is empty if the header of the block could not be fetched.
+	// The batches of full scans carry it, for the other batches it is fetched by the ScriptRunner.
+	BlockID flow.Identifier
+	// Retries is how often the script of the batch (or of the batches it was split from)
+	// was run again after a failure, before it succeeded.
+	Retries int
+}
+
+// AddressResult is the part of the result of a batch that belongs to one address.
+type AddressResult struct {
+	Address flow.Address
+	Value   cadence.Value
+}
+
+// PerAddressResults splits the result of the batch into the results of each address.
+// The result has to follow one of these conventions:
+//   - a dictionary with Address keys,
+//   - an array of structs (or resources, events, ...) with an `address: Address` field,
+//   - an array with one element per address of the batch, in the same order (e.g. with PerAddress).
+//
+// Use PerAddressResultsBy for other results.
+func (b ProcessedAddressBatch) PerAddressResults() ([]AddressResult, error) {
+	switch result := b.Result.(type) {
+	case cadence.Dictionary:
+		results := make([]AddressResult, 0, len(result.Pairs))
+		for _, pair := range result.Pairs {
+			address, ok := pair.Key.(cadence.Address)
+			if !ok {
+				return nil, fmt.Errorf("expected Address key, got %T", pair.Key)
+			}
+			results = append(results, AddressResult{
+				Address: flow.Address(address),
+				Value:   pair.Value,
+			})
+		}
+		return results, nil
+	case cadence.Array:
+		if len(result.Values) > 0 && addressField(result.Values[0]) != nil {
+			return b.PerAddressResultsBy(func(value cadence.Value) (flow.Address, error) {
+				address, ok := addressField(value).(cadence.Address)
+				if !ok {
+					return flow.EmptyAddress, fmt.Errorf("expected an address field, got %T", value)
+				}
+				return flow.Address(address), nil
+			})
+		}
+		if len(result.Values) != len(b.Addresses) {
+			return nil, fmt.Errorf(
+				"expected %d results, one for each address, got %d",
+				len(b.Addresses),
+				len(result.Values))
+		}
+		results := make([]AddressResult, len(result.Values))
+		for i, value := range result.Values {
+			results[i] = AddressResult{
+				Address: b.Addresses[i],
+				Value:   value,
+			}
+		}
+		return results, nil
+	default:
+		return nil, fmt.Errorf("cannot split result of type %T by address", b.Result)
+	}
+}
+
+// PerAddressResultsBy splits an array result into the results of each address,
+// using key to get the address of each element.
+func (b ProcessedAddressBatch) PerAddressResultsBy(
+	key func(cadence.Value) (flow.Address, error),
+) ([]AddressResult, error) {
+	array, ok := b.Result.(cadence.Array)
+	if !ok {
+		return nil, fmt.Errorf("expected an array result, got %T", b.Result)
+	}
+	results := make([]AddressResult, len(array.Values))
+	for i, value := range array.Values {
+		address, err := key(value)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = AddressResult{
+			Address: address,
+			Value:   value,
+		}
+	}
+	return results, nil
+}
+
+// addressField returns the `address` field of a composite value, or nil.
+func addressField(value cadence.Value) cadence.Value {
+	composite, ok := value.(cadence.HasFields)
+	if !ok {
+		return nil
+	}
+	return cadence.GetFieldByName(composite, "address")
 }
 
 func NewAddressBatch(
@@ -106,5 +232,34 @@ func (b *AddressBatch) Split() (AddressBatch, AddressBatch) {
 			rightDone <- struct{}{}
 		},
 		b.isValid)
+	left.Provenance = provenanceOf(left.Addresses, b.Provenance)
+	right.Provenance = provenanceOf(right.Addresses, b.Provenance)
+	left.ScriptName = b.ScriptName
+	right.ScriptName = b.ScriptName
+	left.spanContext = b.spanContext
+	right.spanContext = b.spanContext
+	left.retries = b.retries
+	right.retries = b.retries
+	left.fromFullScan = b.fromFullScan
+	right.fromFullScan = b.fromFullScan
+	left.blockID = b.blockID
+	right.blockID = b.blockID
 	return left, right
 }
+
+// provenanceOf returns the provenance of the given addresses.
+func provenanceOf(
+	addresses []flow.Address,
+	provenance map[flow.Address][]candidates.Provenance,
+) map[flow.Address][]candidates.Provenance {
+	if provenance == nil {
+		return nil
+	}
+	result := make(map[flow.Address][]candidates.Provenance, len(addresses))
+	for _, address := range addresses {
+		if p, ok := provenance[address]; ok {
+			result[address] = p
+		}
+	}
+	return result
+}
@@ -18,6 +18,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/onflow/cadence"
 	"github.com/onflow/flow-go-sdk"
 	"github.com/stretchr/testify/require"
 )
@@ -177,3 +178,47 @@ func TestAddressBatch_Split(t *testing.T) {
 	<-time.After(1 * time.Millisecond) // wait for doneHandling to be called
 	require.Equal(t, 1, doneCalls)
 }
+
+func TestProcessedAddressBatch_PerAddressResults(t *testing.T) {
+	a1 := flow.HexToAddress("0x1")
+	a2 := flow.HexToAddress("0x2")
+	batch := ProcessedAddressBatch{
+		AddressBatch: NewAddressBatch([]flow.Address{a1, a2}, 1, nil, nil),
+	}
+
+	t.Run("dictionary", func(t *testing.T) {
+		batch.Result = cadence.NewDictionary([]cadence.KeyValuePair{
+			{Key: cadence.NewAddress(a2), Value: cadence.NewInt(2)},
+		})
+		results, err := batch.PerAddressResults()
+		require.NoError(t, err)
+		require.Equal(t, []AddressResult{{Address: a2, Value: cadence.NewInt(2)}}, results)
+	})
+
+	t.Run("array of structs", func(t *testing.T) {
+		structType := &cadence.StructType{
+			QualifiedIdentifier: "Info",
+			Fields:              []cadence.Field{{Identifier: "address", Type: cadence.AddressType{}}},
+		}
+		info := cadence.NewStruct([]cadence.Value{cadence.NewAddress(a1)}).WithType(structType)
+		batch.Result = cadence.NewArray([]cadence.Value{info})
+		results, err := batch.PerAddressResults()
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.Equal(t, a1, results[0].Address)
+	})
+
+	t.Run("array by index", func(t *testing.T) {
+		batch.Result = cadence.NewArray([]cadence.Value{cadence.NewInt(1), cadence.NewInt(2)})
+		results, err := batch.PerAddressResults()
+		require.NoError(t, err)
+		require.Equal(t, []AddressResult{
+			{Address: a1, Value: cadence.NewInt(1)},
+			{Address: a2, Value: cadence.NewInt(2)},
+		}, results)
+
+		batch.Result = cadence.NewArray([]cadence.Value{cadence.NewInt(1)})
+		_, err = batch.PerAddressResults()
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/onflow/flow-go-sdk"
+)
+
+// AddressError is the error of a single address of a batch, e.g. in PerAddress or AccountBalanceMode,
+// where each address is requested on its own.
+type AddressError struct {
+	Address flow.Address
+	Err     error
+}
+
+var _ error = (*AddressError)(nil)
+
+func (e *AddressError) Error() string {
+	return fmt.Sprintf("address %s: %v", e.Address, e.Err)
+}
+
+func (e *AddressError) Unwrap() error {
+	return e.Err
+}
+
+// AddressErrorAction is what happens to an address that fails on its own:
+// an AddressError of a batch, or the single address a failing batch was bisected to.
+type AddressErrorAction int
+
+const (
+	// AddressErrorSkip drops the address, and continues with the rest of the batch.
+	// The address is recorded in ScanStats.FailedBatches and passed to the FailedBatchHandler.
+	// The scan fails if more than MaxFailedAddresses addresses are skipped.
+	AddressErrorSkip AddressErrorAction = iota
+	// AddressErrorFail handles the error like the error of any other batch.
+	AddressErrorFail
+)
+
+// DefaultHandleAddressError skips the addresses that fail because of the account itself,
+// e.g. an account that does not exist or a script that panics on the account.
+// Errors of the access node, like rate limits, timeouts and missing blocks, are not the fault of the address,
+// so they fail the batch instead.
+func DefaultHandleAddressError(_ flow.Address, err error) AddressErrorAction {
+	if errors.Is(err, context.Canceled) || isNodePressureError(err) || ClassifyError(err) == ErrBlockNotFound {
+		return AddressErrorFail
+	}
+	return AddressErrorSkip
+}
+
+// handleAddressError returns the action of the HandleAddressError policy.
+// Without a policy the addresses are skipped.
+func (r *ScriptRunner) handleAddressError(address flow.Address, err error) AddressErrorAction {
+	if r.HandleAddressError == nil {
+		return AddressErrorSkip
+	}
+	return r.HandleAddressError(address, err)
+}
+
+// skipAddressError drops the address of an AddressError from the batch and runs the rest of the batch,
+// if the HandleAddressError policy skips it. It returns false if the error is not handled.
+func (r *ScriptRunner) skipAddressError(ctx context.Context, input AddressBatch, err error) bool {
+	var addressErr *AddressError
+	if !errors.As(err, &addressErr) || len(input.Addresses) < 2 {
+		return false
+	}
+	if r.handleAddressError(addressErr.Address, err) != AddressErrorSkip {
+		return false
+	}
+
+	failed := NewAddressBatch([]flow.Address{addressErr.Address}, input.BlockHeight, nil, nil)
+	failed.ScriptName = input.ScriptName
+	failed.spanContext = input.spanContext
+	if !r.skipFailedAddress(failed, err) {
+		return false
+	}
+	input.ExcludeAddress(addressErr.Address)
+	input.retries++
+	r.stats.batchRetried()
+	go func() {
+		r.handleBatch(ctx, input)
+	}()
+	return true
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+	"github.com/onflow/flow-batch-scan/scripts"
+)
+
+func TestDefaultHandleAddressError(t *testing.T) {
+	address := flow.HexToAddress("01")
+	for err, action := range map[error]AddressErrorAction{
+		&AddressError{Address: address, Err: status.Error(codes.NotFound, "not found")}: AddressErrorSkip,
+		status.Error(codes.NotFound, "block not found"):                                 AddressErrorFail,
+		fmt.Errorf("[Error Code: 1101] panic"):                                          AddressErrorSkip,
+		status.Error(codes.ResourceExhausted, "rate limited"):                           AddressErrorFail,
+		status.Error(codes.Unavailable, "unavailable"):                                  AddressErrorFail,
+		fmt.Errorf("state commitment not found"):                                        AddressErrorFail,
+		&AddressError{Address: address, Err: context.Canceled}:                          AddressErrorFail,
+		&AddressError{Address: address, Err: fmt.Errorf("[Error Code: 1201]")}:          AddressErrorSkip,
+	} {
+		require.Equal(t, action, DefaultHandleAddressError(address, err), err.Error())
+	}
+}
+
+func TestScriptRunner_SkipAddressError(t *testing.T) {
+	a1 := flow.HexToAddress("01")
+	missing := flow.HexToAddress("02")
+	a3 := flow.HexToAddress("03")
+
+	c := clienttest.New()
+	c.AddBlock(10)
+	c.HandleAccounts(func(height uint64, address flow.Address) (*flow.Account, error) {
+		if address == missing {
+			return nil, status.Error(codes.NotFound, "account not found")
+		}
+		return &flow.Account{Address: address, Balance: 100}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := DefaultScriptRunnerConfig()
+	config.AccountBalanceMode = true
+	config.BisectFailedBatches = false
+	batches := make(chan AddressBatch, 1)
+	results := make(chan ProcessedAddressBatch, 1)
+	runner := NewScriptRunner(c, batches, results, config, zerolog.Nop())
+	runner.stats = newStatsCollector()
+	<-runner.Start(ctx)
+
+	batches <- NewAddressBatch([]flow.Address{a1, missing, a3}, 10, func() {}, nil)
+	select {
+	case result := <-results:
+		balances, err := scripts.DecodeFlowBalances(result.Result)
+		require.NoError(t, err)
+		require.Equal(t, map[flow.Address]cadence.UFix64{a1: 100, a3: 100}, balances)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "batch was not handled")
+	}
+
+	stats := runner.stats.snapshot(0)
+	require.Len(t, stats.FailedBatches, 1)
+	require.Equal(t, []flow.Address{missing}, stats.FailedBatches[0].Addresses)
+}
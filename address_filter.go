@@ -0,0 +1,158 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"bytes"
+
+	"github.com/onflow/flow-go-sdk"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+)
+
+// AddressRange is an inclusive range of addresses.
+type AddressRange struct {
+	From flow.Address
+	To   flow.Address
+}
+
+func (r AddressRange) Contains(address flow.Address) bool {
+	return bytes.Compare(address[:], r.From[:]) >= 0 &&
+		bytes.Compare(address[:], r.To[:]) <= 0
+}
+
+// AddressFilter selects which addresses are scanned by both the full scan and the incremental scanner.
+// Filtered out addresses are dropped before they are batched, so they are never sent to a script.
+type AddressFilter struct {
+	// Include if any addresses or ranges are included, only those addresses are scanned.
+	Include       []flow.Address
+	IncludeRanges []AddressRange
+	// Exclude addresses and ranges are never scanned, even if they are included.
+	Exclude       []flow.Address
+	ExcludeRanges []AddressRange
+}
+
+func (f AddressFilter) isEmpty() bool {
+	return len(f.Include) == 0 &&
+		len(f.IncludeRanges) == 0 &&
+		len(f.Exclude) == 0 &&
+		len(f.ExcludeRanges) == 0
+}
+
+// compile prepares the filter for lookups. It returns nil if the filter allows all addresses.
+func (f AddressFilter) compile() *addressFilter {
+	if f.isEmpty() {
+		return nil
+	}
+	c := &addressFilter{
+		include:       make(map[flow.Address]struct{}, len(f.Include)),
+		includeRanges: f.IncludeRanges,
+		exclude:       make(map[flow.Address]struct{}, len(f.Exclude)),
+		excludeRanges: f.ExcludeRanges,
+	}
+	for _, address := range f.Include {
+		c.include[address] = struct{}{}
+	}
+	for _, address := range f.Exclude {
+		c.exclude[address] = struct{}{}
+	}
+	return c
+}
+
+type addressFilter struct {
+	include       map[flow.Address]struct{}
+	includeRanges []AddressRange
+	exclude       map[flow.Address]struct{}
+	excludeRanges []AddressRange
+}
+
+// allows returns true if the address should be scanned. A nil filter allows all addresses.
+func (f *addressFilter) allows(address flow.Address) bool {
+	if f == nil {
+		return true
+	}
+	if _, ok := f.exclude[address]; ok {
+		return false
+	}
+	for _, r := range f.excludeRanges {
+		if r.Contains(address) {
+			return false
+		}
+	}
+	if len(f.include) == 0 && len(f.includeRanges) == 0 {
+		return true
+	}
+	if _, ok := f.include[address]; ok {
+		return true
+	}
+	for _, r := range f.includeRanges {
+		if r.Contains(address) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterCandidates removes the candidates the filter does not allow.
+func (f *addressFilter) filterCandidates(result candidates.CandidatesResult) candidates.CandidatesResult {
+	if f == nil {
+		return result
+	}
+	for address := range result.Addresses {
+		if !f.allows(address) {
+			delete(result.Addresses, address)
+			delete(result.Provenance, address)
+		}
+	}
+	return result
+}
+
+// filteredAddressProvider skips the addresses for which keep returns false.
+type filteredAddressProvider struct {
+	AddressProvider
+	keep func(address flow.Address) bool
+}
+
+var _ AddressProvider = (*filteredAddressProvider)(nil)
+
+func (p *filteredAddressProvider) NextBatch(batchSize int) ([]flow.Address, bool) {
+	addresses := make([]flow.Address, 0, batchSize)
+	for len(addresses) < batchSize {
+		batch, done := p.AddressProvider.NextBatch(batchSize - len(addresses))
+		for _, address := range batch {
+			if p.keep(address) {
+				addresses = append(addresses, address)
+			}
+		}
+		if done {
+			return addresses, true
+		}
+	}
+	return addresses, false
+}
+
+// newAddressSampler returns a function that keeps the given fraction of the addresses it is called with,
+// spread evenly over the addresses. The first address is always kept.
+func newAddressSampler(rate float64) func(address flow.Address) bool {
+	accumulated := 1 - rate
+	return func(flow.Address) bool {
+		accumulated += rate
+		if accumulated < 1 {
+			return false
+		}
+		accumulated--
+		return true
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressFilter(t *testing.T) {
+	a1 := flow.HexToAddress("01")
+	a2 := flow.HexToAddress("02")
+	a3 := flow.HexToAddress("03")
+	a4 := flow.HexToAddress("04")
+
+	t.Run("empty filter allows all", func(t *testing.T) {
+		f := AddressFilter{}.compile()
+		require.Nil(t, f)
+		require.True(t, f.allows(a1))
+	})
+	t.Run("include", func(t *testing.T) {
+		f := AddressFilter{
+			Include:       []flow.Address{a1},
+			IncludeRanges: []AddressRange{{From: a3, To: a4}},
+		}.compile()
+		require.True(t, f.allows(a1))
+		require.False(t, f.allows(a2))
+		require.True(t, f.allows(a3))
+		require.True(t, f.allows(a4))
+	})
+	t.Run("exclude wins over include", func(t *testing.T) {
+		f := AddressFilter{
+			IncludeRanges: []AddressRange{{From: a1, To: a4}},
+			Exclude:       []flow.Address{a2},
+			ExcludeRanges: []AddressRange{{From: a4, To: a4}},
+		}.compile()
+		require.True(t, f.allows(a1))
+		require.False(t, f.allows(a2))
+		require.True(t, f.allows(a3))
+		require.False(t, f.allows(a4))
+	})
+	t.Run("filtered provider fills batches", func(t *testing.T) {
+		p := &filteredAddressProvider{
+			AddressProvider: NewListAddressProvider([]flow.Address{a1, a2, a3, a4}),
+			keep:            AddressFilter{Exclude: []flow.Address{a2}}.compile().allows,
+		}
+		batch, done := p.NextBatch(2)
+		require.False(t, done)
+		require.Equal(t, []flow.Address{a1, a3}, batch)
+
+		batch, done = p.NextBatch(2)
+		require.True(t, done)
+		require.Equal(t, []flow.Address{a4}, batch)
+	})
+	t.Run("sampler", func(t *testing.T) {
+		keep := newAddressSampler(0.25)
+		kept := 0
+		for i := 0; i < 100; i++ {
+			if keep(a1) {
+				kept++
+			}
+		}
+		require.Equal(t, 25, kept)
+	})
+}
@@ -17,6 +17,7 @@ package scanner
 import (
 	"context"
 	"strings"
+	"sync"
 
 	"github.com/onflow/cadence"
 	"github.com/onflow/flow-go-sdk"
@@ -50,10 +51,25 @@ func DefaultAddressProviderConfig() AddressProviderConfig {
 	}
 }
 
-// AddressProvider Is used to get all the addresses that exists at a certain referenceBlockId
+// AddressProvider provides the addresses that are scanned by a full scan.
+type AddressProvider interface {
+	// NextBatch returns the next batch of at most batchSize addresses.
+	// done is true if there are no more addresses.
+	NextBatch(batchSize int) (addresses []flow.Address, done bool)
+	// AddressesLen is the total number of addresses. It is used to report progress.
+	AddressesLen() uint
+	// CurrentIndex is the position of the next address.
+	CurrentIndex() uint
+	// SkipTo continues from the given position. It is used to resume a full scan from a checkpoint.
+	SkipTo(index uint)
+}
+
+var _ AddressProvider = (*ChainAddressProvider)(nil)
+
+// ChainAddressProvider Is used to get all the addresses that exists at a certain referenceBlockId
 // this relies on the fact that a certain `endOfAccountsError` will be returned by the
 // `accountStorageUsageScript` if the address doesn't exist yet
-type AddressProvider struct {
+type ChainAddressProvider struct {
 	log              zerolog.Logger
 	lastAddress      flow.Address
 	generator        *flow.AddressGenerator
@@ -80,55 +96,129 @@ func InitAddressProvider(
 	client client.Client,
 	config AddressProviderConfig,
 	log zerolog.Logger,
-) (*AddressProvider, error) {
-	ap := &AddressProvider{
-		log:          log.With().Str("component", "address_provider").Logger(),
-		generator:    flow.NewAddressGenerator(chain),
-		blockHeight:  blockHeight,
-		currentIndex: 1,
-		chainID:      chain,
-		config:       config,
-	}
-
-	searchStep := 0
-	addressExistsAtIndex := func(index uint) (bool, error) {
-		searchStep += 1
-		address := ap.indexToAddress(index)
-
-		log.Debug().Msgf("testing account %d = %s", index, address)
-
-		// This script will fail with endOfAccountsError
-		// if the account (address at given index) doesn't exist yet
-		_, err := client.ExecuteScriptAtBlockHeight(
-			ctx,
-			blockHeight,
-			[]byte(accountStorageUsageScript),
-			[]cadence.Value{cadence.NewAddress(address)},
-		)
-		if err == nil {
-			return true, nil
-		}
-		if strings.Contains(err.Error(), endOfAccountsError) {
-			return false, nil
-		}
-		return false, err
-	}
-
-	// We assume address #2 exists
-	lastAddressIndex, err := ap.getLastAddress(1, 2, true, addressExistsAtIndex)
+) (*ChainAddressProvider, error) {
+	count, err := BisectAccountCounter(chain, client, log)(ctx, blockHeight)
 	if err != nil {
 		return nil, err
 	}
+	ap := NewGeneratorAddressProvider(chain, count, config)
+	ap.log = log.With().Str("component", "address_provider").Logger()
+	ap.blockHeight = blockHeight
+	return ap, nil
+}
 
-	log.Info().
-		Str("lastAddress", ap.indexToAddress(lastAddressIndex).Hex()).
-		Uint("numAccounts", lastAddressIndex).
-		Int("stepsNeeded", searchStep).
-		Msg("Found last address")
+// NewGeneratorAddressProvider enumerates the addresses of the chain locally, with the linear address generator
+// the chain uses to assign the addresses of new accounts, up to the accountsCount-th address.
+// The addresses cost no requests to the access node, only the count does (see AccountCounter).
+func NewGeneratorAddressProvider(
+	chain flow.ChainID,
+	accountsCount uint,
+	config AddressProviderConfig,
+) *ChainAddressProvider {
+	ap := &ChainAddressProvider{
+		log:              zerolog.Nop(),
+		generator:        flow.NewAddressGenerator(chain),
+		lastAddressIndex: accountsCount,
+		currentIndex:     1,
+		chainID:          chain,
+		config:           config,
+	}
+	ap.lastAddress = ap.indexToAddress(accountsCount)
+	return ap
+}
 
-	ap.lastAddress = ap.indexToAddress(lastAddressIndex)
-	ap.lastAddressIndex = lastAddressIndex
-	return ap, nil
+// GeneratorAddresses can be used with Config.WithAddressProvider to enumerate the addresses of the chain locally
+// (see NewGeneratorAddressProvider), bounded by the count of the counter at the block height of each full scan.
+func GeneratorAddresses(
+	chain flow.ChainID,
+	counter AccountCounter,
+	config AddressProviderConfig,
+) func(ctx context.Context, blockHeight uint64) (AddressProvider, error) {
+	return func(ctx context.Context, blockHeight uint64) (AddressProvider, error) {
+		count, err := counter(ctx, blockHeight)
+		if err != nil {
+			return nil, err
+		}
+		return NewGeneratorAddressProvider(chain, count, config), nil
+	}
+}
+
+// AccountCounter returns the number of accounts of the chain at the block height,
+// which is the index of the address generator of the last created account.
+type AccountCounter func(ctx context.Context, blockHeight uint64) (uint, error)
+
+// BisectAccountCounter finds the number of accounts by bisecting the address generator indexes,
+// running a script to check if the account at an index exists. The first count takes about 2 * log2(count) scripts.
+// The counter remembers the last count, and later counts at the same or higher block heights search up from it,
+// which only takes about 2 * log2(new accounts) scripts.
+func BisectAccountCounter(
+	chain flow.ChainID,
+	client client.Client,
+	log zerolog.Logger,
+) AccountCounter {
+	var mu sync.Mutex
+	var lastCount uint
+	var lastHeight uint64
+
+	return func(ctx context.Context, blockHeight uint64) (uint, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		generator := flow.NewAddressGenerator(chain)
+		searchStep := 0
+		addressExistsAtIndex := func(index uint) (bool, error) {
+			searchStep += 1
+			generator.SetIndex(index)
+			address := generator.Address()
+
+			log.Debug().Msgf("testing account %d = %s", index, address)
+
+			// This script will fail with endOfAccountsError
+			// if the account (address at given index) doesn't exist yet
+			_, err := client.ExecuteScriptAtBlockHeight(
+				ctx,
+				blockHeight,
+				[]byte(accountStorageUsageScript),
+				[]cadence.Value{cadence.NewAddress(address)},
+			)
+			if err == nil {
+				return true, nil
+			}
+			if strings.Contains(err.Error(), endOfAccountsError) {
+				return false, nil
+			}
+			return false, err
+		}
+
+		// We assume address #2 exists, or the last counted address if it was counted at a lower height,
+		// as accounts are never removed.
+		// The search is relative to the last counted address, its offset is 1.
+		start, upperOffset := uint(1), uint(2)
+		if lastCount > 1 && blockHeight >= lastHeight {
+			start, upperOffset = lastCount, 1
+		}
+		existsAtOffset := func(offset uint) (bool, error) {
+			return addressExistsAtIndex(start + offset - 1)
+		}
+		lastOffset, err := getLastAddress(1, upperOffset, true, existsAtOffset)
+		if err != nil {
+			return 0, err
+		}
+		count := start + lastOffset - 1
+
+		generator.SetIndex(count)
+		log.Info().
+			Str("lastAddress", generator.Address().Hex()).
+			Uint("numAccounts", count).
+			Int("stepsNeeded", searchStep).
+			Msg("Found last address")
+
+		if blockHeight >= lastHeight {
+			lastCount = count
+			lastHeight = blockHeight
+		}
+		return count, nil
+	}
 }
 
 // getLastAddress is a recursive function that finds the last address. Will use max 2 * log2(number_of_addresses) steps
@@ -140,7 +230,7 @@ func InitAddressProvider(
 // 3. (4,8): check address (8 - 4) / 2 = 6  address exists so next pair is (6,8)
 // 4. (6,8): check address 7 address exists so next pair is (7,8)
 // 5. (7,8): check address (8 - 7) / 2 = 7 ... ok already checked so this is the last existing address
-func (p *AddressProvider) getLastAddress(
+func getLastAddress(
 	lowerIndex uint,
 	upperIndex uint,
 	upperExists bool,
@@ -154,7 +244,7 @@ func (p *AddressProvider) getLastAddress(
 		if err != nil {
 			return 0, err
 		}
-		return p.getLastAddress(upperIndex, newUpperIndex, newUpperExists, addressExistsAtIndex)
+		return getLastAddress(upperIndex, newUpperIndex, newUpperExists, addressExistsAtIndex)
 	}
 
 	midIndex := (upperIndex-lowerIndex)/2 + lowerIndex
@@ -171,18 +261,18 @@ func (p *AddressProvider) getLastAddress(
 		return 0, err
 	}
 	if midIndexExists {
-		return p.getLastAddress(midIndex, upperIndex, upperExists, addressExistsAtIndex)
+		return getLastAddress(midIndex, upperIndex, upperExists, addressExistsAtIndex)
 	} else {
-		return p.getLastAddress(lowerIndex, midIndex, midIndexExists, addressExistsAtIndex)
+		return getLastAddress(lowerIndex, midIndex, midIndexExists, addressExistsAtIndex)
 	}
 }
 
-func (p *AddressProvider) indexToAddress(index uint) flow.Address {
+func (p *ChainAddressProvider) indexToAddress(index uint) flow.Address {
 	p.generator.SetIndex(index)
 	return p.generator.Address()
 }
 
-func (p *AddressProvider) GetNextAddress() (address flow.Address, isOutOfBounds bool) {
+func (p *ChainAddressProvider) GetNextAddress() (address flow.Address, isOutOfBounds bool) {
 	address = p.indexToAddress(p.currentIndex)
 
 	if p.currentIndex > p.lastAddressIndex {
@@ -193,30 +283,15 @@ func (p *AddressProvider) GetNextAddress() (address flow.Address, isOutOfBounds
 	return
 }
 
-func (p *AddressProvider) AddressesLen() uint {
+func (p *ChainAddressProvider) AddressesLen() uint {
 	return p.lastAddressIndex - uint(len(brokenAddresses[p.chainID]))
 }
 
-func (p *AddressProvider) GenerateAddressBatches(addressChan chan<- []flow.Address, batchSize int) {
+func (p *ChainAddressProvider) GenerateAddressBatches(addressChan chan<- []flow.Address, batchSize int) {
 	var done bool
 	for !done {
-		addresses := make([]flow.Address, 0)
-
-		for i := 0; i < batchSize; i++ {
-			addr, oob := p.GetNextAddress()
-			if oob {
-				// Out of bounds, there are no more addresses
-				done = true
-				break
-			}
-
-			// Skip address if known broken
-			if p.config.ExcludeAddress(p.chainID, addr) {
-				i--
-				continue
-			}
-			addresses = append(addresses, addr)
-		}
+		var addresses []flow.Address
+		addresses, done = p.NextBatch(batchSize)
 
 		if len(addresses) > 0 {
 			addressChan <- addresses
@@ -224,6 +299,87 @@ func (p *AddressProvider) GenerateAddressBatches(addressChan chan<- []flow.Addre
 	}
 }
 
-func (p *AddressProvider) LastAddress() flow.Address {
+// NextBatch returns the next batch of at most batchSize addresses.
+// done is true if there are no more addresses.
+func (p *ChainAddressProvider) NextBatch(batchSize int) (addresses []flow.Address, done bool) {
+	addresses = make([]flow.Address, 0)
+
+	for i := 0; i < batchSize; i++ {
+		addr, oob := p.GetNextAddress()
+		if oob {
+			// Out of bounds, there are no more addresses
+			return addresses, true
+		}
+
+		// Skip address if known broken
+		if p.config.ExcludeAddress(p.chainID, addr) {
+			i--
+			continue
+		}
+		addresses = append(addresses, addr)
+	}
+	return addresses, false
+}
+
+// SkipTo makes index the index of the next address. It is used to resume a full scan.
+func (p *ChainAddressProvider) SkipTo(index uint) {
+	p.currentIndex = index
+}
+
+// CurrentIndex is the index of the next address.
+func (p *ChainAddressProvider) CurrentIndex() uint {
+	return p.currentIndex
+}
+
+func (p *ChainAddressProvider) LastAddress() flow.Address {
 	return p.lastAddress
 }
+
+// ListAddressProvider provides a fixed list of addresses,
+// for example from a file, a database query or the output of a previous scan.
+type ListAddressProvider struct {
+	addresses    []flow.Address
+	currentIndex uint
+}
+
+var _ AddressProvider = (*ListAddressProvider)(nil)
+
+func NewListAddressProvider(addresses []flow.Address) *ListAddressProvider {
+	return &ListAddressProvider{
+		addresses: addresses,
+	}
+}
+
+// StaticAddresses can be used with Config.WithAddressProvider to scan the given addresses on every full scan.
+func StaticAddresses(
+	addresses []flow.Address,
+) func(ctx context.Context, blockHeight uint64) (AddressProvider, error) {
+	return func(context.Context, uint64) (AddressProvider, error) {
+		return NewListAddressProvider(addresses), nil
+	}
+}
+
+func (p *ListAddressProvider) NextBatch(batchSize int) ([]flow.Address, bool) {
+	start := p.currentIndex
+	if start >= uint(len(p.addresses)) {
+		return nil, true
+	}
+	end := start + uint(batchSize)
+	if end > uint(len(p.addresses)) {
+		end = uint(len(p.addresses))
+	}
+	p.currentIndex = end
+	return p.addresses[start:end], end == uint(len(p.addresses))
+}
+
+func (p *ListAddressProvider) AddressesLen() uint {
+	return uint(len(p.addresses))
+}
+
+func (p *ListAddressProvider) CurrentIndex() uint {
+	return p.currentIndex
+}
+
+func (p *ListAddressProvider) SkipTo(index uint) {
+	p.currentIndex = index
+}
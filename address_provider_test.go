@@ -0,0 +1,111 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+)
+
+func TestListAddressProvider(t *testing.T) {
+	addresses := []flow.Address{
+		flow.HexToAddress("01"),
+		flow.HexToAddress("02"),
+		flow.HexToAddress("03"),
+	}
+
+	t.Run("batches", func(t *testing.T) {
+		p := NewListAddressProvider(addresses)
+		require.Equal(t, uint(3), p.AddressesLen())
+
+		batch, done := p.NextBatch(2)
+		require.False(t, done)
+		require.Equal(t, addresses[:2], batch)
+
+		batch, done = p.NextBatch(2)
+		require.True(t, done)
+		require.Equal(t, addresses[2:], batch)
+
+		batch, done = p.NextBatch(2)
+		require.True(t, done)
+		require.Empty(t, batch)
+	})
+	t.Run("skip to", func(t *testing.T) {
+		p := NewListAddressProvider(addresses)
+		p.SkipTo(1)
+
+		batch, done := p.NextBatch(5)
+		require.True(t, done)
+		require.Equal(t, addresses[1:], batch)
+		require.Equal(t, uint(3), p.CurrentIndex())
+	})
+}
+
+func TestGeneratorAddressProvider(t *testing.T) {
+	p := NewGeneratorAddressProvider(flow.Emulator, 3, DefaultAddressProviderConfig())
+	require.Equal(t, uint(3), p.AddressesLen())
+
+	generator := flow.NewAddressGenerator(flow.Emulator)
+	var expected []flow.Address
+	for i := 0; i < 3; i++ {
+		expected = append(expected, generator.NextAddress())
+	}
+	batch, done := p.NextBatch(10)
+	require.True(t, done)
+	require.Equal(t, expected, batch)
+	require.Equal(t, expected[2], p.LastAddress())
+}
+
+func TestBisectAccountCounter(t *testing.T) {
+	accounts := map[flow.Address]struct{}{}
+	generator := flow.NewAddressGenerator(flow.Emulator)
+	addAccounts := func(count int) {
+		for i := 0; i < count; i++ {
+			accounts[generator.NextAddress()] = struct{}{}
+		}
+	}
+
+	c := clienttest.New()
+	c.AddBlocks(1, 2)
+	c.HandleScripts(func(_ uint64, _ []byte, arguments []cadence.Value) (cadence.Value, error) {
+		if _, ok := accounts[flow.Address(arguments[0].(cadence.Address))]; !ok {
+			return nil, fmt.Errorf("%s: account not found", endOfAccountsError)
+		}
+		return cadence.NewUInt64(0), nil
+	})
+	counter := BisectAccountCounter(flow.Emulator, c, zerolog.Nop())
+
+	addAccounts(100)
+	count, err := counter(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, uint(100), count)
+	firstCalls := c.Calls(client.MethodExecuteScriptAtBlockHeight)
+
+	// the next count searches up from the previous count
+	addAccounts(3)
+	count, err = counter(context.Background(), 2)
+	require.NoError(t, err)
+	require.Equal(t, uint(103), count)
+	require.Less(t, c.Calls(client.MethodExecuteScriptAtBlockHeight)-firstCalls, firstCalls)
+}
@@ -0,0 +1,139 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/onflow/cadence"
+)
+
+// AggregateHandler is a ScriptResultHandler that folds the results of the scan into an aggregate,
+// which is returned in ScanConcluded.Aggregates. Create one with Aggregate, and add it with WithAggregates.
+type AggregateHandler interface {
+	ScriptResultHandler
+	// Name is the key of the aggregate in ScanConcluded.Aggregates.
+	Name() string
+	// aggregate returns the current aggregate.
+	aggregate() any
+	// reset restarts the aggregate from its initial value, when a scan starts.
+	reset()
+}
+
+// Aggregator folds the decoded results of a scan into an aggregate of type A,
+// e.g. the sum of the balances, a histogram of the storage used, or the count of each contract.
+//
+// Every handled result is folded, so an address that is scanned more than once is folded more than once.
+// Use a point in time scan (see WithReferenceBlockHeight), or a ResultReconciliation that handles each address once.
+type Aggregator[T, A any] struct {
+	name    string
+	initial func() A
+	decode  func(cadence.Value) ([]T, error)
+	fold    func(aggregate A, value T) A
+
+	snapshotInterval time.Duration
+	snapshot         func(aggregate A)
+
+	mu           sync.Mutex
+	value        A
+	lastSnapshot time.Time
+}
+
+var _ AggregateHandler = (*Aggregator[any, any])(nil)
+
+// Aggregate creates an Aggregator that decodes the result of each batch into values of type T,
+// e.g. with DecodeArray or a decoder of the scripts package, and folds each value into the aggregate.
+// initial returns the initial aggregate of a scan. The folds are serialized, so fold can update the aggregate in place.
+func Aggregate[T, A any](
+	name string,
+	initial func() A,
+	decode func(cadence.Value) ([]T, error),
+	fold func(aggregate A, value T) A,
+) *Aggregator[T, A] {
+	return &Aggregator[T, A]{
+		name:    name,
+		initial: initial,
+		decode:  decode,
+		fold:    fold,
+		value:   initial(),
+	}
+}
+
+// WithSnapshots passes the aggregate to snapshot at most once per interval while the scan is running,
+// e.g. to report the progress of the aggregate. snapshot is called while no value is folded,
+// so it can read the aggregate, but must not keep a reference to an aggregate that is updated in place.
+func (a *Aggregator[T, A]) WithSnapshots(interval time.Duration, snapshot func(aggregate A)) *Aggregator[T, A] {
+	a.snapshotInterval = interval
+	a.snapshot = snapshot
+	return a
+}
+
+func (a *Aggregator[T, A]) Name() string {
+	return a.name
+}
+
+func (a *Aggregator[T, A]) Handle(batch ProcessedAddressBatch) error {
+	values, err := a.decode(batch.Result)
+	if err != nil {
+		return fmt.Errorf("failed to decode the result of the batch at height %d for aggregate %s: %w",
+			batch.BlockHeight, a.name, err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, value := range values {
+		a.value = a.fold(a.value, value)
+	}
+	if a.snapshot != nil && time.Since(a.lastSnapshot) >= a.snapshotInterval {
+		a.lastSnapshot = time.Now()
+		a.snapshot(a.value)
+	}
+	return nil
+}
+
+// Value returns the current aggregate.
+func (a *Aggregator[T, A]) Value() A {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.value
+}
+
+func (a *Aggregator[T, A]) aggregate() any {
+	return a.Value()
+}
+
+func (a *Aggregator[T, A]) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.value = a.initial()
+	a.lastSnapshot = time.Time{}
+}
+
+// aggregates returns the current value of each of the AggregateHandlers, by name.
+func aggregates(handlers []AggregateHandler) map[string]any {
+	if len(handlers) == 0 {
+		return nil
+	}
+	values := make(map[string]any, len(handlers))
+	for _, handler := range handlers {
+		values[handler.Name()] = handler.aggregate()
+	}
+	return values
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregate(t *testing.T) {
+	sum := Aggregate("used", func() uint64 { return 0 }, DecodeArray[decodedAccount](),
+		func(total uint64, account decodedAccount) uint64 { return total + account.Used })
+
+	var snapshots []uint64
+	sum.WithSnapshots(time.Hour, func(total uint64) { snapshots = append(snapshots, total) })
+
+	batch := func(used ...int) ProcessedAddressBatch {
+		values := make([]cadence.Value, 0, len(used))
+		for _, u := range used {
+			values = append(values, newAccountValue(u))
+		}
+		return ProcessedAddressBatch{Result: cadence.NewArray(values)}
+	}
+
+	require.NoError(t, sum.Handle(batch(1, 2)))
+	require.NoError(t, sum.Handle(batch(3)))
+	require.Equal(t, uint64(6), sum.Value())
+	// the second snapshot is within the interval of the first
+	require.Equal(t, []uint64{3}, snapshots)
+	require.Equal(t, map[string]any{"used": uint64(6)}, aggregates([]AggregateHandler{sum}))
+
+	require.Error(t, sum.Handle(ProcessedAddressBatch{Result: cadence.String("not an array")}))
+	require.Equal(t, uint64(6), sum.Value())
+
+	sum.reset()
+	require.Equal(t, uint64(0), sum.Value())
+	require.Nil(t, aggregates(nil))
+}
+
+func TestConfigWithAggregates(t *testing.T) {
+	counts := Aggregate("counts", func() map[string]int { return map[string]int{} }, DecodeArray[string](),
+		func(counts map[string]int, name string) map[string]int {
+			counts[name]++
+			return counts
+		})
+
+	config := DefaultConfig().WithAggregates(counts)
+	require.NoError(t, config.Validate())
+	require.Equal(t, []AggregateHandler{counts}, config.Aggregates)
+	require.Equal(t, []ScriptResultHandler{counts}, config.ScriptResultHandlers)
+
+	require.ErrorContains(t, config.WithAggregates(counts).Validate(), `duplicate aggregate "counts"`)
+	require.Error(t, DefaultConfig().WithAggregates(nil).Validate())
+}
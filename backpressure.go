@@ -0,0 +1,114 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"sync"
+)
+
+// BackpressurePolicy decides what the incremental scanner does when the address batch queue is full,
+// because script execution is falling behind.
+type BackpressurePolicy int
+
+const (
+	// BackpressureBlock waits until there is room in the queue. This is the default.
+	// While waiting the incremental scanner falls behind, which can eventually trigger a full scan.
+	BackpressureBlock BackpressurePolicy = iota
+	// BackpressureDropAndRequestFullScan drops the remaining batches of the block range
+	// and requests a full scan at the end of the block range instead.
+	BackpressureDropAndRequestFullScan
+	// BackpressureSpill puts the batches that do not fit in the queue in an unbounded overflow buffer,
+	// which is drained into the queue in order.
+	BackpressureSpill
+)
+
+// The names of the internal queues, see QueueStats.
+const (
+	FullScanRequestQueueName      = "full_scan_requests"
+	AddressBatchQueueName         = "address_batches"
+	AddressBatchOverflowQueueName = "address_batches_overflow"
+	FullScanBatchQueueName        = "full_scan_batches"
+	ScriptResultQueueName         = "script_results"
+	ScriptResultSpillQueueName    = "script_results_spilled"
+)
+
+// overflowBuffer is an unbounded queue of address batches, that is drained into a channel.
+type overflowBuffer struct {
+	mu      sync.Mutex
+	batches []AddressBatch
+	notify  chan struct{}
+}
+
+func newOverflowBuffer() *overflowBuffer {
+	return &overflowBuffer{
+		notify: make(chan struct{}, 1),
+	}
+}
+
+func (b *overflowBuffer) push(batch AddressBatch) {
+	b.mu.Lock()
+	b.batches = append(b.batches, batch)
+	b.mu.Unlock()
+
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (b *overflowBuffer) len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.batches)
+}
+
+func (b *overflowBuffer) peek() (AddressBatch, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.batches) == 0 {
+		return AddressBatch{}, false
+	}
+	return b.batches[0], true
+}
+
+func (b *overflowBuffer) pop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.batches[0] = AddressBatch{}
+	b.batches = b.batches[1:]
+}
+
+// drain sends the buffered batches to out, until the context is done.
+func (b *overflowBuffer) drain(ctx context.Context, out chan<- AddressBatch) {
+	for {
+		batch, ok := b.peek()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-b.notify:
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case out <- batch:
+			b.pop()
+		}
+	}
+}
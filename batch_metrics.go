@@ -0,0 +1,73 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"time"
+
+	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+	"github.com/onflow/flow-go-sdk"
+)
+
+// BatchMetrics describe an execution of the script of a batch, e.g. to attribute the cost on the access nodes
+// to the scan jobs, or to find the accounts that are expensive to scan.
+type BatchMetrics struct {
+	ScriptName  string
+	BlockHeight uint64
+	Addresses   []flow.Address
+	// FullScan is true for the batches of the full scan, false for the batches of the incremental scan.
+	FullScan bool
+	// Duration is how long it took to run the script.
+	Duration time.Duration
+	// Retries is how often the script of the batch (or of the batches it was split from) ran before.
+	Retries int
+	// ResultSize is the size of the JSON-Cadence encoded result in bytes, 0 if the script failed.
+	ResultSize int
+	// Err is the error of the script, nil if it succeeded.
+	Err error
+	// ErrorClass is the class of Err, e.g. ErrScriptComputationLimit, or nil if the class is not known.
+	ErrorClass error
+}
+
+// BatchMetricsObserver observes every execution of the script of a batch,
+// including the executions that failed and are retried, e.g. with a smaller batch.
+// ObserveBatch is called concurrently, and synchronously, so it should return quickly.
+type BatchMetricsObserver interface {
+	ObserveBatch(metrics BatchMetrics)
+}
+
+// observeBatch reports an execution of the script of the batch to the BatchMetricsObserver, if one is set.
+func (r *ScriptRunner) observeBatch(input AddressBatch, duration time.Duration, result cadence.Value, err error) {
+	if r.BatchMetricsObserver == nil {
+		return
+	}
+	metrics := BatchMetrics{
+		ScriptName:  input.ScriptName,
+		BlockHeight: input.BlockHeight,
+		Addresses:   append([]flow.Address(nil), input.Addresses...),
+		FullScan:    input.fromFullScan,
+		Duration:    duration,
+		Retries:     input.retries,
+		Err:         err,
+		ErrorClass:  ClassifyError(err),
+	}
+	if err == nil && result != nil {
+		if encoded, encodeErr := jsoncdc.Encode(result); encodeErr == nil {
+			metrics.ResultSize = len(encoded)
+		}
+	}
+	r.BatchMetricsObserver.ObserveBatch(metrics)
+}
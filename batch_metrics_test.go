@@ -0,0 +1,92 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+)
+
+type recordingBatchMetricsObserver struct {
+	mu      sync.Mutex
+	metrics []BatchMetrics
+}
+
+func (o *recordingBatchMetricsObserver) ObserveBatch(metrics BatchMetrics) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.metrics = append(o.metrics, metrics)
+}
+
+func TestScriptRunner_BatchMetricsObserver(t *testing.T) {
+	a1 := flow.HexToAddress("01")
+	a2 := flow.HexToAddress("02")
+
+	c := clienttest.New()
+	c.AddBlock(10)
+	c.HandleScripts(func(_ uint64, _ []byte, arguments []cadence.Value) (cadence.Value, error) {
+		addresses := arguments[0].(cadence.Array).Values
+		if len(addresses) > 1 {
+			return nil, errors.New("[Error Code: 1110] computation exceeds limit")
+		}
+		return cadence.NewBool(true), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	observer := &recordingBatchMetricsObserver{}
+	config := DefaultScriptRunnerConfig()
+	config.BatchMetricsObserver = observer
+	config.BisectFailedBatches = true
+	batches := make(chan AddressBatch, 1)
+	results := make(chan ProcessedAddressBatch, 2)
+	runner := NewScriptRunner(c, batches, results, config, zerolog.Nop())
+	<-runner.Start(ctx)
+
+	batches <- NewAddressBatch([]flow.Address{a1, a2}, 10, func() {}, nil)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-results:
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "batch was not handled")
+		}
+	}
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	require.Len(t, observer.metrics, 3)
+	failed := observer.metrics[0]
+	require.Equal(t, []flow.Address{a1, a2}, failed.Addresses)
+	require.Equal(t, uint64(10), failed.BlockHeight)
+	require.Equal(t, ErrScriptComputationLimit, failed.ErrorClass)
+	require.Zero(t, failed.ResultSize)
+	for _, split := range observer.metrics[1:] {
+		require.Len(t, split.Addresses, 1)
+		require.Equal(t, 1, split.Retries)
+		require.NoError(t, split.Err)
+		require.Positive(t, split.ResultSize)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+)
+
+// BatchPriority decides which address batches are executed first, when the incremental scanner
+// and a full scan both have batches waiting for script execution.
+type BatchPriority int
+
+const (
+	// PrioritizeIncremental executes the batches of the incremental scanner (and of the linked addresses)
+	// before the batches of the full scan, so a long full scan does not let the incremental scanner fall behind.
+	// This is the default.
+	PrioritizeIncremental BatchPriority = iota
+	// PrioritizeFullScan executes the batches of the full scan first.
+	PrioritizeFullScan
+	// PrioritizeNone executes the batches in the order they arrive.
+	PrioritizeNone
+)
+
+// batchPrioritizer forwards the address batches of two queues to the script runner,
+// always taking the next batch from the high priority queue if it has one.
+// out should be unbuffered, so the batches wait in the prioritized queues.
+type batchPrioritizer struct {
+	*ComponentBase
+
+	high <-chan AddressBatch
+	low  <-chan AddressBatch
+	out  chan<- AddressBatch
+}
+
+func newBatchPrioritizer(
+	high <-chan AddressBatch,
+	low <-chan AddressBatch,
+	out chan<- AddressBatch,
+	logger zerolog.Logger,
+) *batchPrioritizer {
+	p := &batchPrioritizer{
+		high: high,
+		low:  low,
+		out:  out,
+	}
+	p.ComponentBase = NewComponentWithStart(
+		"batch_prioritizer",
+		p.start,
+		logger,
+	)
+	return p
+}
+
+func (p *batchPrioritizer) start(ctx context.Context) {
+	go func() {
+		for {
+			var batch AddressBatch
+			select {
+			case batch = <-p.high:
+			default:
+				select {
+				case <-ctx.Done():
+					p.Finish(ctx.Err())
+					return
+				case batch = <-p.high:
+				case batch = <-p.low:
+				}
+			}
+			select {
+			case <-ctx.Done():
+				p.Finish(ctx.Err())
+				return
+			case p.out <- batch:
+			}
+		}
+	}()
+}
@@ -0,0 +1,49 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchPrioritizer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	high := make(chan AddressBatch, 2)
+	low := make(chan AddressBatch, 2)
+	out := make(chan AddressBatch)
+	low <- NewAddressBatch(nil, 1, nil, nil)
+	low <- NewAddressBatch(nil, 2, nil, nil)
+	high <- NewAddressBatch(nil, 3, nil, nil)
+	high <- NewAddressBatch(nil, 4, nil, nil)
+
+	p := newBatchPrioritizer(high, low, out, zerolog.Nop())
+	<-p.Start(ctx)
+
+	var heights []uint64
+	for i := 0; i < 4; i++ {
+		heights = append(heights, (<-out).BlockHeight)
+	}
+	require.Equal(t, []uint64{3, 4, 1, 2}, heights)
+
+	cancel()
+	<-p.Done()
+	require.ErrorIs(t, p.Err(), context.Canceled)
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"encoding/binary"
+
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+)
+
+// ProcessedAddressBatchMessage is the message that is signed for a ProcessedAddressBatch.
+// All integers are big endian uint64 and each field is length prefixed, so different batches
+// never have the same message:
+//
+//	block height | number of addresses | addresses (in order) | length of the result | JSON-CDC encoded result
+//
+// A batch without a result has a result of length 0.
+func ProcessedAddressBatchMessage(batch ProcessedAddressBatch) ([]byte, error) {
+	var result []byte
+	if batch.Result != nil {
+		var err error
+		result, err = jsoncdc.Encode(batch.Result)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	message := make([]byte, 0, 8+8+flow.AddressLength*len(batch.Addresses)+8+len(result))
+	message = binary.BigEndian.AppendUint64(message, batch.BlockHeight)
+	message = binary.BigEndian.AppendUint64(message, uint64(len(batch.Addresses)))
+	for _, address := range batch.Addresses {
+		message = append(message, address.Bytes()...)
+	}
+	message = binary.BigEndian.AppendUint64(message, uint64(len(result)))
+	return append(message, result...), nil
+}
+
+// SignProcessedAddressBatch signs the batch with the given signer.
+// The signer also hashes the message, so the hash algorithm is the one the signer was created with.
+func SignProcessedAddressBatch(batch ProcessedAddressBatch, signer crypto.Signer) ([]byte, error) {
+	message, err := ProcessedAddressBatchMessage(batch)
+	if err != nil {
+		return nil, err
+	}
+	return signer.Sign(message)
+}
+
+// VerifyProcessedAddressBatch verifies the signature of the batch against the public key of the scanner
+// that produced it.
+func VerifyProcessedAddressBatch(
+	batch ProcessedAddressBatch,
+	publicKey crypto.PublicKey,
+	hashAlgo crypto.HashAlgorithm,
+) (bool, error) {
+	message, err := ProcessedAddressBatchMessage(batch)
+	if err != nil {
+		return false, err
+	}
+	hasher, err := crypto.NewHasher(hashAlgo)
+	if err != nil {
+		return false, err
+	}
+	return publicKey.Verify(batch.Signature, message, hasher)
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner_test
+
+import (
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+	"github.com/stretchr/testify/require"
+
+	scan "github.com/onflow/flow-batch-scan"
+)
+
+func TestSignProcessedAddressBatch(t *testing.T) {
+	seed := make([]byte, crypto.MinSeedLength)
+	privateKey, err := crypto.GeneratePrivateKey(crypto.ECDSA_secp256k1, seed)
+	require.NoError(t, err)
+
+	signer, err := crypto.NewInMemorySigner(privateKey, crypto.SHA3_256)
+	require.NoError(t, err)
+
+	batch := scan.ProcessedAddressBatch{
+		AddressBatch: scan.NewAddressBatch(
+			[]flow.Address{flow.HexToAddress("0x1"), flow.HexToAddress("0x2")},
+			10,
+			nil,
+			nil,
+		),
+		Result: cadence.NewUInt64(42),
+	}
+
+	batch.Signature, err = scan.SignProcessedAddressBatch(batch, signer)
+	require.NoError(t, err)
+
+	t.Run("signature is valid", func(t *testing.T) {
+		valid, err := scan.VerifyProcessedAddressBatch(batch, privateKey.PublicKey(), crypto.SHA3_256)
+		require.NoError(t, err)
+		require.True(t, valid)
+	})
+
+	t.Run("modified result is not valid", func(t *testing.T) {
+		modified := batch
+		modified.Result = cadence.NewUInt64(43)
+
+		valid, err := scan.VerifyProcessedAddressBatch(modified, privateKey.PublicKey(), crypto.SHA3_256)
+		require.NoError(t, err)
+		require.False(t, valid)
+	})
+
+	t.Run("removed address is not valid", func(t *testing.T) {
+		modified := batch
+		modified.AddressBatch = scan.NewAddressBatch(batch.Addresses[:1], batch.BlockHeight, nil, nil)
+
+		valid, err := scan.VerifyProcessedAddressBatch(modified, privateKey.PublicKey(), crypto.SHA3_256)
+		require.NoError(t, err)
+		require.False(t, valid)
+	})
+
+	t.Run("modified height is not valid", func(t *testing.T) {
+		modified := batch
+		modified.BlockHeight = 11
+
+		valid, err := scan.VerifyProcessedAddressBatch(modified, privateKey.PublicKey(), crypto.SHA3_256)
+		require.NoError(t, err)
+		require.False(t, valid)
+	})
+}
+
+func TestProcessedAddressBatchMessage(t *testing.T) {
+	batch := scan.ProcessedAddressBatch{
+		AddressBatch: scan.NewAddressBatch([]flow.Address{flow.HexToAddress("0x1")}, 10, nil, nil),
+	}
+
+	message, err := scan.ProcessedAddressBatchMessage(batch)
+	require.NoError(t, err)
+	require.Equal(t, []byte{
+		0, 0, 0, 0, 0, 0, 0, 10, // block height
+		0, 0, 0, 0, 0, 0, 0, 1, // number of addresses
+		0, 0, 0, 0, 0, 0, 0, 1, // address
+		0, 0, 0, 0, 0, 0, 0, 0, // length of the result
+	}, message)
+}
@@ -0,0 +1,149 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"sync"
+)
+
+// DefaultMinBatchSize is the smallest batch size the adaptive batch size shrinks to.
+const DefaultMinBatchSize = 10
+
+// batchSizeGrowAfter is the number of consecutive successful batches at the current batch size,
+// after which the adaptive batch size grows.
+const batchSizeGrowAfter = 20
+
+// isLimitError is true if the script failed because it exceeded the computation or memory limit.
+func isLimitError(err error) bool {
+	class := ClassifyError(err)
+	return class == ErrScriptComputationLimit || class == ErrScriptMemoryLimit
+}
+
+// batchSizeController adapts the batch size to the computation and memory limits of scripts.
+// The batch size is halved on each limit error, and grows by a quarter after batchSizeGrowAfter
+// consecutive successful batches, up to the configured batch size.
+// A batchSizeController that is not adaptive stays at the configured batch size, which can be changed with resize.
+// A nil batchSizeController always uses the configured batch size.
+type batchSizeController struct {
+	mu        sync.Mutex
+	adaptive  bool
+	current   int
+	min       int
+	max       int
+	successes int
+
+	reporter StatusReporter
+}
+
+func newBatchSizeController(batchSize int, minBatchSize int, adaptive bool, reporter StatusReporter) *batchSizeController {
+	if minBatchSize < 1 || !adaptive {
+		minBatchSize = batchSize
+	}
+	if minBatchSize > batchSize {
+		minBatchSize = batchSize
+	}
+	c := &batchSizeController{
+		adaptive: adaptive,
+		current:  batchSize,
+		min:      minBatchSize,
+		max:      batchSize,
+		reporter: reporter,
+	}
+	reporter.ReportBatchSize(batchSize)
+	return c
+}
+
+// isAdaptive returns true if the batch size adapts to the limits of scripts, false for a nil controller.
+func (c *batchSizeController) isAdaptive() bool {
+	return c != nil && c.adaptive
+}
+
+// size returns the current batch size, or batchSize if the controller is nil.
+func (c *batchSizeController) size(batchSize int) int {
+	if c == nil {
+		return batchSize
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current
+}
+
+// limitExceeded shrinks the batch size after a batch of the given size exceeded a limit.
+func (c *batchSizeController) limitExceeded(failedSize int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.successes = 0
+
+	size := failedSize / 2
+	if size >= c.current {
+		// the batch size was already shrunk by another failed batch
+		return
+	}
+	if size < c.min {
+		size = c.min
+	}
+	c.set(size)
+}
+
+// succeeded grows the batch size after enough successful batches of the current size.
+func (c *batchSizeController) succeeded(size int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if size < c.current || c.current == c.max {
+		// smaller batches do not tell if the current size is comfortable
+		return
+	}
+	c.successes++
+	if c.successes < batchSizeGrowAfter {
+		return
+	}
+	c.successes = 0
+
+	grown := c.current + c.current/4
+	if grown == c.current {
+		grown++
+	}
+	if grown > c.max {
+		grown = c.max
+	}
+	c.set(grown)
+}
+
+// resize changes the configured batch size, and starts adapting from it.
+// A controller that is not adaptive stays fixed at the new batch size.
+func (c *batchSizeController) resize(batchSize int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.adaptive || c.min > batchSize {
+		c.min = batchSize
+	}
+	c.max = batchSize
+	c.successes = 0
+	c.set(batchSize)
+}
+
+func (c *batchSizeController) set(size int) {
+	if size == c.current {
+		return
+	}
+	c.current = size
+	c.reporter.ReportBatchSize(size)
+}
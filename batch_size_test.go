@@ -0,0 +1,132 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+)
+
+func TestBatchSizeController(t *testing.T) {
+	t.Run("nil controller uses the configured size", func(t *testing.T) {
+		var c *batchSizeController
+		require.Equal(t, 100, c.size(100))
+	})
+	t.Run("shrinks on limit errors", func(t *testing.T) {
+		c := newBatchSizeController(100, 10, true, NoOpStatusReporter{})
+		require.True(t, c.isAdaptive())
+		c.limitExceeded(100)
+		require.Equal(t, 50, c.size(100))
+
+		// a batch of the old size failing again does not shrink further
+		c.limitExceeded(100)
+		require.Equal(t, 50, c.size(100))
+
+		c.limitExceeded(50)
+		c.limitExceeded(25)
+		c.limitExceeded(12)
+		require.Equal(t, 10, c.size(100))
+	})
+	t.Run("grows after successes", func(t *testing.T) {
+		c := newBatchSizeController(100, 10, true, NoOpStatusReporter{})
+		c.limitExceeded(100)
+		for i := 0; i < batchSizeGrowAfter; i++ {
+			// smaller batches are ignored
+			c.succeeded(10)
+			c.succeeded(50)
+		}
+		require.Equal(t, 62, c.size(100))
+
+		for i := 0; i < 10*batchSizeGrowAfter; i++ {
+			c.succeeded(c.size(100))
+		}
+		require.Equal(t, 100, c.size(100))
+	})
+	t.Run("resizes", func(t *testing.T) {
+		c := newBatchSizeController(100, 10, true, NoOpStatusReporter{})
+		c.limitExceeded(100)
+		c.resize(200)
+		require.Equal(t, 200, c.size(100))
+		c.limitExceeded(200)
+		require.Equal(t, 100, c.size(100))
+
+		// a fixed batch size stays fixed
+		c = newBatchSizeController(100, 10, false, NoOpStatusReporter{})
+		require.False(t, c.isAdaptive())
+		c.resize(20)
+		c.limitExceeded(20)
+		require.Equal(t, 20, c.size(100))
+	})
+	t.Run("limit errors", func(t *testing.T) {
+		require.True(t, isLimitError(fmt.Errorf("[Error Code: 1110] computation exceeds limit (9999)")))
+		require.True(t, isLimitError(fmt.Errorf("[Error Code: 1111] memory usage exceeds limit")))
+		require.False(t, isLimitError(fmt.Errorf("[Error Code: 1204] account is frozen")))
+	})
+}
+
+func TestScriptRunner_LimitErrorsWithFixedBatchSize(t *testing.T) {
+	c := clienttest.New()
+	c.AddBlock(10)
+	c.HandleScripts(func(_ uint64, _ []byte, arguments []cadence.Value) (cadence.Value, error) {
+		for _, address := range arguments[0].(cadence.Array).Values {
+			if flow.Address(address.(cadence.Address)) == flow.HexToAddress("02") {
+				return nil, errors.New("[Error Code: 1110] computation exceeds limit (9999)")
+			}
+		}
+		return cadence.NewBool(true), nil
+	})
+
+	config := DefaultScriptRunnerConfig()
+	config.BisectFailedBatches = false
+	handled := make(chan AddressBatch, 1)
+	config.HandleScriptError = func(batch AddressBatch, err error) ScriptErrorAction {
+		handled <- batch
+		return ScriptErrorActionExclude{Addresses: []flow.Address{flow.HexToAddress("02")}}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	batches := make(chan AddressBatch, 1)
+	results := make(chan ProcessedAddressBatch, 2)
+	runner := NewScriptRunner(c, batches, results, config, zerolog.Nop())
+	// a batch size that is not adaptive does not split the batches that exceed the limits
+	runner.batchSizeController = newBatchSizeController(2, 1, false, NoOpStatusReporter{})
+	<-runner.Start(ctx)
+
+	batches <- NewAddressBatch([]flow.Address{flow.HexToAddress("01"), flow.HexToAddress("02")}, 10, func() {}, nil)
+	select {
+	case batch := <-handled:
+		require.Len(t, batch.Addresses, 2)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the limit error was not handled by HandleScriptError")
+	}
+	select {
+	case result := <-results:
+		require.Equal(t, []flow.Address{flow.HexToAddress("01")}, result.Addresses)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "batch was not handled")
+	}
+	require.Equal(t, 2, runner.batchSizeController.size(2))
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"time"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+)
+
+// candidateCoalescer collects candidates over multiple block ranges,
+// so that an address that is a candidate in consecutive block ranges is only scanned once.
+// It is not safe for concurrent use.
+type candidateCoalescer struct {
+	blocks   uint64
+	duration time.Duration
+
+	pending     candidates.CandidatesResult
+	startHeight uint64
+	startTime   time.Time
+}
+
+func newCandidateCoalescer(blocks uint64, duration time.Duration) *candidateCoalescer {
+	return &candidateCoalescer{
+		blocks:   blocks,
+		duration: duration,
+	}
+}
+
+// add adds the candidates found in the block range starting at start.
+// It returns the number of candidates that were already pending.
+func (c *candidateCoalescer) add(result candidates.CandidatesResult, start uint64, now time.Time) int {
+	if len(result.Addresses) == 0 {
+		return 0
+	}
+	if !c.isPending() {
+		c.startHeight = start
+		c.startTime = now
+	}
+
+	coalesced := 0
+	for address := range result.Addresses {
+		if _, ok := c.pending.Addresses[address]; ok {
+			coalesced++
+		}
+	}
+	c.pending.MergeWith(result)
+	return coalesced
+}
+
+func (c *candidateCoalescer) isPending() bool {
+	return len(c.pending.Addresses) > 0
+}
+
+// ready returns true if the pending candidates should be scanned at the end height.
+func (c *candidateCoalescer) ready(end uint64, now time.Time) bool {
+	if !c.isPending() {
+		return true
+	}
+	if c.blocks == 0 && c.duration == 0 {
+		return true
+	}
+	if c.blocks > 0 && end-c.startHeight+1 >= c.blocks {
+		return true
+	}
+	return c.duration > 0 && now.Sub(c.startTime) >= c.duration
+}
+
+// flush returns the pending candidates and clears them.
+func (c *candidateCoalescer) flush() candidates.CandidatesResult {
+	result := c.pending
+	c.pending = candidates.CandidatesResult{}
+	return result
+}
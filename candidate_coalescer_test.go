@@ -0,0 +1,93 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+)
+
+func TestCandidateCoalescer(t *testing.T) {
+	a1, a2, a3 := flow.HexToAddress("0x1"), flow.HexToAddress("0x2"), flow.HexToAddress("0x3")
+	now := time.Now()
+
+	candidatesOf := func(height uint64, addresses ...flow.Address) candidates.CandidatesResult {
+		result := candidates.NewCandidatesResult(make(map[flow.Address]struct{}))
+		for _, address := range addresses {
+			result.Add(address, candidates.Provenance{Scanner: "test", BlockHeight: height})
+		}
+		return result
+	}
+
+	t.Run("without limits the candidates are ready right away", func(t *testing.T) {
+		c := newCandidateCoalescer(0, 0)
+
+		require.Equal(t, 0, c.add(candidatesOf(1, a1), 1, now))
+		require.True(t, c.ready(1, now))
+		require.Equal(t, candidatesOf(1, a1).Addresses, c.flush().Addresses)
+		require.False(t, c.isPending())
+	})
+
+	t.Run("candidates are coalesced over blocks", func(t *testing.T) {
+		c := newCandidateCoalescer(10, 0)
+
+		require.Equal(t, 0, c.add(candidatesOf(1, a1, a2), 1, now))
+		require.False(t, c.ready(5, now))
+		// a1 is a candidate again, it is only scanned once
+		require.Equal(t, 1, c.add(candidatesOf(6, a1, a3), 6, now))
+		require.False(t, c.ready(9, now))
+		require.True(t, c.ready(10, now))
+
+		result := c.flush()
+		require.Equal(t, candidatesOf(1, a1, a2, a3).Addresses, result.Addresses)
+		// the provenance of both block ranges is kept
+		require.Equal(t, []candidates.Provenance{
+			{Scanner: "test", BlockHeight: 1},
+			{Scanner: "test", BlockHeight: 6},
+		}, result.Provenance[a1])
+	})
+
+	t.Run("candidates are coalesced over a duration", func(t *testing.T) {
+		c := newCandidateCoalescer(0, time.Minute)
+
+		c.add(candidatesOf(1, a1), 1, now)
+		require.False(t, c.ready(100, now.Add(30*time.Second)))
+		require.True(t, c.ready(100, now.Add(time.Minute)))
+	})
+
+	t.Run("the window starts with the first pending candidates", func(t *testing.T) {
+		c := newCandidateCoalescer(10, 0)
+
+		// block ranges without candidates do not start the window
+		require.Equal(t, 0, c.add(candidatesOf(1), 1, now))
+		require.False(t, c.isPending())
+		require.True(t, c.ready(5, now))
+
+		c.add(candidatesOf(6, a1), 6, now)
+		require.False(t, c.ready(14, now))
+		require.True(t, c.ready(15, now))
+		c.flush()
+
+		// after a flush the next candidates start a new window
+		c.add(candidatesOf(16, a2), 16, now)
+		require.False(t, c.ready(16, now))
+		require.True(t, c.ready(25, now))
+	})
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+)
+
+type scannedAt struct {
+	height uint64
+	time   time.Time
+}
+
+// candidateDebouncer holds back candidates that were scanned recently, until their cool-down has elapsed.
+// Held back candidates are not dropped, they are scanned when their cool-down has elapsed.
+// It is not safe for concurrent use.
+type candidateDebouncer struct {
+	blocks   uint64
+	duration time.Duration
+
+	scanned map[flow.Address]scannedAt
+	held    candidates.CandidatesResult
+	// heldFrom is the first block height of the block range each held candidate was first held back in.
+	heldFrom map[flow.Address]uint64
+}
+
+func newCandidateDebouncer(blocks uint64, duration time.Duration) *candidateDebouncer {
+	return &candidateDebouncer{
+		blocks:   blocks,
+		duration: duration,
+		scanned:  make(map[flow.Address]scannedAt),
+		held:     candidates.NewCandidatesResult(make(map[flow.Address]struct{})),
+		heldFrom: make(map[flow.Address]uint64),
+	}
+}
+
+func (d *candidateDebouncer) enabled() bool {
+	return d.blocks > 0 || d.duration > 0
+}
+
+func (d *candidateDebouncer) elapsed(scanned scannedAt, height uint64, now time.Time) bool {
+	return (d.blocks > 0 && height >= scanned.height+d.blocks) ||
+		(d.duration > 0 && now.Sub(scanned.time) >= d.duration)
+}
+
+// filter returns the candidates that should be scanned at the end of the block range.
+// These are the candidates that were not scanned recently, and the held candidates whose cool-down has elapsed.
+func (d *candidateDebouncer) filter(
+	result candidates.CandidatesResult,
+	start uint64,
+	end uint64,
+	now time.Time,
+) candidates.CandidatesResult {
+	if !d.enabled() {
+		return result
+	}
+
+	ready := candidates.NewCandidatesResult(make(map[flow.Address]struct{}))
+	for address := range d.held.Addresses {
+		if d.elapsed(d.scanned[address], end, now) {
+			moveCandidate(&d.held, &ready, address)
+			delete(d.heldFrom, address)
+		}
+	}
+
+	for address := range result.Addresses {
+		_, isHeld := d.held.Addresses[address]
+		scanned, wasScanned := d.scanned[address]
+		if !isHeld && (!wasScanned || d.elapsed(scanned, end, now)) {
+			moveCandidate(&result, &ready, address)
+			continue
+		}
+		if !isHeld {
+			d.heldFrom[address] = start
+		}
+		moveCandidate(&result, &d.held, address)
+	}
+
+	for address := range ready.Addresses {
+		d.scanned[address] = scannedAt{height: end, time: now}
+	}
+	for address, scanned := range d.scanned {
+		if _, isHeld := d.held.Addresses[address]; !isHeld && d.elapsed(scanned, end, now) {
+			delete(d.scanned, address)
+		}
+	}
+
+	return ready
+}
+
+// handledHeight is the height up to which all candidates were (or are being) scanned,
+// which is before the block range the candidate that is held back the longest was found in.
+func (d *candidateDebouncer) handledHeight(end uint64) uint64 {
+	handled := end
+	for _, from := range d.heldFrom {
+		if from-1 < handled {
+			handled = from - 1
+		}
+	}
+	return handled
+}
+
+// flush returns all the held candidates, regardless of their cool-down.
+func (d *candidateDebouncer) flush() candidates.CandidatesResult {
+	result := d.held
+	d.held = candidates.NewCandidatesResult(make(map[flow.Address]struct{}))
+	d.heldFrom = make(map[flow.Address]uint64)
+	return result
+}
+
+// moveCandidate moves the address and its provenance from one result to the other.
+func moveCandidate(from *candidates.CandidatesResult, to *candidates.CandidatesResult, address flow.Address) {
+	provenance := from.Provenance[address]
+	if len(provenance) == 0 {
+		to.Addresses[address] = struct{}{}
+	}
+	for _, p := range provenance {
+		to.Add(address, p)
+	}
+	delete(from.Addresses, address)
+	delete(from.Provenance, address)
+}
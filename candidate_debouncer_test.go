@@ -0,0 +1,90 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+)
+
+func TestCandidateDebouncer(t *testing.T) {
+	a1, a2 := flow.HexToAddress("0x1"), flow.HexToAddress("0x2")
+	now := time.Now()
+
+	candidatesOf := func(addresses ...flow.Address) candidates.CandidatesResult {
+		result := candidates.NewCandidatesResult(make(map[flow.Address]struct{}))
+		for _, address := range addresses {
+			result.Addresses[address] = struct{}{}
+		}
+		return result
+	}
+
+	d := newCandidateDebouncer(10, 0)
+
+	ready := d.filter(candidatesOf(a1), 1, 5, now)
+	require.Equal(t, candidatesOf(a1).Addresses, ready.Addresses)
+	require.Equal(t, uint64(5), d.handledHeight(5))
+
+	// a1 was just scanned, so it is held back
+	ready = d.filter(candidatesOf(a1, a2), 6, 10, now)
+	require.Equal(t, candidatesOf(a2).Addresses, ready.Addresses)
+	require.Equal(t, uint64(5), d.handledHeight(10))
+
+	// the cool-down of a1 has elapsed
+	ready = d.filter(candidatesOf(), 11, 15, now)
+	require.Equal(t, candidatesOf(a1).Addresses, ready.Addresses)
+	require.Equal(t, uint64(15), d.handledHeight(15))
+
+	// held back candidates are returned on flush
+	d.filter(candidatesOf(a1), 16, 16, now)
+	require.Equal(t, candidatesOf(a1).Addresses, d.flush().Addresses)
+	require.Equal(t, uint64(16), d.handledHeight(16))
+}
+
+func TestCandidateDebouncer_HandledHeight(t *testing.T) {
+	a1, a2 := flow.HexToAddress("0x1"), flow.HexToAddress("0x2")
+	now := time.Now()
+
+	candidatesOf := func(addresses ...flow.Address) candidates.CandidatesResult {
+		result := candidates.NewCandidatesResult(make(map[flow.Address]struct{}))
+		for _, address := range addresses {
+			result.Addresses[address] = struct{}{}
+		}
+		return result
+	}
+
+	d := newCandidateDebouncer(10, 0)
+	d.filter(candidatesOf(a1), 1, 5, now)
+	d.filter(candidatesOf(a2), 6, 8, now)
+
+	// a1 is held back from 9, a2 from 11
+	d.filter(candidatesOf(a1), 9, 10, now)
+	d.filter(candidatesOf(a2), 11, 14, now)
+	require.Equal(t, uint64(8), d.handledHeight(14))
+
+	// a1 was released, a2 is still held back
+	ready := d.filter(candidatesOf(), 15, 15, now)
+	require.Equal(t, candidatesOf(a1).Addresses, ready.Addresses)
+	require.Equal(t, uint64(10), d.handledHeight(15))
+
+	ready = d.filter(candidatesOf(), 16, 18, now)
+	require.Equal(t, candidatesOf(a2).Addresses, ready.Addresses)
+	require.Equal(t, uint64(18), d.handledHeight(18))
+}
@@ -0,0 +1,51 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates
+
+import (
+	"context"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+
+	"github.com/onflow/flow-batch-scan/client"
+)
+
+// AccountCreatedCandidatesScanner finds accounts that were created in the block range.
+// Accounts created after the reference block of the full scan are not part of the full scan,
+// so this scanner should be used if new accounts can match the script.
+type AccountCreatedCandidatesScanner struct {
+	scanner *EventCandidatesScanner
+}
+
+func NewAccountCreatedCandidatesScanner(logger zerolog.Logger) *AccountCreatedCandidatesScanner {
+	return &AccountCreatedCandidatesScanner{
+		scanner: NewEventCandidatesScanner(
+			flow.EventAccountCreated,
+			AddressFromEventField("address"),
+			logger,
+		),
+	}
+}
+
+var _ CandidateScanner = (*AccountCreatedCandidatesScanner)(nil)
+
+func (s *AccountCreatedCandidatesScanner) Scan(
+	ctx context.Context,
+	client client.Client,
+	blocks BlockRange,
+) CandidatesResult {
+	return s.scanner.Scan(ctx, client, blocks)
+}
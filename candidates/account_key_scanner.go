@@ -0,0 +1,56 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates
+
+import (
+	"context"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+
+	"github.com/onflow/flow-batch-scan/client"
+)
+
+// AccountKeyCandidatesScanner finds accounts that had keys added or removed.
+type AccountKeyCandidatesScanner struct {
+	scanners CandidateScanners
+}
+
+func NewAccountKeyCandidatesScanner(logger zerolog.Logger) *AccountKeyCandidatesScanner {
+	return &AccountKeyCandidatesScanner{
+		scanners: CandidateScanners{
+			NewEventCandidatesScanner(
+				flow.EventAccountKeyAdded,
+				AddressFromEventField("address"),
+				logger,
+			),
+			NewEventCandidatesScanner(
+				flow.EventAccountKeyRemoved,
+				AddressFromEventField("address"),
+				logger,
+			),
+		},
+	}
+}
+
+var _ CandidateScanner = (*AccountKeyCandidatesScanner)(nil)
+
+func (s *AccountKeyCandidatesScanner) Scan(
+	ctx context.Context,
+	client client.Client,
+	blocks BlockRange,
+) CandidatesResult {
+	return s.scanners.Scan(ctx, client, blocks)
+}
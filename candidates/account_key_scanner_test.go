@@ -0,0 +1,123 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+)
+
+func TestAccountKeyCandidatesScanner(t *testing.T) {
+	c := &eventsClient{
+		events: []flow.BlockEvents{
+			{Height: 10, Events: []flow.Event{loadEvent(t, "account_key_added.json")}},
+			{Height: 11, Events: []flow.Event{loadEvent(t, "account_key_removed.json")}},
+		},
+	}
+	scanner := candidates.NewAccountKeyCandidatesScanner(zerolog.Nop())
+
+	t.Run("finds added and removed keys", func(t *testing.T) {
+		result := scanner.Scan(context.Background(), c, candidates.BlockRange{Start: 10, End: 11})
+
+		require.NoError(t, result.Err())
+		require.Equal(t, map[flow.Address]struct{}{
+			flow.HexToAddress("e467b9dd11fa00df"): {},
+			flow.HexToAddress("1654653399040a61"): {},
+		}, result.Addresses)
+	})
+
+	t.Run("only looks at the block range", func(t *testing.T) {
+		result := scanner.Scan(context.Background(), c, candidates.BlockRange{Start: 11, End: 12})
+
+		require.NoError(t, result.Err())
+		require.Equal(t, map[flow.Address]struct{}{
+			flow.HexToAddress("1654653399040a61"): {},
+		}, result.Addresses)
+	})
+}
+
+func TestEventCandidatesScanner_Chunks(t *testing.T) {
+	c := &eventsClient{
+		events: []flow.BlockEvents{
+			{Height: 10, Events: []flow.Event{loadEvent(t, "account_key_added.json")}},
+			{Height: 15, Events: []flow.Event{loadEvent(t, "account_key_added.json")}},
+		},
+	}
+	scanner := candidates.NewEventCandidatesScanner(
+		flow.EventAccountKeyAdded,
+		candidates.AddressFromEventField("address"),
+		zerolog.Nop(),
+		candidates.WithEventQueryChunkSize(2),
+		candidates.WithEventQueryConcurrency(2),
+	)
+
+	result := scanner.Scan(context.Background(), c, candidates.BlockRange{Start: 10, End: 16})
+
+	require.NoError(t, result.Err())
+	require.Len(t, result.Provenance[flow.HexToAddress("e467b9dd11fa00df")], 2)
+}
+
+func TestBlockRange_Chunks(t *testing.T) {
+	require.Equal(t, []candidates.BlockRange{
+		{Start: 10, End: 12},
+		{Start: 13, End: 15},
+		{Start: 16, End: 16},
+	}, candidates.BlockRange{Start: 10, End: 16}.Chunks(3))
+
+	require.Equal(t, []candidates.BlockRange{
+		{Start: 10, End: 10},
+	}, candidates.BlockRange{Start: 10, End: 10}.Chunks(3))
+}
+
+func TestScanChunks(t *testing.T) {
+	address := flow.HexToAddress("0x1")
+	var running, maxRunning atomic.Int32
+
+	result := candidates.ScanChunks(candidates.BlockRange{Start: 1, End: 10}, 2, 3,
+		func(blocks candidates.BlockRange) candidates.CandidatesResult {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			// the later chunks finish first
+			time.Sleep(time.Duration(10-blocks.Start) * time.Millisecond)
+
+			result := candidates.NewCandidatesResult(make(map[flow.Address]struct{}))
+			result.Add(address, candidates.Provenance{BlockHeight: blocks.Start})
+			return result
+		})
+
+	require.NoError(t, result.Err())
+	require.LessOrEqual(t, maxRunning.Load(), int32(3))
+	require.Equal(t, []candidates.Provenance{
+		{BlockHeight: 1},
+		{BlockHeight: 3},
+		{BlockHeight: 5},
+		{BlockHeight: 7},
+		{BlockHeight: 9},
+	}, result.Provenance[address])
+}
@@ -26,16 +26,61 @@ import (
 	"github.com/onflow/flow-batch-scan/client"
 )
 
+const AuthorizerCandidatesScannerName = "authorizer_candidates_scanner"
+
+// AuthorizerCandidatesScanner finds the authorizers of all transactions in the block range.
+// By default, the payer and the proposer of the transactions are also candidates.
 type AuthorizerCandidatesScanner struct {
+	includePayer      bool
+	includeProposer   bool
+	transactionFilter func(tx *flow.Transaction) bool
+
+	name   string
 	logger zerolog.Logger
 }
 
-func NewAuthorizerCandidatesScanner(logger zerolog.Logger) AuthorizerCandidatesScanner {
-	return AuthorizerCandidatesScanner{
-		logger: logger.With().Str("component", "authorizer_candidates_scanner").Logger(),
+type AuthorizerCandidatesScannerOption = func(*AuthorizerCandidatesScanner)
+
+// WithPayer sets whether the payer of a transaction is a candidate. Defaults to true.
+func WithPayer(include bool) AuthorizerCandidatesScannerOption {
+	return func(s *AuthorizerCandidatesScanner) {
+		s.includePayer = include
+	}
+}
+
+// WithProposer sets whether the proposer of a transaction is a candidate. Defaults to true.
+func WithProposer(include bool) AuthorizerCandidatesScannerOption {
+	return func(s *AuthorizerCandidatesScanner) {
+		s.includeProposer = include
 	}
 }
 
+// WithTransactionFilter only looks at the transactions for which the filter returns true.
+func WithTransactionFilter(filter func(tx *flow.Transaction) bool) AuthorizerCandidatesScannerOption {
+	return func(s *AuthorizerCandidatesScanner) {
+		s.transactionFilter = filter
+	}
+}
+
+func NewAuthorizerCandidatesScanner(
+	logger zerolog.Logger,
+	options ...AuthorizerCandidatesScannerOption,
+) AuthorizerCandidatesScanner {
+	s := AuthorizerCandidatesScanner{
+		includePayer:    true,
+		includeProposer: true,
+
+		name:   AuthorizerCandidatesScannerName,
+		logger: logger.With().Str("component", AuthorizerCandidatesScannerName).Logger(),
+	}
+
+	for _, option := range options {
+		option(&s)
+	}
+
+	return s
+}
+
 var _ CandidateScanner = AuthorizerCandidatesScanner{}
 
 func (s AuthorizerCandidatesScanner) Scan(
@@ -96,7 +141,7 @@ func (s AuthorizerCandidatesScanner) scanBlock(
 
 	for _, guarantee := range block.CollectionGuarantees {
 		go func(guarantee *flow.CollectionGuarantee) {
-			candidatesChan <- s.scanCollection(ctx, client, guarantee.CollectionID)
+			candidatesChan <- s.scanCollection(ctx, client, blockHeight, guarantee.CollectionID)
 		}(guarantee)
 	}
 
@@ -106,6 +151,7 @@ func (s AuthorizerCandidatesScanner) scanBlock(
 func (s AuthorizerCandidatesScanner) scanCollection(
 	ctx context.Context,
 	client client.Client,
+	blockHeight uint64,
 	collectionID flow.Identifier,
 ) CandidatesResult {
 	coll, err := s.GetCollection(client, ctx, collectionID)
@@ -125,7 +171,7 @@ func (s AuthorizerCandidatesScanner) scanCollection(
 
 	for _, transactionID := range coll.TransactionIDs {
 		go func(transactionID flow.Identifier) {
-			candidatesChan <- s.scanTransaction(ctx, client, transactionID)
+			candidatesChan <- s.scanTransaction(ctx, client, blockHeight, transactionID)
 		}(transactionID)
 	}
 
@@ -135,6 +181,7 @@ func (s AuthorizerCandidatesScanner) scanCollection(
 func (s AuthorizerCandidatesScanner) scanTransaction(
 	ctx context.Context,
 	client client.Client,
+	blockHeight uint64,
 	TransactionId flow.Identifier,
 ) CandidatesResult {
 	tx, err := client.GetTransaction(ctx, TransactionId)
@@ -145,14 +192,27 @@ func (s AuthorizerCandidatesScanner) scanTransaction(
 		return NewCandidatesResultError(err)
 	}
 
-	addresses := make(map[flow.Address]struct{}, len(tx.Authorizers))
+	if s.transactionFilter != nil && !s.transactionFilter(tx) {
+		return NewCandidatesResult(nil)
+	}
+
+	provenance := Provenance{
+		Scanner:       s.name,
+		BlockHeight:   blockHeight,
+		TransactionID: TransactionId,
+	}
+	result := NewCandidatesResult(make(map[flow.Address]struct{}, len(tx.Authorizers)))
 	for _, authorizer := range tx.Authorizers {
-		addresses[authorizer] = struct{}{}
+		result.Add(authorizer, provenance)
+	}
+	if s.includePayer {
+		result.Add(tx.Payer, provenance)
+	}
+	if s.includeProposer {
+		result.Add(tx.ProposalKey.Address, provenance)
 	}
-	addresses[tx.Payer] = struct{}{}
-	addresses[tx.ProposalKey.Address] = struct{}{}
 
-	return NewCandidatesResult(addresses)
+	return result
 }
 
 func (s AuthorizerCandidatesScanner) GetCollection(
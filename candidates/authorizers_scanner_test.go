@@ -0,0 +1,81 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+)
+
+func TestAuthorizerCandidatesScanner(t *testing.T) {
+	authorizer := flow.HexToAddress("0x1")
+	payer := flow.HexToAddress("0x2")
+	proposer := flow.HexToAddress("0x3")
+
+	c := clienttest.New()
+	c.AddBlock(10, clienttest.Transaction{
+		Authorizers: []flow.Address{authorizer},
+		Payer:       payer,
+		Proposer:    proposer,
+	})
+	blocks := candidates.BlockRange{Start: 10, End: 10}
+
+	t.Run("payer and proposer are candidates by default", func(t *testing.T) {
+		scanner := candidates.NewAuthorizerCandidatesScanner(zerolog.Nop())
+
+		result := scanner.Scan(context.Background(), c, blocks)
+
+		require.NoError(t, result.Err())
+		require.Equal(t, map[flow.Address]struct{}{
+			authorizer: {},
+			payer:      {},
+			proposer:   {},
+		}, result.Addresses)
+	})
+
+	t.Run("without payer", func(t *testing.T) {
+		scanner := candidates.NewAuthorizerCandidatesScanner(zerolog.Nop(), candidates.WithPayer(false))
+
+		result := scanner.Scan(context.Background(), c, blocks)
+
+		require.NoError(t, result.Err())
+		require.Equal(t, map[flow.Address]struct{}{
+			authorizer: {},
+			proposer:   {},
+		}, result.Addresses)
+	})
+
+	t.Run("only authorizers", func(t *testing.T) {
+		scanner := candidates.NewAuthorizerCandidatesScanner(zerolog.Nop(),
+			candidates.WithPayer(false),
+			candidates.WithProposer(false),
+		)
+
+		result := scanner.Scan(context.Background(), c, blocks)
+
+		require.NoError(t, result.Err())
+		require.Equal(t, map[flow.Address]struct{}{
+			authorizer: {},
+		}, result.Addresses)
+		require.Equal(t, uint64(10), result.Provenance[authorizer][0].BlockHeight)
+	})
+}
@@ -16,6 +16,7 @@ package candidates
 
 import (
 	"context"
+	"sync"
 
 	"github.com/hashicorp/go-multierror"
 
@@ -31,9 +32,84 @@ type BlockRange struct {
 	End   uint64 // inclusive
 }
 
+// Chunks splits the block range into consecutive block ranges of at most size blocks.
+func (r BlockRange) Chunks(size uint64) []BlockRange {
+	if size == 0 || r.End < r.Start {
+		return []BlockRange{r}
+	}
+	chunks := make([]BlockRange, 0, (r.End-r.Start)/size+1)
+	for start := r.Start; start <= r.End; start += size {
+		end := start + size - 1
+		if end > r.End || end < start {
+			end = r.End
+		}
+		chunks = append(chunks, BlockRange{Start: start, End: end})
+		if end == r.End {
+			break
+		}
+	}
+	return chunks
+}
+
+// ScanChunks splits the block range into chunks of at most chunkSize blocks and scans them with scan,
+// with at most concurrency chunks being scanned at the same time.
+// The results are merged in the order of the chunks, so the provenance of each address is ordered by block height,
+// no matter which chunk was scanned first.
+func ScanChunks(
+	blocks BlockRange,
+	chunkSize uint64,
+	concurrency int,
+	scan func(blocks BlockRange) CandidatesResult,
+) CandidatesResult {
+	chunks := blocks.Chunks(chunkSize)
+	if len(chunks) == 1 {
+		return scan(chunks[0])
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	results := make([]CandidatesResult, len(chunks))
+	wg := sync.WaitGroup{}
+	limit := make(chan struct{}, concurrency)
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk BlockRange) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			results[i] = scan(chunk)
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	result := CandidatesResult{}
+	for _, r := range results {
+		result.MergeWith(r)
+	}
+	return result
+}
+
+// Provenance describes why an address is a candidate.
+type Provenance struct {
+	// Scanner is the name of the candidate scanner that found the address.
+	Scanner string
+	// BlockHeight is the height of the block that caused the address to be a candidate.
+	BlockHeight uint64
+	// TransactionID is the transaction that caused the address to be a candidate, if known.
+	TransactionID flow.Identifier
+	// EventType is the type of the event that caused the address to be a candidate, if any.
+	EventType string
+	// EventIndex is the index of the event in its transaction, if EventType is set.
+	EventIndex int
+}
+
 type CandidatesResult struct {
 	Addresses map[flow.Address]struct{}
-	err       error
+	// Provenance contains the reasons why each address is a candidate.
+	// Scanners that do not know why an address is a candidate do not add provenance for it.
+	Provenance map[flow.Address][]Provenance
+	err        error
 }
 
 func NewCandidatesResult(addresses map[flow.Address]struct{}) CandidatesResult {
@@ -48,8 +124,26 @@ func NewCandidatesResultError(err error) CandidatesResult {
 	}
 }
 
+// Add adds a candidate address along with the reason it is a candidate.
+func (r *CandidatesResult) Add(address flow.Address, provenance Provenance) {
+	if r.Addresses == nil {
+		r.Addresses = make(map[flow.Address]struct{})
+	}
+	if r.Provenance == nil {
+		r.Provenance = make(map[flow.Address][]Provenance)
+	}
+	r.Addresses[address] = struct{}{}
+	r.Provenance[address] = append(r.Provenance[address], provenance)
+}
+
 func (r *CandidatesResult) MergeWith(r2 CandidatesResult) {
 	r.Addresses = utils.MergeInto(r.Addresses, r2.Addresses)
+	for address, provenance := range r2.Provenance {
+		if r.Provenance == nil {
+			r.Provenance = make(map[flow.Address][]Provenance)
+		}
+		r.Provenance[address] = append(r.Provenance[address], provenance...)
+	}
 	r.err = multierror.Append(r.err, r2.err)
 }
 
@@ -64,6 +158,24 @@ type CandidateScanner interface {
 	Scan(ctx context.Context, client client.Client, blocks BlockRange) CandidatesResult
 }
 
+// CandidateScanners runs all the scanners concurrently and merges their results.
+type CandidateScanners []CandidateScanner
+
+var _ CandidateScanner = CandidateScanners{}
+
+func (s CandidateScanners) Scan(ctx context.Context, client client.Client, blocks BlockRange) CandidatesResult {
+	results := make(chan CandidatesResult, len(s))
+	defer close(results)
+
+	for _, scanner := range s {
+		go func(scanner CandidateScanner) {
+			results <- scanner.Scan(ctx, client, blocks)
+		}(scanner)
+	}
+
+	return WaitForCandidateResults(results, len(s))
+}
+
 func WaitForCandidateResults(
 	candidatesChan <-chan CandidatesResult,
 	expectedResults int,
@@ -0,0 +1,108 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates
+
+import (
+	"context"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+
+	"github.com/onflow/flow-batch-scan/client"
+)
+
+// The events emitted when the capabilities of an account change.
+const (
+	EventStorageCapabilityControllerIssued        = "flow.StorageCapabilityControllerIssued"
+	EventAccountCapabilityControllerIssued        = "flow.AccountCapabilityControllerIssued"
+	EventCapabilityControllerDeleted              = "flow.CapabilityControllerDeleted"
+	EventStorageCapabilityControllerTargetChanged = "flow.StorageCapabilityControllerTargetChanged"
+	EventCapabilityPublished                      = "flow.CapabilityPublished"
+	EventCapabilityUnpublished                    = "flow.CapabilityUnpublished"
+	EventInboxValuePublished                      = "flow.InboxValuePublished"
+	EventInboxValueUnpublished                    = "flow.InboxValueUnpublished"
+	EventInboxValueClaimed                        = "flow.InboxValueClaimed"
+)
+
+// capabilityEventAddressFields are the address fields of the capability events.
+// The controller events have the address of the account that issued the capability,
+// and the inbox events the provider of the capability and its recipient (if the event has one).
+var capabilityEventAddressFields = []struct {
+	eventType string
+	fields    []string
+}{
+	{EventStorageCapabilityControllerIssued, []string{"address"}},
+	{EventAccountCapabilityControllerIssued, []string{"address"}},
+	{EventCapabilityControllerDeleted, []string{"address"}},
+	{EventStorageCapabilityControllerTargetChanged, []string{"address"}},
+	{EventCapabilityPublished, []string{"address"}},
+	{EventCapabilityUnpublished, []string{"address"}},
+	{EventInboxValuePublished, []string{"provider", "recipient"}},
+	{EventInboxValueUnpublished, []string{"provider"}},
+	{EventInboxValueClaimed, []string{"provider", "recipient"}},
+}
+
+// CapabilityCandidatesScanner finds accounts whose capabilities changed: capability controllers that were issued,
+// retargeted or deleted, capabilities that were published or unpublished, and capabilities passed through an inbox,
+// for which both the provider and the recipient are candidates.
+type CapabilityCandidatesScanner struct {
+	scanners CandidateScanners
+}
+
+func NewCapabilityCandidatesScanner(
+	logger zerolog.Logger,
+	options ...EventCandidatesScannerOption,
+) *CapabilityCandidatesScanner {
+	scanners := make(CandidateScanners, 0, len(capabilityEventAddressFields))
+	for _, event := range capabilityEventAddressFields {
+		scanners = append(scanners, NewEventCandidatesScannerForAddresses(
+			event.eventType,
+			addressesFromEventFields(event.fields...),
+			logger,
+			options...,
+		))
+	}
+	return &CapabilityCandidatesScanner{
+		scanners: scanners,
+	}
+}
+
+var _ CandidateScanner = (*CapabilityCandidatesScanner)(nil)
+
+func (s *CapabilityCandidatesScanner) Scan(
+	ctx context.Context,
+	client client.Client,
+	blocks BlockRange,
+) CandidatesResult {
+	return s.scanners.Scan(ctx, client, blocks)
+}
+
+// addressesFromEventFields returns a function that gets the addresses of the (optional) address fields
+// with the given names. The fields are looked up by name, so the other fields of the events,
+// like the borrow type of the capability, are never looked at.
+func addressesFromEventFields(names ...string) func(event cadence.Event) ([]flow.Address, error) {
+	return func(event cadence.Event) ([]flow.Address, error) {
+		var addresses []flow.Address
+		for _, name := range names {
+			fieldAddresses, err := OptionalAddressFromEventField(name)(event)
+			if err != nil {
+				return nil, err
+			}
+			addresses = append(addresses, fieldAddresses...)
+		}
+		return addresses, nil
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+)
+
+func TestCapabilityCandidatesScanner(t *testing.T) {
+	c := &eventsClient{
+		events: []flow.BlockEvents{
+			{Height: 10, Events: []flow.Event{loadEvent(t, "storage_capability_controller_issued.json")}},
+			{Height: 11, Events: []flow.Event{loadEvent(t, "inbox_value_claimed.json")}},
+		},
+	}
+	scanner := candidates.NewCapabilityCandidatesScanner(zerolog.Nop())
+
+	result := scanner.Scan(context.Background(), c, candidates.BlockRange{Start: 10, End: 11})
+
+	require.NoError(t, result.Err())
+	require.Equal(t, map[flow.Address]struct{}{
+		flow.HexToAddress("e467b9dd11fa00df"): {},
+		flow.HexToAddress("1654653399040a61"): {},
+		flow.HexToAddress("f233dcee88fe0abe"): {},
+	}, result.Addresses)
+	provenance := result.Provenance[flow.HexToAddress("e467b9dd11fa00df")]
+	require.Len(t, provenance, 1)
+	require.Equal(t, candidates.EventStorageCapabilityControllerIssued, provenance[0].EventType)
+}
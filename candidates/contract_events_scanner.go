@@ -0,0 +1,161 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+
+	"github.com/onflow/flow-batch-scan/client"
+)
+
+const ContractEventsCandidatesScannerName = "contract_events_candidates_scanner"
+
+// ContractEventsCandidatesScanner finds candidates in all events with a type matching a pattern,
+// e.g. `A.1654653399040a61.FlowToken.*` for all events of the FlowToken contract.
+//
+// The access API can only query events by their exact type, so this scanner gets the
+// transaction results of every block in the range instead, which is a lot more expensive
+// than the EventCandidatesScanner.
+type ContractEventsCandidatesScanner struct {
+	pattern                     string
+	candidateAddressesFromEvent func(event cadence.Event) ([]flow.Address, error)
+
+	logger zerolog.Logger
+}
+
+// NewContractEventsCandidatesScanner creates a new ContractEventsCandidatesScanner.
+// The pattern uses the syntax of path.Match, where `*` matches any part of the event type.
+// AddressesFromEventFields can be used to get the candidates from events of different types.
+func NewContractEventsCandidatesScanner(
+	pattern string,
+	candidateAddressesFromEvent func(event cadence.Event) ([]flow.Address, error),
+	logger zerolog.Logger,
+) (*ContractEventsCandidatesScanner, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid event type pattern %s: %w", pattern, err)
+	}
+
+	return &ContractEventsCandidatesScanner{
+		pattern:                     pattern,
+		candidateAddressesFromEvent: candidateAddressesFromEvent,
+
+		logger: logger.With().
+			Str("component", ContractEventsCandidatesScannerName).
+			Str("pattern", pattern).
+			Logger(),
+	}, nil
+}
+
+var _ CandidateScanner = (*ContractEventsCandidatesScanner)(nil)
+
+func (s *ContractEventsCandidatesScanner) Scan(
+	ctx context.Context,
+	client client.Client,
+	blocks BlockRange,
+) CandidatesResult {
+	candidatesChan := make(chan CandidatesResult, blocks.End-blocks.Start+1)
+	defer close(candidatesChan)
+
+	for blockHeight := blocks.Start; blockHeight <= blocks.End; blockHeight++ {
+		go func(blockHeight uint64) {
+			candidatesChan <- s.scanBlock(ctx, client, blockHeight)
+		}(blockHeight)
+	}
+
+	candidates := WaitForCandidateResults(candidatesChan, int(blocks.End-blocks.Start+1))
+
+	if candidates.Err() != nil {
+		return candidates
+	}
+
+	s.logger.
+		Debug().
+		Int("count", len(candidates.Addresses)).
+		Uint64("start", blocks.Start).
+		Uint64("end", blocks.End).
+		Msg("Found contract event candidates")
+
+	return candidates
+}
+
+func (s *ContractEventsCandidatesScanner) scanBlock(
+	ctx context.Context,
+	client client.Client,
+	blockHeight uint64,
+) CandidatesResult {
+	header, err := client.GetBlockHeaderByHeight(ctx, blockHeight)
+	if err != nil {
+		if !isCancellationError(err) {
+			s.logger.Error().
+				Err(err).
+				Uint64("block_height", blockHeight).
+				Msg("Could not get block header by height.")
+		}
+		return NewCandidatesResultError(err)
+	}
+
+	results, err := client.GetTransactionResultsByBlockID(ctx, header.ID)
+	if err != nil {
+		if !isCancellationError(err) {
+			s.logger.Error().
+				Err(err).
+				Uint64("block_height", blockHeight).
+				Str("block_id", header.ID.Hex()).
+				Msg("Could not get transaction results.")
+		}
+		return NewCandidatesResultError(err)
+	}
+
+	result := NewCandidatesResult(make(map[flow.Address]struct{}))
+	for _, txResult := range results {
+		for _, event := range txResult.Events {
+			if !s.matches(event.Type) {
+				continue
+			}
+			addresses, err := s.candidateAddressesFromEvent(event.Value)
+			if err != nil {
+				s.logger.Error().
+					Err(err).
+					Uint64("block_height", blockHeight).
+					Str("event_type", event.Type).
+					Str("event", event.String()).
+					Msg("could not get candidate address from event")
+				return NewCandidatesResultError(err)
+			}
+			for _, address := range addresses {
+				result.Add(address, Provenance{
+					Scanner:       ContractEventsCandidatesScannerName,
+					BlockHeight:   blockHeight,
+					TransactionID: event.TransactionID,
+					EventType:     event.Type,
+					EventIndex:    event.EventIndex,
+				})
+			}
+		}
+	}
+	return result
+}
+
+func (s *ContractEventsCandidatesScanner) matches(eventType string) bool {
+	// the pattern was validated in the constructor
+	matched, _ := path.Match(s.pattern, eventType)
+	return matched
+}
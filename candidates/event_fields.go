@@ -0,0 +1,91 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates
+
+import (
+	"fmt"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+)
+
+// EventField returns the value of the field with the given name.
+// Looking up fields by name is more robust than using the field index,
+// as contracts can add fields to their events.
+func EventField(event cadence.Event, name string) (cadence.Value, error) {
+	if event.EventType == nil {
+		return nil, fmt.Errorf("event has no type, cannot get field %s", name)
+	}
+	for i, field := range event.EventType.Fields {
+		if field.Identifier == name && i < len(event.Fields) {
+			return event.Fields[i], nil
+		}
+	}
+	return nil, fmt.Errorf("event %s has no field %s", event.EventType.ID(), name)
+}
+
+// AddressFromEventField returns a function that gets the address from the field with the given name.
+// It can be used with NewEventCandidatesScanner.
+func AddressFromEventField(name string) func(event cadence.Event) (flow.Address, error) {
+	return func(event cadence.Event) (flow.Address, error) {
+		value, err := EventField(event, name)
+		if err != nil {
+			return flow.EmptyAddress, err
+		}
+		address, ok := value.(cadence.Address)
+		if !ok {
+			return flow.EmptyAddress, fmt.Errorf("event field %s is not an address: %s", name, value)
+		}
+		return flow.BytesToAddress(address.Bytes()), nil
+	}
+}
+
+// OptionalAddressFromEventField returns a function that gets the address from the field with the given name.
+// The field can be an optional address, in which case no address is returned if it is nil.
+// It can be used with NewEventCandidatesScannerForAddresses.
+func OptionalAddressFromEventField(name string) func(event cadence.Event) ([]flow.Address, error) {
+	return func(event cadence.Event) ([]flow.Address, error) {
+		value, err := EventField(event, name)
+		if err != nil {
+			return nil, err
+		}
+		if optional, ok := value.(cadence.Optional); ok {
+			if optional.Value == nil {
+				return nil, nil
+			}
+			value = optional.Value
+		}
+		address, ok := value.(cadence.Address)
+		if !ok {
+			return nil, fmt.Errorf("event field %s is not an address: %s", name, value)
+		}
+		return []flow.Address{flow.BytesToAddress(address.Bytes())}, nil
+	}
+}
+
+// AddressesFromEventFields returns the addresses of all the address (or optional address) fields of the event.
+// It can be used when the event type is not known in advance, e.g. with NewContractEventsCandidatesScanner.
+func AddressesFromEventFields(event cadence.Event) ([]flow.Address, error) {
+	var addresses []flow.Address
+	for _, value := range event.Fields {
+		if optional, ok := value.(cadence.Optional); ok {
+			value = optional.Value
+		}
+		if address, ok := value.(cadence.Address); ok {
+			addresses = append(addresses, flow.BytesToAddress(address.Bytes()))
+		}
+	}
+	return addresses, nil
+}
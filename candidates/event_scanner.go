@@ -25,24 +25,86 @@ import (
 	"github.com/onflow/flow-batch-scan/client"
 )
 
+const EventCandidatesScannerName = "event_candidates_scanner"
+
+// DefaultEventQueryChunkSize is the maximum number of blocks queried for events at once.
+// Access nodes reject event queries for more than 250 blocks.
+const DefaultEventQueryChunkSize = 250
+
+// DefaultEventQueryConcurrency is the maximum number of concurrent event queries of one scanner.
+const DefaultEventQueryConcurrency = 5
+
 type EventCandidatesScanner struct {
-	eventType                 string
-	candidateAddressFromEvent func(event cadence.Event) (flow.Address, error)
+	eventType                   string
+	candidateAddressesFromEvent func(event cadence.Event) ([]flow.Address, error)
+
+	chunkSize   uint64
+	concurrency int
 
 	logger zerolog.Logger
 }
 
+type EventCandidatesScannerOption = func(*EventCandidatesScanner)
+
+// WithEventQueryChunkSize sets the maximum number of blocks queried for events at once.
+// Larger block ranges are split into multiple queries. Defaults to DefaultEventQueryChunkSize.
+func WithEventQueryChunkSize(size uint64) EventCandidatesScannerOption {
+	return func(s *EventCandidatesScanner) {
+		s.chunkSize = size
+	}
+}
+
+// WithEventQueryConcurrency sets the maximum number of concurrent event queries,
+// when a block range is split into multiple queries. Defaults to DefaultEventQueryConcurrency.
+func WithEventQueryConcurrency(concurrency int) EventCandidatesScannerOption {
+	return func(s *EventCandidatesScanner) {
+		s.concurrency = concurrency
+	}
+}
+
 func NewEventCandidatesScanner(
 	eventType string,
 	candidateAddressFromEvent func(event cadence.Event) (flow.Address, error),
 	logger zerolog.Logger,
+	options ...EventCandidatesScannerOption,
+) *EventCandidatesScanner {
+	return NewEventCandidatesScannerForAddresses(
+		eventType,
+		func(event cadence.Event) ([]flow.Address, error) {
+			address, err := candidateAddressFromEvent(event)
+			if err != nil {
+				return nil, err
+			}
+			return []flow.Address{address}, nil
+		},
+		logger,
+		options...,
+	)
+}
+
+// NewEventCandidatesScannerForAddresses is like NewEventCandidatesScanner,
+// but each event can produce any number of candidates (including none).
+func NewEventCandidatesScannerForAddresses(
+	eventType string,
+	candidateAddressesFromEvent func(event cadence.Event) ([]flow.Address, error),
+	logger zerolog.Logger,
+	options ...EventCandidatesScannerOption,
 ) *EventCandidatesScanner {
-	return &EventCandidatesScanner{
-		eventType:                 eventType,
-		candidateAddressFromEvent: candidateAddressFromEvent,
+	s := &EventCandidatesScanner{
+		eventType:                   eventType,
+		candidateAddressesFromEvent: candidateAddressesFromEvent,
+
+		chunkSize:   DefaultEventQueryChunkSize,
+		concurrency: DefaultEventQueryConcurrency,
 
-		logger: logger.With().Str("component", "authorizer_candidates_scanner").Logger(),
+		logger: logger.With().Str("component", EventCandidatesScannerName).Logger(),
 	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
 }
 
 var _ CandidateScanner = (*EventCandidatesScanner)(nil)
@@ -51,6 +113,16 @@ func (s *EventCandidatesScanner) Scan(
 	ctx context.Context,
 	client client.Client,
 	blocks BlockRange,
+) CandidatesResult {
+	return ScanChunks(blocks, s.chunkSize, s.concurrency, func(chunk BlockRange) CandidatesResult {
+		return s.scanChunk(ctx, client, chunk)
+	})
+}
+
+func (s *EventCandidatesScanner) scanChunk(
+	ctx context.Context,
+	client client.Client,
+	blocks BlockRange,
 ) CandidatesResult {
 	l := s.logger.With().
 		Uint64("start", blocks.Start).
@@ -69,10 +141,10 @@ func (s *EventCandidatesScanner) Scan(
 			Msg("could not get events")
 		return NewCandidatesResultError(err)
 	}
-	addresses := make(map[flow.Address]struct{})
+	result := NewCandidatesResult(make(map[flow.Address]struct{}))
 	for _, events := range blockEvents {
 		for _, event := range events.Events {
-			address, err := s.candidateAddressFromEvent(event.Value)
+			eventAddresses, err := s.candidateAddressesFromEvent(event.Value)
 			if err != nil {
 				l.Error().
 					Err(err).
@@ -82,13 +154,21 @@ func (s *EventCandidatesScanner) Scan(
 					Msg("could not get candidate address from event")
 				return NewCandidatesResultError(err)
 			}
-			addresses[address] = struct{}{}
+			for _, address := range eventAddresses {
+				result.Add(address, Provenance{
+					Scanner:       EventCandidatesScannerName,
+					BlockHeight:   events.Height,
+					TransactionID: event.TransactionID,
+					EventType:     s.eventType,
+					EventIndex:    event.EventIndex,
+				})
+			}
 		}
 	}
 	l.Debug().
-		Int("count", len(addresses)).
+		Int("count", len(result.Addresses)).
 		Str("event_type", s.eventType).
 		Msg("Found event candidates")
 
-	return NewCandidatesResult(addresses)
+	return result
 }
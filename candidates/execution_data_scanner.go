@@ -0,0 +1,134 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates
+
+import (
+	"context"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow/protobuf/go/flow/entities"
+	"github.com/rs/zerolog"
+
+	"github.com/onflow/flow-batch-scan/client"
+)
+
+// keyPartOwner is the type of the ledger key part that holds the owner (address) of a register.
+const keyPartOwner = 0
+
+const ExecutionDataCandidatesScannerName = "execution_data_candidates_scanner"
+
+// ExecutionDataCandidatesScanner finds candidates by looking at the registers that were written to
+// in each block. This catches all state changes, even the ones that do not emit any events.
+// The access node needs to have the execution data API enabled.
+type ExecutionDataCandidatesScanner struct {
+	logger zerolog.Logger
+}
+
+func NewExecutionDataCandidatesScanner(logger zerolog.Logger) ExecutionDataCandidatesScanner {
+	return ExecutionDataCandidatesScanner{
+		logger: logger.With().Str("component", ExecutionDataCandidatesScannerName).Logger(),
+	}
+}
+
+var _ CandidateScanner = ExecutionDataCandidatesScanner{}
+
+func (s ExecutionDataCandidatesScanner) Scan(
+	ctx context.Context,
+	client client.Client,
+	blocks BlockRange,
+) CandidatesResult {
+	candidatesChan := make(chan CandidatesResult, blocks.End-blocks.Start+1)
+	defer close(candidatesChan)
+
+	for blockHeight := blocks.Start; blockHeight <= blocks.End; blockHeight++ {
+		go func(blockHeight uint64) {
+			candidatesChan <- s.scanBlock(ctx, client, blockHeight)
+		}(blockHeight)
+	}
+
+	candidates := WaitForCandidateResults(candidatesChan, int(blocks.End-blocks.Start+1))
+
+	if candidates.Err() != nil {
+		return candidates
+	}
+
+	s.logger.
+		Debug().
+		Int("count", len(candidates.Addresses)).
+		Uint64("start", blocks.Start).
+		Uint64("end", blocks.End).
+		Msg("Found execution data candidates")
+
+	return candidates
+}
+
+func (s ExecutionDataCandidatesScanner) scanBlock(
+	ctx context.Context,
+	client client.Client,
+	blockHeight uint64,
+) CandidatesResult {
+	header, err := client.GetBlockHeaderByHeight(ctx, blockHeight)
+	if err != nil {
+		if !isCancellationError(err) {
+			s.logger.Error().
+				Err(err).
+				Uint64("block_height", blockHeight).
+				Msg("Could not get block header by height.")
+		}
+		return NewCandidatesResultError(err)
+	}
+
+	executionData, err := client.GetExecutionDataByBlockID(ctx, header.ID)
+	if err != nil {
+		if !isCancellationError(err) {
+			s.logger.Error().
+				Err(err).
+				Uint64("block_height", blockHeight).
+				Str("block_id", header.ID.Hex()).
+				Msg("Could not get execution data.")
+		}
+		return NewCandidatesResultError(err)
+	}
+
+	result := NewCandidatesResult(make(map[flow.Address]struct{}))
+	for address := range RegisterOwnersFromExecutionData(executionData) {
+		result.Add(address, Provenance{
+			Scanner:     ExecutionDataCandidatesScannerName,
+			BlockHeight: blockHeight,
+		})
+	}
+	return result
+}
+
+// RegisterOwnersFromExecutionData returns the addresses of all accounts that had any of their registers
+// written to in the given execution data.
+func RegisterOwnersFromExecutionData(executionData *entities.BlockExecutionData) map[flow.Address]struct{} {
+	addresses := make(map[flow.Address]struct{})
+	for _, chunk := range executionData.GetChunkExecutionData() {
+		for _, payload := range chunk.GetTrieUpdate().GetPayloads() {
+			for _, keyPart := range payload.GetKeyPart() {
+				if keyPart.GetType() != keyPartOwner {
+					continue
+				}
+				// global registers have no owner
+				if len(keyPart.GetValue()) != flow.AddressLength {
+					continue
+				}
+				addresses[flow.BytesToAddress(keyPart.GetValue())] = struct{}{}
+			}
+		}
+	}
+	return addresses
+}
@@ -0,0 +1,127 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow/protobuf/go/flow/entities"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+)
+
+// register is the payload of a register written to by the owner, with the key parts owner (0) and key (2).
+func register(owner []byte, key string) *entities.Payload {
+	return &entities.Payload{
+		KeyPart: []*entities.KeyPart{
+			{Type: 0, Value: owner},
+			{Type: 2, Value: []byte(key)},
+		},
+	}
+}
+
+func executionData(payloads ...[]*entities.Payload) *entities.BlockExecutionData {
+	data := &entities.BlockExecutionData{}
+	for _, chunk := range payloads {
+		data.ChunkExecutionData = append(data.ChunkExecutionData, &entities.ChunkExecutionData{
+			TrieUpdate: &entities.TrieUpdate{Payloads: chunk},
+		})
+	}
+	return data
+}
+
+func TestRegisterOwnersFromExecutionData(t *testing.T) {
+	a1 := flow.HexToAddress("0x1")
+	a2 := flow.HexToAddress("0x2")
+
+	t.Run("owners of all chunks", func(t *testing.T) {
+		data := executionData(
+			[]*entities.Payload{register(a1.Bytes(), "storage"), register(a1.Bytes(), "public_key_0")},
+			[]*entities.Payload{register(a2.Bytes(), "contract_names")},
+		)
+
+		require.Equal(t, map[flow.Address]struct{}{
+			a1: {},
+			a2: {},
+		}, candidates.RegisterOwnersFromExecutionData(data))
+	})
+
+	t.Run("global registers have no owner", func(t *testing.T) {
+		data := executionData(
+			[]*entities.Payload{register(nil, "uuid"), register(a1.Bytes(), "storage")},
+		)
+
+		require.Equal(t, map[flow.Address]struct{}{
+			a1: {},
+		}, candidates.RegisterOwnersFromExecutionData(data))
+	})
+
+	t.Run("only the owner key part is an address", func(t *testing.T) {
+		data := executionData([]*entities.Payload{{
+			KeyPart: []*entities.KeyPart{{Type: 2, Value: a1.Bytes()}},
+		}})
+
+		require.Empty(t, candidates.RegisterOwnersFromExecutionData(data))
+	})
+
+	t.Run("missing execution data has no owners", func(t *testing.T) {
+		require.Empty(t, candidates.RegisterOwnersFromExecutionData(nil))
+		require.Empty(t, candidates.RegisterOwnersFromExecutionData(&entities.BlockExecutionData{
+			ChunkExecutionData: []*entities.ChunkExecutionData{{}},
+		}))
+	})
+}
+
+func TestExecutionDataCandidatesScanner(t *testing.T) {
+	a1 := flow.HexToAddress("0x1")
+	a2 := flow.HexToAddress("0x2")
+
+	c := clienttest.New()
+	c.AddBlocks(10, 12)
+	c.SetExecutionData(clienttest.BlockID(10), executionData([]*entities.Payload{register(a1.Bytes(), "storage")}))
+	c.SetExecutionData(clienttest.BlockID(11), executionData())
+	c.SetExecutionData(clienttest.BlockID(12), executionData([]*entities.Payload{register(a2.Bytes(), "storage")}))
+	scanner := candidates.NewExecutionDataCandidatesScanner(zerolog.Nop())
+
+	t.Run("finds the owners of the block range", func(t *testing.T) {
+		result := scanner.Scan(context.Background(), c, candidates.BlockRange{Start: 10, End: 12})
+
+		require.NoError(t, result.Err())
+		require.Equal(t, map[flow.Address]struct{}{
+			a1: {},
+			a2: {},
+		}, result.Addresses)
+		require.Equal(t, []candidates.Provenance{{
+			Scanner:     candidates.ExecutionDataCandidatesScannerName,
+			BlockHeight: 12,
+		}}, result.Provenance[a2])
+	})
+
+	t.Run("fails if the execution data is not available", func(t *testing.T) {
+		c.SetError(client.MethodGetExecutionDataByBlockID, status.Error(codes.Unimplemented, "execution data API disabled"))
+		defer c.SetError(client.MethodGetExecutionDataByBlockID, nil)
+
+		result := scanner.Scan(context.Background(), c, candidates.BlockRange{Start: 10, End: 12})
+		require.Equal(t, codes.Unimplemented, status.Code(result.Err()))
+	})
+}
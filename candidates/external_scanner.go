@@ -0,0 +1,102 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates
+
+import (
+	"context"
+
+	"github.com/onflow/flow-go-sdk"
+
+	"github.com/onflow/flow-batch-scan/client"
+)
+
+const (
+	ChannelCandidatesScannerName  = "channel_candidates_scanner"
+	CallbackCandidatesScannerName = "callback_candidates_scanner"
+)
+
+// ChannelCandidatesScanner lets an external source inject candidates.
+// Each Scan takes all the addresses that are currently waiting on the channel, without blocking.
+// The addresses are scanned at the end of the block range that is being scanned.
+type ChannelCandidatesScanner struct {
+	addresses <-chan flow.Address
+}
+
+func NewChannelCandidatesScanner(addresses <-chan flow.Address) *ChannelCandidatesScanner {
+	return &ChannelCandidatesScanner{
+		addresses: addresses,
+	}
+}
+
+var _ CandidateScanner = (*ChannelCandidatesScanner)(nil)
+
+func (s *ChannelCandidatesScanner) Scan(
+	ctx context.Context,
+	_ client.Client,
+	blocks BlockRange,
+) CandidatesResult {
+	result := NewCandidatesResult(make(map[flow.Address]struct{}))
+	for {
+		select {
+		case <-ctx.Done():
+			return NewCandidatesResultError(ctx.Err())
+		case address, ok := <-s.addresses:
+			if !ok {
+				return result
+			}
+			result.Add(address, Provenance{
+				Scanner:     ChannelCandidatesScannerName,
+				BlockHeight: blocks.End,
+			})
+		default:
+			return result
+		}
+	}
+}
+
+// CallbackCandidatesScanner asks an external source for the candidates of each block range.
+type CallbackCandidatesScanner struct {
+	candidates func(ctx context.Context, blocks BlockRange) ([]flow.Address, error)
+}
+
+func NewCallbackCandidatesScanner(
+	candidates func(ctx context.Context, blocks BlockRange) ([]flow.Address, error),
+) *CallbackCandidatesScanner {
+	return &CallbackCandidatesScanner{
+		candidates: candidates,
+	}
+}
+
+var _ CandidateScanner = (*CallbackCandidatesScanner)(nil)
+
+func (s *CallbackCandidatesScanner) Scan(
+	ctx context.Context,
+	_ client.Client,
+	blocks BlockRange,
+) CandidatesResult {
+	candidates, err := s.candidates(ctx, blocks)
+	if err != nil {
+		return NewCandidatesResultError(err)
+	}
+
+	result := NewCandidatesResult(make(map[flow.Address]struct{}, len(candidates)))
+	for _, address := range candidates {
+		result.Add(address, Provenance{
+			Scanner:     CallbackCandidatesScannerName,
+			BlockHeight: blocks.End,
+		})
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+)
+
+func TestChannelCandidatesScanner(t *testing.T) {
+	a1, a2 := flow.HexToAddress("0x1"), flow.HexToAddress("0x2")
+	addresses := make(chan flow.Address, 2)
+	scanner := candidates.NewChannelCandidatesScanner(addresses)
+	blocks := candidates.BlockRange{Start: 10, End: 12}
+
+	t.Run("takes the waiting addresses without blocking", func(t *testing.T) {
+		addresses <- a1
+		addresses <- a2
+
+		result := scanner.Scan(context.Background(), nil, blocks)
+
+		require.NoError(t, result.Err())
+		require.Equal(t, map[flow.Address]struct{}{a1: {}, a2: {}}, result.Addresses)
+		require.Equal(t, []candidates.Provenance{{
+			Scanner:     candidates.ChannelCandidatesScannerName,
+			BlockHeight: 12,
+		}}, result.Provenance[a1])
+
+		result = scanner.Scan(context.Background(), nil, blocks)
+		require.NoError(t, result.Err())
+		require.Empty(t, result.Addresses)
+	})
+
+	t.Run("a closed channel has no candidates", func(t *testing.T) {
+		closed := make(chan flow.Address)
+		close(closed)
+
+		result := candidates.NewChannelCandidatesScanner(closed).Scan(context.Background(), nil, blocks)
+		require.NoError(t, result.Err())
+		require.Empty(t, result.Addresses)
+	})
+}
+
+func TestCallbackCandidatesScanner(t *testing.T) {
+	a1 := flow.HexToAddress("0x1")
+	blocks := candidates.BlockRange{Start: 10, End: 12}
+
+	t.Run("returns the candidates of the block range", func(t *testing.T) {
+		var asked candidates.BlockRange
+		scanner := candidates.NewCallbackCandidatesScanner(
+			func(_ context.Context, blocks candidates.BlockRange) ([]flow.Address, error) {
+				asked = blocks
+				return []flow.Address{a1}, nil
+			})
+
+		result := scanner.Scan(context.Background(), nil, blocks)
+
+		require.NoError(t, result.Err())
+		require.Equal(t, blocks, asked)
+		require.Equal(t, map[flow.Address]struct{}{a1: {}}, result.Addresses)
+		require.Equal(t, []candidates.Provenance{{
+			Scanner:     candidates.CallbackCandidatesScannerName,
+			BlockHeight: 12,
+		}}, result.Provenance[a1])
+	})
+
+	t.Run("returns the error of the callback", func(t *testing.T) {
+		scanner := candidates.NewCallbackCandidatesScanner(
+			func(context.Context, candidates.BlockRange) ([]flow.Address, error) {
+				return nil, fmt.Errorf("source unavailable")
+			})
+
+		result := scanner.Scan(context.Background(), nil, blocks)
+		require.ErrorContains(t, result.Err(), "source unavailable")
+	})
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+	"github.com/onflow/flow-go-sdk"
+	flowgrpc "github.com/onflow/flow-go-sdk/access/grpc"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/client"
+)
+
+// eventsClient is a client that only serves events.
+// The events of a block can also be fetched as a single transaction result.
+type eventsClient struct {
+	client.Client
+
+	events []flow.BlockEvents
+}
+
+func (c *eventsClient) GetEventsForHeightRange(
+	_ context.Context,
+	query flowgrpc.EventRangeQuery,
+) ([]flow.BlockEvents, error) {
+	var result []flow.BlockEvents
+	for _, blockEvents := range c.events {
+		if blockEvents.Height < query.StartHeight || blockEvents.Height > query.EndHeight {
+			continue
+		}
+		filtered := flow.BlockEvents{
+			BlockID: blockEvents.BlockID,
+			Height:  blockEvents.Height,
+		}
+		for _, event := range blockEvents.Events {
+			if event.Type == query.Type {
+				filtered.Events = append(filtered.Events, event)
+			}
+		}
+		result = append(result, filtered)
+	}
+	return result, nil
+}
+
+func (c *eventsClient) GetBlockHeaderByHeight(
+	_ context.Context,
+	height uint64,
+) (*flow.BlockHeader, error) {
+	return &flow.BlockHeader{ID: blockIDAtHeight(height), Height: height}, nil
+}
+
+func (c *eventsClient) GetTransactionResultsByBlockID(
+	_ context.Context,
+	blockID flow.Identifier,
+) ([]*flow.TransactionResult, error) {
+	for _, blockEvents := range c.events {
+		if blockIDAtHeight(blockEvents.Height) == blockID {
+			return []*flow.TransactionResult{{Events: blockEvents.Events}}, nil
+		}
+	}
+	return nil, nil
+}
+
+// SubscribeEvents streams the events of all blocks starting at startHeight, and then blocks.
+func (c *eventsClient) SubscribeEvents(
+	ctx context.Context,
+	startHeight uint64,
+	eventTypes []string,
+) (<-chan flow.BlockEvents, <-chan error, error) {
+	events := make(chan flow.BlockEvents)
+	errs := make(chan error)
+	go func() {
+		for _, blockEvents := range c.events {
+			if blockEvents.Height < startHeight {
+				continue
+			}
+			filtered := flow.BlockEvents{Height: blockEvents.Height}
+			for _, event := range blockEvents.Events {
+				for _, eventType := range eventTypes {
+					if event.Type == eventType {
+						filtered.Events = append(filtered.Events, event)
+					}
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case events <- filtered:
+			}
+		}
+	}()
+	return events, errs, nil
+}
+
+func blockIDAtHeight(height uint64) flow.Identifier {
+	return flow.Identifier{byte(height)}
+}
+
+// loadEvent loads a JSON-CDC encoded event from the testdata directory.
+func loadEvent(t *testing.T, name string) flow.Event {
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	value, err := jsoncdc.Decode(nil, data)
+	require.NoError(t, err)
+
+	event, ok := value.(cadence.Event)
+	require.True(t, ok, "fixture %s is not an event", name)
+
+	return flow.Event{
+		Type:  event.EventType.ID(),
+		Value: event,
+	}
+}
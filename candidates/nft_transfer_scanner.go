@@ -0,0 +1,73 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates
+
+import (
+	"context"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+
+	"github.com/onflow/flow-batch-scan/client"
+)
+
+// NFTContract identifies a contract that implements the NonFungibleToken standard.
+type NFTContract struct {
+	Address flow.Address
+	Name    string
+}
+
+// NFTTransferCandidatesScanner finds accounts that received or sent NFTs of the given contracts,
+// by looking at the Deposit and Withdraw events of those contracts.
+// The receiving address of a Deposit and the sending address of a Withdraw are candidates,
+// as the ownership of both changed. Nil addresses are ignored.
+type NFTTransferCandidatesScanner struct {
+	scanners CandidateScanners
+}
+
+func NewNFTTransferCandidatesScanner(
+	contracts []NFTContract,
+	logger zerolog.Logger,
+) *NFTTransferCandidatesScanner {
+	scanners := make(CandidateScanners, 0, 2*len(contracts))
+	for _, contract := range contracts {
+		scanners = append(scanners,
+			NewEventCandidatesScannerForAddresses(
+				ContractEventType(contract.Address, contract.Name, "Deposit"),
+				OptionalAddressFromEventField("to"),
+				logger,
+			),
+			NewEventCandidatesScannerForAddresses(
+				ContractEventType(contract.Address, contract.Name, "Withdraw"),
+				OptionalAddressFromEventField("from"),
+				logger,
+			),
+		)
+	}
+
+	return &NFTTransferCandidatesScanner{
+		scanners: scanners,
+	}
+}
+
+var _ CandidateScanner = (*NFTTransferCandidatesScanner)(nil)
+
+func (s *NFTTransferCandidatesScanner) Scan(
+	ctx context.Context,
+	client client.Client,
+	blocks BlockRange,
+) CandidatesResult {
+	return s.scanners.Scan(ctx, client, blocks)
+}
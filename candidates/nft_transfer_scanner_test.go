@@ -0,0 +1,71 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+)
+
+func TestNFTTransferCandidatesScanner(t *testing.T) {
+	topShot := candidates.NFTContract{Address: flow.HexToAddress("0b2a3299cc857e29"), Name: "TopShot"}
+
+	c := &eventsClient{
+		events: []flow.BlockEvents{
+			{
+				Height: 10,
+				Events: []flow.Event{
+					loadEvent(t, "nft_withdrawn.json"),
+					loadEvent(t, "nft_deposited.json"),
+					loadEvent(t, "nft_deposited_nil.json"),
+					loadEvent(t, "flow_token_deposited.json"),
+				},
+			},
+		},
+	}
+
+	t.Run("finds senders and receivers", func(t *testing.T) {
+		scanner := candidates.NewNFTTransferCandidatesScanner([]candidates.NFTContract{topShot}, zerolog.Nop())
+
+		result := scanner.Scan(context.Background(), c, candidates.BlockRange{Start: 10, End: 10})
+
+		require.NoError(t, result.Err())
+		require.Equal(t, map[flow.Address]struct{}{
+			flow.HexToAddress("18eb4ee6b3c026d2"): {},
+			flow.HexToAddress("e467b9dd11fa00df"): {},
+		}, result.Addresses)
+		require.Equal(t, []candidates.Provenance{{
+			Scanner:     candidates.EventCandidatesScannerName,
+			BlockHeight: 10,
+			EventType:   "A.0b2a3299cc857e29.TopShot.Withdraw",
+		}}, result.Provenance[flow.HexToAddress("18eb4ee6b3c026d2")])
+	})
+
+	t.Run("ignores other contracts", func(t *testing.T) {
+		other := candidates.NFTContract{Address: flow.HexToAddress("0b2a3299cc857e29"), Name: "TopShotLocking"}
+		scanner := candidates.NewNFTTransferCandidatesScanner([]candidates.NFTContract{other}, zerolog.Nop())
+
+		result := scanner.Scan(context.Background(), c, candidates.BlockRange{Start: 10, End: 10})
+
+		require.NoError(t, result.Err())
+		require.Empty(t, result.Addresses)
+	})
+}
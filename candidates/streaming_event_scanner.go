@@ -0,0 +1,238 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+
+	"github.com/onflow/flow-batch-scan/client"
+)
+
+const StreamingEventCandidatesScannerName = "streaming_event_candidates_scanner"
+
+// StreamingEventCandidatesScanner finds candidates in events like the EventCandidatesScanner,
+// but it subscribes to the event stream of the access node instead of polling for events.
+// The stream only contains blocks that were executed, so the incremental scanner
+// does not need to lag behind the latest block (IncrementalScannerBlockLag can be 0).
+// Scan waits until the stream has reached the end of the requested block range.
+//
+// The access node needs to have the execution data API enabled.
+type StreamingEventCandidatesScanner struct {
+	candidateAddressesFromEvent map[string]func(event cadence.Event) ([]flow.Address, error)
+	eventTypes                  []string
+
+	mu         sync.Mutex
+	cancel     context.CancelFunc
+	events     <-chan flow.BlockEvents
+	errs       <-chan error
+	nextHeight uint64
+	// next is a block that was received from the stream, but was past the scanned block range.
+	next *flow.BlockEvents
+
+	logger zerolog.Logger
+}
+
+// NewStreamingEventCandidatesScanner creates a new StreamingEventCandidatesScanner.
+// candidateAddressesFromEvent contains a function for each event type that should be streamed.
+func NewStreamingEventCandidatesScanner(
+	candidateAddressesFromEvent map[string]func(event cadence.Event) ([]flow.Address, error),
+	logger zerolog.Logger,
+) *StreamingEventCandidatesScanner {
+	eventTypes := make([]string, 0, len(candidateAddressesFromEvent))
+	for eventType := range candidateAddressesFromEvent {
+		eventTypes = append(eventTypes, eventType)
+	}
+
+	return &StreamingEventCandidatesScanner{
+		candidateAddressesFromEvent: candidateAddressesFromEvent,
+		eventTypes:                  eventTypes,
+
+		logger: logger.With().Str("component", StreamingEventCandidatesScannerName).Logger(),
+	}
+}
+
+var _ CandidateScanner = (*StreamingEventCandidatesScanner)(nil)
+
+func (s *StreamingEventCandidatesScanner) Scan(
+	ctx context.Context,
+	client client.Client,
+	blocks BlockRange,
+) CandidatesResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.events == nil || s.nextHeight != blocks.Start {
+		err := s.subscribe(ctx, client, blocks.Start)
+		if err != nil {
+			if !isCancellationError(err) {
+				s.logger.Error().
+					Err(err).
+					Uint64("start", blocks.Start).
+					Msg("Could not subscribe to events.")
+			}
+			return NewCandidatesResultError(err)
+		}
+	}
+
+	result := NewCandidatesResult(make(map[flow.Address]struct{}))
+	for {
+		var blockEvents flow.BlockEvents
+		if s.next != nil {
+			blockEvents = *s.next
+			s.next = nil
+		} else {
+			var err error
+			blockEvents, err = s.receive(ctx)
+			if err != nil {
+				s.unsubscribe()
+				return NewCandidatesResultError(err)
+			}
+		}
+
+		if blockEvents.Height > blocks.End {
+			s.next = &blockEvents
+			break
+		}
+
+		err := s.addCandidates(&result, blockEvents)
+		if err != nil {
+			s.unsubscribe()
+			return NewCandidatesResultError(err)
+		}
+		s.nextHeight = blockEvents.Height + 1
+		if blockEvents.Height == blocks.End {
+			break
+		}
+	}
+	s.nextHeight = blocks.End + 1
+
+	s.logger.
+		Debug().
+		Int("count", len(result.Addresses)).
+		Uint64("start", blocks.Start).
+		Uint64("end", blocks.End).
+		Msg("Found streamed event candidates")
+
+	return result
+}
+
+func (s *StreamingEventCandidatesScanner) subscribe(
+	ctx context.Context,
+	client client.Client,
+	startHeight uint64,
+) error {
+	s.unsubscribe()
+
+	ctx, cancel := context.WithCancel(ctx)
+	events, errs, err := client.SubscribeEvents(ctx, startHeight, s.eventTypes)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	s.cancel = cancel
+	s.events = events
+	s.errs = errs
+	s.nextHeight = startHeight
+	return nil
+}
+
+func (s *StreamingEventCandidatesScanner) unsubscribe() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.cancel = nil
+	s.events = nil
+	s.errs = nil
+	s.next = nil
+}
+
+func (s *StreamingEventCandidatesScanner) receive(ctx context.Context) (flow.BlockEvents, error) {
+	select {
+	case <-ctx.Done():
+		return flow.BlockEvents{}, ctx.Err()
+	case err, ok := <-s.errs:
+		if !ok {
+			return flow.BlockEvents{}, fmt.Errorf("event stream closed")
+		}
+		if !isCancellationError(err) {
+			s.logger.Error().
+				Err(err).
+				Msg("Event stream failed.")
+		}
+		return flow.BlockEvents{}, err
+	case blockEvents, ok := <-s.events:
+		if !ok {
+			return flow.BlockEvents{}, fmt.Errorf("event stream closed")
+		}
+		return blockEvents, nil
+	}
+}
+
+func (s *StreamingEventCandidatesScanner) addCandidates(
+	result *CandidatesResult,
+	blockEvents flow.BlockEvents,
+) error {
+	candidates := CandidatesFromBlockEvents(
+		blockEvents,
+		s.candidateAddressesFromEvent,
+		StreamingEventCandidatesScannerName,
+	)
+	if candidates.Err() != nil {
+		s.logger.Error().
+			Err(candidates.Err()).
+			Uint64("block_height", blockEvents.Height).
+			Msg("could not get candidate address from event")
+		return candidates.Err()
+	}
+	result.MergeWith(candidates)
+	return nil
+}
+
+// CandidatesFromBlockEvents gets the candidates from the events of a block,
+// using the function for the type of each event. Events of other types are ignored.
+func CandidatesFromBlockEvents(
+	blockEvents flow.BlockEvents,
+	candidateAddressesFromEvent map[string]func(event cadence.Event) ([]flow.Address, error),
+	scannerName string,
+) CandidatesResult {
+	result := NewCandidatesResult(make(map[flow.Address]struct{}))
+	for _, event := range blockEvents.Events {
+		addressesFromEvent, ok := candidateAddressesFromEvent[event.Type]
+		if !ok {
+			continue
+		}
+		addresses, err := addressesFromEvent(event.Value)
+		if err != nil {
+			return NewCandidatesResultError(fmt.Errorf("event %s: %w", event.Type, err))
+		}
+		for _, address := range addresses {
+			result.Add(address, Provenance{
+				Scanner:       scannerName,
+				BlockHeight:   blockEvents.Height,
+				TransactionID: event.TransactionID,
+				EventType:     event.Type,
+				EventIndex:    event.EventIndex,
+			})
+		}
+	}
+	return result
+}
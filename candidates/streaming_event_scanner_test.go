@@ -0,0 +1,89 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+)
+
+func TestStreamingEventCandidatesScanner(t *testing.T) {
+	c := &eventsClient{
+		events: []flow.BlockEvents{
+			{Height: 10, Events: []flow.Event{loadEvent(t, "account_key_added.json")}},
+			{Height: 11},
+			{Height: 13, Events: []flow.Event{loadEvent(t, "account_key_removed.json")}},
+		},
+	}
+	newScanner := func() *candidates.StreamingEventCandidatesScanner {
+		return candidates.NewStreamingEventCandidatesScanner(
+			map[string]func(event cadence.Event) ([]flow.Address, error){
+				flow.EventAccountKeyAdded:   candidates.OptionalAddressFromEventField("address"),
+				flow.EventAccountKeyRemoved: candidates.OptionalAddressFromEventField("address"),
+			},
+			zerolog.Nop(),
+		)
+	}
+
+	t.Run("consecutive block ranges use the same stream", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		scanner := newScanner()
+
+		result := scanner.Scan(ctx, c, candidates.BlockRange{Start: 10, End: 11})
+		require.NoError(t, result.Err())
+		require.Equal(t, map[flow.Address]struct{}{
+			flow.HexToAddress("e467b9dd11fa00df"): {},
+		}, result.Addresses)
+
+		// block 12 is skipped by the stream
+		result = scanner.Scan(ctx, c, candidates.BlockRange{Start: 12, End: 13})
+		require.NoError(t, result.Err())
+		require.Equal(t, map[flow.Address]struct{}{
+			flow.HexToAddress("1654653399040a61"): {},
+		}, result.Addresses)
+	})
+
+	t.Run("stream is restarted for a different block range", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		scanner := newScanner()
+
+		result := scanner.Scan(ctx, c, candidates.BlockRange{Start: 10, End: 10})
+		require.NoError(t, result.Err())
+
+		result = scanner.Scan(ctx, c, candidates.BlockRange{Start: 13, End: 13})
+		require.NoError(t, result.Err())
+		require.Equal(t, map[flow.Address]struct{}{
+			flow.HexToAddress("1654653399040a61"): {},
+		}, result.Addresses)
+	})
+
+	t.Run("waits for the end of the block range", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		scanner := newScanner()
+
+		cancel()
+		result := scanner.Scan(ctx, c, candidates.BlockRange{Start: 14, End: 14})
+		require.ErrorIs(t, result.Err(), context.Canceled)
+	})
+}
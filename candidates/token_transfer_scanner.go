@@ -0,0 +1,69 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+
+	"github.com/onflow/flow-batch-scan/client"
+)
+
+// TokenTransferCandidatesScanner finds accounts that received or sent fungible tokens of a specific contract,
+// by looking at the TokensDeposited and TokensWithdrawn events of that contract.
+// Deposits and withdrawals from vaults that are not stored in an account (nil addresses) are ignored.
+type TokenTransferCandidatesScanner struct {
+	scanners CandidateScanners
+}
+
+func NewTokenTransferCandidatesScanner(
+	contractAddress flow.Address,
+	contractName string,
+	logger zerolog.Logger,
+) *TokenTransferCandidatesScanner {
+	return &TokenTransferCandidatesScanner{
+		scanners: CandidateScanners{
+			NewEventCandidatesScannerForAddresses(
+				ContractEventType(contractAddress, contractName, "TokensDeposited"),
+				OptionalAddressFromEventField("to"),
+				logger,
+			),
+			NewEventCandidatesScannerForAddresses(
+				ContractEventType(contractAddress, contractName, "TokensWithdrawn"),
+				OptionalAddressFromEventField("from"),
+				logger,
+			),
+		},
+	}
+}
+
+var _ CandidateScanner = (*TokenTransferCandidatesScanner)(nil)
+
+func (s *TokenTransferCandidatesScanner) Scan(
+	ctx context.Context,
+	client client.Client,
+	blocks BlockRange,
+) CandidatesResult {
+	return s.scanners.Scan(ctx, client, blocks)
+}
+
+// ContractEventType returns the fully qualified type of an event declared in a contract.
+// e.g. A.1654653399040a61.FlowToken.TokensDeposited
+func ContractEventType(contractAddress flow.Address, contractName string, eventName string) string {
+	return fmt.Sprintf("A.%s.%s.%s", contractAddress.Hex(), contractName, eventName)
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+)
+
+func TestTokenTransferCandidatesScanner(t *testing.T) {
+	flowTokenAddress := flow.HexToAddress("1654653399040a61")
+
+	c := &eventsClient{
+		events: []flow.BlockEvents{
+			{
+				Height: 10,
+				Events: []flow.Event{
+					loadEvent(t, "flow_token_withdrawn.json"),
+					loadEvent(t, "flow_token_deposited.json"),
+					loadEvent(t, "flow_token_deposited_nil.json"),
+				},
+			},
+		},
+	}
+
+	t.Run("finds senders and receivers", func(t *testing.T) {
+		scanner := candidates.NewTokenTransferCandidatesScanner(flowTokenAddress, "FlowToken", zerolog.Nop())
+
+		result := scanner.Scan(context.Background(), c, candidates.BlockRange{Start: 10, End: 10})
+
+		require.NoError(t, result.Err())
+		require.Equal(t, map[flow.Address]struct{}{
+			flow.HexToAddress("18eb4ee6b3c026d2"): {},
+			flow.HexToAddress("e467b9dd11fa00df"): {},
+		}, result.Addresses)
+		require.Equal(t, []candidates.Provenance{{
+			Scanner:     candidates.EventCandidatesScannerName,
+			BlockHeight: 10,
+			EventType:   "A.1654653399040a61.FlowToken.TokensDeposited",
+		}}, result.Provenance[flow.HexToAddress("e467b9dd11fa00df")])
+	})
+
+	t.Run("ignores other contracts", func(t *testing.T) {
+		scanner := candidates.NewTokenTransferCandidatesScanner(flowTokenAddress, "FiatToken", zerolog.Nop())
+
+		result := scanner.Scan(context.Background(), c, candidates.BlockRange{Start: 10, End: 10})
+
+		require.NoError(t, result.Err())
+		require.Empty(t, result.Addresses)
+	})
+}
+
+func TestContractEventType(t *testing.T) {
+	require.Equal(t,
+		"A.1654653399040a61.FlowToken.TokensDeposited",
+		candidates.ContractEventType(flow.HexToAddress("1654653399040a61"), "FlowToken", "TokensDeposited"),
+	)
+}
@@ -0,0 +1,72 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+)
+
+const TransactionScriptCandidatesScannerName = "transaction_script_candidates_scanner"
+
+// TransactionScriptCandidatesScanner finds the authorizers of transactions whose script matches a pattern.
+// Unlike the AuthorizerCandidatesScanner, payers and proposers are not candidates by default.
+type TransactionScriptCandidatesScanner struct {
+	AuthorizerCandidatesScanner
+}
+
+func NewTransactionScriptCandidatesScanner(
+	pattern *regexp.Regexp,
+	logger zerolog.Logger,
+	options ...AuthorizerCandidatesScannerOption,
+) TransactionScriptCandidatesScanner {
+	options = append([]AuthorizerCandidatesScannerOption{
+		WithPayer(false),
+		WithProposer(false),
+		WithTransactionFilter(func(tx *flow.Transaction) bool {
+			return pattern.Match(tx.Script)
+		}),
+	}, options...)
+
+	s := NewAuthorizerCandidatesScanner(logger, options...)
+	s.name = TransactionScriptCandidatesScannerName
+	s.logger = logger.With().Str("component", TransactionScriptCandidatesScannerName).Logger()
+
+	return TransactionScriptCandidatesScanner{
+		AuthorizerCandidatesScanner: s,
+	}
+}
+
+// NewTransactionImportCandidatesScanner finds the authorizers of transactions that import the given contract.
+// e.g. `import TopShot from 0x0b2a3299cc857e29` or `import "TopShot"`
+func NewTransactionImportCandidatesScanner(
+	contractName string,
+	logger zerolog.Logger,
+	options ...AuthorizerCandidatesScannerOption,
+) TransactionScriptCandidatesScanner {
+	return NewTransactionScriptCandidatesScanner(
+		ImportPattern(contractName),
+		logger,
+		options...,
+	)
+}
+
+// ImportPattern returns a pattern that matches a cadence import of the given contract.
+func ImportPattern(contractName string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(`(?m)^\s*import\s[^\n]*\b%s\b`, regexp.QuoteMeta(contractName)))
+}
@@ -0,0 +1,47 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package candidates_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+)
+
+func TestImportPattern(t *testing.T) {
+	pattern := candidates.ImportPattern("TopShot")
+
+	matches := []string{
+		"import TopShot from 0x0b2a3299cc857e29",
+		"  import TopShot from 0x0b2a3299cc857e29",
+		"import NonFungibleToken, TopShot from 0x0b2a3299cc857e29",
+		`import "TopShot"`,
+		"import FungibleToken from 0xf233dcee88fe0abe\nimport TopShot from 0x0b2a3299cc857e29\n",
+	}
+	for _, script := range matches {
+		require.True(t, pattern.MatchString(script), script)
+	}
+
+	nonMatches := []string{
+		"import TopShotMarket from 0x0b2a3299cc857e29",
+		"// TopShot\nimport FungibleToken from 0xf233dcee88fe0abe",
+		"transaction { prepare(acct: AuthAccount) { let TopShot = 1 } }",
+	}
+	for _, script := range nonMatches {
+		require.False(t, pattern.MatchString(script), script)
+	}
+}
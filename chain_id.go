@@ -0,0 +1,81 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/onflow/flow-go-sdk"
+)
+
+// ChainIDMismatchError is returned by the scan when the access node is not on the configured ChainID.
+type ChainIDMismatchError struct {
+	Configured flow.ChainID
+	Detected   flow.ChainID
+}
+
+func (e *ChainIDMismatchError) Error() string {
+	return fmt.Sprintf(
+		"the ChainID is %s, but the access node is on %s: connect to an access node of %s, or use WithChainID(%q)",
+		e.Configured,
+		e.Detected,
+		e.Configured,
+		e.Detected,
+	)
+}
+
+// chainID returns the chain ID of the scan.
+// The access node is asked for its chain ID: if the ChainID is not set the detected chain ID is used,
+// otherwise it has to match the detected chain ID.
+// If the access node can not tell its chain ID (e.g. the REST API), the configured ChainID is trusted.
+func (scanner *Scanner) chainID(ctx context.Context) (flow.ChainID, error) {
+	configured := scanner.tuning.getChainID()
+	params, err := scanner.client.GetNetworkParameters(ctx)
+	if err != nil {
+		if configured == "" {
+			return "", fmt.Errorf("the ChainID is not set and could not be detected from the access node, set it with WithChainID: %w", err)
+		}
+		scanner.logger.Warn().
+			Err(err).
+			Str("chain_id", configured.String()).
+			Msg("Could not detect the chain ID of the access node, using the configured ChainID")
+		return configured, nil
+	}
+
+	detected := params.ChainID
+	if configured != "" {
+		if detected != configured {
+			return "", &ChainIDMismatchError{
+				Configured: configured,
+				Detected:   detected,
+			}
+		}
+		return configured, nil
+	}
+
+	// the addresses of the config can only be checked against the chain now
+	config := scanner.config
+	config.ChainID = detected
+	err = config.Validate()
+	if err != nil {
+		return "", err
+	}
+	scanner.logger.Info().
+		Str("chain_id", detected.String()).
+		Msg("Detected the chain ID of the access node")
+	scanner.tuning.setChainID(detected)
+	return detected, nil
+}
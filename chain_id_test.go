@@ -0,0 +1,72 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+)
+
+func TestScanner_DetectChainID(t *testing.T) {
+	ctx := context.Background()
+	flowClient := clienttest.New()
+	flowClient.SetChainID(flow.Testnet)
+
+	newScanner := func(config Config) *Scanner {
+		scanner, err := NewScanner(flowClient, config.WithScript([]byte("access(all) fun main() {}")))
+		require.NoError(t, err)
+		return scanner
+	}
+
+	// unset: the chain ID of the access node is used
+	scanner := newScanner(DefaultConfig())
+	chainID, err := scanner.chainID(ctx)
+	require.NoError(t, err)
+	require.Equal(t, flow.Testnet, chainID)
+	require.Equal(t, flow.Testnet, scanner.Config().ChainID)
+
+	// the chain ID of the access node is needed to check the addresses
+	scanner = newScanner(DefaultConfig().WithIncludeAddresses(flow.HexToAddress("1654653399040a61")))
+	var invalid *InvalidConfigError
+	_, err = scanner.chainID(ctx)
+	require.ErrorAs(t, err, &invalid)
+
+	// a mismatch fails
+	scanner = newScanner(DefaultConfig().WithChainID(flow.Mainnet))
+	var mismatch *ChainIDMismatchError
+	_, err = scanner.chainID(ctx)
+	require.ErrorAs(t, err, &mismatch)
+	require.Equal(t, flow.Mainnet, mismatch.Configured)
+	require.Equal(t, flow.Testnet, mismatch.Detected)
+
+	// if it can not be detected, the configured chain ID is used
+	flowClient.SetError(client.MethodGetNetworkParameters, status.Error(codes.Unimplemented, "unimplemented"))
+	scanner = newScanner(DefaultConfig().WithChainID(flow.Mainnet))
+	chainID, err = scanner.chainID(ctx)
+	require.NoError(t, err)
+	require.Equal(t, flow.Mainnet, chainID)
+
+	scanner = newScanner(DefaultConfig())
+	_, err = scanner.chainID(ctx)
+	require.ErrorContains(t, err, "set it with WithChainID")
+}
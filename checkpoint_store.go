@@ -0,0 +1,145 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultFullScanCheckpointInterval is how often the progress of a full scan is saved to the CheckpointStore.
+const DefaultFullScanCheckpointInterval = 30 * time.Second
+
+// FullScanCheckpoint is the progress of a full scan.
+type FullScanCheckpoint struct {
+	// AddressIndex is the index of the first address that was not scanned yet.
+	// All the addresses before it were scanned.
+	AddressIndex uint `json:"address_index"`
+	// IncrementalHeight is the latest block height handled by the incremental scanner.
+	// A resumed scan backfills all the blocks after it, to catch changes to the already scanned addresses.
+	IncrementalHeight uint64 `json:"incremental_height"`
+}
+
+// CheckpointStore persists the progress of a full scan, so that a restarted scanner
+// can continue the full scan where it left off instead of starting from the first address.
+type CheckpointStore interface {
+	// LoadCheckpoint returns the stored checkpoint. ok is false if there is no checkpoint.
+	LoadCheckpoint(ctx context.Context) (checkpoint FullScanCheckpoint, ok bool, err error)
+	SaveCheckpoint(ctx context.Context, checkpoint FullScanCheckpoint) error
+	// ClearCheckpoint removes the checkpoint once the full scan is complete.
+	ClearCheckpoint(ctx context.Context) error
+}
+
+// FileCheckpointStore stores the checkpoint as JSON in a file.
+type FileCheckpointStore struct {
+	path string
+}
+
+var _ CheckpointStore = (*FileCheckpointStore)(nil)
+
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{
+		path: path,
+	}
+}
+
+func (s *FileCheckpointStore) LoadCheckpoint(_ context.Context) (FullScanCheckpoint, bool, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return FullScanCheckpoint{}, false, nil
+	}
+	if err != nil {
+		return FullScanCheckpoint{}, false, err
+	}
+
+	var checkpoint FullScanCheckpoint
+	err = json.Unmarshal(data, &checkpoint)
+	if err != nil {
+		return FullScanCheckpoint{}, false, fmt.Errorf("invalid checkpoint in %s: %w", s.path, err)
+	}
+	return checkpoint, true, nil
+}
+
+func (s *FileCheckpointStore) SaveCheckpoint(_ context.Context, checkpoint FullScanCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+func (s *FileCheckpointStore) ClearCheckpoint(_ context.Context) error {
+	err := os.Remove(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// checkpointTracker tracks which address batches of a full scan are done.
+// Batches finish out of order, so the checkpoint is the end of the longest run of done batches from the start.
+type checkpointTracker struct {
+	mu        sync.Mutex
+	nextIndex map[uint64]uint
+	done      map[uint64]struct{}
+	nextBatch uint64
+	confirmed uint64
+	cursor    uint
+}
+
+func newCheckpointTracker(startIndex uint) *checkpointTracker {
+	return &checkpointTracker{
+		nextIndex: make(map[uint64]uint),
+		done:      make(map[uint64]struct{}),
+		cursor:    startIndex,
+	}
+}
+
+// add registers a batch, nextIndex is the index of the first address after the batch.
+// Batches have to be added in order. It returns the id of the batch.
+func (t *checkpointTracker) add(nextIndex uint) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	id := t.nextBatch
+	t.nextIndex[id] = nextIndex
+	t.nextBatch++
+	return id
+}
+
+func (t *checkpointTracker) markDone(id uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.done[id] = struct{}{}
+	for {
+		if _, ok := t.done[t.confirmed]; !ok {
+			return
+		}
+		t.cursor = t.nextIndex[t.confirmed]
+		delete(t.done, t.confirmed)
+		delete(t.nextIndex, t.confirmed)
+		t.confirmed++
+	}
+}
+
+// addressIndex is the index of the first address that might not have been scanned yet.
+func (t *checkpointTracker) addressIndex() uint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cursor
+}
@@ -0,0 +1,38 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package scanner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointTracker(t *testing.T) {
+	tracker := newCheckpointTracker(1)
+
+	first := tracker.add(11)
+	second := tracker.add(21)
+	third := tracker.add(31)
+
+	// batches can finish out of order
+	tracker.markDone(second)
+	require.Equal(t, uint(1), tracker.addressIndex())
+
+	tracker.markDone(first)
+	require.Equal(t, uint(21), tracker.addressIndex())
+
+	tracker.markDone(third)
+	require.Equal(t, uint(31), tracker.addressIndex())
+}
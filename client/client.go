@@ -16,19 +16,28 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 
 	"github.com/onflow/flow-batch-scan/client/interceptors"
 
 	"github.com/onflow/cadence/encoding/json"
 	protoAccess "github.com/onflow/flow/protobuf/go/flow/access"
+	"github.com/onflow/flow/protobuf/go/flow/entities"
+	protoExecutionData "github.com/onflow/flow/protobuf/go/flow/executiondata"
 	"google.golang.org/grpc"
 
 	"github.com/onflow/cadence"
@@ -36,13 +45,57 @@ import (
 	flowgrpc "github.com/onflow/flow-go-sdk/access/grpc"
 )
 
+// TracerName is the name of the tracer of the client spans.
+const TracerName = "github.com/onflow/flow-batch-scan/client"
+
+// The gRPC methods of the Access API, to configure rate limits and retry policies per method.
+const (
+	MethodGetLatestBlockHeader           = "/flow.access.AccessAPI/GetLatestBlockHeader"
+	MethodGetBlockHeaderByHeight         = "/flow.access.AccessAPI/GetBlockHeaderByHeight"
+	MethodGetBlockByHeight               = "/flow.access.AccessAPI/GetBlockByHeight"
+	MethodGetBlockByID                   = "/flow.access.AccessAPI/GetBlockByID"
+	MethodGetCollectionByID              = "/flow.access.AccessAPI/GetCollectionByID"
+	MethodGetTransaction                 = "/flow.access.AccessAPI/GetTransaction"
+	MethodGetTransactionResult           = "/flow.access.AccessAPI/GetTransactionResult"
+	MethodGetTransactionResultsByBlockID = "/flow.access.AccessAPI/GetTransactionResultsByBlockID"
+	MethodGetEventsForHeightRange        = "/flow.access.AccessAPI/GetEventsForHeightRange"
+	MethodExecuteScriptAtBlockHeight     = "/flow.access.AccessAPI/ExecuteScriptAtBlockHeight"
+	MethodGetAccountAtBlockHeight        = "/flow.access.AccessAPI/GetAccountAtBlockHeight"
+	MethodGetExecutionDataByBlockID      = "/flow.access.ExecutionDataAPI/GetExecutionDataByBlockID"
+	MethodSubscribeEvents                = "/flow.access.ExecutionDataAPI/SubscribeEvents"
+	MethodGetNetworkParameters           = "/flow.access.AccessAPI/GetNetworkParameters"
+)
+
 type Client interface {
 	GetLatestBlockHeader(ctx context.Context, isSealed bool) (*flow.BlockHeader, error)
+	GetBlockHeaderByHeight(ctx context.Context, height uint64) (*flow.BlockHeader, error)
 	ExecuteScriptAtBlockHeight(ctx context.Context, height uint64, script []byte, arguments []cadence.Value) (cadence.Value, error)
+	// GetAccountAtBlockHeight returns the account at a height, including its FLOW balance, keys and contracts.
+	GetAccountAtBlockHeight(ctx context.Context, address flow.Address, height uint64) (*flow.Account, error)
 	GetBlockByHeight(ctx context.Context, height uint64) (*flow.Block, error)
 	GetTransaction(ctx context.Context, txID flow.Identifier) (*flow.Transaction, error)
 	GetEventsForHeightRange(ctx context.Context, query flowgrpc.EventRangeQuery) ([]flow.BlockEvents, error)
 	GetCollection(ctx context.Context, colID flow.Identifier) (*flow.Collection, error)
+	GetTransactionResultsByBlockID(ctx context.Context, blockID flow.Identifier) ([]*flow.TransactionResult, error)
+	// GetExecutionDataByBlockID returns the raw execution data of a block.
+	// The access node needs to have the execution data API enabled.
+	GetExecutionDataByBlockID(ctx context.Context, blockID flow.Identifier) (*entities.BlockExecutionData, error)
+	// SubscribeEvents streams the events of the given types for every block, starting at startHeight.
+	// A message is sent for every block, even if it has no matching events.
+	// The error channel receives at most one error, after which both channels are closed.
+	// The access node needs to have the execution data API enabled.
+	SubscribeEvents(
+		ctx context.Context,
+		startHeight uint64,
+		eventTypes []string,
+	) (<-chan flow.BlockEvents, <-chan error, error)
+	// GetNetworkParameters returns the parameters of the network of the access node, e.g. its chain ID.
+	GetNetworkParameters(ctx context.Context) (*NetworkParameters, error)
+}
+
+// NetworkParameters are the parameters of a Flow network.
+type NetworkParameters struct {
+	ChainID flow.ChainID
 }
 
 type ClosableClient interface {
@@ -52,7 +105,7 @@ type ClosableClient interface {
 
 type closableClient struct {
 	Client
-	*grpc.ClientConn
+	io.Closer
 }
 
 func NewClientFromConnection(
@@ -64,7 +117,9 @@ func NewClientFromConnection(
 	flowClient.SetJSONOptions([]json.Option{json.WithAllowUnstructuredStaticTypes(true)})
 
 	return &client{
-		flowClient,
+		BaseClient:          flowClient,
+		accessClient:        grpcClient,
+		executionDataClient: protoExecutionData.NewExecutionDataAPIClient(conn),
 	}
 }
 
@@ -73,12 +128,76 @@ type Config struct {
 
 	DefaultCallOptions []grpc.CallOption
 
+	// TransportCredentials are the credentials of the connection, e.g. TLS.
+	// If nil the connection is insecure.
+	TransportCredentials credentials.TransportCredentials
+	// Headers are added to the metadata of every request, e.g. an API key or a JWT.
+	Headers map[string]string
+
+	// UnaryInterceptors are called once per call, before the retries and rate limits of the client,
+	// e.g. for logging, metrics, header injection or tracing.
+	UnaryInterceptors []grpc.UnaryClientInterceptor
+	// StreamInterceptors are called for every stream, e.g. the event subscriptions.
+	StreamInterceptors []grpc.StreamClientInterceptor
+	// TracerProvider if set, records a span for every call, and propagates the trace context
+	// of the call to the access node (W3C trace context).
+	TracerProvider trace.TracerProvider
+
 	DefaultRateLimit   int
 	SpecificRateLimits map[string]int
+	// DefaultBurst and SpecificBursts are the number of requests that can be sent at once,
+	// after fewer requests than the rate limit were sent for a while. See interceptors.RateLimit.
+	DefaultBurst   int
+	SpecificBursts map[string]int
+	// RateLimitSchedule overrides the rate limits during certain times of the day.
+	RateLimitSchedule []interceptors.RateLimitWindow
+	// RateLimitReloader if set, can change the rate limits while the client is in use.
+	// The access nodes with NodeRateLimits keep their rate limits.
+	RateLimitReloader *interceptors.RateLimitReloader
+	// CircuitBreaker if set, stops sending requests to an access node after consecutive failures,
+	// until it recovers. With multiple access nodes each node has its own circuit breaker.
+	CircuitBreaker *interceptors.CircuitBreakerConfig
+	// AdaptiveRateLimit if set, lowers the rate limits when the access node returns ResourceExhausted,
+	// and ramps them back up when it does not.
+	AdaptiveRateLimit *interceptors.AdaptiveRateLimitConfig
+	// ConcurrencyBudget if set, bounds the requests in flight to each access node.
+	// The requests over the budget wait, without counting towards the Timeout.
+	ConcurrencyBudget *ConcurrencyBudget
 
 	Timeout time.Duration
 
 	Retries int
+	// RetryPolicy is used for all methods without a specific retry policy.
+	// If nil, the interceptors.DefaultRetryPolicy with Retries attempts is used.
+	RetryPolicy           interceptors.RetryPolicy
+	SpecificRetryPolicies map[string]interceptors.RetryPolicy
+
+	// FailoverCooldown is how long an access node is not used after it failed,
+	// when there are multiple access nodes.
+	FailoverCooldown time.Duration
+	// HealthCheckInterval is how often the access nodes are pinged, when there are multiple access nodes.
+	// 0 disables the health checks.
+	HealthCheckInterval time.Duration
+	// LoadBalancing decides how the calls are spread over multiple access nodes.
+	LoadBalancing LoadBalancing
+	// NodeRateLimits are the rate limits of single access nodes, by target.
+	// The other access nodes use the DefaultRateLimit, SpecificRateLimits and RateLimitSchedule.
+	// With multiple access nodes the rate limits apply to each node separately.
+	NodeRateLimits map[string]NodeRateLimit
+
+	// ConnectionsPerNode is the number of connections to each access node (see ConnPool).
+	ConnectionsPerNode int
+	// Keepalive pings the access node on idle connections, if Keepalive.Time is set.
+	Keepalive keepalive.ClientParameters
+
+	// BlockHeaderCacheTTL if set, caches the block headers of NewClient for that long,
+	// so the same headers requested by multiple components are only fetched once (see NewBlockHeaderCache).
+	BlockHeaderCacheTTL time.Duration
+	// BlockHeaderCacheSize is the maximum number of cached block headers by height.
+	BlockHeaderCacheSize int
+	// BlockHeaderPrefetch if set, is the number of heights after a requested block header,
+	// whose headers are fetched in the background, e.g. for the candidate scanners catching up on a block range.
+	BlockHeaderPrefetch int
 
 	WithMetrics      bool
 	MetricsNamespace string
@@ -92,53 +211,184 @@ func DefaultConfig() Config {
 		},
 		DefaultRateLimit: 10,
 		SpecificRateLimits: map[string]int{
-			//"/flow.access.AccessAPI/GetLatestBlockHeader":       20,
-			//"/flow.access.AccessAPI/GetEventsForHeightRange":    30,
-			//"/flow.access.AccessAPI/GetBlockByHeight":           20,
-			//"/flow.access.AccessAPI/GetCollectionByID":          20,
-			//"/flow.access.AccessAPI/GetTransaction":             20,
-			"/flow.access.AccessAPI/ExecuteScriptAtBlockHeight": 2,
+			//MethodGetLatestBlockHeader:    20,
+			//MethodGetEventsForHeightRange: 30,
+			//MethodGetBlockByHeight:        20,
+			//MethodGetCollectionByID:       20,
+			//MethodGetTransaction:          20,
+			MethodExecuteScriptAtBlockHeight: 2,
 		},
-		Timeout:          60 * time.Second,
-		Retries:          10,
-		WithMetrics:      false,
-		MetricsNamespace: "",
+		Timeout:              60 * time.Second,
+		Retries:              10,
+		FailoverCooldown:     30 * time.Second,
+		HealthCheckInterval:  10 * time.Second,
+		ConnectionsPerNode:   1,
+		BlockHeaderCacheTTL:  time.Second,
+		BlockHeaderCacheSize: 1000,
+		WithMetrics:          false,
+		MetricsNamespace:     "",
 	}
 }
 
 func (c Config) Interceptors() []grpc.UnaryClientInterceptor {
-	inter := []grpc.UnaryClientInterceptor{
+	return c.interceptors("")
+}
+
+// interceptors are all the interceptors of a client that connects to a single access node at target.
+func (c Config) interceptors(target string) []grpc.UnaryClientInterceptor {
+	inter := append(c.callInterceptors(), c.nodeInterceptors(target)...)
+
+	if c.WithMetrics {
+		inter = append(inter, c.metricsInterceptor())
+	}
+
+	return inter
+}
+
+// callInterceptors are applied once per call, also when there are multiple access nodes.
+func (c Config) callInterceptors() []grpc.UnaryClientInterceptor {
+	inter := append([]grpc.UnaryClientInterceptor(nil), c.UnaryInterceptors...)
+	if c.TracerProvider != nil {
+		inter = append(inter, interceptors.TracingUnaryClientInterceptor(
+			c.TracerProvider.Tracer(TracerName),
+			propagation.TraceContext{},
+		))
+	}
+	if len(c.Headers) > 0 {
+		inter = append(inter, interceptors.HeadersUnaryClientInterceptor(c.Headers))
+	}
+	return append(inter,
 		interceptors.UnpackCancelledUnaryClientInterceptor(),
 		interceptors.LogUnaryClientInterceptor(c.Log),
-		interceptors.RetryUnaryClientInterceptor(c.Retries),
-		interceptors.RateLimitUnaryClientInterceptor(
-			c.DefaultRateLimit,
-			c.SpecificRateLimits,
-			c.Log,
+		interceptors.RetryPolicyUnaryClientInterceptor(
+			c.retryPolicy(),
+			c.SpecificRetryPolicies,
 		),
-		// timeout per retried request, not per call
-		// timout is after waiting for rate limit
-		interceptors.TimeoutUnaryClientInterceptor(c.Timeout),
+	)
+}
+
+// nodeInterceptors are applied to every request to the access node at target.
+func (c Config) nodeInterceptors(target string) []grpc.UnaryClientInterceptor {
+	defaultRateLimit := c.DefaultRateLimit
+	specificRateLimits := c.SpecificRateLimits
+	schedule := c.RateLimitSchedule
+	if limit, ok := c.NodeRateLimits[target]; ok {
+		defaultRateLimit = limit.DefaultRateLimit
+		specificRateLimits = limit.SpecificRateLimits
+		schedule = nil
 	}
 
-	if c.WithMetrics {
-		metrics := grpc_prometheus.NewClientMetrics(
-			func(opts *prometheus.CounterOpts) {
-				opts.Namespace = c.MetricsNamespace
-			},
-		)
-		metrics.EnableClientHandlingTimeHistogram(
-			func(opts *prometheus.HistogramOpts) {
-				opts.Namespace = c.MetricsNamespace
-			})
+	defaultLimit := interceptors.RateLimit{
+		Rate:  defaultRateLimit,
+		Burst: c.DefaultBurst,
+	}
+	methodRateLimits := make(map[string]interceptors.RateLimit, len(specificRateLimits))
+	for method, rate := range specificRateLimits {
+		methodRateLimits[method] = interceptors.RateLimit{
+			Rate:  rate,
+			Burst: c.SpecificBursts[method],
+		}
+	}
 
-		// register to default registry
-		prometheus.DefaultRegisterer.MustRegister(metrics)
+	var inter []grpc.UnaryClientInterceptor
+	if c.CircuitBreaker != nil {
+		// before the rate limit, so failing fast does not wait for the rate limit
+		inter = append(inter, interceptors.CircuitBreakerUnaryClientInterceptor(*c.CircuitBreaker, c.Log))
+	}
+	_, nodeRateLimit := c.NodeRateLimits[target]
+	if c.RateLimitReloader != nil && !nodeRateLimit {
+		inter = append(inter, c.RateLimitReloader.UnaryClientInterceptor(
+			defaultLimit,
+			methodRateLimits,
+			schedule,
+			c.Log,
+		))
+	} else {
+		inter = append(inter, interceptors.BurstRateLimitUnaryClientInterceptor(
+			defaultLimit,
+			methodRateLimits,
+			schedule,
+			c.Log,
+		))
+	}
+	if c.AdaptiveRateLimit != nil {
+		inter = append(inter, interceptors.AdaptiveRateLimitUnaryClientInterceptor(
+			defaultLimit,
+			methodRateLimits,
+			*c.AdaptiveRateLimit,
+			c.Log,
+		))
+	}
+	if c.ConcurrencyBudget != nil {
+		// after the rate limit, so waiting for the rate limit does not hold a slot
+		inter = append(inter, c.ConcurrencyBudget.Limiter(target).UnaryClientInterceptor())
+	}
+	// timeout per retried request, not per call
+	// timout is after waiting for rate limit
+	return append(inter, interceptors.TimeoutUnaryClientInterceptor(c.Timeout))
+}
+
+func (c Config) metricsInterceptor() grpc.UnaryClientInterceptor {
+	metrics := grpc_prometheus.NewClientMetrics(
+		func(opts *prometheus.CounterOpts) {
+			opts.Namespace = c.MetricsNamespace
+		},
+	)
+	metrics.EnableClientHandlingTimeHistogram(
+		func(opts *prometheus.HistogramOpts) {
+			opts.Namespace = c.MetricsNamespace
+		})
+
+	// register to default registry
+	prometheus.DefaultRegisterer.MustRegister(metrics)
+
+	return metrics.UnaryClientInterceptor()
+}
+
+// dialOptions are the options to dial an access node.
+func (c Config) dialOptions(inter []grpc.UnaryClientInterceptor) []grpc.DialOption {
+	transportCredentials := c.TransportCredentials
+	if transportCredentials == nil {
+		transportCredentials = insecure.NewCredentials()
+	}
+	streamInterceptors := append([]grpc.StreamClientInterceptor(nil), c.StreamInterceptors...)
+	if len(c.Headers) > 0 {
+		streamInterceptors = append(streamInterceptors, interceptors.HeadersStreamClientInterceptor(c.Headers))
+	}
 
-		inter = append(inter, metrics.UnaryClientInterceptor())
+	options := []grpc.DialOption{
+		grpc.WithTransportCredentials(transportCredentials),
+		grpc.WithDefaultCallOptions(
+			c.DefaultCallOptions...,
+		),
+		grpc.WithChainUnaryInterceptor(
+			inter...,
+		),
+		grpc.WithChainStreamInterceptor(
+			streamInterceptors...,
+		),
 	}
+	if c.Keepalive.Time > 0 {
+		options = append(options, grpc.WithKeepaliveParams(c.Keepalive))
+	}
+	return options
+}
 
-	return inter
+func (c Config) retryPolicy() interceptors.RetryPolicy {
+	if c.RetryPolicy != nil {
+		return c.RetryPolicy
+	}
+	return interceptors.DefaultRetryPolicy(c.Retries)
+}
+
+// NodeRateLimit are the rate limits of a single access node.
+type NodeRateLimit struct {
+	DefaultRateLimit   int
+	SpecificRateLimits map[string]int
+	// DefaultBurst and SpecificBursts are the number of requests that can be sent at once,
+	// after fewer requests than the rate limit were sent for a while. See interceptors.RateLimit.
+	DefaultBurst   int
+	SpecificBursts map[string]int
 }
 
 type Option func(*Config)
@@ -149,18 +399,275 @@ func WithLog(log zerolog.Logger) Option {
 	}
 }
 
+// WithRateLimits sets the default rate limit, and the rate limits of the given methods.
+// The rate limits of the other methods are kept.
+// Method names are the full gRPC method names, e.g. MethodExecuteScriptAtBlockHeight.
+func WithRateLimits(
+	defaultRateLimit interceptors.RateLimit,
+	methodRateLimits map[string]interceptors.RateLimit,
+) Option {
+	return func(c *Config) {
+		c.DefaultRateLimit = defaultRateLimit.Rate
+		c.DefaultBurst = defaultRateLimit.Burst
+
+		specificRateLimits := make(map[string]int, len(c.SpecificRateLimits)+len(methodRateLimits))
+		for method, rate := range c.SpecificRateLimits {
+			specificRateLimits[method] = rate
+		}
+		specificBursts := make(map[string]int, len(c.SpecificBursts)+len(methodRateLimits))
+		for method, burst := range c.SpecificBursts {
+			specificBursts[method] = burst
+		}
+		for method, limit := range methodRateLimits {
+			specificRateLimits[method] = limit.Rate
+			specificBursts[method] = limit.Burst
+		}
+		c.SpecificRateLimits = specificRateLimits
+		c.SpecificBursts = specificBursts
+	}
+}
+
+// WithRateLimitReloader changes the rate limits of the client when the reloader is reloaded,
+// e.g. to tune the throughput of a long-running scan without restarting it.
+func WithRateLimitReloader(reloader *interceptors.RateLimitReloader) Option {
+	return func(c *Config) {
+		c.RateLimitReloader = reloader
+	}
+}
+
+// WithCircuitBreaker stops sending requests to an access node after consecutive failures.
+// The requests fail fast with interceptors.ErrCircuitOpen, or go to another access node if there are multiple.
+func WithCircuitBreaker(config interceptors.CircuitBreakerConfig) Option {
+	return func(c *Config) {
+		c.CircuitBreaker = &config
+	}
+}
+
+// WithAdaptiveRateLimit lowers the rate limits when the access node returns ResourceExhausted,
+// and ramps them back up when it does not.
+// The rate limits of the Config are the rates it ramps up to.
+func WithAdaptiveRateLimit(config interceptors.AdaptiveRateLimitConfig) Option {
+	return func(c *Config) {
+		c.AdaptiveRateLimit = &config
+	}
+}
+
+// WithTLS connects to the access nodes with TLS. A nil config uses the system root certificates.
+func WithTLS(config *tls.Config) Option {
+	return func(c *Config) {
+		c.TransportCredentials = credentials.NewTLS(config)
+	}
+}
+
+// WithTransportCredentials sets the credentials of the connection.
+func WithTransportCredentials(transportCredentials credentials.TransportCredentials) Option {
+	return func(c *Config) {
+		c.TransportCredentials = transportCredentials
+	}
+}
+
+// WithHeader adds a header to the metadata of every request, e.g. an API key.
+func WithHeader(key string, value string) Option {
+	return func(c *Config) {
+		headers := make(map[string]string, len(c.Headers)+1)
+		for k, v := range c.Headers {
+			headers[k] = v
+		}
+		headers[key] = value
+		c.Headers = headers
+	}
+}
+
+// WithBearerToken adds an authorization header with the token, e.g. a JWT, to every request.
+func WithBearerToken(token string) Option {
+	return WithHeader("authorization", "Bearer "+token)
+}
+
+// WithInterceptors adds unary interceptors, that are called once per call before the interceptors of the client.
+func WithInterceptors(inter ...grpc.UnaryClientInterceptor) Option {
+	return func(c *Config) {
+		c.UnaryInterceptors = append(c.UnaryInterceptors[:len(c.UnaryInterceptors):len(c.UnaryInterceptors)], inter...)
+	}
+}
+
+// WithStreamInterceptors adds stream interceptors.
+func WithStreamInterceptors(inter ...grpc.StreamClientInterceptor) Option {
+	return func(c *Config) {
+		c.StreamInterceptors = append(c.StreamInterceptors[:len(c.StreamInterceptors):len(c.StreamInterceptors)], inter...)
+	}
+}
+
+// WithTracing records a span for every call with the provider,
+// and propagates the trace context to the access node, e.g. to continue the trace of the scan.
+func WithTracing(provider trace.TracerProvider) Option {
+	return func(c *Config) {
+		c.TracerProvider = provider
+	}
+}
+
+// WithRateLimitSchedule sets different rate limits for certain times of the day.
+// For example to scan at full speed during the night and throttle during business hours.
+func WithRateLimitSchedule(schedule ...interceptors.RateLimitWindow) Option {
+	return func(c *Config) {
+		c.RateLimitSchedule = schedule
+	}
+}
+
+// WithRetryPolicy sets the retry policy used for all methods without a specific retry policy.
+func WithRetryPolicy(policy interceptors.RetryPolicy) Option {
+	return func(c *Config) {
+		c.RetryPolicy = policy
+	}
+}
+
+// WithMethodRetryPolicy sets the retry policy of one method, e.g. MethodExecuteScriptAtBlockHeight.
+func WithMethodRetryPolicy(method string, policy interceptors.RetryPolicy) Option {
+	return func(c *Config) {
+		if c.SpecificRetryPolicies == nil {
+			c.SpecificRetryPolicies = make(map[string]interceptors.RetryPolicy)
+		}
+		c.SpecificRetryPolicies[method] = policy
+	}
+}
+
+// WithFailover sets how long a failed access node is not used,
+// and how often the access nodes are health checked, when there are multiple access nodes.
+func WithFailover(cooldown time.Duration, healthCheckInterval time.Duration) Option {
+	return func(c *Config) {
+		c.FailoverCooldown = cooldown
+		c.HealthCheckInterval = healthCheckInterval
+	}
+}
+
+// WithLoadBalancing sets how the calls are spread over multiple access nodes.
+func WithLoadBalancing(loadBalancing LoadBalancing) Option {
+	return func(c *Config) {
+		c.LoadBalancing = loadBalancing
+	}
+}
+
+// WithNodeRateLimit sets the rate limits of the access node at target.
+func WithNodeRateLimit(target string, defaultRateLimit int, specificRateLimits map[string]int) Option {
+	return func(c *Config) {
+		if c.NodeRateLimits == nil {
+			c.NodeRateLimits = make(map[string]NodeRateLimit)
+		}
+		c.NodeRateLimits[target] = NodeRateLimit{
+			DefaultRateLimit:   defaultRateLimit,
+			SpecificRateLimits: specificRateLimits,
+		}
+	}
+}
+
+// WithMaxConcurrentRequests allows at most perNode requests in flight to each access node.
+// The clients created with the same Option share the limit, see ConcurrencyBudget.
+func WithMaxConcurrentRequests(perNode int) Option {
+	return WithConcurrencyBudget(NewConcurrencyBudget(perNode))
+}
+
+// WithConcurrencyBudget bounds the requests in flight to each access node by the budget,
+// which can be shared with other clients.
+func WithConcurrencyBudget(budget *ConcurrencyBudget) Option {
+	return func(c *Config) {
+		c.ConcurrencyBudget = budget
+	}
+}
+
+// WithConnectionPool opens size connections to each access node.
+func WithConnectionPool(size int) Option {
+	return func(c *Config) {
+		c.ConnectionsPerNode = size
+	}
+}
+
+// WithKeepalive pings the access node after interval without activity on a connection,
+// and closes the connection if the ping is not answered within timeout.
+// Access nodes close connections that ping more often than they allow.
+func WithKeepalive(interval time.Duration, timeout time.Duration) Option {
+	return func(c *Config) {
+		c.Keepalive = keepalive.ClientParameters{
+			Time:                interval,
+			Timeout:             timeout,
+			PermitWithoutStream: true,
+		}
+	}
+}
+
+// WithBlockHeaderCache caches the block headers for ttl. A ttl of 0 disables the cache.
+func WithBlockHeaderCache(ttl time.Duration, size int) Option {
+	return func(c *Config) {
+		c.BlockHeaderCacheTTL = ttl
+		c.BlockHeaderCacheSize = size
+	}
+}
+
+// WithBlockHeaderPrefetch prefetches the headers of the next heights after a requested block header.
+// It needs the block header cache, the prefetched headers are cached for its ttl.
+func WithBlockHeaderPrefetch(heights int) Option {
+	return func(c *Config) {
+		c.BlockHeaderPrefetch = heights
+	}
+}
+
+// NewClient connects to the access node at target.
+// target can be a comma separated list of access nodes, in which case the client fails over
+// to the next access node when one is unavailable, or balances the load over the access nodes (see FailoverConn).
+// If target is an http(s) URL, the Access REST API is used (see NewRESTClient).
+// The block headers are cached for Config.BlockHeaderCacheTTL.
 func NewClient(
 	target string,
 	opts ...Option,
 ) (ClosableClient, error) {
-	conn, err := NewConnection(target, opts...)
+	conf := DefaultConfig()
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	client, err := newClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return conf.withBlockHeaderCache(client), nil
+}
+
+func newClient(
+	target string,
+	opts ...Option,
+) (ClosableClient, error) {
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		return NewRESTClient(target, opts...)
+	}
+
+	targets := cleanTargets(strings.Split(target, ","))
+	if len(targets) != 1 {
+		return NewFailoverClient(targets, opts...)
+	}
+	target = targets[0]
+
+	conn, err := NewPooledConnection(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	client := NewClientFromConnection(conn)
+	return &closableClient{
+		Client: client,
+		Closer: conn,
+	}, nil
+}
+
+// NewFailoverClient connects to multiple access nodes, and fails over to the next one when one is unavailable.
+func NewFailoverClient(
+	targets []string,
+	opts ...Option,
+) (ClosableClient, error) {
+	conn, err := NewFailoverConnection(targets, opts...)
 	if err != nil {
 		return nil, err
 	}
 	client := NewClientFromConnection(conn)
 	return &closableClient{
-		Client:     client,
-		ClientConn: conn,
+		Client: client,
+		Closer: conn,
 	}, nil
 }
 
@@ -175,13 +682,7 @@ func NewConnection(
 
 	return grpc.Dial(
 		target,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithDefaultCallOptions(
-			conf.DefaultCallOptions...,
-		),
-		grpc.WithChainUnaryInterceptor(
-			conf.Interceptors()...,
-		),
+		conf.dialOptions(conf.interceptors(target))...,
 	)
 }
 
@@ -189,12 +690,21 @@ var _ Client = (*client)(nil)
 
 type client struct {
 	*flowgrpc.BaseClient
+	accessClient        protoAccess.AccessAPIClient
+	executionDataClient protoExecutionData.ExecutionDataAPIClient
 }
 
 func (c *client) GetLatestBlockHeader(ctx context.Context, isSealed bool) (*flow.BlockHeader, error) {
 	return c.BaseClient.GetLatestBlockHeader(ctx, isSealed)
 }
 
+func (c *client) GetBlockHeaderByHeight(
+	ctx context.Context,
+	height uint64,
+) (*flow.BlockHeader, error) {
+	return c.BaseClient.GetBlockHeaderByHeight(ctx, height)
+}
+
 func (c *client) ExecuteScriptAtBlockHeight(
 	ctx context.Context,
 	height uint64,
@@ -204,6 +714,14 @@ func (c *client) ExecuteScriptAtBlockHeight(
 	return c.BaseClient.ExecuteScriptAtBlockHeight(ctx, height, script, arguments)
 }
 
+func (c *client) GetAccountAtBlockHeight(
+	ctx context.Context,
+	address flow.Address,
+	height uint64,
+) (*flow.Account, error) {
+	return c.BaseClient.GetAccountAtBlockHeight(ctx, address, height)
+}
+
 func (c *client) GetBlockByHeight(
 	ctx context.Context,
 	height uint64,
@@ -231,3 +749,115 @@ func (c *client) GetCollection(
 ) (*flow.Collection, error) {
 	return c.BaseClient.GetCollection(ctx, colID)
 }
+
+func (c *client) GetTransactionResultsByBlockID(
+	ctx context.Context,
+	blockID flow.Identifier,
+) ([]*flow.TransactionResult, error) {
+	return c.BaseClient.GetTransactionResultsByBlockID(ctx, blockID)
+}
+
+func (c *client) GetExecutionDataByBlockID(
+	ctx context.Context,
+	blockID flow.Identifier,
+) (*entities.BlockExecutionData, error) {
+	res, err := c.executionDataClient.GetExecutionDataByBlockID(
+		ctx,
+		&protoExecutionData.GetExecutionDataByBlockIDRequest{BlockId: blockID.Bytes()},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return res.GetBlockExecutionData(), nil
+}
+
+func (c *client) GetNetworkParameters(ctx context.Context) (*NetworkParameters, error) {
+	res, err := c.accessClient.GetNetworkParameters(ctx, &protoAccess.GetNetworkParametersRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return &NetworkParameters{ChainID: flow.ChainID(res.GetChainId())}, nil
+}
+
+func (c *client) SubscribeEvents(
+	ctx context.Context,
+	startHeight uint64,
+	eventTypes []string,
+) (<-chan flow.BlockEvents, <-chan error, error) {
+	stream, err := c.executionDataClient.SubscribeEvents(
+		ctx,
+		&protoExecutionData.SubscribeEventsRequest{
+			StartBlockHeight: startHeight,
+			Filter: &protoExecutionData.EventFilter{
+				EventType: eventTypes,
+			},
+			// a heartbeat for every block, so that blocks without events are also received
+			HeartbeatInterval: 1,
+		},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	eventsChan := make(chan flow.BlockEvents)
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(eventsChan)
+		defer close(errChan)
+
+		for {
+			res, err := stream.Recv()
+			if err != nil {
+				if err != io.EOF {
+					errChan <- err
+				}
+				return
+			}
+
+			blockEvents, err := messageToBlockEvents(res)
+			if err != nil {
+				errChan <- err
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case eventsChan <- blockEvents:
+			}
+		}
+	}()
+
+	return eventsChan, errChan, nil
+}
+
+func messageToBlockEvents(m *protoExecutionData.SubscribeEventsResponse) (flow.BlockEvents, error) {
+	blockEvents := flow.BlockEvents{
+		BlockID: flow.BytesToID(m.GetBlockId()),
+		Height:  m.GetBlockHeight(),
+		Events:  make([]flow.Event, 0, len(m.GetEvents())),
+	}
+	if m.GetBlockTimestamp() != nil {
+		blockEvents.BlockTimestamp = m.GetBlockTimestamp().AsTime()
+	}
+
+	for _, event := range m.GetEvents() {
+		value, err := json.Decode(nil, event.GetPayload(), json.WithAllowUnstructuredStaticTypes(true))
+		if err != nil {
+			return flow.BlockEvents{}, err
+		}
+		eventValue, ok := value.(cadence.Event)
+		if !ok {
+			return flow.BlockEvents{}, fmt.Errorf("event payload is not an event: %s", event.GetType())
+		}
+		blockEvents.Events = append(blockEvents.Events, flow.Event{
+			Type:             event.GetType(),
+			TransactionID:    flow.BytesToID(event.GetTransactionId()),
+			TransactionIndex: int(event.GetTransactionIndex()),
+			EventIndex:       int(event.GetEventIndex()),
+			Value:            eventValue,
+			Payload:          event.GetPayload(),
+		})
+	}
+	return blockEvents, nil
+}
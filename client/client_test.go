@@ -0,0 +1,118 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	protoAccess "github.com/onflow/flow/protobuf/go/flow/access"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/onflow/flow-batch-scan/client/interceptors"
+)
+
+func TestWithRateLimits(t *testing.T) {
+	conf := DefaultConfig()
+	WithRateLimits(
+		interceptors.RateLimit{Rate: 20, Burst: 5},
+		map[string]interceptors.RateLimit{
+			MethodGetEventsForHeightRange: {Rate: 30, Burst: 10},
+		},
+	)(&conf)
+
+	require.Equal(t, 20, conf.DefaultRateLimit)
+	require.Equal(t, 5, conf.DefaultBurst)
+	require.Equal(t, 30, conf.SpecificRateLimits[MethodGetEventsForHeightRange])
+	require.Equal(t, 10, conf.SpecificBursts[MethodGetEventsForHeightRange])
+	// the rate limits of the other methods are kept
+	require.Equal(t, 2, conf.SpecificRateLimits[MethodExecuteScriptAtBlockHeight])
+	require.Equal(t, 2, DefaultConfig().SpecificRateLimits[MethodExecuteScriptAtBlockHeight])
+}
+
+func TestPresetForNetwork(t *testing.T) {
+	for _, name := range []string{"mainnet", "testnet", "emulator"} {
+		preset, ok := PresetForNetwork(name)
+		require.True(t, ok)
+		require.Equal(t, name, preset.Name)
+		require.NotEmpty(t, preset.AccessNodes)
+
+		conf := DefaultConfig()
+		for _, option := range preset.Options() {
+			option(&conf)
+		}
+		require.Equal(t, preset.DefaultRateLimit.Rate, conf.DefaultRateLimit)
+		require.Equal(t,
+			preset.MethodRateLimits[MethodExecuteScriptAtBlockHeight].Rate,
+			conf.SpecificRateLimits[MethodExecuteScriptAtBlockHeight],
+		)
+	}
+
+	_, ok := PresetForNetwork("devnet")
+	require.False(t, ok)
+}
+
+func TestWithInterceptors(t *testing.T) {
+	_, target := startPingServer(t)
+
+	var methods []string
+	conn, err := NewConnection(
+		target,
+		WithInterceptors(func(
+			ctx context.Context,
+			method string,
+			req, reply interface{},
+			cc *grpc.ClientConn,
+			invoker grpc.UnaryInvoker,
+			opts ...grpc.CallOption,
+		) error {
+			methods = append(methods, method)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}),
+	)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, conn.Close())
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = protoAccess.NewAccessAPIClient(conn).Ping(ctx, &protoAccess.PingRequest{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"/flow.access.AccessAPI/Ping"}, methods)
+}
+
+func TestWithHeader(t *testing.T) {
+	ping, target := startPingServer(t)
+
+	conn, err := NewConnection(
+		target,
+		WithHeader("x-api-key", "key"),
+		WithBearerToken("token"),
+	)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, conn.Close())
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = protoAccess.NewAccessAPIClient(conn).Ping(ctx, &protoAccess.PingRequest{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"key"}, ping.headers.Get("x-api-key"))
+	require.Equal(t, []string{"Bearer token"}, ping.headers.Get("authorization"))
+}
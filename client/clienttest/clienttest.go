@@ -0,0 +1,492 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clienttest provides an in-memory client.Client for tests,
+// e.g. of custom candidate scanners and result handlers:
+//
+//	c := clienttest.New()
+//	c.AddBlock(100, clienttest.Transaction{
+//		Authorizers: []flow.Address{address},
+//		Events:      []flow.Event{event},
+//	})
+//	c.HandleScripts(func(height uint64, script []byte, arguments []cadence.Value) (cadence.Value, error) {
+//		return cadence.NewBool(true), nil
+//	})
+package clienttest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	flowgrpc "github.com/onflow/flow-go-sdk/access/grpc"
+	"github.com/onflow/flow/protobuf/go/flow/entities"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/onflow/flow-batch-scan/client"
+)
+
+// Transaction is a transaction of a block, with the events it emitted.
+type Transaction struct {
+	Script      []byte
+	Arguments   [][]byte
+	Authorizers []flow.Address
+	Payer       flow.Address
+	Proposer    flow.Address
+	Events      []flow.Event
+	// ErrorMessage if set, marks the transaction as failed.
+	ErrorMessage string
+}
+
+// ScriptHandler returns the result of a script executed at a height.
+type ScriptHandler func(height uint64, script []byte, arguments []cadence.Value) (cadence.Value, error)
+
+// AccountHandler returns the account at a height.
+type AccountHandler func(height uint64, address flow.Address) (*flow.Account, error)
+
+// Client is an in-memory client.Client. Its blocks and script results are scripted by the test.
+// Blocks that were not added, and unknown IDs return a NotFound error, like an access node does.
+// Client is safe for concurrent use.
+type Client struct {
+	mu sync.Mutex
+
+	blocks        map[uint64]*flow.Block
+	latest        *flow.Block
+	collections   map[flow.Identifier]*flow.Collection
+	transactions  map[flow.Identifier]*flow.Transaction
+	results       map[flow.Identifier][]*flow.TransactionResult
+	events        map[uint64][]flow.Event
+	executionData map[flow.Identifier]*entities.BlockExecutionData
+	scripts       ScriptHandler
+	accounts      AccountHandler
+	chainID       flow.ChainID
+
+	errors map[string]error
+	calls  map[string]int
+	// added is closed and replaced when a block is added, to wake up the subscriptions.
+	added chan struct{}
+}
+
+var _ client.Client = (*Client)(nil)
+
+func New() *Client {
+	return &Client{
+		blocks:        make(map[uint64]*flow.Block),
+		collections:   make(map[flow.Identifier]*flow.Collection),
+		transactions:  make(map[flow.Identifier]*flow.Transaction),
+		results:       make(map[flow.Identifier][]*flow.TransactionResult),
+		events:        make(map[uint64][]flow.Event),
+		executionData: make(map[flow.Identifier]*entities.BlockExecutionData),
+		errors:        make(map[string]error),
+		calls:         make(map[string]int),
+		added:         make(chan struct{}),
+		chainID:       flow.Emulator,
+	}
+}
+
+// BlockID is the ID of the block at height.
+func BlockID(height uint64) flow.Identifier {
+	return flow.HashToID([]byte(fmt.Sprintf("block-%d", height)))
+}
+
+// AddBlock adds a block at height, with a single collection of the transactions.
+// The highest block is the latest block. Subscriptions receive the events of the block.
+func (c *Client) AddBlock(height uint64, transactions ...Transaction) *flow.Block {
+	block := &flow.Block{
+		BlockHeader: flow.BlockHeader{
+			ID:       BlockID(height),
+			ParentID: BlockID(height - 1),
+			Height:   height,
+		},
+	}
+
+	collection := &flow.Collection{}
+	var results []*flow.TransactionResult
+	var events []flow.Event
+	var txs []*flow.Transaction
+	for i, fixture := range transactions {
+		tx := &flow.Transaction{
+			Script:           fixture.Script,
+			Arguments:        fixture.Arguments,
+			ReferenceBlockID: block.ParentID,
+			Authorizers:      fixture.Authorizers,
+			Payer:            fixture.Payer,
+			// the sequence number makes the IDs of equal transactions unique
+			ProposalKey: flow.ProposalKey{Address: fixture.Proposer, SequenceNumber: uint64(i)},
+		}
+		txID := tx.ID()
+		collection.TransactionIDs = append(collection.TransactionIDs, txID)
+
+		txEvents := make([]flow.Event, len(fixture.Events))
+		for j, event := range fixture.Events {
+			event.TransactionID = txID
+			event.TransactionIndex = i
+			event.EventIndex = j
+			txEvents[j] = event
+		}
+		events = append(events, txEvents...)
+
+		var txErr error
+		if fixture.ErrorMessage != "" {
+			txErr = fmt.Errorf("%s", fixture.ErrorMessage)
+		}
+		results = append(results, &flow.TransactionResult{
+			Status:        flow.TransactionStatusSealed,
+			Error:         txErr,
+			Events:        txEvents,
+			BlockID:       block.ID,
+			BlockHeight:   height,
+			TransactionID: txID,
+		})
+		txs = append(txs, tx)
+	}
+	if len(transactions) > 0 {
+		block.CollectionGuarantees = []*flow.CollectionGuarantee{{CollectionID: collection.ID()}}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blocks[height] = block
+	for _, tx := range txs {
+		c.transactions[tx.ID()] = tx
+	}
+	if len(transactions) > 0 {
+		c.collections[collection.ID()] = collection
+	}
+	c.results[block.ID] = results
+	c.events[height] = events
+	if c.latest == nil || height > c.latest.Height {
+		c.latest = block
+	}
+	close(c.added)
+	c.added = make(chan struct{})
+	return block
+}
+
+// AddBlocks adds empty blocks from start to end, inclusive.
+func (c *Client) AddBlocks(start uint64, end uint64) {
+	for height := start; height <= end; height++ {
+		c.AddBlock(height)
+	}
+}
+
+// SetExecutionData sets the execution data of a block.
+func (c *Client) SetExecutionData(blockID flow.Identifier, data *entities.BlockExecutionData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.executionData[blockID] = data
+}
+
+// HandleScripts sets the handler of ExecuteScriptAtBlockHeight.
+// Without a handler, scripts return an Unimplemented error.
+func (c *Client) HandleScripts(handler ScriptHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.scripts = handler
+}
+
+// HandleAccounts sets the handler of GetAccountAtBlockHeight.
+// Without a handler, accounts return an Unimplemented error.
+func (c *Client) HandleAccounts(handler AccountHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accounts = handler
+}
+
+// SetChainID sets the chain ID returned by GetNetworkParameters. The default is flow.Emulator.
+func (c *Client) SetChainID(chainID flow.ChainID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.chainID = chainID
+}
+
+// SetError makes all calls of the method fail with err, until it is set to nil.
+// The methods are the gRPC methods of the client package, e.g. client.MethodGetEventsForHeightRange.
+func (c *Client) SetError(method string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		delete(c.errors, method)
+		return
+	}
+	c.errors[method] = err
+}
+
+// Calls is the number of calls of the method, including the failed calls.
+func (c *Client) Calls(method string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[method]
+}
+
+// call counts the call of the method, and returns the error set for the method.
+// The caller must hold the lock.
+func (c *Client) call(method string) error {
+	c.calls[method]++
+	return c.errors[method]
+}
+
+func (c *Client) GetLatestBlockHeader(_ context.Context, _ bool) (*flow.BlockHeader, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.call(client.MethodGetLatestBlockHeader); err != nil {
+		return nil, err
+	}
+	if c.latest == nil {
+		return nil, status.Error(codes.NotFound, "no blocks")
+	}
+	header := c.latest.BlockHeader
+	return &header, nil
+}
+
+func (c *Client) GetBlockHeaderByHeight(_ context.Context, height uint64) (*flow.BlockHeader, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.call(client.MethodGetBlockHeaderByHeight); err != nil {
+		return nil, err
+	}
+	block, err := c.block(height)
+	if err != nil {
+		return nil, err
+	}
+	header := block.BlockHeader
+	return &header, nil
+}
+
+func (c *Client) GetBlockByHeight(_ context.Context, height uint64) (*flow.Block, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.call(client.MethodGetBlockByHeight); err != nil {
+		return nil, err
+	}
+	return c.block(height)
+}
+
+func (c *Client) block(height uint64) (*flow.Block, error) {
+	block, ok := c.blocks[height]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "block at height %d not found", height)
+	}
+	return block, nil
+}
+
+func (c *Client) ExecuteScriptAtBlockHeight(
+	_ context.Context,
+	height uint64,
+	script []byte,
+	arguments []cadence.Value,
+) (cadence.Value, error) {
+	c.mu.Lock()
+	err := c.call(client.MethodExecuteScriptAtBlockHeight)
+	if err == nil {
+		_, err = c.block(height)
+	}
+	scripts := c.scripts
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if scripts == nil {
+		return nil, status.Error(codes.Unimplemented, "no script handler")
+	}
+	return scripts(height, script, arguments)
+}
+
+func (c *Client) GetAccountAtBlockHeight(
+	_ context.Context,
+	address flow.Address,
+	height uint64,
+) (*flow.Account, error) {
+	c.mu.Lock()
+	err := c.call(client.MethodGetAccountAtBlockHeight)
+	if err == nil {
+		_, err = c.block(height)
+	}
+	accounts := c.accounts
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if accounts == nil {
+		return nil, status.Error(codes.Unimplemented, "no account handler")
+	}
+	return accounts(height, address)
+}
+
+func (c *Client) GetTransaction(_ context.Context, txID flow.Identifier) (*flow.Transaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.call(client.MethodGetTransaction); err != nil {
+		return nil, err
+	}
+	tx, ok := c.transactions[txID]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "transaction %s not found", txID)
+	}
+	return tx, nil
+}
+
+func (c *Client) GetEventsForHeightRange(
+	_ context.Context,
+	query flowgrpc.EventRangeQuery,
+) ([]flow.BlockEvents, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.call(client.MethodGetEventsForHeightRange); err != nil {
+		return nil, err
+	}
+
+	var result []flow.BlockEvents
+	for height := query.StartHeight; height <= query.EndHeight; height++ {
+		block, err := c.block(height)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, c.blockEvents(block, []string{query.Type}))
+	}
+	return result, nil
+}
+
+// blockEvents are the events of the block with one of the types.
+func (c *Client) blockEvents(block *flow.Block, eventTypes []string) flow.BlockEvents {
+	blockEvents := flow.BlockEvents{
+		BlockID:        block.ID,
+		Height:         block.Height,
+		BlockTimestamp: block.Timestamp,
+	}
+	for _, event := range c.events[block.Height] {
+		for _, eventType := range eventTypes {
+			if event.Type == eventType {
+				blockEvents.Events = append(blockEvents.Events, event)
+				break
+			}
+		}
+	}
+	return blockEvents
+}
+
+func (c *Client) GetCollection(_ context.Context, colID flow.Identifier) (*flow.Collection, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.call(client.MethodGetCollectionByID); err != nil {
+		return nil, err
+	}
+	collection, ok := c.collections[colID]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "collection %s not found", colID)
+	}
+	return collection, nil
+}
+
+func (c *Client) GetTransactionResultsByBlockID(
+	_ context.Context,
+	blockID flow.Identifier,
+) ([]*flow.TransactionResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.call(client.MethodGetTransactionResultsByBlockID); err != nil {
+		return nil, err
+	}
+	results, ok := c.results[blockID]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "block %s not found", blockID)
+	}
+	return results, nil
+}
+
+func (c *Client) GetExecutionDataByBlockID(
+	_ context.Context,
+	blockID flow.Identifier,
+) (*entities.BlockExecutionData, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.call(client.MethodGetExecutionDataByBlockID); err != nil {
+		return nil, err
+	}
+	data, ok := c.executionData[blockID]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "execution data of block %s not found", blockID)
+	}
+	return data, nil
+}
+
+// SubscribeEvents streams the events of every block from startHeight,
+// including the blocks that are added after the subscription started, until ctx is done.
+func (c *Client) SubscribeEvents(
+	ctx context.Context,
+	startHeight uint64,
+	eventTypes []string,
+) (<-chan flow.BlockEvents, <-chan error, error) {
+	c.mu.Lock()
+	err := c.call(client.MethodSubscribeEvents)
+	c.mu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	eventsChan := make(chan flow.BlockEvents)
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(eventsChan)
+		defer close(errChan)
+
+		for height := startHeight; ; height++ {
+			blockEvents, ok := c.waitForBlock(ctx, height, eventTypes)
+			if !ok {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case eventsChan <- blockEvents:
+			}
+		}
+	}()
+	return eventsChan, errChan, nil
+}
+
+// waitForBlock waits until the block at height is added, and returns its events.
+func (c *Client) waitForBlock(ctx context.Context, height uint64, eventTypes []string) (flow.BlockEvents, bool) {
+	for {
+		c.mu.Lock()
+		block, ok := c.blocks[height]
+		added := c.added
+		var blockEvents flow.BlockEvents
+		if ok {
+			blockEvents = c.blockEvents(block, eventTypes)
+		}
+		c.mu.Unlock()
+		if ok {
+			return blockEvents, true
+		}
+
+		select {
+		case <-ctx.Done():
+			return flow.BlockEvents{}, false
+		case <-added:
+		}
+	}
+}
+
+func (c *Client) GetNetworkParameters(context.Context) (*client.NetworkParameters, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.call(client.MethodGetNetworkParameters); err != nil {
+		return nil, err
+	}
+	return &client.NetworkParameters{ChainID: c.chainID}, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clienttest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	flowgrpc "github.com/onflow/flow-go-sdk/access/grpc"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	address := flow.HexToAddress("0x01")
+	c := clienttest.New()
+	c.AddBlocks(1, 9)
+	block := c.AddBlock(10, clienttest.Transaction{
+		Authorizers: []flow.Address{address},
+		Events:      []flow.Event{{Type: "A.0000000000000001.Contract.Event"}},
+	})
+
+	header, err := c.GetLatestBlockHeader(ctx, true)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), header.Height)
+
+	_, err = c.GetBlockHeaderByHeight(ctx, 11)
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	events, err := c.GetEventsForHeightRange(ctx, flowgrpc.EventRangeQuery{
+		Type:        "A.0000000000000001.Contract.Event",
+		StartHeight: 9,
+		EndHeight:   10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Empty(t, events[0].Events)
+	require.Len(t, events[1].Events, 1)
+
+	// the transactions can be found through the collections of the block
+	collection, err := c.GetCollection(ctx, block.CollectionGuarantees[0].CollectionID)
+	require.NoError(t, err)
+	tx, err := c.GetTransaction(ctx, collection.TransactionIDs[0])
+	require.NoError(t, err)
+	require.Equal(t, []flow.Address{address}, tx.Authorizers)
+	results, err := c.GetTransactionResultsByBlockID(ctx, block.ID)
+	require.NoError(t, err)
+	require.Equal(t, events[1].Events, results[0].Events)
+
+	c.HandleScripts(func(height uint64, _ []byte, _ []cadence.Value) (cadence.Value, error) {
+		return cadence.NewUInt64(height), nil
+	})
+	value, err := c.ExecuteScriptAtBlockHeight(ctx, 5, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, cadence.NewUInt64(5), value)
+
+	c.SetError(client.MethodExecuteScriptAtBlockHeight, status.Error(codes.ResourceExhausted, "rate limited"))
+	_, err = c.ExecuteScriptAtBlockHeight(ctx, 5, nil, nil)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.Equal(t, 2, c.Calls(client.MethodExecuteScriptAtBlockHeight))
+}
+
+func TestClient_SubscribeEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c := clienttest.New()
+	c.AddBlock(1)
+	eventsChan, _, err := c.SubscribeEvents(ctx, 1, []string{"A.0000000000000001.Contract.Event"})
+	require.NoError(t, err)
+
+	blockEvents := <-eventsChan
+	require.Equal(t, uint64(1), blockEvents.Height)
+
+	// blocks added after the subscription started are streamed as well
+	c.AddBlock(2, clienttest.Transaction{
+		Events: []flow.Event{{Type: "A.0000000000000001.Contract.Event"}},
+	})
+	blockEvents = <-eventsChan
+	require.Equal(t, uint64(2), blockEvents.Height)
+	require.Len(t, blockEvents.Events, 1)
+}
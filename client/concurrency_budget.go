@@ -0,0 +1,63 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"sync"
+
+	"github.com/onflow/flow-batch-scan/client/interceptors"
+)
+
+// ConcurrencyBudget bounds the requests in flight to each access node.
+// Clients created with the same budget (see WithConcurrencyBudget) share the limit of each access node,
+// so e.g. the client of the blocks and events and the ScriptClient of a scan together
+// do not have more than the budget of requests in flight to the same node.
+type ConcurrencyBudget struct {
+	perNode int
+
+	mu       sync.Mutex
+	limiters map[string]*interceptors.ConcurrencyLimiter
+}
+
+// NewConcurrencyBudget allows perNode requests in flight to each access node.
+func NewConcurrencyBudget(perNode int) *ConcurrencyBudget {
+	return &ConcurrencyBudget{
+		perNode:  perNode,
+		limiters: make(map[string]*interceptors.ConcurrencyLimiter),
+	}
+}
+
+// Limiter is the limiter of the access node at target.
+func (b *ConcurrencyBudget) Limiter(target string) *interceptors.ConcurrencyLimiter {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	limiter, ok := b.limiters[target]
+	if !ok {
+		limiter = interceptors.NewConcurrencyLimiter(b.perNode)
+		b.limiters[target] = limiter
+	}
+	return limiter
+}
+
+// InFlight is the number of requests in flight to each access node.
+func (b *ConcurrencyBudget) InFlight() map[string]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	inFlight := make(map[string]int, len(b.limiters))
+	for target, limiter := range b.limiters {
+		inFlight[target] = limiter.InFlight()
+	}
+	return inFlight
+}
@@ -0,0 +1,101 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/hashicorp/go-multierror"
+	"google.golang.org/grpc"
+)
+
+// ConnPool is a pool of connections to one access node.
+// A single connection is limited by the number of concurrent HTTP/2 streams,
+// so the calls and streams are spread over the connections round-robin.
+// The connections share the interceptors, so the rate limits apply to the whole pool.
+type ConnPool struct {
+	// next is the connection the next call is sent on.
+	next uint64
+
+	conns []*grpc.ClientConn
+}
+
+var _ grpc.ClientConnInterface = (*ConnPool)(nil)
+
+// NewPooledConnection opens Config.ConnectionsPerNode connections to the access node at target.
+func NewPooledConnection(
+	target string,
+	opts ...Option,
+) (*ConnPool, error) {
+	conf := DefaultConfig()
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	return dialPool(target, conf.ConnectionsPerNode, conf.dialOptions(conf.interceptors(target)))
+}
+
+func dialPool(target string, size int, options []grpc.DialOption) (*ConnPool, error) {
+	if size < 1 {
+		size = 1
+	}
+	p := &ConnPool{
+		conns: make([]*grpc.ClientConn, 0, size),
+	}
+	for i := 0; i < size; i++ {
+		conn, err := grpc.Dial(target, options...)
+		if err != nil {
+			_ = p.Close()
+			return nil, err
+		}
+		p.conns = append(p.conns, conn)
+	}
+	return p, nil
+}
+
+func (p *ConnPool) conn() *grpc.ClientConn {
+	i := atomic.AddUint64(&p.next, 1) - 1
+	return p.conns[i%uint64(len(p.conns))]
+}
+
+func (p *ConnPool) Invoke(
+	ctx context.Context,
+	method string,
+	args interface{},
+	reply interface{},
+	opts ...grpc.CallOption,
+) error {
+	return p.conn().Invoke(ctx, method, args, reply, opts...)
+}
+
+func (p *ConnPool) NewStream(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	method string,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	return p.conn().NewStream(ctx, desc, method, opts...)
+}
+
+// Close closes all the connections of the pool.
+func (p *ConnPool) Close() error {
+	merr := &multierror.Error{}
+	for _, conn := range p.conns {
+		if err := conn.Close(); err != nil {
+			merr = multierror.Append(merr, err)
+		}
+	}
+	return merr.ErrorOrNil()
+}
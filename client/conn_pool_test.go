@@ -0,0 +1,49 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	protoAccess "github.com/onflow/flow/protobuf/go/flow/access"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnPool(t *testing.T) {
+	ping, target := startPingServer(t)
+
+	pool, err := NewPooledConnection(
+		target,
+		WithConnectionPool(3),
+		WithKeepalive(time.Minute, 10*time.Second),
+	)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, pool.Close())
+	}()
+	require.Len(t, pool.conns, 3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := protoAccess.NewAccessAPIClient(pool)
+	for i := 0; i < 3; i++ {
+		_, err = client.Ping(ctx, &protoAccess.PingRequest{})
+		require.NoError(t, err)
+	}
+	require.Equal(t, 3, ping.pings)
+	require.Equal(t, uint64(3), pool.next)
+}
@@ -0,0 +1,423 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	protoAccess "github.com/onflow/flow/protobuf/go/flow/access"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const pingMethod = "/flow.access.AccessAPI/Ping"
+
+// errNoAccessNodes is returned by the calls of a FailoverConn without any access node to send them to.
+var errNoAccessNodes = status.Error(codes.Unavailable, "no access nodes")
+
+// LoadBalancing decides how the calls are spread over multiple access nodes.
+type LoadBalancing int
+
+const (
+	// LoadBalancingFailover sends all calls to the first healthy access node. This is the default.
+	LoadBalancingFailover LoadBalancing = iota
+	// LoadBalancingRoundRobin sends the calls to the healthy access nodes in turn.
+	LoadBalancingRoundRobin
+	// LoadBalancingLeastLoaded sends each call to the healthy access node with the fewest calls in flight.
+	// Calls waiting for the rate limit of a node count as in flight, so slow or throttled nodes get fewer calls.
+	LoadBalancingLeastLoaded
+)
+
+// FailoverConn is a connection to multiple access nodes.
+// Calls are sent to the healthy nodes according to the LoadBalancing. When a node returns Unavailable
+// or DeadlineExceeded, it is considered unhealthy for a cool-down period, and the call is retried on the next node.
+// If all nodes are unhealthy, the nodes whose cool-down ends first are tried first.
+// The rate limits and the timeout apply to each node separately, the other interceptors once per call.
+type FailoverConn struct {
+	// next is the node the next round-robin call starts at.
+	next uint64
+
+	nodes         []*failoverNode
+	invoker       grpc.UnaryInvoker
+	loadBalancing LoadBalancing
+	// metrics are nil if metrics are disabled.
+	metrics *nodeMetrics
+
+	cooldown            time.Duration
+	healthCheckInterval time.Duration
+	log                 zerolog.Logger
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+var _ grpc.ClientConnInterface = (*FailoverConn)(nil)
+
+type failoverNode struct {
+	// inFlight is the number of calls to the node that did not return yet.
+	inFlight int64
+
+	target string
+	conn   *ConnPool
+
+	mu sync.Mutex
+	// retryAt is when the cool-down of an unhealthy node ends.
+	retryAt time.Time
+}
+
+func (n *failoverNode) retryTime() time.Time {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.retryAt
+}
+
+// NewFailoverConnection connects to all the targets. Empty targets are ignored,
+// and an error is returned if no targets are left.
+// The interceptors of the Config are applied once per call, not per node.
+func NewFailoverConnection(
+	targets []string,
+	opts ...Option,
+) (*FailoverConn, error) {
+	targets = cleanTargets(targets)
+	if len(targets) == 0 {
+		return nil, errors.New("no access node targets")
+	}
+
+	conf := DefaultConfig()
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	c := &FailoverConn{
+		loadBalancing:       conf.LoadBalancing,
+		cooldown:            conf.FailoverCooldown,
+		healthCheckInterval: conf.HealthCheckInterval,
+		log:                 conf.Log.With().Str("component", "failover_conn").Logger(),
+		done:                make(chan struct{}),
+	}
+	callInterceptors := conf.callInterceptors()
+	if conf.WithMetrics {
+		c.metrics = newNodeMetrics(conf.MetricsNamespace)
+		callInterceptors = append(callInterceptors, conf.metricsInterceptor())
+	}
+	for _, target := range targets {
+		nodeInterceptors := conf.nodeInterceptors(target)
+		if c.metrics != nil {
+			nodeInterceptors = append(nodeInterceptors, c.metrics.interceptor(target))
+		}
+		conn, err := dialPool(target, conf.ConnectionsPerNode, conf.dialOptions(nodeInterceptors))
+		if err != nil {
+			_ = c.closeConnections()
+			return nil, err
+		}
+		c.nodes = append(c.nodes, &failoverNode{
+			target: target,
+			conn:   conn,
+		})
+	}
+	c.invoker = chainUnaryInterceptors(callInterceptors, c.invoke)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	go c.checkHealth(ctx)
+
+	return c, nil
+}
+
+func (c *FailoverConn) Invoke(
+	ctx context.Context,
+	method string,
+	args interface{},
+	reply interface{},
+	opts ...grpc.CallOption,
+) error {
+	return c.invoker(ctx, method, args, reply, nil, opts...)
+}
+
+// NewStream opens the stream on a healthy node. Open streams do not fail over.
+func (c *FailoverConn) NewStream(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	method string,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	err := errNoAccessNodes
+	for _, node := range c.candidates() {
+		var stream grpc.ClientStream
+		stream, err = node.conn.NewStream(ctx, desc, method, opts...)
+		if !isNodeFailure(err) {
+			return stream, err
+		}
+		c.markUnhealthy(node, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+// Close stops the health checks and closes the connections to all nodes.
+func (c *FailoverConn) Close() error {
+	c.cancel()
+	<-c.done
+	return c.closeConnections()
+}
+
+func (c *FailoverConn) closeConnections() error {
+	merr := &multierror.Error{}
+	for _, node := range c.nodes {
+		if err := node.conn.Close(); err != nil {
+			merr = multierror.Append(merr, err)
+		}
+	}
+	return merr.ErrorOrNil()
+}
+
+func (c *FailoverConn) invoke(
+	ctx context.Context,
+	method string,
+	req interface{},
+	reply interface{},
+	_ *grpc.ClientConn,
+	opts ...grpc.CallOption,
+) error {
+	err := errNoAccessNodes
+	for _, node := range c.candidates() {
+		err = c.invokeNode(ctx, node, method, req, reply, opts...)
+		if !isNodeFailure(err) {
+			return err
+		}
+		c.markUnhealthy(node, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return err
+}
+
+func (c *FailoverConn) invokeNode(
+	ctx context.Context,
+	node *failoverNode,
+	method string,
+	req interface{},
+	reply interface{},
+	opts ...grpc.CallOption,
+) error {
+	inFlight := atomic.AddInt64(&node.inFlight, 1)
+	c.metrics.reportInFlight(node.target, inFlight)
+	defer func() {
+		inFlight := atomic.AddInt64(&node.inFlight, -1)
+		c.metrics.reportInFlight(node.target, inFlight)
+	}()
+
+	return node.conn.Invoke(ctx, method, req, reply, opts...)
+}
+
+// candidates are the healthy nodes in the order of the LoadBalancing,
+// followed by the unhealthy nodes in the order their cool-down ends.
+func (c *FailoverConn) candidates() []*failoverNode {
+	now := time.Now()
+	healthy := make([]*failoverNode, 0, len(c.nodes))
+	var unhealthy []*failoverNode
+	for _, node := range c.nodes {
+		if now.Before(node.retryTime()) {
+			unhealthy = append(unhealthy, node)
+			continue
+		}
+		healthy = append(healthy, node)
+	}
+
+	switch c.loadBalancing {
+	case LoadBalancingRoundRobin:
+		if len(healthy) > 1 {
+			start := int(atomic.AddUint64(&c.next, 1)-1) % len(healthy)
+			healthy = append(healthy[start:], healthy[:start]...)
+		}
+	case LoadBalancingLeastLoaded:
+		sort.SliceStable(healthy, func(i, j int) bool {
+			return atomic.LoadInt64(&healthy[i].inFlight) < atomic.LoadInt64(&healthy[j].inFlight)
+		})
+	}
+
+	sort.SliceStable(unhealthy, func(i, j int) bool {
+		return unhealthy[i].retryTime().Before(unhealthy[j].retryTime())
+	})
+	return append(healthy, unhealthy...)
+}
+
+func (c *FailoverConn) markUnhealthy(node *failoverNode, err error) {
+	node.mu.Lock()
+	wasHealthy := !time.Now().Before(node.retryAt)
+	node.retryAt = time.Now().Add(c.cooldown)
+	node.mu.Unlock()
+
+	if wasHealthy {
+		c.log.Warn().
+			Err(err).
+			Str("target", node.target).
+			Dur("cooldown", c.cooldown).
+			Msg("access node is unhealthy")
+	}
+}
+
+// checkHealth pings the nodes that are not in their cool-down,
+// so that unhealthy nodes are found before calls are sent to them.
+func (c *FailoverConn) checkHealth(ctx context.Context) {
+	defer close(c.done)
+	if c.healthCheckInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, node := range c.nodes {
+			if time.Now().Before(node.retryTime()) {
+				continue
+			}
+			err := c.ping(ctx, node)
+			if err != nil && ctx.Err() == nil {
+				c.markUnhealthy(node, err)
+			}
+		}
+	}
+}
+
+func (c *FailoverConn) ping(ctx context.Context, node *failoverNode) error {
+	ctx, cancel := context.WithTimeout(ctx, c.healthCheckInterval)
+	defer cancel()
+	return node.conn.Invoke(ctx, pingMethod, &protoAccess.PingRequest{}, &protoAccess.PingResponse{})
+}
+
+// nodeMetrics are the latency and the calls in flight per access node.
+// A nil nodeMetrics reports nothing.
+type nodeMetrics struct {
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+func newNodeMetrics(namespace string) *nodeMetrics {
+	return &nodeMetrics{
+		duration: registerCollector(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "access_node_request_duration_seconds",
+			Help:      "The duration of the requests to an access node, without waiting for the rate limit.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"target", "method"})),
+		inFlight: registerCollector(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "access_node_requests_in_flight",
+			Help:      "The number of requests to an access node that did not return yet, including the requests waiting for the rate limit.",
+		}, []string{"target"})),
+	}
+}
+
+func (m *nodeMetrics) interceptor(target string) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string, req,
+		reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		m.duration.WithLabelValues(target, method).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+func (m *nodeMetrics) reportInFlight(target string, inFlight int64) {
+	if m == nil {
+		return
+	}
+	m.inFlight.WithLabelValues(target).Set(float64(inFlight))
+}
+
+// registerCollector registers the collector to the default registry,
+// or returns the collector that is already registered, e.g. by another connection.
+func registerCollector[T prometheus.Collector](collector T) T {
+	err := prometheus.DefaultRegisterer.Register(collector)
+	if err == nil {
+		return collector
+	}
+	var registered prometheus.AlreadyRegisteredError
+	if errors.As(err, &registered) {
+		if existing, ok := registered.ExistingCollector.(T); ok {
+			return existing
+		}
+	}
+	panic(err)
+}
+
+// isNodeFailure is true if the error means the node, not the call, has a problem.
+func isNodeFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// chainUnaryInterceptors calls the interceptors in order, and the invoker last.
+func chainUnaryInterceptors(
+	interceptors []grpc.UnaryClientInterceptor,
+	invoker grpc.UnaryInvoker,
+) grpc.UnaryInvoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(
+			ctx context.Context,
+			method string,
+			req interface{},
+			reply interface{},
+			cc *grpc.ClientConn,
+			opts ...grpc.CallOption,
+		) error {
+			return interceptor(ctx, method, req, reply, cc, next, opts...)
+		}
+	}
+	return invoker
+}
+
+// cleanTargets trims the targets and drops the empty ones.
+func cleanTargets(targets []string) []string {
+	cleaned := make([]string, 0, len(targets))
+	for _, target := range targets {
+		target = strings.TrimSpace(target)
+		if target != "" {
+			cleaned = append(cleaned, target)
+		}
+	}
+	return cleaned
+}
@@ -0,0 +1,140 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	protoAccess "github.com/onflow/flow/protobuf/go/flow/access"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type pingServer struct {
+	protoAccess.UnimplementedAccessAPIServer
+	pings int
+	// headers are the headers of the last ping.
+	headers metadata.MD
+}
+
+func (s *pingServer) Ping(ctx context.Context, _ *protoAccess.PingRequest) (*protoAccess.PingResponse, error) {
+	s.pings++
+	s.headers, _ = metadata.FromIncomingContext(ctx)
+	return &protoAccess.PingResponse{}, nil
+}
+
+func startPingServer(t *testing.T) (*pingServer, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	ping := &pingServer{}
+	protoAccess.RegisterAccessAPIServer(server, ping)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+	return ping, listener.Addr().String()
+}
+
+func TestFailoverConn(t *testing.T) {
+	// nothing is listening on the first node
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	down := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	ping, up := startPingServer(t)
+
+	conn, err := NewFailoverConnection(
+		[]string{down, up},
+		WithFailover(time.Minute, 0),
+	)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, conn.Close())
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := protoAccess.NewAccessAPIClient(conn)
+	_, err = client.Ping(ctx, &protoAccess.PingRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 1, ping.pings)
+
+	// the node that is down is tried last during its cool-down
+	candidates := conn.candidates()
+	require.Equal(t, down, candidates[1].target)
+
+	_, err = client.Ping(ctx, &protoAccess.PingRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 2, ping.pings)
+}
+
+func TestFailoverConn_RoundRobin(t *testing.T) {
+	first, firstTarget := startPingServer(t)
+	second, secondTarget := startPingServer(t)
+
+	conn, err := NewFailoverConnection(
+		[]string{firstTarget, secondTarget},
+		WithFailover(time.Minute, 0),
+		WithLoadBalancing(LoadBalancingRoundRobin),
+	)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, conn.Close())
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := protoAccess.NewAccessAPIClient(conn)
+	for i := 0; i < 4; i++ {
+		_, err = client.Ping(ctx, &protoAccess.PingRequest{})
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, first.pings)
+	require.Equal(t, 2, second.pings)
+}
+
+func TestFailoverConn_Targets(t *testing.T) {
+	_, err := NewFailoverConnection([]string{"", " "})
+	require.Error(t, err)
+	_, err = NewClient(" , ")
+	require.Error(t, err)
+
+	_, up := startPingServer(t)
+	conn, err := NewFailoverConnection([]string{" " + up + " ", ""})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, conn.Close())
+	}()
+	require.Len(t, conn.nodes, 1)
+	require.Equal(t, up, conn.nodes[0].target)
+
+	require.Equal(t, []string{"a", "b"}, cleanTargets(strings.Split("a,, b,", ",")))
+
+	// calls without nodes fail instead of returning an empty reply
+	empty := &FailoverConn{}
+	err = empty.invoke(context.Background(), pingMethod, &protoAccess.PingRequest{}, &protoAccess.PingResponse{}, nil)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	_, err = empty.NewStream(context.Background(), &grpc.StreamDesc{}, pingMethod)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+}
@@ -0,0 +1,252 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+)
+
+// NewBlockHeaderCache caches the block headers of the client for ttl,
+// so components that ask for the same headers at the same time only send one request.
+// At most size headers by height are cached. Errors are not cached.
+func NewBlockHeaderCache(client Client, ttl time.Duration, size int) Client {
+	return NewPrefetchingBlockHeaderCache(client, ttl, size, 0)
+}
+
+// NewPrefetchingBlockHeaderCache is a NewBlockHeaderCache, that fetches the headers of the prefetch heights
+// after a requested height in the background, so the headers of a range of blocks are ready when they are needed.
+// Only the heights up to the latest sealed header the cache has seen are prefetched.
+// The prefetched headers expire after ttl as well, so the ttl should be long enough for them to be used.
+func NewPrefetchingBlockHeaderCache(client Client, ttl time.Duration, size int, prefetch int) Client {
+	return &blockHeaderCache{
+		Client:   client,
+		latest:   newTTLCache[bool, *flow.BlockHeader](ttl, 2),
+		byHeight: newTTLCache[uint64, *flow.BlockHeader](ttl, size),
+		prefetch: uint64(prefetch),
+	}
+}
+
+type blockHeaderCache struct {
+	Client
+
+	latest   *ttlCache[bool, *flow.BlockHeader]
+	byHeight *ttlCache[uint64, *flow.BlockHeader]
+
+	prefetch uint64
+	// latestSealed is the height of the latest sealed header that was fetched, the last height that is prefetched.
+	latestSealed atomic.Uint64
+}
+
+func (c *blockHeaderCache) GetLatestBlockHeader(ctx context.Context, isSealed bool) (*flow.BlockHeader, error) {
+	return c.latest.get(ctx, isSealed, func() (*flow.BlockHeader, error) {
+		header, err := c.Client.GetLatestBlockHeader(ctx, isSealed)
+		if err == nil {
+			c.byHeight.set(header.Height, header)
+			if isSealed {
+				c.latestSealed.Store(header.Height)
+			}
+		}
+		return header, err
+	})
+}
+
+func (c *blockHeaderCache) GetBlockHeaderByHeight(ctx context.Context, height uint64) (*flow.BlockHeader, error) {
+	header, err := c.byHeight.get(ctx, height, func() (*flow.BlockHeader, error) {
+		return c.Client.GetBlockHeaderByHeight(ctx, height)
+	})
+	if err == nil && c.prefetch > 0 {
+		c.prefetchAfter(height)
+	}
+	return header, err
+}
+
+// prefetchAfter fetches the headers after height, that are not cached yet, one after the other in the background.
+func (c *blockHeaderCache) prefetchAfter(height uint64) {
+	last := height + c.prefetch
+	if latestSealed := c.latestSealed.Load(); last > latestSealed {
+		last = latestSealed
+	}
+	var heights []uint64
+	for h := height + 1; h <= last; h++ {
+		if !c.byHeight.contains(h) {
+			heights = append(heights, h)
+		}
+	}
+	if len(heights) == 0 {
+		return
+	}
+	go func() {
+		ctx := context.Background()
+		for _, h := range heights {
+			ok := c.byHeight.prefetch(h, func() (*flow.BlockHeader, error) {
+				return c.Client.GetBlockHeaderByHeight(ctx, h)
+			})
+			if !ok {
+				return
+			}
+		}
+	}()
+}
+
+// withBlockHeaderCache adds the block header cache of the config to the client, if it is enabled.
+func (c Config) withBlockHeaderCache(client ClosableClient) ClosableClient {
+	if c.BlockHeaderCacheTTL <= 0 {
+		return client
+	}
+	return &closableClient{
+		Client: NewPrefetchingBlockHeaderCache(client, c.BlockHeaderCacheTTL, c.BlockHeaderCacheSize, c.BlockHeaderPrefetch),
+		Closer: client,
+	}
+}
+
+// ttlCache caches values for ttl. Concurrent gets of the same key wait for the same fetch.
+type ttlCache[K comparable, V any] struct {
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[K]*ttlCacheEntry[V]
+}
+
+type ttlCacheEntry[V any] struct {
+	// done is closed once the value is fetched.
+	done    chan struct{}
+	value   V
+	err     error
+	expires time.Time
+}
+
+func newTTLCache[K comparable, V any](ttl time.Duration, size int) *ttlCache[K, V] {
+	return &ttlCache[K, V]{
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[K]*ttlCacheEntry[V]),
+	}
+}
+
+func (c *ttlCache[K, V]) get(ctx context.Context, key K, fetch func() (V, error)) (V, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok || entry.expired(time.Now()) {
+		entry = &ttlCacheEntry[V]{done: make(chan struct{})}
+		if !c.add(key, entry) {
+			c.mu.Unlock()
+			return fetch()
+		}
+		c.mu.Unlock()
+
+		c.fill(key, entry, fetch)
+		return entry.value, entry.err
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	case <-entry.done:
+		return entry.value, entry.err
+	}
+}
+
+// prefetch fetches the value of key into the cache, unless it is cached or being fetched already.
+// It returns false if the value could not be fetched, or the cache is full.
+func (c *ttlCache[K, V]) prefetch(key K, fetch func() (V, error)) bool {
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok && !entry.expired(time.Now()) {
+		c.mu.Unlock()
+		return true
+	}
+	entry := &ttlCacheEntry[V]{done: make(chan struct{})}
+	if !c.add(key, entry) {
+		c.mu.Unlock()
+		return false
+	}
+	c.mu.Unlock()
+
+	c.fill(key, entry, fetch)
+	return entry.err == nil
+}
+
+// fill fetches the value of the entry that was added for key.
+func (c *ttlCache[K, V]) fill(key K, entry *ttlCacheEntry[V], fetch func() (V, error)) {
+	entry.value, entry.err = fetch()
+	entry.expires = time.Now().Add(c.ttl)
+	if entry.err != nil {
+		c.remove(key, entry)
+	}
+	close(entry.done)
+}
+
+// contains is true if the value of key is cached or being fetched.
+func (c *ttlCache[K, V]) contains(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	return ok && !entry.expired(time.Now())
+}
+
+func (c *ttlCache[K, V]) set(key K, value V) {
+	entry := &ttlCacheEntry[V]{
+		done:    make(chan struct{}),
+		value:   value,
+		expires: time.Now().Add(c.ttl),
+	}
+	close(entry.done)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(key, entry)
+}
+
+// add adds the entry, if the cache is not full of entries that have not expired.
+func (c *ttlCache[K, V]) add(key K, entry *ttlCacheEntry[V]) bool {
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+		now := time.Now()
+		for k, e := range c.entries {
+			if e.expired(now) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.size {
+			return false
+		}
+	}
+	c.entries[key] = entry
+	return true
+}
+
+func (c *ttlCache[K, V]) remove(key K, entry *ttlCacheEntry[V]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries[key] == entry {
+		delete(c.entries, key)
+	}
+}
+
+// expired is true once the fetched value is older than the ttl. Entries that are being fetched have not expired.
+func (e *ttlCacheEntry[V]) expired(now time.Time) bool {
+	select {
+	case <-e.done:
+		return !now.Before(e.expires)
+	default:
+		return false
+	}
+}
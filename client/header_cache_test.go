@@ -0,0 +1,108 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+)
+
+type headerCountingClient struct {
+	Client
+	latest   int32
+	byHeight int32
+}
+
+func (c *headerCountingClient) GetLatestBlockHeader(_ context.Context, _ bool) (*flow.BlockHeader, error) {
+	atomic.AddInt32(&c.latest, 1)
+	time.Sleep(10 * time.Millisecond)
+	return &flow.BlockHeader{Height: 100}, nil
+}
+
+func (c *headerCountingClient) GetBlockHeaderByHeight(_ context.Context, height uint64) (*flow.BlockHeader, error) {
+	atomic.AddInt32(&c.byHeight, 1)
+	return &flow.BlockHeader{Height: height}, nil
+}
+
+func TestBlockHeaderCache(t *testing.T) {
+	inner := &headerCountingClient{}
+	c := NewBlockHeaderCache(inner, 100*time.Millisecond, 2)
+	ctx := context.Background()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			header, err := c.GetLatestBlockHeader(ctx, true)
+			require.NoError(t, err)
+			require.Equal(t, uint64(100), header.Height)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), atomic.LoadInt32(&inner.latest))
+
+	// the latest header is cached by height as well
+	_, err := c.GetBlockHeaderByHeight(ctx, 100)
+	require.NoError(t, err)
+	require.Equal(t, int32(0), atomic.LoadInt32(&inner.byHeight))
+
+	_, err = c.GetBlockHeaderByHeight(ctx, 101)
+	require.NoError(t, err)
+	// the cache is full, so this header is not cached
+	_, err = c.GetBlockHeaderByHeight(ctx, 102)
+	require.NoError(t, err)
+	_, err = c.GetBlockHeaderByHeight(ctx, 102)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), atomic.LoadInt32(&inner.byHeight))
+
+	time.Sleep(150 * time.Millisecond)
+	_, err = c.GetLatestBlockHeader(ctx, true)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(&inner.latest))
+}
+
+func TestBlockHeaderCache_Prefetch(t *testing.T) {
+	inner := &headerCountingClient{}
+	c := NewPrefetchingBlockHeaderCache(inner, time.Minute, 100, 5)
+	ctx := context.Background()
+	byHeight := func() int32 { return atomic.LoadInt32(&inner.byHeight) }
+
+	// the latest sealed height is 100
+	_, err := c.GetLatestBlockHeader(ctx, true)
+	require.NoError(t, err)
+
+	_, err = c.GetBlockHeaderByHeight(ctx, 90)
+	require.NoError(t, err)
+	// 91 to 95 are prefetched
+	require.Eventually(t, func() bool { return byHeight() == 6 }, time.Second, time.Millisecond)
+
+	_, err = c.GetBlockHeaderByHeight(ctx, 93)
+	require.NoError(t, err)
+	// 96 to 98 are prefetched
+	require.Eventually(t, func() bool { return byHeight() == 9 }, time.Second, time.Millisecond)
+
+	// 100 is cached, and nothing after the latest sealed height is prefetched
+	_, err = c.GetBlockHeaderByHeight(ctx, 99)
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, int32(10), byHeight())
+}
@@ -0,0 +1,206 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptors
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AdaptiveRateLimitConfig configures an AIMD (additive increase, multiplicative decrease) rate limiter.
+// When a request fails with ResourceExhausted the rate is multiplied by DecreaseFactor,
+// and after every IncreaseInterval without ResourceExhausted errors, Increase is added to it,
+// until the rate is back at the configured rate limit.
+type AdaptiveRateLimitConfig struct {
+	// MinRate is the lowest rate in requests per second.
+	MinRate float64
+	// DecreaseFactor is the factor the rate is multiplied with on ResourceExhausted errors.
+	DecreaseFactor float64
+	// DecreaseInterval is the minimum time between decreases,
+	// so that the requests that were already in flight do not decrease the rate again.
+	DecreaseInterval time.Duration
+	// Increase is the number of requests per second that is added every IncreaseInterval.
+	Increase         float64
+	IncreaseInterval time.Duration
+
+	// OnRateChange is called with the new rate of a method on the access node at target, when it changes.
+	// See scanner.StatusReporter.ReportRateLimit.
+	OnRateChange func(target string, method string, rate float64)
+}
+
+func DefaultAdaptiveRateLimitConfig() AdaptiveRateLimitConfig {
+	return AdaptiveRateLimitConfig{
+		MinRate:          0.1,
+		DecreaseFactor:   0.5,
+		DecreaseInterval: time.Second,
+		Increase:         0.5,
+		IncreaseInterval: 10 * time.Second,
+	}
+}
+
+// AdaptiveRateLimitUnaryClientInterceptor lowers the rate of a method when the access node returns ResourceExhausted,
+// and ramps it back up to the rate limit of the method when it does not.
+// It does not limit methods that are at their rate limit, that is left to the rate limit interceptor.
+func AdaptiveRateLimitUnaryClientInterceptor(
+	defaultRateLimit RateLimit,
+	methodRateLimits map[string]RateLimit,
+	config AdaptiveRateLimitConfig,
+	logger zerolog.Logger,
+) grpc.UnaryClientInterceptor {
+	logger = logger.With().Str("component", "adaptive_rate_limiter").Logger()
+
+	mu := sync.Mutex{}
+	limiters := make(map[string]*adaptiveLimiter)
+	limiterFor := func(method string) *adaptiveLimiter {
+		mu.Lock()
+		defer mu.Unlock()
+		l, ok := limiters[method]
+		if !ok {
+			limit, ok := methodRateLimits[method]
+			if !ok {
+				limit = defaultRateLimit
+			}
+			l = newAdaptiveLimiter(float64(limit.Rate), config)
+			limiters[method] = l
+		}
+		return l
+	}
+
+	return func(
+		ctx context.Context,
+		method string, req,
+		reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		l := limiterFor(method)
+		err := l.wait(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = invoker(ctx, method, req, reply, cc, opts...)
+
+		var rate float64
+		var changed bool
+		if status.Code(err) == codes.ResourceExhausted {
+			rate, changed = l.decrease(time.Now())
+		} else if err == nil {
+			rate, changed = l.increase(time.Now())
+		}
+		if changed {
+			logger.Info().
+				Str("method", method).
+				Float64("rate", rate).
+				Msg("adapted rate limit")
+			if config.OnRateChange != nil {
+				target := ""
+				if cc != nil {
+					target = cc.Target()
+				}
+				config.OnRateChange(target, method, rate)
+			}
+		}
+
+		return err
+	}
+}
+
+type adaptiveLimiter struct {
+	AdaptiveRateLimitConfig
+
+	mu      sync.Mutex
+	rate    float64
+	maxRate float64
+	// next is when the next request can be sent.
+	next time.Time
+	// changed is when the rate was last changed.
+	changed time.Time
+}
+
+func newAdaptiveLimiter(maxRate float64, config AdaptiveRateLimitConfig) *adaptiveLimiter {
+	return &adaptiveLimiter{
+		AdaptiveRateLimitConfig: config,
+		rate:                    maxRate,
+		maxRate:                 maxRate,
+	}
+}
+
+// wait waits until the next request can be sent at the current rate.
+func (l *adaptiveLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	if l.rate >= l.maxRate {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(time.Second) / l.rate))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (l *adaptiveLimiter) decrease(now time.Time) (float64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.changed) < l.DecreaseInterval {
+		return l.rate, false
+	}
+	rate := l.rate * l.DecreaseFactor
+	if rate < l.MinRate {
+		rate = l.MinRate
+	}
+	if rate == l.rate {
+		return l.rate, false
+	}
+	l.rate = rate
+	l.changed = now
+	return l.rate, true
+}
+
+func (l *adaptiveLimiter) increase(now time.Time) (float64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate >= l.maxRate || now.Sub(l.changed) < l.IncreaseInterval {
+		return l.rate, false
+	}
+	l.rate += l.Increase
+	if l.rate > l.maxRate {
+		l.rate = l.maxRate
+	}
+	l.changed = now
+	return l.rate, true
+}
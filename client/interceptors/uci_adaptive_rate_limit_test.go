@@ -0,0 +1,47 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveLimiter(t *testing.T) {
+	l := newAdaptiveLimiter(4, DefaultAdaptiveRateLimitConfig())
+	now := time.Now()
+
+	rate, changed := l.decrease(now)
+	require.True(t, changed)
+	require.Equal(t, 2.0, rate)
+
+	// requests that were already in flight do not decrease the rate again
+	_, changed = l.decrease(now.Add(time.Millisecond))
+	require.False(t, changed)
+
+	_, changed = l.increase(now.Add(time.Second))
+	require.False(t, changed)
+	rate, changed = l.increase(now.Add(10 * time.Second))
+	require.True(t, changed)
+	require.Equal(t, 2.5, rate)
+
+	// the rate does not go above the rate limit
+	for i := 2; i < 10; i++ {
+		rate, _ = l.increase(now.Add(time.Duration(i) * 10 * time.Second))
+	}
+	require.Equal(t, 4.0, rate)
+}
@@ -0,0 +1,194 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptors
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrCircuitOpen is returned for requests that are not sent, because the circuit breaker is open.
+// It has the Unavailable code, so that the requests fail over to another access node.
+var ErrCircuitOpen = status.Error(codes.Unavailable, "circuit breaker is open")
+
+// CircuitBreakerConfig configures a circuit breaker.
+// After FailureThreshold consecutive failures the circuit opens, and requests fail fast with ErrCircuitOpen.
+// After OpenDuration the circuit is half-open, and a single request is sent to probe the access node.
+// If it succeeds the circuit closes, otherwise it opens again.
+type CircuitBreakerConfig struct {
+	FailureThreshold int
+	OpenDuration     time.Duration
+	// FailureCodes are the codes that count as failures of the access node.
+	FailureCodes []codes.Code
+}
+
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureThreshold: 5,
+		OpenDuration:     30 * time.Second,
+		FailureCodes: []codes.Code{
+			codes.Unavailable,
+			codes.DeadlineExceeded,
+			codes.Internal,
+		},
+	}
+}
+
+// CircuitBreakerUnaryClientInterceptor stops sending requests to an access node that keeps failing.
+// It should come after the retry interceptor in the chain, so that retries fail fast,
+// and before the rate limit interceptor, so that failing fast does not wait for the rate limit.
+func CircuitBreakerUnaryClientInterceptor(
+	config CircuitBreakerConfig,
+	logger zerolog.Logger,
+) grpc.UnaryClientInterceptor {
+	breaker := newCircuitBreaker(config)
+	logger = logger.With().Str("component", "circuit_breaker").Logger()
+
+	return func(
+		ctx context.Context,
+		method string, req,
+		reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if !breaker.allow(time.Now()) {
+			return ErrCircuitOpen
+		}
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		state, changed := breaker.done(time.Now(), breaker.isFailure(err))
+		if changed {
+			target := ""
+			if cc != nil {
+				target = cc.Target()
+			}
+			logger.Info().
+				Err(err).
+				Str("target", target).
+				Str("state", state.String()).
+				Msg("circuit breaker state changed")
+		}
+		return err
+	}
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitClosed:
+		return "closed"
+	case circuitOpen:
+		return "open"
+	default:
+		return "half-open"
+	}
+}
+
+type circuitBreaker struct {
+	CircuitBreakerConfig
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	// probing is true while the request of the half-open circuit is in flight.
+	probing bool
+}
+
+func newCircuitBreaker(config CircuitBreakerConfig) *circuitBreaker {
+	return &circuitBreaker{
+		CircuitBreakerConfig: config,
+	}
+}
+
+func (b *circuitBreaker) isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	code := status.Code(err)
+	for _, c := range b.FailureCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// allow is true if a request can be sent.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if now.Sub(b.openedAt) < b.OpenDuration {
+			return false
+		}
+		b.state = circuitHalfOpen
+		b.probing = true
+		return true
+	case circuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// done records the outcome of a request, and returns the new state if it changed.
+func (b *circuitBreaker) done(now time.Time, failed bool) (circuitState, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	previous := b.state
+	switch {
+	case b.state == circuitHalfOpen:
+		b.probing = false
+		if failed {
+			b.state = circuitOpen
+			b.openedAt = now
+		} else {
+			b.state = circuitClosed
+			b.failures = 0
+		}
+	case !failed:
+		b.failures = 0
+	case b.state == circuitClosed:
+		b.failures++
+		if b.failures >= b.FailureThreshold {
+			b.state = circuitOpen
+			b.openedAt = now
+		}
+	}
+	return b.state, b.state != previous
+}
@@ -0,0 +1,59 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptors
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	config := DefaultCircuitBreakerConfig()
+	config.FailureThreshold = 2
+	b := newCircuitBreaker(config)
+	now := time.Now()
+	unavailable := status.Error(codes.Unavailable, "down")
+
+	require.True(t, b.isFailure(unavailable))
+	require.False(t, b.isFailure(fmt.Errorf("invalid script")))
+
+	require.True(t, b.allow(now))
+	_, changed := b.done(now, true)
+	require.False(t, changed)
+	state, changed := b.done(now, true)
+	require.True(t, changed)
+	require.Equal(t, circuitOpen, state)
+
+	// requests fail fast while the circuit is open
+	require.False(t, b.allow(now.Add(time.Second)))
+
+	// a single probe is sent when the circuit is half-open
+	later := now.Add(config.OpenDuration)
+	require.True(t, b.allow(later))
+	require.False(t, b.allow(later))
+	state, _ = b.done(later, true)
+	require.Equal(t, circuitOpen, state)
+
+	later = later.Add(config.OpenDuration)
+	require.True(t, b.allow(later))
+	state, _ = b.done(later, false)
+	require.Equal(t, circuitClosed, state)
+	require.True(t, b.allow(later))
+}
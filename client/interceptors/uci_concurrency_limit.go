@@ -0,0 +1,80 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptors
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// ConcurrencyLimiter bounds the number of requests that are in flight at the same time.
+// The requests over the limit wait until a request finishes, or their context is done.
+type ConcurrencyLimiter struct {
+	slots chan struct{}
+}
+
+// NewConcurrencyLimiter allows limit requests in flight at the same time.
+func NewConcurrencyLimiter(limit int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		slots: make(chan struct{}, limit),
+	}
+}
+
+// Acquire waits for a free slot. It returns the error of the context if it is done before.
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case l.slots <- struct{}{}:
+		return nil
+	}
+}
+
+// Release frees a slot taken by Acquire.
+func (l *ConcurrencyLimiter) Release() {
+	<-l.slots
+}
+
+// InFlight is the number of requests that are in flight.
+func (l *ConcurrencyLimiter) InFlight() int {
+	return len(l.slots)
+}
+
+// Limit is the maximum number of requests in flight.
+func (l *ConcurrencyLimiter) Limit() int {
+	return cap(l.slots)
+}
+
+// UnaryClientInterceptor holds a slot of the limiter for every request.
+// Interceptors created from the same limiter share its slots.
+func (l *ConcurrencyLimiter) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string, req,
+		reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if err := l.Acquire(ctx); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		defer l.Release()
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptors
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1)
+	interceptor := limiter.UnaryClientInterceptor()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	blocking := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		close(started)
+		<-release
+		return nil
+	}
+	done := make(chan error)
+	go func() {
+		done <- interceptor(context.Background(), "method", nil, nil, nil, blocking)
+	}()
+	<-started
+	require.Equal(t, 1, limiter.InFlight())
+
+	// the second request waits for the first one, until its context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	invoked := false
+	invoker := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		invoked = true
+		return nil
+	}
+	err := interceptor(ctx, "method", nil, nil, nil, invoker)
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	require.False(t, invoked)
+
+	close(release)
+	require.NoError(t, <-done)
+	require.Equal(t, 0, limiter.InFlight())
+
+	require.NoError(t, interceptor(context.Background(), "method", nil, nil, nil, invoker))
+	require.True(t, invoked)
+}
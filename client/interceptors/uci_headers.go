@@ -0,0 +1,67 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptors
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// HeadersUnaryClientInterceptor adds the headers to the metadata of every request,
+// e.g. the API key of a managed access node.
+func HeadersUnaryClientInterceptor(
+	headers map[string]string,
+) grpc.UnaryClientInterceptor {
+	pairs := headerPairs(headers)
+	return func(
+		ctx context.Context,
+		method string, req,
+		reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		ctx = metadata.AppendToOutgoingContext(ctx, pairs...)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// HeadersStreamClientInterceptor adds the headers to the metadata of every stream.
+func HeadersStreamClientInterceptor(
+	headers map[string]string,
+) grpc.StreamClientInterceptor {
+	pairs := headerPairs(headers)
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		ctx = metadata.AppendToOutgoingContext(ctx, pairs...)
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+func headerPairs(headers map[string]string) []string {
+	pairs := make([]string, 0, 2*len(headers))
+	for key, value := range headers {
+		pairs = append(pairs, key, value)
+	}
+	return pairs
+}
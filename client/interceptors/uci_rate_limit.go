@@ -16,22 +16,170 @@ package interceptors
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
 	"go.uber.org/ratelimit"
 	"google.golang.org/grpc"
+
+	"github.com/onflow/flow-batch-scan/utils"
 )
 
+// RateLimit is a limit of Rate requests per second.
+// Burst is the number of requests that can be sent at once, after fewer requests than the limit were sent for a while.
+// With a Burst of 0 the requests are evenly spaced.
+type RateLimit struct {
+	Rate  int
+	Burst int
+}
+
+// RateLimitWindow overrides the rate limits during a time of day window.
+type RateLimitWindow struct {
+	utils.TimeOfDayWindow
+
+	DefaultRateLimit   int
+	SpecificRateLimits map[string]int
+}
+
 func RateLimitUnaryClientInterceptor(
 	defaultRateLimit int,
 	methodRateLimits map[string]int,
 	logger zerolog.Logger,
 ) grpc.UnaryClientInterceptor {
-	limiter := newLimiter(
+	return ScheduledRateLimitUnaryClientInterceptor(
+		defaultRateLimit,
+		methodRateLimits,
+		nil,
+		logger,
+	)
+}
+
+// ScheduledRateLimitUnaryClientInterceptor uses the rate limits of the first window in the schedule
+// that contains the current time. If no window contains the current time the default rate limits are used.
+func ScheduledRateLimitUnaryClientInterceptor(
+	defaultRateLimit int,
+	methodRateLimits map[string]int,
+	schedule []RateLimitWindow,
+	logger zerolog.Logger,
+) grpc.UnaryClientInterceptor {
+	return BurstRateLimitUnaryClientInterceptor(
+		RateLimit{Rate: defaultRateLimit},
+		rateLimits(methodRateLimits),
+		schedule,
+		logger,
+	)
+}
+
+// BurstRateLimitUnaryClientInterceptor is the ScheduledRateLimitUnaryClientInterceptor with bursts.
+// The rate limits of the schedule do not allow bursts.
+func BurstRateLimitUnaryClientInterceptor(
+	defaultRateLimit RateLimit,
+	methodRateLimits map[string]RateLimit,
+	schedule []RateLimitWindow,
+	logger zerolog.Logger,
+) grpc.UnaryClientInterceptor {
+	return newScheduledLimiter(
+		defaultRateLimit,
+		methodRateLimits,
+		schedule,
+		logger,
+	).unaryClientInterceptor()
+}
+
+// RateLimitReloader changes the rate limits of the rate limit interceptors created with it, while they are in use.
+// The AdaptiveRateLimitUnaryClientInterceptor keeps ramping up to the rate limits it was created with.
+type RateLimitReloader struct {
+	mu       sync.Mutex
+	limiters []*scheduledLimiter
+}
+
+func NewRateLimitReloader() *RateLimitReloader {
+	return &RateLimitReloader{}
+}
+
+// UnaryClientInterceptor is a BurstRateLimitUnaryClientInterceptor, whose rate limits are changed by Reload.
+func (r *RateLimitReloader) UnaryClientInterceptor(
+	defaultRateLimit RateLimit,
+	methodRateLimits map[string]RateLimit,
+	schedule []RateLimitWindow,
+	logger zerolog.Logger,
+) grpc.UnaryClientInterceptor {
+	limiter := newScheduledLimiter(
 		defaultRateLimit,
 		methodRateLimits,
+		schedule,
 		logger)
 
+	r.mu.Lock()
+	r.limiters = append(r.limiters, limiter)
+	r.mu.Unlock()
+
+	return limiter.unaryClientInterceptor()
+}
+
+// Reload sets the default rate limit, and the rate limits of the given methods.
+// The rate limits of the other methods and the schedule are kept.
+func (r *RateLimitReloader) Reload(
+	defaultRateLimit RateLimit,
+	methodRateLimits map[string]RateLimit,
+) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, limiter := range r.limiters {
+		limiter.reload(defaultRateLimit, methodRateLimits)
+	}
+}
+
+type scheduledLimiter struct {
+	mu      sync.RWMutex
+	limiter *limiter
+	// methodRates are the method rate limits of the limiter, to merge them with the reloaded rate limits.
+	methodRates map[string]RateLimit
+
+	schedule        []RateLimitWindow
+	scheduleLimiter []*limiter
+	logger          zerolog.Logger
+}
+
+func newScheduledLimiter(
+	defaultRate RateLimit,
+	methodLimiters map[string]RateLimit,
+	schedule []RateLimitWindow,
+	logger zerolog.Logger,
+) *scheduledLimiter {
+	l := &scheduledLimiter{
+		limiter:         newLimiter(defaultRate, methodLimiters, logger),
+		methodRates:     methodLimiters,
+		schedule:        schedule,
+		scheduleLimiter: make([]*limiter, len(schedule)),
+		logger:          logger,
+	}
+	for i, window := range schedule {
+		l.scheduleLimiter[i] = newLimiter(
+			RateLimit{Rate: window.DefaultRateLimit},
+			rateLimits(window.SpecificRateLimits),
+			logger,
+		)
+	}
+	return l
+}
+
+func (l *scheduledLimiter) Limit(method string) {
+	now := time.Now()
+	for i, window := range l.schedule {
+		if window.Contains(now) {
+			l.scheduleLimiter[i].Limit(method)
+			return
+		}
+	}
+	l.mu.RLock()
+	limiter := l.limiter
+	l.mu.RUnlock()
+	limiter.Limit(method)
+}
+
+func (l *scheduledLimiter) unaryClientInterceptor() grpc.UnaryClientInterceptor {
 	return func(
 		ctx context.Context,
 		method string, req,
@@ -40,12 +188,31 @@ func RateLimitUnaryClientInterceptor(
 		invoker grpc.UnaryInvoker,
 		opts ...grpc.CallOption,
 	) error {
-		limiter.Limit(method)
+		l.Limit(method)
 
 		return invoker(ctx, method, req, reply, cc, opts...)
 	}
 }
 
+// reload replaces the limiter outside the schedule, with the default rate limit and the merged method rate limits.
+func (l *scheduledLimiter) reload(defaultRate RateLimit, methodRates map[string]RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	merged := make(map[string]RateLimit, len(l.methodRates)+len(methodRates))
+	for method, rate := range l.methodRates {
+		merged[method] = rate
+	}
+	for method, rate := range methodRates {
+		merged[method] = rate
+	}
+	l.methodRates = merged
+	l.limiter = newLimiter(defaultRate, merged, l.logger)
+	l.limiter.logger.Info().
+		Int("default_rate_limit", defaultRate.Rate).
+		Msg("Reloaded rate limits")
+}
+
 type limiter struct {
 	ratelimit.Limiter
 	methodLimiters map[string]ratelimit.Limiter
@@ -63,17 +230,30 @@ func (l *limiter) Limit(method string) {
 }
 
 func newLimiter(
-	defaultRate int,
-	methodLimiters map[string]int,
+	defaultRate RateLimit,
+	methodLimiters map[string]RateLimit,
 	logger zerolog.Logger,
 ) *limiter {
 	l := &limiter{
-		Limiter: ratelimit.New(defaultRate, ratelimit.WithoutSlack),
+		Limiter: newRateLimiter(defaultRate),
 		logger:  logger.With().Str("component", "rate_limiter").Logger(),
 	}
 	l.methodLimiters = make(map[string]ratelimit.Limiter)
 	for method, rate := range methodLimiters {
-		l.methodLimiters[method] = ratelimit.New(rate, ratelimit.WithoutSlack)
+		l.methodLimiters[method] = newRateLimiter(rate)
 	}
 	return l
 }
+
+func newRateLimiter(limit RateLimit) ratelimit.Limiter {
+	return ratelimit.New(limit.Rate, ratelimit.WithSlack(limit.Burst))
+}
+
+// rateLimits are the rate limits without bursts.
+func rateLimits(rates map[string]int) map[string]RateLimit {
+	limits := make(map[string]RateLimit, len(rates))
+	for method, rate := range rates {
+		limits[method] = RateLimit{Rate: rate}
+	}
+	return limits
+}
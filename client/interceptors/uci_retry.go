@@ -17,6 +17,9 @@ package interceptors
 import (
 	"context"
 	"fmt"
+	"math"
+	"math/rand"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"google.golang.org/grpc"
@@ -24,35 +27,93 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-func RetryUnaryClientInterceptor(
-	retries int,
-) grpc.UnaryClientInterceptor {
-	if retries <= 0 {
-		retries = -1
-	}
+// RetryPolicy decides if and when a failed call is retried.
+type RetryPolicy interface {
+	// MaxAttempts is the maximum number of attempts of a call. Zero or less means no limit.
+	MaxAttempts() int
+	// Backoff is the time to wait before the given retry. The first retry is attempt 1.
+	Backoff(attempt int) time.Duration
+	// Retryable returns true if the error of a call can be retried.
+	Retryable(err error) bool
+}
 
-	canRetry := func(ctx context.Context, err error) bool {
-		if ctx.Err() != nil {
-			return false
-		}
+// ExponentialBackoffRetryPolicy retries calls that failed with one of the RetryableCodes.
+// The backoff doubles (times Multiplier) with every retry, up to MaxBackoff,
+// and is randomized by up to Jitter (a fraction of the backoff).
+type ExponentialBackoffRetryPolicy struct {
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	Jitter         float64
+	RetryableCodes []codes.Code
+}
 
-		code := status.Code(err)
+var _ RetryPolicy = ExponentialBackoffRetryPolicy{}
 
-		if code == codes.ResourceExhausted {
-			return true
-		}
+// DefaultRetryPolicy retries ResourceExhausted, DeadlineExceeded and Internal errors
+// with exponential backoff and jitter.
+func DefaultRetryPolicy(attempts int) ExponentialBackoffRetryPolicy {
+	return ExponentialBackoffRetryPolicy{
+		Attempts:       attempts,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryableCodes: []codes.Code{
+			codes.ResourceExhausted,
+			codes.DeadlineExceeded,
+			codes.Internal,
+		},
+	}
+}
 
-		if code == codes.DeadlineExceeded {
-			return true
-		}
+func (p ExponentialBackoffRetryPolicy) MaxAttempts() int {
+	return p.Attempts
+}
 
-		if code == codes.Internal {
+func (p ExponentialBackoffRetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt <= 0 || p.InitialBackoff <= 0 {
+		return 0
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(backoff)
+}
+
+func (p ExponentialBackoffRetryPolicy) Retryable(err error) bool {
+	code := status.Code(err)
+	for _, c := range p.RetryableCodes {
+		if c == code {
 			return true
 		}
-
-		return false
 	}
+	return false
+}
 
+// RetryUnaryClientInterceptor retries all methods with the DefaultRetryPolicy.
+func RetryUnaryClientInterceptor(
+	retries int,
+) grpc.UnaryClientInterceptor {
+	return RetryPolicyUnaryClientInterceptor(DefaultRetryPolicy(retries), nil)
+}
+
+// RetryPolicyUnaryClientInterceptor retries failed calls according to the retry policy of the method,
+// or the default policy if the method has no specific policy.
+func RetryPolicyUnaryClientInterceptor(
+	defaultPolicy RetryPolicy,
+	methodPolicies map[string]RetryPolicy,
+) grpc.UnaryClientInterceptor {
 	return func(
 		ctx context.Context,
 		method string, req,
@@ -61,16 +122,36 @@ func RetryUnaryClientInterceptor(
 		invoker grpc.UnaryInvoker,
 		opts ...grpc.CallOption,
 	) error {
+		policy := defaultPolicy
+		if p, ok := methodPolicies[method]; ok {
+			policy = p
+		}
+
+		maxAttempts := policy.MaxAttempts()
+		if maxAttempts <= 0 {
+			maxAttempts = -1
+		}
+
 		var err *multierror.Error
 
-		for r := 0; r != retries; r++ {
+		for attempt := 0; attempt != maxAttempts; attempt++ {
+			if attempt > 0 {
+				timer := time.NewTimer(policy.Backoff(attempt))
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return multierror.Append(err, ctx.Err())
+				case <-timer.C:
+				}
+			}
+
 			ierr := invoker(ctx, method, req, reply, cc, opts...)
-			if ierr != nil && canRetry(ctx, ierr) {
+			if ierr != nil && ctx.Err() == nil && policy.Retryable(ierr) {
 				err = multierror.Append(err, ierr)
 				continue
 			}
 			return ierr
 		}
-		return fmt.Errorf("reached maximum number of retries (%d): %w", retries, err)
+		return fmt.Errorf("reached maximum number of retries (%d): %w", maxAttempts, err)
 	}
 }
@@ -0,0 +1,125 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptors
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestExponentialBackoffRetryPolicy_Backoff(t *testing.T) {
+	policy := ExponentialBackoffRetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	}
+
+	t.Run("backoff grows up to the max backoff", func(t *testing.T) {
+		require.Equal(t, time.Duration(0), policy.Backoff(0))
+		require.Equal(t, 100*time.Millisecond, policy.Backoff(1))
+		require.Equal(t, 200*time.Millisecond, policy.Backoff(2))
+		require.Equal(t, 400*time.Millisecond, policy.Backoff(3))
+		require.Equal(t, 800*time.Millisecond, policy.Backoff(4))
+		require.Equal(t, time.Second, policy.Backoff(5))
+		require.Equal(t, time.Second, policy.Backoff(50))
+	})
+
+	t.Run("a multiplier below 1 keeps the backoff constant", func(t *testing.T) {
+		constant := policy
+		constant.Multiplier = 0
+		require.Equal(t, 100*time.Millisecond, constant.Backoff(1))
+		require.Equal(t, 100*time.Millisecond, constant.Backoff(5))
+	})
+
+	t.Run("jitter stays within its fraction of the backoff", func(t *testing.T) {
+		jittered := policy
+		jittered.Jitter = 0.2
+		for i := 0; i < 100; i++ {
+			backoff := jittered.Backoff(2)
+			require.GreaterOrEqual(t, backoff, 160*time.Millisecond)
+			require.LessOrEqual(t, backoff, 240*time.Millisecond)
+		}
+	})
+}
+
+func TestRetryPolicyUnaryClientInterceptor(t *testing.T) {
+	noBackoff := func(attempts int, retryable ...codes.Code) ExponentialBackoffRetryPolicy {
+		return ExponentialBackoffRetryPolicy{
+			Attempts:       attempts,
+			RetryableCodes: retryable,
+		}
+	}
+	failing := func(code codes.Code, calls *int) grpc.UnaryInvoker {
+		return func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+			*calls++
+			return status.Error(code, "failed")
+		}
+	}
+
+	interceptor := RetryPolicyUnaryClientInterceptor(
+		noBackoff(3, codes.Unavailable),
+		map[string]RetryPolicy{
+			"/flow.access.AccessAPI/ExecuteScriptAtBlockHeight": noBackoff(5, codes.ResourceExhausted),
+		},
+	)
+
+	t.Run("the default policy is used for other methods", func(t *testing.T) {
+		calls := 0
+		err := interceptor(context.Background(), "/flow.access.AccessAPI/GetLatestBlockHeader",
+			nil, nil, nil, failing(codes.Unavailable, &calls))
+		require.ErrorContains(t, err, "reached maximum number of retries (3)")
+		require.Equal(t, 3, calls)
+
+		calls = 0
+		err = interceptor(context.Background(), "/flow.access.AccessAPI/GetLatestBlockHeader",
+			nil, nil, nil, failing(codes.ResourceExhausted, &calls))
+		require.Equal(t, codes.ResourceExhausted, status.Code(err))
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("the policy of the method is used", func(t *testing.T) {
+		calls := 0
+		err := interceptor(context.Background(), "/flow.access.AccessAPI/ExecuteScriptAtBlockHeight",
+			nil, nil, nil, failing(codes.ResourceExhausted, &calls))
+		require.ErrorContains(t, err, "reached maximum number of retries (5)")
+		require.Equal(t, 5, calls)
+
+		calls = 0
+		err = interceptor(context.Background(), "/flow.access.AccessAPI/ExecuteScriptAtBlockHeight",
+			nil, nil, nil, failing(codes.Unavailable, &calls))
+		require.Equal(t, codes.Unavailable, status.Code(err))
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("retries stop when the context is done", func(t *testing.T) {
+		slow := RetryPolicyUnaryClientInterceptor(ExponentialBackoffRetryPolicy{
+			InitialBackoff: time.Hour,
+			RetryableCodes: []codes.Code{codes.Unavailable},
+		}, nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		calls := 0
+		err := slow(ctx, "method", nil, nil, nil, failing(codes.Unavailable, &calls))
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, 1, calls)
+	})
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptors
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestTracingUnaryClientInterceptor(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	interceptor := TracingUnaryClientInterceptor(provider.Tracer("test"), propagation.TraceContext{})
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "scan")
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", "secret")
+
+	var md metadata.MD
+	err := interceptor(ctx, "/flow.access.AccessAPI/Ping", nil, nil, nil,
+		func(ctx context.Context, _ string, _, _ interface{}, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+			md, _ = metadata.FromOutgoingContext(ctx)
+			return status.Error(codes.Unavailable, "down")
+		},
+	)
+	require.Error(t, err)
+	parent.End()
+
+	require.Equal(t, []string{"secret"}, md.Get("x-api-key"))
+	require.Len(t, md.Get("traceparent"), 1)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	require.Equal(t, "/flow.access.AccessAPI/Ping", spans[0].Name())
+	require.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	require.Contains(t, md.Get("traceparent")[0], spans[0].SpanContext().SpanID().String())
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"strings"
+
+	"github.com/onflow/flow-go-sdk"
+
+	"github.com/onflow/flow-batch-scan/client/interceptors"
+)
+
+// Preset is the configuration of a Flow network: its access nodes, chain ID and rate limits.
+// Use Preset.NewClient to connect to the network, and scanner.Config.ForNetwork for the chain ID of the scan.
+type Preset struct {
+	// Name is the name of the network, e.g. mainnet.
+	Name    string
+	ChainID flow.ChainID
+	// AccessNodes are the public gRPC access nodes of the current spork.
+	AccessNodes []string
+	// RESTEndpoint is the public Access REST API of the network.
+	RESTEndpoint string
+
+	// DefaultRateLimit and MethodRateLimits are the requests per second sent to each access node.
+	// They are conservative, so a scan stays well below the rate limits of the public access nodes.
+	DefaultRateLimit interceptors.RateLimit
+	MethodRateLimits map[string]interceptors.RateLimit
+}
+
+// MainnetPreset is the preset of Flow mainnet.
+func MainnetPreset() Preset {
+	return Preset{
+		Name:             "mainnet",
+		ChainID:          flow.Mainnet,
+		AccessNodes:      []string{"access.mainnet.nodes.onflow.org:9000"},
+		RESTEndpoint:     "https://rest-mainnet.onflow.org",
+		DefaultRateLimit: interceptors.RateLimit{Rate: 10},
+		MethodRateLimits: map[string]interceptors.RateLimit{
+			MethodExecuteScriptAtBlockHeight: {Rate: 2},
+		},
+	}
+}
+
+// TestnetPreset is the preset of Flow testnet.
+func TestnetPreset() Preset {
+	return Preset{
+		Name:             "testnet",
+		ChainID:          flow.Testnet,
+		AccessNodes:      []string{"access.devnet.nodes.onflow.org:9000"},
+		RESTEndpoint:     "https://rest-testnet.onflow.org",
+		DefaultRateLimit: interceptors.RateLimit{Rate: 10},
+		MethodRateLimits: map[string]interceptors.RateLimit{
+			MethodExecuteScriptAtBlockHeight: {Rate: 2},
+		},
+	}
+}
+
+// EmulatorPreset is the preset of a local Flow emulator, which has no rate limits of its own.
+func EmulatorPreset() Preset {
+	return Preset{
+		Name:             "emulator",
+		ChainID:          flow.Emulator,
+		AccessNodes:      []string{"127.0.0.1:3569"},
+		RESTEndpoint:     "http://127.0.0.1:8888",
+		DefaultRateLimit: interceptors.RateLimit{Rate: 100},
+		MethodRateLimits: map[string]interceptors.RateLimit{
+			MethodExecuteScriptAtBlockHeight: {Rate: 50},
+		},
+	}
+}
+
+// PresetForNetwork returns the preset of the network by its name: mainnet, testnet or emulator.
+func PresetForNetwork(name string) (Preset, bool) {
+	switch name {
+	case "mainnet":
+		return MainnetPreset(), true
+	case "testnet":
+		return TestnetPreset(), true
+	case "emulator":
+		return EmulatorPreset(), true
+	default:
+		return Preset{}, false
+	}
+}
+
+// Options are the client options of the preset: its rate limits.
+func (p Preset) Options() []Option {
+	return []Option{WithRateLimits(p.DefaultRateLimit, p.MethodRateLimits)}
+}
+
+// NewClient connects to the access nodes of the preset, failing over between them if there are multiple.
+// The options are applied after the options of the preset, so they can override its rate limits.
+func (p Preset) NewClient(opts ...Option) (ClosableClient, error) {
+	options := append(p.Options(), opts...)
+	return NewClient(strings.Join(p.AccessNodes, ","), options...)
+}
@@ -0,0 +1,280 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	flowgrpc "github.com/onflow/flow-go-sdk/access/grpc"
+	flowhttp "github.com/onflow/flow-go-sdk/access/http"
+	"github.com/onflow/flow/protobuf/go/flow/entities"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errRESTUnsupported is returned for the methods the REST API does not support.
+var errRESTUnsupported = status.Error(codes.Unimplemented, "not supported by the Access REST API")
+
+// NewRESTClient uses the Flow Access REST API at host (e.g. https://rest-mainnet.onflow.org/v1),
+// for environments where gRPC is not available.
+// The interceptors of the options (retries, rate limits, timeouts, UnaryInterceptors) apply to the REST requests,
+// with the gRPC method names, e.g. MethodExecuteScriptAtBlockHeight. The REST errors are converted to gRPC status errors.
+// The requests use the proxy of the environment (HTTPS_PROXY), the TransportCredentials and Headers do not apply.
+// The REST API does not support execution data, so GetExecutionDataByBlockID and SubscribeEvents are Unimplemented.
+// GetNetworkParameters is Unimplemented as well.
+func NewRESTClient(
+	host string,
+	opts ...Option,
+) (ClosableClient, error) {
+	conf := DefaultConfig()
+	for _, opt := range opts {
+		opt(&conf)
+	}
+
+	sdkClient, err := flowhttp.NewClient(host)
+	if err != nil {
+		return nil, err
+	}
+	c := &restClient{
+		client:  sdkClient,
+		invoker: chainUnaryInterceptors(conf.interceptors(host), invokeREST),
+	}
+	return &closableClient{
+		Client: c,
+		Closer: sdkClient,
+	}, nil
+}
+
+var _ Client = (*restClient)(nil)
+
+type restClient struct {
+	client  *flowhttp.Client
+	invoker grpc.UnaryInvoker
+}
+
+// restCall runs the call through the interceptors.
+func restCall[T any](
+	ctx context.Context,
+	c *restClient,
+	method string,
+	call func() (T, error),
+) (T, error) {
+	var reply interface{}
+	err := c.invoker(ctx, method, func() (interface{}, error) { return call() }, &reply, nil)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return reply.(T), nil
+}
+
+// invokeREST is the invoker of the interceptors for REST calls.
+// req is the call, and reply a pointer to its result.
+// The REST client does not use the context, so the call is abandoned when the context is done.
+func invokeREST(
+	ctx context.Context,
+	_ string,
+	req interface{},
+	reply interface{},
+	_ *grpc.ClientConn,
+	_ ...grpc.CallOption,
+) error {
+	call := req.(func() (interface{}, error))
+
+	type response struct {
+		value interface{}
+		err   error
+	}
+	done := make(chan response, 1)
+	go func() {
+		value, err := call()
+		done <- response{value: value, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	case res := <-done:
+		if res.err != nil {
+			return restStatusError(res.err)
+		}
+		*(reply.(*interface{})) = res.value
+		return nil
+	}
+}
+
+// restStatusError converts the error of a REST call to a gRPC status error,
+// so that the retry policies and the failover work the same as with gRPC.
+func restStatusError(err error) error {
+	var httpErr flowhttp.HTTPError
+	if errors.As(err, &httpErr) {
+		return status.Error(httpStatusCode(httpErr.Code), err.Error())
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+
+func httpStatusCode(code int) codes.Code {
+	switch code {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusInternalServerError:
+		return codes.Internal
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Unknown
+	}
+}
+
+func (c *restClient) GetLatestBlockHeader(ctx context.Context, isSealed bool) (*flow.BlockHeader, error) {
+	return restCall(ctx, c, MethodGetLatestBlockHeader, func() (*flow.BlockHeader, error) {
+		return c.client.GetLatestBlockHeader(ctx, isSealed)
+	})
+}
+
+func (c *restClient) GetBlockHeaderByHeight(
+	ctx context.Context,
+	height uint64,
+) (*flow.BlockHeader, error) {
+	return restCall(ctx, c, MethodGetBlockHeaderByHeight, func() (*flow.BlockHeader, error) {
+		return c.client.GetBlockHeaderByHeight(ctx, height)
+	})
+}
+
+func (c *restClient) ExecuteScriptAtBlockHeight(
+	ctx context.Context,
+	height uint64,
+	script []byte,
+	arguments []cadence.Value,
+) (cadence.Value, error) {
+	return restCall(ctx, c, MethodExecuteScriptAtBlockHeight, func() (cadence.Value, error) {
+		return c.client.ExecuteScriptAtBlockHeight(ctx, height, script, arguments)
+	})
+}
+
+func (c *restClient) GetAccountAtBlockHeight(
+	ctx context.Context,
+	address flow.Address,
+	height uint64,
+) (*flow.Account, error) {
+	return restCall(ctx, c, MethodGetAccountAtBlockHeight, func() (*flow.Account, error) {
+		return c.client.GetAccountAtBlockHeight(ctx, address, height)
+	})
+}
+
+func (c *restClient) GetBlockByHeight(
+	ctx context.Context,
+	height uint64,
+) (*flow.Block, error) {
+	return restCall(ctx, c, MethodGetBlockByHeight, func() (*flow.Block, error) {
+		return c.client.GetBlockByHeight(ctx, height)
+	})
+}
+
+func (c *restClient) GetTransaction(
+	ctx context.Context,
+	txID flow.Identifier,
+) (*flow.Transaction, error) {
+	return restCall(ctx, c, MethodGetTransaction, func() (*flow.Transaction, error) {
+		return c.client.GetTransaction(ctx, txID)
+	})
+}
+
+func (c *restClient) GetEventsForHeightRange(
+	ctx context.Context,
+	query flowgrpc.EventRangeQuery,
+) ([]flow.BlockEvents, error) {
+	return restCall(ctx, c, MethodGetEventsForHeightRange, func() ([]flow.BlockEvents, error) {
+		return c.client.GetEventsForHeightRange(ctx, query.Type, query.StartHeight, query.EndHeight)
+	})
+}
+
+func (c *restClient) GetCollection(
+	ctx context.Context,
+	colID flow.Identifier,
+) (*flow.Collection, error) {
+	return restCall(ctx, c, MethodGetCollectionByID, func() (*flow.Collection, error) {
+		return c.client.GetCollection(ctx, colID)
+	})
+}
+
+// GetTransactionResultsByBlockID gets the results of the transactions in the collections of the block one by one,
+// as the REST API has no endpoint for the results of a block.
+// The system transaction is not part of a collection, so its result is missing.
+func (c *restClient) GetTransactionResultsByBlockID(
+	ctx context.Context,
+	blockID flow.Identifier,
+) ([]*flow.TransactionResult, error) {
+	block, err := restCall(ctx, c, MethodGetBlockByID, func() (*flow.Block, error) {
+		return c.client.GetBlockByID(ctx, blockID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*flow.TransactionResult
+	for _, guarantee := range block.CollectionGuarantees {
+		collection, err := c.GetCollection(ctx, guarantee.CollectionID)
+		if err != nil {
+			return nil, err
+		}
+		for _, txID := range collection.TransactionIDs {
+			txID := txID
+			result, err := restCall(ctx, c, MethodGetTransactionResult, func() (*flow.TransactionResult, error) {
+				return c.client.GetTransactionResult(ctx, txID)
+			})
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+func (c *restClient) GetExecutionDataByBlockID(
+	context.Context,
+	flow.Identifier,
+) (*entities.BlockExecutionData, error) {
+	return nil, errRESTUnsupported
+}
+
+func (c *restClient) GetNetworkParameters(context.Context) (*NetworkParameters, error) {
+	return nil, errRESTUnsupported
+}
+
+func (c *restClient) SubscribeEvents(
+	context.Context,
+	uint64,
+	[]string,
+) (<-chan flow.BlockEvents, <-chan error, error) {
+	return nil, nil, errRESTUnsupported
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/onflow/flow-batch-scan/client/interceptors"
+)
+
+func TestRESTClient_Errors(t *testing.T) {
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"code":429,"message":"too many requests"}`))
+	}))
+	defer server.Close()
+
+	c, err := NewClient(
+		server.URL+"/v1",
+		WithRetryPolicy(interceptors.DefaultRetryPolicy(2)),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = c.GetLatestBlockHeader(ctx, true)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	// ResourceExhausted is retried
+	require.Equal(t, int64(2), atomic.LoadInt64(&requests))
+
+	_, err = c.GetExecutionDataByBlockID(ctx, flow.EmptyID)
+	require.Equal(t, codes.Unimplemented, status.Code(err))
+}
@@ -0,0 +1,304 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	flowgrpc "github.com/onflow/flow-go-sdk/access/grpc"
+	"github.com/onflow/flow/protobuf/go/flow/entities"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultSporksURL is the list of sporks maintained by the Flow team.
+const DefaultSporksURL = "https://raw.githubusercontent.com/onflow/flow/master/sporks.json"
+
+// Spork is the access node that serves the blocks from RootHeight up to the RootHeight of the next spork.
+type Spork struct {
+	Name       string
+	RootHeight uint64
+	AccessNode string
+}
+
+// FetchSporks gets the sporks of a network ("mainnet" or "testnet") from a spork list like DefaultSporksURL.
+func FetchSporks(ctx context.Context, url string, network string) ([]Spork, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get the sporks from %s: %s", url, res.Status)
+	}
+	return ParseSporks(res.Body, network)
+}
+
+// ReadSporks reads the sporks of a network from a file in the format of DefaultSporksURL,
+// e.g. a copy of the list with the access nodes of archived sporks.
+func ReadSporks(path string, network string) ([]Spork, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseSporks(file, network)
+}
+
+// ParseSporks reads the sporks of a network from a spork list in the format of DefaultSporksURL.
+func ParseSporks(r io.Reader, network string) ([]Spork, error) {
+	var list struct {
+		Networks map[string]map[string]struct {
+			RootHeight  json.RawMessage `json:"rootHeight"`
+			AccessNodes []string        `json:"accessNodes"`
+		} `json:"networks"`
+	}
+	err := json.NewDecoder(r).Decode(&list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the sporks: %w", err)
+	}
+	networkSporks, ok := list.Networks[network]
+	if !ok {
+		return nil, fmt.Errorf("no sporks for network %q", network)
+	}
+
+	sporks := make([]Spork, 0, len(networkSporks))
+	for name, spork := range networkSporks {
+		if len(spork.AccessNodes) == 0 {
+			continue
+		}
+		// the root height is a string in the spork list
+		rootHeight, err := strconv.ParseUint(strings.Trim(string(spork.RootHeight), `"`), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid root height of spork %s: %w", name, err)
+		}
+		sporks = append(sporks, Spork{
+			Name:       name,
+			RootHeight: rootHeight,
+			AccessNode: spork.AccessNodes[0],
+		})
+	}
+	sortSporks(sporks)
+	return sporks, nil
+}
+
+func sortSporks(sporks []Spork) {
+	sort.Slice(sporks, func(i, j int) bool {
+		return sporks[i].RootHeight < sporks[j].RootHeight
+	})
+}
+
+// NewSporkClient connects to the access nodes of all sporks.
+// Calls for a height go to the access node of the spork of that height,
+// event queries that span multiple sporks are split, and calls by ID try the sporks from the newest to the oldest,
+// until one does not return NotFound. Other calls go to the newest spork.
+func NewSporkClient(
+	sporks []Spork,
+	opts ...Option,
+) (ClosableClient, error) {
+	if len(sporks) == 0 {
+		return nil, fmt.Errorf("no sporks")
+	}
+	sporks = append([]Spork(nil), sporks...)
+	sortSporks(sporks)
+
+	c := &sporkClient{}
+	for _, spork := range sporks {
+		client, err := NewClient(spork.AccessNode, opts...)
+		if err != nil {
+			_ = c.Close()
+			return nil, fmt.Errorf("failed to connect to the access node of spork %s: %w", spork.Name, err)
+		}
+		c.sporks = append(c.sporks, sporkNode{
+			Spork:  spork,
+			client: client,
+		})
+	}
+	return c, nil
+}
+
+var _ ClosableClient = (*sporkClient)(nil)
+
+type sporkClient struct {
+	// sporks are ordered by their root height
+	sporks []sporkNode
+}
+
+type sporkNode struct {
+	Spork
+	client Client
+}
+
+// forHeight is the spork of the height.
+func (c *sporkClient) forHeight(height uint64) sporkNode {
+	return c.sporks[c.index(height)]
+}
+
+// index is the index of the spork of the height.
+// Heights below the first spork go to the first spork.
+func (c *sporkClient) index(height uint64) int {
+	i := sort.Search(len(c.sporks), func(i int) bool {
+		return c.sporks[i].RootHeight > height
+	})
+	if i == 0 {
+		return 0
+	}
+	return i - 1
+}
+
+func (c *sporkClient) latest() sporkNode {
+	return c.sporks[len(c.sporks)-1]
+}
+
+// byID calls the sporks from the newest to the oldest, until the call does not return NotFound.
+func byID[T any](c *sporkClient, call func(client Client) (T, error)) (T, error) {
+	var result T
+	var err error
+	for i := len(c.sporks) - 1; i >= 0; i-- {
+		result, err = call(c.sporks[i].client)
+		if status.Code(err) != codes.NotFound {
+			return result, err
+		}
+	}
+	return result, err
+}
+
+func (c *sporkClient) Close() error {
+	merr := &multierror.Error{}
+	for _, spork := range c.sporks {
+		if closer, ok := spork.client.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				merr = multierror.Append(merr, err)
+			}
+		}
+	}
+	return merr.ErrorOrNil()
+}
+
+func (c *sporkClient) GetLatestBlockHeader(ctx context.Context, isSealed bool) (*flow.BlockHeader, error) {
+	return c.latest().client.GetLatestBlockHeader(ctx, isSealed)
+}
+
+func (c *sporkClient) GetBlockHeaderByHeight(ctx context.Context, height uint64) (*flow.BlockHeader, error) {
+	return c.forHeight(height).client.GetBlockHeaderByHeight(ctx, height)
+}
+
+func (c *sporkClient) ExecuteScriptAtBlockHeight(
+	ctx context.Context,
+	height uint64,
+	script []byte,
+	arguments []cadence.Value,
+) (cadence.Value, error) {
+	return c.forHeight(height).client.ExecuteScriptAtBlockHeight(ctx, height, script, arguments)
+}
+
+func (c *sporkClient) GetAccountAtBlockHeight(
+	ctx context.Context,
+	address flow.Address,
+	height uint64,
+) (*flow.Account, error) {
+	return c.forHeight(height).client.GetAccountAtBlockHeight(ctx, address, height)
+}
+
+func (c *sporkClient) GetBlockByHeight(ctx context.Context, height uint64) (*flow.Block, error) {
+	return c.forHeight(height).client.GetBlockByHeight(ctx, height)
+}
+
+func (c *sporkClient) GetTransaction(ctx context.Context, txID flow.Identifier) (*flow.Transaction, error) {
+	return byID(c, func(client Client) (*flow.Transaction, error) {
+		return client.GetTransaction(ctx, txID)
+	})
+}
+
+// GetEventsForHeightRange splits the range at the spork root heights.
+func (c *sporkClient) GetEventsForHeightRange(
+	ctx context.Context,
+	query flowgrpc.EventRangeQuery,
+) ([]flow.BlockEvents, error) {
+	var events []flow.BlockEvents
+	start := query.StartHeight
+	for start <= query.EndHeight {
+		i := c.index(start)
+		end := query.EndHeight
+		if i+1 < len(c.sporks) && c.sporks[i+1].RootHeight <= end {
+			end = c.sporks[i+1].RootHeight - 1
+		}
+
+		sporkEvents, err := c.sporks[i].client.GetEventsForHeightRange(ctx, flowgrpc.EventRangeQuery{
+			Type:        query.Type,
+			StartHeight: start,
+			EndHeight:   end,
+		})
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, sporkEvents...)
+		start = end + 1
+	}
+	return events, nil
+}
+
+func (c *sporkClient) GetCollection(ctx context.Context, colID flow.Identifier) (*flow.Collection, error) {
+	return byID(c, func(client Client) (*flow.Collection, error) {
+		return client.GetCollection(ctx, colID)
+	})
+}
+
+func (c *sporkClient) GetTransactionResultsByBlockID(
+	ctx context.Context,
+	blockID flow.Identifier,
+) ([]*flow.TransactionResult, error) {
+	return byID(c, func(client Client) ([]*flow.TransactionResult, error) {
+		return client.GetTransactionResultsByBlockID(ctx, blockID)
+	})
+}
+
+func (c *sporkClient) GetExecutionDataByBlockID(
+	ctx context.Context,
+	blockID flow.Identifier,
+) (*entities.BlockExecutionData, error) {
+	return byID(c, func(client Client) (*entities.BlockExecutionData, error) {
+		return client.GetExecutionDataByBlockID(ctx, blockID)
+	})
+}
+
+// GetNetworkParameters asks the access node of the latest spork. All sporks of a network have the same chain ID.
+func (c *sporkClient) GetNetworkParameters(ctx context.Context) (*NetworkParameters, error) {
+	return c.latest().client.GetNetworkParameters(ctx)
+}
+
+// SubscribeEvents subscribes on the spork of startHeight. The subscription ends at the end of the spork.
+func (c *sporkClient) SubscribeEvents(
+	ctx context.Context,
+	startHeight uint64,
+	eventTypes []string,
+) (<-chan flow.BlockEvents, <-chan error, error) {
+	return c.forHeight(startHeight).client.SubscribeEvents(ctx, startHeight, eventTypes)
+}
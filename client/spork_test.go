@@ -0,0 +1,107 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	flowgrpc "github.com/onflow/flow-go-sdk/access/grpc"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// sporkTestClient serves the heights from root up, and records the queried event ranges.
+type sporkTestClient struct {
+	Client
+	root   uint64
+	ranges [][2]uint64
+}
+
+func (c *sporkTestClient) GetBlockHeaderByHeight(_ context.Context, height uint64) (*flow.BlockHeader, error) {
+	if height < c.root {
+		return nil, status.Error(codes.NotFound, "height below root block")
+	}
+	return &flow.BlockHeader{Height: height}, nil
+}
+
+func (c *sporkTestClient) GetEventsForHeightRange(
+	_ context.Context,
+	query flowgrpc.EventRangeQuery,
+) ([]flow.BlockEvents, error) {
+	c.ranges = append(c.ranges, [2]uint64{query.StartHeight, query.EndHeight})
+	var events []flow.BlockEvents
+	for h := query.StartHeight; h <= query.EndHeight; h++ {
+		events = append(events, flow.BlockEvents{Height: h})
+	}
+	return events, nil
+}
+
+func (c *sporkTestClient) GetTransaction(_ context.Context, _ flow.Identifier) (*flow.Transaction, error) {
+	if c.root != 0 {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	return &flow.Transaction{}, nil
+}
+
+func TestSporkClient(t *testing.T) {
+	first := &sporkTestClient{root: 0}
+	second := &sporkTestClient{root: 100}
+	third := &sporkTestClient{root: 200}
+	c := &sporkClient{sporks: []sporkNode{
+		{Spork: Spork{RootHeight: 0}, client: first},
+		{Spork: Spork{RootHeight: 100}, client: second},
+		{Spork: Spork{RootHeight: 200}, client: third},
+	}}
+	ctx := context.Background()
+
+	for _, height := range []uint64{0, 99, 100, 150, 200, 1000} {
+		_, err := c.GetBlockHeaderByHeight(ctx, height)
+		require.NoError(t, err, "height %d", height)
+	}
+
+	events, err := c.GetEventsForHeightRange(ctx, flowgrpc.EventRangeQuery{StartHeight: 90, EndHeight: 210})
+	require.NoError(t, err)
+	require.Len(t, events, 121)
+	require.Equal(t, [][2]uint64{{90, 99}}, first.ranges)
+	require.Equal(t, [][2]uint64{{100, 199}}, second.ranges)
+	require.Equal(t, [][2]uint64{{200, 210}}, third.ranges)
+
+	// only the first spork has the transaction
+	_, err = c.GetTransaction(ctx, flow.EmptyID)
+	require.NoError(t, err)
+}
+
+func TestParseSporks(t *testing.T) {
+	sporks, err := ParseSporks(strings.NewReader(`{
+		"networks": {
+			"mainnet": {
+				"mainnet2": {"rootHeight": "8742959", "accessNodes": ["access-001.mainnet2.nodes.onflow.org:9000"]},
+				"mainnet1": {"rootHeight": "7601063", "accessNodes": ["access-001.mainnet1.nodes.onflow.org:9000"]}
+			}
+		}
+	}`), "mainnet")
+	require.NoError(t, err)
+	require.Equal(t, []Spork{
+		{Name: "mainnet1", RootHeight: 7601063, AccessNode: "access-001.mainnet1.nodes.onflow.org:9000"},
+		{Name: "mainnet2", RootHeight: 8742959, AccessNode: "access-001.mainnet2.nodes.onflow.org:9000"},
+	}, sporks)
+
+	_, err = ParseSporks(strings.NewReader(`{"networks": {}}`), "mainnet")
+	require.Error(t, err)
+}
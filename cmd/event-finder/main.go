@@ -0,0 +1,390 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command event-finder fetches events from the Flow network on the command line:
+//
+//	event-finder events --network mainnet --type A.1654653399040a61.FlowToken.TokensWithdrawn \
+//		--start 85000000 --end 85500000 --format ndjson
+//
+// The tail command follows the chain, and resumes from its cursor file after a restart:
+//
+//	event-finder tail --network mainnet --type A.1654653399040a61.FlowToken.TokensDeposited \
+//		--start 85000000 --cursor cursor.json --output deposits.ndjson
+//
+// With --service-events, the tail command follows the epoch and protocol version service events
+// emitted in the system chunk, instead of --type:
+//
+//	event-finder tail --network mainnet --service-events --cursor cursor.json
+//
+// Run `event-finder <command> -h` for all the flags.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/eventfinder"
+	"github.com/onflow/flow-batch-scan/eventfinder/webhook"
+	"github.com/onflow/flow-batch-scan/serviceevents"
+)
+
+const usage = `Usage: event-finder <command> [flags]
+
+Commands:
+  events    fetch all the events of a type over a block height range
+  tail      follow the chain for new events of one or more types
+
+Run 'event-finder <command> -h' for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var err error
+	switch os.Args[1] {
+	case "events":
+		err = runEvents(ctx, os.Args[2:])
+	case "tail":
+		err = runTail(ctx, os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runEvents(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("events", flag.ExitOnError)
+	network := flags.String("network", "mainnet", "the network: mainnet, testnet or emulator")
+	accessNode := flags.String("access-node", "",
+		"the access node(s) to use instead of the default one of the network, comma separated to fail over between multiple nodes")
+	sporks := flags.Bool("sporks", false,
+		"route the queries of heights before the current spork to the access nodes of past sporks")
+	sporksFile := flags.String("sporks-file", "",
+		"like --sporks, but with the sporks of a file in the format of "+client.DefaultSporksURL)
+	eventType := flags.String("type", "", "the event type, e.g. A.1654653399040a61.FlowToken.TokensWithdrawn (required)")
+	start := flags.Uint64("start", 0, "the first block height (required)")
+	end := flags.Uint64("end", 0, "the last block height, defaults to the latest sealed block")
+	filterExpression := flags.String("filter", "",
+		`keep only the events whose fields match, e.g. 'amount > 1000 && to == 0x1654653399040a61'`)
+	format := flags.String("format", formatNDJSON, "the output format: ndjson, json, csv or flat-csv (a column per event field)")
+	output := flags.String("output", "", "the file to write to, defaults to stdout")
+	chunkSize := flags.Uint64("chunk-size", candidates.DefaultEventQueryChunkSize, "the number of blocks queried at once")
+	concurrency := flags.Int("concurrency", candidates.DefaultEventQueryConcurrency, "the number of concurrent queries")
+	rateLimit := flags.Int("rate-limit", 0, "the maximum number of queries per second, 0 for the default rate limits of the client")
+	dedup := flags.Int("dedup", 0,
+		"skip duplicate events, remembering this many events, e.g. when failing over between access nodes")
+	verbose := flags.Bool("v", false, "log debug output")
+	_ = flags.Parse(args)
+
+	if *eventType == "" {
+		return fmt.Errorf("--type is required")
+	}
+	if *start == 0 {
+		return fmt.Errorf("--start is required")
+	}
+
+	options := []eventfinder.Option{
+		eventfinder.WithChunkSize(*chunkSize),
+		eventfinder.WithConcurrency(*concurrency),
+		eventfinder.WithRateLimit(*rateLimit),
+	}
+	if *filterExpression != "" {
+		filter, err := eventfinder.ParseFilter(*filterExpression)
+		if err != nil {
+			return err
+		}
+		options = append(options, eventfinder.WithFilter(filter))
+	}
+	if *dedup > 0 {
+		options = append(options, eventfinder.WithDedup(*dedup))
+	}
+
+	logger := newLogger(*verbose)
+
+	sporkList, err := loadSporks(ctx, *network, *sporks, *sporksFile)
+	if err != nil {
+		return err
+	}
+	if sporkList != nil {
+		options = append(options, eventfinder.WithSporks(sporkList))
+	}
+
+	flowClient, err := newClient(*network, *accessNode, sporkList, logger)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := flowClient.Close(); err != nil {
+			logger.Warn().Err(err).Msg("failed to close client")
+		}
+	}()
+
+	if *end == 0 {
+		header, err := flowClient.GetLatestBlockHeader(ctx, true)
+		if err != nil {
+			return fmt.Errorf("failed to get the latest sealed block: %w", err)
+		}
+		*end = header.Height
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	buffered := bufio.NewWriter(out)
+	writer, err := newEventWriter(*format, buffered)
+	if err != nil {
+		return err
+	}
+
+	logger.Info().
+		Str("type", *eventType).
+		Uint64("start", *start).
+		Uint64("end", *end).
+		Msg("finding events")
+	started := time.Now()
+	count := 0
+	err = eventfinder.Stream(ctx, flowClient, *eventType, *start, *end,
+		func(event eventfinder.Event) error {
+			count++
+			return writer.Write(event)
+		},
+		options...,
+	)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if flushErr := buffered.Flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Info().
+		Int("events", count).
+		Dur("duration", time.Since(started)).
+		Msg("done")
+	return nil
+}
+
+func runTail(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("tail", flag.ExitOnError)
+	network := flags.String("network", "mainnet", "the network: mainnet, testnet or emulator")
+	accessNode := flags.String("access-node", "",
+		"the access node(s) to use instead of the default one of the network, comma separated to fail over between multiple nodes")
+	eventTypes := flags.String("type", "", "the event types, comma separated (required, unless --service-events is set)")
+	serviceEvents := flags.Bool("service-events", false,
+		"follow the service events (EpochSetup, EpochCommit, VersionBeacon, ProtocolStateVersionUpgrade) emitted in the system chunk")
+	start := flags.Uint64("start", 0, "the first block height, if there is no cursor yet, defaults to the latest sealed block")
+	cursorPath := flags.String("cursor", "", "the file the cursor is stored in, to resume after a restart")
+	filterExpression := flags.String("filter", "",
+		`keep only the events whose fields match, e.g. 'amount > 1000 && to == 0x1654653399040a61'`)
+	output := flags.String("output", "", "the ndjson file to append to, defaults to stdout")
+	chunkSize := flags.Uint64("chunk-size", candidates.DefaultEventQueryChunkSize, "the number of blocks queried at once")
+	concurrency := flags.Int("concurrency", candidates.DefaultEventQueryConcurrency, "the number of concurrent queries")
+	rateLimit := flags.Int("rate-limit", 0, "the maximum number of queries per second, 0 for the default rate limits of the client")
+	pollInterval := flags.Duration("poll-interval", eventfinder.DefaultPollInterval, "how often to check for new blocks")
+	stream := flags.Bool("stream", false,
+		"subscribe to the event stream of the access node instead of polling, falls back to polling if the node does not support it")
+	webhookURL := flags.String("webhook", "", "post the events to this URL instead of writing them to the output")
+	webhookSecret := flags.String("webhook-secret", "",
+		"sign the webhook requests with this secret, in the "+webhook.SignatureHeader+" header")
+	deadLetter := flags.String("dead-letter", "",
+		"append the events that could not be posted to the webhook to this file, instead of stopping")
+	verbose := flags.Bool("v", false, "log debug output")
+	_ = flags.Parse(args)
+
+	if (*eventTypes == "") == !*serviceEvents {
+		return fmt.Errorf("either --type or --service-events is required")
+	}
+
+	options := []eventfinder.Option{
+		eventfinder.WithChunkSize(*chunkSize),
+		eventfinder.WithConcurrency(*concurrency),
+		eventfinder.WithRateLimit(*rateLimit),
+		eventfinder.WithPollInterval(*pollInterval),
+	}
+	if *filterExpression != "" {
+		filter, err := eventfinder.ParseFilter(*filterExpression)
+		if err != nil {
+			return err
+		}
+		options = append(options, eventfinder.WithFilter(filter))
+	}
+	if *cursorPath != "" {
+		options = append(options, eventfinder.WithCursorStore(eventfinder.NewFileCursorStore(*cursorPath)))
+	}
+	if *stream {
+		options = append(options, eventfinder.WithStreaming())
+	}
+
+	logger := newLogger(*verbose)
+
+	flowClient, err := newClient(*network, *accessNode, nil, logger)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := flowClient.Close(); err != nil {
+			logger.Warn().Err(err).Msg("failed to close client")
+		}
+	}()
+
+	if *start == 0 {
+		header, err := flowClient.GetLatestBlockHeader(ctx, true)
+		if err != nil {
+			return fmt.Errorf("failed to get the latest sealed block: %w", err)
+		}
+		*start = header.Height
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.OpenFile(*output, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	// the events are not buffered, so every handled event is written before the cursor is saved
+	writer, err := newEventWriter(formatNDJSON, out)
+	if err != nil {
+		return err
+	}
+	handle, closeWriter := writer.Write, writer.Close
+	if *webhookURL != "" {
+		config := webhook.DefaultConfig(*webhookURL)
+		config.Secret = *webhookSecret
+		config.DeadLetterPath = *deadLetter
+		forwarder := webhook.NewForwarder(config, logger)
+		options = append(options, eventfinder.WithFlush(forwarder.Flush))
+		handle, closeWriter = forwarder.Handle, forwarder.Close
+	}
+
+	types := strings.Split(*eventTypes, ",")
+	if *serviceEvents {
+		preset, ok := client.PresetForNetwork(*network)
+		if !ok {
+			return fmt.Errorf("unknown network %q, expected mainnet, testnet or emulator", *network)
+		}
+		chain := preset.ChainID
+		registry := eventfinder.NewRegistry()
+		serviceevents.RegisterIn(registry, chain)
+		types = serviceevents.EventTypes(chain)
+		options = append(options,
+			eventfinder.WithRegistry(registry),
+			eventfinder.WithFilter(serviceevents.SystemChunkFilter(ctx, flowClient)),
+		)
+	}
+
+	logger.Info().
+		Strs("types", types).
+		Uint64("start", *start).
+		Msg("tailing events")
+	err = eventfinder.Tail(ctx, flowClient, types, *start, handle, options...)
+	if errors.Is(err, context.Canceled) {
+		err = nil
+	}
+	if closeErr := closeWriter(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func newLogger(verbose bool) zerolog.Logger {
+	level := zerolog.InfoLevel
+	if verbose {
+		level = zerolog.DebugLevel
+	}
+	return zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).
+		Level(level).
+		With().
+		Timestamp().
+		Logger()
+}
+
+// loadSporks returns the sporks of the network from the spork list or the file, or nil if neither is used.
+func loadSporks(ctx context.Context, network string, fetch bool, path string) ([]client.Spork, error) {
+	switch {
+	case path != "":
+		sporks, err := client.ReadSporks(path, network)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the sporks of %s from %s: %w", network, path, err)
+		}
+		return sporks, nil
+	case fetch:
+		sporks, err := client.FetchSporks(ctx, client.DefaultSporksURL, network)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the sporks of %s: %w", network, err)
+		}
+		return sporks, nil
+	default:
+		return nil, nil
+	}
+}
+
+func newClient(
+	network string,
+	accessNode string,
+	sporks []client.Spork,
+	logger zerolog.Logger,
+) (client.ClosableClient, error) {
+	options := []client.Option{client.WithLog(logger)}
+
+	if len(sporks) > 0 {
+		return client.NewSporkClient(sporks, options...)
+	}
+
+	if accessNode != "" {
+		return client.NewClient(accessNode, options...)
+	}
+	preset, ok := client.PresetForNetwork(network)
+	if !ok {
+		return nil, fmt.Errorf("unknown network %q, expected mainnet, testnet or emulator", network)
+	}
+	return preset.NewClient(options...)
+}
@@ -0,0 +1,176 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+
+	"github.com/onflow/flow-batch-scan/eventfinder"
+)
+
+// The output formats.
+const (
+	formatNDJSON = "ndjson"
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	// formatFlatCSV has a column per (nested) field of the events, see eventfinder.FlattenConfig.
+	formatFlatCSV = "flat-csv"
+)
+
+// eventWriter writes the events in one of the output formats.
+type eventWriter interface {
+	Write(event eventfinder.Event) error
+	// Close completes the output, but does not close the underlying writer.
+	Close() error
+}
+
+func newEventWriter(format string, w io.Writer) (eventWriter, error) {
+	switch format {
+	case formatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	case formatJSON:
+		return &jsonWriter{w: w}, nil
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		err := cw.Write([]string{
+			"block_height",
+			"block_id",
+			"block_timestamp",
+			"transaction_id",
+			"transaction_index",
+			"event_index",
+			"type",
+			"value",
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: cw}, nil
+	case formatFlatCSV:
+		return eventfinder.NewCSVExporter(w, eventfinder.DefaultFlattenConfig()), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected %s, %s, %s or %s",
+			format, formatNDJSON, formatJSON, formatCSV, formatFlatCSV)
+	}
+}
+
+// eventRecord is an event in the JSON formats. The value of the event is encoded as JSON-Cadence.
+type eventRecord struct {
+	BlockHeight      uint64          `json:"block_height"`
+	BlockID          string          `json:"block_id"`
+	BlockTimestamp   time.Time       `json:"block_timestamp"`
+	TransactionID    string          `json:"transaction_id"`
+	TransactionIndex int             `json:"transaction_index"`
+	EventIndex       int             `json:"event_index"`
+	Type             string          `json:"type"`
+	Value            json.RawMessage `json:"value"`
+}
+
+func newEventRecord(event eventfinder.Event) (eventRecord, error) {
+	value, err := jsoncdc.Encode(event.Value)
+	if err != nil {
+		return eventRecord{}, fmt.Errorf("failed to encode event at height %d: %w", event.BlockHeight, err)
+	}
+	return eventRecord{
+		BlockHeight:      event.BlockHeight,
+		BlockID:          event.BlockID.Hex(),
+		BlockTimestamp:   event.BlockTimestamp,
+		TransactionID:    event.TransactionID.Hex(),
+		TransactionIndex: event.TransactionIndex,
+		EventIndex:       event.EventIndex,
+		Type:             event.Type,
+		Value:            value,
+	}, nil
+}
+
+// ndjsonWriter writes one JSON object per line.
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(event eventfinder.Event) error {
+	record, err := newEventRecord(event)
+	if err != nil {
+		return err
+	}
+	return w.encoder.Encode(record)
+}
+
+func (w *ndjsonWriter) Close() error {
+	return nil
+}
+
+// jsonWriter writes a JSON array. The events are streamed, so the array is only complete once it is closed.
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (w *jsonWriter) Write(event eventfinder.Event) error {
+	record, err := newEventRecord(event)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	separator := ",\n"
+	if w.count == 0 {
+		separator = "[\n"
+	}
+	w.count++
+	_, err = io.WriteString(w.w, separator+string(b))
+	return err
+}
+
+func (w *jsonWriter) Close() error {
+	end := "\n]\n"
+	if w.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(w.w, end)
+	return err
+}
+
+// csvWriter writes one row per event. The value of the event is in the Cadence string format.
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (w *csvWriter) Write(event eventfinder.Event) error {
+	return w.w.Write([]string{
+		strconv.FormatUint(event.BlockHeight, 10),
+		event.BlockID.Hex(),
+		event.BlockTimestamp.Format(time.RFC3339Nano),
+		event.TransactionID.Hex(),
+		strconv.Itoa(event.TransactionIndex),
+		strconv.Itoa(event.EventIndex),
+		event.Type,
+		event.Value.String(),
+	})
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
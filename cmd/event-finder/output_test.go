@@ -0,0 +1,99 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/cadence/runtime/common"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/eventfinder"
+)
+
+func depositEvent(amount uint64) cadence.Event {
+	return cadence.NewEvent([]cadence.Value{cadence.UFix64(amount)}).
+		WithType(&cadence.EventType{
+			Location:            common.AddressLocation{Address: common.Address{0, 0, 0, 0, 0, 0, 0, 1}, Name: "Token"},
+			QualifiedIdentifier: "Token.Deposited",
+			Fields:              []cadence.Field{{Identifier: "amount", Type: cadence.UFix64Type{}}},
+		})
+}
+
+func testEvents() []eventfinder.Event {
+	return []eventfinder.Event{
+		{
+			BlockHeight:   10,
+			TransactionID: flow.HexToID("01"),
+			Type:          "A.0000000000000001.Token.Deposited",
+			Value:         depositEvent(100),
+		},
+		{
+			BlockHeight: 11,
+			EventIndex:  1,
+			Type:        "A.0000000000000001.Token.Deposited",
+			Value:       depositEvent(200),
+		},
+	}
+}
+
+func writeEvents(t *testing.T, format string, events []eventfinder.Event) string {
+	buf := &bytes.Buffer{}
+	w, err := newEventWriter(format, buf)
+	require.NoError(t, err)
+	for _, event := range events {
+		require.NoError(t, w.Write(event))
+	}
+	require.NoError(t, w.Close())
+	return buf.String()
+}
+
+func TestEventWriter_JSON(t *testing.T) {
+	var records []eventRecord
+	require.NoError(t, json.Unmarshal([]byte(writeEvents(t, formatJSON, testEvents())), &records))
+	require.Len(t, records, 2)
+	require.Equal(t, uint64(11), records[1].BlockHeight)
+	require.Equal(t, flow.HexToID("01").Hex(), records[0].TransactionID)
+
+	require.Equal(t, "[]\n", writeEvents(t, formatJSON, nil))
+}
+
+func TestEventWriter_NDJSON(t *testing.T) {
+	lines := bytes.Split(bytes.TrimSpace([]byte(writeEvents(t, formatNDJSON, testEvents()))), []byte("\n"))
+	require.Len(t, lines, 2)
+	record := eventRecord{}
+	require.NoError(t, json.Unmarshal(lines[0], &record))
+	require.Equal(t, uint64(10), record.BlockHeight)
+}
+
+func TestEventWriter_CSV(t *testing.T) {
+	lines := bytes.Split(bytes.TrimSpace([]byte(writeEvents(t, formatCSV, testEvents()))), []byte("\n"))
+	require.Len(t, lines, 3)
+	require.True(t, bytes.HasPrefix(lines[0], []byte("block_height,")))
+
+	_, err := newEventWriter("xml", &bytes.Buffer{})
+	require.Error(t, err)
+}
+
+func TestEventWriter_FlatCSV(t *testing.T) {
+	lines := bytes.Split(bytes.TrimSpace([]byte(writeEvents(t, formatFlatCSV, testEvents()))), []byte("\n"))
+	require.Len(t, lines, 3)
+	require.True(t, bytes.HasSuffix(lines[0], []byte(",type,amount")), string(lines[0]))
+	require.True(t, bytes.HasSuffix(lines[2], []byte(",0.00000200")), string(lines[2]))
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command scanner runs a scan configured by a settings file (see the config package) as a long-running daemon,
+// e.g. in a container:
+//
+//	scanner --config /etc/flow-batch-scan/scan.yaml
+//
+// The results are written to the output of the handler settings: JSON lines, or a Postgres table.
+// With the metrics settings, the Prometheus metrics of the scan are served on /metrics.
+// The health and the status of the scan are served on --status-addr (/healthz, /readyz and /status),
+// unless scanner.status_server_addr is set.
+//
+// The settings are reloaded on SIGHUP, and with --watch when the file changes.
+// On SIGTERM or SIGINT the scan stops, and the batches that are being handled are drained into the output,
+// for at most --shutdown-timeout. A second signal exits immediately.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/client/interceptors"
+	"github.com/onflow/flow-batch-scan/config"
+)
+
+// configEnv is the environment variable of the default of --config.
+const configEnv = "FLOW_BATCH_SCAN_CONFIG"
+
+func main() {
+	configPath := flag.String("config", os.Getenv(configEnv),
+		"the settings file, YAML or TOML (defaults to $"+configEnv+")")
+	watch := flag.Bool("watch", false, "also reload the settings when the file changes")
+	statusAddr := flag.String("status-addr", ":8080",
+		"the address of the status server, if scanner.status_server_addr is not set, empty to disable it")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second,
+		"how long to wait for the results in flight to be handled after SIGTERM")
+	flag.Parse()
+
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+
+	if *configPath == "" {
+		fmt.Fprintf(os.Stderr, "error: --config or $%s is required\n", configEnv)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// the next signal is not caught anymore, and exits immediately
+		stop()
+		logger.Info().
+			Dur("timeout", *shutdownTimeout).
+			Msg("Shutting down, draining the results in flight")
+		time.Sleep(*shutdownTimeout)
+		logger.Error().Msg("Shutdown timed out")
+		os.Exit(1)
+	}()
+
+	err := run(ctx, *configPath, *watch, *statusAddr, logger)
+	if err != nil {
+		logger.Error().Err(err).Msg("Scan failed")
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, configPath string, watch bool, statusAddr string, logger zerolog.Logger) error {
+	loaded, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	settings := loaded
+	if settings.Scanner.StatusServerAddr == "" {
+		settings.Scanner.StatusServerAddr = statusAddr
+	}
+
+	rateLimits := interceptors.NewRateLimitReloader()
+	flowClient, err := settings.NewClient(logger, client.WithRateLimitReloader(rateLimits))
+	if err != nil {
+		return err
+	}
+	defer closeAndLog(flowClient.Close, "client", logger)
+
+	scannerConfig, err := settings.ScannerConfig(logger)
+	if err != nil {
+		return err
+	}
+	handler, handlerCloser, err := settings.NewHandler(ctx, logger)
+	if err != nil {
+		return err
+	}
+	// the results that were drained on shutdown are flushed when the handler is closed
+	defer closeAndLog(handlerCloser.Close, "handler", logger)
+
+	scan, err := scanner.NewScanner(flowClient, scannerConfig.WithScriptResultHandler(handler))
+	if err != nil {
+		return err
+	}
+
+	reloader := config.NewReloader(configPath, loaded, scan, rateLimits, logger)
+	reloader.Watch = watch
+	go func() {
+		if err := reloader.Run(ctx); err != nil {
+			logger.Error().Err(err).Msg("Reloading the settings failed")
+		}
+	}()
+
+	concluded, err := scan.Scan(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	logger.Info().
+		Bool("complete", concluded.ScanIsComplete).
+		Uint64("latest_scanned_block_height", concluded.LatestScannedBlockHeight).
+		Int("failed_batches", len(concluded.Stats.FailedBatches)).
+		Msg("Scan concluded")
+	return nil
+}
+
+func closeAndLog(close func() error, name string, logger zerolog.Logger) {
+	if err := close(); err != nil {
+		logger.Error().Err(err).Str("closing", name).Msg("Failed to close")
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/onflow/flow-go-sdk"
+)
+
+// CompletenessAudit is the audit of a full scan, see WithCompletenessAudit.
+// It checks that every address the full scan enumerated produced exactly one handled result
+// (one per script, with multiple Scripts) at or after the reference block of the full scan.
+type CompletenessAudit struct {
+	// ReferenceBlockHeight is the height the audited full scan started at.
+	ReferenceBlockHeight uint64
+	// Addresses is the number of addresses the full scan enumerated.
+	Addresses uint64
+	// Missing are the enumerated addresses with fewer results than expected, e.g. skipped failed addresses
+	// or the addresses of the results a handler could not handle. They are sorted.
+	Missing []flow.Address
+	// Duplicated are the enumerated addresses with more results than expected. They are sorted.
+	Duplicated []flow.Address
+}
+
+// IsComplete is true if every enumerated address produced exactly the expected results.
+func (a CompletenessAudit) IsComplete() bool {
+	return len(a.Missing) == 0 && len(a.Duplicated) == 0
+}
+
+// merge adds the audit of another partition of the scan.
+func (a CompletenessAudit) merge(other CompletenessAudit) CompletenessAudit {
+	a.Addresses += other.Addresses
+	a.Missing = sortedAddresses(append(append([]flow.Address(nil), a.Missing...), other.Missing...))
+	a.Duplicated = sortedAddresses(append(append([]flow.Address(nil), a.Duplicated...), other.Duplicated...))
+	return a
+}
+
+// completenessAuditor counts the handled results of the addresses the full scan enumerates.
+// A nil auditor audits nothing.
+type completenessAuditor struct {
+	// resultsPerAddress is the number of results expected for each address.
+	resultsPerAddress uint8
+
+	mu                   sync.Mutex
+	started              bool
+	referenceBlockHeight uint64
+	// results is the number of handled results of each enumerated address.
+	// It stops counting at the maximum of uint8, which is a duplicate anyway.
+	results map[flow.Address]uint8
+}
+
+func newCompletenessAuditor(enabled bool, config ScriptRunnerConfig) *completenessAuditor {
+	if !enabled {
+		return nil
+	}
+	resultsPerAddress := uint8(1)
+	if len(config.Scripts) > 0 {
+		resultsPerAddress = uint8(len(config.Scripts))
+	}
+	return &completenessAuditor{
+		resultsPerAddress: resultsPerAddress,
+	}
+}
+
+// fullScanStarted starts the audit of a full scan, replacing the audit of the previous full scan.
+func (a *completenessAuditor) fullScanStarted(referenceBlockHeight uint64) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.started = true
+	a.referenceBlockHeight = referenceBlockHeight
+	a.results = make(map[flow.Address]uint8)
+}
+
+// enumerated records the addresses the full scan sends to the script runner.
+func (a *completenessAuditor) enumerated(addresses []flow.Address) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, address := range addresses {
+		if _, ok := a.results[address]; !ok {
+			a.results[address] = 0
+		}
+	}
+}
+
+// handled counts the results of a batch of the full scan that was handled successfully.
+func (a *completenessAuditor) handled(batch ProcessedAddressBatch) {
+	if a == nil || !batch.fromFullScan {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.started || batch.BlockHeight < a.referenceBlockHeight {
+		return
+	}
+	for _, address := range batch.Addresses {
+		count, ok := a.results[address]
+		if ok && count < ^uint8(0) {
+			a.results[address] = count + 1
+		}
+	}
+}
+
+// audit returns the audit of the last full scan, or nil if no full scan started.
+func (a *completenessAuditor) audit() *CompletenessAudit {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.started {
+		return nil
+	}
+	audit := &CompletenessAudit{
+		ReferenceBlockHeight: a.referenceBlockHeight,
+		Addresses:            uint64(len(a.results)),
+	}
+	for address, count := range a.results {
+		switch {
+		case count < a.resultsPerAddress:
+			audit.Missing = append(audit.Missing, address)
+		case count > a.resultsPerAddress:
+			audit.Duplicated = append(audit.Duplicated, address)
+		}
+	}
+	sortedAddresses(audit.Missing)
+	sortedAddresses(audit.Duplicated)
+	return audit
+}
+
+func sortedAddresses(addresses []flow.Address) []flow.Address {
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i].Hex() < addresses[j].Hex()
+	})
+	return addresses
+}
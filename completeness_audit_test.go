@@ -0,0 +1,102 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+)
+
+func TestCompletenessAuditor(t *testing.T) {
+	a1 := flow.HexToAddress("01")
+	a2 := flow.HexToAddress("02")
+	a3 := flow.HexToAddress("03")
+
+	var disabled *completenessAuditor
+	disabled.fullScanStarted(10)
+	require.Nil(t, disabled.audit())
+
+	auditor := newCompletenessAuditor(true, DefaultScriptRunnerConfig())
+	require.Nil(t, auditor.audit())
+
+	auditor.fullScanStarted(10)
+	auditor.enumerated([]flow.Address{a1, a2, a3})
+	batch := func(height uint64, fromFullScan bool, addresses ...flow.Address) ProcessedAddressBatch {
+		b := ProcessedAddressBatch{AddressBatch: NewAddressBatch(addresses, height, nil, nil)}
+		b.fromFullScan = fromFullScan
+		return b
+	}
+	auditor.handled(batch(10, true, a1, a2))
+	auditor.handled(batch(11, true, a2))
+	// the results below the reference block, and of the incremental scanner, are not counted
+	auditor.handled(batch(9, true, a3))
+	auditor.handled(batch(12, false, a3))
+
+	audit := auditor.audit()
+	require.Equal(t, &CompletenessAudit{
+		ReferenceBlockHeight: 10,
+		Addresses:            3,
+		Missing:              []flow.Address{a3},
+		Duplicated:           []flow.Address{a2},
+	}, audit)
+	require.False(t, audit.IsComplete())
+
+	merged := CompletenessAudit{Addresses: 1, Missing: []flow.Address{a1}}.merge(*audit)
+	require.Equal(t, uint64(4), merged.Addresses)
+	require.Equal(t, []flow.Address{a1, a3}, merged.Missing)
+
+	auditor.fullScanStarted(20)
+	require.True(t, auditor.audit().IsComplete())
+}
+
+func TestScan_CompletenessAudit(t *testing.T) {
+	addresses := []flow.Address{flow.HexToAddress("01"), flow.HexToAddress("02"), flow.HexToAddress("03")}
+	failing := addresses[2]
+
+	c := clienttest.New()
+	c.AddBlocks(1, 10)
+	c.HandleScripts(func(height uint64, script []byte, arguments []cadence.Value) (cadence.Value, error) {
+		for _, address := range arguments[0].(cadence.Array).Values {
+			if flow.Address(address.(cadence.Address)) == failing {
+				return nil, fmt.Errorf("failing account")
+			}
+		}
+		return cadence.NewBool(true), nil
+	})
+
+	s, err := NewScanner(c,
+		WithScript([]byte("pub fun main(addresses: [Address]): Bool { return true }")),
+		WithReferenceBlockHeight(10),
+		WithAddressProvider(StaticAddresses(addresses)),
+		WithBatchSize(2),
+		WithBisectFailedBatches(true, DefaultMaxFailedAddresses),
+		WithCompletenessAudit(),
+	)
+	require.NoError(t, err)
+
+	concluded, err := s.Scan(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, concluded.Audit)
+	require.Equal(t, uint64(3), concluded.Audit.Addresses)
+	require.Equal(t, []flow.Address{failing}, concluded.Audit.Missing)
+	require.Empty(t, concluded.Audit.Duplicated)
+}
@@ -16,7 +16,10 @@ package scanner
 
 import (
 	"context"
+	"runtime/pprof"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rs/zerolog"
 )
@@ -30,6 +33,7 @@ type Component interface {
 var _ Component = (*ComponentBase)(nil)
 
 type ComponentBase struct {
+	name        string
 	start       func(ctx context.Context)
 	startedChan chan struct{}
 	startOnce   sync.Once
@@ -38,6 +42,9 @@ type ComponentBase struct {
 	doneOnce sync.Once
 	doneErr  error
 
+	// lastHeartbeat is the unix time in nanoseconds of the last heartbeat.
+	lastHeartbeat atomic.Int64
+
 	Logger zerolog.Logger
 }
 
@@ -53,6 +60,7 @@ func NewComponentWithStart(
 		doneChan:    make(chan struct{}, 1),
 		startedChan: make(chan struct{}, 1),
 
+		name:  name,
 		start: start,
 
 		Logger: logger.With().Str("component", name).Logger(),
@@ -61,9 +69,12 @@ func NewComponentWithStart(
 
 func (c *ComponentBase) Start(ctx context.Context) <-chan struct{} {
 	c.startOnce.Do(func() {
+		c.Heartbeat()
 		go func() {
 			defer close(c.startedChan)
-			c.start(ctx)
+			// the goroutines of the component are labeled with its name,
+			// so they can be told apart in goroutine profiles.
+			pprof.Do(ctx, pprof.Labels(componentProfileLabel, c.name), c.start)
 			c.start = nil
 			c.Logger.Info().Msg("Started")
 		}()
@@ -72,6 +83,22 @@ func (c *ComponentBase) Start(ctx context.Context) <-chan struct{} {
 	return c.startedChan
 }
 
+// Heartbeat records that the component made progress.
+// Components that record heartbeats can be watched for stalls.
+func (c *ComponentBase) Heartbeat() {
+	c.lastHeartbeat.Store(time.Now().UnixNano())
+}
+
+// LastHeartbeat is the time of the last heartbeat, or when the component was started.
+// It is the zero time if the component was not started.
+func (c *ComponentBase) LastHeartbeat() time.Time {
+	last := c.lastHeartbeat.Load()
+	if last == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last)
+}
+
 func (c *ComponentBase) Done() <-chan struct{} {
 	return c.doneChan
 }
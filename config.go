@@ -15,38 +15,145 @@
 package scanner
 
 import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/onflow/cadence"
 	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+	"github.com/robfig/cron/v3"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/onflow/flow-batch-scan/candidates"
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/utils"
 )
 
 const DefaultBatchSize = 1000
 
+// DefaultAddressBatchQueueSize is small so that the batches in the queue don't encounter
+// "state commitment not found" errors.
+const DefaultAddressBatchQueueSize = 10
+
+const DefaultScriptResultQueueSize = 10000
+
 type Config struct {
 	ScriptRunnerConfig
 	FullScanRunnerConfig
 	IncrementalScannerConfig
+	ScriptResultProcessorConfig
 
 	ScriptResultHandler ScriptResultHandler
-	Reporter            StatusReporter
+	// ScriptResultHandlers are additional handlers. If set, every batch is handled by the ScriptResultHandler
+	// and all the ScriptResultHandlers concurrently. The errors of the handlers are isolated:
+	// they are logged and counted in ScanStats.HandlerErrors, but do not stop the other handlers or the scan.
+	ScriptResultHandlers []ScriptResultHandler
+	// Aggregates fold the results of each scan into the ScanConcluded.Aggregates. See WithAggregates.
+	Aggregates []AggregateHandler
+	// CompletenessAudit if true, the scan audits that every address of the full scan produced exactly one result.
+	// See CompletenessAudit.
+	CompletenessAudit bool
+	Reporter          StatusReporter
+
+	// ScriptClient if set, is used to execute the scripts (including the scripts of the address provider),
+	// while the client passed to NewScanner is used for the block and event queries.
+	// This allows running the scripts against an archive node, e.g. for point in time scans of historical heights.
+	ScriptClient client.Client
 
 	ContinuousScan bool
-	BatchSize      int
+	// Schedule if set, is a cron expression, e.g. "0 3 * * *": Scan runs as a service,
+	// and starts a fresh scan at every time of the schedule, until its context is cancelled.
+	// A run is skipped if the previous run is still in progress. See WithSchedule.
+	Schedule  string
+	BatchSize int
+	// AdaptiveBatchSize if true, the batch size is halved (down to MinBatchSize) when a script exceeds
+	// the computation or memory limit, and grows back up to BatchSize after consecutive successful batches.
+	// The failed batch is split and retried.
+	AdaptiveBatchSize bool
+	MinBatchSize      int
+
+	// AddressBatchQueueSize is the number of address batches that can wait for script execution.
+	AddressBatchQueueSize int
+	// BatchPriority decides whether the batches of the incremental scanner or of the full scan are executed first,
+	// when both are waiting. The full scan batches have their own queue of AddressBatchQueueSize.
+	// The durable queue keeps the batches in the order they arrive, so BatchPriority has no effect with a DurableQueuePath.
+	BatchPriority BatchPriority
+	// ScriptResultQueueSize is the number of script results that can wait to be handled.
+	ScriptResultQueueSize int
+	// FullScanRequestQueueSize is the number of full scan requests of the incremental scanner that can wait
+	// for the running full scan to be restarted. By default, the incremental scanner waits.
+	FullScanRequestQueueSize int
+	// DurableQueuePath if set, is the directory of a Badger database that queues the address batches
+	// between the candidate discovery and the script execution, so the queued batches are not lost in a crash,
+	// and do not have to be held in memory while the scripts fall behind.
+	// The batches that were not done when the scan stopped are executed first when a scan is started again.
+	DurableQueuePath string
+
+	// AddressFilter selects which addresses are scanned by the full and the incremental scans.
+	AddressFilter AddressFilter
+
+	// Hooks are called when the scan reaches certain phases.
+	Hooks ScanHooks
 
+	// StatusServerAddr if set, serves /healthz, /readyz and /status (the ScanStatus as JSON) on this address.
+	// /healthz fails if the incremental scanner made no progress for StatusServerStallTimeout,
+	// so a liveness probe can restart a stalled scan.
+	StatusServerAddr         string
+	StatusServerStallTimeout time.Duration
+
+	// ConsoleProgress if set, draws the progress of the scan to stderr: a progress bar of the full scan,
+	// the incremental scanner, the live throughput and the error counts.
+	// It is meant for interactive runs, and should be combined with a Logger that does not write to the terminal.
+	ConsoleProgress bool
+
+	// WatchdogStallTimeout if set, detects stalls of the incremental scanner and the running full scan:
+	// a stage stalls if the incremental scanner did not advance its latest handled block,
+	// or the full scan did not emit a batch, for the timeout.
+	// Stalls are logged and passed to the OnStall hook, and fail the scan with ErrStalled if WatchdogFailOnStall is set.
+	WatchdogStallTimeout time.Duration
+	WatchdogFailOnStall  bool
+
+	// ProfilingAddr if set, serves the net/http/pprof endpoints under /debug/pprof/ on this address,
+	// publishes the ScanVars with expvar on /debug/vars, and logs the heap usage and the goroutines per component every ProfilingLogInterval.
+	ProfilingAddr        string
+	ProfilingLogInterval time.Duration
+
+	// TracerProvider if set, records the spans of the scan: one per scanned block range and full scan,
+	// with the script execution and result handling of each batch as children.
+	// Use client.WithTracing to propagate the trace context to the access node.
+	TracerProvider trace.TracerProvider
+
+	// Logger is the logger of the scan. Other logging libraries can be used with WithLogSink or WithSlogLogger.
 	Logger zerolog.Logger
+
+	// builderErrors are the values rejected by the With* builders. See Validate.
+	builderErrors []error
 }
 
 func DefaultConfig() Config {
+	fullScanRunnerConfig := DefaultFullScanRunnerConfig()
+	// the chain ID is detected from the access node when the scan starts
+	fullScanRunnerConfig.ChainID = ""
+
 	return Config{
-		ScriptRunnerConfig:       DefaultScriptRunnerConfig(),
-		FullScanRunnerConfig:     DefaultFullScanRunnerConfig(),
-		IncrementalScannerConfig: DefaultIncrementalScannerConfig(),
-		ScriptResultHandler:      NoOpScriptResultHandler{},
-		Reporter:                 NoOpStatusReporter{},
-		ContinuousScan:           false,
-		BatchSize:                DefaultBatchSize,
-		Logger:                   zerolog.Nop(),
+		ScriptRunnerConfig:          DefaultScriptRunnerConfig(),
+		FullScanRunnerConfig:        fullScanRunnerConfig,
+		IncrementalScannerConfig:    DefaultIncrementalScannerConfig(),
+		ScriptResultProcessorConfig: DefaultScriptResultProcessorConfig(),
+		ScriptResultHandler:         NoOpScriptResultHandler{},
+		Reporter:                    NoOpStatusReporter{},
+		ContinuousScan:              false,
+		BatchSize:                   DefaultBatchSize,
+		MinBatchSize:                DefaultMinBatchSize,
+		AddressBatchQueueSize:       DefaultAddressBatchQueueSize,
+		BatchPriority:               PrioritizeIncremental,
+		ScriptResultQueueSize:       DefaultScriptResultQueueSize,
+		FullScanRequestQueueSize:    0,
+		StatusServerStallTimeout:    DefaultStatusServerStallTimeout,
+		ProfilingLogInterval:        DefaultProfilingLogInterval,
+		Logger:                      zerolog.Nop(),
 	}
 }
 
@@ -57,13 +164,232 @@ func (c Config) WithLogger(
 	return c
 }
 
+// WithLogSink logs the scan to the sink, for logging libraries other than zerolog.
+func (c Config) WithLogSink(
+	sink LogSink,
+) Config {
+	c.Logger = NewSinkLogger(sink)
+	return c
+}
+
 func (c Config) WithBatchSize(
 	value int,
 ) Config {
+	if value <= 0 {
+		return c.reject("WithBatchSize: the batch size must be positive, got %d", value)
+	}
 	c.BatchSize = value
 	return c
 }
 
+// WithFullScanThrottle slows the full scan down while the script latency or the error rate of the access node is high,
+// and speeds it back up when the node recovers. See FullScanThrottleConfig.
+// The state of the throttle is reported with StatusReporter.ReportFullScanThrottle.
+func (c Config) WithFullScanThrottle(
+	config FullScanThrottleConfig,
+) Config {
+	c.FullScanThrottle = &config
+	return c
+}
+
+// WithAdaptiveBatchSize shrinks the batch size down to minBatchSize when scripts exceed the computation or memory limit,
+// and grows it back up to the configured batch size when the scripts succeed.
+func (c Config) WithAdaptiveBatchSize(
+	minBatchSize int,
+) Config {
+	if minBatchSize <= 0 {
+		return c.reject("WithAdaptiveBatchSize: the minimum batch size must be positive, got %d", minBatchSize)
+	}
+	c.AdaptiveBatchSize = true
+	c.MinBatchSize = minBatchSize
+	return c
+}
+
+func (c Config) WithAddressBatchQueueSize(
+	value int,
+) Config {
+	if value < 0 {
+		return c.reject("WithAddressBatchQueueSize: the queue size must not be negative, got %d", value)
+	}
+	c.AddressBatchQueueSize = value
+	return c
+}
+
+// WithBatchPriority sets which batches are executed first, when the incremental scanner and a full scan
+// both have batches waiting. See Config.BatchPriority.
+func (c Config) WithBatchPriority(
+	priority BatchPriority,
+) Config {
+	c.BatchPriority = priority
+	return c
+}
+
+func (c Config) WithScriptResultQueueSize(
+	value int,
+) Config {
+	if value < 0 {
+		return c.reject("WithScriptResultQueueSize: the queue size must not be negative, got %d", value)
+	}
+	c.ScriptResultQueueSize = value
+	return c
+}
+
+func (c Config) WithFullScanRequestQueueSize(
+	value int,
+) Config {
+	if value < 0 {
+		return c.reject("WithFullScanRequestQueueSize: the queue size must not be negative, got %d", value)
+	}
+	c.FullScanRequestQueueSize = value
+	return c
+}
+
+// WithDurableQueue queues the address batches in a Badger database in the directory at path.
+// See Config.DurableQueuePath.
+func (c Config) WithDurableQueue(
+	path string,
+) Config {
+	c.DurableQueuePath = path
+	return c
+}
+
+// WithMaxInFlightBatches limits the number of batches that are handled concurrently,
+// to bound the memory of the scan when the handler is slower than the scripts.
+// See ScriptResultProcessorConfig.MaxInFlightBatches.
+func (c Config) WithMaxInFlightBatches(
+	value int,
+) Config {
+	if value < 0 {
+		return c.reject("WithMaxInFlightBatches: the limit must not be negative, got %d", value)
+	}
+	c.MaxInFlightBatches = value
+	return c
+}
+
+// WithResultSpillDir writes the results that exceed MaxInFlightBatches to files in dir,
+// instead of making the scripts wait. See ScriptResultProcessorConfig.ResultSpillDir.
+func (c Config) WithResultSpillDir(
+	dir string,
+) Config {
+	c.ResultSpillDir = dir
+	return c
+}
+
+// WithResultReconciliation sets the policy for addresses that are scanned more than once in a scan.
+// See ScriptResultProcessorConfig.ResultReconciliation.
+func (c Config) WithResultReconciliation(
+	policy ResultReconciliation,
+) Config {
+	c.ResultReconciliation = policy
+	return c
+}
+
+func (c Config) WithCatchUpBlockRange(
+	value uint64,
+) Config {
+	c.CatchUpBlockRange = value
+	return c
+}
+
+func (c Config) WithCatchUpConcurrency(
+	value int,
+) Config {
+	if value <= 0 {
+		return c.reject("WithCatchUpConcurrency: the concurrency must be positive, got %d", value)
+	}
+	c.CatchUpConcurrency = value
+	return c
+}
+
+func (c Config) WithProgressStore(
+	value ProgressStore,
+) Config {
+	c.ProgressStore = value
+	return c
+}
+
+func (c Config) WithResumeFromProgress(
+	value bool,
+) Config {
+	c.ResumeFromProgress = value
+	return c
+}
+
+func (c Config) WithOnFullScanRequest(
+	value func(request FullScanRequest) bool,
+) Config {
+	c.OnFullScanRequest = value
+	return c
+}
+
+// WithSubscription makes the incremental scanner follow new blocks by subscribing to the given events,
+// instead of polling and running the candidate scanners.
+func (c Config) WithSubscription(
+	events map[string]func(event cadence.Event) ([]flow.Address, error),
+) Config {
+	c.IncrementalScannerMode = IncrementalScannerSubscription
+	c.SubscriptionEvents = events
+	return c
+}
+
+// WithResumeFromCheckpoint saves the progress of full scans to the store,
+// and continues an unfinished full scan from the store when the scan is started.
+func (c Config) WithResumeFromCheckpoint(
+	store CheckpointStore,
+) Config {
+	c.CheckpointStore = store
+	return c
+}
+
+func (c Config) WithFullScanCheckpointInterval(
+	value time.Duration,
+) Config {
+	c.FullScanCheckpointInterval = value
+	return c
+}
+
+// WithFullScanProgressLogInterval logs the progress, throughput and ETA of running full scans every interval.
+func (c Config) WithFullScanProgressLogInterval(
+	value time.Duration,
+) Config {
+	c.FullScanProgressLogInterval = value
+	return c
+}
+
+// WithFullScanInterval starts a new full scan every interval in continuous mode,
+// while the incremental scanner keeps running.
+func (c Config) WithFullScanInterval(
+	value time.Duration,
+) Config {
+	c.FullScanInterval = value
+	return c
+}
+
+// WithFullScanBlockInterval starts a new full scan every value blocks in continuous mode,
+// while the incremental scanner keeps running.
+func (c Config) WithFullScanBlockInterval(
+	value uint64,
+) Config {
+	c.FullScanBlockInterval = value
+	return c
+}
+
+// WithReferenceBlockHeight runs a single full scan with all scripts executed at the given block height,
+// for a reproducible snapshot of the accounts at that height. The incremental scanner does not run.
+func (c Config) WithReferenceBlockHeight(
+	height uint64,
+) Config {
+	c.ReferenceBlockHeight = height
+	return c
+}
+
+func (c Config) WithBackpressurePolicy(
+	value BackpressurePolicy,
+) Config {
+	c.BackpressurePolicy = value
+	return c
+}
+
 func (c Config) WithContinuousScan(
 	value bool,
 ) Config {
@@ -71,6 +397,21 @@ func (c Config) WithContinuousScan(
 	return c
 }
 
+// WithSchedule runs a fresh scan at every time of the cron schedule, instead of a single scan.
+// The schedule has the standard five fields (minute, hour, day of month, month, day of week),
+// or is a descriptor like @daily or @every 6h.
+// Each run reports its result through the OnScanConcluded hook, skipped runs through OnScheduledRunSkipped.
+func (c Config) WithSchedule(
+	schedule string,
+) Config {
+	_, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return c.reject("WithSchedule: invalid schedule %q: %v", schedule, err)
+	}
+	c.Schedule = schedule
+	return c
+}
+
 func (c Config) WithCandidateScanners(
 	value []candidates.CandidateScanner,
 ) Config {
@@ -78,6 +419,200 @@ func (c Config) WithCandidateScanners(
 	return c
 }
 
+// WithIncrementalOnly skips the full scan and only runs the incremental scanner,
+// starting after startHeight. The scan runs until the context is cancelled.
+func (c Config) WithIncrementalOnly(
+	startHeight uint64,
+) Config {
+	c.IncrementalOnly = true
+	c.IncrementalStartHeight = startHeight
+	return c
+}
+
+// WithIncrementalStartHeight starts the incremental scanner after startHeight instead of doing a full scan.
+// The blocks up to the latest block are backfilled first.
+func (c Config) WithIncrementalStartHeight(
+	startHeight uint64,
+) Config {
+	c.IncrementalStartHeight = startHeight
+	return c
+}
+
+func (c Config) WithBackfillBlockRange(
+	value uint64,
+) Config {
+	c.BackfillBlockRange = value
+	return c
+}
+
+func (c Config) WithBackfillInterval(
+	value time.Duration,
+) Config {
+	c.BackfillInterval = value
+	return c
+}
+
+func (c Config) WithIncrementalScannerBlockLag(
+	value uint64,
+) Config {
+	c.IncrementalScannerBlockLag = value
+	return c
+}
+
+func (c Config) WithFollowFinalized(
+	value bool,
+) Config {
+	c.FollowFinalized = value
+	return c
+}
+
+func (c Config) WithIncrementalScannerMaxBlockGap(
+	value uint64,
+) Config {
+	c.IncrementalScannerMaxBlockGap = value
+	return c
+}
+
+func (c Config) WithIncrementalScanInterval(
+	value time.Duration,
+) Config {
+	c.IncrementalScanInterval = value
+	return c
+}
+
+func (c Config) WithCandidateCoalesceBlocks(
+	value uint64,
+) Config {
+	c.CandidateCoalesceBlocks = value
+	return c
+}
+
+func (c Config) WithCandidateCoalesceDuration(
+	value time.Duration,
+) Config {
+	c.CandidateCoalesceDuration = value
+	return c
+}
+
+func (c Config) WithCandidateDebounceBlocks(
+	value uint64,
+) Config {
+	c.CandidateDebounceBlocks = value
+	return c
+}
+
+func (c Config) WithCandidateDebounceDuration(
+	value time.Duration,
+) Config {
+	c.CandidateDebounceDuration = value
+	return c
+}
+
+// WithEventDedup drops the candidates of events that were already handled by the incremental scanner.
+// The last size events are remembered. If size is 0, DefaultEventDedupSize is used.
+func (c Config) WithEventDedup(
+	size int,
+) Config {
+	if size <= 0 {
+		size = DefaultEventDedupSize
+	}
+	c.EventDedupSize = size
+	return c
+}
+
+func (c Config) WithOnFullScanStarted(
+	value func(referenceBlockHeight uint64),
+) Config {
+	c.Hooks.OnFullScanStarted = value
+	return c
+}
+
+func (c Config) WithOnFullScanCompleted(
+	value func(stats FullScanStats),
+) Config {
+	c.Hooks.OnFullScanCompleted = value
+	return c
+}
+
+func (c Config) WithOnBatchProcessed(
+	value func(batch ProcessedAddressBatch, duration time.Duration),
+) Config {
+	c.Hooks.OnBatchProcessed = value
+	return c
+}
+
+func (c Config) WithOnIncrementalRangeScanned(
+	value func(start uint64, end uint64),
+) Config {
+	c.Hooks.OnIncrementalRangeScanned = value
+	return c
+}
+
+func (c Config) WithOnStall(
+	value func(stage string, since time.Duration),
+) Config {
+	c.Hooks.OnStall = value
+	return c
+}
+
+func (c Config) WithOnScanConcluded(
+	value func(concluded ScanConcluded, err error),
+) Config {
+	c.Hooks.OnScanConcluded = value
+	return c
+}
+
+func (c Config) WithOnScheduledRunSkipped(
+	value func(scheduled time.Time),
+) Config {
+	c.Hooks.OnScheduledRunSkipped = value
+	return c
+}
+
+// WithStatusServer serves the health and the status of the scan on addr, e.g. ":8080".
+func (c Config) WithStatusServer(
+	addr string,
+) Config {
+	c.StatusServerAddr = addr
+	return c
+}
+
+// WithConsoleProgress draws the progress of the scan to stderr, for interactive runs.
+func (c Config) WithConsoleProgress(
+	value bool,
+) Config {
+	c.ConsoleProgress = value
+	return c
+}
+
+// WithWatchdog detects pipeline stages that made no progress for stallTimeout,
+// and fails the scan if failOnStall is set.
+func (c Config) WithWatchdog(
+	stallTimeout time.Duration,
+	failOnStall bool,
+) Config {
+	c.WatchdogStallTimeout = stallTimeout
+	c.WatchdogFailOnStall = failOnStall
+	return c
+}
+
+// WithProfiling serves the pprof endpoints on addr, e.g. ":6060",
+// and periodically logs the memory and goroutine usage of the scan.
+func (c Config) WithProfiling(
+	addr string,
+) Config {
+	c.ProfilingAddr = addr
+	return c
+}
+
+// WithTracerProvider records the spans of the scan with the provider.
+func (c Config) WithTracerProvider(
+	value trace.TracerProvider,
+) Config {
+	c.TracerProvider = value
+	return c
+}
+
 func (c Config) WithStatusReporter(
 	value StatusReporter,
 ) Config {
@@ -88,17 +623,83 @@ func (c Config) WithStatusReporter(
 func (c Config) WithScriptResultHandler(
 	value ScriptResultHandler,
 ) Config {
+	if value == nil {
+		return c.reject("WithScriptResultHandler: the handler is nil, use NoOpScriptResultHandler to ignore the results")
+	}
 	c.ScriptResultHandler = value
 	return c
 }
 
+// WithScriptResultHandlers adds handlers that handle every batch in addition to the ScriptResultHandler.
+// A failing handler does not stop the other handlers or the scan.
+func (c Config) WithScriptResultHandlers(
+	handlers ...ScriptResultHandler,
+) Config {
+	for i, handler := range handlers {
+		if handler == nil {
+			return c.reject("WithScriptResultHandlers: handler %d is nil", i)
+		}
+	}
+	existing := c.ScriptResultHandlers
+	c.ScriptResultHandlers = append(existing[:len(existing):len(existing)], handlers...)
+	return c
+}
+
+// WithCompletenessAudit audits that every address the full scan enumerates (after the filters) produces
+// exactly one handled result at or after its reference block, and returns the CompletenessAudit in ScanConcluded.Audit.
+// The audit keeps every enumerated address in memory until the next full scan starts.
+// A resumed full scan only audits the addresses after its checkpoint.
+func (c Config) WithCompletenessAudit() Config {
+	c.CompletenessAudit = true
+	return c
+}
+
+// WithAggregates folds the results of each scan into aggregates, e.g. created with Aggregate.
+// They handle every batch like the ScriptResultHandlers, are reset when a scan starts,
+// and their final values are returned in ScanConcluded.Aggregates.
+func (c Config) WithAggregates(
+	aggregates ...AggregateHandler,
+) Config {
+	names := make(map[string]struct{}, len(c.Aggregates)+len(aggregates))
+	for _, aggregate := range c.Aggregates {
+		names[aggregate.Name()] = struct{}{}
+	}
+	handlers := make([]ScriptResultHandler, 0, len(aggregates))
+	for i, aggregate := range aggregates {
+		if aggregate == nil {
+			return c.reject("WithAggregates: aggregate %d is nil", i)
+		}
+		if _, ok := names[aggregate.Name()]; ok {
+			return c.reject("WithAggregates: duplicate aggregate %q", aggregate.Name())
+		}
+		names[aggregate.Name()] = struct{}{}
+		handlers = append(handlers, aggregate)
+	}
+	existing := c.Aggregates
+	c.Aggregates = append(existing[:len(existing):len(existing)], aggregates...)
+	return c.WithScriptResultHandlers(handlers...)
+}
+
+// WithChainID sets the chain of the scan. If it is not set, the chain ID is detected from the access node
+// when the scan starts. If it is set, the scan fails with a ChainIDMismatchError when the access node is on another chain.
 func (c Config) WithChainID(
 	value flow.ChainID,
 ) Config {
+	if _, ok := validChainIDs[value]; !ok {
+		return c.reject("WithChainID: unknown chain ID %q", value)
+	}
 	c.ChainID = value
 	return c
 }
 
+// ForNetwork sets the chain ID of the network of the preset.
+// Connect to the same network with preset.NewClient.
+func (c Config) ForNetwork(
+	preset client.Preset,
+) Config {
+	return c.WithChainID(preset.ChainID)
+}
+
 func (c Config) WithExcludeAddress(
 	value func(id flow.ChainID, address flow.Address) bool,
 ) Config {
@@ -106,23 +707,466 @@ func (c Config) WithExcludeAddress(
 	return c
 }
 
+// WithIncludeAddresses only scans the given addresses (and any other included addresses or ranges).
+func (c Config) WithIncludeAddresses(
+	value ...flow.Address,
+) Config {
+	addresses := c.AddressFilter.Include
+	c.AddressFilter.Include = append(addresses[:len(addresses):len(addresses)], value...)
+	return c
+}
+
+// WithIncludeAddressRange only scans the addresses from `from` to `to` inclusive
+// (and any other included addresses or ranges).
+func (c Config) WithIncludeAddressRange(
+	from flow.Address,
+	to flow.Address,
+) Config {
+	if bytes.Compare(from[:], to[:]) > 0 {
+		return c.reject("WithIncludeAddressRange: 0x%s is after 0x%s", from.Hex(), to.Hex())
+	}
+	ranges := c.AddressFilter.IncludeRanges
+	c.AddressFilter.IncludeRanges = append(ranges[:len(ranges):len(ranges)], AddressRange{From: from, To: to})
+	return c
+}
+
+// WithExcludeAddresses never scans the given addresses.
+func (c Config) WithExcludeAddresses(
+	value ...flow.Address,
+) Config {
+	addresses := c.AddressFilter.Exclude
+	c.AddressFilter.Exclude = append(addresses[:len(addresses):len(addresses)], value...)
+	return c
+}
+
+// WithExcludeAddressRange never scans the addresses from `from` to `to` inclusive.
+func (c Config) WithExcludeAddressRange(
+	from flow.Address,
+	to flow.Address,
+) Config {
+	if bytes.Compare(from[:], to[:]) > 0 {
+		return c.reject("WithExcludeAddressRange: 0x%s is after 0x%s", from.Hex(), to.Hex())
+	}
+	ranges := c.AddressFilter.ExcludeRanges
+	c.AddressFilter.ExcludeRanges = append(ranges[:len(ranges):len(ranges)], AddressRange{From: from, To: to})
+	return c
+}
+
+// WithSampleRate makes full scans only scan the given fraction (between 0 and 1) of the addresses.
+func (c Config) WithSampleRate(
+	fraction float64,
+) Config {
+	if fraction <= 0 || fraction > 1 {
+		return c.reject("WithSampleRate: the fraction must be greater than 0 and at most 1, got %v", fraction)
+	}
+	c.SampleRate = fraction
+	return c
+}
+
+// WithSampleEvery makes full scans only scan every nth address.
+func (c Config) WithSampleEvery(
+	n uint,
+) Config {
+	if n == 0 {
+		return c.reject("WithSampleEvery: n must be positive")
+	}
+	c.SampleRate = 1 / float64(n)
+	return c
+}
+
+// WithPartitions makes the full scan only scan one of total disjoint partitions of the address space,
+// the one with the given index (from 0 to total-1). Running a scanner process for each index,
+// all with the same WithReferenceBlockHeight, scans all the addresses once at that block height.
+// The results of the processes can be merged with MergePartitions.
+func (c Config) WithPartitions(
+	total uint,
+	index uint,
+) Config {
+	if total == 0 || index >= total {
+		return c.reject("WithPartitions: the index must be less than the total, got %d of %d", index, total)
+	}
+	c.Partition = Partition{Total: total, Index: index}
+	return c
+}
+
+// WithMaxAddresses stops full scans after value addresses. Meant for development runs.
+func (c Config) WithMaxAddresses(
+	value uint,
+) Config {
+	c.MaxAddresses = value
+	return c
+}
+
+// WithMaxBatches stops full scans after value batches. Meant for development runs.
+func (c Config) WithMaxBatches(
+	value uint,
+) Config {
+	c.MaxBatches = value
+	return c
+}
+
+// WithAddressProvider makes full scans only scan the addresses from the provider instead of all the addresses
+// on the chain. The incremental scanner still scans all candidates.
+func (c Config) WithAddressProvider(
+	value func(ctx context.Context, blockHeight uint64) (AddressProvider, error),
+) Config {
+	c.NewAddressProvider = value
+	return c
+}
+
 func (c Config) WithScript(
 	value []byte,
 ) Config {
+	if len(value) == 0 {
+		return c.reject("WithScript: the script is empty")
+	}
 	c.Script = value
 	return c
 }
 
+// WithScripts runs every batch against each of the scripts.
+// Use a ScriptResultHandlerMux to handle the results of each script separately.
+func (c Config) WithScripts(
+	value map[string][]byte,
+) Config {
+	c.Scripts = value
+	return c
+}
+
+// WithJobs adds independent scan jobs that share the pipeline of the scan, see ScanJob.
+// Each job's script is added to the Scripts, and its handler to the ScriptResultHandlers,
+// so a failing job's handler does not stop the other jobs. The jobs are run instead of Script.
+func (c Config) WithJobs(
+	jobs ...ScanJob,
+) Config {
+	scripts := utils.CloneMap(c.Scripts)
+	if scripts == nil {
+		scripts = make(map[string][]byte)
+	}
+	batchSizes := utils.CloneMap(c.ScriptBatchSizes)
+	if batchSizes == nil {
+		batchSizes = make(map[string]int)
+	}
+	handlers := make([]ScriptResultHandler, 0, len(jobs))
+	for i, job := range jobs {
+		switch {
+		case job.Name == "":
+			return c.reject("WithJobs: job %d has no name", i)
+		case len(job.Script) == 0:
+			return c.reject("WithJobs: the script of job %q is empty", job.Name)
+		case job.Handler == nil:
+			return c.reject("WithJobs: the handler of job %q is nil", job.Name)
+		case job.BatchSize < 0:
+			return c.reject("WithJobs: the batch size of job %q must not be negative, got %d", job.Name, job.BatchSize)
+		}
+		if _, ok := scripts[job.Name]; ok {
+			return c.reject("WithJobs: duplicate script name %q", job.Name)
+		}
+		scripts[job.Name] = job.Script
+		if job.BatchSize > 0 {
+			batchSizes[job.Name] = job.BatchSize
+		}
+		handlers = append(handlers, &jobResultHandler{name: job.Name, handler: job.Handler})
+	}
+	c.Scripts = scripts
+	c.ScriptBatchSizes = batchSizes
+	return c.WithScriptResultHandlers(handlers...)
+}
+
+// WithScriptUpgrade runs the script instead of Script for the batches at or above the block height,
+// e.g. the Cadence 1.0 version of the script from the height of the Crescendo upgrade on.
+func (c Config) WithScriptUpgrade(
+	height uint64,
+	script []byte,
+) Config {
+	return c.WithScriptUpgrades(ScriptUpgrade{Height: height, Script: script})
+}
+
+// WithScriptUpgrades adds versions of Script or of the Scripts for the batches at or above their heights.
+func (c Config) WithScriptUpgrades(
+	upgrades ...ScriptUpgrade,
+) Config {
+	for _, upgrade := range upgrades {
+		if len(upgrade.Script) == 0 {
+			return c.reject("WithScriptUpgrades: the script of the upgrade at height %d is empty", upgrade.Height)
+		}
+	}
+	c.ScriptUpgrades = append(utils.CloneSlice(c.ScriptUpgrades), upgrades...)
+	sortScriptUpgrades(c.ScriptUpgrades)
+	return c
+}
+
+// WithPerAddress runs the script once for each address of a batch, with at most concurrency scripts of a batch
+// running concurrently. This is for scripts that take a single `Address` argument.
+func (c Config) WithPerAddress(
+	concurrency int,
+) Config {
+	if concurrency <= 0 {
+		return c.reject("WithPerAddress: the concurrency must be positive, got %d", concurrency)
+	}
+	c.PerAddress = true
+	c.PerAddressConcurrency = concurrency
+	return c
+}
+
+// WithAccountBalanceMode scans the FLOW balances of the accounts without running a script:
+// the balance of each address is read with GetAccountAtBlockHeight, with at most PerAddressConcurrency
+// requests of a batch running concurrently. The results are handled like the results of scripts.FlowBalances,
+// decode them with scripts.DecodeFlowBalances.
+func (c Config) WithAccountBalanceMode() Config {
+	c.AccountBalanceMode = true
+	return c
+}
+
+// WithAccountKeysMode scans the keys of the accounts without running a script:
+// the keys of each address are read with GetAccountAtBlockHeight, like in WithAccountBalanceMode.
+// The results are handled like the results of scripts.AccountKeys, with the sequence numbers of the keys,
+// decode them with scripts.DecodeAccountKeys.
+func (c Config) WithAccountKeysMode() Config {
+	c.AccountKeysMode = true
+	return c
+}
+
+// WithScriptArguments passes the arguments to the script after the addresses.
+func (c Config) WithScriptArguments(
+	value ...cadence.Value,
+) Config {
+	c.ScriptArguments = value
+	return c
+}
+
+// WithScriptArgumentsForBatch builds the arguments passed to the script after the addresses for each batch.
+func (c Config) WithScriptArgumentsForBatch(
+	value func(batch AddressBatch) []cadence.Value,
+) Config {
+	c.ScriptArgumentsForBatch = value
+	return c
+}
+
+// WithExpectedResultType fails the scan when a result of the script with the name ("" is Script)
+// does not have the type, e.g. because the script or a contract it uses changed shape,
+// instead of handling it. See CheckResultType.
+func (c Config) WithExpectedResultType(
+	scriptName string,
+	typ cadence.Type,
+) Config {
+	if typ == nil {
+		return c.reject("WithExpectedResultType: the type of script %q is nil", scriptName)
+	}
+	types := utils.CloneMap(c.ExpectedResultTypes)
+	if types == nil {
+		types = make(map[string]cadence.Type)
+	}
+	types[scriptName] = typ
+	c.ExpectedResultTypes = types
+	return c
+}
+
+// WithExpectedResultTypeString is WithExpectedResultType with a Cadence type, e.g. `{Address: UFix64}`.
+// See ParseResultType.
+func (c Config) WithExpectedResultTypeString(
+	scriptName string,
+	typ string,
+) Config {
+	parsed, err := ParseResultType(typ)
+	if err != nil {
+		return c.reject("WithExpectedResultTypeString: %v", err)
+	}
+	return c.WithExpectedResultType(scriptName, parsed)
+}
+
+// WithResolveImports resolves the placeholder imports of the scripts (`import "FungibleToken"` or
+// `import FungibleToken from 0xFUNGIBLETOKEN`) to the addresses of the contracts on the configured chain.
+func (c Config) WithResolveImports(
+	aliases ContractAliases,
+) Config {
+	c.ContractAliases = aliases
+	return c
+}
+
+// WithScriptClient sets the client used to execute the scripts,
+// for example an archive node that supports historical block heights.
+func (c Config) WithScriptClient(value client.Client) Config {
+	c.ScriptClient = value
+	return c
+}
+
+// WithScriptTimeout sets a deadline for each script execution, independent of the client timeout.
+// A batch that times out is retried up to retries times, before it is handled as a script error.
+func (c Config) WithScriptTimeout(
+	timeout time.Duration,
+	retries int,
+) Config {
+	if timeout < 0 || retries < 0 {
+		return c.reject("WithScriptTimeout: the timeout and the retries must not be negative, got %s and %d", timeout, retries)
+	}
+	c.ScriptTimeout = timeout
+	c.ScriptTimeoutRetries = retries
+	return c
+}
+
+// WithMaxConcurrentScripts sets the number of script workers, i.e. the number of batches whose scripts
+// can run concurrently. The utilization of the workers is reported to the StatusReporter.
 func (c Config) WithMaxConcurrentScripts(
 	value int,
 ) Config {
+	if value <= 0 {
+		return c.reject("WithMaxConcurrentScripts: the number of scripts must be positive, got %d", value)
+	}
 	c.MaxConcurrentScripts = value
 	return c
 }
 
+// WithDryRun runs the full scan address enumeration and the candidate scanners, but does not run any scripts.
+// The number of addresses and batches that would be scanned is returned in ScanStats.
+func (c Config) WithDryRun(
+	value bool,
+) Config {
+	c.DryRun = value
+	return c
+}
+
+func (c Config) WithConcurrencySchedule(
+	value ...ConcurrencyWindow,
+) Config {
+	c.ConcurrencySchedule = value
+	return c
+}
+
+// WithHandlerErrorPolicy sets what happens when the ScriptResultHandler returns an error.
+// Use a PolicyResultHandler to set the policy of a single handler.
+func (c Config) WithHandlerErrorPolicy(
+	value HandlerErrorPolicy,
+) Config {
+	c.HandlerErrorPolicy = value
+	return c
+}
+
 func (c Config) WithHandleScriptError(
 	value func(AddressBatch, error) ScriptErrorAction,
 ) Config {
 	c.HandleScriptError = value
 	return c
 }
+
+// WithHandleAddressError sets the policy for the addresses that fail on their own, e.g. accounts that do not exist:
+// they can be skipped, so the rest of their batch is scanned. See DefaultHandleAddressError.
+func (c Config) WithHandleAddressError(
+	value func(flow.Address, error) AddressErrorAction,
+) Config {
+	c.HandleAddressError = value
+	return c
+}
+
+// WithBisectFailedBatches splits failing batches until the failing addresses are found, and skips them.
+// The scan fails if more than maxFailedAddresses addresses fail (0 means no limit).
+func (c Config) WithBisectFailedBatches(
+	value bool,
+	maxFailedAddresses int,
+) Config {
+	c.BisectFailedBatches = value
+	c.MaxFailedAddresses = maxFailedAddresses
+	return c
+}
+
+// WithScriptErrorPolicy sets how the script errors of the class are handled, e.g.
+// ErrScriptComputationLimit with ScriptErrorPolicyShrinkBatch, or ErrAccountNotFound with ScriptErrorPolicySkipAddress.
+// It replaces the policy of the class in DefaultScriptErrorPolicies.
+func (c Config) WithScriptErrorPolicy(
+	class error,
+	policy ScriptErrorPolicy,
+) Config {
+	if class == nil {
+		return c.reject("WithScriptErrorPolicy: the error class is nil")
+	}
+	if !policy.valid() {
+		return c.reject("WithScriptErrorPolicy: unknown policy %s of class %q", policy, class)
+	}
+	policies := cloneScriptErrorPolicies(c.ScriptErrorPolicies)
+	if policies == nil {
+		policies = make(map[error]ScriptErrorPolicy)
+	}
+	policies[class] = policy
+	c.ScriptErrorPolicies = policies
+	return c
+}
+
+// WithoutScriptErrorPolicy removes the policy of the class, so its errors are handled by HandleScriptError.
+func (c Config) WithoutScriptErrorPolicy(
+	class error,
+) Config {
+	policies := cloneScriptErrorPolicies(c.ScriptErrorPolicies)
+	delete(policies, class)
+	c.ScriptErrorPolicies = policies
+	return c
+}
+
+// WithScriptErrorRetries sets how often a batch is retried with ScriptErrorPolicyRetry,
+// and the backoff before the first retry, which doubles with every retry.
+func (c Config) WithScriptErrorRetries(
+	retries int,
+	backoff time.Duration,
+) Config {
+	if retries < 0 {
+		return c.reject("WithScriptErrorRetries: the retries must not be negative, got %d", retries)
+	}
+	if backoff < 0 {
+		return c.reject("WithScriptErrorRetries: the backoff must not be negative, got %s", backoff)
+	}
+	c.ScriptErrorRetries = retries
+	c.ScriptErrorRetryBackoff = backoff
+	return c
+}
+
+// WithFailedBatchHandler sets the handler for batches that failed after all retries.
+// The scan continues without the failed batches. See FileFailedBatchHandler.
+func (c Config) WithFailedBatchHandler(handler FailedBatchHandler) Config {
+	c.FailedBatchHandler = handler
+	return c
+}
+
+// WithBatchMetricsObserver sets the observer of every execution of the script of a batch,
+// with its duration, addresses, retries, result size and error class.
+func (c Config) WithBatchMetricsObserver(observer BatchMetricsObserver) Config {
+	c.BatchMetricsObserver = observer
+	return c
+}
+
+func (c Config) WithLinkedAddresses(
+	value func(batch ProcessedAddressBatch) []flow.Address,
+) Config {
+	c.LinkedAddresses = value
+	return c
+}
+
+func (c Config) WithBatchSigner(
+	value crypto.Signer,
+) Config {
+	c.BatchSigner = value
+	return c
+}
+
+// clone returns a copy of the config with copies of its slices and maps,
+// so changes to the config it was cloned from do not affect it.
+// The values in the slices and maps (e.g. the handlers and the candidate scanners) are shared.
+func (c Config) clone() Config {
+	c.Script = utils.CloneSlice(c.Script)
+	c.Scripts = utils.CloneMap(c.Scripts)
+	c.ScriptUpgrades = utils.CloneSlice(c.ScriptUpgrades)
+	c.ScriptArguments = utils.CloneSlice(c.ScriptArguments)
+	c.ExpectedResultTypes = utils.CloneMap(c.ExpectedResultTypes)
+	c.ScriptErrorPolicies = cloneScriptErrorPolicies(c.ScriptErrorPolicies)
+	c.ScriptBatchSizes = utils.CloneMap(c.ScriptBatchSizes)
+	c.ConcurrencySchedule = utils.CloneSlice(c.ConcurrencySchedule)
+	c.ContractAliases = c.ContractAliases.clone()
+	c.CandidateScanners = utils.CloneSlice(c.CandidateScanners)
+	c.SubscriptionEvents = utils.CloneMap(c.SubscriptionEvents)
+	c.ScriptResultHandlers = utils.CloneSlice(c.ScriptResultHandlers)
+	c.Aggregates = utils.CloneSlice(c.Aggregates)
+	c.AddressFilter.Include = utils.CloneSlice(c.AddressFilter.Include)
+	c.AddressFilter.IncludeRanges = utils.CloneSlice(c.AddressFilter.IncludeRanges)
+	c.AddressFilter.Exclude = utils.CloneSlice(c.AddressFilter.Exclude)
+	c.AddressFilter.ExcludeRanges = utils.CloneSlice(c.AddressFilter.ExcludeRanges)
+	c.builderErrors = utils.CloneSlice(c.builderErrors)
+	return c
+}
@@ -0,0 +1,323 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/client/interceptors"
+	"github.com/onflow/flow-batch-scan/handlers"
+	"github.com/onflow/flow-batch-scan/handlers/postgres"
+	"github.com/onflow/flow-batch-scan/status"
+)
+
+// chainIDs are the chains by their short names.
+var chainIDs = map[string]flow.ChainID{
+	"mainnet":  flow.Mainnet,
+	"testnet":  flow.Testnet,
+	"emulator": flow.Emulator,
+}
+
+// Chain returns the chain of ChainID, which can be a short name or a chain ID.
+// It returns false if the chain is not known.
+func (s ScannerSettings) Chain() (flow.ChainID, bool) {
+	if chainID, ok := chainIDs[strings.ToLower(s.ChainID)]; ok {
+		return chainID, true
+	}
+	for _, chainID := range chainIDs {
+		if string(chainID) == s.ChainID {
+			return chainID, true
+		}
+	}
+	return flow.ChainID(s.ChainID), false
+}
+
+// Validate checks the settings, and returns a *scanner.InvalidConfigError with all the problems.
+// The scanner config built from the settings is validated by ScannerConfig.
+func (s Settings) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(len(s.Client.AccessNodes) > 0, "client.access_nodes: at least one access node is required")
+	check(s.Client.RateLimit >= 0, "client.rate_limit must not be negative, got %d", s.Client.RateLimit)
+	check(s.Client.MaxConcurrentRequests >= 0,
+		"client.max_concurrent_requests must not be negative, got %d", s.Client.MaxConcurrentRequests)
+	check(s.Client.BlockHeaderPrefetch >= 0,
+		"client.block_header_prefetch must not be negative, got %d", s.Client.BlockHeaderPrefetch)
+
+	_, ok := s.Scanner.Chain()
+	check(ok || s.Scanner.ChainID == "", "scanner.chain_id: unknown chain %q, expected mainnet, testnet or emulator", s.Scanner.ChainID)
+	check(s.Scanner.BatchSize > 0, "scanner.batch_size must be positive, got %d", s.Scanner.BatchSize)
+	check(s.Scanner.MaxConcurrentScripts > 0,
+		"scanner.max_concurrent_scripts must be positive, got %d", s.Scanner.MaxConcurrentScripts)
+	for name, duration := range map[string]Duration{
+		"scanner.script_timeout":                s.Scanner.ScriptTimeout,
+		"scanner.full_scan_interval":            s.Scanner.FullScanInterval,
+		"scanner.full_scan_checkpoint_interval": s.Scanner.FullScanCheckpointInterval,
+		"handler.retry_backoff":                 s.Handler.RetryBackoff,
+		"client.block_header_cache_ttl":         s.Client.BlockHeaderCacheTTL,
+	} {
+		check(duration >= 0, "%s must not be negative, got %s", name, time.Duration(duration))
+	}
+	check(s.Scanner.IncrementalScanInterval > 0,
+		"scanner.incremental_scan_interval must be positive, got %s", time.Duration(s.Scanner.IncrementalScanInterval))
+	check(!s.Scanner.AccountBalanceMode || s.Scanner.ScriptPath == "",
+		"scanner.script_path is not run with scanner.account_balance_mode")
+	check(!s.Scanner.AccountKeysMode || s.Scanner.ScriptPath == "",
+		"scanner.script_path is not run with scanner.account_keys_mode")
+	_, err := s.Scanner.logLevel()
+	check(err == nil, "scanner.log_level: %v", err)
+	if s.Scanner.ResultType != "" {
+		_, err = scanner.ParseResultType(s.Scanner.ResultType)
+		check(err == nil, "scanner.result_type: %v", err)
+	}
+	_, ok = s.Scanner.reconciliation()
+	check(ok, "scanner.result_reconciliation: unknown reconciliation %q, expected %s, %s or %s",
+		s.Scanner.ResultReconciliation, ReconcileNone, ReconcileHighestHeight, ReconcileFirst)
+
+	switch s.Handler.Output {
+	case OutputNone:
+	case OutputJSONLines:
+		check(s.Handler.Path != "", "handler.path is required with the %s output", s.Handler.Output)
+	case OutputPostgres:
+		check(s.Handler.DSN != "", "handler.dsn is required with the %s output", s.Handler.Output)
+		check(s.Handler.Table != "", "handler.table is required with the %s output", s.Handler.Output)
+	default:
+		check(false, "handler.output: unknown output %q, expected %s or %s", s.Handler.Output, OutputJSONLines, OutputPostgres)
+	}
+	check(s.Handler.MaxFileBytes >= 0, "handler.max_file_bytes must not be negative, got %d", s.Handler.MaxFileBytes)
+	switch s.Handler.ErrorPolicy {
+	case ErrorPolicyFail, ErrorPolicyLog:
+	case ErrorPolicyRetry:
+		check(s.Handler.Retries > 0, "handler.retries must be positive with the %s error policy", ErrorPolicyRetry)
+	default:
+		check(false, "handler.error_policy: unknown policy %q, expected %s, %s or %s",
+			s.Handler.ErrorPolicy, ErrorPolicyFail, ErrorPolicyRetry, ErrorPolicyLog)
+	}
+
+	if len(errs) > 0 {
+		return &scanner.InvalidConfigError{Errors: errs}
+	}
+	return nil
+}
+
+// NewClient connects to the access nodes of the settings.
+// The options are applied after the settings, e.g. client.WithRateLimitReloader for a Reloader.
+func (s Settings) NewClient(logger zerolog.Logger, opts ...client.Option) (client.ClosableClient, error) {
+	options := []client.Option{client.WithLog(logger)}
+	if s.Client.RateLimit > 0 {
+		options = append(options, client.WithRateLimits(interceptors.RateLimit{Rate: s.Client.RateLimit}, nil))
+	}
+	if s.Client.MaxConcurrentRequests > 0 {
+		options = append(options, client.WithMaxConcurrentRequests(s.Client.MaxConcurrentRequests))
+	}
+	if s.Client.BlockHeaderPrefetch > 0 {
+		options = append(options, client.WithBlockHeaderPrefetch(s.Client.BlockHeaderPrefetch))
+	}
+	if s.Client.BlockHeaderCacheTTL > 0 {
+		options = append(options, client.WithBlockHeaderCache(
+			time.Duration(s.Client.BlockHeaderCacheTTL),
+			client.DefaultConfig().BlockHeaderCacheSize))
+	}
+	if s.Client.BearerToken != "" {
+		options = append(options, client.WithBearerToken(s.Client.BearerToken))
+	}
+	options = append(options, opts...)
+	return client.NewClient(strings.Join(s.Client.AccessNodes, ","), options...)
+}
+
+// reconciliation is the scanner.ResultReconciliation of ResultReconciliation, an empty one is ReconcileNone.
+func (s ScannerSettings) reconciliation() (scanner.ResultReconciliation, bool) {
+	switch s.ResultReconciliation {
+	case "", ReconcileNone:
+		return scanner.ReconcileNone, true
+	case ReconcileHighestHeight:
+		return scanner.ReconcileHighestHeight, true
+	case ReconcileFirst:
+		return scanner.ReconcileFirst, true
+	default:
+		return scanner.ReconcileNone, false
+	}
+}
+
+// logLevel is the level of LogLevel. It returns zerolog.NoLevel if LogLevel is not set.
+func (s ScannerSettings) logLevel() (zerolog.Level, error) {
+	if s.LogLevel == "" {
+		return zerolog.NoLevel, nil
+	}
+	return zerolog.ParseLevel(strings.ToLower(s.LogLevel))
+}
+
+// ScannerConfig builds the scanner config of the settings, and validates it.
+// The ScriptResultHandler is not set, see HandlerSettings.NewHandler.
+func (s Settings) ScannerConfig(logger zerolog.Logger) (scanner.Config, error) {
+	if level, err := s.Scanner.logLevel(); err == nil && level != zerolog.NoLevel {
+		logger = logger.Level(level)
+	}
+	config := scanner.DefaultConfig().
+		WithLogger(logger).
+		WithBatchSize(s.Scanner.BatchSize).
+		WithMaxConcurrentScripts(s.Scanner.MaxConcurrentScripts).
+		WithContinuousScan(s.Scanner.ContinuousScan).
+		WithIncrementalScanInterval(time.Duration(s.Scanner.IncrementalScanInterval)).
+		WithIncrementalScannerBlockLag(s.Scanner.IncrementalScannerBlockLag).
+		WithIncrementalScannerMaxBlockGap(s.Scanner.IncrementalScannerMaxBlockGap).
+		WithFullScanInterval(time.Duration(s.Scanner.FullScanInterval)).
+		WithFullScanCheckpointInterval(time.Duration(s.Scanner.FullScanCheckpointInterval)).
+		WithMaxInFlightBatches(s.Scanner.MaxInFlightBatches).
+		WithHandlerErrorPolicy(s.Handler.errorPolicy())
+	if chainID, ok := s.Scanner.Chain(); ok {
+		config = config.WithChainID(chainID)
+	}
+	if s.Scanner.ScriptTimeout > 0 {
+		config = config.WithScriptTimeout(time.Duration(s.Scanner.ScriptTimeout), config.ScriptTimeoutRetries)
+	}
+	if s.Scanner.StatusServerAddr != "" {
+		config = config.WithStatusServer(s.Scanner.StatusServerAddr)
+	}
+	if s.Scanner.Schedule != "" {
+		config = config.WithSchedule(s.Scanner.Schedule)
+	}
+	if reconciliation, ok := s.Scanner.reconciliation(); ok {
+		config = config.WithResultReconciliation(reconciliation)
+	}
+	if s.Scanner.ResultSpillDir != "" {
+		config = config.WithResultSpillDir(s.path(s.Scanner.ResultSpillDir))
+	}
+	if s.Scanner.DurableQueuePath != "" {
+		config = config.WithDurableQueue(s.path(s.Scanner.DurableQueuePath))
+	}
+	if s.Metrics.Addr != "" {
+		reporterConfig := status.DefaultPrometheusReporterConfig()
+		reporterConfig.Addr = s.Metrics.Addr
+		reporterConfig.Namespace = s.Metrics.Namespace
+		config = config.WithStatusReporter(status.NewPrometheusReporter(reporterConfig, logger))
+	}
+	if s.Scanner.ScriptPath != "" {
+		script, err := os.ReadFile(s.path(s.Scanner.ScriptPath))
+		if err != nil {
+			return scanner.Config{}, fmt.Errorf("failed to read the script: %w", err)
+		}
+		config = config.WithScript(script)
+	}
+	if s.Scanner.AccountBalanceMode {
+		config = config.WithAccountBalanceMode()
+	}
+	if s.Scanner.AccountKeysMode {
+		config = config.WithAccountKeysMode()
+	}
+	if s.Scanner.ResultType != "" {
+		config = config.WithExpectedResultTypeString("", s.Scanner.ResultType)
+	}
+
+	err := config.Validate()
+	if err != nil {
+		return scanner.Config{}, err
+	}
+	return config, nil
+}
+
+// NewHandler creates the handler of the output. The closer closes the output file or database.
+// Without an output, the results are ignored.
+func (s Settings) NewHandler(ctx context.Context, logger zerolog.Logger) (scanner.ScriptResultHandler, io.Closer, error) {
+	switch s.Handler.Output {
+	case OutputPostgres:
+		config := postgres.DefaultConfig()
+		config.Table = s.Handler.Table
+		handler, err := postgres.Open(ctx, s.Handler.DSN, config, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		return handler, handler, nil
+	case OutputJSONLines:
+		file, err := handlers.NewRotatingFile(s.path(s.Handler.Path), s.Handler.MaxFileBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		var options []handlers.JSONLinesOption
+		if s.Handler.PerAddress {
+			options = append(options, handlers.WithPerAddressRecords())
+		}
+		return handlers.NewJSONLinesHandler(file, options...), file, nil
+	default:
+		return scanner.NoOpScriptResultHandler{}, io.NopCloser(nil), nil
+	}
+}
+
+func (s HandlerSettings) errorPolicy() scanner.HandlerErrorPolicy {
+	switch s.ErrorPolicy {
+	case ErrorPolicyRetry:
+		return scanner.RetryHandlerErrors(s.Retries, time.Duration(s.RetryBackoff))
+	case ErrorPolicyLog:
+		return scanner.LogHandlerErrors()
+	default:
+		return scanner.DefaultHandlerErrorPolicy()
+	}
+}
+
+// path resolves a path of the settings relative to the directory of the file.
+func (s Settings) path(path string) string {
+	if filepath.IsAbs(path) || s.dir == "" {
+		return path
+	}
+	return filepath.Join(s.dir, path)
+}
+
+// WithinDir resolves the relative paths of the settings against baseDir, and fails
+// if a path of the settings is outside of baseDir: handler.path, scanner.script_path,
+// scanner.result_spill_dir and scanner.durable_queue_path.
+// The paths are checked lexically, symbolic links within baseDir are followed by the scan.
+func (s Settings) WithinDir(baseDir string) (Settings, error) {
+	base, err := filepath.Abs(baseDir)
+	if err != nil {
+		return Settings{}, fmt.Errorf("invalid base directory %q: %w", baseDir, err)
+	}
+	s.dir = base
+
+	var errs []error
+	for _, path := range []struct{ name, path string }{
+		{"handler.path", s.Handler.Path},
+		{"scanner.script_path", s.Scanner.ScriptPath},
+		{"scanner.result_spill_dir", s.Scanner.ResultSpillDir},
+		{"scanner.durable_queue_path", s.Scanner.DurableQueuePath},
+	} {
+		if path.path == "" {
+			continue
+		}
+		rel, err := filepath.Rel(base, filepath.Clean(s.path(path.path)))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			errs = append(errs, fmt.Errorf("%s: %q is outside of %s", path.name, path.path, base))
+		}
+	}
+	if len(errs) > 0 {
+		return Settings{}, &scanner.InvalidConfigError{Errors: errs}
+	}
+	return s, nil
+}
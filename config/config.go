@@ -0,0 +1,367 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config loads the settings of a scan from a YAML or TOML file, overridden by environment variables,
+// so a deployment can be reconfigured without recompiling:
+//
+//	client:
+//	  access_nodes: [access.mainnet.nodes.onflow.org:9000]
+//	scanner:
+//	  chain_id: mainnet
+//	  batch_size: 500
+//	  script_path: scripts/balances.cdc
+//	  incremental_scan_interval: 5s
+//	handler:
+//	  output: jsonlines
+//	  path: results.jsonl
+//
+// Every setting can be overridden with an environment variable named after its section and key,
+// e.g. FLOW_BATCH_SCAN_SCANNER_BATCH_SIZE=1000 or FLOW_BATCH_SCAN_CLIENT_ACCESS_NODES=node-1:9000,node-2:9000.
+// Settings that are not set keep the defaults of scanner.DefaultConfig.
+//
+// A Reloader applies the settings that can change without a restart to a running scan.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/handlers/postgres"
+)
+
+// EnvPrefix is the prefix of the environment variables that override the settings.
+const EnvPrefix = "FLOW_BATCH_SCAN_"
+
+// The formats of the settings, see Parse.
+const (
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// Duration is a time.Duration that is written as a string in the files, e.g. "5s" or "1m30s".
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// Settings are the settings of a scan.
+type Settings struct {
+	Client  ClientSettings  `yaml:"client" toml:"client"`
+	Scanner ScannerSettings `yaml:"scanner" toml:"scanner"`
+	Handler HandlerSettings `yaml:"handler" toml:"handler"`
+	Metrics MetricsSettings `yaml:"metrics" toml:"metrics"`
+
+	// dir is the directory of the file, relative paths in the settings are relative to it.
+	dir string
+}
+
+type ClientSettings struct {
+	// AccessNodes are the access nodes to connect to. With multiple access nodes the client fails over between them.
+	AccessNodes []string `yaml:"access_nodes" toml:"access_nodes"`
+	// RateLimit if set, is the default number of requests per second of the client.
+	// The methods with specific rate limits keep them, see client.WithRateLimits.
+	RateLimit int `yaml:"rate_limit" toml:"rate_limit"`
+	// MaxConcurrentRequests if set, is the number of requests in flight to each access node,
+	// shared by all the components of the scan. See client.WithMaxConcurrentRequests.
+	MaxConcurrentRequests int `yaml:"max_concurrent_requests" toml:"max_concurrent_requests"`
+	// BlockHeaderPrefetch if set, is the number of block headers after a requested height that are fetched ahead,
+	// and cached for BlockHeaderCacheTTL. See client.WithBlockHeaderPrefetch.
+	BlockHeaderPrefetch int `yaml:"block_header_prefetch" toml:"block_header_prefetch"`
+	// BlockHeaderCacheTTL if set, is how long the block headers are cached, instead of the default of the client.
+	BlockHeaderCacheTTL Duration `yaml:"block_header_cache_ttl" toml:"block_header_cache_ttl"`
+	// BearerToken if set, is sent as the authorization header of every request.
+	BearerToken string `yaml:"bearer_token" toml:"bearer_token"`
+}
+
+type ScannerSettings struct {
+	// ChainID is the chain, e.g. mainnet or flow-mainnet.
+	// If it is empty, the chain is detected from the access node.
+	ChainID string `yaml:"chain_id" toml:"chain_id"`
+	// ScriptPath is the path of the Cadence script, the default script of the scanner is used if it is empty.
+	ScriptPath string `yaml:"script_path" toml:"script_path"`
+	// AccountBalanceMode if true, scans the FLOW balances with the account API instead of a script.
+	// See scanner.Config.WithAccountBalanceMode.
+	AccountBalanceMode bool `yaml:"account_balance_mode" toml:"account_balance_mode"`
+	// AccountKeysMode if true, scans the keys with the account API instead of a script.
+	// See scanner.Config.WithAccountKeysMode.
+	AccountKeysMode bool `yaml:"account_keys_mode" toml:"account_keys_mode"`
+	// ResultType if set, is the Cadence type the results of the script must have, e.g. `{Address: UFix64}`.
+	// See scanner.Config.WithExpectedResultType.
+	ResultType                    string   `yaml:"result_type" toml:"result_type"`
+	BatchSize                     int      `yaml:"batch_size" toml:"batch_size"`
+	MaxConcurrentScripts          int      `yaml:"max_concurrent_scripts" toml:"max_concurrent_scripts"`
+	ScriptTimeout                 Duration `yaml:"script_timeout" toml:"script_timeout"`
+	ContinuousScan                bool     `yaml:"continuous_scan" toml:"continuous_scan"`
+	IncrementalScanInterval       Duration `yaml:"incremental_scan_interval" toml:"incremental_scan_interval"`
+	IncrementalScannerBlockLag    uint64   `yaml:"incremental_scanner_block_lag" toml:"incremental_scanner_block_lag"`
+	IncrementalScannerMaxBlockGap uint64   `yaml:"incremental_scanner_max_block_gap" toml:"incremental_scanner_max_block_gap"`
+	FullScanInterval              Duration `yaml:"full_scan_interval" toml:"full_scan_interval"`
+	FullScanCheckpointInterval    Duration `yaml:"full_scan_checkpoint_interval" toml:"full_scan_checkpoint_interval"`
+	StatusServerAddr              string   `yaml:"status_server_addr" toml:"status_server_addr"`
+	// LogLevel if set, is the level of the scan logger, e.g. debug or info.
+	LogLevel string `yaml:"log_level" toml:"log_level"`
+	// Schedule if set, is a cron expression: a fresh scan runs at every time of the schedule. See scanner.Config.WithSchedule.
+	Schedule string `yaml:"schedule" toml:"schedule"`
+	// MaxInFlightBatches if set, bounds the results held in memory. See scanner.Config.WithMaxInFlightBatches.
+	MaxInFlightBatches int `yaml:"max_in_flight_batches" toml:"max_in_flight_batches"`
+	// ResultSpillDir if set, is the directory the results that exceed MaxInFlightBatches are spilled to.
+	ResultSpillDir string `yaml:"result_spill_dir" toml:"result_spill_dir"`
+	// DurableQueuePath if set, is the directory of the durable queue of the address batches. See scanner.Config.WithDurableQueue.
+	DurableQueuePath string `yaml:"durable_queue_path" toml:"durable_queue_path"`
+	// ResultReconciliation is what happens when an address is scanned more than once in a scan:
+	// none, highest_height or first. See scanner.ResultReconciliation.
+	ResultReconciliation string `yaml:"result_reconciliation" toml:"result_reconciliation"`
+}
+
+// The result reconciliations of ScannerSettings, see scanner.ResultReconciliation.
+const (
+	ReconcileNone          = "none"
+	ReconcileHighestHeight = "highest_height"
+	ReconcileFirst         = "first"
+)
+
+// The outputs of HandlerSettings.
+const (
+	OutputNone      = ""
+	OutputJSONLines = "jsonlines"
+	OutputPostgres  = "postgres"
+)
+
+// The error policies of HandlerSettings, see scanner.HandlerErrorPolicy.
+const (
+	ErrorPolicyFail  = "fail"
+	ErrorPolicyRetry = "retry"
+	ErrorPolicyLog   = "log"
+)
+
+type HandlerSettings struct {
+	// Output is where the results are written to: jsonlines, postgres, or nothing if it is empty.
+	Output string `yaml:"output" toml:"output"`
+	// Path is the file the results are written to by the jsonlines output.
+	Path string `yaml:"path" toml:"path"`
+	// DSN is the connection string of the database of the postgres output, see github.com/lib/pq.
+	DSN string `yaml:"dsn" toml:"dsn"`
+	// Table is the results table of the postgres output. See postgres.Config.
+	Table string `yaml:"table" toml:"table"`
+	// MaxFileBytes if set, rotates the file once it reaches this size. See handlers.RotatingFile.
+	MaxFileBytes int64 `yaml:"max_file_bytes" toml:"max_file_bytes"`
+	// PerAddress writes a record per address instead of per batch.
+	PerAddress bool `yaml:"per_address" toml:"per_address"`
+	// ErrorPolicy is what happens when writing the results fails: fail, retry or log.
+	ErrorPolicy  string   `yaml:"error_policy" toml:"error_policy"`
+	Retries      int      `yaml:"retries" toml:"retries"`
+	RetryBackoff Duration `yaml:"retry_backoff" toml:"retry_backoff"`
+}
+
+type MetricsSettings struct {
+	// Addr if set, serves the Prometheus metrics of the scan on /metrics, e.g. ":9090". See status.PrometheusReporter.
+	Addr      string `yaml:"addr" toml:"addr"`
+	Namespace string `yaml:"namespace" toml:"namespace"`
+}
+
+// Defaults are the settings that match scanner.DefaultConfig.
+func Defaults() Settings {
+	config := scanner.DefaultConfig()
+	return Settings{
+		Scanner: ScannerSettings{
+			ChainID:                       config.ChainID.String(),
+			BatchSize:                     config.BatchSize,
+			MaxConcurrentScripts:          config.MaxConcurrentScripts,
+			ScriptTimeout:                 Duration(config.ScriptTimeout),
+			ContinuousScan:                config.ContinuousScan,
+			IncrementalScanInterval:       Duration(config.IncrementalScanInterval),
+			IncrementalScannerBlockLag:    config.IncrementalScannerBlockLag,
+			IncrementalScannerMaxBlockGap: config.IncrementalScannerMaxBlockGap,
+			FullScanInterval:              Duration(config.FullScanInterval),
+			FullScanCheckpointInterval:    Duration(config.FullScanCheckpointInterval),
+			StatusServerAddr:              config.StatusServerAddr,
+		},
+		Handler: HandlerSettings{
+			Table:        postgres.DefaultConfig().Table,
+			ErrorPolicy:  ErrorPolicyFail,
+			Retries:      config.HandlerErrorPolicy.Retries,
+			RetryBackoff: Duration(config.HandlerErrorPolicy.RetryBackoff),
+		},
+	}
+}
+
+// Load reads the settings from the file at path, overrides them with the environment variables and validates them.
+// The format of the file is chosen by its extension: .yaml, .yml or .toml.
+// If path is empty, only the defaults and the environment variables are used.
+func Load(path string) (Settings, error) {
+	return LoadWithEnv(path, os.LookupEnv)
+}
+
+// LoadWithEnv is like Load, but looks up the environment variables with lookupEnv.
+func LoadWithEnv(path string, lookupEnv func(key string) (string, bool)) (Settings, error) {
+	settings := Defaults()
+	if path != "" {
+		err := settings.readFile(path)
+		if err != nil {
+			return Settings{}, err
+		}
+	}
+	err := settings.applyEnv(lookupEnv)
+	if err != nil {
+		return Settings{}, err
+	}
+	err = settings.Validate()
+	if err != nil {
+		return Settings{}, err
+	}
+	return settings, nil
+}
+
+// Parse reads the settings from data in the format (yaml or toml), and validates them.
+// Unlike Load, the environment variables are not applied, and relative paths are relative to the working directory.
+func Parse(data []byte, format string) (Settings, error) {
+	settings := Defaults()
+	err := settings.decode(data, format)
+	if err != nil {
+		return Settings{}, fmt.Errorf("invalid config: %w", err)
+	}
+	err = settings.Validate()
+	if err != nil {
+		return Settings{}, err
+	}
+	return settings, nil
+}
+
+func (s *Settings) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the config file: %w", err)
+	}
+	s.dir = filepath.Dir(path)
+
+	var format string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		format = FormatYAML
+	case ".toml":
+		format = FormatTOML
+	default:
+		return fmt.Errorf("unknown config file format %q, expected .yaml, .yml or .toml", ext)
+	}
+	err = s.decode(data, format)
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// decode reads the settings in the format from data. Unknown keys are an error.
+func (s *Settings) decode(data []byte, format string) error {
+	switch format {
+	case FormatYAML:
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err := decoder.Decode(s)
+		if errors.Is(err, io.EOF) {
+			// the file is empty
+			return nil
+		}
+		return err
+	case FormatTOML:
+		return toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields().Decode(s)
+	default:
+		return fmt.Errorf("unknown config format %q, expected %s or %s", format, FormatYAML, FormatTOML)
+	}
+}
+
+// applyEnv overrides the settings with the environment variables <EnvPrefix><SECTION>_<KEY>.
+func (s *Settings) applyEnv(lookupEnv func(key string) (string, bool)) error {
+	sections := reflect.ValueOf(s).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		sectionField := sections.Type().Field(i)
+		if !sectionField.IsExported() {
+			continue
+		}
+		section := sections.Field(i)
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Type().Field(j)
+			key := EnvPrefix + strings.ToUpper(sectionField.Tag.Get("yaml")+"_"+field.Tag.Get("yaml"))
+			value, ok := lookupEnv(key)
+			if !ok {
+				continue
+			}
+			err := setValue(section.Field(j), value)
+			if err != nil {
+				return fmt.Errorf("invalid environment variable %s=%q: %w", key, value, err)
+			}
+		}
+	}
+	return nil
+}
+
+func setValue(field reflect.Value, value string) error {
+	if unmarshaler, ok := field.Addr().Interface().(interface{ UnmarshalText([]byte) error }); ok {
+		return unmarshaler.UnmarshalText([]byte(value))
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Slice:
+		var values []string
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/client/interceptors"
+	"github.com/onflow/flow-batch-scan/config"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func env(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+func TestLoad(t *testing.T) {
+	yamlPath := writeFile(t, "scan.yaml", `
+client:
+  access_nodes: [node-1:9000]
+scanner:
+  chain_id: mainnet
+  batch_size: 500
+  script_path: script.cdc
+  incremental_scan_interval: 5s
+handler:
+  output: jsonlines
+  path: results.jsonl
+`)
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(yamlPath), "script.cdc"), []byte("pub fun main() {}"), 0o644))
+	tomlPath := writeFile(t, "scan.toml", `
+[client]
+access_nodes = ["node-1:9000"]
+
+[scanner]
+chain_id = "mainnet"
+batch_size = 500
+script_path = "`+filepath.Join(filepath.Dir(yamlPath), "script.cdc")+`"
+incremental_scan_interval = "5s"
+
+[handler]
+output = "jsonlines"
+path = "results.jsonl"
+`)
+
+	for _, path := range []string{yamlPath, tomlPath} {
+		settings, err := config.LoadWithEnv(path, env(map[string]string{
+			"FLOW_BATCH_SCAN_CLIENT_ACCESS_NODES":  "node-2:9000, node-3:9000",
+			"FLOW_BATCH_SCAN_SCANNER_BATCH_SIZE":   "250",
+			"FLOW_BATCH_SCAN_HANDLER_ERROR_POLICY": "log",
+		}))
+		require.NoError(t, err, path)
+		require.Equal(t, []string{"node-2:9000", "node-3:9000"}, settings.Client.AccessNodes)
+		require.Equal(t, 250, settings.Scanner.BatchSize)
+		require.Equal(t, config.Duration(5*time.Second), settings.Scanner.IncrementalScanInterval)
+		// not set in the file, so the default
+		require.Equal(t, scanner.DefaultScriptRunnerMaxConcurrentScripts, settings.Scanner.MaxConcurrentScripts)
+
+		scanConfig, err := settings.ScannerConfig(zerolog.Nop())
+		require.NoError(t, err)
+		require.Equal(t, flow.Mainnet, scanConfig.ChainID)
+		require.Equal(t, 250, scanConfig.BatchSize)
+		require.Equal(t, []byte("pub fun main() {}"), scanConfig.Script)
+		require.Equal(t, scanner.HandlerErrorLogAndContinue, scanConfig.HandlerErrorPolicy.Action)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	_, err := config.LoadWithEnv(writeFile(t, "scan.yaml", "scanner:\n  batch_sise: 10\n"), env(nil))
+	require.ErrorContains(t, err, "batch_sise")
+
+	_, err = config.LoadWithEnv("", env(map[string]string{"FLOW_BATCH_SCAN_SCANNER_BATCH_SIZE": "many"}))
+	require.ErrorContains(t, err, "FLOW_BATCH_SCAN_SCANNER_BATCH_SIZE")
+
+	_, err = config.LoadWithEnv(writeFile(t, "scan.yaml", `
+scanner:
+  chain_id: moonnet
+  batch_size: 0
+handler:
+  output: jsonlines
+`), env(nil))
+	var invalid *scanner.InvalidConfigError
+	require.True(t, errors.As(err, &invalid))
+	require.Len(t, invalid.Errors, 4)
+	require.ErrorContains(t, err, "client.access_nodes")
+	require.ErrorContains(t, err, `unknown chain "moonnet"`)
+	require.ErrorContains(t, err, "scanner.batch_size must be positive")
+	require.ErrorContains(t, err, "handler.path is required")
+}
+
+func TestReloader(t *testing.T) {
+	settingsFile := func(batchSize int, logLevel string, statusServer string) string {
+		return fmt.Sprintf(`
+client:
+  access_nodes: [node-1:9000]
+scanner:
+  batch_size: %d
+  log_level: %s
+  status_server_addr: %q
+`, batchSize, logLevel, statusServer)
+	}
+	path := writeFile(t, "scan.yaml", settingsFile(500, "info", ""))
+	settings, err := config.Load(path)
+	require.NoError(t, err)
+
+	logs := &bytes.Buffer{}
+	scanConfig, err := settings.ScannerConfig(zerolog.New(logs))
+	require.NoError(t, err)
+	scan, err := scanner.NewScanner(nil, scanConfig.WithScript([]byte("pub fun main() {}")))
+	require.NoError(t, err)
+
+	reloader := config.NewReloader(path, settings, scan, interceptors.NewRateLimitReloader(), zerolog.New(logs))
+	logger := scan.Config().Logger
+	logger.Debug().Msg("before reload")
+
+	require.NoError(t, os.WriteFile(path, []byte(settingsFile(1000, "debug", ":8080")), 0o644))
+	require.NoError(t, reloader.Reload())
+	logger.Debug().Msg("after reload")
+
+	require.NotContains(t, logs.String(), "before reload")
+	require.Contains(t, logs.String(), "after reload")
+	require.Contains(t, logs.String(), `"batch_size":1000`)
+	require.Contains(t, logs.String(), "they change after a restart")
+
+	// invalid settings are not applied
+	require.NoError(t, os.WriteFile(path, []byte(settingsFile(0, "debug", ":8080")), 0o644))
+	require.Error(t, reloader.Reload())
+}
+
+func TestLoad_PostgresAndMetrics(t *testing.T) {
+	settings, err := config.LoadWithEnv(writeFile(t, "scan.yaml", `
+client:
+  access_nodes: [node-1:9000]
+handler:
+  output: postgres
+metrics:
+  addr: ":0"
+  namespace: scan
+`), env(nil))
+	require.ErrorContains(t, err, "handler.dsn is required")
+
+	settings, err = config.LoadWithEnv(writeFile(t, "scan.yaml", `
+client:
+  access_nodes: [node-1:9000]
+handler:
+  output: postgres
+  dsn: postgres://localhost/scan
+metrics:
+  addr: ":0"
+  namespace: scan
+`), env(nil))
+	require.NoError(t, err)
+	require.Equal(t, "scan_results", settings.Handler.Table)
+
+	scanConfig, err := settings.ScannerConfig(zerolog.Nop())
+	require.NoError(t, err)
+	require.NotNil(t, scanConfig.Reporter)
+}
+
+func TestSettings_WithinDir(t *testing.T) {
+	base := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(base, "script.cdc"), []byte("pub fun main() {}"), 0o644))
+
+	settings, err := config.Parse([]byte(`
+client:
+  access_nodes: [node-1:9000]
+scanner:
+  script_path: script.cdc
+  max_in_flight_batches: 10
+  result_spill_dir: spill
+handler:
+  output: jsonlines
+  path: out/results.jsonl
+`), config.FormatYAML)
+	require.NoError(t, err)
+	settings, err = settings.WithinDir(base)
+	require.NoError(t, err)
+	_, err = settings.ScannerConfig(zerolog.Nop())
+	require.NoError(t, err, "the script is read relative to the base directory")
+
+	settings, err = config.Parse([]byte(`
+client:
+  access_nodes: [node-1:9000]
+scanner:
+  script_path: /etc/passwd
+  durable_queue_path: ../queue
+handler:
+  output: jsonlines
+  path: out/../../results.jsonl
+`), config.FormatYAML)
+	require.NoError(t, err)
+	_, err = settings.WithinDir(base)
+	var invalid *scanner.InvalidConfigError
+	require.True(t, errors.As(err, &invalid))
+	require.Len(t, invalid.Errors, 3)
+	require.ErrorContains(t, err, "handler.path")
+	require.ErrorContains(t, err, "scanner.script_path")
+	require.ErrorContains(t, err, "scanner.durable_queue_path")
+}
@@ -0,0 +1,215 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/client/interceptors"
+)
+
+// reloadDebounce is how long the Reloader waits for more changes of the file, before it reloads it.
+// Editors often write a file in multiple steps.
+const reloadDebounce = 100 * time.Millisecond
+
+// Reloader applies the settings that can change without a restart to a running scan,
+// when the process receives SIGHUP, or when the file changes if Watch is set:
+// client.rate_limit, scanner.batch_size, scanner.incremental_scan_interval and scanner.log_level.
+// Changes to the other settings are logged, and only apply after a restart.
+type Reloader struct {
+	// Watch also reloads the settings when the file changes.
+	Watch bool
+
+	path       string
+	scan       *scanner.Scanner
+	rateLimits *interceptors.RateLimitReloader
+	logger     zerolog.Logger
+
+	mu       sync.Mutex
+	settings Settings
+}
+
+// NewReloader reloads the settings at path, that were loaded as settings, into the scan.
+// The rate limits are reloaded if the client of the scan was created with client.WithRateLimitReloader(rateLimits),
+// rateLimits can be nil otherwise.
+func NewReloader(
+	path string,
+	settings Settings,
+	scan *scanner.Scanner,
+	rateLimits *interceptors.RateLimitReloader,
+	logger zerolog.Logger,
+) *Reloader {
+	return &Reloader{
+		path:       path,
+		settings:   settings,
+		scan:       scan,
+		rateLimits: rateLimits,
+		logger:     logger.With().Str("component", "config_reloader").Logger(),
+	}
+}
+
+// Run reloads the settings on SIGHUP and, if Watch is set, when the file changes, until the context is done.
+// Settings that fail to load are logged, and the previous settings are kept.
+func (r *Reloader) Run(ctx context.Context) error {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var changed <-chan struct{}
+	if r.Watch {
+		c, err := r.watch(ctx)
+		if err != nil {
+			return err
+		}
+		changed = c
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hangup:
+		case <-changed:
+		}
+		err := r.Reload()
+		if err != nil {
+			r.logger.Error().
+				Err(err).
+				Str("path", r.path).
+				Msg("Failed to reload the settings, keeping the previous settings")
+		}
+	}
+}
+
+// Reload loads the settings, and applies the ones that changed to the scan.
+// If the settings are not valid none of them are applied.
+func (r *Reloader) Reload() error {
+	settings, err := Load(r.path)
+	if err != nil {
+		return err
+	}
+	level, err := settings.Scanner.logLevel()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous := r.settings
+
+	if settings.Client.RateLimit != previous.Client.RateLimit {
+		if r.rateLimits == nil {
+			r.logger.Warn().Msg("The rate limit can not be reloaded without a rate limit reloader, it changes after a restart")
+		} else {
+			rate := settings.Client.RateLimit
+			if rate == 0 {
+				rate = client.DefaultConfig().DefaultRateLimit
+			}
+			r.rateLimits.Reload(interceptors.RateLimit{Rate: rate}, nil)
+		}
+	}
+	if settings.Scanner.BatchSize != previous.Scanner.BatchSize {
+		err := r.scan.SetBatchSize(settings.Scanner.BatchSize)
+		if err != nil {
+			return err
+		}
+	}
+	if settings.Scanner.IncrementalScanInterval != previous.Scanner.IncrementalScanInterval {
+		err := r.scan.SetIncrementalScanInterval(time.Duration(settings.Scanner.IncrementalScanInterval))
+		if err != nil {
+			return err
+		}
+	}
+	if settings.Scanner.LogLevel != previous.Scanner.LogLevel && level != zerolog.NoLevel {
+		r.scan.SetLogLevel(level)
+	}
+
+	if !reflect.DeepEqual(restartSettings(settings), restartSettings(previous)) {
+		r.logger.Warn().Msg("Settings changed that can not be reloaded, they change after a restart")
+	}
+	r.settings = settings
+	return nil
+}
+
+// restartSettings are the settings without the ones a Reloader can change.
+func restartSettings(s Settings) Settings {
+	s.Client.RateLimit = 0
+	s.Scanner.BatchSize = 0
+	s.Scanner.IncrementalScanInterval = 0
+	s.Scanner.LogLevel = ""
+	return s
+}
+
+// watch sends on the returned channel after the file changed.
+// The directory is watched, because editors often replace the file instead of writing to it.
+func (r *Reloader) watch(ctx context.Context) (<-chan struct{}, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch the config file: %w", err)
+	}
+	err = watcher.Add(filepath.Dir(r.path))
+	if err != nil {
+		_ = watcher.Close()
+		return nil, fmt.Errorf("failed to watch the config file: %w", err)
+	}
+
+	path := filepath.Clean(r.path)
+	changed := make(chan struct{})
+	go func() {
+		defer func() {
+			_ = watcher.Close()
+		}()
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == path && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					debounce = time.After(reloadDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				r.logger.Warn().Err(err).Msg("Watching the config file failed")
+			case <-debounce:
+				debounce = nil
+				select {
+				case <-ctx.Done():
+					return
+				case changed <- struct{}{}:
+				}
+			}
+		}
+	}()
+	return changed, nil
+}
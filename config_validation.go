@@ -0,0 +1,221 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/robfig/cron/v3"
+)
+
+// InvalidConfigError is returned by Config.Validate. It lists every problem of the config.
+type InvalidConfigError struct {
+	Errors []error
+}
+
+var _ error = (*InvalidConfigError)(nil)
+
+func (e *InvalidConfigError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		lines[i] = "\n  - " + err.Error()
+	}
+	return fmt.Sprintf("invalid scanner config (%d problems):%s", len(e.Errors), strings.Join(lines, ""))
+}
+
+// validChainIDs are the chains addresses can be generated for.
+var validChainIDs = map[flow.ChainID]struct{}{
+	flow.Mainnet:    {},
+	flow.Testnet:    {},
+	flow.Sandboxnet: {},
+	flow.Emulator:   {},
+	flow.Localnet:   {},
+	flow.Benchnet:   {},
+	flow.BftTestnet: {},
+}
+
+// Validate checks the config for values that can not work, including the values rejected by the With* builders.
+// It returns an *InvalidConfigError with all the problems, or nil if the config is valid.
+// NewScanner calls it.
+func (c Config) Validate() error {
+	errs := append([]error(nil), c.builderErrors...)
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.BatchSize > 0, "BatchSize must be positive, got %d", c.BatchSize)
+	if c.AdaptiveBatchSize {
+		check(c.MinBatchSize > 0 && c.MinBatchSize <= c.BatchSize,
+			"MinBatchSize must be between 1 and BatchSize (%d) with AdaptiveBatchSize, got %d", c.BatchSize, c.MinBatchSize)
+	}
+	check(c.AddressBatchQueueSize >= 0, "AddressBatchQueueSize must not be negative, got %d", c.AddressBatchQueueSize)
+	check(c.ScriptResultQueueSize >= 0, "ScriptResultQueueSize must not be negative, got %d", c.ScriptResultQueueSize)
+	check(c.FullScanRequestQueueSize >= 0, "FullScanRequestQueueSize must not be negative, got %d", c.FullScanRequestQueueSize)
+	check(c.MaxInFlightBatches >= 0, "MaxInFlightBatches must not be negative, got %d", c.MaxInFlightBatches)
+	check(c.ResultSpillDir == "" || c.MaxInFlightBatches > 0,
+		"ResultSpillDir requires MaxInFlightBatches, results are only spilled once the limit is reached")
+
+	// scripts
+	if !c.DryRun {
+		check(len(c.Script) > 0 || len(c.Scripts) > 0, "no script to run: set Script or Scripts (or use DryRun)")
+	}
+	for name, script := range c.Scripts {
+		check(len(script) > 0, "script %q of Scripts is empty", name)
+	}
+	for i, upgrade := range c.ScriptUpgrades {
+		check(len(upgrade.Script) > 0, "ScriptUpgrades[%d].Script is empty", i)
+		_, ok := c.Scripts[upgrade.ScriptName]
+		check(upgrade.ScriptName == "" || ok,
+			"ScriptUpgrades[%d].ScriptName: unknown script %q of Scripts", i, upgrade.ScriptName)
+		check(i == 0 || c.ScriptUpgrades[i-1].Height <= upgrade.Height, "ScriptUpgrades must be ordered by height")
+	}
+	for name, typ := range c.ExpectedResultTypes {
+		check(typ != nil, "ExpectedResultTypes: the type of script %q is nil", name)
+		_, ok := c.Scripts[name]
+		check(name == "" || ok, "ExpectedResultTypes: unknown script %q of Scripts", name)
+	}
+	for name, size := range c.ScriptBatchSizes {
+		_, ok := c.Scripts[name]
+		check(ok, "ScriptBatchSizes: unknown script %q of Scripts", name)
+		check(size > 0 && size <= c.BatchSize,
+			"ScriptBatchSizes: the batch size of script %q must be positive and at most BatchSize %d, got %d", name, c.BatchSize, size)
+	}
+	check(c.MaxConcurrentScripts > 0, "MaxConcurrentScripts must be positive, got %d", c.MaxConcurrentScripts)
+	for i, window := range c.ConcurrencySchedule {
+		check(window.MaxConcurrentScripts > 0,
+			"ConcurrencySchedule[%d].MaxConcurrentScripts must be positive, got %d", i, window.MaxConcurrentScripts)
+	}
+	if c.AccountBalanceMode {
+		check(len(c.Scripts) == 0, "Scripts are not run with AccountBalanceMode")
+		check(!c.PerAddress, "PerAddress and AccountBalanceMode are mutually exclusive")
+		check(c.PerAddressConcurrency > 0,
+			"PerAddressConcurrency must be positive with AccountBalanceMode, got %d", c.PerAddressConcurrency)
+	}
+	if c.AccountKeysMode {
+		check(len(c.Scripts) == 0, "Scripts are not run with AccountKeysMode")
+		check(!c.PerAddress, "PerAddress and AccountKeysMode are mutually exclusive")
+		check(!c.AccountBalanceMode, "AccountBalanceMode and AccountKeysMode are mutually exclusive")
+		check(c.PerAddressConcurrency > 0,
+			"PerAddressConcurrency must be positive with AccountKeysMode, got %d", c.PerAddressConcurrency)
+	}
+	if c.PerAddress {
+		check(c.PerAddressConcurrency > 0, "PerAddressConcurrency must be positive with PerAddress, got %d", c.PerAddressConcurrency)
+	}
+	check(c.ScriptTimeout >= 0, "ScriptTimeout must not be negative, got %s", c.ScriptTimeout)
+	check(c.ScriptTimeoutRetries >= 0, "ScriptTimeoutRetries must not be negative, got %d", c.ScriptTimeoutRetries)
+	for class, policy := range c.ScriptErrorPolicies {
+		check(class != nil, "ScriptErrorPolicies: the error class is nil")
+		check(policy.valid(), "ScriptErrorPolicies: unknown policy %s of class %q", policy, class)
+	}
+	check(c.ScriptErrorRetries >= 0, "ScriptErrorRetries must not be negative, got %d", c.ScriptErrorRetries)
+	check(c.ScriptErrorRetryBackoff >= 0, "ScriptErrorRetryBackoff must not be negative, got %s", c.ScriptErrorRetryBackoff)
+	check(c.MaxFailedAddresses >= 0, "MaxFailedAddresses must not be negative, got %d", c.MaxFailedAddresses)
+
+	// handlers
+	check(c.ScriptResultHandler != nil, "ScriptResultHandler is nil: use NoOpScriptResultHandler to ignore the results")
+	for i, handler := range c.ScriptResultHandlers {
+		check(handler != nil, "ScriptResultHandlers[%d] is nil", i)
+	}
+	check(c.ResultReconciliation != ReconcileFirst || !c.ContinuousScan,
+		"ResultReconciliation ReconcileFirst remembers every address of the scan, it can not be combined with ContinuousScan")
+
+	// chain and addresses
+	_, validChain := validChainIDs[c.ChainID]
+	check(validChain || c.ChainID == "", "unknown ChainID %q, e.g. use flow.Mainnet or flow.Testnet", c.ChainID)
+	if validChain {
+		c.checkAddresses(check, "AddressFilter.Include", c.AddressFilter.Include)
+		c.checkAddresses(check, "AddressFilter.Exclude", c.AddressFilter.Exclude)
+	}
+	checkRanges(check, "AddressFilter.IncludeRanges", c.AddressFilter.IncludeRanges)
+	checkRanges(check, "AddressFilter.ExcludeRanges", c.AddressFilter.ExcludeRanges)
+
+	// full scans
+	check(c.SampleRate >= 0 && c.SampleRate <= 1, "SampleRate must be between 0 and 1, got %v", c.SampleRate)
+	check(c.ReferenceBlockHeight == 0 || !c.IncrementalOnly,
+		"ReferenceBlockHeight (a point in time full scan) and IncrementalOnly (no full scans) can not be combined")
+	check(!c.IncrementalOnly || c.IncrementalStartHeight > 0,
+		"IncrementalOnly needs the IncrementalStartHeight the caller has complete results at, got 0")
+	if throttle := c.FullScanThrottle; throttle != nil {
+		check(throttle.TargetLatency > 0, "FullScanThrottle.TargetLatency must be positive, got %s", throttle.TargetLatency)
+		check(throttle.MaxErrorRate >= 0 && throttle.MaxErrorRate <= 1,
+			"FullScanThrottle.MaxErrorRate must be between 0 and 1, got %v", throttle.MaxErrorRate)
+		check(throttle.InitialDelay > 0 && throttle.InitialDelay <= throttle.MaxDelay,
+			"FullScanThrottle.InitialDelay must be positive and at most MaxDelay (%s), got %s",
+			throttle.MaxDelay, throttle.InitialDelay)
+		check(throttle.Interval > 0, "FullScanThrottle.Interval must be positive, got %s", throttle.Interval)
+	}
+	if c.Partition.IsPartitioned() {
+		check(c.Partition.Index < c.Partition.Total,
+			"Partition.Index must be less than Partition.Total, got %d of %d", c.Partition.Index, c.Partition.Total)
+		check(c.ReferenceBlockHeight > 0,
+			"Partition needs a ReferenceBlockHeight, so all the partitions are scanned at the same block height")
+	}
+
+	// scheduled scans
+	if c.Schedule != "" {
+		_, err := cron.ParseStandard(c.Schedule)
+		check(err == nil, "invalid Schedule %q: %v", c.Schedule, err)
+		check(!c.ContinuousScan, "Schedule (a scan per scheduled time) and ContinuousScan can not be combined")
+		check(c.ReferenceBlockHeight == 0,
+			"Schedule (a fresh scan per scheduled time) and ReferenceBlockHeight (a point in time scan) can not be combined")
+	}
+
+	// incremental scans
+	check(c.IncrementalScanInterval > 0 || c.ReferenceBlockHeight > 0,
+		"IncrementalScanInterval must be positive, got %s", c.IncrementalScanInterval)
+	if c.CatchUpBlockRange > 0 {
+		check(c.CatchUpConcurrency > 0, "CatchUpConcurrency must be positive with CatchUpBlockRange, got %d", c.CatchUpConcurrency)
+	}
+	if c.IncrementalScannerMode == IncrementalScannerSubscription {
+		check(len(c.SubscriptionEvents) > 0, "SubscriptionEvents must be set in IncrementalScannerSubscription mode")
+	}
+	check(!c.ResumeFromProgress || c.ProgressStore != nil, "ResumeFromProgress needs a ProgressStore")
+	for i, scanner := range c.CandidateScanners {
+		check(scanner != nil, "CandidateScanners[%d] is nil", i)
+	}
+
+	if len(errs) > 0 {
+		return &InvalidConfigError{Errors: errs}
+	}
+	return nil
+}
+
+// checkAddresses checks that the addresses are addresses of the chain, to catch a mismatched ChainID.
+func (c Config) checkAddresses(check func(bool, string, ...any), name string, addresses []flow.Address) {
+	for _, address := range addresses {
+		address := address
+		check(address.IsValid(c.ChainID),
+			"%s: 0x%s is not an address of %s, is the ChainID right?", name, address.Hex(), c.ChainID)
+	}
+}
+
+func checkRanges(check func(bool, string, ...any), name string, ranges []AddressRange) {
+	for _, r := range ranges {
+		check(bytes.Compare(r.From[:], r.To[:]) <= 0,
+			"%s: the range from 0x%s to 0x%s is empty", name, r.From.Hex(), r.To.Hex())
+	}
+}
+
+// reject records a value rejected by a With* builder. The error is returned by Validate.
+func (c Config) reject(format string, args ...any) Config {
+	errs := c.builderErrors
+	c.builderErrors = append(errs[:len(errs):len(errs)], fmt.Errorf(format, args...))
+	return c
+}
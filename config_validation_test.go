@@ -0,0 +1,63 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	require.NoError(t, DefaultConfig().Validate())
+	require.NoError(t, DefaultConfig().
+		WithChainID(flow.Mainnet).
+		WithIncludeAddresses(flow.HexToAddress("1654653399040a61")).
+		Validate())
+
+	config := DefaultConfig().
+		WithChainID(flow.Testnet).
+		WithBatchSize(0).
+		WithScriptResultHandler(nil).
+		WithIncludeAddresses(flow.HexToAddress("1654653399040a61"))
+	config.Script = nil
+
+	err := config.Validate()
+	var invalid *InvalidConfigError
+	require.True(t, errors.As(err, &invalid))
+	require.Len(t, invalid.Errors, 4)
+	require.ErrorContains(t, err, "WithBatchSize: the batch size must be positive, got 0")
+	require.ErrorContains(t, err, "WithScriptResultHandler: the handler is nil")
+	require.ErrorContains(t, err, "no script to run")
+	require.ErrorContains(t, err, "0x1654653399040a61 is not an address of flow-testnet, is the ChainID right?")
+
+	// rejected values are not set
+	require.Equal(t, DefaultBatchSize, config.BatchSize)
+
+	_, err = NewScanner(nil, config)
+	require.ErrorAs(t, err, &invalid)
+
+	err = DefaultConfig().
+		WithContinuousScan(true).
+		WithResultReconciliation(ReconcileFirst).
+		Validate()
+	require.ErrorContains(t, err, "ReconcileFirst")
+
+	require.NoError(t, DefaultConfig().WithIncrementalOnly(100).Validate())
+	err = DefaultConfig().WithIncrementalOnly(0).Validate()
+	require.ErrorContains(t, err, "IncrementalOnly needs the IncrementalStartHeight")
+}
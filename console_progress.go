@@ -0,0 +1,176 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// consoleProgressInterval is how often the console progress is redrawn.
+const consoleProgressInterval = 500 * time.Millisecond
+
+// consoleProgressBarWidth is the number of characters of the progress bars.
+const consoleProgressBarWidth = 30
+
+// consoleProgress draws the progress of the scan to a terminal:
+// a progress bar of the running full scan, the incremental scanner,
+// the live throughput and the error counts.
+// It redraws its lines in place, so it should not share the terminal with the log output.
+type consoleProgress struct {
+	*ComponentBase
+
+	out      io.Writer
+	interval time.Duration
+	status   func() ScanStatus
+
+	// lines is the number of lines that were drawn last, and are redrawn in place.
+	lines int
+	// lastScanned and lastDraw are used to calculate the live throughput.
+	lastScanned uint64
+	lastDraw    time.Time
+}
+
+var _ Component = (*consoleProgress)(nil)
+
+func newConsoleProgress(
+	out io.Writer,
+	interval time.Duration,
+	status func() ScanStatus,
+	logger zerolog.Logger,
+) *consoleProgress {
+	c := &consoleProgress{
+		out:      out,
+		interval: interval,
+		status:   status,
+	}
+	c.ComponentBase = NewComponentWithStart(
+		"console_progress",
+		c.start,
+		logger,
+	)
+	return c
+}
+
+func (c *consoleProgress) start(ctx context.Context) {
+	c.lastDraw = time.Now()
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				c.draw(time.Now())
+				c.Finish(ctx.Err())
+				return
+			case <-ticker.C:
+				c.draw(time.Now())
+			}
+		}
+	}()
+}
+
+func (c *consoleProgress) draw(now time.Time) {
+	status := c.status()
+
+	addressesPerSecond := 0.0
+	if elapsed := now.Sub(c.lastDraw); elapsed > 0 && status.AddressesScanned >= c.lastScanned {
+		addressesPerSecond = float64(status.AddressesScanned-c.lastScanned) / elapsed.Seconds()
+	}
+	c.lastScanned = status.AddressesScanned
+	c.lastDraw = now
+
+	lines := renderConsoleProgress(status, addressesPerSecond)
+
+	b := &strings.Builder{}
+	if c.lines > 0 {
+		// move the cursor back to the first line that was drawn last
+		fmt.Fprintf(b, "\x1b[%dA", c.lines)
+	}
+	for _, line := range lines {
+		// clear the line before drawing it
+		fmt.Fprintf(b, "\x1b[2K%s\n", line)
+	}
+	c.lines = len(lines)
+
+	if _, err := io.WriteString(c.out, b.String()); err != nil {
+		c.Logger.Debug().
+			Err(err).
+			Msg("failed to draw console progress")
+	}
+}
+
+// renderConsoleProgress renders the lines of the console progress.
+func renderConsoleProgress(status ScanStatus, addressesPerSecond float64) []string {
+	lines := make([]string, 0, 4)
+
+	if status.FullScan != nil {
+		progress := *status.FullScan
+		eta := "-"
+		if progress.ETA > 0 {
+			eta = progress.ETA.Round(time.Second).String()
+		}
+		lines = append(lines, fmt.Sprintf("full scan    %s %5.1f%%  %d/%d addresses  ETA %s",
+			progressBar(progress.Fraction(), consoleProgressBarWidth),
+			progress.Fraction()*100,
+			progress.Scanned,
+			progress.Total,
+			eta,
+		))
+	} else {
+		lines = append(lines, "full scan    not running")
+	}
+
+	if status.IncrementalScanRunning {
+		state := "running"
+		if status.Paused {
+			state = "paused"
+		}
+		lines = append(lines, fmt.Sprintf("incremental  %s at block height %d", state, status.IncrementalBlockHeight))
+	}
+
+	lines = append(lines,
+		fmt.Sprintf("throughput   %.0f addresses/s  %d addresses in %d batches  elapsed %s",
+			addressesPerSecond,
+			status.AddressesScanned,
+			status.BatchesExecuted,
+			status.Uptime.Round(time.Second),
+		),
+		fmt.Sprintf("errors       %d script errors  %d retries  %d failed batches  %d handler errors",
+			status.ScriptErrors,
+			status.Retries,
+			status.FailedBatches,
+			status.HandlerErrors,
+		),
+	)
+	return lines
+}
+
+// progressBar renders a progress bar of the given width for a fraction from 0 to 1.
+func progressBar(fraction float64, width int) string {
+	if fraction < 0 {
+		fraction = 0
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	filled := int(fraction * float64(width))
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", width-filled) + "]"
+}
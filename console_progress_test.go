@@ -0,0 +1,63 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderConsoleProgress(t *testing.T) {
+	lines := renderConsoleProgress(ScanStatus{
+		IncrementalScanRunning: true,
+		IncrementalBlockHeight: 100,
+		FullScan: &FullScanProgress{
+			Scanned: 250,
+			Total:   1000,
+			ETA:     90 * time.Second,
+		},
+		AddressesScanned: 250,
+		BatchesExecuted:  5,
+		ScriptErrors:     2,
+	}, 12.3)
+
+	require.Len(t, lines, 4)
+	require.Contains(t, lines[0], "[#######-----------------------]  25.0%")
+	require.Contains(t, lines[0], "ETA 1m30s")
+	require.Contains(t, lines[1], "running at block height 100")
+	require.Contains(t, lines[2], "12 addresses/s")
+	require.Contains(t, lines[3], "2 script errors")
+}
+
+func TestConsoleProgress_Redraw(t *testing.T) {
+	out := &bytes.Buffer{}
+	c := newConsoleProgress(out, time.Second, func() ScanStatus {
+		return ScanStatus{}
+	}, zerolog.Nop())
+
+	now := time.Now()
+	c.lastDraw = now
+	c.draw(now.Add(time.Second))
+	require.False(t, strings.HasPrefix(out.String(), "\x1b[3A"))
+
+	out.Reset()
+	c.draw(now.Add(2 * time.Second))
+	require.True(t, strings.HasPrefix(out.String(), "\x1b[3A"))
+}
@@ -0,0 +1,264 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package contractdiff is a scan mode that compares the contracts of all accounts to the previous scan,
+// and handles only the contracts that were added, removed or changed since then, with a diff of their code.
+//
+// The code of the previous scan is kept in a Store, e.g. a DirStore, which keeps the code in
+// `A.<address>.<name>.cdc` files like the contract_names example:
+//
+//	store, err := contractdiff.NewDirStore("contracts")
+//	...
+//	differ := contractdiff.New(store, func(changes []contractdiff.Change, _ scanner.ProcessedAddressBatch) error {
+//		for _, change := range changes {
+//			fmt.Println(change.Diff)
+//		}
+//		return nil
+//	})
+//	s, err := scanner.NewScanner(flowClient, append(options, differ.Options(logger)...)...)
+//
+// The first scan with an empty store reports every contract as added.
+package contractdiff
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/rs/zerolog"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/candidates"
+	"github.com/onflow/flow-batch-scan/scripts"
+)
+
+// DiffContext is the number of unchanged lines around the changes in a diff.
+const DiffContext = 3
+
+type ChangeKind string
+
+const (
+	ContractAdded   ChangeKind = "added"
+	ContractRemoved ChangeKind = "removed"
+	ContractChanged ChangeKind = "changed"
+)
+
+// Change is a contract that was added, removed or changed since the previous scan.
+type Change struct {
+	Address flow.Address
+	Name    string
+	Kind    ChangeKind
+	// OldHash and NewHash are the hex encoded SHA-256 hashes of the code,
+	// OldHash is empty for added contracts, and NewHash for removed contracts.
+	OldHash string
+	NewHash string
+	// Diff is the unified diff from the old to the new code.
+	Diff string
+	// BlockHeight is the height the new code was scanned at.
+	BlockHeight uint64
+}
+
+// Differ is a scanner.ScriptResultHandler of the scripts.ContractCodes script.
+// It compares the code of the contracts of each batch to the store, passes the changes to its handler,
+// and updates the store once the changes were handled. The addresses of a batch without any changes
+// are not passed to the handler.
+type Differ struct {
+	store  Store
+	handle func(changes []Change, batch scanner.ProcessedAddressBatch) error
+
+	// mu serializes the batches, so the changes of an address are handled and stored in order.
+	mu sync.Mutex
+}
+
+var _ scanner.ScriptResultHandler = (*Differ)(nil)
+
+func New(
+	store Store,
+	handle func(changes []Change, batch scanner.ProcessedAddressBatch) error,
+) *Differ {
+	return &Differ{
+		store:  store,
+		handle: handle,
+	}
+}
+
+// Options are the options of the scan: the ContractCodes script, the differ as its result handler,
+// the candidate scanners for the accounts whose contracts were added, updated or removed,
+// and the reconciliation of the results of the addresses scanned more than once,
+// so an older result does not undo a newer one.
+func (d *Differ) Options(logger zerolog.Logger) []scanner.Option {
+	candidateScanners := make([]candidates.CandidateScanner, 0, 3)
+	for _, eventType := range []string{
+		flow.EventAccountContractAdded,
+		flow.EventAccountContractUpdated,
+		flow.EventAccountContractRemoved,
+	} {
+		candidateScanners = append(candidateScanners, candidates.NewEventCandidatesScanner(
+			eventType,
+			candidates.AddressFromEventField("address"),
+			logger,
+		))
+	}
+	return []scanner.Option{
+		scanner.WithScript(scripts.ContractCodes),
+		scanner.WithScriptResultHandler(d),
+		scanner.WithCandidateScanners(candidateScanners),
+		scanner.WithResultReconciliation(scanner.ReconcileHighestHeight),
+	}
+}
+
+func (d *Differ) Handle(batch scanner.ProcessedAddressBatch) error {
+	codes, err := scripts.DecodeContractCodes(batch.Result)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	addresses := make([]flow.Address, 0, len(codes))
+	for address := range codes {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i].Hex() < addresses[j].Hex()
+	})
+
+	var changes []Change
+	for _, address := range addresses {
+		accountChanges, err := d.diff(address, codes[address], batch.BlockHeight)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, accountChanges...)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	err = d.handle(changes, batch)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if change.Kind == ContractRemoved {
+			err = d.store.Remove(change.Address, change.Name)
+		} else {
+			err = d.store.Set(change.Address, change.Name, codes[change.Address][change.Name])
+		}
+		if err != nil {
+			return fmt.Errorf("could not store contract %s of %s: %w", change.Name, change.Address, err)
+		}
+	}
+	return nil
+}
+
+// diff compares the contracts of the address to the store, ordered by name.
+func (d *Differ) diff(address flow.Address, codes map[string]string, blockHeight uint64) ([]Change, error) {
+	stored, err := d.store.Hashes(address)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the stored contracts of %s: %w", address, err)
+	}
+
+	names := make([]string, 0, len(codes)+len(stored))
+	for name := range codes {
+		names = append(names, name)
+	}
+	for name := range stored {
+		if _, ok := codes[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []Change
+	for _, name := range names {
+		code, ok := codes[name]
+		oldHash, wasStored := stored[name]
+		change := Change{
+			Address:     address,
+			Name:        name,
+			OldHash:     oldHash,
+			BlockHeight: blockHeight,
+		}
+		var oldCode string
+		switch {
+		case !wasStored:
+			change.Kind = ContractAdded
+		case !ok:
+			change.Kind = ContractRemoved
+		default:
+			if Hash(code) == oldHash {
+				continue
+			}
+			change.Kind = ContractChanged
+		}
+		if ok {
+			change.NewHash = Hash(code)
+		}
+		if wasStored {
+			oldCode, err = d.store.Code(address, name)
+			if err != nil {
+				return nil, fmt.Errorf("could not get the stored contract %s of %s: %w", name, address, err)
+			}
+		}
+		change.Diff, err = Diff(address, name, oldCode, code)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// Hash is the hex encoded SHA-256 hash of the code.
+func Hash(code string) string {
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
+
+// Diff returns the unified diff from the old to the new code of the contract.
+func Diff(address flow.Address, name string, oldCode string, newCode string) (string, error) {
+	fileName := contractFileName(address, name)
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(oldCode),
+		FromFile: "a/" + fileName,
+		B:        splitLines(newCode),
+		ToFile:   "b/" + fileName,
+		Context:  DiffContext,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not diff contract %s of %s: %w", name, address, err)
+	}
+	return diff, nil
+}
+
+// splitLines splits the code into lines that keep their line endings, as expected by difflib.
+func splitLines(code string) []string {
+	if code == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(code, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] += "\n"
+	}
+	return lines
+}
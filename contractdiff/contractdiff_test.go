@@ -0,0 +1,115 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractdiff_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/contractdiff"
+)
+
+func codesBatch(height uint64, codes map[flow.Address]map[string]string) scanner.ProcessedAddressBatch {
+	pairs := make([]cadence.KeyValuePair, 0, len(codes))
+	for address, contracts := range codes {
+		contractPairs := make([]cadence.KeyValuePair, 0, len(contracts))
+		for name, code := range contracts {
+			contractPairs = append(contractPairs, cadence.KeyValuePair{Key: cadence.String(name), Value: cadence.String(code)})
+		}
+		pairs = append(pairs, cadence.KeyValuePair{Key: cadence.Address(address), Value: cadence.NewDictionary(contractPairs)})
+	}
+	return scanner.ProcessedAddressBatch{
+		AddressBatch: scanner.AddressBatch{BlockHeight: height},
+		Result:       cadence.NewDictionary(pairs),
+	}
+}
+
+func TestDiffer(t *testing.T) {
+	a1 := flow.HexToAddress("01")
+	a2 := flow.HexToAddress("02")
+	dir := t.TempDir()
+
+	var changes []contractdiff.Change
+	newDiffer := func() *contractdiff.Differ {
+		store, err := contractdiff.NewDirStore(dir)
+		require.NoError(t, err)
+		return contractdiff.New(store, func(batchChanges []contractdiff.Change, _ scanner.ProcessedAddressBatch) error {
+			changes = append(changes, batchChanges...)
+			return nil
+		})
+	}
+
+	// the first scan adds all contracts
+	differ := newDiffer()
+	require.NoError(t, differ.Handle(codesBatch(100, map[flow.Address]map[string]string{
+		a1: {"A": "pub contract A {\n}\n", "B": "pub contract B {}"},
+		a2: {},
+	})))
+	require.Len(t, changes, 2)
+	require.Equal(t, contractdiff.ContractAdded, changes[0].Kind)
+	require.Equal(t, "A", changes[0].Name)
+	require.Equal(t, contractdiff.Hash("pub contract A {\n}\n"), changes[0].NewHash)
+	require.FileExists(t, filepath.Join(dir, fmt.Sprintf("A.%s.A.cdc", a1.Hex())))
+
+	// the next scan only has the changes
+	changes = nil
+	differ = newDiffer()
+	require.NoError(t, differ.Handle(codesBatch(200, map[flow.Address]map[string]string{
+		a1: {"A": "pub contract A {\n    pub let x: Int\n}\n"},
+		a2: {"C": "pub contract C {}"},
+	})))
+	require.Equal(t, []contractdiff.ChangeKind{
+		contractdiff.ContractChanged,
+		contractdiff.ContractRemoved,
+		contractdiff.ContractAdded,
+	}, []contractdiff.ChangeKind{changes[0].Kind, changes[1].Kind, changes[2].Kind})
+	require.Equal(t, fmt.Sprintf(`--- a/A.%[1]s.A.cdc
++++ b/A.%[1]s.A.cdc
+@@ -1,2 +1,3 @@
+ pub contract A {
++    pub let x: Int
+ }
+`, a1.Hex()), changes[0].Diff)
+	require.Equal(t, "B", changes[1].Name)
+	require.Empty(t, changes[1].NewHash)
+	require.NoFileExists(t, filepath.Join(dir, fmt.Sprintf("A.%s.B.cdc", a1.Hex())))
+
+	changes = nil
+	require.NoError(t, differ.Handle(codesBatch(300, map[flow.Address]map[string]string{
+		a2: {"C": "pub contract C {}"},
+	})))
+	require.Empty(t, changes)
+
+	// a failing handler does not update the store
+	store := contractdiff.NewMemoryStore()
+	failing := contractdiff.New(store, func([]contractdiff.Change, scanner.ProcessedAddressBatch) error {
+		return fmt.Errorf("failed")
+	})
+	require.Error(t, failing.Handle(codesBatch(100, map[flow.Address]map[string]string{a1: {"A": "pub contract A {}"}})))
+	hashes, err := store.Hashes(a1)
+	require.NoError(t, err)
+	require.Empty(t, hashes)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a contract"), 0o644))
+	_, err = contractdiff.NewDirStore(dir)
+	require.NoError(t, err)
+}
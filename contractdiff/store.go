@@ -0,0 +1,204 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contractdiff
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/onflow/flow-go-sdk"
+)
+
+// Store keeps the code of the contracts of the previous scan.
+type Store interface {
+	// Hashes returns the hashes of the code of the stored contracts of the address, by contract name.
+	Hashes(address flow.Address) (map[string]string, error)
+	// Code returns the stored code of a contract.
+	Code(address flow.Address, name string) (string, error)
+	Set(address flow.Address, name string, code string) error
+	Remove(address flow.Address, name string) error
+}
+
+// MemoryStore is a Store that keeps the contracts in memory, e.g. to compare the scans of a continuous scan.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	contracts map[flow.Address]map[string]string
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		contracts: make(map[flow.Address]map[string]string),
+	}
+}
+
+func (s *MemoryStore) Hashes(address flow.Address) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hashes := make(map[string]string, len(s.contracts[address]))
+	for name, code := range s.contracts[address] {
+		hashes[name] = Hash(code)
+	}
+	return hashes, nil
+}
+
+func (s *MemoryStore) Code(address flow.Address, name string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	code, ok := s.contracts[address][name]
+	if !ok {
+		return "", fmt.Errorf("contract %s of %s is not stored", name, address)
+	}
+	return code, nil
+}
+
+func (s *MemoryStore) Set(address flow.Address, name string, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contracts, ok := s.contracts[address]
+	if !ok {
+		contracts = make(map[string]string)
+		s.contracts[address] = contracts
+	}
+	contracts[name] = code
+	return nil
+}
+
+func (s *MemoryStore) Remove(address flow.Address, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.contracts[address], name)
+	if len(s.contracts[address]) == 0 {
+		delete(s.contracts, address)
+	}
+	return nil
+}
+
+// DirStore is a Store that keeps each contract in a `A.<address>.<name>.cdc` file of a directory.
+// The hashes of the contracts are read once, when the store is created,
+// so the directory should not be changed by anything else while the store is used.
+type DirStore struct {
+	dir string
+
+	mu     sync.RWMutex
+	hashes map[flow.Address]map[string]string
+}
+
+var _ Store = (*DirStore)(nil)
+
+// NewDirStore creates the directory if it does not exist, and reads the hashes of the contracts in it.
+func NewDirStore(dir string) (*DirStore, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("could not create the contracts directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the contracts directory: %w", err)
+	}
+
+	s := &DirStore{
+		dir:    dir,
+		hashes: make(map[flow.Address]map[string]string),
+	}
+	for _, entry := range entries {
+		address, name, ok := parseContractFileName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		code, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("could not read contract %s: %w", entry.Name(), err)
+		}
+		s.setHash(address, name, Hash(string(code)))
+	}
+	return s, nil
+}
+
+func (s *DirStore) Hashes(address flow.Address) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hashes := make(map[string]string, len(s.hashes[address]))
+	for name, hash := range s.hashes[address] {
+		hashes[name] = hash
+	}
+	return hashes, nil
+}
+
+func (s *DirStore) Code(address flow.Address, name string) (string, error) {
+	code, err := os.ReadFile(filepath.Join(s.dir, contractFileName(address, name)))
+	if err != nil {
+		return "", err
+	}
+	return string(code), nil
+}
+
+func (s *DirStore) Set(address flow.Address, name string, code string) error {
+	err := os.WriteFile(filepath.Join(s.dir, contractFileName(address, name)), []byte(code), 0o644)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setHash(address, name, Hash(code))
+	return nil
+}
+
+func (s *DirStore) Remove(address flow.Address, name string) error {
+	err := os.Remove(filepath.Join(s.dir, contractFileName(address, name)))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.hashes[address], name)
+	if len(s.hashes[address]) == 0 {
+		delete(s.hashes, address)
+	}
+	return nil
+}
+
+func (s *DirStore) setHash(address flow.Address, name string, hash string) {
+	hashes, ok := s.hashes[address]
+	if !ok {
+		hashes = make(map[string]string)
+		s.hashes[address] = hashes
+	}
+	hashes[name] = hash
+}
+
+// contractFileName is the `A.<address>.<name>.cdc` file name of a contract.
+func contractFileName(address flow.Address, name string) string {
+	return fmt.Sprintf("A.%s.%s.cdc", address.Hex(), name)
+}
+
+func parseContractFileName(fileName string) (flow.Address, string, bool) {
+	parts := strings.Split(strings.TrimSuffix(fileName, ".cdc"), ".")
+	if len(parts) != 3 || parts[0] != "A" || !strings.HasSuffix(fileName, ".cdc") || len(parts[1]) != 2*flow.AddressLength {
+		return flow.EmptyAddress, "", false
+	}
+	return flow.HexToAddress(parts[1]), parts[2], true
+}
@@ -0,0 +1,600 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package control serves a REST API to drive a scanner service, e.g. from an orchestration system:
+//
+//	POST /scan                  starts a scan with the posted settings (see the config package),
+//	                            in YAML, or in TOML with the Content-Type application/toml
+//	POST /scan/stop             stops the running scan
+//	POST /scan/pause            pauses the running scan
+//	POST /scan/resume           resumes the paused scan
+//	GET  /scan                  the state and progress of the running (or last) scan as a ScanState
+//	GET  /scan/failed-batches   the failed batches of the running (or last) scan as JSON lines,
+//	                            which can be read with scanner.ReadFailedBatches and rescanned
+//
+// With Config.GRPCAddr the same API is served as the ScanControl gRPC service of the controlpb package
+// (see control/controlpb/control.proto), which also streams the ScanState with WatchScan,
+// so that orchestrators and dashboards can supervise many scanners.
+//
+// The requests are authenticated with the bearer Token of the Config, or with client certificates
+// of a TLSConfig (mutual TLS); Run refuses to serve the API without either. By default the API is only
+// served on the loopback interface. The file paths of the posted settings must be in the BaseDir of the
+// Config, their database one of its DSNs, their access nodes some of its AccessNodes,
+// and the addresses they serve their status and metrics on some of its ListenAddrs.
+//
+// Only one scan runs at a time.
+package control
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/config"
+)
+
+// maxSettingsBytes is the maximum size of posted settings.
+const maxSettingsBytes = 1 << 20
+
+// DefaultAddr is the default Config.Addr, which only accepts connections from the same host.
+const DefaultAddr = "127.0.0.1:8081"
+
+// State is the state of the scan of the control server.
+type State string
+
+const (
+	// StateIdle is the state before the first scan was started.
+	StateIdle    State = "idle"
+	StateRunning State = "running"
+	// StateCompleted is the state of a scan that concluded without an error.
+	// See ScanConcluded.ScanIsComplete for whether a full scan was completed.
+	StateCompleted State = "completed"
+	StateFailed    State = "failed"
+	// StateStopped is the state of a scan that was stopped with /scan/stop.
+	StateStopped State = "stopped"
+)
+
+// ScanState is the state of the running (or last) scan, as served on GET /scan.
+type ScanState struct {
+	State State `json:"state"`
+	// Paused is true while the running scan is paused.
+	Paused     bool       `json:"paused"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Status is the progress of the running scan. A scan with a schedule has no progress between its runs.
+	Status *scanner.ScanStatus `json:"status,omitempty"`
+	// Concluded is the result of the finished scan.
+	Concluded *scanner.ScanConcluded `json:"concluded,omitempty"`
+	// Error is the error of the failed scan.
+	Error string `json:"error,omitempty"`
+}
+
+// NewScanFunc creates the scanner of the posted settings. The closer is closed after the scan finished.
+type NewScanFunc func(settings config.Settings, logger zerolog.Logger) (*scanner.Scanner, io.Closer, error)
+
+type Config struct {
+	// Addr is the address the server listens on. Defaults to DefaultAddr.
+	// Listen on all interfaces, e.g. ":8081", only with a Token or mutual TLS.
+	Addr string
+	// GRPCAddr is the address the ScanControl gRPC service is served on, e.g. "127.0.0.1:9091".
+	// It is not served if empty.
+	GRPCAddr string
+	// Token is the bearer token the requests must have, in the Authorization header,
+	// or in the authorization metadata of the gRPC requests. The requests are not checked if it is empty.
+	Token string
+	// TLSConfig if set, serves the API and the gRPC service over TLS. Set its ClientAuth to
+	// tls.RequireAndVerifyClientCert to authenticate the clients by their certificates.
+	TLSConfig *tls.Config
+	// BaseDir is the directory the file paths of the started scans must be in, relative paths are relative to it.
+	// Without a BaseDir, scans with file paths are rejected. See config.Settings.WithinDir.
+	BaseDir string
+	// DSNs are the database connection strings the started scans may write to.
+	// Without DSNs, scans with a handler.dsn are rejected.
+	DSNs []string
+	// AccessNodes are the access nodes the started scans may connect to.
+	// Without AccessNodes, all scans are rejected.
+	AccessNodes []string
+	// ListenAddrs are the addresses the started scans may serve their status (scanner.status_server_addr)
+	// and their metrics (metrics.addr) on. Without ListenAddrs, scans that serve either are rejected.
+	ListenAddrs []string
+	// NewScan creates the scanners. Defaults to NewScan.
+	NewScan NewScanFunc
+}
+
+// Server is the control server. See the package documentation for its endpoints.
+type Server struct {
+	Config
+
+	logger zerolog.Logger
+
+	mu      sync.Mutex
+	current *scanRun
+}
+
+// scanRun is a scan started by the control server.
+type scanRun struct {
+	scanner   *scanner.Scanner
+	cancel    context.CancelFunc
+	done      chan struct{}
+	startedAt time.Time
+
+	// set once the scan is done
+	stopped    bool
+	finishedAt time.Time
+	concluded  scanner.ScanConcluded
+	err        error
+}
+
+func NewServer(
+	config Config,
+	logger zerolog.Logger,
+) *Server {
+	if config.Addr == "" {
+		config.Addr = DefaultAddr
+	}
+	if config.NewScan == nil {
+		config.NewScan = NewScan
+	}
+	return &Server{
+		Config: config,
+		logger: logger.With().Str("component", "control_server").Logger(),
+	}
+}
+
+// NewScan creates a scanner with the client, the scanner config and the handler of the settings.
+func NewScan(settings config.Settings, logger zerolog.Logger) (*scanner.Scanner, io.Closer, error) {
+	scanConfig, err := settings.ScannerConfig(logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	handler, handlerCloser, err := settings.NewHandler(context.Background(), logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	flowClient, err := settings.NewClient(logger)
+	if err != nil {
+		_ = handlerCloser.Close()
+		return nil, nil, err
+	}
+	newScanner, err := scanner.NewScanner(flowClient, scanConfig.WithScriptResultHandler(handler))
+	if err != nil {
+		_ = flowClient.Close()
+		_ = handlerCloser.Close()
+		return nil, nil, err
+	}
+	return newScanner, closers{flowClient, handlerCloser}, nil
+}
+
+// closers closes all of its closers.
+type closers []io.Closer
+
+func (c closers) Close() error {
+	merr := &multierror.Error{}
+	for _, closer := range c {
+		if err := closer.Close(); err != nil {
+			merr = multierror.Append(merr, err)
+		}
+	}
+	return merr.ErrorOrNil()
+}
+
+// Run serves the control API on Addr, and the gRPC service on GRPCAddr, until the context is cancelled.
+// The running scan is stopped before it returns.
+// It fails without a Token, or a TLSConfig that verifies the client certificates.
+func (s *Server) Run(ctx context.Context) error {
+	if s.Token == "" && (s.TLSConfig == nil || s.TLSConfig.ClientAuth != tls.RequireAndVerifyClientCert) {
+		return errNoAuthentication
+	}
+	server := &http.Server{
+		Addr:              s.Addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	var grpcOptions []grpc.ServerOption
+	if s.TLSConfig != nil {
+		server.TLSConfig = s.TLSConfig.Clone()
+		grpcOptions = append(grpcOptions, grpc.Creds(credentials.NewTLS(s.TLSConfig.Clone())))
+	}
+	var grpcServer *grpc.Server
+	var grpcListener net.Listener
+	if s.GRPCAddr != "" {
+		var err error
+		grpcListener, err = net.Listen("tcp", s.GRPCAddr)
+		if err != nil {
+			return fmt.Errorf("could not listen on %s: %w", s.GRPCAddr, err)
+		}
+		grpcServer = grpc.NewServer(grpcOptions...)
+		s.RegisterGRPC(grpcServer)
+	}
+	s.logger.Info().
+		Str("addr", s.Addr).
+		Str("grpc_addr", s.GRPCAddr).
+		Msg("serving the control API")
+
+	errs := make(chan error, 2)
+	go func() {
+		if server.TLSConfig != nil {
+			// the certificates are in the TLSConfig
+			errs <- server.ListenAndServeTLS("", "")
+			return
+		}
+		errs <- server.ListenAndServe()
+	}()
+	if grpcServer != nil {
+		go func() {
+			errs <- grpcServer.Serve(grpcListener)
+		}()
+	}
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+	}
+
+	if closeErr := server.Close(); closeErr != nil {
+		s.logger.Warn().
+			Err(closeErr).
+			Msg("error while closing control server")
+	}
+	if grpcServer != nil {
+		// Stop instead of GracefulStop, WatchScan streams only end when their clients cancel them.
+		grpcServer.Stop()
+	}
+	if current := s.stop(); current != nil {
+		<-current.done
+	}
+	return err
+}
+
+// Handler is the handler of the control API, to serve it on an existing server.
+// It checks the Token of the requests, the TLS of the existing server is up to its owner.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scan", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			s.writeJSON(w, http.StatusOK, s.State())
+		case http.MethodPost:
+			s.handleStart(w, r)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/scan/stop", s.post(func() error {
+		if s.stop() == nil {
+			return errNotRunning
+		}
+		return nil
+	}))
+	mux.HandleFunc("/scan/pause", s.post(func() error {
+		return s.withRunning(func(scan *scanner.Scanner) { scan.Pause() })
+	}))
+	mux.HandleFunc("/scan/resume", s.post(func() error {
+		return s.withRunning(func(scan *scanner.Scanner) { scan.Resume() })
+	}))
+	mux.HandleFunc("/scan/failed-batches", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="failed-batches.jsonl"`)
+		if err := scanner.WriteFailedBatches(w, s.FailedBatches()); err != nil {
+			s.logger.Debug().
+				Err(err).
+				Msg("failed to write the failed batches")
+		}
+	})
+	return s.authenticate(mux)
+}
+
+var (
+	errRunning          = errors.New("a scan is already running")
+	errNotRunning       = errors.New("no scan is running")
+	errNoAuthentication = errors.New("the control API requires a Token, or a TLSConfig that verifies the client certificates")
+)
+
+// authenticate rejects the requests without the Token.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if s.Token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.validAuthorization(r.Header.Get("Authorization")) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validAuthorization checks that an Authorization header is "Bearer <Token>".
+func (s *Server) validAuthorization(authorization string) bool {
+	const prefix = "Bearer "
+	if s.Token == "" {
+		return true
+	}
+	if !strings.HasPrefix(authorization, prefix) {
+		return false
+	}
+	token := strings.TrimPrefix(authorization, prefix)
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1
+}
+
+func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSettingsBytes))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not read the settings: %v", err), http.StatusBadRequest)
+		return
+	}
+	settings, err := config.Parse(data, settingsFormat(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = s.Start(settings)
+	switch {
+	case errors.Is(err, errRunning):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		s.writeJSON(w, http.StatusAccepted, s.State())
+	}
+}
+
+// settingsFormat is the format of the posted settings: TOML for the TOML content types, YAML otherwise.
+func settingsFormat(r *http.Request) string {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/toml", "text/toml":
+		return config.FormatTOML
+	default:
+		return config.FormatYAML
+	}
+}
+
+// post handles a POST request with action, and responds with the ScanState.
+func (s *Server) post(action func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := action(); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, s.State())
+	}
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, code int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		s.logger.Debug().
+			Err(err).
+			Msg("failed to write response")
+	}
+}
+
+// Start starts a scan with the settings. It fails if a scan is already running, if the settings have
+// file paths outside of the BaseDir, or a DSN, access node or listen address that is not allowed by the Config.
+func (s *Server) Start(settings config.Settings) error {
+	if s.running() {
+		return errRunning
+	}
+	settings, err := s.restrict(settings)
+	if err != nil {
+		return err
+	}
+
+	// creating the scanner connects to the access nodes, which must not block the other requests
+	newScanner, closer, err := s.NewScan(settings, s.logger)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	// another scan might have been started in the meantime
+	if s.current != nil && !isDone(s.current.done) {
+		s.mu.Unlock()
+		if closeErr := closer.Close(); closeErr != nil {
+			s.logger.Warn().
+				Err(closeErr).
+				Msg("error while closing the scan that was not started")
+		}
+		return errRunning
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	current := &scanRun{
+		scanner:   newScanner,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		startedAt: time.Now(),
+	}
+	s.current = current
+	s.mu.Unlock()
+	s.logger.Info().Msg("starting a scan")
+
+	go func() {
+		defer close(current.done)
+		defer cancel()
+		concluded, err := newScanner.Scan(ctx)
+		if closeErr := closer.Close(); closeErr != nil {
+			s.logger.Warn().
+				Err(closeErr).
+				Msg("error while closing the scan")
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		current.finishedAt = time.Now()
+		current.concluded = concluded
+		current.err = err
+		s.logger.Info().
+			Err(err).
+			Bool("complete", concluded.ScanIsComplete).
+			Msg("scan finished")
+	}()
+	return nil
+}
+
+// restrict confines the file paths of the settings to the BaseDir, and their database to the DSNs.
+func (s *Server) restrict(settings config.Settings) (config.Settings, error) {
+	if s.BaseDir != "" {
+		var err error
+		settings, err = settings.WithinDir(s.BaseDir)
+		if err != nil {
+			return config.Settings{}, err
+		}
+	} else if settings.Handler.Path != "" || settings.Scanner.ScriptPath != "" ||
+		settings.Scanner.ResultSpillDir != "" || settings.Scanner.DurableQueuePath != "" {
+		return config.Settings{}, errors.New("the settings have file paths, which require a BaseDir of the control server")
+	}
+	if settings.Handler.DSN != "" && !contains(s.DSNs, settings.Handler.DSN) {
+		return config.Settings{}, errors.New("handler.dsn is not one of the DSNs of the control server")
+	}
+	for _, accessNode := range settings.Client.AccessNodes {
+		if !contains(s.AccessNodes, accessNode) {
+			return config.Settings{}, fmt.Errorf("client.access_nodes: %s is not one of the AccessNodes of the control server", accessNode)
+		}
+	}
+	if settings.Scanner.StatusServerAddr != "" && !contains(s.ListenAddrs, settings.Scanner.StatusServerAddr) {
+		return config.Settings{}, errors.New("scanner.status_server_addr is not one of the ListenAddrs of the control server")
+	}
+	if settings.Metrics.Addr != "" && !contains(s.ListenAddrs, settings.Metrics.Addr) {
+		return config.Settings{}, errors.New("metrics.addr is not one of the ListenAddrs of the control server")
+	}
+	return settings, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// running is true while a scan is running.
+func (s *Server) running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current != nil && !isDone(s.current.done)
+}
+
+// stop cancels the running scan, and returns it. It returns nil if no scan is running.
+func (s *Server) stop() *scanRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil || isDone(s.current.done) {
+		return nil
+	}
+	s.current.stopped = true
+	s.current.cancel()
+	s.logger.Info().Msg("stopping the scan")
+	return s.current
+}
+
+func (s *Server) withRunning(f func(scan *scanner.Scanner)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil || isDone(s.current.done) {
+		return errNotRunning
+	}
+	f(s.current.scanner)
+	return nil
+}
+
+// State is the state of the running (or last) scan.
+func (s *Server) State() ScanState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.current
+	if current == nil {
+		return ScanState{State: StateIdle}
+	}
+
+	startedAt := current.startedAt
+	state := ScanState{StartedAt: &startedAt}
+	if current.finishedAt.IsZero() {
+		state.State = StateRunning
+		state.Paused = current.scanner.IsPaused()
+		if status, ok := current.scanner.Status(); ok {
+			state.Status = &status
+		}
+		return state
+	}
+
+	finishedAt := current.finishedAt
+	concluded := current.concluded
+	state.FinishedAt = &finishedAt
+	state.Concluded = &concluded
+	switch {
+	case current.stopped:
+		state.State = StateStopped
+	case current.err != nil && !errors.Is(current.err, context.Canceled):
+		state.State = StateFailed
+		state.Error = current.err.Error()
+	default:
+		state.State = StateCompleted
+	}
+	return state
+}
+
+// FailedBatches are the failed batches of the running (or last) scan.
+func (s *Server) FailedBatches() []scanner.FailedBatch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		return nil
+	}
+	if s.current.finishedAt.IsZero() {
+		stats, _ := s.current.scanner.Stats()
+		return stats.FailedBatches
+	}
+	return s.current.concluded.Stats.FailedBatches
+}
+
+func isDone(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+	"github.com/onflow/flow-batch-scan/config"
+	"github.com/onflow/flow-batch-scan/control"
+)
+
+// newScan creates the scanners of a control server with the client.
+func newScan(flowClient client.Client) control.NewScanFunc {
+	return func(settings config.Settings, logger zerolog.Logger) (*scanner.Scanner, io.Closer, error) {
+		scanConfig, err := settings.ScannerConfig(logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		scan, err := scanner.NewScanner(flowClient, scanConfig)
+		return scan, io.NopCloser(nil), err
+	}
+}
+
+func TestServer(t *testing.T) {
+	flowClient := clienttest.New()
+	server := control.NewServer(control.Config{
+		AccessNodes: []string{"localhost:3569"},
+		NewScan:     newScan(flowClient),
+	}, zerolog.Nop())
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	post := func(path string, body string) *http.Response {
+		resp, err := http.Post(httpServer.URL+path, "application/yaml", strings.NewReader(body))
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp
+	}
+	state := func() control.ScanState {
+		resp, err := http.Get(httpServer.URL + "/scan")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var state control.ScanState
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&state))
+		return state
+	}
+
+	require.Equal(t, control.StateIdle, state().State)
+	require.Equal(t, http.StatusConflict, post("/scan/stop", "").StatusCode)
+	require.Equal(t, http.StatusBadRequest, post("/scan", "scanner:\n  batch_size: -1\n").StatusCode)
+
+	// a scheduled scan runs until it is stopped
+	settings := "client:\n  access_nodes: [localhost:3569]\nscanner:\n  schedule: '@every 1h'\n"
+	require.Equal(t, http.StatusAccepted, post("/scan", settings).StatusCode)
+	require.Equal(t, http.StatusConflict, post("/scan", settings).StatusCode)
+	require.Equal(t, control.StateRunning, state().State)
+
+	require.Equal(t, http.StatusOK, post("/scan/pause", "").StatusCode)
+	require.True(t, state().Paused)
+	require.Equal(t, http.StatusOK, post("/scan/resume", "").StatusCode)
+	require.False(t, state().Paused)
+
+	require.Equal(t, http.StatusOK, post("/scan/stop", "").StatusCode)
+	require.Eventually(t, func() bool {
+		return state().State == control.StateStopped
+	}, time.Second, 10*time.Millisecond)
+
+	// a failing scan
+	flowClient.SetError(client.MethodGetNetworkParameters, status.Error(codes.Unimplemented, "unimplemented"))
+	require.Equal(t, http.StatusAccepted, post("/scan", "client:\n  access_nodes: [localhost:3569]\n").StatusCode)
+	require.Eventually(t, func() bool {
+		return state().State == control.StateFailed
+	}, time.Second, 10*time.Millisecond)
+	require.Contains(t, state().Error, "WithChainID")
+
+	resp, err := http.Get(httpServer.URL + "/scan/failed-batches")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServer_Token(t *testing.T) {
+	server := control.NewServer(control.Config{
+		Token:   "secret",
+		NewScan: newScan(clienttest.New()),
+	}, zerolog.Nop())
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	get := func(authorization string) int {
+		req, err := http.NewRequest(http.MethodGet, httpServer.URL+"/scan", nil)
+		require.NoError(t, err)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusUnauthorized, get(""))
+	require.Equal(t, http.StatusUnauthorized, get("Bearer wrong"))
+	require.Equal(t, http.StatusUnauthorized, get("secret"))
+	require.Equal(t, http.StatusOK, get("Bearer secret"))
+}
+
+func TestServer_Restrict(t *testing.T) {
+	baseDir := t.TempDir()
+	server := control.NewServer(control.Config{
+		AccessNodes: []string{"localhost:3569"},
+		NewScan:     newScan(clienttest.New()),
+	}, zerolog.Nop())
+
+	parse := func(data string) config.Settings {
+		settings, err := config.Parse([]byte(data), config.FormatYAML)
+		require.NoError(t, err)
+		return settings
+	}
+	jsonLines := parse("client:\n  access_nodes: [localhost:3569]\nhandler:\n  output: jsonlines\n  path: results.jsonl\n")
+	require.ErrorContains(t, server.Start(jsonLines), "BaseDir")
+	postgres := parse("client:\n  access_nodes: [localhost:3569]\nhandler:\n  output: postgres\n  dsn: postgres://db/scan\n")
+	require.ErrorContains(t, server.Start(postgres), "handler.dsn")
+
+	otherNode := parse("client:\n  access_nodes: [localhost:3569, 10.0.0.1:9000]\n")
+	require.ErrorContains(t, server.Start(otherNode), "10.0.0.1:9000")
+	statusServer := parse("client:\n  access_nodes: [localhost:3569]\nscanner:\n  status_server_addr: 127.0.0.1:8082\n")
+	require.ErrorContains(t, server.Start(statusServer), "scanner.status_server_addr")
+	metrics := parse("client:\n  access_nodes: [localhost:3569]\nmetrics:\n  addr: 127.0.0.1:9090\n")
+	require.ErrorContains(t, server.Start(metrics), "metrics.addr")
+
+	server = control.NewServer(control.Config{
+		BaseDir:     baseDir,
+		DSNs:        []string{"postgres://db/scan"},
+		AccessNodes: []string{"localhost:3569"},
+		ListenAddrs: []string{"127.0.0.1:8082", "127.0.0.1:9090"},
+		NewScan: func(settings config.Settings, logger zerolog.Logger) (*scanner.Scanner, io.Closer, error) {
+			return nil, nil, errors.New("not started")
+		},
+	}, zerolog.Nop())
+	outside := parse("client:\n  access_nodes: [localhost:3569]\nhandler:\n  output: jsonlines\n  path: ../results.jsonl\n")
+	require.ErrorContains(t, server.Start(outside), "outside of")
+	require.ErrorContains(t, server.Start(jsonLines), "not started")
+	require.ErrorContains(t, server.Start(postgres), "not started")
+	require.ErrorContains(t, server.Start(statusServer), "not started")
+	require.ErrorContains(t, server.Start(metrics), "not started")
+}
+
+func TestServer_StartDoesNotBlock(t *testing.T) {
+	creating := make(chan struct{})
+	created := make(chan struct{})
+	var closed atomic.Int32
+	server := control.NewServer(control.Config{
+		AccessNodes: []string{"localhost:3569"},
+		NewScan: func(settings config.Settings, logger zerolog.Logger) (*scanner.Scanner, io.Closer, error) {
+			creating <- struct{}{}
+			<-created
+			scanConfig, err := settings.ScannerConfig(logger)
+			if err != nil {
+				return nil, nil, err
+			}
+			scan, err := scanner.NewScanner(clienttest.New(), scanConfig)
+			return scan, closerFunc(func() error {
+				closed.Add(1)
+				return nil
+			}), err
+		},
+	}, zerolog.Nop())
+	settings, err := config.Parse([]byte("client:\n  access_nodes: [localhost:3569]\nscanner:\n  schedule: '@every 1h'\n"), config.FormatYAML)
+	require.NoError(t, err)
+
+	started := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { started <- server.Start(settings) }()
+		<-creating
+	}
+	// the state is served while the scanners are created
+	require.Equal(t, control.StateIdle, server.State().State)
+
+	// only one of the scans is started, the scanner of the other one is closed
+	close(created)
+	var errs []string
+	for i := 0; i < 2; i++ {
+		if err := <-started; err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	require.Equal(t, []string{"a scan is already running"}, errs)
+	require.Equal(t, int32(1), closed.Load())
+	require.Equal(t, control.StateRunning, server.State().State)
+
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/scan/stop", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+}
+
+// closerFunc is an io.Closer that calls itself.
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+func TestServer_RunRequiresAuthentication(t *testing.T) {
+	server := control.NewServer(control.Config{Addr: "127.0.0.1:0"}, zerolog.Nop())
+	require.Equal(t, "127.0.0.1:0", server.Addr)
+	require.ErrorContains(t, server.Run(context.Background()), "requires a Token")
+
+	server = control.NewServer(control.Config{}, zerolog.Nop())
+	require.Equal(t, control.DefaultAddr, server.Addr)
+}
@@ -0,0 +1,229 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/config"
+	"github.com/onflow/flow-batch-scan/control/controlpb"
+)
+
+// defaultWatchInterval is the interval of WatchScan if the request has none.
+const defaultWatchInterval = time.Second
+
+// RegisterGRPC registers the ScanControl gRPC service of the server, to serve it on an existing gRPC server.
+// The service checks the Token of the requests, the TLS of the existing server is up to its owner.
+func (s *Server) RegisterGRPC(registrar grpc.ServiceRegistrar) {
+	controlpb.RegisterScanControlServer(registrar, &grpcService{server: s})
+}
+
+// grpcService is the ScanControl gRPC service, with the same semantics as the REST API.
+type grpcService struct {
+	controlpb.UnimplementedScanControlServer
+
+	server *Server
+}
+
+var _ controlpb.ScanControlServer = (*grpcService)(nil)
+
+// authenticate rejects the requests without the Token in their authorization metadata.
+func (g *grpcService) authenticate(ctx context.Context) error {
+	if g.server.Token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, authorization := range md.Get("authorization") {
+		if g.server.validAuthorization(authorization) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "unauthorized")
+}
+
+func (g *grpcService) GetScan(ctx context.Context, _ *controlpb.GetScanRequest) (*controlpb.ScanState, error) {
+	if err := g.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	return g.server.State().proto(), nil
+}
+
+func (g *grpcService) WatchScan(req *controlpb.WatchScanRequest, stream controlpb.ScanControl_WatchScanServer) error {
+	if err := g.authenticate(stream.Context()); err != nil {
+		return err
+	}
+	interval := req.GetInterval().AsDuration()
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := stream.Send(g.server.State().proto()); err != nil {
+			return err
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (g *grpcService) StartScan(ctx context.Context, req *controlpb.StartScanRequest) (*controlpb.ScanState, error) {
+	if err := g.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	format := config.FormatYAML
+	if req.GetFormat() == controlpb.SettingsFormat_SETTINGS_FORMAT_TOML {
+		format = config.FormatTOML
+	}
+	settings, err := config.Parse([]byte(req.GetSettings()), format)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := g.server.Start(settings); err != nil {
+		return nil, statusError(err)
+	}
+	return g.server.State().proto(), nil
+}
+
+func (g *grpcService) StopScan(ctx context.Context, _ *controlpb.StopScanRequest) (*controlpb.ScanState, error) {
+	if err := g.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	if g.server.stop() == nil {
+		return nil, statusError(errNotRunning)
+	}
+	return g.server.State().proto(), nil
+}
+
+func (g *grpcService) PauseScan(ctx context.Context, _ *controlpb.PauseScanRequest) (*controlpb.ScanState, error) {
+	if err := g.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	if err := g.server.withRunning(func(scan *scanner.Scanner) { scan.Pause() }); err != nil {
+		return nil, statusError(err)
+	}
+	return g.server.State().proto(), nil
+}
+
+func (g *grpcService) ResumeScan(ctx context.Context, _ *controlpb.ResumeScanRequest) (*controlpb.ScanState, error) {
+	if err := g.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	if err := g.server.withRunning(func(scan *scanner.Scanner) { scan.Resume() }); err != nil {
+		return nil, statusError(err)
+	}
+	return g.server.State().proto(), nil
+}
+
+// statusError is the gRPC status of an error of the server,
+// FAILED_PRECONDITION where the REST API responds with 409 Conflict, and INVALID_ARGUMENT otherwise.
+func statusError(err error) error {
+	if errors.Is(err, errRunning) || errors.Is(err, errNotRunning) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+var protoStates = map[State]controlpb.State{
+	StateIdle:      controlpb.State_STATE_IDLE,
+	StateRunning:   controlpb.State_STATE_RUNNING,
+	StateCompleted: controlpb.State_STATE_COMPLETED,
+	StateFailed:    controlpb.State_STATE_FAILED,
+	StateStopped:   controlpb.State_STATE_STOPPED,
+}
+
+func (s ScanState) proto() *controlpb.ScanState {
+	state := &controlpb.ScanState{
+		State:  protoStates[s.State],
+		Paused: s.Paused,
+		Error:  s.Error,
+	}
+	if s.StartedAt != nil {
+		state.StartedAt = timestamppb.New(*s.StartedAt)
+	}
+	if s.FinishedAt != nil {
+		state.FinishedAt = timestamppb.New(*s.FinishedAt)
+	}
+	if s.Status != nil {
+		state.Status = protoStatus(*s.Status)
+	}
+	if s.Concluded != nil {
+		state.Concluded = protoConcluded(*s.Concluded)
+	}
+	return state
+}
+
+func protoStatus(s scanner.ScanStatus) *controlpb.ScanStatus {
+	status := &controlpb.ScanStatus{
+		Ready:                  s.Ready,
+		Healthy:                s.Healthy,
+		IncrementalScanRunning: s.IncrementalScanRunning,
+		IncrementalBlockHeight: s.IncrementalBlockHeight,
+		AddressesScanned:       s.AddressesScanned,
+		BatchesExecuted:        s.BatchesExecuted,
+		Retries:                s.Retries,
+		ScriptErrors:           s.ScriptErrors,
+		FailedBatches:          int64(s.FailedBatches),
+		HandlerErrors:          s.HandlerErrors,
+		Uptime:                 durationpb.New(s.Uptime),
+	}
+	if !s.IncrementalProgressAt.IsZero() {
+		status.IncrementalProgressAt = timestamppb.New(s.IncrementalProgressAt)
+	}
+	if s.FullScan != nil {
+		status.FullScan = &controlpb.FullScanProgress{
+			Scanned:            s.FullScan.Scanned,
+			Total:              s.FullScan.Total,
+			AddressesPerSecond: s.FullScan.AddressesPerSecond,
+			Eta:                durationpb.New(s.FullScan.ETA),
+		}
+	}
+	for _, queue := range s.Queues {
+		status.Queues = append(status.Queues, &controlpb.QueueStats{
+			Name:          queue.Name,
+			Depth:         int64(queue.Depth),
+			Capacity:      int64(queue.Capacity),
+			HighWaterMark: int64(queue.HighWaterMark),
+		})
+	}
+	return status
+}
+
+func protoConcluded(c scanner.ScanConcluded) *controlpb.ScanConcluded {
+	return &controlpb.ScanConcluded{
+		LatestScannedBlockHeight: c.LatestScannedBlockHeight,
+		ScanIsComplete:           c.ScanIsComplete,
+		AddressesScanned:         c.Stats.AddressesScanned,
+		BatchesExecuted:          c.Stats.BatchesExecuted,
+		Retries:                  c.Stats.Retries,
+		ScriptErrors:             c.Stats.ScriptErrors,
+		FailedBatches:            int64(len(c.Stats.FailedBatches)),
+		HandlerErrors:            c.Stats.HandlerErrors,
+		Duration:                 durationpb.New(c.Stats.Duration),
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+	"github.com/onflow/flow-batch-scan/control"
+	"github.com/onflow/flow-batch-scan/control/controlpb"
+)
+
+func TestServer_GRPC(t *testing.T) {
+	flowClient := clienttest.New()
+	server := control.NewServer(control.Config{
+		AccessNodes: []string{"localhost:3569"},
+		NewScan:     newScan(flowClient),
+	}, zerolog.Nop())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer()
+	server.RegisterGRPC(grpcServer)
+	go func() { _ = grpcServer.Serve(listener) }()
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	ctl := controlpb.NewScanControlClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	state, err := ctl.GetScan(ctx, &controlpb.GetScanRequest{})
+	require.NoError(t, err)
+	require.Equal(t, controlpb.State_STATE_IDLE, state.State)
+
+	_, err = ctl.StopScan(ctx, &controlpb.StopScanRequest{})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = ctl.StartScan(ctx, &controlpb.StartScanRequest{Settings: "[scanner]\nbatch_size = -1\n", Format: controlpb.SettingsFormat_SETTINGS_FORMAT_TOML})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// a scheduled scan runs until it is stopped
+	settings := &controlpb.StartScanRequest{Settings: "client:\n  access_nodes: [localhost:3569]\nscanner:\n  schedule: '@every 1h'\n"}
+	state, err = ctl.StartScan(ctx, settings)
+	require.NoError(t, err)
+	require.Equal(t, controlpb.State_STATE_RUNNING, state.State)
+	require.NotNil(t, state.StartedAt)
+	_, err = ctl.StartScan(ctx, settings)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	state, err = ctl.PauseScan(ctx, &controlpb.PauseScanRequest{})
+	require.NoError(t, err)
+	require.True(t, state.Paused)
+	state, err = ctl.ResumeScan(ctx, &controlpb.ResumeScanRequest{})
+	require.NoError(t, err)
+	require.False(t, state.Paused)
+
+	watch, err := ctl.WatchScan(ctx, &controlpb.WatchScanRequest{Interval: durationpb.New(10 * time.Millisecond)})
+	require.NoError(t, err)
+	state, err = watch.Recv()
+	require.NoError(t, err)
+	require.Equal(t, controlpb.State_STATE_RUNNING, state.State)
+
+	_, err = ctl.StopScan(ctx, &controlpb.StopScanRequest{})
+	require.NoError(t, err)
+	for state.State == controlpb.State_STATE_RUNNING {
+		state, err = watch.Recv()
+		require.NoError(t, err)
+	}
+	require.Equal(t, controlpb.State_STATE_STOPPED, state.State)
+	require.NotNil(t, state.FinishedAt)
+	require.NotNil(t, state.Concluded)
+}
+
+func TestServer_GRPCToken(t *testing.T) {
+	server := control.NewServer(control.Config{
+		Token:   "secret",
+		NewScan: newScan(clienttest.New()),
+	}, zerolog.Nop())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer()
+	server.RegisterGRPC(grpcServer)
+	go func() { _ = grpcServer.Serve(listener) }()
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	ctl := controlpb.NewScanControlClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = ctl.GetScan(ctx, &controlpb.GetScanRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	watch, err := ctl.WatchScan(ctx, &controlpb.WatchScanRequest{})
+	require.NoError(t, err)
+	_, err = watch.Recv()
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+	state, err := ctl.GetScan(ctx, &controlpb.GetScanRequest{})
+	require.NoError(t, err)
+	require.Equal(t, controlpb.State_STATE_IDLE, state.State)
+}
@@ -0,0 +1,395 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/onflow/cadence"
+)
+
+// DecodeValue decodes a Cadence value directly into the Go value target points to,
+// without encoding it to JSON first. The Cadence values are decoded into:
+//   - bool from Bool,
+//   - integers, floats and *big.Int (or big.Int) from all the integer types, if they fit,
+//     and floats from UFix64 and Fix64,
+//   - strings from String and Character, and the string form of Address, Path, UFix64 and Fix64,
+//   - flow.Address (or any [8]byte) from Address,
+//   - slices and arrays from arrays, and maps from dictionaries,
+//   - structs from structs, resources, events and enums: a Go field with a `cadence:"name"` tag
+//     is decoded from the Cadence field with that name, which has to exist, and the other exported Go fields
+//     from the Cadence field with the same name ignoring case, if there is one. `cadence:"-"` skips a field,
+//   - pointers from optionals (nil is nil), other Go values from an optional are decoded from its value,
+//   - the Cadence values themselves, e.g. cadence.UFix64 or cadence.Value.
+//
+// Decoding a result directly is about 4 times faster, and allocates about a quarter of the memory,
+// compared to converting it to JSON and decoding that with encoding/json (see BenchmarkDecodeValue).
+func DecodeValue(value cadence.Value, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, got %T", target)
+	}
+	err := decodeValue(value, v.Elem())
+	if err != nil {
+		if _, ok := err.(*DecodeError); !ok {
+			err = &DecodeError{Err: err}
+		}
+	}
+	return err
+}
+
+// DecodeArray returns a decoder of array results into []T, e.g. for NewTypedResultHandler.
+func DecodeArray[T any]() func(cadence.Value) ([]T, error) {
+	return func(value cadence.Value) ([]T, error) {
+		var values []T
+		err := DecodeValue(value, &values)
+		return values, err
+	}
+}
+
+// DecodeError is the error of DecodeValue. Path is where in the value the error happened, e.g. `[3].balance`.
+type DecodeError struct {
+	Path string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("cannot decode cadence value: %v", e.Err)
+	}
+	return fmt.Sprintf("cannot decode cadence value at %s: %v", e.Path, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	cadencePkgPath = reflect.TypeOf(cadence.Int{}).PkgPath()
+	bigIntType     = reflect.TypeOf(big.Int{})
+	bigIntPtrType  = reflect.TypeOf((*big.Int)(nil))
+)
+
+func decodeValue(value cadence.Value, target reflect.Value) error {
+	t := target.Type()
+	// the Cadence values themselves, checking the package first is much cheaper than Implements
+	if value != nil && t.PkgPath() == cadencePkgPath && reflect.TypeOf(value).AssignableTo(t) {
+		target.Set(reflect.ValueOf(value))
+		return nil
+	}
+
+	if optional, ok := value.(cadence.Optional); ok {
+		if t.Kind() == reflect.Pointer && t != bigIntPtrType {
+			if optional.Value == nil {
+				target.Set(reflect.Zero(t))
+				return nil
+			}
+			target.Set(reflect.New(t.Elem()))
+			return decodeValue(optional.Value, target.Elem())
+		}
+		if optional.Value == nil {
+			target.Set(reflect.Zero(t))
+			return nil
+		}
+		return decodeValue(optional.Value, target)
+	}
+	if value == nil {
+		return fmt.Errorf("cannot decode a nil value into %s", t)
+	}
+
+	switch {
+	case t == bigIntPtrType:
+		i, ok := cadenceInteger(value)
+		if !ok {
+			return mismatch(value, t)
+		}
+		target.Set(reflect.ValueOf(i))
+		return nil
+	case t == bigIntType:
+		i, ok := cadenceInteger(value)
+		if !ok {
+			return mismatch(value, t)
+		}
+		target.Set(reflect.ValueOf(i).Elem())
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		target.Set(reflect.New(t.Elem()))
+		return decodeValue(value, target.Elem())
+	case reflect.Bool:
+		b, ok := value.(cadence.Bool)
+		if !ok {
+			return mismatch(value, t)
+		}
+		target.SetBool(bool(b))
+	case reflect.String:
+		switch v := value.(type) {
+		case cadence.String:
+			target.SetString(string(v))
+		case cadence.Character:
+			target.SetString(string(v))
+		case cadence.Address, cadence.Path, cadence.UFix64, cadence.Fix64:
+			target.SetString(v.String())
+		default:
+			return mismatch(value, t)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := cadenceInt64(value)
+		if !ok {
+			big, ok := cadenceInteger(value)
+			if !ok {
+				return mismatch(value, t)
+			}
+			if !big.IsInt64() {
+				return fmt.Errorf("%s does not fit into %s", big, t)
+			}
+			i = big.Int64()
+		}
+		if target.OverflowInt(i) {
+			return fmt.Errorf("%d does not fit into %s", i, t)
+		}
+		target.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, ok := cadenceUint64(value)
+		if !ok {
+			big, ok := cadenceInteger(value)
+			if !ok {
+				return mismatch(value, t)
+			}
+			if !big.IsUint64() {
+				return fmt.Errorf("%s does not fit into %s", big, t)
+			}
+			u = big.Uint64()
+		}
+		if target.OverflowUint(u) {
+			return fmt.Errorf("%d does not fit into %s", u, t)
+		}
+		target.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		switch v := value.(type) {
+		case cadence.UFix64:
+			target.SetFloat(float64(v) / 1e8)
+		case cadence.Fix64:
+			target.SetFloat(float64(v) / 1e8)
+		default:
+			i, ok := cadenceInteger(value)
+			if !ok {
+				return mismatch(value, t)
+			}
+			f, _ := new(big.Float).SetInt(i).Float64()
+			if math.IsInf(f, 0) || target.OverflowFloat(f) {
+				return fmt.Errorf("%s does not fit into %s", i, t)
+			}
+			target.SetFloat(f)
+		}
+	case reflect.Slice:
+		array, ok := value.(cadence.Array)
+		if !ok {
+			return mismatch(value, t)
+		}
+		slice := reflect.MakeSlice(t, len(array.Values), len(array.Values))
+		for i, element := range array.Values {
+			if err := decodeValue(element, slice.Index(i)); err != nil {
+				return withPath(fmt.Sprintf("[%d]", i), err)
+			}
+		}
+		target.Set(slice)
+	case reflect.Array:
+		if address, ok := value.(cadence.Address); ok {
+			if !reflect.TypeOf(address).ConvertibleTo(t) {
+				return mismatch(value, t)
+			}
+			target.Set(reflect.ValueOf(address).Convert(t))
+			return nil
+		}
+		array, ok := value.(cadence.Array)
+		if !ok {
+			return mismatch(value, t)
+		}
+		if len(array.Values) != t.Len() {
+			return fmt.Errorf("cannot decode an array of %d values into %s", len(array.Values), t)
+		}
+		for i, element := range array.Values {
+			if err := decodeValue(element, target.Index(i)); err != nil {
+				return withPath(fmt.Sprintf("[%d]", i), err)
+			}
+		}
+	case reflect.Map:
+		dictionary, ok := value.(cadence.Dictionary)
+		if !ok {
+			return mismatch(value, t)
+		}
+		m := reflect.MakeMapWithSize(t, len(dictionary.Pairs))
+		for _, pair := range dictionary.Pairs {
+			key := reflect.New(t.Key()).Elem()
+			if err := decodeValue(pair.Key, key); err != nil {
+				return withPath(fmt.Sprintf("[%s]", pair.Key), err)
+			}
+			element := reflect.New(t.Elem()).Elem()
+			if err := decodeValue(pair.Value, element); err != nil {
+				return withPath(fmt.Sprintf("[%s]", pair.Key), err)
+			}
+			m.SetMapIndex(key, element)
+		}
+		target.Set(m)
+	case reflect.Struct:
+		composite, ok := value.(cadence.HasFields)
+		if !ok {
+			return mismatch(value, t)
+		}
+		return decodeStruct(composite, target)
+	case reflect.Interface:
+		if !reflect.TypeOf(value).AssignableTo(t) {
+			return mismatch(value, t)
+		}
+		target.Set(reflect.ValueOf(value))
+	default:
+		return fmt.Errorf("cannot decode into %s", t)
+	}
+	return nil
+}
+
+func decodeStruct(composite cadence.HasFields, target reflect.Value) error {
+	fields := composite.GetFields()
+	values := composite.GetFieldValues()
+	if len(fields) != len(values) {
+		return fmt.Errorf("the composite has %d fields and %d values", len(fields), len(values))
+	}
+	for _, field := range structFieldsOf(target.Type()) {
+		index := -1
+		for i, f := range fields {
+			if f.Identifier == field.name || (!field.tagged && strings.EqualFold(f.Identifier, field.name)) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			if field.tagged {
+				return withPath("."+field.name, fmt.Errorf("field not found"))
+			}
+			continue
+		}
+		if err := decodeValue(values[index], target.Field(field.index)); err != nil {
+			return withPath("."+fields[index].Identifier, err)
+		}
+	}
+	return nil
+}
+
+// decodedField is a field of a Go struct that is decoded from a Cadence field.
+type decodedField struct {
+	index  int
+	name   string
+	tagged bool
+}
+
+// decodedFields caches the decodedFields of the struct types.
+var decodedFields sync.Map
+
+func structFieldsOf(t reflect.Type) []decodedField {
+	if fields, ok := decodedFields.Load(t); ok {
+		return fields.([]decodedField)
+	}
+	fields := make([]decodedField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("cadence")
+		switch tag {
+		case "-":
+		case "":
+			fields = append(fields, decodedField{index: i, name: field.Name})
+		default:
+			fields = append(fields, decodedField{index: i, name: tag, tagged: true})
+		}
+	}
+	decodedFields.Store(t, fields)
+	return fields
+}
+
+// cadenceInt64 is the value of a signed Cadence integer of up to 64 bits.
+func cadenceInt64(value cadence.Value) (int64, bool) {
+	switch v := value.(type) {
+	case cadence.Int8:
+		return int64(v), true
+	case cadence.Int16:
+		return int64(v), true
+	case cadence.Int32:
+		return int64(v), true
+	case cadence.Int64:
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// cadenceUint64 is the value of an unsigned Cadence integer of up to 64 bits.
+func cadenceUint64(value cadence.Value) (uint64, bool) {
+	switch v := value.(type) {
+	case cadence.UInt8:
+		return uint64(v), true
+	case cadence.UInt16:
+		return uint64(v), true
+	case cadence.UInt32:
+		return uint64(v), true
+	case cadence.UInt64:
+		return uint64(v), true
+	case cadence.Word8:
+		return uint64(v), true
+	case cadence.Word16:
+		return uint64(v), true
+	case cadence.Word32:
+		return uint64(v), true
+	case cadence.Word64:
+		return uint64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// cadenceInteger is the value of any Cadence integer.
+func cadenceInteger(value cadence.Value) (*big.Int, bool) {
+	if v, ok := value.(interface{ Big() *big.Int }); ok {
+		return v.Big(), true
+	}
+	if i, ok := cadenceInt64(value); ok {
+		return big.NewInt(i), true
+	}
+	if u, ok := cadenceUint64(value); ok {
+		return new(big.Int).SetUint64(u), true
+	}
+	return nil, false
+}
+
+func mismatch(value cadence.Value, t reflect.Type) error {
+	return fmt.Errorf("cannot decode %T into %s", value, t)
+}
+
+// withPath prefixes the path of a DecodeError with segment.
+func withPath(segment string, err error) error {
+	if decodeErr, ok := err.(*DecodeError); ok {
+		return &DecodeError{Path: segment + decodeErr.Path, Err: decodeErr.Err}
+	}
+	return &DecodeError{Path: segment, Err: err}
+}
@@ -0,0 +1,187 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+)
+
+type decodedAccount struct {
+	Address flow.Address `cadence:"address"`
+	Balance float64      `cadence:"balance"`
+	Used    uint64
+	Names   []string
+	Tags    map[string]*big.Int
+	Parent  *flow.Address
+	Raw     cadence.UFix64 `cadence:"balance"`
+	Ignored string         `cadence:"-"`
+}
+
+var accountType = &cadence.StructType{
+	QualifiedIdentifier: "Account",
+	Fields: []cadence.Field{
+		{Identifier: "address", Type: cadence.AddressType{}},
+		{Identifier: "balance", Type: cadence.UFix64Type{}},
+		{Identifier: "used", Type: cadence.UInt64Type{}},
+		{Identifier: "names", Type: cadence.NewVariableSizedArrayType(cadence.StringType{})},
+		{Identifier: "tags", Type: cadence.NewDictionaryType(cadence.StringType{}, cadence.IntType{})},
+		{Identifier: "parent", Type: cadence.NewOptionalType(cadence.AddressType{})},
+	},
+}
+
+func newAccountValue(i int) cadence.Value {
+	address := flow.HexToAddress(fmt.Sprintf("%x", i+1))
+	balance, _ := cadence.NewUFix64("12.5")
+	return cadence.NewStruct([]cadence.Value{
+		cadence.NewAddress(address),
+		balance,
+		cadence.NewUInt64(uint64(i)),
+		cadence.NewArray([]cadence.Value{cadence.String("A"), cadence.String("B")}),
+		cadence.NewDictionary([]cadence.KeyValuePair{{Key: cadence.String("tag"), Value: cadence.NewInt(i)}}),
+		cadence.NewOptional(nil),
+	}).WithType(accountType)
+}
+
+func TestDecodeValue(t *testing.T) {
+	var accounts []decodedAccount
+	err := DecodeValue(cadence.NewArray([]cadence.Value{newAccountValue(1)}), &accounts)
+	require.NoError(t, err)
+	require.Equal(t, []decodedAccount{{
+		Address: flow.HexToAddress("2"),
+		Balance: 12.5,
+		Used:    1,
+		Names:   []string{"A", "B"},
+		Tags:    map[string]*big.Int{"tag": big.NewInt(1)},
+		Raw:     1_250_000_000,
+	}}, accounts)
+
+	t.Run("optional", func(t *testing.T) {
+		var address *flow.Address
+		require.NoError(t, DecodeValue(cadence.NewOptional(cadence.NewAddress(flow.HexToAddress("1"))), &address))
+		require.Equal(t, flow.HexToAddress("1"), *address)
+		require.NoError(t, DecodeValue(cadence.NewOptional(nil), &address))
+		require.Nil(t, address)
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		var small int8
+		err := DecodeValue(cadence.NewInt(300), &small)
+		var decodeErr *DecodeError
+		require.ErrorAs(t, err, &decodeErr)
+	})
+
+	t.Run("errors have a path", func(t *testing.T) {
+		var wrong []struct {
+			Used string
+		}
+		err := DecodeValue(cadence.NewArray([]cadence.Value{newAccountValue(1)}), &wrong)
+		var decodeErr *DecodeError
+		require.ErrorAs(t, err, &decodeErr)
+		require.Equal(t, "[0].used", decodeErr.Path)
+	})
+
+	t.Run("missing tagged field", func(t *testing.T) {
+		var missing struct {
+			Missing string `cadence:"missing"`
+		}
+		require.Error(t, DecodeValue(newAccountValue(1), &missing))
+	})
+}
+
+// jsonValue converts a Cadence value to plain Go values, as the handlers that decode
+// the results with encoding/json do before marshalling them.
+func jsonValue(value cadence.Value) any {
+	switch v := value.(type) {
+	case cadence.Optional:
+		if v.Value == nil {
+			return nil
+		}
+		return jsonValue(v.Value)
+	case cadence.Struct:
+		fields := make(map[string]any, len(v.Fields))
+		for i, field := range v.StructType.Fields {
+			fields[field.Identifier] = jsonValue(v.Fields[i])
+		}
+		return fields
+	case cadence.Array:
+		values := make([]any, len(v.Values))
+		for i, value := range v.Values {
+			values[i] = jsonValue(value)
+		}
+		return values
+	case cadence.Dictionary:
+		values := make(map[string]any, len(v.Pairs))
+		for _, pair := range v.Pairs {
+			values[string(pair.Key.(cadence.String))] = jsonValue(pair.Value)
+		}
+		return values
+	case cadence.String:
+		return string(v)
+	case cadence.Address:
+		return v.Hex()
+	case cadence.UFix64:
+		return float64(v) / 1e8
+	default:
+		return v.ToGoValue()
+	}
+}
+
+// BenchmarkDecodeValue compares DecodeValue with converting the result to JSON and decoding it with encoding/json.
+func BenchmarkDecodeValue(b *testing.B) {
+	values := make([]cadence.Value, 1000)
+	for i := range values {
+		values[i] = newAccountValue(i)
+	}
+	result := cadence.NewArray(values)
+
+	type jsonAccount struct {
+		Address string              `json:"address"`
+		Balance float64             `json:"balance"`
+		Used    uint64              `json:"used"`
+		Names   []string            `json:"names"`
+		Tags    map[string]*big.Int `json:"tags"`
+		Parent  *string             `json:"parent"`
+	}
+
+	b.Run("DecodeValue", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var accounts []decodedAccount
+			if err := DecodeValue(result, &accounts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("JSON", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, err := json.Marshal(jsonValue(result))
+			if err != nil {
+				b.Fatal(err)
+			}
+			var accounts []jsonAccount
+			if err := json.Unmarshal(data, &accounts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
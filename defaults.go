@@ -14,6 +14,10 @@
 
 package scanner
 
+import (
+	"time"
+)
+
 const defaultScript = `
 pub struct AccountInfo {
 	pub(set) var address: Address
@@ -43,4 +47,26 @@ func (n NoOpStatusReporter) ReportIsFullScanRunning(bool) {}
 
 func (n NoOpStatusReporter) ReportFullScanProgress(uint64, uint64) {}
 
+func (n NoOpStatusReporter) ReportFullScanThroughput(float64, time.Duration) {}
+
+func (n NoOpStatusReporter) ReportCandidates(int, int) {}
+
+func (n NoOpStatusReporter) ReportQueueDepth(string, int) {}
+
+func (n NoOpStatusReporter) ReportQueueHighWaterMark(string, int) {}
+
+func (n NoOpStatusReporter) ReportBatchSize(int) {}
+
+func (n NoOpStatusReporter) ReportFullScanThrottle(FullScanThrottleState) {}
+
+func (n NoOpStatusReporter) ReportScriptWorkers(int, int) {}
+
+func (n NoOpStatusReporter) ReportHandlerErrors(int) {}
+
+func (n NoOpStatusReporter) ReportRateLimit(string, string, float64) {}
+
+func (n NoOpStatusReporter) ReportBatchProcessed(int, time.Duration) {}
+
+func (n NoOpStatusReporter) ReportScriptError() {}
+
 var _ StatusReporter = NoOpStatusReporter{}
@@ -0,0 +1,215 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diff compares the results of two scans, e.g. at the heights H1 and H2,
+// and returns the addresses whose results were added, removed or changed in between.
+//
+// The results of a scan are read into a Snapshot from the output of a persistence handler,
+// e.g. with ReadJSONLines or with the Results of a sqlite.DB:
+//
+//	before, err := diff.ReadJSONLines(oldFile, "")
+//	...
+//	after := diff.Snapshot{}
+//	err = db.Results(ctx, "", after.Add)
+//	...
+//	changes := diff.Compare(before.Values(), after.Values(), diff.EqualValues)
+package diff
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+	"github.com/onflow/flow-go-sdk"
+
+	scanner "github.com/onflow/flow-batch-scan"
+)
+
+// Record is the result of an address in a snapshot.
+type Record struct {
+	BlockHeight uint64
+	Value       cadence.Value
+}
+
+// Snapshot is the result of each address of a scan.
+type Snapshot map[flow.Address]Record
+
+// Add adds the result of an address scanned at the block height,
+// unless the snapshot has a result of a higher block height for the address.
+func (s Snapshot) Add(result scanner.AddressResult, blockHeight uint64) error {
+	if existing, ok := s[result.Address]; ok && existing.BlockHeight > blockHeight {
+		return nil
+	}
+	s[result.Address] = Record{
+		BlockHeight: blockHeight,
+		Value:       result.Value,
+	}
+	return nil
+}
+
+// Values returns the result of each address.
+func (s Snapshot) Values() map[flow.Address]cadence.Value {
+	values := make(map[flow.Address]cadence.Value, len(s))
+	for address, record := range s {
+		values[address] = record.Value
+	}
+	return values
+}
+
+// Decode decodes the result of each address, e.g. with a decoder of the scripts package,
+// so the results can be compared by their Go values.
+func Decode[T any](snapshot Snapshot, decode func(cadence.Value) (T, error)) (map[flow.Address]T, error) {
+	values := make(map[flow.Address]T, len(snapshot))
+	for address, record := range snapshot {
+		value, err := decode(record.Value)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode the result of %s: %w", address, err)
+		}
+		values[address] = value
+	}
+	return values, nil
+}
+
+// jsonLine has the fields of both the handlers.BatchRecord and the handlers.AddressRecord.
+type jsonLine struct {
+	BlockHeight uint64          `json:"block_height"`
+	Address     *flow.Address   `json:"address"`
+	Addresses   []flow.Address  `json:"addresses"`
+	ScriptName  string          `json:"script_name"`
+	Result      json.RawMessage `json:"result"`
+}
+
+// ReadJSONLines reads a snapshot from the output of a handlers.JSONLinesHandler,
+// with either per batch or per address records. Only the results of the script scriptName are read,
+// scriptName is empty if the scan has a single script.
+// The results of the batch records are split by address, see scanner.ProcessedAddressBatch.PerAddressResults.
+func ReadJSONLines(r io.Reader, scriptName string) (Snapshot, error) {
+	snapshot := Snapshot{}
+	reader := bufio.NewReader(r)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			err := snapshot.addJSONLine(line, scriptName)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return snapshot, nil
+		}
+	}
+}
+
+func (s Snapshot) addJSONLine(line []byte, scriptName string) error {
+	var record jsonLine
+	err := json.Unmarshal(line, &record)
+	if err != nil {
+		return err
+	}
+	if record.ScriptName != scriptName {
+		return nil
+	}
+	value, err := jsoncdc.Decode(nil, record.Result)
+	if err != nil {
+		return fmt.Errorf("could not decode the result: %w", err)
+	}
+
+	if record.Address != nil {
+		return s.Add(scanner.AddressResult{Address: *record.Address, Value: value}, record.BlockHeight)
+	}
+	batch := scanner.ProcessedAddressBatch{
+		AddressBatch: scanner.AddressBatch{
+			Addresses:   record.Addresses,
+			BlockHeight: record.BlockHeight,
+		},
+		Result: value,
+	}
+	results, err := batch.PerAddressResults()
+	if err != nil {
+		return fmt.Errorf("failed to split the result by address: %w", err)
+	}
+	for _, result := range results {
+		err = s.Add(result, record.BlockHeight)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "added"
+	Removed ChangeKind = "removed"
+	Changed ChangeKind = "changed"
+)
+
+// Change is an address whose result was added, removed or changed between two snapshots.
+// Old is the zero value for added results, and New for removed results.
+type Change[T any] struct {
+	Address flow.Address
+	Kind    ChangeKind
+	Old     T
+	New     T
+}
+
+// Compare returns the changes from the results before to the results after, ordered by address.
+// equal decides if the result of an address changed, see EqualValues and ByKey.
+func Compare[T any](before, after map[flow.Address]T, equal func(before, after T) bool) []Change[T] {
+	var changes []Change[T]
+	for address, oldValue := range before {
+		newValue, ok := after[address]
+		switch {
+		case !ok:
+			changes = append(changes, Change[T]{Address: address, Kind: Removed, Old: oldValue})
+		case !equal(oldValue, newValue):
+			changes = append(changes, Change[T]{Address: address, Kind: Changed, Old: oldValue, New: newValue})
+		}
+	}
+	for address, newValue := range after {
+		if _, ok := before[address]; !ok {
+			changes = append(changes, Change[T]{Address: address, Kind: Added, New: newValue})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return bytes.Compare(changes[i].Address[:], changes[j].Address[:]) < 0
+	})
+	return changes
+}
+
+// EqualValues compares two Cadence values by their value, ignoring their static types.
+func EqualValues(before, after cadence.Value) bool {
+	if before == nil || after == nil {
+		return before == after
+	}
+	return before.String() == after.String()
+}
+
+// ByKey compares the results by a key, so only the part of the results the key covers is compared,
+// e.g. the balance of a struct with more fields.
+func ByKey[T any, K comparable](key func(T) K) func(before, after T) bool {
+	return func(before, after T) bool {
+		return key(before) == key(after)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/diff"
+	"github.com/onflow/flow-batch-scan/handlers"
+)
+
+func balancesBatch(height uint64, balances map[flow.Address]cadence.UFix64) scanner.ProcessedAddressBatch {
+	pairs := make([]cadence.KeyValuePair, 0, len(balances))
+	addresses := make([]flow.Address, 0, len(balances))
+	for address, balance := range balances {
+		pairs = append(pairs, cadence.KeyValuePair{Key: cadence.Address(address), Value: balance})
+		addresses = append(addresses, address)
+	}
+	return scanner.ProcessedAddressBatch{
+		AddressBatch: scanner.AddressBatch{Addresses: addresses, BlockHeight: height},
+		Result:       cadence.NewDictionary(pairs),
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a1 := flow.HexToAddress("01")
+	a2 := flow.HexToAddress("02")
+	a3 := flow.HexToAddress("03")
+
+	var before bytes.Buffer
+	handler := handlers.NewJSONLinesHandler(&before)
+	require.NoError(t, handler.Handle(balancesBatch(100, map[flow.Address]cadence.UFix64{a1: 1, a2: 2})))
+	// a newer result of the same scan
+	require.NoError(t, handler.Handle(balancesBatch(110, map[flow.Address]cadence.UFix64{a2: 3})))
+
+	var after bytes.Buffer
+	handler = handlers.NewJSONLinesHandler(&after, handlers.WithPerAddressRecords())
+	require.NoError(t, handler.Handle(balancesBatch(200, map[flow.Address]cadence.UFix64{a2: 3, a3: 4})))
+
+	beforeSnapshot, err := diff.ReadJSONLines(&before, "")
+	require.NoError(t, err)
+	require.Equal(t, uint64(110), beforeSnapshot[a2].BlockHeight)
+	afterSnapshot, err := diff.ReadJSONLines(&after, "")
+	require.NoError(t, err)
+
+	changes := diff.Compare(beforeSnapshot.Values(), afterSnapshot.Values(), diff.EqualValues)
+	require.Equal(t, []diff.Change[cadence.Value]{
+		{Address: a1, Kind: diff.Removed, Old: cadence.UFix64(1)},
+		{Address: a3, Kind: diff.Added, New: cadence.UFix64(4)},
+	}, changes)
+
+	// decoded values compared by a key
+	type balance struct {
+		Balance cadence.UFix64
+		Height  uint64
+	}
+	decode := func(snapshot diff.Snapshot) map[flow.Address]balance {
+		balances, err := diff.Decode(snapshot, func(value cadence.Value) (balance, error) {
+			return balance{Balance: value.(cadence.UFix64)}, nil
+		})
+		require.NoError(t, err)
+		for address, b := range balances {
+			b.Height = snapshot[address].BlockHeight
+			balances[address] = b
+		}
+		return balances
+	}
+	balanceChanges := diff.Compare(decode(beforeSnapshot), decode(afterSnapshot), diff.ByKey(func(b balance) cadence.UFix64 {
+		return b.Balance
+	}))
+	require.Len(t, balanceChanges, 2)
+
+	_, err = diff.ReadJSONLines(strings.NewReader("{\n"), "")
+	require.ErrorContains(t, err, "line 1")
+}
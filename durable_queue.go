@@ -0,0 +1,340 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"sync"
+
+	"github.com/dgraph-io/badger/v2"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+)
+
+// DurableAddressBatchQueueName is the name of the durable queue, see Config.DurableQueuePath.
+const DurableAddressBatchQueueName = "address_batches_durable"
+
+// durableBatch is the part of an AddressBatch that is stored in the durable queue.
+type durableBatch struct {
+	Addresses   []flow.Address
+	BlockHeight uint64
+	Provenance  map[flow.Address][]candidates.Provenance
+	ScriptName  string
+}
+
+// queuedBatch is the part of an AddressBatch that stays in memory while the batch is in the durable queue.
+type queuedBatch struct {
+	doneHandling func()
+	isValid      func() bool
+	spanContext  trace.SpanContext
+	fromFullScan bool
+}
+
+// durableQueue is a queue of address batches between the candidate discovery and the script execution,
+// that is stored in a Badger database, so the batches that were not executed survive a crash,
+// and a slow script runner does not hold the waiting batches in memory.
+// The batches are removed from the database once they are done. The batches that are left in the database
+// when it is opened (e.g. after a crash) are executed first.
+type durableQueue struct {
+	*ComponentBase
+
+	db  *badger.DB
+	in  <-chan AddressBatch
+	out chan<- AddressBatch
+
+	mu sync.Mutex
+	// recovered are the sequence numbers of the batches of a previous run.
+	recovered []uint64
+	// head is the sequence number of the next batch of this run to be sent,
+	// and tail the sequence number of the next batch to be queued.
+	head, tail uint64
+	// queued are the batches of this run that were not sent yet.
+	queued map[uint64]queuedBatch
+	notify chan struct{}
+}
+
+var _ Component = (*durableQueue)(nil)
+
+// openDurableQueue opens the queue in the directory at path, it is created if it does not exist.
+func openDurableQueue(
+	path string,
+	in <-chan AddressBatch,
+	out chan<- AddressBatch,
+	logger zerolog.Logger,
+) (*durableQueue, error) {
+	db, err := badger.Open(badger.DefaultOptions(path).WithLogger(nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the durable queue at %s: %w", path, err)
+	}
+	q := &durableQueue{
+		db:     db,
+		in:     in,
+		out:    out,
+		queued: make(map[uint64]queuedBatch),
+		notify: make(chan struct{}, 1),
+	}
+	err = db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{PrefetchValues: false})
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			q.recovered = append(q.recovered, binary.BigEndian.Uint64(it.Item().Key()))
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to read the durable queue at %s: %w", path, err)
+	}
+	if len(q.recovered) > 0 {
+		q.head = q.recovered[len(q.recovered)-1] + 1
+		q.tail = q.head
+	}
+	q.ComponentBase = NewComponentWithStart(
+		"durable_queue",
+		q.start,
+		logger,
+	)
+	if len(q.recovered) > 0 {
+		q.Logger.Info().
+			Int("batches", len(q.recovered)).
+			Str("path", path).
+			Msg("Recovered address batches from the durable queue")
+	}
+	return q, nil
+}
+
+func (q *durableQueue) start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				q.Finish(ctx.Err())
+				return
+			case batch, ok := <-q.in:
+				if !ok {
+					q.Finish(nil)
+					return
+				}
+				if err := q.push(batch); err != nil {
+					batch.DoneHandling()
+					q.Finish(err)
+					return
+				}
+			}
+		}
+	}()
+	go func() {
+		for {
+			batch, err := q.pop(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					q.Finish(err)
+				}
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case q.out <- batch:
+			}
+		}
+	}()
+}
+
+// push stores the batch, and keeps the rest of it in memory until it is sent.
+func (q *durableQueue) push(batch AddressBatch) error {
+	if !batch.IsValid() {
+		return nil
+	}
+	var value bytes.Buffer
+	err := gob.NewEncoder(&value).Encode(durableBatch{
+		Addresses:   batch.Addresses,
+		BlockHeight: batch.BlockHeight,
+		Provenance:  batch.Provenance,
+		ScriptName:  batch.ScriptName,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode the batch at height %d: %w", batch.BlockHeight, err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	seq := q.tail
+	err = q.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(durableQueueKey(seq), value.Bytes())
+	})
+	if err != nil {
+		return fmt.Errorf("failed to queue the batch at height %d: %w", batch.BlockHeight, err)
+	}
+	q.tail++
+	q.queued[seq] = queuedBatch{
+		doneHandling: batch.doneHandling,
+		isValid:      batch.isValid,
+		spanContext:  batch.spanContext,
+		fromFullScan: batch.fromFullScan,
+	}
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// pop waits for the next batch, and reads it back.
+// The recovered batches of a previous run come first.
+func (q *durableQueue) pop(ctx context.Context) (AddressBatch, error) {
+	for {
+		q.mu.Lock()
+		seq, recovered, ok := q.next()
+		var queued queuedBatch
+		if ok && !recovered {
+			queued = q.queued[seq]
+			delete(q.queued, seq)
+		}
+		q.mu.Unlock()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return AddressBatch{}, ctx.Err()
+			case <-q.notify:
+				continue
+			}
+		}
+
+		stored, err := q.read(seq)
+		if err != nil {
+			return AddressBatch{}, err
+		}
+		batch := NewAddressBatch(
+			stored.Addresses,
+			stored.BlockHeight,
+			func() {
+				q.remove(seq)
+				if queued.doneHandling != nil {
+					queued.doneHandling()
+				}
+			},
+			queued.isValid,
+		)
+		batch.Provenance = stored.Provenance
+		batch.ScriptName = stored.ScriptName
+		batch.spanContext = queued.spanContext
+		batch.fromFullScan = queued.fromFullScan
+		if !batch.IsValid() {
+			continue
+		}
+		return batch, nil
+	}
+}
+
+// next is the sequence number of the next batch to be sent, and advances the queue.
+// It has to be called with the lock held.
+func (q *durableQueue) next() (seq uint64, recovered bool, ok bool) {
+	if len(q.recovered) > 0 {
+		seq = q.recovered[0]
+		q.recovered = q.recovered[1:]
+		return seq, true, true
+	}
+	if q.head == q.tail {
+		return 0, false, false
+	}
+	seq = q.head
+	q.head++
+	return seq, false, true
+}
+
+func (q *durableQueue) read(seq uint64) (durableBatch, error) {
+	var stored durableBatch
+	err := q.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(durableQueueKey(seq))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(value []byte) error {
+			return gob.NewDecoder(bytes.NewReader(value)).Decode(&stored)
+		})
+	})
+	if err != nil {
+		return durableBatch{}, fmt.Errorf("failed to read batch %d of the durable queue: %w", seq, err)
+	}
+	return stored, nil
+}
+
+// remove removes a done batch. If it fails, the batch is executed again after a restart.
+func (q *durableQueue) remove(seq uint64) {
+	err := q.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(durableQueueKey(seq))
+	})
+	if err != nil {
+		q.Logger.Warn().
+			Err(err).
+			Uint64("seq", seq).
+			Msg("failed to remove a done batch from the durable queue")
+	}
+}
+
+// pending are the batches in the database, the batches that are waiting and the batches that are not done.
+func (q *durableQueue) pending() ([]pendingBatchRecord, error) {
+	var pending []pendingBatchRecord
+	err := q.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			var stored durableBatch
+			err := it.Item().Value(func(value []byte) error {
+				return gob.NewDecoder(bytes.NewReader(value)).Decode(&stored)
+			})
+			if err != nil {
+				return err
+			}
+			pending = append(pending, pendingBatchRecord{
+				Addresses:   stored.Addresses,
+				BlockHeight: stored.BlockHeight,
+				ScriptName:  stored.ScriptName,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the durable queue: %w", err)
+	}
+	return pending, nil
+}
+
+// len is the number of batches waiting in the queue.
+func (q *durableQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.recovered) + int(q.tail-q.head)
+}
+
+// Close closes the database. The batches that are not done stay in it.
+func (q *durableQueue) Close() error {
+	return q.db.Close()
+}
+
+func durableQueueKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
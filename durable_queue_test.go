@@ -0,0 +1,64 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDurableQueue(t *testing.T) {
+	path := t.TempDir()
+	a1, a2 := flow.HexToAddress("0x1"), flow.HexToAddress("0x2")
+
+	// a crash: the batches are queued, but never done
+	q, err := openDurableQueue(path, nil, nil, zerolog.Nop())
+	require.NoError(t, err)
+	require.NoError(t, q.push(NewAddressBatch([]flow.Address{a1}, 10, nil, nil)))
+	require.NoError(t, q.push(NewAddressBatch([]flow.Address{a2}, 11, nil, nil)))
+	require.Equal(t, 2, q.len())
+	require.NoError(t, q.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in := make(chan AddressBatch)
+	out := make(chan AddressBatch)
+	q, err = openDurableQueue(path, in, out, zerolog.Nop())
+	require.NoError(t, err)
+	<-q.Start(ctx)
+
+	// the recovered batches come first
+	done := make(chan struct{})
+	in <- NewAddressBatch([]flow.Address{a1}, 12, func() { close(done) }, nil)
+	for _, height := range []uint64{10, 11, 12} {
+		batch := <-out
+		require.Equal(t, height, batch.BlockHeight)
+		batch.DoneHandling()
+	}
+	<-done
+	cancel()
+	<-q.Done()
+	require.NoError(t, q.Close())
+
+	// all the batches were done
+	q, err = openDurableQueue(path, nil, nil, zerolog.Nop())
+	require.NoError(t, err)
+	require.Equal(t, 0, q.len())
+	require.NoError(t, q.Close())
+}
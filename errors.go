@@ -0,0 +1,196 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/onflow/flow-go-sdk"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The error classes of the scan. The errors returned by Scan, and passed to the ScriptErrorHandler
+// and FailedBatchHandler, can be checked against them with errors.Is.
+var (
+	// ErrScriptComputationLimit is the class of scripts that exceeded the computation limit.
+	ErrScriptComputationLimit = errors.New("script computation limit exceeded")
+	// ErrScriptMemoryLimit is the class of scripts that exceeded the memory limit.
+	ErrScriptMemoryLimit = errors.New("script memory limit exceeded")
+	// ErrAccountFrozen is the class of scripts that failed because one of the accounts is frozen.
+	ErrAccountFrozen = errors.New("account is frozen")
+	// ErrAccountNotFound is the class of requests for an account that does not exist.
+	ErrAccountNotFound = errors.New("account not found")
+	// ErrMissingContract is the class of scripts that import a contract, or use a type, that does not exist.
+	ErrMissingContract = errors.New("missing contract or type")
+	// ErrTransient is the class of requests that failed because the access node was unavailable or timed out,
+	// and that are likely to succeed when they are retried.
+	ErrTransient = errors.New("transient access node error")
+	// ErrRateLimited is the class of requests that were rejected by the rate limits of the access node.
+	ErrRateLimited = errors.New("rate limited by the access node")
+	// ErrBlockNotFound is the class of requests for a block, or the execution state of a block,
+	// that the access node does not have.
+	ErrBlockNotFound = errors.New("block not found")
+	// ErrHandlerFailed is the class of errors returned by the ScriptResultHandler.
+	ErrHandlerFailed = errors.New("result handler failed")
+	// ErrStalled is the class of scans that were failed by the watchdog, because a pipeline stage stalled.
+	ErrStalled = errors.New("scan stalled")
+	// ErrUnexpectedResultType is the class of script results that do not have the expected type,
+	// see ResultTypeError.
+	ErrUnexpectedResultType = errors.New("unexpected result type")
+)
+
+// ClassifyError returns the error class of err, or nil if the class is not known.
+// Errors that already have a class, like BatchError and BlockRangeError, keep it.
+func ClassifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) && batchErr.Class != nil {
+		return batchErr.Class
+	}
+	var rangeErr *BlockRangeError
+	if errors.As(err, &rangeErr) && rangeErr.Class != nil {
+		return rangeErr.Class
+	}
+	if errors.Is(err, ErrScriptTimeout) {
+		return ErrScriptTimeout
+	}
+	var typeErr *ResultTypeError
+	if errors.As(err, &typeErr) {
+		return ErrUnexpectedResultType
+	}
+
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "[Error Code: 1110]"):
+		return ErrScriptComputationLimit
+	case strings.Contains(message, "[Error Code: 1111]"):
+		return ErrScriptMemoryLimit
+	case strings.Contains(message, "[Error Code: 1204]"):
+		return ErrAccountFrozen
+	case strings.Contains(message, "[Error Code: 1201]"):
+		return ErrAccountNotFound
+	case strings.Contains(message, "[Error Code: 1251]"),
+		strings.Contains(message, "cannot find declaration"),
+		strings.Contains(message, "cannot find type in this scope"):
+		return ErrMissingContract
+	case strings.Contains(message, "state commitment not found"):
+		return ErrBlockNotFound
+	}
+
+	// the address of the request was not found, not its block
+	var addressErr *AddressError
+	if errors.As(err, &addressErr) && status.Code(addressErr.Err) == codes.NotFound {
+		return ErrAccountNotFound
+	}
+
+	switch status.Code(err) {
+	case codes.ResourceExhausted:
+		return ErrRateLimited
+	case codes.NotFound:
+		// other things than blocks are not found too, e.g. accounts, contracts or transactions
+		if strings.Contains(strings.ToLower(message), "block") {
+			return ErrBlockNotFound
+		}
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted:
+		return ErrTransient
+	}
+	return nil
+}
+
+// BatchError is the error of running the script for, or handling the result of, a batch of addresses.
+type BatchError struct {
+	// Class is one of the error classes, e.g. ErrScriptComputationLimit, or nil if the class is not known.
+	Class       error
+	ScriptName  string
+	BlockHeight uint64
+	Addresses   []flow.Address
+	Err         error
+}
+
+var _ error = (*BatchError)(nil)
+
+// newBatchError wraps err with the context of the batch.
+// If class is nil, the class of err is used.
+func newBatchError(batch AddressBatch, class error, err error) *BatchError {
+	if class == nil {
+		class = ClassifyError(err)
+	}
+	return &BatchError{
+		Class:       class,
+		ScriptName:  batch.ScriptName,
+		BlockHeight: batch.BlockHeight,
+		Addresses:   batch.Addresses,
+		Err:         err,
+	}
+}
+
+func (e *BatchError) Error() string {
+	if e.ScriptName != "" {
+		return fmt.Sprintf("batch of %d addresses for script %q at block height %d: %v",
+			len(e.Addresses), e.ScriptName, e.BlockHeight, e.Err)
+	}
+	return fmt.Sprintf("batch of %d addresses at block height %d: %v",
+		len(e.Addresses), e.BlockHeight, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the error class of the batch.
+func (e *BatchError) Is(target error) bool {
+	return e.Class != nil && target == e.Class
+}
+
+// BlockRangeError is the error of scanning a range of blocks for candidates.
+type BlockRangeError struct {
+	// Class is one of the error classes, e.g. ErrBlockNotFound, or nil if the class is not known.
+	Class error
+	// Start and End are the inclusive block heights of the range.
+	Start uint64
+	End   uint64
+	Err   error
+}
+
+var _ error = (*BlockRangeError)(nil)
+
+// newBlockRangeError wraps err with the block range, and the class of err.
+func newBlockRangeError(start uint64, end uint64, err error) *BlockRangeError {
+	return &BlockRangeError{
+		Class: ClassifyError(err),
+		Start: start,
+		End:   end,
+		Err:   err,
+	}
+}
+
+func (e *BlockRangeError) Error() string {
+	return fmt.Sprintf("block range %d to %d: %v", e.Start, e.End, e.Err)
+}
+
+func (e *BlockRangeError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the error class of the block range.
+func (e *BlockRangeError) Is(target error) bool {
+	return e.Class != nil && target == e.Class
+}
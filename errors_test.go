@@ -0,0 +1,73 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err   error
+		class error
+	}{
+		{nil, nil},
+		{fmt.Errorf("[Error Code: 1110] computation exceeds limit (9999)"), ErrScriptComputationLimit},
+		{fmt.Errorf("[Error Code: 1111] memory usage exceeds limit"), ErrScriptMemoryLimit},
+		{fmt.Errorf("[Error Code: 1204] account 0x01 is frozen"), ErrAccountFrozen},
+		{fmt.Errorf("failed to execute script: state commitment not found"), ErrBlockNotFound},
+		{status.Error(codes.NotFound, "block not found"), ErrBlockNotFound},
+		{status.Error(codes.NotFound, "could not find contract A"), nil},
+		{fmt.Errorf("wrapped: %w", status.Error(codes.ResourceExhausted, "slow down")), ErrRateLimited},
+		{fmt.Errorf("%w after 1s", ErrScriptTimeout), ErrScriptTimeout},
+		{fmt.Errorf("something else"), nil},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.class, ClassifyError(c.err), "%v", c.err)
+	}
+}
+
+func TestBatchError(t *testing.T) {
+	batch := NewAddressBatch([]flow.Address{flow.HexToAddress("01")}, 100, nil, nil)
+	cause := fmt.Errorf("[Error Code: 1110] computation exceeds limit (9999)")
+	err := fmt.Errorf("scan failed: %w", newBatchError(batch, nil, cause))
+
+	require.ErrorIs(t, err, ErrScriptComputationLimit)
+	require.NotErrorIs(t, err, ErrScriptMemoryLimit)
+	require.ErrorIs(t, err, cause)
+	require.Equal(t, ErrScriptComputationLimit, ClassifyError(err))
+
+	var batchErr *BatchError
+	require.True(t, errors.As(err, &batchErr))
+	require.Equal(t, uint64(100), batchErr.BlockHeight)
+	require.Equal(t, batch.Addresses, batchErr.Addresses)
+
+	handlerErr := newBatchError(batch, ErrHandlerFailed, fmt.Errorf("disk full"))
+	require.ErrorIs(t, handlerErr, ErrHandlerFailed)
+	require.Contains(t, handlerErr.Error(), "disk full")
+}
+
+func TestBlockRangeError(t *testing.T) {
+	err := newBlockRangeError(10, 20, status.Error(codes.ResourceExhausted, "slow down"))
+	require.ErrorIs(t, err, ErrRateLimited)
+	require.Contains(t, err.Error(), "block range 10 to 20")
+}
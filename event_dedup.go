@@ -0,0 +1,90 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"github.com/onflow/flow-go-sdk"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+	"github.com/onflow/flow-batch-scan/utils"
+)
+
+// DefaultEventDedupSize is the number of events remembered for deduplication, when it is enabled without a size.
+const DefaultEventDedupSize = 100_000
+
+type eventKey struct {
+	transactionID flow.Identifier
+	eventIndex    int
+}
+
+// eventDeduplicator drops the candidates of events that were already handled,
+// e.g. because a block range was scanned again after a retry or a failover to another access node.
+// It remembers the last size events. A nil deduplicator drops nothing.
+type eventDeduplicator struct {
+	seen *utils.LRUSet[eventKey]
+}
+
+func newEventDeduplicator(size int) *eventDeduplicator {
+	if size <= 0 {
+		return nil
+	}
+	return &eventDeduplicator{
+		seen: utils.NewLRUSet[eventKey](size),
+	}
+}
+
+// filterCandidates removes the provenance of the events that were already seen.
+// Addresses that were only candidates because of already seen events are removed.
+// Addresses without event provenance are kept.
+func (d *eventDeduplicator) filterCandidates(result candidates.CandidatesResult) candidates.CandidatesResult {
+	if d == nil {
+		return result
+	}
+	// the same event can make several addresses candidates, so the events are only marked as seen
+	// once all the addresses were checked
+	var seen []eventKey
+	isNew := make(map[eventKey]bool)
+	for address, provenance := range result.Provenance {
+		kept := provenance[:0]
+		fromEvents := false
+		for _, p := range provenance {
+			if p.EventType == "" {
+				kept = append(kept, p)
+				continue
+			}
+			fromEvents = true
+			key := eventKey{transactionID: p.TransactionID, eventIndex: p.EventIndex}
+			n, ok := isNew[key]
+			if !ok {
+				n = !d.seen.Contains(key)
+				isNew[key] = n
+				seen = append(seen, key)
+			}
+			if n {
+				kept = append(kept, p)
+			}
+		}
+		if fromEvents && len(kept) == 0 {
+			delete(result.Addresses, address)
+			delete(result.Provenance, address)
+			continue
+		}
+		result.Provenance[address] = kept
+	}
+	for _, key := range seen {
+		d.seen.Add(key)
+	}
+	return result
+}
@@ -0,0 +1,73 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+)
+
+func TestEventDeduplicator(t *testing.T) {
+	a1 := flow.HexToAddress("01")
+	a2 := flow.HexToAddress("02")
+	a3 := flow.HexToAddress("03")
+	tx := flow.HexToID("0a")
+
+	event := func(index int) candidates.Provenance {
+		return candidates.Provenance{
+			Scanner:       candidates.EventCandidatesScannerName,
+			TransactionID: tx,
+			EventType:     "A.0000000000000001.Contract.Event",
+			EventIndex:    index,
+		}
+	}
+	newResult := func() candidates.CandidatesResult {
+		result := candidates.NewCandidatesResult(map[flow.Address]struct{}{})
+		result.Add(a1, event(0))
+		result.Add(a2, event(0))
+		result.Add(a2, event(1))
+		result.Add(a3, candidates.Provenance{Scanner: "other"})
+		return result
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		d := newEventDeduplicator(0)
+		require.Nil(t, d)
+		require.Len(t, d.filterCandidates(newResult()).Addresses, 3)
+		require.Len(t, d.filterCandidates(newResult()).Addresses, 3)
+	})
+
+	t.Run("drops seen events", func(t *testing.T) {
+		d := newEventDeduplicator(10)
+
+		// an event that makes several addresses candidates is not a duplicate of itself
+		result := d.filterCandidates(newResult())
+		require.Len(t, result.Addresses, 3)
+
+		result = candidates.NewCandidatesResult(map[flow.Address]struct{}{})
+		result.Add(a1, event(0))
+		result.Add(a2, event(1))
+		result.Add(a2, event(2))
+		result.Add(a3, candidates.Provenance{Scanner: "other"})
+		result = d.filterCandidates(result)
+
+		require.Equal(t, map[flow.Address]struct{}{a2: {}, a3: {}}, result.Addresses)
+		require.Equal(t, []candidates.Provenance{event(2)}, result.Provenance[a2])
+	})
+}
@@ -0,0 +1,348 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package eventfinder fetches all the events of a type over an arbitrary block height range.
+// The range is split into queries the access node accepts, which run concurrently,
+// and the events are returned in the order they were emitted.
+// Tail follows the chain for new events, and resumes from a persisted cursor after a restart.
+package eventfinder
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	flowgrpc "github.com/onflow/flow-go-sdk/access/grpc"
+	"go.uber.org/ratelimit"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/utils"
+)
+
+// Event is an event with the block and transaction it was emitted in.
+type Event struct {
+	BlockHeight    uint64
+	BlockID        flow.Identifier
+	BlockTimestamp time.Time
+
+	TransactionID    flow.Identifier
+	TransactionIndex int
+	// EventIndex is the index of the event in its transaction.
+	EventIndex int
+
+	Type  string
+	Value cadence.Event
+	// Decoded is the event decoded into the struct registered for its type, see Registry.
+	// It is nil if no struct was registered for the type.
+	Decoded any
+}
+
+// EventKey identifies an event across queries and access nodes.
+type EventKey struct {
+	TransactionID flow.Identifier
+	EventIndex    int
+}
+
+func (e Event) Key() EventKey {
+	return EventKey{TransactionID: e.TransactionID, EventIndex: e.EventIndex}
+}
+
+// DefaultDedupSize is the number of events remembered for deduplication, when it is enabled without a size.
+const DefaultDedupSize = 100_000
+
+type Config struct {
+	// ChunkSize is the maximum number of blocks queried at once.
+	ChunkSize uint64
+	// Concurrency is the maximum number of concurrent queries.
+	// It also limits how many chunks are held in memory, waiting for an earlier chunk to be returned.
+	Concurrency int
+	// RateLimit if set, is the maximum number of queries per second.
+	// The rate limits of the client apply as well.
+	RateLimit int
+	// Registry decodes the events of the registered types into Event.Decoded.
+	Registry *Registry
+	// Filters if set, keep only the events that match all of them. See WithFilter.
+	Filters []Filter
+	// DedupSize if set, is the number of events (by EventKey) remembered to skip duplicate events,
+	// e.g. from retried queries or a failover to another access node. See WithDedup.
+	DedupSize int
+
+	// CursorStore if set, persists the cursor of Tail. See WithCursorStore.
+	CursorStore CursorStore
+	// PollInterval is how often Tail checks for new blocks once it has caught up.
+	PollInterval time.Duration
+	// Sporks if set, are the sporks the block ranges are split at. See WithSporks.
+	Sporks []client.Spork
+	// Flush if set, is called by Tail before the cursor is saved. See WithFlush.
+	Flush func(ctx context.Context) error
+	// Streaming if true, makes Tail subscribe to the event stream of the access node instead of polling.
+	// See WithStreaming.
+	Streaming bool
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ChunkSize:    candidates.DefaultEventQueryChunkSize,
+		Concurrency:  candidates.DefaultEventQueryConcurrency,
+		RateLimit:    0,
+		Registry:     DefaultRegistry,
+		PollInterval: DefaultPollInterval,
+	}
+}
+
+type Option = func(*Config)
+
+// WithChunkSize sets the maximum number of blocks queried at once. Defaults to 250, the limit of the access nodes.
+func WithChunkSize(size uint64) Option {
+	return func(c *Config) {
+		c.ChunkSize = size
+	}
+}
+
+// WithConcurrency sets the maximum number of concurrent queries.
+func WithConcurrency(concurrency int) Option {
+	return func(c *Config) {
+		c.Concurrency = concurrency
+	}
+}
+
+// WithRateLimit sets the maximum number of queries per second.
+func WithRateLimit(perSecond int) Option {
+	return func(c *Config) {
+		c.RateLimit = perSecond
+	}
+}
+
+// WithRegistry sets the registry the events are decoded with. Defaults to the DefaultRegistry.
+func WithRegistry(registry *Registry) Option {
+	return func(c *Config) {
+		c.Registry = registry
+	}
+}
+
+// WithDedup skips events that were already returned, remembering the last size events.
+// If size is 0, DefaultDedupSize is used.
+func WithDedup(size int) Option {
+	return func(c *Config) {
+		if size <= 0 {
+			size = DefaultDedupSize
+		}
+		c.DedupSize = size
+	}
+}
+
+// Find returns all the events of eventType from startHeight to endHeight (inclusive),
+// ordered by block height, transaction index and event index.
+func Find(
+	ctx context.Context,
+	client client.Client,
+	eventType string,
+	startHeight uint64,
+	endHeight uint64,
+	options ...Option,
+) ([]Event, error) {
+	var events []Event
+	err := Stream(ctx, client, eventType, startHeight, endHeight, func(event Event) error {
+		events = append(events, event)
+		return nil
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Stream is like Find, but passes the events to handle as soon as all the earlier events were handled,
+// so arbitrarily large ranges can be processed without holding all the events in memory.
+// If handle returns an error, Stream stops and returns it.
+func Stream(
+	ctx context.Context,
+	client client.Client,
+	eventType string,
+	startHeight uint64,
+	endHeight uint64,
+	handle func(event Event) error,
+	options ...Option,
+) error {
+	if endHeight < startHeight {
+		return fmt.Errorf("invalid block range: end height %d is before start height %d", endHeight, startHeight)
+	}
+	config := newConfig(options)
+	return stream(ctx, client, eventType, startHeight, endHeight, handle, config, newLimiter(config))
+}
+
+func newConfig(options []Option) Config {
+	config := DefaultConfig()
+	for _, option := range options {
+		option(&config)
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	return config
+}
+
+func newLimiter(config Config) ratelimit.Limiter {
+	if config.RateLimit > 0 {
+		return ratelimit.New(config.RateLimit)
+	}
+	return ratelimit.NewUnlimited()
+}
+
+func stream(
+	ctx context.Context,
+	client client.Client,
+	eventType string,
+	startHeight uint64,
+	endHeight uint64,
+	handle func(event Event) error,
+	config Config,
+	limiter ratelimit.Limiter,
+) error {
+	var seen *utils.LRUSet[EventKey]
+	if config.DedupSize > 0 {
+		seen = utils.NewLRUSet[EventKey](config.DedupSize)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunks, err := config.chunks(candidates.BlockRange{Start: startHeight, End: endHeight})
+	if err != nil {
+		return err
+	}
+	results := make([]chan chunkResult, len(chunks))
+	for i := range results {
+		results[i] = make(chan chunkResult, 1)
+	}
+
+	// a slot is taken before a chunk is queried, and released once the chunk was handled,
+	// so at most Concurrency chunks are queried or waiting to be handled
+	slots := make(chan struct{}, config.Concurrency)
+	go func() {
+		for i, chunk := range chunks {
+			select {
+			case <-ctx.Done():
+				return
+			case slots <- struct{}{}:
+			}
+			go func(i int, chunk candidates.BlockRange) {
+				limiter.Take()
+				events, err := query(ctx, client, eventType, chunk, config)
+				results[i] <- chunkResult{events: events, err: err}
+			}(i, chunk)
+		}
+	}()
+
+	for i := range chunks {
+		var result chunkResult
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case result = <-results[i]:
+		}
+		if result.err != nil {
+			return fmt.Errorf("failed to get events of type %s from block %d to %d: %w",
+				eventType, chunks[i].Start, chunks[i].End, result.err)
+		}
+		for _, event := range result.events {
+			if seen != nil && !seen.Add(event.Key()) {
+				continue
+			}
+			if err := handle(event); err != nil {
+				return err
+			}
+		}
+		<-slots
+	}
+	return nil
+}
+
+type chunkResult struct {
+	events []Event
+	err    error
+}
+
+func query(
+	ctx context.Context,
+	client client.Client,
+	eventType string,
+	chunk candidates.BlockRange,
+	config Config,
+) ([]Event, error) {
+	blockEvents, err := client.GetEventsForHeightRange(ctx, flowgrpc.EventRangeQuery{
+		Type:        eventType,
+		StartHeight: chunk.Start,
+		EndHeight:   chunk.End,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toEvents(blockEvents, config)
+}
+
+// toEvents decodes and filters the events of the blocks, and sorts them.
+func toEvents(blockEvents []flow.BlockEvents, config Config) ([]Event, error) {
+	var events []Event
+	for _, block := range blockEvents {
+		for _, event := range block.Events {
+			var decoded any
+			if config.Registry != nil && config.Registry.Registered(event.Type) {
+				var err error
+				decoded, err = config.Registry.Decode(event.Value)
+				if err != nil {
+					return nil, err
+				}
+			}
+			found := Event{
+				BlockHeight:      block.Height,
+				BlockID:          block.BlockID,
+				BlockTimestamp:   block.BlockTimestamp,
+				TransactionID:    event.TransactionID,
+				TransactionIndex: event.TransactionIndex,
+				EventIndex:       event.EventIndex,
+				Type:             event.Type,
+				Value:            event.Value,
+				Decoded:          decoded,
+			}
+			keep, err := And(config.Filters...)(found)
+			if err != nil {
+				return nil, fmt.Errorf("failed to filter event at height %d: %w", block.Height, err)
+			}
+			if keep {
+				events = append(events, found)
+			}
+		}
+	}
+	sortEvents(events)
+	return events, nil
+}
+
+// sortEvents sorts the events by block height, transaction index and event index.
+func sortEvents(events []Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if a.BlockHeight != b.BlockHeight {
+			return a.BlockHeight < b.BlockHeight
+		}
+		if a.TransactionIndex != b.TransactionIndex {
+			return a.TransactionIndex < b.TransactionIndex
+		}
+		return a.EventIndex < b.EventIndex
+	})
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventfinder_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	flowgrpc "github.com/onflow/flow-go-sdk/access/grpc"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+	"github.com/onflow/flow-batch-scan/eventfinder"
+)
+
+const eventType = "A.0000000000000001.Contract.Event"
+
+func TestFind(t *testing.T) {
+	c := clienttest.New()
+	c.AddBlocks(1, 100)
+	for height := uint64(1); height <= 100; height += 7 {
+		c.AddBlock(height,
+			clienttest.Transaction{Events: []flow.Event{{Type: eventType}, {Type: "A.0000000000000001.Contract.Other"}}},
+			clienttest.Transaction{Events: []flow.Event{{Type: eventType}, {Type: eventType}}},
+		)
+	}
+
+	events, err := eventfinder.Find(context.Background(), c, eventType, 1, 100,
+		eventfinder.WithChunkSize(10),
+		eventfinder.WithConcurrency(3),
+	)
+	require.NoError(t, err)
+	require.Len(t, events, 15*3)
+	require.Equal(t, 10, c.Calls(client.MethodGetEventsForHeightRange))
+
+	for i := 1; i < len(events); i++ {
+		previous, event := events[i-1], events[i]
+		require.Equal(t, eventType, event.Type)
+		require.True(t, less(previous, event), "events out of order at %d", i)
+	}
+	require.Equal(t, clienttest.BlockID(1), events[0].BlockID)
+}
+
+func TestStream_Error(t *testing.T) {
+	c := clienttest.New()
+	c.AddBlocks(1, 100)
+	c.SetError(client.MethodGetEventsForHeightRange, fmt.Errorf("unavailable"))
+
+	err := eventfinder.Stream(context.Background(), c, eventType, 1, 100, func(eventfinder.Event) error {
+		return nil
+	})
+	require.ErrorContains(t, err, "unavailable")
+
+	_, err = eventfinder.Find(context.Background(), c, eventType, 10, 1)
+	require.Error(t, err)
+}
+
+func TestFind_Sporks(t *testing.T) {
+	c := clienttest.New()
+	c.AddBlocks(1, 100)
+	for height := uint64(50); height <= 60; height++ {
+		c.AddBlock(height, clienttest.Transaction{Events: []flow.Event{{Type: eventType}}})
+	}
+	sporks := []client.Spork{
+		{Name: "spork-2", RootHeight: 55},
+		{Name: "spork-1", RootHeight: 10},
+	}
+
+	events, err := eventfinder.Find(context.Background(), c, eventType, 41, 100,
+		eventfinder.WithChunkSize(10),
+		eventfinder.WithSporks(sporks),
+	)
+	require.NoError(t, err)
+	require.Len(t, events, 11)
+	// 41-50 and 51-54 in the first spork, 55-64 ... 95-100 in the second
+	require.Equal(t, 2+5, c.Calls(client.MethodGetEventsForHeightRange))
+
+	_, err = eventfinder.Find(context.Background(), c, eventType, 1, 100, eventfinder.WithSporks(sporks))
+	require.ErrorContains(t, err, "before the first spork spork-1")
+}
+
+// duplicatingClient returns every event twice, like overlapping responses of different access nodes.
+type duplicatingClient struct {
+	*clienttest.Client
+}
+
+func (c duplicatingClient) GetEventsForHeightRange(
+	ctx context.Context,
+	query flowgrpc.EventRangeQuery,
+) ([]flow.BlockEvents, error) {
+	blocks, err := c.Client.GetEventsForHeightRange(ctx, query)
+	for i := range blocks {
+		blocks[i].Events = append(blocks[i].Events, blocks[i].Events...)
+	}
+	return blocks, err
+}
+
+func TestFind_Dedup(t *testing.T) {
+	c := clienttest.New()
+	c.AddBlocks(1, 20)
+	for height := uint64(1); height <= 20; height += 5 {
+		c.AddBlock(height,
+			clienttest.Transaction{Events: []flow.Event{{Type: eventType}, {Type: eventType}}},
+		)
+	}
+	dc := duplicatingClient{Client: c}
+
+	events, err := eventfinder.Find(context.Background(), dc, eventType, 1, 20, eventfinder.WithChunkSize(10))
+	require.NoError(t, err)
+	require.Len(t, events, 4*2*2)
+
+	events, err = eventfinder.Find(context.Background(), dc, eventType, 1, 20,
+		eventfinder.WithChunkSize(10),
+		eventfinder.WithDedup(0),
+	)
+	require.NoError(t, err)
+	require.Len(t, events, 4*2)
+	for i := 1; i < len(events); i++ {
+		require.NotEqual(t, events[i-1].Key(), events[i].Key())
+	}
+}
+
+func less(a, b eventfinder.Event) bool {
+	if a.BlockHeight != b.BlockHeight {
+		return a.BlockHeight < b.BlockHeight
+	}
+	if a.TransactionIndex != b.TransactionIndex {
+		return a.TransactionIndex < b.TransactionIndex
+	}
+	return a.EventIndex < b.EventIndex
+}
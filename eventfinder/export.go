@@ -0,0 +1,465 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventfinder
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/onflow/cadence"
+)
+
+// ColumnKind is the Go type of the values of a column.
+type ColumnKind int
+
+const (
+	// ColumnString values are strings. Nested values that are not flattened are encoded as JSON strings.
+	ColumnString ColumnKind = iota
+	ColumnBool
+	ColumnInt64
+	ColumnUint64
+	ColumnFloat64
+)
+
+func (k ColumnKind) String() string {
+	switch k {
+	case ColumnBool:
+		return "bool"
+	case ColumnInt64:
+		return "int64"
+	case ColumnUint64:
+		return "uint64"
+	case ColumnFloat64:
+		return "float64"
+	default:
+		return "string"
+	}
+}
+
+// Column is a column of a flattened event.
+type Column struct {
+	Name string
+	Kind ColumnKind
+}
+
+// The columns of the block and transaction every event was emitted in. They are the first columns of every row.
+var baseColumns = []Column{
+	{Name: "block_height", Kind: ColumnUint64},
+	{Name: "block_id", Kind: ColumnString},
+	{Name: "block_timestamp", Kind: ColumnString},
+	{Name: "transaction_id", Kind: ColumnString},
+	{Name: "transaction_index", Kind: ColumnInt64},
+	{Name: "event_index", Kind: ColumnInt64},
+	{Name: "type", Kind: ColumnString},
+}
+
+// FlattenConfig are the rules for flattening the fields of events into columns.
+type FlattenConfig struct {
+	// Separator joins the names of nested fields into column names, e.g. "vault.balance".
+	Separator string
+	// FieldPrefix is prepended to the column names of the fields,
+	// to avoid collisions with the base columns (block_height, type, ...).
+	FieldPrefix string
+	// MaxDepth is the depth up to which nested structs are flattened into columns.
+	// Deeper structs are encoded as JSON. 0 flattens all the nested structs.
+	MaxDepth int
+	// IndexArrays flattens arrays into a column per element, e.g. "ids.0", "ids.1".
+	// Otherwise arrays are encoded as JSON. Dictionaries are always encoded as JSON.
+	IndexArrays bool
+	// FixedPointAsFloat converts fixed point numbers (e.g. UFix64) to float64.
+	// Otherwise they are decimal strings, because float64 can not represent all of them exactly.
+	FixedPointAsFloat bool
+	// Columns if set, are the columns of the export. Otherwise they are the columns of the first event.
+	// Events are exported with the same columns, so they should all be of the same type.
+	// Columns should be set if events of the same type can have different columns,
+	// e.g. because of nil optional structs or arrays of different lengths with IndexArrays.
+	Columns []Column
+}
+
+func DefaultFlattenConfig() FlattenConfig {
+	return FlattenConfig{
+		Separator: ".",
+	}
+}
+
+// Flatten returns the columns of the event and their values.
+// The base columns of the block and transaction come first, followed by the fields in the order they are declared.
+// Nil values (e.g. nil optionals) are returned as nil.
+func (c FlattenConfig) Flatten(event Event) ([]Column, []any, error) {
+	columns := append([]Column(nil), baseColumns...)
+	values := []any{
+		event.BlockHeight,
+		event.BlockID.Hex(),
+		event.BlockTimestamp.UTC().Format(time.RFC3339Nano),
+		event.TransactionID.Hex(),
+		int64(event.TransactionIndex),
+		int64(event.EventIndex),
+		event.Type,
+	}
+	names := make(map[string]struct{}, len(columns))
+	for _, column := range columns {
+		names[column.Name] = struct{}{}
+	}
+
+	add := func(column Column, value any) error {
+		if _, ok := names[column.Name]; ok {
+			return fmt.Errorf("duplicate column %s, set a FieldPrefix", column.Name)
+		}
+		names[column.Name] = struct{}{}
+		columns = append(columns, column)
+		values = append(values, value)
+		return nil
+	}
+	err := c.flattenFields(c.FieldPrefix, event.Value, 1, add)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to flatten event at height %d: %w", event.BlockHeight, err)
+	}
+	return columns, values, nil
+}
+
+func (c FlattenConfig) flattenFields(
+	prefix string,
+	value cadence.HasFields,
+	depth int,
+	add func(column Column, value any) error,
+) error {
+	fields := value.GetFields()
+	if fields == nil {
+		return fmt.Errorf("the fields of %s have no names", value.(cadence.Value).Type().ID())
+	}
+	for i, fieldValue := range value.GetFieldValues() {
+		err := c.flattenValue(prefix+fields[i].Identifier, fieldValue, depth, add)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c FlattenConfig) flattenValue(
+	name string,
+	value cadence.Value,
+	depth int,
+	add func(column Column, value any) error,
+) error {
+	value = unwrapOptional(value)
+	switch v := value.(type) {
+	case cadence.HasFields:
+		if v.GetFields() != nil && (c.MaxDepth == 0 || depth < c.MaxDepth) {
+			return c.flattenFields(name+c.Separator, v, depth+1, add)
+		}
+	case cadence.Array:
+		if c.IndexArrays {
+			for i, element := range v.Values {
+				err := c.flattenValue(name+c.Separator+strconv.Itoa(i), element, depth, add)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	column, flat, err := c.columnValue(name, value)
+	if err != nil {
+		return err
+	}
+	return add(column, flat)
+}
+
+// columnValue converts a value that is not flattened any further into the value of a column.
+func (c FlattenConfig) columnValue(name string, value cadence.Value) (Column, any, error) {
+	switch v := value.(type) {
+	case nil:
+		return Column{Name: name, Kind: ColumnString}, nil, nil
+	case cadence.Bool:
+		return Column{Name: name, Kind: ColumnBool}, bool(v), nil
+	case cadence.String:
+		return Column{Name: name, Kind: ColumnString}, string(v), nil
+	case cadence.Address:
+		return Column{Name: name, Kind: ColumnString}, v.String(), nil
+	case cadence.Int8, cadence.Int16, cadence.Int32, cadence.Int64:
+		n, err := strconv.ParseInt(v.String(), 10, 64)
+		return Column{Name: name, Kind: ColumnInt64}, n, err
+	case cadence.UInt8, cadence.UInt16, cadence.UInt32, cadence.UInt64,
+		cadence.Word8, cadence.Word16, cadence.Word32, cadence.Word64:
+		n, err := strconv.ParseUint(v.String(), 10, 64)
+		return Column{Name: name, Kind: ColumnUint64}, n, err
+	case cadence.Fix64, cadence.UFix64:
+		if !c.FixedPointAsFloat {
+			return Column{Name: name, Kind: ColumnString}, v.String(), nil
+		}
+		f, err := strconv.ParseFloat(v.String(), 64)
+		return Column{Name: name, Kind: ColumnFloat64}, f, err
+	case cadence.HasFields, cadence.Array, cadence.Dictionary:
+		data, err := json.Marshal(PlainValue(v))
+		if err != nil {
+			return Column{}, nil, err
+		}
+		return Column{Name: name, Kind: ColumnString}, string(data), nil
+	default:
+		// e.g. Int, UInt128, paths and types
+		return Column{Name: name, Kind: ColumnString}, v.String(), nil
+	}
+}
+
+// PlainValue converts a value to Go values that are encoded as plain JSON,
+// instead of the type annotated JSON-Cadence encoding. Structs (and events) are maps of their fields,
+// numbers are encoded as JSON numbers and addresses as hex strings.
+func PlainValue(value cadence.Value) any {
+	switch v := unwrapOptional(value).(type) {
+	case nil:
+		return nil
+	case cadence.Bool:
+		return bool(v)
+	case cadence.String:
+		return string(v)
+	case cadence.Address:
+		return v.String()
+	case cadence.Bytes:
+		return hex.EncodeToString(v)
+	case cadence.Array:
+		values := make([]any, len(v.Values))
+		for i, element := range v.Values {
+			values[i] = PlainValue(element)
+		}
+		return values
+	case cadence.Dictionary:
+		values := make(map[string]any, len(v.Pairs))
+		for _, pair := range v.Pairs {
+			key := pair.Key.String()
+			if s, ok := pair.Key.(cadence.String); ok {
+				key = string(s)
+			}
+			values[key] = PlainValue(pair.Value)
+		}
+		return values
+	case cadence.HasFields:
+		fields := v.GetFields()
+		if fields == nil {
+			return v.(cadence.Value).String()
+		}
+		values := make(map[string]any, len(fields))
+		for i, fieldValue := range v.GetFieldValues() {
+			values[fields[i].Identifier] = PlainValue(fieldValue)
+		}
+		return values
+	case cadence.NumberValue:
+		return json.Number(v.String())
+	default:
+		return v.String()
+	}
+}
+
+// exportColumns keeps the columns of the export, and finds the index of the columns of each event.
+type exportColumns struct {
+	columns []Column
+	index   map[string]int
+}
+
+func newExportColumns(columns []Column) *exportColumns {
+	index := make(map[string]int, len(columns))
+	for i, column := range columns {
+		index[column.Name] = i
+	}
+	return &exportColumns{
+		columns: columns,
+		index:   index,
+	}
+}
+
+// row orders the values of an event by the columns of the export.
+// Columns the event does not have are nil.
+func (e *exportColumns) row(columns []Column, values []any) ([]any, error) {
+	row := make([]any, len(e.columns))
+	for i, column := range columns {
+		j, ok := e.index[column.Name]
+		if !ok {
+			return nil, fmt.Errorf("unexpected column %s, set the Columns of the FlattenConfig", column.Name)
+		}
+		row[j] = values[i]
+	}
+	return row, nil
+}
+
+// CSVExporter writes events as CSV rows, with a column per flattened field.
+// The header is written before the first row. Nil values are empty cells.
+// It is not safe for concurrent use.
+type CSVExporter struct {
+	w      *csv.Writer
+	config FlattenConfig
+
+	columns *exportColumns
+}
+
+func NewCSVExporter(w io.Writer, config FlattenConfig) *CSVExporter {
+	return &CSVExporter{
+		w:      csv.NewWriter(w),
+		config: config,
+	}
+}
+
+func (e *CSVExporter) Write(event Event) error {
+	columns, values, err := e.config.Flatten(event)
+	if err != nil {
+		return err
+	}
+	if e.columns == nil {
+		err := e.writeHeader(columns)
+		if err != nil {
+			return err
+		}
+	}
+	row, err := e.columns.row(columns, values)
+	if err != nil {
+		return err
+	}
+	record := make([]string, len(row))
+	for i, value := range row {
+		if value != nil {
+			record[i] = fmt.Sprint(value)
+		}
+	}
+	return e.w.Write(record)
+}
+
+func (e *CSVExporter) writeHeader(columns []Column) error {
+	if len(e.config.Columns) > 0 {
+		columns = e.config.Columns
+	}
+	e.columns = newExportColumns(columns)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Name
+	}
+	return e.w.Write(header)
+}
+
+// Close flushes the buffered rows. It does not close the underlying writer.
+func (e *CSVExporter) Close() error {
+	if e.columns == nil && len(e.config.Columns) > 0 {
+		err := e.writeHeader(nil)
+		if err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// DefaultParquetRowGroupSize is the number of rows that are buffered before they are written to the ParquetRowWriter.
+const DefaultParquetRowGroupSize = 10_000
+
+// ParquetRowWriter writes rows to a Parquet file. The file is complete once the writer is closed.
+// *parquet.GenericWriter[map[string]any] of github.com/parquet-go/parquet-go implements it,
+// when it is created with a schema of optional columns:
+//
+//	newWriter := func(w io.Writer, columns []eventfinder.Column) eventfinder.ParquetRowWriter {
+//		group := parquet.Group{}
+//		for _, column := range columns {
+//			group[column.Name] = parquet.Optional(node(column.Kind)) // e.g. parquet.String() for ColumnString
+//		}
+//		return parquet.NewGenericWriter[map[string]any](w, parquet.NewSchema("event", group))
+//	}
+type ParquetRowWriter interface {
+	Write(rows []map[string]any) (int, error)
+	Close() error
+}
+
+// ParquetExporter writes events as Parquet rows, with a column per flattened field.
+// The writer is created with the columns before the first row is written.
+// It is not safe for concurrent use.
+type ParquetExporter struct {
+	w         io.Writer
+	config    FlattenConfig
+	newWriter func(w io.Writer, columns []Column) ParquetRowWriter
+
+	columns *exportColumns
+	writer  ParquetRowWriter
+	rows    []map[string]any
+}
+
+func NewParquetExporter(
+	w io.Writer,
+	newWriter func(w io.Writer, columns []Column) ParquetRowWriter,
+	config FlattenConfig,
+) *ParquetExporter {
+	return &ParquetExporter{
+		w:         w,
+		config:    config,
+		newWriter: newWriter,
+	}
+}
+
+func (e *ParquetExporter) Write(event Event) error {
+	columns, values, err := e.config.Flatten(event)
+	if err != nil {
+		return err
+	}
+	if e.writer == nil {
+		e.open(columns)
+	}
+	row, err := e.columns.row(columns, values)
+	if err != nil {
+		return err
+	}
+	named := make(map[string]any, len(row))
+	for i, value := range row {
+		named[e.columns.columns[i].Name] = value
+	}
+	e.rows = append(e.rows, named)
+	if len(e.rows) >= DefaultParquetRowGroupSize {
+		return e.flush()
+	}
+	return nil
+}
+
+func (e *ParquetExporter) open(columns []Column) {
+	if len(e.config.Columns) > 0 {
+		columns = e.config.Columns
+	}
+	e.columns = newExportColumns(columns)
+	e.writer = e.newWriter(e.w, columns)
+}
+
+func (e *ParquetExporter) flush() error {
+	if len(e.rows) == 0 {
+		return nil
+	}
+	_, err := e.writer.Write(e.rows)
+	e.rows = e.rows[:0]
+	return err
+}
+
+// Close writes the buffered rows and completes the file. It does not close the underlying writer.
+// If no events were written and no Columns are set, no file is written.
+func (e *ParquetExporter) Close() error {
+	if e.writer == nil {
+		if len(e.config.Columns) == 0 {
+			return nil
+		}
+		e.open(nil)
+	}
+	err := e.flush()
+	if err != nil {
+		_ = e.writer.Close()
+		return err
+	}
+	return e.writer.Close()
+}
@@ -0,0 +1,151 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventfinder_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/cadence/runtime/common"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/eventfinder"
+)
+
+func listedEvent(height uint64, ids ...uint64) eventfinder.Event {
+	location := common.AddressLocation{Address: common.Address{0, 0, 0, 0, 0, 0, 0, 1}, Name: "Market"}
+	priceType := &cadence.StructType{
+		Location:            location,
+		QualifiedIdentifier: "Market.Price",
+		Fields: []cadence.Field{
+			{Identifier: "amount", Type: cadence.UFix64Type{}},
+			{Identifier: "currency", Type: cadence.StringType{}},
+		},
+	}
+	values := make([]cadence.Value, len(ids))
+	for i, id := range ids {
+		values[i] = cadence.UInt64(id)
+	}
+	value := cadence.NewEvent([]cadence.Value{
+		cadence.NewAddress(flow.HexToAddress("02")),
+		cadence.NewStruct([]cadence.Value{cadence.UFix64(150_000_000), cadence.String("FLOW")}).WithType(priceType),
+		cadence.NewArray(values),
+	}).WithType(&cadence.EventType{
+		Location:            location,
+		QualifiedIdentifier: "Market.Listed",
+		Fields: []cadence.Field{
+			{Identifier: "seller", Type: cadence.AddressType{}},
+			{Identifier: "price", Type: priceType},
+			{Identifier: "ids", Type: &cadence.VariableSizedArrayType{ElementType: cadence.UInt64Type{}}},
+		},
+	})
+	return eventfinder.Event{
+		BlockHeight: height,
+		Type:        value.EventType.ID(),
+		Value:       value,
+	}
+}
+
+func columnNames(columns []eventfinder.Column) []string {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+func TestFlattenConfig_Flatten(t *testing.T) {
+	event := listedEvent(10, 1, 2)
+
+	columns, values, err := eventfinder.DefaultFlattenConfig().Flatten(event)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"block_height", "block_id", "block_timestamp", "transaction_id", "transaction_index", "event_index", "type",
+		"seller", "price.amount", "price.currency", "ids",
+	}, columnNames(columns))
+	require.Equal(t, []any{"0x0000000000000002", "1.50000000", "FLOW", "[1,2]"}, values[7:])
+
+	config := eventfinder.DefaultFlattenConfig()
+	config.Separator = "_"
+	config.MaxDepth = 1
+	config.IndexArrays = true
+	config.FixedPointAsFloat = true
+	columns, values, err = config.Flatten(event)
+	require.NoError(t, err)
+	require.Equal(t, []string{"seller", "price", "ids_0", "ids_1"}, columnNames(columns[7:]))
+	require.Equal(t, eventfinder.ColumnUint64, columns[9].Kind)
+	require.Equal(t, []any{"0x0000000000000002", `{"amount":1.50000000,"currency":"FLOW"}`, uint64(1), uint64(2)}, values[7:])
+}
+
+func TestCSVExporter(t *testing.T) {
+	config := eventfinder.DefaultFlattenConfig()
+	config.IndexArrays = true
+
+	buf := &bytes.Buffer{}
+	exporter := eventfinder.NewCSVExporter(buf, config)
+	require.NoError(t, exporter.Write(listedEvent(10, 1, 2)))
+	require.NoError(t, exporter.Write(listedEvent(11, 3)))
+	// the columns are those of the first event
+	require.ErrorContains(t, exporter.Write(listedEvent(12, 4, 5, 6)), "unexpected column ids.2")
+	require.NoError(t, exporter.Close())
+
+	blockID := flow.EmptyID.Hex()
+	require.Equal(t, ""+
+		"block_height,block_id,block_timestamp,transaction_id,transaction_index,event_index,type,seller,price.amount,price.currency,ids.0,ids.1\n"+
+		"10,"+blockID+",0001-01-01T00:00:00Z,"+blockID+",0,0,A.0000000000000001.Market.Listed,0x0000000000000002,1.50000000,FLOW,1,2\n"+
+		"11,"+blockID+",0001-01-01T00:00:00Z,"+blockID+",0,0,A.0000000000000001.Market.Listed,0x0000000000000002,1.50000000,FLOW,3,\n",
+		buf.String())
+}
+
+// mapRowWriter stands in for a Parquet writer.
+type mapRowWriter struct {
+	columns []eventfinder.Column
+	rows    []map[string]any
+	closed  bool
+}
+
+func (w *mapRowWriter) Write(rows []map[string]any) (int, error) {
+	w.rows = append(w.rows, rows...)
+	return len(rows), nil
+}
+
+func (w *mapRowWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestParquetExporter(t *testing.T) {
+	writer := &mapRowWriter{}
+	exporter := eventfinder.NewParquetExporter(io.Discard,
+		func(_ io.Writer, columns []eventfinder.Column) eventfinder.ParquetRowWriter {
+			writer.columns = columns
+			return writer
+		},
+		eventfinder.DefaultFlattenConfig(),
+	)
+	require.NoError(t, exporter.Write(listedEvent(10, 1)))
+	require.NoError(t, exporter.Write(listedEvent(11, 2)))
+	require.Empty(t, writer.rows)
+	require.NoError(t, exporter.Close())
+
+	require.True(t, writer.closed)
+	require.Len(t, writer.columns, 11)
+	require.Len(t, writer.rows, 2)
+	require.Equal(t, uint64(11), writer.rows[1]["block_height"])
+	require.Equal(t, "[2]", writer.rows[1]["ids"])
+}
@@ -0,0 +1,235 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventfinder
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+)
+
+// Filter decides if an event is kept. Filters are applied while the events are fetched,
+// so the events that are not kept are never held in memory.
+type Filter func(event Event) (bool, error)
+
+// WithFilter keeps only the events that match all the filters.
+func WithFilter(filters ...Filter) Option {
+	return func(c *Config) {
+		c.Filters = append(c.Filters, filters...)
+	}
+}
+
+// And matches the events that match all the filters.
+func And(filters ...Filter) Filter {
+	return func(event Event) (bool, error) {
+		for _, filter := range filters {
+			ok, err := filter(event)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+}
+
+// Or matches the events that match any of the filters.
+func Or(filters ...Filter) Filter {
+	return func(event Event) (bool, error) {
+		for _, filter := range filters {
+			ok, err := filter(event)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+}
+
+// Not matches the events that do not match the filter.
+func Not(filter Filter) Filter {
+	return func(event Event) (bool, error) {
+		ok, err := filter(event)
+		return !ok && err == nil, err
+	}
+}
+
+// Field matches the events with a field that matches the predicate.
+// Nested fields of structs are separated by dots, e.g. "vault.balance".
+// Events without the field do not match. Optional values are unwrapped, nil optionals do not match.
+func Field(path string, predicate func(value cadence.Value) (bool, error)) Filter {
+	return func(event Event) (bool, error) {
+		value, ok := fieldValue(event.Value, path)
+		if !ok {
+			return false, nil
+		}
+		return predicate(value)
+	}
+}
+
+// Where matches the events that were decoded into T (see Registry), and match the predicate.
+func Where[T any](predicate func(value T) bool) Filter {
+	return func(event Event) (bool, error) {
+		value, ok := As[T](event)
+		return ok && predicate(value), nil
+	}
+}
+
+// fieldValue gets the value of a field of the event by its dot separated path,
+// unwrapping optional values.
+func fieldValue(event cadence.Event, path string) (cadence.Value, bool) {
+	var value cadence.Value = event
+	for _, name := range strings.Split(path, ".") {
+		value = unwrapOptional(value)
+		hasFields, ok := value.(cadence.HasFields)
+		if !ok || hasFields.GetFields() == nil {
+			return nil, false
+		}
+		value = cadence.GetFieldsMappedByName(hasFields)[name]
+		if value == nil {
+			return nil, false
+		}
+	}
+	value = unwrapOptional(value)
+	return value, value != nil
+}
+
+func unwrapOptional(value cadence.Value) cadence.Value {
+	for {
+		optional, ok := value.(cadence.Optional)
+		if !ok {
+			return value
+		}
+		value = optional.Value
+	}
+}
+
+// ParseFilter parses a filter expression of comparisons of fields with literals,
+// combined with && and ||, where && binds stronger:
+//
+//	amount > 1000 && to == 0x1654653399040a61
+//	name == "Flow" || name == "FUSD"
+//
+// The comparison operators are ==, !=, <, <=, > and >=. Numbers are compared by value,
+// addresses, strings and booleans are compared for (in)equality, strings can also be ordered.
+func ParseFilter(expression string) (Filter, error) {
+	var alternatives []Filter
+	for _, alternative := range strings.Split(expression, "||") {
+		var comparisons []Filter
+		for _, comparison := range strings.Split(alternative, "&&") {
+			filter, err := parseComparison(strings.TrimSpace(comparison))
+			if err != nil {
+				return nil, fmt.Errorf("invalid filter %q: %w", expression, err)
+			}
+			comparisons = append(comparisons, filter)
+		}
+		alternatives = append(alternatives, And(comparisons...))
+	}
+	if len(alternatives) == 1 {
+		return alternatives[0], nil
+	}
+	return Or(alternatives...), nil
+}
+
+// comparisonOperators are ordered so the two character operators are found before their prefixes.
+var comparisonOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func parseComparison(comparison string) (Filter, error) {
+	for _, operator := range comparisonOperators {
+		index := strings.Index(comparison, operator)
+		if index < 0 {
+			continue
+		}
+		field := strings.TrimSpace(comparison[:index])
+		literal := strings.TrimSpace(comparison[index+len(operator):])
+		if field == "" || literal == "" {
+			return nil, fmt.Errorf("expected <field> %s <value>, got %q", operator, comparison)
+		}
+		operator := operator
+		return Field(field, func(value cadence.Value) (bool, error) {
+			return compare(value, operator, literal)
+		}), nil
+	}
+	return nil, fmt.Errorf("no comparison operator in %q", comparison)
+}
+
+// compare compares a cadence value with a literal of the filter expression.
+func compare(value cadence.Value, operator string, literal string) (bool, error) {
+	switch value := value.(type) {
+	case cadence.Address:
+		if !strings.HasPrefix(literal, "0x") {
+			return false, fmt.Errorf("expected an address literal like 0x1654653399040a61, got %s", literal)
+		}
+		return compareEquality(flow.Address(value) == flow.HexToAddress(literal), operator)
+	case cadence.String:
+		return compareOrder(strings.Compare(string(value), unquote(literal)), operator), nil
+	case cadence.Bool:
+		b, err := strconv.ParseBool(literal)
+		if err != nil {
+			return false, fmt.Errorf("expected true or false, got %s", literal)
+		}
+		return compareEquality(bool(value) == b, operator)
+	}
+
+	// integers and fixed point numbers are formatted as decimals
+	number, ok := new(big.Rat).SetString(value.String())
+	if !ok {
+		return compareOrder(strings.Compare(value.String(), unquote(literal)), operator), nil
+	}
+	other, ok := new(big.Rat).SetString(literal)
+	if !ok {
+		return false, fmt.Errorf("expected a number, got %s", literal)
+	}
+	return compareOrder(number.Cmp(other), operator), nil
+}
+
+func compareEquality(equal bool, operator string) (bool, error) {
+	switch operator {
+	case "==":
+		return equal, nil
+	case "!=":
+		return !equal, nil
+	default:
+		return false, fmt.Errorf("operator %s can not be used for this value", operator)
+	}
+}
+
+// compareOrder applies the operator to the result of a three-way comparison.
+func compareOrder(cmp int, operator string) bool {
+	switch operator {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+func unquote(literal string) string {
+	if unquoted, err := strconv.Unquote(literal); err == nil {
+		return unquoted
+	}
+	return literal
+}
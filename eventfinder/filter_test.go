@@ -0,0 +1,89 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventfinder_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+	"github.com/onflow/flow-batch-scan/eventfinder"
+)
+
+func TestParseFilter(t *testing.T) {
+	// 1500.0 to 0x02
+	event := eventfinder.Event{Value: depositEvent(1500_00000000, flow.HexToAddress("02"))}
+
+	cases := map[string]bool{
+		"amount > 1000":                              true,
+		"amount >= 1500.0":                           true,
+		"amount < 1000":                              false,
+		"amount != 1500":                             false,
+		"to == 0x02":                                 true,
+		"to == 0x0000000000000002":                   true,
+		"to != 0x02":                                 false,
+		`memo == "new field"`:                        true,
+		"amount > 1000 && to == 0x03":                false,
+		"amount > 2000 || to == 0x02":                true,
+		"amount > 2000 || amount < 10 && to == 0x02": false,
+		"missing == 1":                               false,
+	}
+	for expression, expected := range cases {
+		filter, err := eventfinder.ParseFilter(expression)
+		require.NoError(t, err, expression)
+		ok, err := filter(event)
+		require.NoError(t, err, expression)
+		require.Equal(t, expected, ok, expression)
+	}
+
+	_, err := eventfinder.ParseFilter("amount 1000")
+	require.Error(t, err)
+
+	filter, err := eventfinder.ParseFilter("to > 0x02")
+	require.NoError(t, err)
+	_, err = filter(event)
+	require.Error(t, err)
+}
+
+func TestFind_Filter(t *testing.T) {
+	c := clienttest.New()
+	for height := uint64(1); height <= 10; height++ {
+		c.AddBlock(height, clienttest.Transaction{Events: []flow.Event{{
+			Type:  depositType,
+			Value: depositEvent(height*100_00000000, flow.HexToAddress("02")),
+		}}})
+	}
+
+	registry := eventfinder.NewRegistry()
+	eventfinder.RegisterIn[deposit](registry, depositType)
+	events, err := eventfinder.Find(context.Background(), c, depositType, 1, 10,
+		eventfinder.WithRegistry(registry),
+		eventfinder.WithFilter(
+			eventfinder.Field("amount", func(value cadence.Value) (bool, error) {
+				return value.(cadence.UFix64) > 300_00000000, nil
+			}),
+			eventfinder.Where(func(d deposit) bool {
+				return d.Amount < 900_00000000
+			}),
+		),
+	)
+	require.NoError(t, err)
+	require.Len(t, events, 5)
+	require.Equal(t, uint64(4), events[0].BlockHeight)
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventfinder
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/onflow/cadence"
+)
+
+// ErrEventTypeNotRegistered is returned when an event of a type without a decoder is decoded.
+var ErrEventTypeNotRegistered = errors.New("event type is not registered")
+
+// Registry maps event types to the Go structs their events are decoded into.
+// The fields of the structs are mapped to the fields of the events with `cadence` field tags,
+// so decoding does not break when a contract adds fields to an event:
+//
+//	type Deposit struct {
+//		Amount cadence.UFix64 `cadence:"amount"`
+//		To     *cadence.Address `cadence:"to"`
+//	}
+//
+//	eventfinder.Register[Deposit]("A.1654653399040a61.FlowToken.TokensDeposited")
+//
+// A Registry is safe for concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	decoders map[string]func(event cadence.Event) (any, error)
+}
+
+// DefaultRegistry is the registry of Register, and the registry the events are decoded with by default.
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{
+		decoders: make(map[string]func(event cadence.Event) (any, error)),
+	}
+}
+
+// Register decodes the events of eventType into T with the DefaultRegistry.
+func Register[T any](eventType string) {
+	RegisterIn[T](DefaultRegistry, eventType)
+}
+
+// RegisterIn decodes the events of eventType into T with the registry.
+// A later registration of the same event type replaces the earlier one.
+func RegisterIn[T any](registry *Registry, eventType string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.decoders[eventType] = func(event cadence.Event) (any, error) {
+		return DecodeEvent[T](event)
+	}
+}
+
+// RegisterFunc decodes the events of eventType with the decode function,
+// for events that can not be decoded with field tags, e.g. the events of the evm package.
+func (r *Registry) RegisterFunc(eventType string, decode func(event cadence.Event) (any, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decoders[eventType] = decode
+}
+
+// Decode decodes the event into the struct registered for its type.
+// It returns ErrEventTypeNotRegistered if no struct was registered for the type.
+func (r *Registry) Decode(event cadence.Event) (any, error) {
+	eventType := eventTypeID(event)
+
+	r.mu.RLock()
+	decode, ok := r.decoders[eventType]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrEventTypeNotRegistered, eventType)
+	}
+	return decode(event)
+}
+
+// Registered is true if a struct was registered for the event type.
+func (r *Registry) Registered(eventType string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.decoders[eventType]
+	return ok
+}
+
+// DecodeEvent decodes the fields of the event into T, using the `cadence` field tags of T.
+func DecodeEvent[T any](event cadence.Event) (T, error) {
+	var value T
+	err := cadence.DecodeFields(event, &value)
+	if err != nil {
+		return value, fmt.Errorf("failed to decode event %s: %w", eventTypeID(event), err)
+	}
+	return value, nil
+}
+
+// As returns the decoded value of the event, if it was decoded into T.
+func As[T any](event Event) (T, bool) {
+	value, ok := event.Decoded.(T)
+	return value, ok
+}
+
+func eventTypeID(event cadence.Event) string {
+	if event.EventType == nil {
+		return ""
+	}
+	return event.EventType.ID()
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventfinder_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/cadence/runtime/common"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+	"github.com/onflow/flow-batch-scan/eventfinder"
+)
+
+const depositType = "A.0000000000000001.Token.Deposited"
+
+type deposit struct {
+	Amount cadence.UFix64   `cadence:"amount"`
+	To     *cadence.Address `cadence:"to"`
+}
+
+func depositEvent(amount uint64, to flow.Address) cadence.Event {
+	return cadence.NewEvent([]cadence.Value{
+		cadence.UFix64(amount),
+		cadence.NewOptional(cadence.NewAddress(to)),
+		cadence.String("new field"),
+	}).WithType(&cadence.EventType{
+		Location:            common.AddressLocation{Address: common.Address{0, 0, 0, 0, 0, 0, 0, 1}, Name: "Token"},
+		QualifiedIdentifier: "Token.Deposited",
+		Fields: []cadence.Field{
+			{Identifier: "amount", Type: cadence.UFix64Type{}},
+			{Identifier: "to", Type: &cadence.OptionalType{Type: cadence.AddressType{}}},
+			{Identifier: "memo", Type: cadence.StringType{}},
+		},
+	})
+}
+
+func TestRegistry_Decode(t *testing.T) {
+	registry := eventfinder.NewRegistry()
+	event := depositEvent(100, flow.HexToAddress("02"))
+	require.Equal(t, depositType, event.EventType.ID())
+
+	_, err := registry.Decode(event)
+	require.ErrorIs(t, err, eventfinder.ErrEventTypeNotRegistered)
+
+	eventfinder.RegisterIn[deposit](registry, depositType)
+	decoded, err := registry.Decode(event)
+	require.NoError(t, err)
+	to := cadence.NewAddress(flow.HexToAddress("02"))
+	require.Equal(t, deposit{Amount: 100, To: &to}, decoded)
+}
+
+func TestFind_Decoded(t *testing.T) {
+	registry := eventfinder.NewRegistry()
+	eventfinder.RegisterIn[deposit](registry, depositType)
+
+	c := clienttest.New()
+	c.AddBlock(1, clienttest.Transaction{Events: []flow.Event{{
+		Type:  depositType,
+		Value: depositEvent(100, flow.HexToAddress("02")),
+	}}})
+
+	events, err := eventfinder.Find(context.Background(), c, depositType, 1, 1, eventfinder.WithRegistry(registry))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	value, ok := eventfinder.As[deposit](events[0])
+	require.True(t, ok)
+	require.Equal(t, cadence.UFix64(100), value.Amount)
+}
@@ -0,0 +1,64 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventfinder
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+	"github.com/onflow/flow-batch-scan/client"
+)
+
+// WithSporks makes the queries span spork boundaries. The block range is split at the root heights of the sporks,
+// so every query is answered by the access node of a single spork, and the events of all the sporks
+// are returned as one ordered stream. The client has to route the queries by height,
+// e.g. a client from client.NewSporkClient with the same sporks.
+func WithSporks(sporks []client.Spork) Option {
+	return func(c *Config) {
+		c.Sporks = append([]client.Spork(nil), sporks...)
+		sort.Slice(c.Sporks, func(i, j int) bool {
+			return c.Sporks[i].RootHeight < c.Sporks[j].RootHeight
+		})
+	}
+}
+
+// chunks splits the block range into chunks of at most ChunkSize blocks,
+// that do not span a spork boundary if Sporks are set.
+func (c Config) chunks(blocks candidates.BlockRange) ([]candidates.BlockRange, error) {
+	if len(c.Sporks) == 0 {
+		return blocks.Chunks(c.ChunkSize), nil
+	}
+	if blocks.Start < c.Sporks[0].RootHeight {
+		return nil, fmt.Errorf("block %d is before the first spork %s at height %d",
+			blocks.Start, c.Sporks[0].Name, c.Sporks[0].RootHeight)
+	}
+
+	var chunks []candidates.BlockRange
+	for i, spork := range c.Sporks {
+		sporkRange := candidates.BlockRange{Start: spork.RootHeight, End: blocks.End}
+		if i+1 < len(c.Sporks) && c.Sporks[i+1].RootHeight-1 < sporkRange.End {
+			sporkRange.End = c.Sporks[i+1].RootHeight - 1
+		}
+		if sporkRange.Start < blocks.Start {
+			sporkRange.Start = blocks.Start
+		}
+		if sporkRange.End < sporkRange.Start {
+			continue
+		}
+		chunks = append(chunks, sporkRange.Chunks(c.ChunkSize)...)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventfinder
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/onflow/flow-batch-scan/utils"
+)
+
+var errStreamClosed = errors.New("event stream closed")
+
+// streamError is a failure of the event subscription, as opposed to a failure to handle the events.
+type streamError struct {
+	err error
+}
+
+func (e *streamError) Error() string {
+	return "event subscription failed: " + e.err.Error()
+}
+
+func (e *streamError) Unwrap() error {
+	return e.err
+}
+
+// unsupported is true if the access node does not support the subscription.
+func (e *streamError) unsupported() bool {
+	return status.Code(e.err) == codes.Unimplemented
+}
+
+// subscribe follows the event stream of the access node from the cursor, until ctx is cancelled,
+// handling an event fails or the stream fails. Failures of the stream are returned as a *streamError.
+func (t *tail) subscribe(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	blocks, errs, err := t.client.SubscribeEvents(ctx, t.cursor.BlockHeight, t.eventTypes)
+	if err != nil {
+		return &streamError{err: err}
+	}
+
+	var seen *utils.LRUSet[EventKey]
+	if t.config.DedupSize > 0 {
+		seen = utils.NewLRUSet[EventKey](t.config.DedupSize)
+	}
+	saved := time.Now()
+	for {
+		var block flow.BlockEvents
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case b, ok := <-blocks:
+			if !ok {
+				// the error channel is closed before the blocks channel, so a failure is already in it
+				err, ok := <-errs
+				if !ok {
+					err = errStreamClosed
+				}
+				return &streamError{err: err}
+			}
+			block = b
+		}
+		if block.Height < t.cursor.BlockHeight {
+			continue
+		}
+
+		events, err := toEvents([]flow.BlockEvents{block}, t.config)
+		if err != nil {
+			return err
+		}
+		if seen != nil {
+			unseen := events[:0]
+			for _, event := range events {
+				if seen.Add(event.Key()) {
+					unseen = append(unseen, event)
+				}
+			}
+			events = unseen
+		}
+		err = t.handleEvents(events, block.Height)
+		if err != nil {
+			return err
+		}
+
+		if time.Since(saved) >= t.config.PollInterval {
+			err := t.save(ctx)
+			if err != nil {
+				return err
+			}
+			saved = time.Now()
+		}
+	}
+}
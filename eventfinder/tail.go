@@ -0,0 +1,336 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventfinder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/onflow/flow-batch-scan/client"
+)
+
+// DefaultPollInterval is how often Tail checks for new blocks once it has caught up.
+const DefaultPollInterval = 2 * time.Second
+
+// Cursor is the position of a Tail: the position of the next event to be handled.
+// All the events before it were handled.
+type Cursor struct {
+	BlockHeight      uint64 `json:"block_height"`
+	TransactionIndex int    `json:"transaction_index"`
+	EventIndex       int    `json:"event_index"`
+}
+
+// handled returns true if the event is before the cursor.
+func (c Cursor) handled(event Event) bool {
+	if event.BlockHeight != c.BlockHeight {
+		return event.BlockHeight < c.BlockHeight
+	}
+	if event.TransactionIndex != c.TransactionIndex {
+		return event.TransactionIndex < c.TransactionIndex
+	}
+	return event.EventIndex < c.EventIndex
+}
+
+// after returns the cursor right after the event.
+func after(event Event) Cursor {
+	return Cursor{
+		BlockHeight:      event.BlockHeight,
+		TransactionIndex: event.TransactionIndex,
+		EventIndex:       event.EventIndex + 1,
+	}
+}
+
+// CursorStore persists the cursor of a Tail, so that a restarted Tail resumes where it stopped.
+type CursorStore interface {
+	// LoadCursor returns the stored cursor. ok is false if no cursor was stored yet.
+	LoadCursor(ctx context.Context) (cursor Cursor, ok bool, err error)
+	SaveCursor(ctx context.Context, cursor Cursor) error
+}
+
+// FileCursorStore stores the cursor as JSON in a file.
+type FileCursorStore struct {
+	path string
+}
+
+var _ CursorStore = (*FileCursorStore)(nil)
+
+func NewFileCursorStore(path string) *FileCursorStore {
+	return &FileCursorStore{
+		path: path,
+	}
+}
+
+func (s *FileCursorStore) LoadCursor(_ context.Context) (Cursor, bool, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Cursor{}, false, nil
+	}
+	if err != nil {
+		return Cursor{}, false, err
+	}
+
+	var cursor Cursor
+	err = json.Unmarshal(data, &cursor)
+	if err != nil {
+		return Cursor{}, false, fmt.Errorf("invalid cursor in %s: %w", s.path, err)
+	}
+	return cursor, true, nil
+}
+
+func (s *FileCursorStore) SaveCursor(_ context.Context, cursor Cursor) error {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return err
+	}
+
+	// the cursor is written to a temporary file first, so that the file is never partially written
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	_, err = tmp.Write(data)
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// WithCursorStore persists the cursor of Tail to the store, and resumes from the stored cursor.
+func WithCursorStore(store CursorStore) Option {
+	return func(c *Config) {
+		c.CursorStore = store
+	}
+}
+
+// WithPollInterval sets how often Tail checks for new blocks once it has caught up.
+func WithPollInterval(interval time.Duration) Option {
+	return func(c *Config) {
+		c.PollInterval = interval
+	}
+}
+
+// WithStreaming makes Tail subscribe to the event stream of the access node, instead of polling for new blocks,
+// which lowers the latency and the number of requests. If the access node does not support the subscription,
+// e.g. because its execution data API is disabled, Tail falls back to polling.
+// While streaming, the cursor is saved every PollInterval.
+func WithStreaming() Option {
+	return func(c *Config) {
+		c.Streaming = true
+	}
+}
+
+// WithFlush sets a function that is called by Tail before the cursor is saved,
+// so a handler that buffers events can deliver them before they are considered handled.
+func WithFlush(flush func(ctx context.Context) error) Option {
+	return func(c *Config) {
+		c.Flush = flush
+	}
+}
+
+// Tail follows the chain from startHeight, and passes the events of the event types to handle
+// in the order they were emitted, until ctx is cancelled or handle returns an error.
+// Only sealed blocks are followed.
+//
+// If a CursorStore is set and it has a cursor, Tail resumes from the cursor instead of startHeight.
+// The cursor is saved after every block range and when Tail stops, so after a restart
+// each event is handled exactly once, unless the process was killed before the cursor was saved.
+func Tail(
+	ctx context.Context,
+	client client.Client,
+	eventTypes []string,
+	startHeight uint64,
+	handle func(event Event) error,
+	options ...Option,
+) error {
+	if len(eventTypes) == 0 {
+		return fmt.Errorf("no event types to tail")
+	}
+	config := newConfig(options)
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultPollInterval
+	}
+	if config.ChunkSize == 0 {
+		config.ChunkSize = DefaultConfig().ChunkSize
+	}
+
+	cursor := Cursor{BlockHeight: startHeight}
+	if config.CursorStore != nil {
+		stored, ok, err := config.CursorStore.LoadCursor(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load the cursor: %w", err)
+		}
+		if ok {
+			cursor = stored
+		}
+	}
+
+	t := &tail{
+		client:     client,
+		eventTypes: eventTypes,
+		handle:     handle,
+		config:     config,
+		cursor:     cursor,
+	}
+	err := t.run(ctx)
+
+	// the cursor is saved even if ctx was cancelled, so the next Tail resumes from it
+	saveErr := t.save(context.Background())
+	if err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+type tail struct {
+	client     client.Client
+	eventTypes []string
+	handle     func(event Event) error
+	config     Config
+
+	cursor Cursor
+	saved  *Cursor
+}
+
+func (t *tail) run(ctx context.Context) error {
+	if !t.config.Streaming {
+		return t.poll(ctx, false)
+	}
+	for {
+		err := t.subscribe(ctx)
+		var streamErr *streamError
+		if !errors.As(err, &streamErr) {
+			return err
+		}
+		if streamErr.unsupported() {
+			// the access node does not support the subscription, so Tail keeps polling
+			return t.poll(ctx, false)
+		}
+
+		// the stream failed, e.g. because the cursor is before the execution data of the access node,
+		// so Tail polls until it has caught up and subscribes again
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(t.config.PollInterval):
+		}
+		err = t.poll(ctx, true)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// poll queries the events of the new blocks in ranges. If untilCaughtUp is true,
+// it returns once it has caught up with the latest sealed block, otherwise it keeps polling for new blocks.
+func (t *tail) poll(ctx context.Context, untilCaughtUp bool) error {
+	limiter := newLimiter(t.config)
+	// every block range is split into Concurrency chunks, so a Tail that is far behind catches up concurrently
+	rangeSize := t.config.ChunkSize * uint64(t.config.Concurrency)
+
+	for {
+		header, err := t.client.GetLatestBlockHeader(ctx, true)
+		if err != nil {
+			return fmt.Errorf("failed to get the latest sealed block: %w", err)
+		}
+
+		if header.Height < t.cursor.BlockHeight {
+			if untilCaughtUp {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(t.config.PollInterval):
+			}
+			continue
+		}
+
+		start := t.cursor.BlockHeight
+		end := header.Height
+		if end-start+1 > rangeSize {
+			end = start + rangeSize - 1
+		}
+
+		var events []Event
+		for _, eventType := range t.eventTypes {
+			err := stream(ctx, t.client, eventType, start, end, func(event Event) error {
+				events = append(events, event)
+				return nil
+			}, t.config, limiter)
+			if err != nil {
+				return err
+			}
+		}
+		sortEvents(events)
+
+		err = t.handleEvents(events, end)
+		if err != nil {
+			return err
+		}
+
+		err = t.save(ctx)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handleEvents handles the events that were not handled yet, and moves the cursor past the end height.
+func (t *tail) handleEvents(events []Event, end uint64) error {
+	for _, event := range events {
+		if t.cursor.handled(event) {
+			continue
+		}
+		err := t.handle(event)
+		if err != nil {
+			return err
+		}
+		t.cursor = after(event)
+	}
+	t.cursor = Cursor{BlockHeight: end + 1}
+	return nil
+}
+
+func (t *tail) save(ctx context.Context) error {
+	if t.config.Flush != nil {
+		err := t.config.Flush(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to flush the handled events: %w", err)
+		}
+	}
+	if t.config.CursorStore == nil || (t.saved != nil && *t.saved == t.cursor) {
+		return nil
+	}
+	err := t.config.CursorStore.SaveCursor(ctx, t.cursor)
+	if err != nil {
+		return fmt.Errorf("failed to save the cursor: %w", err)
+	}
+	cursor := t.cursor
+	t.saved = &cursor
+	return nil
+}
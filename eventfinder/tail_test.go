@@ -0,0 +1,154 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventfinder_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+	"github.com/onflow/flow-batch-scan/eventfinder"
+)
+
+func TestTail_Resume(t *testing.T) {
+	const otherType = "A.0000000000000001.Contract.Other"
+	c := clienttest.New()
+	c.AddBlocks(1, 30)
+	for height := uint64(2); height <= 30; height += 4 {
+		c.AddBlock(height,
+			clienttest.Transaction{Events: []flow.Event{{Type: eventType}, {Type: otherType}}},
+			clienttest.Transaction{Events: []flow.Event{{Type: otherType}}},
+		)
+	}
+	expected, err := eventfinder.Find(context.Background(), c, eventType, 1, 30)
+	require.NoError(t, err)
+	other, err := eventfinder.Find(context.Background(), c, otherType, 1, 30)
+	require.NoError(t, err)
+	expected = append(expected, other...)
+	require.Len(t, expected, 8*3)
+
+	store := eventfinder.NewFileCursorStore(filepath.Join(t.TempDir(), "cursor.json"))
+	options := []eventfinder.Option{
+		eventfinder.WithChunkSize(5),
+		eventfinder.WithConcurrency(2),
+		eventfinder.WithPollInterval(time.Millisecond),
+		eventfinder.WithCursorStore(store),
+	}
+	var handled []eventfinder.Event
+	errStop := errors.New("stop")
+
+	// the first run stops in the middle of a block
+	err = eventfinder.Tail(context.Background(), c, []string{eventType, otherType}, 1,
+		func(event eventfinder.Event) error {
+			if len(handled) == 10 {
+				return errStop
+			}
+			handled = append(handled, event)
+			return nil
+		}, options...)
+	require.ErrorIs(t, err, errStop)
+
+	cursor, ok, err := store.LoadCursor(context.Background())
+	require.NoError(t, err)
+	require.True(t, ok)
+	last := handled[len(handled)-1]
+	require.Equal(t, eventfinder.Cursor{
+		BlockHeight:      last.BlockHeight,
+		TransactionIndex: last.TransactionIndex,
+		EventIndex:       last.EventIndex + 1,
+	}, cursor)
+
+	// the second run resumes from the cursor, and follows the chain until ctx is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = eventfinder.Tail(ctx, c, []string{eventType, otherType}, 1,
+		func(event eventfinder.Event) error {
+			handled = append(handled, event)
+			if len(handled) == len(expected) {
+				cancel()
+			}
+			return nil
+		}, options...)
+	require.ErrorIs(t, err, context.Canceled)
+
+	require.Len(t, handled, len(expected))
+	seen := make(map[eventfinder.EventKey]struct{})
+	for i, event := range handled {
+		seen[event.Key()] = struct{}{}
+		if i > 0 {
+			require.True(t, less(handled[i-1], event), "events out of order at %d", i)
+		}
+	}
+	require.Len(t, seen, len(expected))
+}
+
+func TestTail_Streaming(t *testing.T) {
+	for _, supported := range []bool{true, false} {
+		supported := supported
+		t.Run(fmt.Sprintf("supported=%v", supported), func(t *testing.T) {
+			c := clienttest.New()
+			c.AddBlocks(1, 20)
+			for height := uint64(3); height <= 20; height += 5 {
+				c.AddBlock(height, clienttest.Transaction{Events: []flow.Event{{Type: eventType}, {Type: eventType}}})
+			}
+			if !supported {
+				c.SetError(client.MethodSubscribeEvents, status.Error(codes.Unimplemented, "unknown service"))
+			}
+
+			store := eventfinder.NewFileCursorStore(filepath.Join(t.TempDir(), "cursor.json"))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var handled []eventfinder.Event
+			err := eventfinder.Tail(ctx, c, []string{eventType}, 1,
+				func(event eventfinder.Event) error {
+					handled = append(handled, event)
+					if len(handled) == 8 {
+						cancel()
+					}
+					return nil
+				},
+				eventfinder.WithStreaming(),
+				eventfinder.WithPollInterval(time.Millisecond),
+				eventfinder.WithCursorStore(store),
+			)
+			require.ErrorIs(t, err, context.Canceled)
+			require.Len(t, handled, 8)
+			for i := 1; i < len(handled); i++ {
+				require.True(t, less(handled[i-1], handled[i]), "events out of order at %d", i)
+			}
+
+			cursor, ok, err := store.LoadCursor(context.Background())
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.Greater(t, cursor.BlockHeight, handled[7].BlockHeight)
+
+			if supported {
+				require.Zero(t, c.Calls(client.MethodGetEventsForHeightRange))
+			} else {
+				require.NotZero(t, c.Calls(client.MethodGetEventsForHeightRange))
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package webhook forwards the events found or tailed by the event finder to an HTTP endpoint,
+// so services that are not written in Go can consume Flow events without running their own chain client:
+//
+//	forwarder := webhook.NewForwarder(webhook.DefaultConfig(url), logger)
+//	err := eventfinder.Tail(ctx, client, eventTypes, startHeight, forwarder.Handle,
+//		eventfinder.WithFlush(forwarder.Flush),
+//		eventfinder.WithCursorStore(store),
+//	)
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/onflow/flow-batch-scan/eventfinder"
+)
+
+const (
+	// DefaultBatchSize is the default number of events posted in one request.
+	DefaultBatchSize = 100
+	// DefaultTimeout is the default timeout of a request.
+	DefaultTimeout = 10 * time.Second
+	// DefaultRetries is the default number of times a failed request is retried.
+	DefaultRetries = 5
+	// DefaultRetryBackoff is the default wait before the first retry. It doubles with every retry.
+	DefaultRetryBackoff = time.Second
+)
+
+// SignatureHeader is the header of the signature of the request body, if a Secret is configured.
+// The value is "sha256=" followed by the hex encoded HMAC-SHA256 of the body with the secret.
+const SignatureHeader = "X-Signature-256"
+
+type Config struct {
+	// URL is the endpoint the events are posted to.
+	URL string
+	// Secret if set, is the key the request bodies are signed with. See SignatureHeader.
+	Secret string
+	// Headers are added to every request, e.g. for authorization.
+	Headers map[string]string
+	// BatchSize is the maximum number of events posted in one request.
+	BatchSize int
+	// Timeout is the timeout of a request.
+	Timeout time.Duration
+	// Retries is the number of times a request is retried if it fails with a network error,
+	// a 429 or a 5xx status code. Other status codes are not retried.
+	Retries int
+	// RetryBackoff is the wait before the first retry. It doubles with every retry.
+	RetryBackoff time.Duration
+	// DeadLetterPath if set, is the file the batches that could not be delivered are appended to,
+	// one JSON object per line, and forwarding continues. Otherwise the error is returned.
+	DeadLetterPath string
+}
+
+func DefaultConfig(url string) Config {
+	return Config{
+		URL:          url,
+		BatchSize:    DefaultBatchSize,
+		Timeout:      DefaultTimeout,
+		Retries:      DefaultRetries,
+		RetryBackoff: DefaultRetryBackoff,
+	}
+}
+
+// Record is an event in the request body.
+type Record struct {
+	BlockHeight      uint64    `json:"block_height"`
+	BlockID          string    `json:"block_id"`
+	BlockTimestamp   time.Time `json:"block_timestamp"`
+	TransactionID    string    `json:"transaction_id"`
+	TransactionIndex int       `json:"transaction_index"`
+	EventIndex       int       `json:"event_index"`
+	Type             string    `json:"type"`
+	// Fields are the fields of the event, see eventfinder.PlainValue.
+	Fields any `json:"fields"`
+}
+
+func NewRecord(event eventfinder.Event) Record {
+	return Record{
+		BlockHeight:      event.BlockHeight,
+		BlockID:          event.BlockID.Hex(),
+		BlockTimestamp:   event.BlockTimestamp,
+		TransactionID:    event.TransactionID.Hex(),
+		TransactionIndex: event.TransactionIndex,
+		EventIndex:       event.EventIndex,
+		Type:             event.Type,
+		Fields:           eventfinder.PlainValue(event.Value),
+	}
+}
+
+// Payload is the request body.
+type Payload struct {
+	Events []Record `json:"events"`
+}
+
+// deadLetter is a batch that could not be delivered.
+type deadLetter struct {
+	Time   time.Time `json:"time"`
+	Error  string    `json:"error"`
+	Events []Record  `json:"events"`
+}
+
+// permanentError is a response that is not retried.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Forwarder posts events to the webhook in batches. Events are buffered until a batch is full,
+// or until Flush or Close is called. It is safe for concurrent use.
+type Forwarder struct {
+	Config
+
+	client *http.Client
+	logger zerolog.Logger
+
+	mu      sync.Mutex
+	pending []Record
+}
+
+func NewForwarder(
+	config Config,
+	logger zerolog.Logger,
+) *Forwarder {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Forwarder{
+		Config: config,
+		client: &http.Client{Timeout: timeout},
+		logger: logger.With().Str("component", "webhook_forwarder").Logger(),
+	}
+}
+
+// Handle buffers the event, and posts the batch once it is full.
+// It is the handle function of eventfinder.Stream and eventfinder.Tail.
+func (f *Forwarder) Handle(event eventfinder.Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.pending = append(f.pending, NewRecord(event))
+	if len(f.pending) < f.BatchSize {
+		return nil
+	}
+	return f.flush(context.Background())
+}
+
+// Flush posts the buffered events.
+func (f *Forwarder) Flush(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.flush(ctx)
+}
+
+// Close posts the buffered events.
+func (f *Forwarder) Close() error {
+	return f.Flush(context.Background())
+}
+
+func (f *Forwarder) flush(ctx context.Context) error {
+	if len(f.pending) == 0 {
+		return nil
+	}
+	records := f.pending
+	f.pending = nil
+
+	err := f.send(ctx, records)
+	if err == nil || f.DeadLetterPath == "" || ctx.Err() != nil {
+		return err
+	}
+
+	f.logger.Error().
+		Err(err).
+		Int("events", len(records)).
+		Str("dead_letter_path", f.DeadLetterPath).
+		Msg("could not deliver events, writing them to the dead-letter file")
+	deadLetterErr := f.writeDeadLetter(records, err)
+	if deadLetterErr != nil {
+		return fmt.Errorf("failed to write to the dead-letter file: %w (delivery error: %s)", deadLetterErr, err)
+	}
+	return nil
+}
+
+// send posts the records, retrying failed requests.
+func (f *Forwarder) send(ctx context.Context, records []Record) error {
+	body, err := json.Marshal(Payload{Events: records})
+	if err != nil {
+		return err
+	}
+
+	backoff := f.RetryBackoff
+	err = f.post(ctx, body)
+	for i := 0; i < f.Retries && err != nil; i++ {
+		var permanent permanentError
+		if errors.As(err, &permanent) {
+			return err
+		}
+		f.logger.Warn().
+			Err(err).
+			Int("attempt", i+1).
+			Dur("backoff", backoff).
+			Msg("failed to post events, retrying")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		err = f.post(ctx, body)
+	}
+	return err
+}
+
+func (f *Forwarder) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.URL, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range f.Headers {
+		req.Header.Set(key, value)
+	}
+	if f.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(body, f.Secret))
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("webhook returned %s", resp.Status)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return err
+	}
+	return permanentError{err: err}
+}
+
+func (f *Forwarder) writeDeadLetter(records []Record, sendErr error) error {
+	data, err := json.Marshal(deadLetter{
+		Time:   time.Now().UTC(),
+		Error:  sendErr.Error(),
+		Events: records,
+	})
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.DeadLetterPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Sign returns the value of the SignatureHeader of the body.
+// Receivers verify a request by comparing the header with the signature of the body they received,
+// e.g. with hmac.Equal.
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,137 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/cadence/runtime/common"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/eventfinder"
+	"github.com/onflow/flow-batch-scan/eventfinder/webhook"
+)
+
+type endpoint struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	payloads []webhook.Payload
+}
+
+// newEndpoint returns the statuses in order, and 200 once they are used up.
+func newEndpoint(t *testing.T, secret string, statuses ...int) *endpoint {
+	e := &endpoint{statuses: statuses}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		if secret != "" {
+			require.Equal(t, webhook.Sign(body, secret), r.Header.Get(webhook.SignatureHeader))
+		}
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if len(e.statuses) > 0 {
+			status := e.statuses[0]
+			e.statuses = e.statuses[1:]
+			rw.WriteHeader(status)
+			return
+		}
+		payload := webhook.Payload{}
+		require.NoError(t, json.Unmarshal(body, &payload))
+		e.payloads = append(e.payloads, payload)
+	}))
+	t.Cleanup(e.Close)
+	return e
+}
+
+func testEvent(height uint64) eventfinder.Event {
+	value := cadence.NewEvent([]cadence.Value{cadence.UFix64(100_000_000)}).
+		WithType(&cadence.EventType{
+			Location:            common.AddressLocation{Address: common.Address{0, 0, 0, 0, 0, 0, 0, 1}, Name: "Token"},
+			QualifiedIdentifier: "Token.Deposited",
+			Fields:              []cadence.Field{{Identifier: "amount", Type: cadence.UFix64Type{}}},
+		})
+	return eventfinder.Event{
+		BlockHeight: height,
+		Type:        value.EventType.ID(),
+		Value:       value,
+	}
+}
+
+func TestForwarder(t *testing.T) {
+	e := newEndpoint(t, "secret", http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	config := webhook.DefaultConfig(e.URL)
+	config.Secret = "secret"
+	config.BatchSize = 2
+	config.RetryBackoff = 0
+	f := webhook.NewForwarder(config, zerolog.Nop())
+
+	for height := uint64(1); height <= 3; height++ {
+		require.NoError(t, f.Handle(testEvent(height)))
+	}
+	// the first batch was retried until it was delivered
+	require.Len(t, e.payloads, 1)
+	require.Len(t, e.payloads[0].Events, 2)
+
+	require.NoError(t, f.Close())
+	require.Len(t, e.payloads, 2)
+	record := e.payloads[1].Events[0]
+	require.Equal(t, uint64(3), record.BlockHeight)
+	require.Equal(t, map[string]any{"amount": 1.0}, record.Fields)
+}
+
+func TestForwarder_DeadLetter(t *testing.T) {
+	e := newEndpoint(t, "", http.StatusBadRequest, http.StatusInternalServerError)
+	config := webhook.DefaultConfig(e.URL)
+	config.RetryBackoff = 0
+
+	// a 400 is not retried
+	f := webhook.NewForwarder(config, zerolog.Nop())
+	require.NoError(t, f.Handle(testEvent(1)))
+	require.ErrorContains(t, f.Flush(context.Background()), "400 Bad Request")
+
+	config.Retries = 0
+	config.DeadLetterPath = filepath.Join(t.TempDir(), "dead-letter.ndjson")
+	f = webhook.NewForwarder(config, zerolog.Nop())
+	require.NoError(t, f.Handle(testEvent(2)))
+	require.NoError(t, f.Close())
+
+	file, err := os.Open(config.DeadLetterPath)
+	require.NoError(t, err)
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	require.True(t, scanner.Scan())
+	var letter struct {
+		Error  string           `json:"error"`
+		Events []webhook.Record `json:"events"`
+	}
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &letter))
+	require.Contains(t, letter.Error, "500 Internal Server Error")
+	require.Equal(t, uint64(2), letter.Events[0].BlockHeight)
+	require.False(t, scanner.Scan())
+}
@@ -0,0 +1,192 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evm
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	flowgrpc "github.com/onflow/flow-go-sdk/access/grpc"
+	"github.com/rs/zerolog"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+	"github.com/onflow/flow-batch-scan/client"
+)
+
+const CandidatesScannerName = "evm_candidates_scanner"
+
+// CandidatesScanner finds the Flow accounts that executed EVM transactions in the block range:
+// the authorizers of the Flow transactions with EVM.TransactionExecuted events,
+// e.g. the owners of Cadence-owned accounts (COAs).
+// With WithEVMAddresses only the EVM transactions that touched the given EVM addresses
+// (e.g. COAs or EVM contracts, see TransactionExecuted.Addresses) are considered.
+type CandidatesScanner struct {
+	eventType string
+	chainID   *big.Int
+	addresses map[common.Address]struct{}
+
+	chunkSize   uint64
+	concurrency int
+
+	logger zerolog.Logger
+}
+
+type CandidatesScannerOption = func(*CandidatesScanner)
+
+// WithEVMAddresses only considers the EVM transactions that touched one of the addresses.
+func WithEVMAddresses(addresses ...common.Address) CandidatesScannerOption {
+	return func(s *CandidatesScanner) {
+		if s.addresses == nil {
+			s.addresses = make(map[common.Address]struct{}, len(addresses))
+		}
+		for _, address := range addresses {
+			s.addresses[address] = struct{}{}
+		}
+	}
+}
+
+// WithEventQueryChunkSize sets the maximum number of blocks queried for events at once.
+func WithEventQueryChunkSize(size uint64) CandidatesScannerOption {
+	return func(s *CandidatesScanner) {
+		s.chunkSize = size
+	}
+}
+
+// WithEventQueryConcurrency sets the maximum number of concurrent event queries.
+func WithEventQueryConcurrency(concurrency int) CandidatesScannerOption {
+	return func(s *CandidatesScanner) {
+		s.concurrency = concurrency
+	}
+}
+
+func NewCandidatesScanner(
+	chain flow.ChainID,
+	logger zerolog.Logger,
+	options ...CandidatesScannerOption,
+) *CandidatesScanner {
+	s := &CandidatesScanner{
+		eventType: TransactionExecutedEventType(chain),
+		chainID:   ChainIDs[chain],
+
+		chunkSize:   candidates.DefaultEventQueryChunkSize,
+		concurrency: candidates.DefaultEventQueryConcurrency,
+
+		logger: logger.With().Str("component", CandidatesScannerName).Logger(),
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+var _ candidates.CandidateScanner = (*CandidatesScanner)(nil)
+
+func (s *CandidatesScanner) Scan(
+	ctx context.Context,
+	client client.Client,
+	blocks candidates.BlockRange,
+) candidates.CandidatesResult {
+	return candidates.ScanChunks(blocks, s.chunkSize, s.concurrency, func(chunk candidates.BlockRange) candidates.CandidatesResult {
+		return s.scanChunk(ctx, client, chunk)
+	})
+}
+
+func (s *CandidatesScanner) scanChunk(
+	ctx context.Context,
+	client client.Client,
+	blocks candidates.BlockRange,
+) candidates.CandidatesResult {
+	l := s.logger.With().
+		Uint64("start", blocks.Start).
+		Uint64("end", blocks.End).
+		Logger()
+
+	blockEvents, err := client.GetEventsForHeightRange(ctx, flowgrpc.EventRangeQuery{
+		Type:        s.eventType,
+		StartHeight: blocks.Start,
+		EndHeight:   blocks.End,
+	})
+	if err != nil {
+		l.Error().
+			Err(err).
+			Msg("could not get EVM events")
+		return candidates.NewCandidatesResultError(err)
+	}
+
+	result := candidates.NewCandidatesResult(make(map[flow.Address]struct{}))
+	// the authorizers are only fetched once per Flow transaction
+	authorizers := make(map[flow.Identifier][]flow.Address)
+	for _, block := range blockEvents {
+		for _, event := range block.Events {
+			matches, err := s.matches(event.Value)
+			if err != nil {
+				l.Error().
+					Err(err).
+					Uint64("block_height", block.Height).
+					Msg("could not decode EVM transaction")
+				return candidates.NewCandidatesResultError(err)
+			}
+			if !matches {
+				continue
+			}
+
+			addresses, ok := authorizers[event.TransactionID]
+			if !ok {
+				tx, err := client.GetTransaction(ctx, event.TransactionID)
+				if err != nil {
+					return candidates.NewCandidatesResultError(err)
+				}
+				addresses = tx.Authorizers
+				authorizers[event.TransactionID] = addresses
+			}
+			for _, address := range addresses {
+				result.Add(address, candidates.Provenance{
+					Scanner:       CandidatesScannerName,
+					BlockHeight:   block.Height,
+					TransactionID: event.TransactionID,
+					EventType:     event.Type,
+					EventIndex:    event.EventIndex,
+				})
+			}
+		}
+	}
+	return result
+}
+
+// matches returns true if the EVM transaction touched one of the addresses, or if no addresses are set.
+func (s *CandidatesScanner) matches(event cadence.Event) (bool, error) {
+	if len(s.addresses) == 0 {
+		return true, nil
+	}
+	tx, err := DecodeTransactionExecuted(event)
+	if err != nil {
+		return false, err
+	}
+	addresses, err := tx.Addresses(s.chainID)
+	if err != nil {
+		return false, err
+	}
+	for _, address := range addresses {
+		if _, ok := s.addresses[address]; ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,367 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package evm decodes the events of the EVM on Flow: EVM.TransactionExecuted and EVM.BlockExecuted,
+// including the EVM logs embedded in them, which can be unpacked with the ABIs of the EVM contracts.
+// See LogDecoder and CandidatesScanner.
+package evm
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+	"github.com/onflow/flow-batch-scan/eventfinder"
+)
+
+// ContractAddresses are the addresses of the EVM contract on the Flow networks.
+var ContractAddresses = map[flow.ChainID]flow.Address{
+	flow.Mainnet:  flow.HexToAddress("e467b9dd11fa00df"),
+	flow.Testnet:  flow.HexToAddress("8c5303eaa26202d6"),
+	flow.Emulator: flow.HexToAddress("f8d6e0586b0a20c7"),
+}
+
+// ChainIDs are the EVM chain IDs of the Flow networks, used to recover the senders of signed transactions.
+var ChainIDs = map[flow.ChainID]*big.Int{
+	flow.Mainnet:  big.NewInt(747),
+	flow.Testnet:  big.NewInt(545),
+	flow.Emulator: big.NewInt(646),
+}
+
+// TransactionExecutedEventType returns the type of the EVM.TransactionExecuted events of the chain.
+func TransactionExecutedEventType(chain flow.ChainID) string {
+	return eventType(chain, "TransactionExecuted")
+}
+
+// BlockExecutedEventType returns the type of the EVM.BlockExecuted events of the chain.
+func BlockExecutedEventType(chain flow.ChainID) string {
+	return eventType(chain, "BlockExecuted")
+}
+
+func eventType(chain flow.ChainID, name string) string {
+	return fmt.Sprintf("A.%s.EVM.%s", ContractAddresses[chain].Hex(), name)
+}
+
+// DirectCallTxType is the transaction type of direct calls. Direct calls are made by Cadence-owned accounts (COAs)
+// and by the EVM contract itself, they are not signed Ethereum transactions. See DirectCall.
+const DirectCallTxType = 0xff
+
+// TransactionExecuted is an EVM.TransactionExecuted event: an EVM transaction, executed in a Flow transaction.
+type TransactionExecuted struct {
+	// BlockHeight is the height of the EVM block.
+	BlockHeight uint64
+	Hash        common.Hash
+	// Index is the index of the transaction in the EVM block.
+	Index uint16
+	Type  uint8
+	// Payload is the encoded transaction. See Transaction and DirectCall.
+	Payload      []byte
+	ErrorCode    uint16
+	ErrorMessage string
+	GasConsumed  uint64
+	// ContractAddress is the address of the contract the transaction deployed, if any.
+	ContractAddress *common.Address
+	Logs            []*types.Log
+	ReturnedData    []byte
+}
+
+// Failed returns true if the EVM transaction failed.
+func (t TransactionExecuted) Failed() bool {
+	return t.ErrorCode != 0
+}
+
+// Transaction decodes the payload of a signed Ethereum transaction.
+// It returns an error for direct calls, see DirectCall.
+func (t TransactionExecuted) Transaction() (*types.Transaction, error) {
+	if t.Type == DirectCallTxType {
+		return nil, fmt.Errorf("transaction %s is a direct call", t.Hash)
+	}
+	tx := &types.Transaction{}
+	err := tx.UnmarshalBinary(t.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode transaction %s: %w", t.Hash, err)
+	}
+	return tx, nil
+}
+
+// DirectCall is a call made by a Cadence-owned account (COA) or the EVM contract, e.g. a COA deposit or a contract call.
+type DirectCall struct {
+	Type     byte
+	SubType  byte
+	From     common.Address
+	To       common.Address
+	Data     []byte
+	Value    *big.Int
+	GasLimit uint64
+}
+
+// DirectCall decodes the payload of a direct call.
+// It returns an error for signed Ethereum transactions, see Transaction.
+func (t TransactionExecuted) DirectCall() (DirectCall, error) {
+	if t.Type != DirectCallTxType || len(t.Payload) == 0 {
+		return DirectCall{}, fmt.Errorf("transaction %s is not a direct call", t.Hash)
+	}
+	var call DirectCall
+	err := rlp.DecodeBytes(t.Payload[1:], &call)
+	if err != nil {
+		return DirectCall{}, fmt.Errorf("failed to decode direct call %s: %w", t.Hash, err)
+	}
+	return call, nil
+}
+
+// Addresses returns the EVM addresses the transaction touched: the sender and the recipient,
+// the deployed contract and the contracts that emitted logs.
+// The sender of signed transactions is recovered with the chain ID, see ChainIDs.
+func (t TransactionExecuted) Addresses(chainID *big.Int) ([]common.Address, error) {
+	var addresses []common.Address
+	if t.Type == DirectCallTxType {
+		call, err := t.DirectCall()
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, call.From, call.To)
+	} else {
+		tx, err := t.Transaction()
+		if err != nil {
+			return nil, err
+		}
+		from, err := types.Sender(types.LatestSignerForChainID(chainID), tx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to recover the sender of transaction %s: %w", t.Hash, err)
+		}
+		addresses = append(addresses, from)
+		if to := tx.To(); to != nil {
+			addresses = append(addresses, *to)
+		}
+	}
+	if t.ContractAddress != nil {
+		addresses = append(addresses, *t.ContractAddress)
+	}
+	for _, log := range t.Logs {
+		addresses = append(addresses, log.Address)
+	}
+	return addresses, nil
+}
+
+// BlockExecuted is an EVM.BlockExecuted event: an EVM block, executed in a Flow block.
+type BlockExecuted struct {
+	Height              uint64
+	Hash                common.Hash
+	Timestamp           uint64
+	TotalSupply         *big.Int
+	TotalGasUsed        uint64
+	ParentHash          common.Hash
+	ReceiptRoot         common.Hash
+	TransactionHashRoot common.Hash
+}
+
+// DecodeTransactionExecuted decodes an EVM.TransactionExecuted event.
+// The fields are looked up by name, fields that are missing in older versions of the event are left empty.
+func DecodeTransactionExecuted(event cadence.Event) (TransactionExecuted, error) {
+	f := fields{event: event}
+	t := TransactionExecuted{
+		BlockHeight:  f.uint64("blockHeight"),
+		Hash:         common.BytesToHash(f.bytes("hash")),
+		Index:        uint16(f.uint64("index")),
+		Type:         uint8(f.uint64("type")),
+		Payload:      f.bytes("payload"),
+		ErrorCode:    uint16(f.uint64("errorCode")),
+		ErrorMessage: f.string("errorMessage"),
+		GasConsumed:  f.uint64("gasConsumed"),
+		ReturnedData: f.bytes("returnedData"),
+	}
+	if address := f.string("contractAddress"); address != "" && common.IsHexAddress(address) {
+		contractAddress := common.HexToAddress(address)
+		if contractAddress != (common.Address{}) {
+			t.ContractAddress = &contractAddress
+		}
+	}
+	if logs := f.bytes("logs"); len(logs) > 0 && f.err == nil {
+		err := rlp.DecodeBytes(logs, &t.Logs)
+		if err != nil {
+			return TransactionExecuted{}, fmt.Errorf("failed to decode the logs of transaction %s: %w", t.Hash, err)
+		}
+		for i, log := range t.Logs {
+			log.BlockNumber = t.BlockHeight
+			log.TxHash = t.Hash
+			log.TxIndex = uint(t.Index)
+			log.Index = uint(i)
+		}
+	}
+	if f.err != nil {
+		return TransactionExecuted{}, f.err
+	}
+	return t, nil
+}
+
+// DecodeBlockExecuted decodes an EVM.BlockExecuted event.
+func DecodeBlockExecuted(event cadence.Event) (BlockExecuted, error) {
+	f := fields{event: event}
+	b := BlockExecuted{
+		Height:              f.uint64("height"),
+		Hash:                common.BytesToHash(f.bytes("hash")),
+		Timestamp:           f.uint64("timestamp"),
+		TotalSupply:         f.bigInt("totalSupply"),
+		TotalGasUsed:        f.uint64("totalGasUsed"),
+		ParentHash:          common.BytesToHash(f.bytes("parentHash")),
+		ReceiptRoot:         common.BytesToHash(f.bytes("receiptRoot")),
+		TransactionHashRoot: common.BytesToHash(f.bytes("transactionHashRoot")),
+	}
+	if f.err != nil {
+		return BlockExecuted{}, f.err
+	}
+	return b, nil
+}
+
+// fields reads the fields of an event. Missing fields are zero values,
+// the first field with an unexpected type is kept as the error.
+type fields struct {
+	event cadence.Event
+	err   error
+}
+
+func (f *fields) value(name string) cadence.Value {
+	value, err := candidates.EventField(f.event, name)
+	if err != nil {
+		return nil
+	}
+	if optional, ok := value.(cadence.Optional); ok {
+		return optional.Value
+	}
+	return value
+}
+
+func typeID(event cadence.Event) string {
+	if event.EventType == nil {
+		return ""
+	}
+	return event.EventType.ID()
+}
+
+func (f *fields) fail(name string, value cadence.Value, expected string) {
+	if f.err == nil {
+		f.err = fmt.Errorf("field %s of %s is not %s: %s", name, typeID(f.event), expected, value)
+	}
+}
+
+func (f *fields) uint64(name string) uint64 {
+	value := f.value(name)
+	if value == nil {
+		return 0
+	}
+	n, ok := new(big.Int).SetString(value.String(), 10)
+	if !ok || !n.IsUint64() {
+		f.fail(name, value, "an unsigned integer")
+		return 0
+	}
+	return n.Uint64()
+}
+
+func (f *fields) bigInt(name string) *big.Int {
+	value := f.value(name)
+	if value == nil {
+		return nil
+	}
+	n, ok := new(big.Int).SetString(value.String(), 10)
+	if !ok {
+		f.fail(name, value, "an integer")
+		return nil
+	}
+	return n
+}
+
+func (f *fields) string(name string) string {
+	value := f.value(name)
+	if value == nil {
+		return ""
+	}
+	s, ok := value.(cadence.String)
+	if !ok {
+		f.fail(name, value, "a string")
+		return ""
+	}
+	return string(s)
+}
+
+func (f *fields) bytes(name string) []byte {
+	value := f.value(name)
+	if value == nil {
+		return nil
+	}
+	return f.toBytes(name, value)
+}
+
+// toBytes converts an array of UInt8 or a hex string to bytes. Older versions of the events used hex strings.
+func (f *fields) toBytes(name string, value cadence.Value) []byte {
+	switch value := value.(type) {
+	case cadence.Array:
+		b := make([]byte, len(value.Values))
+		for i, element := range value.Values {
+			u, ok := element.(cadence.UInt8)
+			if !ok {
+				f.fail(name, value, "an array of UInt8")
+				return nil
+			}
+			b[i] = byte(u)
+		}
+		return b
+	case cadence.String:
+		b, err := hex.DecodeString(strings.TrimPrefix(string(value), "0x"))
+		if err != nil {
+			f.fail(name, value, "hex encoded")
+			return nil
+		}
+		return b
+	default:
+		f.fail(name, value, "bytes")
+		return nil
+	}
+}
+
+// ErrNotEVMEvent is returned by Decode for events that are not EVM events.
+var ErrNotEVMEvent = errors.New("not an EVM event")
+
+// Decode decodes an EVM.TransactionExecuted event into a TransactionExecuted,
+// and an EVM.BlockExecuted event into a BlockExecuted.
+func Decode(event cadence.Event) (any, error) {
+	id := typeID(event)
+	switch {
+	case strings.HasSuffix(id, ".EVM.TransactionExecuted"):
+		return DecodeTransactionExecuted(event)
+	case strings.HasSuffix(id, ".EVM.BlockExecuted"):
+		return DecodeBlockExecuted(event)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrNotEVMEvent, id)
+	}
+}
+
+// RegisterIn decodes the EVM events of the chain with the registry of the event finder,
+// so eventfinder.As[TransactionExecuted] and eventfinder.As[BlockExecuted] can be used on the found events.
+func RegisterIn(registry *eventfinder.Registry, chain flow.ChainID) {
+	registry.RegisterFunc(TransactionExecutedEventType(chain), func(event cadence.Event) (any, error) {
+		return DecodeTransactionExecuted(event)
+	})
+	registry.RegisterFunc(BlockExecutedEventType(chain), func(event cadence.Event) (any, error) {
+		return DecodeBlockExecuted(event)
+	})
+}
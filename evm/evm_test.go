@@ -0,0 +1,190 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evm_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/onflow/cadence"
+	cadencecommon "github.com/onflow/cadence/runtime/common"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+	"github.com/onflow/flow-batch-scan/eventfinder"
+	"github.com/onflow/flow-batch-scan/evm"
+)
+
+const erc20ABI = `[{"anonymous":false,"inputs":[
+	{"indexed":true,"name":"from","type":"address"},
+	{"indexed":true,"name":"to","type":"address"},
+	{"indexed":false,"name":"value","type":"uint256"}
+],"name":"Transfer","type":"event"}]`
+
+var (
+	coa      = common.HexToAddress("0x000000000000000000000002aaaaaaaaaaaaaaaa")
+	token    = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	receiver = common.HexToAddress("0x2222222222222222222222222222222222222222")
+)
+
+func bytesValue(b []byte) cadence.Array {
+	values := make([]cadence.Value, len(b))
+	for i, v := range b {
+		values[i] = cadence.UInt8(v)
+	}
+	return cadence.NewArray(values)
+}
+
+// transferLog is an ERC-20 Transfer log of the token.
+func transferLog(from, to common.Address, value int64) *types.Log {
+	return &types.Log{
+		Address: token,
+		Topics: []common.Hash{
+			crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)")),
+			common.BytesToHash(from.Bytes()),
+			common.BytesToHash(to.Bytes()),
+		},
+		Data: common.LeftPadBytes(big.NewInt(value).Bytes(), 32),
+	}
+}
+
+// transactionExecuted is an EVM.TransactionExecuted event of a direct call of the COA to the token.
+func transactionExecuted(t *testing.T) cadence.Event {
+	call, err := rlp.EncodeToBytes(evm.DirectCall{
+		Type:     evm.DirectCallTxType,
+		SubType:  4,
+		From:     coa,
+		To:       token,
+		Value:    big.NewInt(0),
+		GasLimit: 100_000,
+	})
+	require.NoError(t, err)
+	logs, err := rlp.EncodeToBytes([]*types.Log{transferLog(coa, receiver, 42)})
+	require.NoError(t, err)
+
+	location := cadencecommon.AddressLocation{Address: cadencecommon.Address(evm.ContractAddresses[flow.Emulator]), Name: "EVM"}
+	return cadence.NewEvent([]cadence.Value{
+		bytesValue(common.HexToHash("0x01").Bytes()),
+		cadence.UInt16(3),
+		cadence.UInt8(evm.DirectCallTxType),
+		bytesValue(append([]byte{evm.DirectCallTxType}, call...)),
+		cadence.UInt16(0),
+		cadence.String(""),
+		cadence.UInt64(21_000),
+		cadence.String(""),
+		bytesValue(logs),
+		cadence.UInt64(7),
+	}).WithType(&cadence.EventType{
+		Location:            location,
+		QualifiedIdentifier: "EVM.TransactionExecuted",
+		Fields: []cadence.Field{
+			{Identifier: "hash"},
+			{Identifier: "index"},
+			{Identifier: "type"},
+			{Identifier: "payload"},
+			{Identifier: "errorCode"},
+			{Identifier: "errorMessage"},
+			{Identifier: "gasConsumed"},
+			{Identifier: "contractAddress"},
+			{Identifier: "logs"},
+			{Identifier: "blockHeight"},
+		},
+	})
+}
+
+func TestDecodeTransactionExecuted(t *testing.T) {
+	event := transactionExecuted(t)
+	require.Equal(t, evm.TransactionExecutedEventType(flow.Emulator), event.EventType.ID())
+
+	tx, err := evm.DecodeTransactionExecuted(event)
+	require.NoError(t, err)
+	require.Equal(t, common.HexToHash("0x01"), tx.Hash)
+	require.Equal(t, uint64(7), tx.BlockHeight)
+	require.Equal(t, uint16(3), tx.Index)
+	require.False(t, tx.Failed())
+	require.Nil(t, tx.ContractAddress)
+	require.Len(t, tx.Logs, 1)
+	require.Equal(t, tx.Hash, tx.Logs[0].TxHash)
+
+	call, err := tx.DirectCall()
+	require.NoError(t, err)
+	require.Equal(t, coa, call.From)
+	_, err = tx.Transaction()
+	require.Error(t, err)
+
+	addresses, err := tx.Addresses(evm.ChainIDs[flow.Emulator])
+	require.NoError(t, err)
+	require.Equal(t, []common.Address{coa, token, token}, addresses)
+
+	registry := eventfinder.NewRegistry()
+	evm.RegisterIn(registry, flow.Emulator)
+	decoded, err := registry.Decode(event)
+	require.NoError(t, err)
+	require.Equal(t, tx, decoded)
+}
+
+func TestLogDecoder(t *testing.T) {
+	d := evm.NewLogDecoder()
+	// the ABI of another contract at the token address is tried first, but has no Transfer event
+	require.NoError(t, d.AddJSON("Other", `[]`, token))
+	require.NoError(t, d.AddJSON("ERC20", erc20ABI))
+
+	decoded, err := d.DecodeAll([]*types.Log{
+		transferLog(coa, receiver, 42),
+		{Address: token, Topics: []common.Hash{crypto.Keccak256Hash([]byte("Unknown()"))}},
+	})
+	require.NoError(t, err)
+	require.Len(t, decoded, 1)
+	require.Equal(t, "ERC20", decoded[0].Contract)
+	require.Equal(t, "Transfer", decoded[0].Event)
+	require.Equal(t, map[string]any{
+		"from":  coa,
+		"to":    receiver,
+		"value": big.NewInt(42),
+	}, decoded[0].Fields)
+}
+
+func TestCandidatesScanner(t *testing.T) {
+	owner := flow.HexToAddress("03")
+	c := clienttest.New()
+	c.AddBlocks(1, 10)
+	c.AddBlock(5, clienttest.Transaction{
+		Authorizers: []flow.Address{owner},
+		Events: []flow.Event{{
+			Type:  evm.TransactionExecutedEventType(flow.Emulator),
+			Value: transactionExecuted(t),
+		}},
+	})
+	blocks := candidates.BlockRange{Start: 1, End: 10}
+
+	result := evm.NewCandidatesScanner(flow.Emulator, zerolog.Nop(), evm.WithEVMAddresses(coa)).
+		Scan(context.Background(), c, blocks)
+	require.NoError(t, result.Err())
+	require.Equal(t, map[flow.Address]struct{}{owner: {}}, result.Addresses)
+	require.Equal(t, evm.CandidatesScannerName, result.Provenance[owner][0].Scanner)
+
+	result = evm.NewCandidatesScanner(flow.Emulator, zerolog.Nop(), evm.WithEVMAddresses(receiver)).
+		Scan(context.Background(), c, blocks)
+	require.NoError(t, result.Err())
+	require.Empty(t, result.Addresses)
+}
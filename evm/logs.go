@@ -0,0 +1,154 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evm
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DecodedLog is an EVM log unpacked with the ABI of the contract that emitted it.
+type DecodedLog struct {
+	Log *types.Log
+	// Contract is the name the ABI was added to the LogDecoder with.
+	Contract string
+	// Event is the name of the event in the ABI, e.g. "Transfer".
+	Event string
+	// Fields are the indexed and non-indexed arguments of the event by name.
+	Fields map[string]any
+}
+
+// LogDecoder unpacks EVM logs with the ABIs of the contracts that emitted them.
+// It is safe for concurrent use.
+type LogDecoder struct {
+	mu        sync.RWMutex
+	contracts []contractABI
+}
+
+type contractABI struct {
+	name string
+	abi  abi.ABI
+	// addresses if set, are the only addresses the ABI is used for.
+	addresses map[common.Address]struct{}
+}
+
+func NewLogDecoder() *LogDecoder {
+	return &LogDecoder{}
+}
+
+// Add adds the ABI of a contract. If addresses are given, the ABI is only used for the logs of these addresses,
+// otherwise it is used for the logs of all the contracts with matching events (e.g. all ERC-20 tokens).
+// ABIs with addresses are tried before ABIs without, in the order they were added.
+func (d *LogDecoder) Add(name string, contract abi.ABI, addresses ...common.Address) {
+	c := contractABI{
+		name: name,
+		abi:  contract,
+	}
+	if len(addresses) > 0 {
+		c.addresses = make(map[common.Address]struct{}, len(addresses))
+		for _, address := range addresses {
+			c.addresses[address] = struct{}{}
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.contracts = append(d.contracts, c)
+}
+
+// AddJSON parses the JSON ABI of a contract, and adds it like Add.
+func (d *LogDecoder) AddJSON(name string, contractABI string, addresses ...common.Address) error {
+	contract, err := abi.JSON(strings.NewReader(contractABI))
+	if err != nil {
+		return fmt.Errorf("invalid ABI of %s: %w", name, err)
+	}
+	d.Add(name, contract, addresses...)
+	return nil
+}
+
+// Decode unpacks the log. ok is false if no ABI has an event with the signature of the log.
+func (d *LogDecoder) Decode(log *types.Log) (decoded DecodedLog, ok bool, err error) {
+	if len(log.Topics) == 0 {
+		// anonymous events can not be matched
+		return DecodedLog{}, false, nil
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	c, event, ok := d.find(log)
+	if !ok {
+		return DecodedLog{}, false, nil
+	}
+	fields := make(map[string]any, len(event.Inputs))
+	err = event.Inputs.UnpackIntoMap(fields, log.Data)
+	if err != nil {
+		return DecodedLog{}, false, fmt.Errorf("failed to unpack %s.%s log: %w", c.name, event.Name, err)
+	}
+	var indexed abi.Arguments
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+	err = abi.ParseTopicsIntoMap(fields, indexed, log.Topics[1:])
+	if err != nil {
+		return DecodedLog{}, false, fmt.Errorf("failed to unpack the topics of %s.%s log: %w", c.name, event.Name, err)
+	}
+	return DecodedLog{
+		Log:      log,
+		Contract: c.name,
+		Event:    event.Name,
+		Fields:   fields,
+	}, true, nil
+}
+
+func (d *LogDecoder) find(log *types.Log) (contractABI, *abi.Event, bool) {
+	for _, byAddress := range []bool{true, false} {
+		for _, c := range d.contracts {
+			if (c.addresses != nil) != byAddress {
+				continue
+			}
+			if _, ok := c.addresses[log.Address]; byAddress && !ok {
+				continue
+			}
+			event, err := c.abi.EventByID(log.Topics[0])
+			if err == nil {
+				return c, event, true
+			}
+		}
+	}
+	return contractABI{}, nil, false
+}
+
+// DecodeAll unpacks the logs that an ABI matches, and skips the others.
+func (d *LogDecoder) DecodeAll(logs []*types.Log) ([]DecodedLog, error) {
+	var decoded []DecodedLog
+	for _, log := range logs {
+		l, ok, err := d.Decode(log)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			decoded = append(decoded, l)
+		}
+	}
+	return decoded, nil
+}
@@ -17,12 +17,9 @@ package main
 import (
 	"context"
 	_ "embed"
-	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
 
-	"github.com/bjartek/overflow"
 	"github.com/onflow/cadence"
 	fbs "github.com/onflow/flow-batch-scan"
 	scanner "github.com/onflow/flow-batch-scan"
@@ -54,8 +51,8 @@ func main() {
 	// Any access api would work.
 	// This uses `client.Client` from the flow-batch-scan package, which has some rate limits already set,
 	// and a timeout, in case the network is not responding.
-	flowClient, err := client.NewClient(
-		"access.devnet.nodes.onflow.org:9000",
+	preset := client.TestnetPreset()
+	flowClient, err := preset.NewClient(
 		client.WithLog(log.Logger),
 	)
 	if err != nil {
@@ -97,7 +94,7 @@ func main() {
 		WithCandidateScanners(candidateScanners).
 		WithScriptResultHandler(scriptResultHandler).
 		WithBatchSize(batchSize).
-		WithChainID(flow.Testnet).
+		ForNetwork(preset).
 		WithLogger(log.Logger).
 		// false is actually the default.
 		// This means that once the full scan is done the scanner will stop.
@@ -108,10 +105,13 @@ func main() {
 	// It will run a full scan, that will switch to a newer reference block every so often.
 	// It will also run an incremental scanner, that will catch any changes that happened since the full scan started
 	// using the `candidateScanners`.
-	scan := scanner.NewScanner(
+	scan, err := scanner.NewScanner(
 		flowClient,
 		config,
 	)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid scanner config")
+	}
 
 	// Start the scanner.
 	result, err := scan.Scan(context.Background())
@@ -135,52 +135,76 @@ type scriptResultHandler struct {
 	logger zerolog.Logger
 }
 
-// NewScriptResultHandler is a simple result handler that prints the results to the log.
+// NewScriptResultHandler is a simple result handler that writes the contracts to files.
+// The results are decoded to Go values before they are handled.
 func NewScriptResultHandler(
 	logger zerolog.Logger,
 ) fbs.ScriptResultHandler {
 	h := &scriptResultHandler{
 		logger: logger,
 	}
-	return h
+	return fbs.NewTypedResultHandler(decodeContracts, h.handle)
 }
 
-func (r *scriptResultHandler) Handle(batch fbs.ProcessedAddressBatch) error {
-
-	//read as overflow value
-	value, err := overflow.CadenceValueToJsonString(batch.Result)
-	if err != nil {
-		r.logger.Error().Err(err).Msg("cadence value convert")
-		return nil
-	}
-
-	if strings.TrimSpace(value) == "" {
-		return nil
-	}
-
-	var contracts []Contract
-	err = json.Unmarshal([]byte(value), &contracts)
-	if err != nil {
-		r.logger.Error().Err(err).Str("input", value).Msg("marshal to contract")
-		return nil
-	}
+func (r *scriptResultHandler) handle(contracts []Contract, _ fbs.ProcessedAddressBatch) error {
 	for _, c := range contracts {
-		prefix := strings.TrimPrefix(c.Address, "0x")
 		for name, body := range c.Contracts {
 			bodyBytes := []byte(body)
-			fileName := fmt.Sprintf("result/A.%s.%s.cdc", prefix, name)
+			fileName := fmt.Sprintf("result/A.%s.%s.cdc", c.Address.Hex(), name)
 			err := io.WriteFile(fileName, bodyBytes)
 			if err != nil {
 				return err
 			}
 
-			//	r.logger.Info().Msg(fileName)
+			r.logger.Debug().Msg(fileName)
 		}
 	}
 	return nil
 }
 
 type Contract struct {
-	Address   string
+	Address   flow.Address
 	Contracts map[string]string
 }
+
+// decodeContracts decodes the `[AccountInfo]` returned by the script.
+func decodeContracts(value cadence.Value) ([]Contract, error) {
+	array, ok := value.(cadence.Array)
+	if !ok {
+		return nil, fmt.Errorf("expected an array, got %T", value)
+	}
+
+	contracts := make([]Contract, 0, len(array.Values))
+	for _, v := range array.Values {
+		info, ok := v.(cadence.Struct)
+		if !ok || len(info.Fields) != 2 {
+			return nil, fmt.Errorf("expected AccountInfo, got %T", v)
+		}
+		address, ok := info.Fields[0].(cadence.Address)
+		if !ok {
+			return nil, fmt.Errorf("expected Address, got %T", info.Fields[0])
+		}
+		dictionary, ok := info.Fields[1].(cadence.Dictionary)
+		if !ok {
+			return nil, fmt.Errorf("expected {String: String}, got %T", info.Fields[1])
+		}
+
+		contract := Contract{
+			Address:   flow.Address(address),
+			Contracts: make(map[string]string, len(dictionary.Pairs)),
+		}
+		for _, pair := range dictionary.Pairs {
+			name, ok := pair.Key.(cadence.String)
+			if !ok {
+				return nil, fmt.Errorf("expected String, got %T", pair.Key)
+			}
+			code, ok := pair.Value.(cadence.String)
+			if !ok {
+				return nil, fmt.Errorf("expected String, got %T", pair.Value)
+			}
+			contract.Contracts[string(name)] = string(code)
+		}
+		contracts = append(contracts, contract)
+	}
+	return contracts, nil
+}
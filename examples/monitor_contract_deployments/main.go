@@ -21,6 +21,7 @@ import (
 	"github.com/onflow/flow-batch-scan"
 	"github.com/onflow/flow-batch-scan/candidates"
 	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/eventfinder"
 	"github.com/onflow/flow-go-sdk"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -30,14 +31,20 @@ import (
 //go:embed get_contract_deployed.cdc
 var Script string
 
+// AccountContractUpdated is the flow.AccountContractUpdated event.
+// Only the fields that are needed are decoded, so new fields of the event do not break it.
+type AccountContractUpdated struct {
+	Address cadence.Address `cadence:"address"`
+}
+
 // This is a very similar example to the contract_names example. Please see that one first.
 func main() {
 	log.Logger = log.
 		Output(zerolog.ConsoleWriter{Out: os.Stderr}).
 		Level(zerolog.InfoLevel)
 
-	flowClient, err := client.NewClient(
-		"access.testnet.nodes.onflow.org:9000",
+	preset := client.TestnetPreset()
+	flowClient, err := preset.NewClient(
 		client.WithLog(log.Logger),
 	)
 	if err != nil {
@@ -55,7 +62,11 @@ func main() {
 		candidates.NewEventCandidatesScanner(
 			"flow.AccountContractUpdated",
 			func(event cadence.Event) (flow.Address, error) {
-				return flow.BytesToAddress(event.Fields[0].(cadence.Address).Bytes()), nil
+				updated, err := eventfinder.DecodeEvent[AccountContractUpdated](event)
+				if err != nil {
+					return flow.EmptyAddress, err
+				}
+				return flow.Address(updated.Address), nil
 			},
 			log.Logger,
 		),
@@ -77,17 +88,20 @@ func main() {
 		WithCandidateScanners(candidateScanners).
 		WithScriptResultHandler(scriptResultHandler).
 		WithBatchSize(batchSize).
-		WithChainID(flow.Testnet).
+		ForNetwork(preset).
 		WithLogger(log.Logger).
 		// This is new.
 		WithStatusReporter(reporter).
 		// This example uses a continuous scan, which means that it will keep scanning the chain for changes.
 		WithContinuousScan(true)
 
-	scan := scanner.NewScanner(
+	scan, err := scanner.NewScanner(
 		flowClient,
 		config,
 	)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid scanner config")
+	}
 
 	// Start the scanner. We don't need to wait for it to finish, because it will keep running.
 	// It is important to note, the while a full-scan is running (either because the scanner was just started,
@@ -50,7 +50,10 @@ func NewScriptResultHandler(reporter *Reporter, logger zerolog.Logger) fbs.Scrip
 }
 
 func (r *scriptResultHandler) Handle(batch fbs.ProcessedAddressBatch) error {
-	addressContracts := Parse(batch.Result)
+	addressContracts, err := Parse(batch.Result)
+	if err != nil {
+		return err
+	}
 
 	addressesWithNoContracts := make(map[flow.Address]struct{}, len(batch.Addresses))
 	for _, address := range batch.Addresses {
@@ -103,14 +106,23 @@ func (r *scriptResultHandler) Handle(batch fbs.ProcessedAddressBatch) error {
 	return nil
 }
 
-// Parse parses the script result which is the AccountInfo in get_contract_deployed.cdc struct in this case.
-func Parse(values cadence.Value) map[flow.Address]int64 {
-	result := make(map[flow.Address]int64)
-	for _, value := range values.(cadence.Array).Values {
-		s := value.(cadence.Struct)
-		address := flow.BytesToAddress(s.Fields[0].(cadence.Address).Bytes())
-		value := s.Fields[1].(cadence.Int).Int()
-		result[address] = int64(value)
+// AccountInfo is the AccountInfo struct of get_contract_deployed.cdc.
+type AccountInfo struct {
+	Address   flow.Address `cadence:"address"`
+	Contracts int64        `cadence:"contracts"`
+}
+
+// Parse parses the script result which is an array of the AccountInfo struct in get_contract_deployed.cdc in this case.
+// The result is decoded directly into Go structs, without a detour through JSON.
+func Parse(values cadence.Value) (map[flow.Address]int64, error) {
+	var infos []AccountInfo
+	err := fbs.DecodeValue(values, &infos)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[flow.Address]int64, len(infos))
+	for _, info := range infos {
+		result[info.Address] = info.Contracts
 	}
-	return result
+	return result, nil
 }
@@ -0,0 +1,169 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package executionstate enumerates the accounts of an execution state snapshot offline, without any RPCs.
+//
+// It reads the JSON Lines register export of a checkpoint, which the flow-go util writes with:
+//
+//	util export-json-execution-state --execution-state-dir <checkpoint dir> --output-dir <dir> --state-commitment <commit>
+//
+// Every account has an account status register, which holds the storage used by the account.
+// The accounts of the snapshot can be scanned with a full scan using AddressProvider.
+// For chain-wide scans, the snapshot can instead be the baseline of an incremental scan that starts
+// at the height of the snapshot, so the access nodes only serve the incremental scan:
+//
+//	accounts, err := executionstate.ReadAccounts(f)
+//	...
+//	err = executionstate.Baseline(accounts, height, batchSize, handler)
+//	s, err := scanner.NewScanner(flowClient, scanner.WithIncrementalOnly(height), scanner.WithScriptResultHandler(handler))
+package executionstate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/onflow/flow-go-sdk"
+)
+
+// AccountStatusKey is the key of the account status register, that every account has.
+const AccountStatusKey = "a.s"
+
+// the types of the parts of a register key.
+const (
+	keyPartOwner = 0
+	keyPartKey   = 2
+)
+
+// Register is a register of the execution state.
+// The owner of the registers of the service level, e.g. the UUID generator, is empty.
+type Register struct {
+	Owner []byte
+	Key   string
+	Value []byte
+}
+
+// Address is the address of the account owning the register, false for the registers of the service level.
+func (r Register) Address() (flow.Address, bool) {
+	if len(r.Owner) != flow.AddressLength {
+		return flow.EmptyAddress, false
+	}
+	return flow.BytesToAddress(r.Owner), true
+}
+
+// jsonPayload is a line of the register export, a JSON encoded ledger.Payload.
+type jsonPayload struct {
+	Key struct {
+		KeyParts []struct {
+			Type  uint16
+			Value string
+		}
+	}
+	Value string
+}
+
+// ReadRegisters calls fn for each register of the JSON Lines register export.
+func ReadRegisters(r io.Reader, fn func(register Register) error) error {
+	decoder := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var payload jsonPayload
+		err := decoder.Decode(&payload)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		var register Register
+		for _, part := range payload.Key.KeyParts {
+			value, err := hex.DecodeString(part.Value)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid key part: %w", line, err)
+			}
+			switch part.Type {
+			case keyPartOwner:
+				register.Owner = value
+			case keyPartKey:
+				register.Key = string(value)
+			}
+		}
+		register.Value, err = hex.DecodeString(payload.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid value: %w", line, err)
+		}
+		if err := fn(register); err != nil {
+			return err
+		}
+	}
+}
+
+// Account is an account of the execution state.
+type Account struct {
+	Address flow.Address
+	// StorageUsed is the storage used by the account in bytes, as stored in the account status register.
+	StorageUsed uint64
+}
+
+// ReadAccounts reads the accounts of the JSON Lines register export, ordered by address.
+func ReadAccounts(r io.Reader) ([]Account, error) {
+	var accounts []Account
+	err := ReadRegisters(r, func(register Register) error {
+		if register.Key != AccountStatusKey {
+			return nil
+		}
+		address, ok := register.Address()
+		if !ok {
+			return fmt.Errorf("invalid owner %x of an account status register", register.Owner)
+		}
+		storageUsed, err := storageUsed(register.Value)
+		if err != nil {
+			return fmt.Errorf("account %s: %w", address.Hex(), err)
+		}
+		accounts = append(accounts, Account{
+			Address:     address,
+			StorageUsed: storageUsed,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return bytes.Compare(accounts[i].Address[:], accounts[j].Address[:]) < 0
+	})
+	return accounts, nil
+}
+
+// storageUsed reads the storage used of an account status register,
+// which starts with a byte of flags followed by the big endian storage used.
+func storageUsed(status []byte) (uint64, error) {
+	if len(status) < 9 {
+		return 0, fmt.Errorf("account status register of %d bytes is too short", len(status))
+	}
+	return binary.BigEndian.Uint64(status[1:9]), nil
+}
+
+// Addresses are the addresses of the accounts.
+func Addresses(accounts []Account) []flow.Address {
+	addresses := make([]flow.Address, 0, len(accounts))
+	for _, account := range accounts {
+		addresses = append(addresses, account.Address)
+	}
+	return addresses
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executionstate_test
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/executionstate"
+)
+
+func payload(owner []byte, key string, value []byte) string {
+	return fmt.Sprintf(`{"Key":{"KeyParts":[{"Type":0,"Value":"%x"},{"Type":2,"Value":"%x"}]},"Value":"%s"}`,
+		owner, key, hex.EncodeToString(value))
+}
+
+func accountStatus(storageUsed uint64) []byte {
+	status := make([]byte, 33)
+	binary.BigEndian.PutUint64(status[1:9], storageUsed)
+	return status
+}
+
+type handlerFunc func(batch scanner.ProcessedAddressBatch) error
+
+func (f handlerFunc) Handle(batch scanner.ProcessedAddressBatch) error {
+	return f(batch)
+}
+
+func TestReadAccounts(t *testing.T) {
+	a1 := flow.HexToAddress("02")
+	a2 := flow.HexToAddress("01")
+	export := strings.Join([]string{
+		payload(nil, "uuid", []byte{1}),
+		payload(a1.Bytes(), executionstate.AccountStatusKey, accountStatus(300)),
+		payload(a1.Bytes(), "contract_names", []byte{0x80}),
+		payload(a2.Bytes(), executionstate.AccountStatusKey, accountStatus(100)),
+	}, "\n")
+
+	accounts, err := executionstate.ReadAccounts(strings.NewReader(export))
+	require.NoError(t, err)
+	require.Equal(t, []executionstate.Account{
+		{Address: a2, StorageUsed: 100},
+		{Address: a1, StorageUsed: 300},
+	}, accounts)
+
+	provider, err := executionstate.AddressProvider(accounts)(context.Background(), 20)
+	require.NoError(t, err)
+	require.Equal(t, uint(2), provider.AddressesLen())
+
+	var batches []scanner.ProcessedAddressBatch
+	err = executionstate.Baseline(accounts, 10, 1, handlerFunc(func(batch scanner.ProcessedAddressBatch) error {
+		batches = append(batches, batch)
+		return nil
+	}))
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+	require.Equal(t, []flow.Address{a1}, batches[1].Addresses)
+	require.Equal(t, uint64(10), batches[1].BlockHeight)
+	require.Equal(t, cadence.NewDictionary([]cadence.KeyValuePair{
+		{Key: cadence.Address(a1), Value: cadence.UInt64(300)},
+	}).WithType(cadence.NewDictionaryType(cadence.TheAddressType, cadence.TheUInt64Type)), batches[1].Result)
+
+	_, err = executionstate.ReadAccounts(strings.NewReader(payload(a1.Bytes(), executionstate.AccountStatusKey, []byte{0})))
+	require.ErrorContains(t, err, "too short")
+	_, err = executionstate.ReadAccounts(strings.NewReader(export + "\n{"))
+	require.ErrorContains(t, err, "line 5")
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executionstate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/onflow/cadence"
+
+	scanner "github.com/onflow/flow-batch-scan"
+)
+
+// storageUsedType is the type of the results of Baseline.
+var storageUsedType = cadence.NewDictionaryType(cadence.TheAddressType, cadence.TheUInt64Type)
+
+// AddressProvider makes a full scan scan the accounts of the snapshot, see scanner.WithAddressProvider.
+// The accounts created after the snapshot are not scanned, use an incremental scan starting at its height for them.
+func AddressProvider(
+	accounts []Account,
+) func(ctx context.Context, blockHeight uint64) (scanner.AddressProvider, error) {
+	return func(_ context.Context, _ uint64) (scanner.AddressProvider, error) {
+		return scanner.NewListAddressProvider(Addresses(accounts)), nil
+	}
+}
+
+// Baseline hands the storage used of the accounts to the handler, as batches of at most batchSize accounts
+// at the height of the snapshot, with a `{Address: UInt64}` dictionary as result.
+// Use it instead of a full scan, before an incremental scan starting at the height.
+func Baseline(
+	accounts []Account,
+	height uint64,
+	batchSize int,
+	handler scanner.ScriptResultHandler,
+) error {
+	if batchSize <= 0 {
+		return fmt.Errorf("the batch size must be positive, got %d", batchSize)
+	}
+	for start := 0; start < len(accounts); start += batchSize {
+		end := start + batchSize
+		if end > len(accounts) {
+			end = len(accounts)
+		}
+		batch := accounts[start:end]
+
+		pairs := make([]cadence.KeyValuePair, 0, len(batch))
+		for _, account := range batch {
+			pairs = append(pairs, cadence.KeyValuePair{
+				Key:   cadence.Address(account.Address),
+				Value: cadence.UInt64(account.StorageUsed),
+			})
+		}
+		err := handler.Handle(scanner.ProcessedAddressBatch{
+			AddressBatch: scanner.AddressBatch{
+				Addresses:   Addresses(batch),
+				BlockHeight: height,
+			},
+			Result: cadence.NewDictionary(pairs).WithType(storageUsedType),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to handle the baseline: %w", err)
+		}
+	}
+	return nil
+}
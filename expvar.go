@@ -0,0 +1,75 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+)
+
+// ExpvarName is the name the ScanVars are published under with expvar.
+const ExpvarName = "flow_batch_scan"
+
+// ScanVars are the core counters of the running scan, published with expvar
+// and served on /debug/vars of the profiling server.
+type ScanVars struct {
+	BatchesScanned     uint64 `json:"batches_scanned"`
+	AddressesScanned   uint64 `json:"addresses_scanned"`
+	CandidatesFound    uint64 `json:"candidates_found"`
+	ScriptErrors       uint64 `json:"script_errors"`
+	RPCRetries         uint64 `json:"rpc_retries"`
+	FailedBatches      int    `json:"failed_batches"`
+	HandlerErrors      uint64 `json:"handler_errors"`
+	LatestHandledBlock uint64 `json:"latest_handled_block"`
+}
+
+var (
+	publishScanVarsOnce sync.Once
+	currentScanVars     atomic.Pointer[func() ScanVars]
+)
+
+// publishScanVars publishes the ScanVars returned by vars under ExpvarName.
+// expvar names can only be published once per process,
+// so a later scan replaces the vars of an earlier one.
+func publishScanVars(vars func() ScanVars) {
+	currentScanVars.Store(&vars)
+	publishScanVarsOnce.Do(func() {
+		expvar.Publish(ExpvarName, expvar.Func(func() any {
+			vars := currentScanVars.Load()
+			if vars == nil {
+				return nil
+			}
+			return (*vars)()
+		}))
+	})
+}
+
+func newScanVars(stats ScanStats, latestHandledBlock uint64) ScanVars {
+	candidates := uint64(0)
+	for _, count := range stats.Candidates {
+		candidates += count
+	}
+	return ScanVars{
+		BatchesScanned:     stats.BatchesExecuted,
+		AddressesScanned:   stats.AddressesScanned,
+		CandidatesFound:    candidates,
+		ScriptErrors:       stats.ScriptErrors,
+		RPCRetries:         stats.Retries,
+		FailedBatches:      len(stats.FailedBatches),
+		HandlerErrors:      stats.HandlerErrors,
+		LatestHandledBlock: latestHandledBlock,
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/onflow/flow-go-sdk"
+)
+
+// FailedBatchHandler receives the batches that could not be scanned, after all retries were exhausted.
+// If a FailedBatchHandler is configured the scan continues without the failed batches,
+// instead of stopping with the error.
+type FailedBatchHandler interface {
+	HandleFailedBatch(batch FailedBatch) error
+}
+
+// FileFailedBatchHandler appends the failed batches to a file, one JSON object per line.
+// The failed batches can be read with ReadFailedBatches, to replay them later
+// (e.g. with StaticAddresses).
+type FileFailedBatchHandler struct {
+	path string
+	mu   sync.Mutex
+}
+
+var _ FailedBatchHandler = (*FileFailedBatchHandler)(nil)
+
+func NewFileFailedBatchHandler(path string) *FileFailedBatchHandler {
+	return &FileFailedBatchHandler{
+		path: path,
+	}
+}
+
+type failedBatchRecord struct {
+	Addresses   []flow.Address `json:"addresses"`
+	BlockHeight uint64         `json:"block_height"`
+	ScriptName  string         `json:"script_name,omitempty"`
+	Error       string         `json:"error"`
+}
+
+func newFailedBatchRecord(batch FailedBatch) failedBatchRecord {
+	record := failedBatchRecord{
+		Addresses:   batch.Addresses,
+		BlockHeight: batch.BlockHeight,
+		ScriptName:  batch.ScriptName,
+	}
+	if batch.Err != nil {
+		record.Error = batch.Err.Error()
+	}
+	return record
+}
+
+func (r failedBatchRecord) failedBatch() FailedBatch {
+	batch := FailedBatch{
+		Addresses:   r.Addresses,
+		BlockHeight: r.BlockHeight,
+		ScriptName:  r.ScriptName,
+	}
+	if r.Error != "" {
+		batch.Err = errors.New(r.Error)
+	}
+	return batch
+}
+
+func (h *FileFailedBatchHandler) HandleFailedBatch(batch FailedBatch) error {
+	data, err := json.Marshal(newFailedBatchRecord(batch))
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadFailedBatches reads the failed batches written by a FileFailedBatchHandler.
+// It returns no batches if the file does not exist.
+func ReadFailedBatches(path string) ([]FailedBatch, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var batches []FailedBatch
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record failedBatchRecord
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return nil, fmt.Errorf("invalid failed batch in %s: %w", path, err)
+		}
+		batches = append(batches, record.failedBatch())
+	}
+	return batches, scanner.Err()
+}
+
+// WriteFailedBatches writes the failed batches as JSON lines, in the format of a FileFailedBatchHandler,
+// so they can be read with ReadFailedBatches.
+func WriteFailedBatches(w io.Writer, batches []FailedBatch) error {
+	encoder := json.NewEncoder(w)
+	for _, batch := range batches {
+		err := encoder.Encode(newFailedBatchRecord(batch))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FailedAddresses returns the addresses of all the failed batches.
+func FailedAddresses(batches []FailedBatch) []flow.Address {
+	var addresses []flow.Address
+	for _, batch := range batches {
+		addresses = append(addresses, batch.Addresses...)
+	}
+	return addresses
+}
@@ -0,0 +1,53 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileFailedBatchHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failed.jsonl")
+
+	batches, err := ReadFailedBatches(path)
+	require.NoError(t, err)
+	require.Empty(t, batches)
+
+	handler := NewFileFailedBatchHandler(path)
+	require.NoError(t, handler.HandleFailedBatch(FailedBatch{
+		Addresses:   []flow.Address{flow.HexToAddress("01"), flow.HexToAddress("02")},
+		BlockHeight: 10,
+		Err:         fmt.Errorf("failed"),
+	}))
+	require.NoError(t, handler.HandleFailedBatch(FailedBatch{
+		Addresses:   []flow.Address{flow.HexToAddress("03")},
+		BlockHeight: 11,
+	}))
+
+	batches, err = ReadFailedBatches(path)
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+	require.Equal(t, uint64(10), batches[0].BlockHeight)
+	require.EqualError(t, batches[0].Err, "failed")
+	require.NoError(t, batches[1].Err)
+	require.Equal(t,
+		[]flow.Address{flow.HexToAddress("01"), flow.HexToAddress("02"), flow.HexToAddress("03")},
+		FailedAddresses(batches))
+}
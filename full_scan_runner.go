@@ -24,16 +24,63 @@ import (
 
 	"github.com/onflow/flow-go-sdk"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/onflow/flow-batch-scan/client"
 )
 
 const FullScanReferenceBlockSwitch = 30 * time.Second
 
+// DefaultFullScanProgressLogInterval is how often the progress of a full scan is logged.
+const DefaultFullScanProgressLogInterval = time.Minute
+
 type FullScanRunnerConfig struct {
 	AddressProviderConfig
 
 	ChainID flow.ChainID
+
+	// NewAddressProvider creates the AddressProvider for a full scan at the given block height.
+	// If nil, all the addresses on the chain are scanned.
+	NewAddressProvider func(ctx context.Context, blockHeight uint64) (AddressProvider, error)
+
+	// CheckpointStore if set, the progress of full scans is saved to it every FullScanCheckpointInterval,
+	// and a scan that is restarted continues the full scan from the stored checkpoint.
+	CheckpointStore            CheckpointStore
+	FullScanCheckpointInterval time.Duration
+
+	// FullScanInterval and FullScanBlockInterval if set, start a new full scan in continuous mode
+	// when the given time has passed or the given number of blocks were sealed since the last full scan started.
+	// This corrects any drift from candidates the incremental scanner missed.
+	// A running full scan is never interrupted by this, the next one starts when it is done.
+	FullScanInterval      time.Duration
+	FullScanBlockInterval uint64
+
+	// SampleRate if between 0 and 1, full scans only scan this fraction of the addresses,
+	// spread evenly over all the addresses. This can be used to cheaply estimate the results of a full scan.
+	// The incremental scanner still scans all candidates.
+	SampleRate float64
+
+	// Partition if partitioned, makes full scans only scan the addresses of this partition.
+	// See Config.WithPartitions.
+	Partition Partition
+
+	// ReferenceBlockHeight if set, makes the scan a point in time scan: one full scan runs all scripts
+	// at this (historical) block height, and the incremental scanner does not run.
+	// The access node needs to have the state of the block height.
+	ReferenceBlockHeight uint64
+
+	// MaxAddresses and MaxBatches if set, stop full scans after the given number of addresses or batches.
+	// This is meant for development runs. A full scan that stopped early is not reported as complete.
+	MaxAddresses uint
+	MaxBatches   uint
+
+	// FullScanThrottle if set, slows the full scan down while the access node is under pressure,
+	// so the incremental scanner and the other users of the node are not starved. See Config.WithFullScanThrottle.
+	FullScanThrottle *FullScanThrottleConfig
+
+	// FullScanProgressLogInterval is how often the progress, throughput and ETA of a running full scan are logged.
+	FullScanProgressLogInterval time.Duration
 }
 
 func DefaultFullScanRunnerConfig() FullScanRunnerConfig {
@@ -41,6 +88,9 @@ func DefaultFullScanRunnerConfig() FullScanRunnerConfig {
 		AddressProviderConfig: DefaultAddressProviderConfig(),
 
 		ChainID: flow.Testnet,
+
+		FullScanCheckpointInterval:  DefaultFullScanCheckpointInterval,
+		FullScanProgressLogInterval: DefaultFullScanProgressLogInterval,
 	}
 }
 
@@ -51,8 +101,32 @@ type FullScanRunner struct {
 
 	FullScanRunnerConfig
 
+	// incrementalHeight is the latest block height handled by the incremental scanner.
+	// It is saved with the checkpoints.
+	incrementalHeight func() uint64
+	// scriptClient if set, is used instead of client to execute the scripts of the address provider.
+	scriptClient client.Client
+	// addressFilter removes addresses before they are batched.
+	addressFilter *addressFilter
+	hooks         ScanHooks
+	stats         *statsCollector
+	tracer        trace.Tracer
+	// batchSizeController adapts the batch size, if the batch size is adaptive.
+	batchSizeController *batchSizeController
+	// throttle delays the batches while the access node is under pressure.
+	throttle *fullScanThrottle
+	// audit if set, records the addresses of the full scan for the CompletenessAudit.
+	audit *completenessAuditor
+
 	logger   zerolog.Logger
 	reporter StatusReporter
+
+	// latest is the full scan that was created last.
+	latest atomic.Pointer[FullScan]
+
+	// accountCounter counts the accounts of every full scan, starting from the count of the previous full scan.
+	accountCounter     AccountCounter
+	accountCounterOnce sync.Once
 }
 
 func NewFullScanRunner(
@@ -73,14 +147,43 @@ func NewFullScanRunner(
 	}
 }
 
+func (r *FullScanRunner) scriptExecutionClient() client.Client {
+	if r.scriptClient != nil {
+		return r.scriptClient
+	}
+	return r.client
+}
+
+func (r *FullScanRunner) countAccounts(ctx context.Context, blockHeight uint64) (uint, error) {
+	r.accountCounterOnce.Do(func() {
+		r.accountCounter = BisectAccountCounter(r.ChainID, r.scriptExecutionClient(), r.logger)
+	})
+	return r.accountCounter(ctx, blockHeight)
+}
+
 func (r *FullScanRunner) NewBatch(
 	blockHeight uint64,
+) *FullScan {
+	batch := r.NewBatchFrom(blockHeight, 0)
+	batch.resume = false
+	return batch
+}
+
+// NewBatchFrom creates a full scan that starts at the address with the given index.
+// It is used to resume a full scan from a checkpoint.
+func (r *FullScanRunner) NewBatchFrom(
+	blockHeight uint64,
+	startIndex uint,
 ) *FullScan {
 	batch := &FullScan{
 		runner: r,
 
 		blockHeight:              blockHeight,
+		startIndex:               startIndex,
+		resume:                   true,
 		lastReferenceBlockSwitch: time.Now(),
+
+		startHeight: blockHeight,
 	}
 
 	batch.ComponentBase = NewComponentWithStart(
@@ -88,17 +191,95 @@ func (r *FullScanRunner) NewBatch(
 		func(ctx context.Context) { go batch.run(ctx) },
 		r.logger,
 	)
+	r.latest.Store(batch)
 
 	return batch
 }
 
+// running is the full scan that is currently running, if there is one.
+func (r *FullScanRunner) running() *FullScan {
+	fullScan := r.latest.Load()
+	if fullScan == nil {
+		return nil
+	}
+	select {
+	case <-fullScan.Done():
+		return nil
+	default:
+		return fullScan
+	}
+}
+
 type FullScan struct {
 	*ComponentBase
 
 	runner *FullScanRunner
 
-	blockHeight              uint64
+	blockHeight uint64
+	// blockID is the ID of the block at blockHeight, it is carried by the batches.
+	blockID flow.Identifier
+
+	startIndex               uint
+	resume                   bool
 	lastReferenceBlockSwitch time.Time
+	stoppedAtLimit           atomic.Bool
+	// span is the span of the whole full scan. The spans of the batches are its children.
+	span trace.Span
+
+	// used for the FullScanStats
+	startHeight      uint64
+	startTime        time.Time
+	handledAddresses atomic.Uint64
+	handledBatches   atomic.Uint64
+
+	progressMu sync.Mutex
+	progress   FullScanProgress
+
+	// checkpoints track the done batches, once the addresses are enumerated.
+	checkpoints atomic.Pointer[checkpointTracker]
+}
+
+// FullScanProgress is the progress of a running full scan.
+type FullScanProgress struct {
+	// Scanned is the number of addresses that were scanned,
+	// and Total the estimated number of addresses of the full scan.
+	Scanned uint64 `json:"scanned"`
+	Total   uint64 `json:"total"`
+	// AddressesPerSecond is the throughput since the full scan started.
+	AddressesPerSecond float64 `json:"addresses_per_second"`
+	// ETA is the estimated time until the full scan is done, at the current throughput.
+	// It is 0 until the first addresses were scanned.
+	ETA time.Duration `json:"eta"`
+}
+
+func newFullScanProgress(scanned uint64, total uint64, elapsed time.Duration) FullScanProgress {
+	p := FullScanProgress{
+		Scanned: scanned,
+		Total:   total,
+	}
+	if scanned == 0 || elapsed <= 0 {
+		return p
+	}
+	p.AddressesPerSecond = float64(scanned) / elapsed.Seconds()
+	if total > scanned {
+		p.ETA = time.Duration(float64(total-scanned) / p.AddressesPerSecond * float64(time.Second))
+	}
+	return p
+}
+
+// Fraction is the scanned fraction of the addresses, from 0 to 1.
+func (p FullScanProgress) Fraction() float64 {
+	if p.Total == 0 {
+		return 0
+	}
+	return float64(p.Scanned) / float64(p.Total)
+}
+
+// Progress is the current progress of the full scan.
+func (r *FullScan) Progress() FullScanProgress {
+	r.progressMu.Lock()
+	defer r.progressMu.Unlock()
+	return r.progress
 }
 
 var _ Component = &FullScan{}
@@ -109,26 +290,58 @@ func (r *FullScan) finish(wg *sync.WaitGroup, err error) {
 			// wait for all outstanding batches to finish
 			wg.Wait()
 		}
+		if err == nil && r.runner.CheckpointStore != nil {
+			err = r.runner.CheckpointStore.ClearCheckpoint(context.Background())
+		}
+		r.runner.stats.fullScanFinished(time.Since(r.startTime), err == nil)
+		span := r.span
+		span.SetAttributes(
+			attribute.Int64("addresses", int64(r.handledAddresses.Load())),
+			attribute.Int64("batches", int64(r.handledBatches.Load())),
+		)
+		endSpan(span, err)
+		if err == nil {
+			r.runner.hooks.fullScanCompleted(FullScanStats{
+				ReferenceBlockHeight: r.startHeight,
+				Addresses:            r.handledAddresses.Load(),
+				Batches:              r.handledBatches.Load(),
+				Duration:             time.Since(r.startTime),
+				StoppedAtLimit:       r.StoppedAtLimit(),
+			})
+		}
 		r.ComponentBase.Finish(err)
 	}()
 }
 
 func (r *FullScan) run(ctx context.Context) {
-	ap, err := InitAddressProvider(
-		ctx,
-		r.runner.ChainID,
-		r.blockHeight,
-		r.runner.client,
-		r.runner.AddressProviderConfig,
-		r.Logger,
+	r.startTime = time.Now()
+	ctx, r.span = startSpan(ctx, r.runner.tracer, "full_scan",
+		attribute.Int64("reference_block_height", int64(r.startHeight)),
 	)
+	r.runner.hooks.fullScanStarted(r.startHeight)
+	r.runner.stats.fullScanStarted()
+
+	ap, err := r.newAddressProvider(ctx)
 	if err != nil {
 		r.finish(nil, err)
 		return
 	}
 
+	r.runner.audit.fullScanStarted(r.startHeight)
+	r.fetchBlockID(ctx)
+
+	total := uint64(ap.AddressesLen())
+	if r.resume {
+		r.Logger.Info().
+			Uint("start_index", r.startIndex).
+			Msg("Resuming full scan")
+		total = skipAddresses(ap, r.startIndex, total)
+	}
+	checkpoints := newCheckpointTracker(ap.CurrentIndex())
+	r.checkpoints.Store(checkpoints)
+
 	progressChan := make(chan uint64)
-	go r.reportProgress(uint64(ap.AddressesLen()), progressChan)
+	go r.reportProgress(total, progressChan)
 
 	batchWG := &sync.WaitGroup{}
 	cancelled := atomic.Bool{}
@@ -136,11 +349,21 @@ func (r *FullScan) run(ctx context.Context) {
 		return !cancelled.Load()
 	}
 
-	addressChan := make(chan []flow.Address)
-	blockSwitchTimeChan := time.After(FullScanReferenceBlockSwitch)
+	addressChan := make(chan fullScanBatch)
+	var blockSwitchTimeChan <-chan time.Time
+	if r.runner.ReferenceBlockHeight == 0 {
+		blockSwitchTimeChan = time.After(FullScanReferenceBlockSwitch)
+	}
+	var checkpointTimeChan <-chan time.Time
+	if r.runner.CheckpointStore != nil {
+		checkpointTimeChan = time.After(r.runner.FullScanCheckpointInterval)
+	}
 	go func() {
 		for {
 			select {
+			case <-checkpointTimeChan:
+				r.saveCheckpoint(ctx, checkpoints)
+				checkpointTimeChan = time.After(r.runner.FullScanCheckpointInterval)
 			case <-ctx.Done():
 				cancelled.Store(true)
 				r.finish(batchWG, ctx.Err())
@@ -152,54 +375,262 @@ func (r *FullScan) run(ctx context.Context) {
 					return
 				}
 				blockSwitchTimeChan = time.After(FullScanReferenceBlockSwitch)
-			case addresses, ok := <-addressChan:
+			case batch, ok := <-addressChan:
 				if !ok {
 					r.finish(batchWG, nil)
 					return
 				}
 
 				batchWG.Add(1)
-				r.runner.addressBatchChan <- NewAddressBatch(
-					addresses,
+				id := checkpoints.add(batch.nextIndex)
+				r.runner.audit.enumerated(batch.addresses)
+				addressBatch := NewAddressBatch(
+					batch.addresses,
 					r.blockHeight,
 					func() {
-						progressChan <- uint64(len(addresses))
+						progressChan <- uint64(batch.consumed)
+						r.handledAddresses.Add(uint64(len(batch.addresses)))
+						r.handledBatches.Add(1)
+						checkpoints.markDone(id)
 						batchWG.Done()
 					},
 					isBatchValid,
 				)
+				addressBatch.spanContext = trace.SpanContextFromContext(ctx)
+				addressBatch.blockID = r.blockID
+				addressBatch.fromFullScan = true
+				r.runner.addressBatchChan <- addressBatch
+				r.Heartbeat()
 			}
 		}
 	}()
 
 	go func() {
-		ap.GenerateAddressBatches(addressChan, r.runner.batchSize)
+		var done bool
+		index := ap.CurrentIndex()
+		sentAddresses := uint(0)
+		sentBatches := uint(0)
+		for !done {
+			var addresses []flow.Address
+			addresses, done = ap.NextBatch(r.runner.batchSizeController.size(r.runner.batchSize))
+			maxAddresses := r.runner.MaxAddresses
+			if maxAddresses > 0 && sentAddresses+uint(len(addresses)) > maxAddresses {
+				addresses = addresses[:maxAddresses-sentAddresses]
+				done = true
+				r.stopAtLimit()
+			}
+			if len(addresses) > 0 {
+				if !r.runner.throttle.wait(ctx) {
+					// the full scan is finished with the error of the context
+					return
+				}
+				addressChan <- fullScanBatch{
+					addresses: addresses,
+					nextIndex: ap.CurrentIndex(),
+					consumed:  ap.CurrentIndex() - index,
+				}
+				index = ap.CurrentIndex()
+				sentAddresses += uint(len(addresses))
+				sentBatches++
+			}
+			if !done && r.limitReached(sentAddresses, sentBatches) {
+				done = true
+				r.stopAtLimit()
+			}
+		}
 		close(addressChan)
 	}()
 }
 
+func (r *FullScan) limitReached(sentAddresses uint, sentBatches uint) bool {
+	return (r.runner.MaxAddresses > 0 && sentAddresses >= r.runner.MaxAddresses) ||
+		(r.runner.MaxBatches > 0 && sentBatches >= r.runner.MaxBatches)
+}
+
+func (r *FullScan) stopAtLimit() {
+	r.Logger.Info().
+		Uint("max_addresses", r.runner.MaxAddresses).
+		Uint("max_batches", r.runner.MaxBatches).
+		Msg("Full scan stopped at limit")
+	r.stoppedAtLimit.Store(true)
+}
+
+// StoppedAtLimit is true if the full scan stopped early because of MaxAddresses or MaxBatches.
+func (r *FullScan) StoppedAtLimit() bool {
+	return r.stoppedAtLimit.Load()
+}
+
+func (r *FullScan) newAddressProvider(ctx context.Context) (AddressProvider, error) {
+	var ap AddressProvider
+	var err error
+	if r.runner.NewAddressProvider != nil {
+		ap, err = r.runner.NewAddressProvider(ctx, r.blockHeight)
+	} else {
+		// the addresses are enumerated locally, only the number of accounts is queried
+		ap, err = GeneratorAddresses(r.runner.ChainID, r.runner.countAccounts, r.runner.AddressProviderConfig)(ctx, r.blockHeight)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.runner.addressFilter != nil {
+		ap = &filteredAddressProvider{
+			AddressProvider: ap,
+			keep:            r.runner.addressFilter.allows,
+		}
+	}
+	if partition := r.runner.Partition; partition.IsPartitioned() {
+		r.Logger.Info().
+			Stringer("partition", partition).
+			Msg("Only scanning the addresses of the partition")
+		ap = &filteredAddressProvider{
+			AddressProvider: ap,
+			keep:            partition.Contains,
+		}
+	}
+	if r.runner.SampleRate > 0 && r.runner.SampleRate < 1 {
+		r.Logger.Info().
+			Float64("sample_rate", r.runner.SampleRate).
+			Msg("Only scanning a sample of the addresses")
+		ap = &filteredAddressProvider{
+			AddressProvider: ap,
+			keep:            newAddressSampler(r.runner.SampleRate),
+		}
+	}
+	return ap, nil
+}
+
+type fullScanBatch struct {
+	addresses []flow.Address
+	// nextIndex is the index of the first address after the batch.
+	nextIndex uint
+	// consumed is how many addresses the provider went through for the batch,
+	// including the filtered out addresses. It is used to report progress.
+	consumed uint
+}
+
+// checkpoint is the current checkpoint of the full scan.
+// It returns false if the checkpoint could not be resumed.
+func (r *FullScan) checkpoint(checkpoints *checkpointTracker) (FullScanCheckpoint, bool) {
+	if r.runner.incrementalHeight == nil {
+		return FullScanCheckpoint{}, false
+	}
+	incrementalHeight := r.runner.incrementalHeight()
+	if incrementalHeight == 0 {
+		// the incremental scanner has not handled any blocks yet, so the checkpoint could not be resumed
+		return FullScanCheckpoint{}, false
+	}
+	return FullScanCheckpoint{
+		AddressIndex:      checkpoints.addressIndex(),
+		IncrementalHeight: incrementalHeight,
+	}, true
+}
+
+// checkpoint is the checkpoint of the latest full scan, if it was not completed.
+func (r *FullScanRunner) checkpoint() (FullScanCheckpoint, bool) {
+	fullScan := r.latest.Load()
+	if fullScan == nil {
+		return FullScanCheckpoint{}, false
+	}
+	select {
+	case <-fullScan.Done():
+		if fullScan.Err() == nil {
+			return FullScanCheckpoint{}, false
+		}
+	default:
+	}
+	checkpoints := fullScan.checkpoints.Load()
+	if checkpoints == nil {
+		return FullScanCheckpoint{}, false
+	}
+	return fullScan.checkpoint(checkpoints)
+}
+
+// saveCheckpoint saves the progress of the full scan.
+// Failing to save is not fatal, the checkpoint is saved again later.
+func (r *FullScan) saveCheckpoint(ctx context.Context, checkpoints *checkpointTracker) {
+	checkpoint, ok := r.checkpoint(checkpoints)
+	if !ok {
+		return
+	}
+	err := r.runner.CheckpointStore.SaveCheckpoint(ctx, checkpoint)
+	if err != nil {
+		r.Logger.Warn().
+			Err(err).
+			Msg("could not save full scan checkpoint")
+		return
+	}
+	r.Logger.Debug().
+		Uint("address_index", checkpoint.AddressIndex).
+		Uint64("incremental_height", checkpoint.IncrementalHeight).
+		Msg("saved full scan checkpoint")
+}
+
+// skipAddresses skips the addresses before index to resume a full scan, and returns the remaining total.
+// The addresses are only skipped forward, an index at or before the current index of the provider
+// (e.g. of a checkpoint at the start of the scan) does not skip any.
+func skipAddresses(ap AddressProvider, index uint, total uint64) uint64 {
+	current := ap.CurrentIndex()
+	if index <= current {
+		return total
+	}
+	ap.SkipTo(index)
+	skipped := uint64(index - current)
+	if skipped >= total {
+		return 0
+	}
+	return total - skipped
+}
+
 func (r *FullScan) reportProgress(addresses uint64, progressChan <-chan uint64) {
 	total := addresses
 	current := uint64(0)
 	segment := uint64(0)
 	segments := uint64(10)
-	for progress := range progressChan {
-		current += progress
+	start := time.Now()
 
-		if r.runner.reporter != nil {
-			r.runner.reporter.ReportFullScanProgress(current, total)
-		}
+	var logTicker <-chan time.Time
+	if r.runner.FullScanProgressLogInterval > 0 {
+		ticker := time.NewTicker(r.runner.FullScanProgressLogInterval)
+		defer ticker.Stop()
+		logTicker = ticker.C
+	}
+
+	for {
+		select {
+		case <-r.Done():
+			return
+		case <-logTicker:
+			r.logProgress(r.Progress(), "Full scan progress")
+		case p := <-progressChan:
+			current += p
+			progress := newFullScanProgress(current, total, time.Since(start))
+			r.progressMu.Lock()
+			r.progress = progress
+			r.progressMu.Unlock()
+
+			if r.runner.reporter != nil {
+				r.runner.reporter.ReportFullScanProgress(current, total)
+				r.runner.reporter.ReportFullScanThroughput(progress.AddressesPerSecond, progress.ETA)
+			}
 
-		if current > (total/segments)*(segment+1) {
-			r.Logger.Info().
-				Uint64("current", current).
-				Uint64("total", total).
-				Msgf("Batch progress: %d%%", (100/segments)*(segment+1))
-			segment += 1
+			if current > (total/segments)*(segment+1) {
+				r.logProgress(progress, fmt.Sprintf("Batch progress: %d%%", (100/segments)*(segment+1)))
+				segment += 1
+			}
 		}
 	}
 }
 
+func (r *FullScan) logProgress(progress FullScanProgress, msg string) {
+	r.Logger.Info().
+		Uint64("current", progress.Scanned).
+		Uint64("total", progress.Total).
+		Float64("percent", 100*progress.Fraction()).
+		Float64("addresses_per_second", progress.AddressesPerSecond).
+		Dur("eta", progress.ETA).
+		Msg(msg)
+}
+
 // referenceBlockSwitch switches the reference block height to the current block height,
 // to avoid "state commitment not found" errors.
 func (r *FullScan) referenceBlockSwitch(ctx context.Context) error {
@@ -217,5 +648,21 @@ func (r *FullScan) referenceBlockSwitch(ctx context.Context) error {
 		Uint64("height", currentBlockHeader.Height).
 		Msg("switch to new block height")
 	r.blockHeight = currentBlockHeader.Height
+	r.blockID = currentBlockHeader.ID
 	return nil
 }
+
+// fetchBlockID fetches the ID of the reference block once, instead of once per batch.
+// If it fails, the ScriptRunner fetches it for each batch.
+func (r *FullScan) fetchBlockID(ctx context.Context) {
+	header, err := r.runner.client.GetBlockHeaderByHeight(ctx, r.blockHeight)
+	if err != nil {
+		r.Logger.
+			Debug().
+			Err(err).
+			Uint64("block_height", r.blockHeight).
+			Msg("failed to get the ID of the reference block")
+		return
+	}
+	r.blockID = header.ID
+}
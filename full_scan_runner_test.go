@@ -0,0 +1,136 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+)
+
+func TestFullScanProgress(t *testing.T) {
+	progress := newFullScanProgress(0, 1000, time.Second)
+	require.Equal(t, float64(0), progress.AddressesPerSecond)
+	require.Equal(t, time.Duration(0), progress.ETA)
+
+	progress = newFullScanProgress(250, 1000, 10*time.Second)
+	require.Equal(t, 0.25, progress.Fraction())
+	require.Equal(t, float64(25), progress.AddressesPerSecond)
+	require.Equal(t, 30*time.Second, progress.ETA)
+
+	// the total is an estimate, so more addresses than the total can be scanned
+	progress = newFullScanProgress(1100, 1000, 10*time.Second)
+	require.Equal(t, time.Duration(0), progress.ETA)
+}
+
+func TestSkipAddresses(t *testing.T) {
+	addresses := []flow.Address{
+		flow.HexToAddress("0x1"),
+		flow.HexToAddress("0x2"),
+		flow.HexToAddress("0x3"),
+		flow.HexToAddress("0x4"),
+	}
+
+	t.Run("skips forward", func(t *testing.T) {
+		ap := NewListAddressProvider(addresses)
+		require.Equal(t, uint64(1), skipAddresses(ap, 3, 4))
+		require.Equal(t, uint(3), ap.CurrentIndex())
+	})
+
+	t.Run("an index before the current index does not underflow", func(t *testing.T) {
+		ap := NewListAddressProvider(addresses)
+		ap.SkipTo(2)
+		require.Equal(t, uint64(2), skipAddresses(ap, 0, 2))
+		require.Equal(t, uint(2), ap.CurrentIndex())
+	})
+
+	t.Run("an index past the total leaves nothing", func(t *testing.T) {
+		ap := NewListAddressProvider(addresses)
+		require.Equal(t, uint64(0), skipAddresses(ap, 10, 4))
+	})
+}
+
+// runFullScan runs a full scan at block height 10 over the addresses, and returns the scanned batches.
+func runFullScan(t *testing.T, addresses []flow.Address, batchSize int, config FullScanRunnerConfig) (*FullScan, [][]flow.Address) {
+	config.NewAddressProvider = func(context.Context, uint64) (AddressProvider, error) {
+		return NewListAddressProvider(addresses), nil
+	}
+	batches := make(chan AddressBatch)
+	runner := NewFullScanRunner(clienttest.New(), batches, batchSize, config, NoOpStatusReporter{}, zerolog.Nop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fullScan := runner.NewBatch(10)
+	<-fullScan.Start(ctx)
+
+	var scanned [][]flow.Address
+	for {
+		select {
+		case batch := <-batches:
+			scanned = append(scanned, batch.Addresses)
+			batch.DoneHandling()
+		case <-fullScan.Done():
+			require.NoError(t, fullScan.Err())
+			return fullScan, scanned
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "the full scan did not finish")
+		}
+	}
+}
+
+func TestFullScan_Limits(t *testing.T) {
+	var addresses []flow.Address
+	for i := 1; i <= 10; i++ {
+		addresses = append(addresses, flow.HexToAddress(fmt.Sprintf("0x%x", i)))
+	}
+
+	t.Run("no limit", func(t *testing.T) {
+		fullScan, scanned := runFullScan(t, addresses, 3, DefaultFullScanRunnerConfig())
+		require.Len(t, scanned, 4)
+		require.False(t, fullScan.StoppedAtLimit())
+	})
+
+	t.Run("max addresses", func(t *testing.T) {
+		config := DefaultFullScanRunnerConfig()
+		config.MaxAddresses = 5
+		fullScan, scanned := runFullScan(t, addresses, 3, config)
+		// the last batch is cut at the limit
+		require.Equal(t, [][]flow.Address{addresses[0:3], addresses[3:5]}, scanned)
+		require.True(t, fullScan.StoppedAtLimit())
+	})
+
+	t.Run("max batches", func(t *testing.T) {
+		config := DefaultFullScanRunnerConfig()
+		config.MaxBatches = 2
+		fullScan, scanned := runFullScan(t, addresses, 3, config)
+		require.Equal(t, [][]flow.Address{addresses[0:3], addresses[3:6]}, scanned)
+		require.True(t, fullScan.StoppedAtLimit())
+	})
+
+	t.Run("limit at the end of the addresses", func(t *testing.T) {
+		config := DefaultFullScanRunnerConfig()
+		config.MaxAddresses = 10
+		fullScan, scanned := runFullScan(t, addresses, 5, config)
+		require.Len(t, scanned, 2)
+		require.False(t, fullScan.StoppedAtLimit())
+	})
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FullScanThrottleConfig configures the throttling of the full scan, see Config.WithFullScanThrottle.
+// Every Interval the access node is considered unhealthy, if the average script latency was above TargetLatency,
+// or more than MaxErrorRate of the scripts failed because of the node (rate limits, timeouts, unavailable).
+// While the node is unhealthy the delay between the full scan batches doubles, starting at InitialDelay up to MaxDelay,
+// and once the node is healthy again it halves, until there is no delay.
+type FullScanThrottleConfig struct {
+	TargetLatency time.Duration
+	MaxErrorRate  float64
+	InitialDelay  time.Duration
+	MaxDelay      time.Duration
+	Interval      time.Duration
+}
+
+func DefaultFullScanThrottleConfig() FullScanThrottleConfig {
+	return FullScanThrottleConfig{
+		TargetLatency: 5 * time.Second,
+		MaxErrorRate:  0.05,
+		InitialDelay:  100 * time.Millisecond,
+		MaxDelay:      10 * time.Second,
+		Interval:      10 * time.Second,
+	}
+}
+
+// FullScanThrottleState is the state of the full scan throttle.
+type FullScanThrottleState struct {
+	// Delay is the current delay between the full scan batches.
+	Delay time.Duration
+	// Latency and ErrorRate are the average script latency and the node error rate of the last interval.
+	Latency   time.Duration
+	ErrorRate float64
+}
+
+// fullScanThrottle slows the full scan down while the access node is under pressure.
+// The scripts of the incremental scanner are observed as well, they are slowed down by the same node.
+// A nil fullScanThrottle does not throttle.
+type fullScanThrottle struct {
+	config   FullScanThrottleConfig
+	reporter StatusReporter
+
+	mu          sync.Mutex
+	state       FullScanThrottleState
+	windowStart time.Time
+	scripts     int
+	errors      int
+	latency     time.Duration
+}
+
+func newFullScanThrottle(config *FullScanThrottleConfig, reporter StatusReporter) *fullScanThrottle {
+	if config == nil {
+		return nil
+	}
+	return &fullScanThrottle{
+		config:      *config,
+		reporter:    reporter,
+		windowStart: time.Now(),
+	}
+}
+
+// observe records a script execution, and adjusts the delay at the end of each interval.
+func (t *fullScanThrottle) observe(latency time.Duration, err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.scripts++
+	t.latency += latency
+	if isNodePressureError(err) {
+		t.errors++
+	}
+	t.adjust(time.Now())
+}
+
+func (t *fullScanThrottle) adjust(now time.Time) {
+	if now.Sub(t.windowStart) < t.config.Interval || t.scripts == 0 {
+		return
+	}
+	latency := t.latency / time.Duration(t.scripts)
+	errorRate := float64(t.errors) / float64(t.scripts)
+	t.windowStart = now
+	t.scripts = 0
+	t.errors = 0
+	t.latency = 0
+
+	delay := t.state.Delay
+	if latency > t.config.TargetLatency || errorRate > t.config.MaxErrorRate {
+		delay *= 2
+		if delay < t.config.InitialDelay {
+			delay = t.config.InitialDelay
+		}
+		if delay > t.config.MaxDelay {
+			delay = t.config.MaxDelay
+		}
+	} else {
+		delay /= 2
+		if delay < t.config.InitialDelay {
+			delay = 0
+		}
+	}
+	t.state = FullScanThrottleState{
+		Delay:     delay,
+		Latency:   latency,
+		ErrorRate: errorRate,
+	}
+	if t.reporter != nil {
+		t.reporter.ReportFullScanThrottle(t.state)
+	}
+}
+
+// State is the current state of the throttle.
+func (t *fullScanThrottle) State() FullScanThrottleState {
+	if t == nil {
+		return FullScanThrottleState{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state
+}
+
+// wait waits for the current delay before the next full scan batch.
+// It returns false if the context is done before.
+func (t *fullScanThrottle) wait(ctx context.Context) bool {
+	delay := t.State().Delay
+	if delay == 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// isNodePressureError is true if the script failed because the access node is overloaded,
+// not because of the script or the accounts.
+func isNodePressureError(err error) bool {
+	if err == nil {
+		return false
+	}
+	class := ClassifyError(err)
+	if class == ErrRateLimited || class == ErrScriptTimeout {
+		return true
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFullScanThrottle(t *testing.T) {
+	config := FullScanThrottleConfig{
+		TargetLatency: time.Second,
+		MaxErrorRate:  0.1,
+		InitialDelay:  100 * time.Millisecond,
+		MaxDelay:      300 * time.Millisecond,
+		Interval:      time.Hour,
+	}
+	throttle := newFullScanThrottle(&config, nil)
+	// ends the interval after the observations
+	interval := func(latency time.Duration, err error) FullScanThrottleState {
+		for i := 0; i < 10; i++ {
+			throttle.observe(latency, err)
+		}
+		throttle.mu.Lock()
+		throttle.adjust(throttle.windowStart.Add(config.Interval))
+		throttle.mu.Unlock()
+		return throttle.State()
+	}
+
+	// slow scripts
+	require.Equal(t, FullScanThrottleState{Delay: 100 * time.Millisecond, Latency: 2 * time.Second},
+		interval(2*time.Second, nil))
+	require.Equal(t, 200*time.Millisecond, interval(2*time.Second, nil).Delay)
+
+	// the access node is unavailable, the delay is capped
+	state := interval(time.Millisecond, status.Error(codes.Unavailable, "unavailable"))
+	require.Equal(t, 300*time.Millisecond, state.Delay)
+	require.Equal(t, 1.0, state.ErrorRate)
+
+	// script errors do not throttle
+	require.Equal(t, 150*time.Millisecond, interval(time.Millisecond, fmt.Errorf("[Error Code: 1110]")).Delay)
+	require.Equal(t, time.Duration(0), interval(time.Millisecond, nil).Delay)
+
+	// the nil throttle does not throttle
+	var none *fullScanThrottle
+	none.observe(time.Hour, nil)
+	require.True(t, none.wait(context.Background()))
+
+	interval(2*time.Second, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.False(t, throttle.wait(ctx))
+}
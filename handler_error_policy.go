@@ -0,0 +1,216 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rs/zerolog"
+)
+
+// HandlerErrorAction is what happens when a ScriptResultHandler returns an error.
+type HandlerErrorAction int
+
+const (
+	// HandlerErrorFailScan stops the scan with the error. This is the default.
+	HandlerErrorFailScan HandlerErrorAction = iota
+	// HandlerErrorRetry calls the handler again with the same batch, with an exponential backoff.
+	// The scan fails if all retries fail. The handler has to be idempotent.
+	HandlerErrorRetry
+	// HandlerErrorLogAndContinue logs the error and considers the batch handled.
+	// The errors are counted in ScanStats.HandlerErrors and reported to the StatusReporter.
+	HandlerErrorLogAndContinue
+)
+
+// HandlerErrorPolicy decides what happens when a ScriptResultHandler returns an error.
+type HandlerErrorPolicy struct {
+	Action HandlerErrorAction
+	// Retries is the number of times the handler is called again with HandlerErrorRetry.
+	Retries int
+	// RetryBackoff is the wait before the first retry. It doubles with every retry, up to MaxHandlerRetryBackoff.
+	RetryBackoff time.Duration
+}
+
+// MaxHandlerRetryBackoff is the longest wait between the retries of HandlerErrorRetry.
+const MaxHandlerRetryBackoff = time.Minute
+
+func DefaultHandlerErrorPolicy() HandlerErrorPolicy {
+	return HandlerErrorPolicy{
+		Action: HandlerErrorFailScan,
+	}
+}
+
+// RetryHandlerErrors retries the handler the given number of times, before failing the scan.
+func RetryHandlerErrors(retries int, backoff time.Duration) HandlerErrorPolicy {
+	return HandlerErrorPolicy{
+		Action:       HandlerErrorRetry,
+		Retries:      retries,
+		RetryBackoff: backoff,
+	}
+}
+
+// LogHandlerErrors logs the handler errors and continues the scan.
+func LogHandlerErrors() HandlerErrorPolicy {
+	return HandlerErrorPolicy{
+		Action: HandlerErrorLogAndContinue,
+	}
+}
+
+// handle calls the handler, and retries it according to the policy.
+// The retries stop when the context is cancelled, with the last error of the handler.
+func (p HandlerErrorPolicy) handle(ctx context.Context, handler ScriptResultHandler, batch ProcessedAddressBatch) error {
+	err := handler.Handle(batch)
+	if err == nil || p.Action != HandlerErrorRetry {
+		return err
+	}
+
+	backoff := p.RetryBackoff
+	for i := 0; i < p.Retries && err != nil && !isIgnoredHandlerError(err); i++ {
+		if backoff > MaxHandlerRetryBackoff || backoff < 0 {
+			backoff = MaxHandlerRetryBackoff
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+		err = handler.Handle(batch)
+	}
+	return err
+}
+
+// IgnoredHandlerError is returned by handlers with the HandlerErrorLogAndContinue policy.
+// The batch is considered handled, and the error is only counted.
+type IgnoredHandlerError struct {
+	Err error
+}
+
+func (e *IgnoredHandlerError) Error() string {
+	return "ignored handler error: " + e.Err.Error()
+}
+
+func (e *IgnoredHandlerError) Unwrap() error {
+	return e.Err
+}
+
+// count is the number of errors that were ignored.
+func (e *IgnoredHandlerError) count() int {
+	var merr *multierror.Error
+	if errors.As(e.Err, &merr) && len(merr.Errors) > 0 {
+		return len(merr.Errors)
+	}
+	return 1
+}
+
+func isIgnoredHandlerError(err error) bool {
+	var ignored *IgnoredHandlerError
+	return errors.As(err, &ignored)
+}
+
+// PolicyResultHandler applies a HandlerErrorPolicy to a single handler,
+// e.g. to retry a database handler, while ignoring the errors of a metrics handler.
+type PolicyResultHandler struct {
+	Handler ScriptResultHandler
+	Policy  HandlerErrorPolicy
+
+	// ctx cancels the backoff between the retries, it is set to the scan context by the ScriptResultProcessor.
+	ctx    context.Context
+	logger zerolog.Logger
+}
+
+var _ ScriptResultHandler = (*PolicyResultHandler)(nil)
+
+func NewPolicyResultHandler(
+	handler ScriptResultHandler,
+	policy HandlerErrorPolicy,
+	logger zerolog.Logger,
+) *PolicyResultHandler {
+	return &PolicyResultHandler{
+		Handler: handler,
+		Policy:  policy,
+		logger:  logger.With().Str("component", "policy_result_handler").Logger(),
+	}
+}
+
+func (h *PolicyResultHandler) Handle(batch ProcessedAddressBatch) error {
+	ctx := h.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	err := h.Policy.handle(ctx, h.Handler, batch)
+	if err == nil || h.Policy.Action != HandlerErrorLogAndContinue || isIgnoredHandlerError(err) {
+		return err
+	}
+	h.logger.Error().
+		Err(err).
+		Str("handler_type", fmt.Sprintf("%T", h.Handler)).
+		Uint64("block_height", batch.BlockHeight).
+		Int("addresses", len(batch.Addresses)).
+		Msg("result handler failed")
+	return &IgnoredHandlerError{Err: err}
+}
+
+func (h *PolicyResultHandler) Unwrap() ScriptResultHandler {
+	return h.Handler
+}
+
+// setPolicyContext sets the context of the PolicyResultHandlers among the handler, the handlers it wraps
+// and the handlers of a FanOutResultHandler or ScriptResultHandlerMux, so their retries stop when the scan is cancelled.
+func setPolicyContext(handler ScriptResultHandler, ctx context.Context) {
+	switch h := handler.(type) {
+	case *PolicyResultHandler:
+		h.ctx = ctx
+	case *FanOutResultHandler:
+		for _, handler := range h.Handlers {
+			setPolicyContext(handler, ctx)
+		}
+		return
+	case ScriptResultHandlerMux:
+		for _, handler := range h {
+			setPolicyContext(handler, ctx)
+		}
+		return
+	}
+	if wrapper, ok := handler.(interface {
+		Unwrap() ScriptResultHandler
+	}); ok {
+		setPolicyContext(wrapper.Unwrap(), ctx)
+	}
+}
+
+// resultHandlerComponent returns the handler as a Component, if it (or a handler it wraps) is one.
+// Wrapping handlers, like PolicyResultHandler and the middlewares of the handlers package,
+// return the handler they wrap with Unwrap.
+func resultHandlerComponent(handler ScriptResultHandler) (Component, bool) {
+	for {
+		if c, ok := handler.(Component); ok {
+			return c, true
+		}
+		wrapper, ok := handler.(interface {
+			Unwrap() ScriptResultHandler
+		})
+		if !ok {
+			return nil, false
+		}
+		handler = wrapper.Unwrap()
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyResultHandler fails the first failures calls.
+type flakyResultHandler struct {
+	failures int
+	calls    int
+}
+
+func (h *flakyResultHandler) Handle(ProcessedAddressBatch) error {
+	h.calls++
+	if h.calls <= h.failures {
+		return fmt.Errorf("failed")
+	}
+	return nil
+}
+
+func TestPolicyResultHandler(t *testing.T) {
+	batch := ProcessedAddressBatch{AddressBatch: NewAddressBatch(nil, 1, nil, nil)}
+
+	t.Run("fail the scan", func(t *testing.T) {
+		handler := &flakyResultHandler{failures: 1}
+		h := NewPolicyResultHandler(handler, DefaultHandlerErrorPolicy(), zerolog.Nop())
+		err := h.Handle(batch)
+		require.Error(t, err)
+		require.False(t, isIgnoredHandlerError(err))
+		require.Equal(t, 1, handler.calls)
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		handler := &flakyResultHandler{failures: 2}
+		h := NewPolicyResultHandler(handler, RetryHandlerErrors(2, 0), zerolog.Nop())
+		require.NoError(t, h.Handle(batch))
+		require.Equal(t, 3, handler.calls)
+
+		handler = &flakyResultHandler{failures: 3}
+		h = NewPolicyResultHandler(handler, RetryHandlerErrors(2, 0), zerolog.Nop())
+		require.Error(t, h.Handle(batch))
+		require.Equal(t, 3, handler.calls)
+	})
+
+	t.Run("retries stop when the context is cancelled", func(t *testing.T) {
+		handler := &flakyResultHandler{failures: 2}
+		h := NewPolicyResultHandler(handler, RetryHandlerErrors(2, time.Hour), zerolog.Nop())
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		h.ctx = ctx
+
+		start := time.Now()
+		require.Error(t, h.Handle(batch))
+		require.Less(t, time.Since(start), MaxHandlerRetryBackoff)
+		require.Equal(t, 1, handler.calls)
+	})
+
+	t.Run("log and continue", func(t *testing.T) {
+		h := NewPolicyResultHandler(failingResultHandler{}, LogHandlerErrors(), zerolog.Nop())
+		require.True(t, isIgnoredHandlerError(h.Handle(batch)))
+	})
+}
+
+func TestScriptResultProcessor_IgnoreHandlerError(t *testing.T) {
+	stats := newStatsCollector()
+	r := &ScriptResultProcessor{stats: stats}
+
+	require.NoError(t, r.ignoreHandlerError(nil))
+	require.Error(t, r.ignoreHandlerError(fmt.Errorf("failed")))
+
+	fanOut := NewFanOutResultHandler(
+		[]ScriptResultHandler{
+			failingResultHandler{},
+			NewPolicyResultHandler(failingResultHandler{}, LogHandlerErrors(), zerolog.Nop()),
+			&recordingResultHandler{},
+		},
+		true,
+		zerolog.Nop(),
+	)
+	require.NoError(t, r.ignoreHandlerError(fanOut.Handle(ProcessedAddressBatch{})))
+	require.Equal(t, uint64(2), stats.snapshot(0).HandlerErrors)
+}
+
+func TestSetPolicyContext(t *testing.T) {
+	retrying := NewPolicyResultHandler(failingResultHandler{}, RetryHandlerErrors(1, time.Hour), zerolog.Nop())
+	logging := NewPolicyResultHandler(retrying, LogHandlerErrors(), zerolog.Nop())
+	handler := ScriptResultHandlerMux{
+		"script": NewFanOutResultHandler([]ScriptResultHandler{logging}, true, zerolog.Nop()),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	setPolicyContext(handler, ctx)
+	require.Equal(t, ctx, logging.ctx)
+	require.Equal(t, ctx, retrying.ctx)
+}
@@ -0,0 +1,306 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bigquery streams the per address scan results into a BigQuery table,
+// one row per address (see Schema).
+//
+// The Handler creates and migrates the table schema, batches the rows and retries failed appends.
+// Talking to BigQuery is left to the Table the caller passes in: this module does not
+// vendor cloud.google.com/go/bigquery, so the storage write API calls live in that adapter.
+// With the bigquery and managedwriter packages, Schema reads bigquery.Table.Metadata
+// (mapping a 404 to ErrTableNotFound), Create and AddFields call bigquery.Table.Create and
+// bigquery.Table.Update, and AppendRows appends to a managedwriter.ManagedStream
+// and waits for the AppendResult.
+package bigquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/handlers"
+)
+
+// ErrTableNotFound is returned by Table.Schema if the table does not exist.
+var ErrTableNotFound = errors.New("table not found")
+
+// Table is the results table.
+type Table interface {
+	// Schema returns the fields of the table, or ErrTableNotFound.
+	Schema(ctx context.Context) ([]Field, error)
+	// Create creates the table with the fields.
+	Create(ctx context.Context, fields []Field) error
+	// AddFields adds the (nullable) fields to the schema of the table.
+	AddFields(ctx context.Context, fields []Field) error
+	// AppendRows appends the rows with the storage write API, and returns once they were committed.
+	AppendRows(ctx context.Context, rows []Row) error
+}
+
+// Field is a column of the table schema.
+type Field struct {
+	Name string
+	// Type is the BigQuery type, e.g. INT64, STRING, JSON or TIMESTAMP.
+	Type string
+	// Mode is REQUIRED or NULLABLE.
+	Mode        string
+	Description string
+}
+
+// Schema is the schema of the results table, one row per address.
+var Schema = []Field{
+	{Name: "block_height", Type: "INT64", Mode: "REQUIRED", Description: "height of the block the script was run at"},
+	{Name: "block_id", Type: "STRING", Mode: "NULLABLE", Description: "hex encoded ID of the block the script was run at"},
+	{Name: "address", Type: "STRING", Mode: "REQUIRED", Description: "hex encoded account address"},
+	{Name: "script_name", Type: "STRING", Mode: "NULLABLE", Description: "name of the script, empty for the scan script"},
+	{Name: "result", Type: "JSON", Mode: "REQUIRED", Description: "JSON-Cadence encoded result of the address"},
+	{Name: "scanned_at", Type: "TIMESTAMP", Mode: "REQUIRED", Description: "time the result was handled"},
+}
+
+// Row is a row of the results table, see Schema.
+type Row struct {
+	BlockHeight uint64
+	BlockID     string
+	Address     string
+	ScriptName  string
+	Result      string
+	ScannedAt   time.Time
+}
+
+// size is the approximate encoded size of the row.
+func (r Row) size() int {
+	return 32 + len(r.BlockID) + len(r.Address) + len(r.ScriptName) + len(r.Result)
+}
+
+type Config struct {
+	// CreateTable if true, the table is created with Schema if it does not exist.
+	CreateTable bool
+	// UpdateSchema if true, the fields of Schema missing from an existing table are added to it,
+	// e.g. after an upgrade added a column.
+	UpdateSchema bool
+	// MaxBatchRows and MaxBatchBytes are the number of rows and their approximate size after which
+	// the buffered rows are appended. AppendRows requests of the storage write API are limited to 10MB.
+	MaxBatchRows  int
+	MaxBatchBytes int
+	// MaxBatchAge is the time after which the buffered rows are appended, even if the batch is not full.
+	MaxBatchAge time.Duration
+	// Retries is how often an append is retried.
+	Retries      int
+	RetryBackoff time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		CreateTable:   true,
+		UpdateSchema:  true,
+		MaxBatchRows:  10_000,
+		MaxBatchBytes: 8 * 1024 * 1024,
+		MaxBatchAge:   10 * time.Second,
+		Retries:       5,
+		RetryBackoff:  time.Second,
+	}
+}
+
+// Handler buffers a row for each address of the processed batches, and appends them to the table.
+// The handler is a Component: when it starts the table schema is checked, created or updated,
+// and when it is the ScriptResultHandler of a scan the buffered rows are appended when the scan concludes.
+type Handler struct {
+	*scanner.ComponentBase
+	Config
+
+	table Table
+
+	mu        sync.Mutex
+	rows      []Row
+	size      int
+	createdAt time.Time
+}
+
+var _ scanner.ScriptResultHandler = (*Handler)(nil)
+var _ scanner.Component = (*Handler)(nil)
+
+func NewHandler(table Table, config Config, logger zerolog.Logger) *Handler {
+	h := &Handler{
+		Config: config,
+		table:  table,
+	}
+	h.ComponentBase = scanner.NewComponentWithStart(
+		"bigquery_handler",
+		h.start,
+		logger,
+	)
+	return h
+}
+
+func (h *Handler) start(ctx context.Context) {
+	if err := h.EnsureSchema(ctx); err != nil {
+		h.Finish(err)
+		return
+	}
+
+	go func() {
+		var tick <-chan time.Time
+		if h.MaxBatchAge > 0 {
+			ticker := time.NewTicker(h.MaxBatchAge / 10)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				h.Finish(h.Flush())
+				return
+			case <-tick:
+				err := h.appendIf(func() bool {
+					return time.Since(h.createdAt) >= h.MaxBatchAge
+				})
+				if err != nil {
+					h.Finish(err)
+					return
+				}
+			}
+		}
+	}()
+}
+
+// EnsureSchema checks that the table has the fields of Schema.
+// With CreateTable a missing table is created, and with UpdateSchema the missing fields are added.
+// It fails if a field has a different type.
+func (h *Handler) EnsureSchema(ctx context.Context) error {
+	fields, err := h.table.Schema(ctx)
+	switch {
+	case errors.Is(err, ErrTableNotFound) && h.CreateTable:
+		h.Logger.Info().Msg("creating the results table")
+		if err := h.table.Create(ctx, Schema); err != nil {
+			return fmt.Errorf("failed to create the results table: %w", err)
+		}
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to get the schema of the results table: %w", err)
+	}
+
+	missing, err := missingFields(fields, Schema)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if !h.UpdateSchema {
+		return fmt.Errorf("the results table is missing the field %s", missing[0].Name)
+	}
+	h.Logger.Info().
+		Int("fields", len(missing)).
+		Msg("adding the missing fields to the results table")
+	if err := h.table.AddFields(ctx, missing); err != nil {
+		return fmt.Errorf("failed to update the schema of the results table: %w", err)
+	}
+	return nil
+}
+
+// missingFields are the fields of want that are not in existing, as nullable fields,
+// because BigQuery can not add required fields to a table.
+func missingFields(existing []Field, want []Field) ([]Field, error) {
+	types := make(map[string]string, len(existing))
+	for _, field := range existing {
+		types[field.Name] = field.Type
+	}
+	var missing []Field
+	for _, field := range want {
+		typ, ok := types[field.Name]
+		if !ok {
+			field.Mode = "NULLABLE"
+			missing = append(missing, field)
+			continue
+		}
+		if typ != field.Type {
+			return nil, fmt.Errorf("the field %s of the results table has the type %s, expected %s", field.Name, typ, field.Type)
+		}
+	}
+	return missing, nil
+}
+
+// Flush appends the buffered rows.
+func (h *Handler) Flush() error {
+	return h.appendIf(func() bool { return true })
+}
+
+func (h *Handler) Handle(batch scanner.ProcessedAddressBatch) error {
+	records, err := handlers.NewAddressRecords(batch)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	h.mu.Lock()
+	if len(h.rows) == 0 {
+		h.createdAt = now
+	}
+	for _, record := range records {
+		row := Row{
+			BlockHeight: record.BlockHeight,
+			BlockID:     record.BlockID,
+			Address:     record.Address.Hex(),
+			ScriptName:  record.ScriptName,
+			Result:      string(record.Result),
+			ScannedAt:   now,
+		}
+		h.rows = append(h.rows, row)
+		h.size += row.size()
+	}
+	h.mu.Unlock()
+
+	return h.appendIf(func() bool {
+		return (h.MaxBatchRows > 0 && len(h.rows) >= h.MaxBatchRows) ||
+			(h.MaxBatchBytes > 0 && h.size >= h.MaxBatchBytes)
+	})
+}
+
+// appendIf appends the buffered rows if there are any and the condition is true.
+// The condition is called with the lock held.
+func (h *Handler) appendIf(condition func() bool) error {
+	h.mu.Lock()
+	rows := h.rows
+	if len(rows) == 0 || !condition() {
+		h.mu.Unlock()
+		return nil
+	}
+	h.rows = nil
+	h.size = 0
+	h.mu.Unlock()
+
+	ctx := context.Background()
+	for attempt := 0; ; attempt++ {
+		err := h.table.AppendRows(ctx, rows)
+		if err == nil {
+			h.Logger.Debug().
+				Int("rows", len(rows)).
+				Msg("appended rows")
+			return nil
+		}
+		if attempt >= h.Retries {
+			return fmt.Errorf("failed to append %d rows: %w", len(rows), err)
+		}
+		h.Logger.Warn().
+			Err(err).
+			Int("rows", len(rows)).
+			Int("attempt", attempt+1).
+			Msg("failed to append rows, retrying")
+		time.Sleep(h.RetryBackoff)
+	}
+}
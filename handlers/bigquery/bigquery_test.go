@@ -0,0 +1,129 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigquery_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/handlers/bigquery"
+)
+
+type memoryTable struct {
+	fields   []bigquery.Field
+	failures int
+	appends  [][]bigquery.Row
+}
+
+func (t *memoryTable) Schema(context.Context) ([]bigquery.Field, error) {
+	if t.fields == nil {
+		return nil, bigquery.ErrTableNotFound
+	}
+	return t.fields, nil
+}
+
+func (t *memoryTable) Create(_ context.Context, fields []bigquery.Field) error {
+	t.fields = fields
+	return nil
+}
+
+func (t *memoryTable) AddFields(_ context.Context, fields []bigquery.Field) error {
+	t.fields = append(t.fields, fields...)
+	return nil
+}
+
+func (t *memoryTable) AppendRows(_ context.Context, rows []bigquery.Row) error {
+	if t.failures > 0 {
+		t.failures--
+		return fmt.Errorf("append failed")
+	}
+	t.appends = append(t.appends, rows)
+	return nil
+}
+
+func TestHandler(t *testing.T) {
+	a1 := flow.HexToAddress("0x1")
+	a2 := flow.HexToAddress("0x2")
+	batch := scanner.ProcessedAddressBatch{
+		AddressBatch: scanner.NewAddressBatch([]flow.Address{a1, a2}, 5, nil, nil),
+		Result: cadence.NewDictionary([]cadence.KeyValuePair{
+			{Key: cadence.NewAddress(a1), Value: cadence.NewInt(1)},
+			{Key: cadence.NewAddress(a2), Value: cadence.NewInt(2)},
+		}),
+	}
+
+	t.Run("creates the table and appends when the scan concludes", func(t *testing.T) {
+		table := &memoryTable{failures: 1}
+		config := bigquery.DefaultConfig()
+		config.RetryBackoff = 0
+		h := bigquery.NewHandler(table, config, zerolog.Nop())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		<-h.Start(ctx)
+		require.Equal(t, bigquery.Schema, table.fields)
+
+		require.NoError(t, h.Handle(batch))
+		require.NoError(t, h.Handle(batch))
+		require.Empty(t, table.appends)
+		cancel()
+		<-h.Done()
+		require.NoError(t, h.Err())
+
+		require.Len(t, table.appends, 1)
+		rows := table.appends[0]
+		require.Len(t, rows, 4)
+		require.Equal(t, uint64(5), rows[0].BlockHeight)
+		require.Equal(t, a1.Hex(), rows[0].Address)
+		require.JSONEq(t, `{"type":"Int","value":"1"}`, rows[0].Result)
+	})
+
+	t.Run("appends full batches", func(t *testing.T) {
+		table := &memoryTable{}
+		config := bigquery.DefaultConfig()
+		config.MaxBatchRows = 3
+		h := bigquery.NewHandler(table, config, zerolog.Nop())
+
+		require.NoError(t, h.Handle(batch))
+		require.Empty(t, table.appends)
+		require.NoError(t, h.Handle(batch))
+		require.Len(t, table.appends, 1)
+		require.Len(t, table.appends[0], 4)
+	})
+
+	t.Run("updates the schema", func(t *testing.T) {
+		table := &memoryTable{fields: append([]bigquery.Field{}, bigquery.Schema[:2]...)}
+		h := bigquery.NewHandler(table, bigquery.DefaultConfig(), zerolog.Nop())
+		require.NoError(t, h.EnsureSchema(context.Background()))
+		require.Len(t, table.fields, len(bigquery.Schema))
+		require.Equal(t, "NULLABLE", table.fields[2].Mode)
+
+		config := bigquery.DefaultConfig()
+		config.UpdateSchema = false
+		h = bigquery.NewHandler(&memoryTable{fields: bigquery.Schema[:2]}, config, zerolog.Nop())
+		require.ErrorContains(t, h.EnsureSchema(context.Background()), "missing the field address")
+
+		conflicting := append([]bigquery.Field{}, bigquery.Schema...)
+		conflicting[0].Type = "STRING"
+		h = bigquery.NewHandler(&memoryTable{fields: conflicting}, config, zerolog.Nop())
+		require.ErrorContains(t, h.EnsureSchema(context.Background()), "block_height")
+	})
+}
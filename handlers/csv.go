@@ -0,0 +1,169 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sync"
+
+	scanner "github.com/onflow/flow-batch-scan"
+)
+
+// DefaultCSVFlushEvery is the number of rows that are buffered before they are flushed to the writer.
+const DefaultCSVFlushEvery = 1000
+
+// CSVRows maps a batch to rows of the CSV. See PerAddressRows.
+type CSVRows func(batch scanner.ProcessedAddressBatch) ([][]string, error)
+
+// PerAddressRows maps each address of the batch to a row.
+// See scanner.ProcessedAddressBatch.PerAddressResults.
+func PerAddressRows(row func(blockHeight uint64, result scanner.AddressResult) ([]string, error)) CSVRows {
+	return func(batch scanner.ProcessedAddressBatch) ([][]string, error) {
+		results, err := batch.PerAddressResults()
+		if err != nil {
+			return nil, fmt.Errorf("failed to split the result by address: %w", err)
+		}
+		rows := make([][]string, 0, len(results))
+		for _, result := range results {
+			r, err := row(batch.BlockHeight, result)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, r)
+		}
+		return rows, nil
+	}
+}
+
+// CSVHandler writes the results as CSV rows, mapped by the CSVRows function.
+// Writes are serialized, so the handler can be used concurrently.
+// Close has to be called after the scan, to flush the buffered rows and write the summary row.
+type CSVHandler struct {
+	mu sync.Mutex
+	w  *csv.Writer
+
+	header     []string
+	rows       CSVRows
+	summary    func(rows, batches uint64) []string
+	flushEvery int
+
+	rowCount       uint64
+	batchCount     uint64
+	unflushedCount int
+}
+
+var _ scanner.ScriptResultHandler = (*CSVHandler)(nil)
+
+type CSVOption func(*CSVHandler)
+
+// WithCSVFlushEvery sets the number of rows that are buffered before they are flushed.
+func WithCSVFlushEvery(rows int) CSVOption {
+	return func(h *CSVHandler) {
+		h.flushEvery = rows
+	}
+}
+
+// WithCSVSummary sets the function that creates the summary row written on Close.
+// If summary returns nil no summary row is written.
+func WithCSVSummary(summary func(rows, batches uint64) []string) CSVOption {
+	return func(h *CSVHandler) {
+		h.summary = summary
+	}
+}
+
+// NewCSVHandler creates a CSVHandler, the header is written before the first row.
+func NewCSVHandler(
+	w io.Writer,
+	header []string,
+	rows CSVRows,
+	opts ...CSVOption,
+) *CSVHandler {
+	h := &CSVHandler{
+		w:          csv.NewWriter(w),
+		header:     header,
+		rows:       rows,
+		flushEvery: DefaultCSVFlushEvery,
+	}
+	h.summary = h.defaultSummary
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *CSVHandler) Handle(batch scanner.ProcessedAddressBatch) error {
+	rows, err := h.rows(batch)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.batchCount == 0 && len(h.header) > 0 {
+		err := h.w.Write(h.header)
+		if err != nil {
+			return err
+		}
+	}
+	err = h.w.WriteAll(rows)
+	if err != nil {
+		return err
+	}
+	h.batchCount++
+	h.rowCount += uint64(len(rows))
+	h.unflushedCount += len(rows)
+
+	if h.unflushedCount >= h.flushEvery {
+		h.w.Flush()
+		h.unflushedCount = 0
+	}
+	return h.w.Error()
+}
+
+// Close writes the summary row and flushes the buffered rows. It does not close the underlying writer.
+func (h *CSVHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.batchCount == 0 && len(h.header) > 0 {
+		err := h.w.Write(h.header)
+		if err != nil {
+			return err
+		}
+	}
+	if h.summary != nil {
+		if row := h.summary(h.rowCount, h.batchCount); row != nil {
+			err := h.w.Write(row)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	h.w.Flush()
+	return h.w.Error()
+}
+
+// defaultSummary has the same number of columns as the header, so the CSV stays rectangular.
+func (h *CSVHandler) defaultSummary(rows, batches uint64) []string {
+	row := make([]string, len(h.header))
+	if len(row) == 0 {
+		row = make([]string, 1)
+	}
+	row[0] = fmt.Sprintf("total: %d rows in %d batches", rows, batches)
+	return row
+}
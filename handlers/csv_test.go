@@ -0,0 +1,61 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers_test
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/handlers"
+)
+
+func TestCSVHandler(t *testing.T) {
+	batch := scanner.ProcessedAddressBatch{
+		AddressBatch: scanner.NewAddressBatch(
+			[]flow.Address{flow.HexToAddress("0x1"), flow.HexToAddress("0x2")},
+			5,
+			nil,
+			nil),
+		Result: cadence.NewArray([]cadence.Value{cadence.String("a,b"), cadence.String("c")}),
+	}
+
+	buf := &bytes.Buffer{}
+	h := handlers.NewCSVHandler(
+		buf,
+		[]string{"address", "block_height", "value"},
+		handlers.PerAddressRows(func(blockHeight uint64, result scanner.AddressResult) ([]string, error) {
+			return []string{
+				result.Address.Hex(),
+				strconv.FormatUint(blockHeight, 10),
+				string(result.Value.(cadence.String)),
+			}, nil
+		}),
+	)
+	require.NoError(t, h.Handle(batch))
+	require.NoError(t, h.Close())
+
+	require.Equal(t,
+		"address,block_height,value\n"+
+			"0000000000000001,5,\"a,b\"\n"+
+			"0000000000000002,5,c\n"+
+			"total: 2 rows in 1 batches,,\n",
+		buf.String())
+}
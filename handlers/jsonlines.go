@@ -0,0 +1,201 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package handlers contains ScriptResultHandlers for common outputs.
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+	"github.com/onflow/flow-go-sdk"
+
+	scanner "github.com/onflow/flow-batch-scan"
+)
+
+// BatchRecord is the JSON line written for each batch.
+// The result is encoded as JSON-Cadence, so it can be decoded back to a cadence.Value.
+// If the scan signs the batches, the record contains the signature, see scanner.VerifyProcessedAddressBatch
+// and BatchRecord.ProcessedAddressBatch.
+type BatchRecord struct {
+	BlockHeight uint64          `json:"block_height"`
+	BlockID     string          `json:"block_id,omitempty"`
+	Addresses   []flow.Address  `json:"addresses"`
+	ScriptName  string          `json:"script_name,omitempty"`
+	Result      json.RawMessage `json:"result"`
+	Signature   []byte          `json:"signature,omitempty"`
+}
+
+// AddressRecord is the JSON line written for each address, if the handler writes per address records.
+// See scanner.ProcessedAddressBatch.PerAddressResults.
+type AddressRecord struct {
+	BlockHeight uint64          `json:"block_height"`
+	BlockID     string          `json:"block_id,omitempty"`
+	Address     flow.Address    `json:"address"`
+	ScriptName  string          `json:"script_name,omitempty"`
+	Result      json.RawMessage `json:"result"`
+}
+
+// JSONLinesHandler writes the results as JSON Lines, one line per batch or per address.
+// Writes are serialized, so the handler can be used concurrently.
+// Use a RotatingFile as the writer to rotate the output files.
+type JSONLinesHandler struct {
+	mu sync.Mutex
+	w  io.Writer
+
+	perAddress bool
+}
+
+var _ scanner.ScriptResultHandler = (*JSONLinesHandler)(nil)
+
+type JSONLinesOption func(*JSONLinesHandler)
+
+// WithPerAddressRecords writes an AddressRecord for each address instead of a BatchRecord for each batch.
+func WithPerAddressRecords() JSONLinesOption {
+	return func(h *JSONLinesHandler) {
+		h.perAddress = true
+	}
+}
+
+func NewJSONLinesHandler(w io.Writer, opts ...JSONLinesOption) *JSONLinesHandler {
+	h := &JSONLinesHandler{
+		w: w,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *JSONLinesHandler) Handle(batch scanner.ProcessedAddressBatch) error {
+	lines, err := h.lines(batch)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, line := range lines {
+		_, err := h.w.Write(line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *JSONLinesHandler) lines(batch scanner.ProcessedAddressBatch) ([][]byte, error) {
+	if !h.perAddress {
+		record, err := NewBatchRecord(batch)
+		if err != nil {
+			return nil, err
+		}
+		line, err := marshalLine(record)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{line}, nil
+	}
+
+	records, err := NewAddressRecords(batch)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([][]byte, len(records))
+	for i, record := range records {
+		lines[i], err = marshalLine(record)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return lines, nil
+}
+
+// NewBatchRecord creates the record of a batch.
+func NewBatchRecord(batch scanner.ProcessedAddressBatch) (BatchRecord, error) {
+	result, err := jsoncdc.Encode(batch.Result)
+	if err != nil {
+		return BatchRecord{}, err
+	}
+	return BatchRecord{
+		BlockHeight: batch.BlockHeight,
+		BlockID:     blockID(batch),
+		Addresses:   batch.Addresses,
+		ScriptName:  batch.ScriptName,
+		Result:      result,
+		Signature:   batch.Signature,
+	}, nil
+}
+
+// ProcessedAddressBatch decodes the record back to the batch it was created from,
+// e.g. to verify its signature with scanner.VerifyProcessedAddressBatch.
+func (r BatchRecord) ProcessedAddressBatch() (scanner.ProcessedAddressBatch, error) {
+	result, err := jsoncdc.Decode(nil, r.Result)
+	if err != nil {
+		return scanner.ProcessedAddressBatch{}, err
+	}
+	batch := scanner.ProcessedAddressBatch{
+		AddressBatch: scanner.NewAddressBatch(r.Addresses, r.BlockHeight, nil, nil),
+		Result:       result,
+		Signature:    r.Signature,
+	}
+	batch.ScriptName = r.ScriptName
+	if r.BlockID != "" {
+		batch.BlockID = flow.HexToID(r.BlockID)
+	}
+	return batch, nil
+}
+
+// NewAddressRecords creates a record for each address of the batch.
+// See scanner.ProcessedAddressBatch.PerAddressResults.
+func NewAddressRecords(batch scanner.ProcessedAddressBatch) ([]AddressRecord, error) {
+	results, err := batch.PerAddressResults()
+	if err != nil {
+		return nil, fmt.Errorf("failed to split the result by address: %w", err)
+	}
+	records := make([]AddressRecord, len(results))
+	for i, r := range results {
+		result, err := jsoncdc.Encode(r.Value)
+		if err != nil {
+			return nil, err
+		}
+		records[i] = AddressRecord{
+			BlockHeight: batch.BlockHeight,
+			BlockID:     blockID(batch),
+			Address:     r.Address,
+			ScriptName:  batch.ScriptName,
+			Result:      result,
+		}
+	}
+	return records, nil
+}
+
+// blockID is the hex ID of the block of the batch, or empty if it is not known.
+func blockID(batch scanner.ProcessedAddressBatch) string {
+	if batch.BlockID == flow.EmptyID {
+		return ""
+	}
+	return batch.BlockID.Hex()
+}
+
+func marshalLine(record interface{}) ([]byte, error) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/handlers"
+)
+
+func TestJSONLinesHandler(t *testing.T) {
+	a1 := flow.HexToAddress("0x1")
+	a2 := flow.HexToAddress("0x2")
+	batch := scanner.ProcessedAddressBatch{
+		AddressBatch: scanner.NewAddressBatch([]flow.Address{a1, a2}, 5, nil, nil),
+		Result:       cadence.NewArray([]cadence.Value{cadence.NewInt(1), cadence.NewInt(2)}),
+	}
+
+	t.Run("per batch", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		h := handlers.NewJSONLinesHandler(buf)
+		require.NoError(t, h.Handle(batch))
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 1)
+		var record handlers.BatchRecord
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+		require.Equal(t, uint64(5), record.BlockHeight)
+		require.Equal(t, []flow.Address{a1, a2}, record.Addresses)
+	})
+
+	t.Run("signed batch records can be verified", func(t *testing.T) {
+		privateKey, err := crypto.GeneratePrivateKey(crypto.ECDSA_secp256k1, make([]byte, crypto.MinSeedLength))
+		require.NoError(t, err)
+		signer, err := crypto.NewInMemorySigner(privateKey, crypto.SHA3_256)
+		require.NoError(t, err)
+		signed := batch
+		signed.Signature, err = scanner.SignProcessedAddressBatch(signed, signer)
+		require.NoError(t, err)
+
+		buf := &bytes.Buffer{}
+		h := handlers.NewJSONLinesHandler(buf)
+		require.NoError(t, h.Handle(signed))
+
+		var record handlers.BatchRecord
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		require.Equal(t, signed.Signature, record.Signature)
+		decoded, err := record.ProcessedAddressBatch()
+		require.NoError(t, err)
+		valid, err := scanner.VerifyProcessedAddressBatch(decoded, privateKey.PublicKey(), crypto.SHA3_256)
+		require.NoError(t, err)
+		require.True(t, valid)
+	})
+
+	t.Run("per address", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		h := handlers.NewJSONLinesHandler(buf, handlers.WithPerAddressRecords())
+		require.NoError(t, h.Handle(batch))
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 2)
+		var record handlers.AddressRecord
+		require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+		require.Equal(t, a2, record.Address)
+		require.JSONEq(t, `{"type":"Int","value":"2"}`, string(record.Result))
+	})
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "results.jsonl")
+
+	f, err := handlers.NewRotatingFile(path, 10)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("12345678\n"))
+	require.NoError(t, err)
+	_, err = f.Write([]byte("abc\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "abc\n", string(data))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
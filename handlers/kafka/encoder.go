@@ -0,0 +1,117 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"encoding/json"
+
+	"github.com/hamba/avro"
+
+	"github.com/onflow/flow-batch-scan/handlers"
+)
+
+// Encoder encodes the records to message values.
+type Encoder interface {
+	EncodeBatch(record handlers.BatchRecord) ([]byte, error)
+	EncodeAddress(record handlers.AddressRecord) ([]byte, error)
+}
+
+// JSONEncoder encodes the records as JSON, the same as the JSON Lines handler.
+type JSONEncoder struct{}
+
+var _ Encoder = JSONEncoder{}
+
+func (JSONEncoder) EncodeBatch(record handlers.BatchRecord) ([]byte, error) {
+	return json.Marshal(record)
+}
+
+func (JSONEncoder) EncodeAddress(record handlers.AddressRecord) ([]byte, error) {
+	return json.Marshal(record)
+}
+
+// BatchRecordSchema is the Avro schema of the batch records.
+// The addresses are hex encoded, and the result is encoded as JSON-Cadence.
+const BatchRecordSchema = `{
+	"type": "record",
+	"name": "BatchRecord",
+	"namespace": "com.onflow.batchscan",
+	"fields": [
+		{"name": "block_height", "type": "long"},
+		{"name": "addresses", "type": {"type": "array", "items": "string"}},
+		{"name": "script_name", "type": "string"},
+		{"name": "result", "type": "string"}
+	]
+}`
+
+// AddressRecordSchema is the Avro schema of the per address records.
+// The address is hex encoded, and the result is encoded as JSON-Cadence.
+const AddressRecordSchema = `{
+	"type": "record",
+	"name": "AddressRecord",
+	"namespace": "com.onflow.batchscan",
+	"fields": [
+		{"name": "block_height", "type": "long"},
+		{"name": "address", "type": "string"},
+		{"name": "script_name", "type": "string"},
+		{"name": "result", "type": "string"}
+	]
+}`
+
+var (
+	batchRecordSchema   = avro.MustParse(BatchRecordSchema)
+	addressRecordSchema = avro.MustParse(AddressRecordSchema)
+)
+
+// AvroEncoder encodes the records with BatchRecordSchema and AddressRecordSchema.
+// The values do not contain the schema, register the schemas in your schema registry.
+type AvroEncoder struct{}
+
+var _ Encoder = AvroEncoder{}
+
+type avroBatchRecord struct {
+	BlockHeight int64    `avro:"block_height"`
+	Addresses   []string `avro:"addresses"`
+	ScriptName  string   `avro:"script_name"`
+	Result      string   `avro:"result"`
+}
+
+type avroAddressRecord struct {
+	BlockHeight int64  `avro:"block_height"`
+	Address     string `avro:"address"`
+	ScriptName  string `avro:"script_name"`
+	Result      string `avro:"result"`
+}
+
+func (AvroEncoder) EncodeBatch(record handlers.BatchRecord) ([]byte, error) {
+	addresses := make([]string, len(record.Addresses))
+	for i, address := range record.Addresses {
+		addresses[i] = address.Hex()
+	}
+	return avro.Marshal(batchRecordSchema, avroBatchRecord{
+		BlockHeight: int64(record.BlockHeight),
+		Addresses:   addresses,
+		ScriptName:  record.ScriptName,
+		Result:      string(record.Result),
+	})
+}
+
+func (AvroEncoder) EncodeAddress(record handlers.AddressRecord) ([]byte, error) {
+	return avro.Marshal(addressRecordSchema, avroAddressRecord{
+		BlockHeight: int64(record.BlockHeight),
+		Address:     record.Address.Hex(),
+		ScriptName:  record.ScriptName,
+		Result:      string(record.Result),
+	})
+}
@@ -0,0 +1,171 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kafka publishes the scan results to a Kafka topic, one message per address keyed by
+// the hex address, or one message per batch (see Config.PerAddress), encoded as JSON or Avro.
+//
+// The messages are delivered by the Producer the handler is created with, e.g. a kafka-go Writer
+// whose Flush returns nil, or a franz-go Client with ProduceSync and Flush.
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/handlers"
+)
+
+// Message is a Kafka message.
+type Message struct {
+	Topic string
+	// Key is the address for per address records, and empty for batch records.
+	Key   []byte
+	Value []byte
+}
+
+// Producer publishes messages to Kafka, e.g. a thin wrapper around a kafka-go Writer or a franz-go Client.
+type Producer interface {
+	// Produce returns once all the messages were delivered, or with the delivery error.
+	Produce(ctx context.Context, messages []Message) error
+	// Flush delivers all the buffered messages.
+	Flush(ctx context.Context) error
+}
+
+type Config struct {
+	Topic string
+	// PerAddress if true, a message is published for each address, keyed by the address.
+	// Otherwise a message is published for each batch.
+	PerAddress bool
+	// Encoder encodes the messages, JSONEncoder or AvroEncoder.
+	Encoder Encoder
+	// Retries is how often the messages of a batch are published again, after a delivery failure.
+	Retries      int
+	RetryBackoff time.Duration
+	// FlushTimeout is how long the flush at the end of the scan can take.
+	FlushTimeout time.Duration
+}
+
+func DefaultConfig(topic string) Config {
+	return Config{
+		Topic:        topic,
+		PerAddress:   true,
+		Encoder:      JSONEncoder{},
+		Retries:      5,
+		RetryBackoff: time.Second,
+		FlushTimeout: 30 * time.Second,
+	}
+}
+
+// Handler publishes each processed batch, or each address of the batch, as a Kafka message.
+// The handler is a Component, so when it is the ScriptResultHandler of a scan
+// the producer is flushed when the scan concludes.
+type Handler struct {
+	*scanner.ComponentBase
+	Config
+
+	producer Producer
+}
+
+var _ scanner.ScriptResultHandler = (*Handler)(nil)
+var _ scanner.Component = (*Handler)(nil)
+
+func NewHandler(producer Producer, config Config, logger zerolog.Logger) *Handler {
+	if config.Encoder == nil {
+		config.Encoder = JSONEncoder{}
+	}
+	h := &Handler{
+		Config:   config,
+		producer: producer,
+	}
+	h.ComponentBase = scanner.NewComponentWithStart(
+		"kafka_handler",
+		h.start,
+		logger,
+	)
+	return h
+}
+
+func (h *Handler) start(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		h.Finish(h.Flush())
+	}()
+}
+
+// Flush delivers all the messages buffered by the producer.
+func (h *Handler) Flush() error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.FlushTimeout)
+	defer cancel()
+	err := h.producer.Flush(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to flush kafka producer: %w", err)
+	}
+	return nil
+}
+
+func (h *Handler) Handle(batch scanner.ProcessedAddressBatch) error {
+	messages, err := h.messages(batch)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	for attempt := 0; ; attempt++ {
+		err = h.producer.Produce(ctx, messages)
+		if err == nil || attempt >= h.Retries {
+			return err
+		}
+		h.Logger.Warn().
+			Err(err).
+			Int("attempt", attempt+1).
+			Msg("failed to deliver messages, retrying")
+		time.Sleep(h.RetryBackoff)
+	}
+}
+
+func (h *Handler) messages(batch scanner.ProcessedAddressBatch) ([]Message, error) {
+	if !h.PerAddress {
+		record, err := handlers.NewBatchRecord(batch)
+		if err != nil {
+			return nil, err
+		}
+		value, err := h.Encoder.EncodeBatch(record)
+		if err != nil {
+			return nil, err
+		}
+		return []Message{{Topic: h.Topic, Value: value}}, nil
+	}
+
+	records, err := handlers.NewAddressRecords(batch)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]Message, len(records))
+	for i, record := range records {
+		value, err := h.Encoder.EncodeAddress(record)
+		if err != nil {
+			return nil, err
+		}
+		messages[i] = Message{
+			Topic: h.Topic,
+			Key:   []byte(record.Address.Hex()),
+			Value: value,
+		}
+	}
+	return messages, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hamba/avro"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/handlers/kafka"
+)
+
+type recordingProducer struct {
+	failures int
+	messages []kafka.Message
+	flushed  bool
+}
+
+func (p *recordingProducer) Produce(_ context.Context, messages []kafka.Message) error {
+	if p.failures > 0 {
+		p.failures--
+		return fmt.Errorf("delivery failed")
+	}
+	p.messages = append(p.messages, messages...)
+	return nil
+}
+
+func (p *recordingProducer) Flush(context.Context) error {
+	p.flushed = true
+	return nil
+}
+
+func TestHandler(t *testing.T) {
+	a1 := flow.HexToAddress("0x1")
+	batch := scanner.ProcessedAddressBatch{
+		AddressBatch: scanner.NewAddressBatch([]flow.Address{a1}, 5, nil, nil),
+		Result:       cadence.NewArray([]cadence.Value{cadence.NewInt(1)}),
+	}
+
+	t.Run("retries failed deliveries", func(t *testing.T) {
+		producer := &recordingProducer{failures: 2}
+		config := kafka.DefaultConfig("results")
+		config.RetryBackoff = 0
+		h := kafka.NewHandler(producer, config, zerolog.Nop())
+
+		require.NoError(t, h.Handle(batch))
+		require.Len(t, producer.messages, 1)
+		require.Equal(t, "results", producer.messages[0].Topic)
+		require.Equal(t, []byte(a1.Hex()), producer.messages[0].Key)
+	})
+
+	t.Run("flushes when the scan concludes", func(t *testing.T) {
+		producer := &recordingProducer{}
+		h := kafka.NewHandler(producer, kafka.DefaultConfig("results"), zerolog.Nop())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		<-h.Start(ctx)
+		cancel()
+		<-h.Done()
+		require.NoError(t, h.Err())
+		require.True(t, producer.flushed)
+	})
+
+	t.Run("avro", func(t *testing.T) {
+		producer := &recordingProducer{}
+		config := kafka.DefaultConfig("results")
+		config.Encoder = kafka.AvroEncoder{}
+		h := kafka.NewHandler(producer, config, zerolog.Nop())
+		require.NoError(t, h.Handle(batch))
+
+		var record map[string]interface{}
+		schema := avro.MustParse(kafka.AddressRecordSchema)
+		require.NoError(t, avro.Unmarshal(schema, producer.messages[0].Value, &record))
+		require.Equal(t, a1.Hex(), record["address"])
+		require.Equal(t, int64(5), record["block_height"])
+	})
+}
@@ -0,0 +1,237 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/rs/zerolog"
+
+	scanner "github.com/onflow/flow-batch-scan"
+)
+
+// The middlewares wrap a ScriptResultHandler to add a cross-cutting concern to it,
+// and can be stacked, e.g. WithMetrics(WithRetry(handler, 5, time.Second), reporter).
+// The wrapped handler is returned by Unwrap, so if it is a Component it is still started by the scanner.
+
+// WithRetry calls the handler again with the same batch while it fails, at most retries times,
+// with a backoff that starts at backoff and doubles with every retry. With no retries the handler is called once.
+// The handler has to be idempotent. IgnoredHandlerErrors are not retried.
+// It is a scanner.PolicyResultHandler with scanner.RetryHandlerErrors, so the retries stop when the scan is cancelled.
+func WithRetry(handler scanner.ScriptResultHandler, retries int, backoff time.Duration) scanner.ScriptResultHandler {
+	return scanner.NewPolicyResultHandler(handler, scanner.RetryHandlerErrors(retries, backoff), zerolog.Nop())
+}
+
+// MetricsReporter reports the Handle calls of a handler wrapped WithMetrics.
+// The scanner.DefaultStatusReporter reports them to prometheus.
+type MetricsReporter interface {
+	// ReportHandlerCall reports a Handle call of the handler (its type), the size of the batch,
+	// how long the call took and its error.
+	ReportHandlerCall(handler string, addresses int, duration time.Duration, err error)
+}
+
+var _ MetricsReporter = (*scanner.DefaultStatusReporter)(nil)
+
+// WithMetrics reports every Handle call of the handler to the reporter.
+func WithMetrics(handler scanner.ScriptResultHandler, reporter MetricsReporter) scanner.ScriptResultHandler {
+	return &metricsHandler{
+		handler:  handler,
+		name:     handlerName(handler),
+		reporter: reporter,
+	}
+}
+
+type metricsHandler struct {
+	handler  scanner.ScriptResultHandler
+	name     string
+	reporter MetricsReporter
+}
+
+func (h *metricsHandler) Unwrap() scanner.ScriptResultHandler {
+	return h.handler
+}
+
+func (h *metricsHandler) Handle(batch scanner.ProcessedAddressBatch) error {
+	start := time.Now()
+	err := h.handler.Handle(batch)
+	h.reporter.ReportHandlerCall(h.name, len(batch.Addresses), time.Since(start), err)
+	return err
+}
+
+// handlerName is the type of the innermost handler, e.g. *kafka.Handler.
+func handlerName(handler scanner.ScriptResultHandler) string {
+	for {
+		wrapper, ok := handler.(interface {
+			Unwrap() scanner.ScriptResultHandler
+		})
+		if !ok {
+			return fmt.Sprintf("%T", handler)
+		}
+		handler = wrapper.Unwrap()
+	}
+}
+
+// BatchesHandler is implemented by handlers that handle multiple batches more efficiently at once,
+// e.g. with a single bulk insert. A handler wrapped WithBuffering is called with the buffered batches.
+type BatchesHandler interface {
+	HandleBatches(batches []scanner.ProcessedAddressBatch) error
+}
+
+// BufferedResultHandler buffers the batches, and passes them to its handler once it buffered size batches,
+// or flushInterval after the oldest buffered batch.
+// The batches are considered handled once they are buffered, so the buffered batches are lost if the scanner crashes,
+// and errors of a flush of the interval stop the handler. Create it WithBuffering.
+//
+// The handler is a Component, so when it is the ScriptResultHandler of a scan the buffer is flushed
+// when the scan concludes. If the wrapped handler is a Component it is stopped after the flush.
+type BufferedResultHandler struct {
+	*scanner.ComponentBase
+
+	handler       scanner.ScriptResultHandler
+	size          int
+	flushInterval time.Duration
+
+	mu       sync.Mutex
+	batches  []scanner.ProcessedAddressBatch
+	oldestAt time.Time
+}
+
+var _ scanner.ScriptResultHandler = (*BufferedResultHandler)(nil)
+var _ scanner.Component = (*BufferedResultHandler)(nil)
+
+// WithBuffering buffers up to size batches for the handler, for at most flushInterval.
+// A flushInterval of 0 only flushes full buffers, and at the end of the scan.
+func WithBuffering(handler scanner.ScriptResultHandler, size int, flushInterval time.Duration) *BufferedResultHandler {
+	h := &BufferedResultHandler{
+		handler:       handler,
+		size:          size,
+		flushInterval: flushInterval,
+	}
+	h.ComponentBase = scanner.NewComponentWithStart(
+		"buffered_result_handler",
+		h.start,
+		zerolog.Nop(),
+	)
+	return h
+}
+
+func (h *BufferedResultHandler) Unwrap() scanner.ScriptResultHandler {
+	return h.handler
+}
+
+func (h *BufferedResultHandler) start(ctx context.Context) {
+	// the wrapped component runs until the last batches were flushed to it
+	inner, hasInner := component(h.handler)
+	innerCtx, cancelInner := context.WithCancel(context.Background())
+	if hasInner {
+		<-inner.Start(innerCtx)
+	}
+
+	go func() {
+		var tick <-chan time.Time
+		if h.flushInterval > 0 {
+			ticker := time.NewTicker(h.flushInterval / 10)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		var err error
+	loop:
+		for {
+			select {
+			case <-ctx.Done():
+				err = h.Flush()
+				break loop
+			case <-tick:
+				err = h.flushIf(func() bool {
+					return time.Since(h.oldestAt) >= h.flushInterval
+				})
+				if err != nil {
+					break loop
+				}
+			}
+		}
+
+		cancelInner()
+		if hasInner {
+			<-inner.Done()
+			if innerErr := inner.Err(); innerErr != nil && !errors.Is(innerErr, context.Canceled) {
+				err = multierror.Append(err, innerErr).ErrorOrNil()
+			}
+		}
+		h.Finish(err)
+	}()
+}
+
+func (h *BufferedResultHandler) Handle(batch scanner.ProcessedAddressBatch) error {
+	h.mu.Lock()
+	if len(h.batches) == 0 {
+		h.oldestAt = time.Now()
+	}
+	h.batches = append(h.batches, batch)
+	h.mu.Unlock()
+
+	return h.flushIf(func() bool {
+		return len(h.batches) >= h.size
+	})
+}
+
+// Flush passes the buffered batches to the handler.
+func (h *BufferedResultHandler) Flush() error {
+	return h.flushIf(func() bool { return true })
+}
+
+// flushIf flushes the buffered batches if there are any and the condition is true.
+// The condition is called with the lock held.
+func (h *BufferedResultHandler) flushIf(condition func() bool) error {
+	h.mu.Lock()
+	batches := h.batches
+	if len(batches) == 0 || !condition() {
+		h.mu.Unlock()
+		return nil
+	}
+	h.batches = nil
+	h.mu.Unlock()
+
+	if handler, ok := h.handler.(BatchesHandler); ok {
+		return handler.HandleBatches(batches)
+	}
+	for _, batch := range batches {
+		if err := h.handler.Handle(batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// component returns the handler as a Component, if it (or a handler it wraps) is one.
+func component(handler scanner.ScriptResultHandler) (scanner.Component, bool) {
+	for {
+		if c, ok := handler.(scanner.Component); ok {
+			return c, true
+		}
+		wrapper, ok := handler.(interface {
+			Unwrap() scanner.ScriptResultHandler
+		})
+		if !ok {
+			return nil, false
+		}
+		handler = wrapper.Unwrap()
+	}
+}
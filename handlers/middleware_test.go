@@ -0,0 +1,145 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/handlers"
+)
+
+type recordingHandler struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	handled  []uint64
+	bulk     []int
+}
+
+func (h *recordingHandler) Handle(batch scanner.ProcessedAddressBatch) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls++
+	if h.failures > 0 {
+		h.failures--
+		return fmt.Errorf("handler failed")
+	}
+	h.handled = append(h.handled, batch.BlockHeight)
+	return nil
+}
+
+type bulkHandler struct {
+	recordingHandler
+}
+
+func (h *bulkHandler) HandleBatches(batches []scanner.ProcessedAddressBatch) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.bulk = append(h.bulk, len(batches))
+	return nil
+}
+
+type recordingReporter struct {
+	handler string
+	errors  int
+}
+
+func (r *recordingReporter) ReportHandlerCall(handler string, _ int, _ time.Duration, err error) {
+	r.handler = handler
+	if err != nil {
+		r.errors++
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	batch := func(height uint64) scanner.ProcessedAddressBatch {
+		return scanner.ProcessedAddressBatch{
+			AddressBatch: scanner.NewAddressBatch([]flow.Address{flow.HexToAddress("0x1")}, height, nil, nil),
+			Result:       cadence.NewArray(nil),
+		}
+	}
+
+	t.Run("retry", func(t *testing.T) {
+		inner := &recordingHandler{failures: 2}
+		h := handlers.WithRetry(inner, 2, 0)
+		require.NoError(t, h.Handle(batch(1)))
+		require.Equal(t, 3, inner.calls)
+
+		inner = &recordingHandler{failures: 3}
+		h = handlers.WithRetry(inner, 2, 0)
+		require.Error(t, h.Handle(batch(1)))
+		require.Equal(t, 3, inner.calls)
+
+		// without retries the handler is called once
+		inner = &recordingHandler{failures: 1}
+		h = handlers.WithRetry(inner, 0, 0)
+		require.Error(t, h.Handle(batch(1)))
+		require.Equal(t, 1, inner.calls)
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		reporter := &recordingReporter{}
+		h := handlers.WithMetrics(handlers.WithRetry(&recordingHandler{failures: 1}, 0, 0), reporter)
+		require.Error(t, h.Handle(batch(1)))
+		require.NoError(t, h.Handle(batch(1)))
+		require.Equal(t, "*handlers_test.recordingHandler", reporter.handler)
+		require.Equal(t, 1, reporter.errors)
+	})
+
+	t.Run("buffering", func(t *testing.T) {
+		inner := &recordingHandler{}
+		h := handlers.WithBuffering(inner, 2, 0)
+		ctx, cancel := context.WithCancel(context.Background())
+		<-h.Start(ctx)
+
+		require.NoError(t, h.Handle(batch(1)))
+		require.Empty(t, inner.handled)
+		require.NoError(t, h.Handle(batch(2)))
+		require.Equal(t, []uint64{1, 2}, inner.handled)
+
+		// the buffer is flushed when the scan concludes
+		require.NoError(t, h.Handle(batch(3)))
+		cancel()
+		<-h.Done()
+		require.NoError(t, h.Err())
+		require.Equal(t, []uint64{1, 2, 3}, inner.handled)
+	})
+
+	t.Run("buffering flushes after the interval", func(t *testing.T) {
+		inner := &bulkHandler{}
+		h := handlers.WithBuffering(inner, 10, 10*time.Millisecond)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		<-h.Start(ctx)
+
+		require.NoError(t, h.Handle(batch(1)))
+		require.NoError(t, h.Handle(batch(2)))
+		require.Eventually(t, func() bool {
+			inner.mu.Lock()
+			defer inner.mu.Unlock()
+			return len(inner.bulk) == 1
+		}, time.Second, time.Millisecond)
+		require.Equal(t, []int{2}, inner.bulk)
+	})
+}
@@ -0,0 +1,249 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package objectstore uploads the scan results to an object storage like S3 or GCS,
+// as gzip compressed JSON Lines chunks under a prefix per chain and block height.
+//
+// A chunk is uploaded once it reaches MaxChunkBytes or MaxChunkAge, and the last one when the scan concludes.
+// The Uploader puts the objects, e.g. with an S3 PutObject or a GCS ObjectHandle.NewWriter.
+package objectstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/handlers"
+)
+
+// Uploader uploads an object, e.g. a thin wrapper around an S3 PutObject or a GCS object writer.
+type Uploader interface {
+	Upload(ctx context.Context, key string, data []byte) error
+}
+
+type Config struct {
+	// Prefix of the object keys. {chain} is replaced with the ChainID,
+	// and {height} with the block height of the first batch in the chunk.
+	Prefix  string
+	ChainID flow.ChainID
+	// PerAddress if true, a record is written for each address, otherwise for each batch.
+	PerAddress bool
+	// MaxChunkBytes is the uncompressed size after which a chunk is uploaded.
+	MaxChunkBytes int
+	// MaxChunkAge is the time after which a chunk is uploaded, even if it is not full.
+	MaxChunkAge time.Duration
+	// Retries is how often an upload is retried.
+	Retries      int
+	RetryBackoff time.Duration
+}
+
+func DefaultConfig(chainID flow.ChainID) Config {
+	return Config{
+		Prefix:        "scans/{chain}/{height}/",
+		ChainID:       chainID,
+		PerAddress:    true,
+		MaxChunkBytes: 64 * 1024 * 1024,
+		MaxChunkAge:   5 * time.Minute,
+		Retries:       5,
+		RetryBackoff:  time.Second,
+	}
+}
+
+// Handler collects the results in chunks, and uploads each chunk as
+// <prefix>part-00001.json.gz, <prefix>part-00002.json.gz, ...
+// The handler is a Component, so when it is the ScriptResultHandler of a scan
+// the last chunk is uploaded when the scan concludes.
+type Handler struct {
+	*scanner.ComponentBase
+	Config
+
+	uploader Uploader
+
+	mu    sync.Mutex
+	chunk *chunk
+	part  int
+}
+
+var _ scanner.ScriptResultHandler = (*Handler)(nil)
+var _ scanner.Component = (*Handler)(nil)
+
+type chunk struct {
+	buf         bytes.Buffer
+	gz          *gzip.Writer
+	size        int
+	blockHeight uint64
+	created     time.Time
+	part        int
+}
+
+func NewHandler(uploader Uploader, config Config, logger zerolog.Logger) *Handler {
+	h := &Handler{
+		Config:   config,
+		uploader: uploader,
+	}
+	h.ComponentBase = scanner.NewComponentWithStart(
+		"object_store_handler",
+		h.start,
+		logger,
+	)
+	return h
+}
+
+func (h *Handler) start(ctx context.Context) {
+	go func() {
+		var tick <-chan time.Time
+		if h.MaxChunkAge > 0 {
+			ticker := time.NewTicker(h.MaxChunkAge / 10)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				h.Finish(h.Flush())
+				return
+			case <-tick:
+				err := h.uploadIf(func(c *chunk) bool {
+					return time.Since(c.created) >= h.MaxChunkAge
+				})
+				if err != nil {
+					h.Finish(err)
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Flush uploads the current chunk.
+func (h *Handler) Flush() error {
+	return h.uploadIf(func(*chunk) bool { return true })
+}
+
+func (h *Handler) Handle(batch scanner.ProcessedAddressBatch) error {
+	lines, err := h.lines(batch)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	if h.chunk == nil {
+		h.part++
+		h.chunk = &chunk{
+			blockHeight: batch.BlockHeight,
+			created:     time.Now(),
+			part:        h.part,
+		}
+		h.chunk.gz = gzip.NewWriter(&h.chunk.buf)
+	}
+	for _, line := range lines {
+		_, err := h.chunk.gz.Write(line)
+		if err != nil {
+			h.mu.Unlock()
+			return err
+		}
+		h.chunk.size += len(line)
+	}
+	h.mu.Unlock()
+
+	return h.uploadIf(func(c *chunk) bool {
+		return h.MaxChunkBytes > 0 && c.size >= h.MaxChunkBytes
+	})
+}
+
+// uploadIf uploads the current chunk if there is one and the condition is true.
+func (h *Handler) uploadIf(condition func(*chunk) bool) error {
+	h.mu.Lock()
+	c := h.chunk
+	if c == nil || !condition(c) {
+		h.mu.Unlock()
+		return nil
+	}
+	h.chunk = nil
+	h.mu.Unlock()
+
+	err := c.gz.Close()
+	if err != nil {
+		return err
+	}
+	key := h.key(c)
+
+	ctx := context.Background()
+	for attempt := 0; ; attempt++ {
+		err = h.uploader.Upload(ctx, key, c.buf.Bytes())
+		if err == nil {
+			h.Logger.Debug().
+				Str("key", key).
+				Int("size", c.size).
+				Msg("uploaded chunk")
+			return nil
+		}
+		if attempt >= h.Retries {
+			return fmt.Errorf("failed to upload %s: %w", key, err)
+		}
+		h.Logger.Warn().
+			Err(err).
+			Str("key", key).
+			Int("attempt", attempt+1).
+			Msg("failed to upload chunk, retrying")
+		time.Sleep(h.RetryBackoff)
+	}
+}
+
+func (h *Handler) key(c *chunk) string {
+	prefix := strings.NewReplacer(
+		"{chain}", string(h.ChainID),
+		"{height}", strconv.FormatUint(c.blockHeight, 10),
+	).Replace(h.Prefix)
+	return fmt.Sprintf("%spart-%05d.json.gz", prefix, c.part)
+}
+
+func (h *Handler) lines(batch scanner.ProcessedAddressBatch) ([][]byte, error) {
+	var records []interface{}
+	if h.PerAddress {
+		addressRecords, err := handlers.NewAddressRecords(batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range addressRecords {
+			records = append(records, record)
+		}
+	} else {
+		record, err := handlers.NewBatchRecord(batch)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	lines := make([][]byte, len(records))
+	for i, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		lines[i] = append(line, '\n')
+	}
+	return lines, nil
+}
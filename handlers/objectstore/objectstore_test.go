@@ -0,0 +1,78 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objectstore_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/handlers/objectstore"
+)
+
+type memoryUploader struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (u *memoryUploader) Upload(_ context.Context, key string, data []byte) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.objects[key] = data
+	return nil
+}
+
+func TestHandler(t *testing.T) {
+	uploader := &memoryUploader{objects: map[string][]byte{}}
+	config := objectstore.DefaultConfig(flow.Testnet)
+	config.MaxChunkBytes = 100
+	h := objectstore.NewHandler(uploader, config, zerolog.Nop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	<-h.Start(ctx)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, h.Handle(scanner.ProcessedAddressBatch{
+			AddressBatch: scanner.NewAddressBatch([]flow.Address{flow.HexToAddress("0x1")}, 7, nil, nil),
+			Result:       cadence.NewArray([]cadence.Value{cadence.NewInt(i)}),
+		}))
+	}
+	// the first two batches fill the first chunk,
+	// the last chunk is uploaded when the scan concludes
+	cancel()
+	<-h.Done()
+	require.NoError(t, h.Err())
+
+	require.Len(t, uploader.objects, 2)
+	data, ok := uploader.objects["scans/flow-testnet/7/part-00002.json.gz"]
+	require.True(t, ok)
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	content, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, 1, strings.Count(string(content), "\n"))
+	require.Contains(t, string(content), `"address":"0000000000000001"`)
+}
@@ -0,0 +1,122 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package overflow hands the results of a scan to handlers as the values of github.com/bjartek/overflow,
+// instead of converting each result with overflow.CadenceValueToJsonString and decoding the JSON by hand.
+//
+// Value and AddressValues return the values overflow parses results into: maps, slices, strings, numbers and bools,
+// with addresses as "0x" prefixed strings and UFix64 and Fix64 as float64.
+// MarshalAs and the handlers map them into Go values, like overflow's MarshalAs, through their JSON encoding:
+//
+//	handler := overflow.NewHandler(func(balances map[flow.Address]float64, batch scanner.ProcessedAddressBatch) error {
+//		...
+//	})
+//
+// The package is separate from the handlers package, so only scans using it depend on overflow.
+// For results that do not have to be compatible with overflow, scanner.DecodeValue is faster.
+package overflow
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bjartek "github.com/bjartek/overflow"
+	"github.com/onflow/flow-go-sdk"
+
+	scanner "github.com/onflow/flow-batch-scan"
+)
+
+// Value is the result of the batch parsed by overflow.CadenceValueToInterface.
+// Empty strings, arrays, dictionaries and structs, and nil optionals, are nil.
+func Value(batch scanner.ProcessedAddressBatch) interface{} {
+	return bjartek.CadenceValueToInterface(batch.Result)
+}
+
+// AddressValues are the results of each address of the batch parsed by overflow.CadenceValueToInterface,
+// see scanner.ProcessedAddressBatch.PerAddressResults for the results that can be split by address.
+func AddressValues(batch scanner.ProcessedAddressBatch) (map[flow.Address]interface{}, error) {
+	results, err := batch.PerAddressResults()
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[flow.Address]interface{}, len(results))
+	for _, result := range results {
+		values[result.Address] = bjartek.CadenceValueToInterface(result.Value)
+	}
+	return values, nil
+}
+
+// MarshalAs maps the result of the batch into target, like overflow's MarshalAs of a script result.
+func MarshalAs(batch scanner.ProcessedAddressBatch, target interface{}) error {
+	return marshalAs(Value(batch), target)
+}
+
+func marshalAs(value interface{}, target interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+// ResultHandler is a ScriptResultHandler that maps the result of each batch into a T with MarshalAs.
+type ResultHandler[T any] struct {
+	handle func(result T, batch scanner.ProcessedAddressBatch) error
+}
+
+var _ scanner.ScriptResultHandler = (*ResultHandler[any])(nil)
+
+func NewResultHandler[T any](handle func(result T, batch scanner.ProcessedAddressBatch) error) *ResultHandler[T] {
+	return &ResultHandler[T]{
+		handle: handle,
+	}
+}
+
+func (h *ResultHandler[T]) Handle(batch scanner.ProcessedAddressBatch) error {
+	var result T
+	if err := MarshalAs(batch, &result); err != nil {
+		return fmt.Errorf("failed to map the result of the batch at height %d: %w", batch.BlockHeight, err)
+	}
+	return h.handle(result, batch)
+}
+
+// Handler is a ScriptResultHandler that maps the result of each address of a batch into a T.
+// See AddressValues for the results that can be split by address.
+type Handler[T any] struct {
+	handle func(results map[flow.Address]T, batch scanner.ProcessedAddressBatch) error
+}
+
+var _ scanner.ScriptResultHandler = (*Handler[any])(nil)
+
+func NewHandler[T any](handle func(results map[flow.Address]T, batch scanner.ProcessedAddressBatch) error) *Handler[T] {
+	return &Handler[T]{
+		handle: handle,
+	}
+}
+
+func (h *Handler[T]) Handle(batch scanner.ProcessedAddressBatch) error {
+	values, err := AddressValues(batch)
+	if err != nil {
+		return fmt.Errorf("failed to split the result of the batch at height %d: %w", batch.BlockHeight, err)
+	}
+	results := make(map[flow.Address]T, len(values))
+	for address, value := range values {
+		var result T
+		if err := marshalAs(value, &result); err != nil {
+			return fmt.Errorf("failed to map the result of %s at height %d: %w", address.Hex(), batch.BlockHeight, err)
+		}
+		results[address] = result
+	}
+	return h.handle(results, batch)
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package overflow_test
+
+import (
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/handlers/overflow"
+)
+
+var nftType = cadence.NewStructType(nil, "NFT", []cadence.Field{
+	{Identifier: "id", Type: cadence.TheUInt64Type},
+	{Identifier: "name", Type: cadence.TheStringType},
+}, nil)
+
+type nft struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+}
+
+func nftsBatch(a1 flow.Address, a2 flow.Address) scanner.ProcessedAddressBatch {
+	return scanner.ProcessedAddressBatch{
+		AddressBatch: scanner.AddressBatch{Addresses: []flow.Address{a1, a2}, BlockHeight: 10},
+		Result: cadence.NewDictionary([]cadence.KeyValuePair{
+			{Key: cadence.Address(a1), Value: cadence.NewArray([]cadence.Value{
+				cadence.NewStruct([]cadence.Value{cadence.UInt64(1), cadence.String("one")}).WithType(nftType),
+			})},
+			{Key: cadence.Address(a2), Value: cadence.NewArray(nil)},
+		}),
+	}
+}
+
+func TestHandler(t *testing.T) {
+	a1, a2 := flow.HexToAddress("01"), flow.HexToAddress("02")
+
+	var handled map[flow.Address][]nft
+	handler := overflow.NewHandler(func(results map[flow.Address][]nft, _ scanner.ProcessedAddressBatch) error {
+		handled = results
+		return nil
+	})
+	require.NoError(t, handler.Handle(nftsBatch(a1, a2)))
+	require.Equal(t, map[flow.Address][]nft{
+		a1: {{ID: 1, Name: "one"}},
+		// overflow parses empty arrays as nil
+		a2: nil,
+	}, handled)
+
+	values, err := overflow.AddressValues(nftsBatch(a1, a2))
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{map[string]interface{}{"id": uint64(1), "name": "one"}}, values[a1])
+}
+
+func TestResultHandler(t *testing.T) {
+	batch := scanner.ProcessedAddressBatch{
+		Result: cadence.NewDictionary([]cadence.KeyValuePair{
+			{Key: cadence.Address(flow.HexToAddress("01")), Value: cadence.UFix64(150_000_000)},
+		}),
+	}
+
+	var handled map[string]float64
+	handler := overflow.NewResultHandler(func(result map[string]float64, _ scanner.ProcessedAddressBatch) error {
+		handled = result
+		return nil
+	})
+	require.NoError(t, handler.Handle(batch))
+	require.Equal(t, map[string]float64{"0x0000000000000001": 1.5}, handled)
+
+	var wrong []string
+	require.Error(t, overflow.MarshalAs(batch, &wrong))
+}
@@ -0,0 +1,266 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package parquetfile writes the scan results as typed rows to Parquet files.
+//
+// The package does not encode Parquet itself, and does not depend on a Parquet library:
+// the caller brings the RowWriter of their Parquet library, which also infers the schema of the files
+// from the row type, e.g. with github.com/parquet-go/parquet-go:
+//
+//	newWriter := func(w io.Writer) parquetfile.RowWriter[Row] {
+//		return parquet.NewGenericWriter[Row](w)
+//	}
+package parquetfile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/onflow/cadence"
+	"github.com/rs/zerolog"
+
+	scanner "github.com/onflow/flow-batch-scan"
+)
+
+// RowWriter writes rows to a Parquet file. The file is complete once the writer is closed.
+// *parquet.GenericWriter[T] of github.com/parquet-go/parquet-go implements it.
+type RowWriter[T any] interface {
+	Write(rows []T) (int, error)
+	Close() error
+}
+
+type Config struct {
+	// Dir is the directory the files are written to.
+	Dir string
+	// FilePrefix is the prefix of the file names: <prefix>-00001.parquet.
+	// The numbers continue after the files of the prefix that are already in Dir, e.g. of a previous scan.
+	FilePrefix string
+	// MaxRowsPerFile is the number of rows after which a new file is started.
+	MaxRowsPerFile int
+}
+
+func DefaultConfig(dir string) Config {
+	return Config{
+		Dir:            dir,
+		FilePrefix:     "part",
+		MaxRowsPerFile: 1_000_000,
+	}
+}
+
+// Rows maps a batch to rows.
+type Rows[T any] func(batch scanner.ProcessedAddressBatch) ([]T, error)
+
+// Decoded uses the decoder of a TypedResultHandler to map a batch to rows.
+func Decoded[T any](decode func(cadence.Value) ([]T, error)) Rows[T] {
+	return func(batch scanner.ProcessedAddressBatch) ([]T, error) {
+		rows, err := decode(batch.Result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the result of the batch at height %d: %w", batch.BlockHeight, err)
+		}
+		return rows, nil
+	}
+}
+
+// PerAddress maps each address of the batch to a row.
+// See scanner.ProcessedAddressBatch.PerAddressResults.
+func PerAddress[T any](row func(blockHeight uint64, result scanner.AddressResult) (T, error)) Rows[T] {
+	return func(batch scanner.ProcessedAddressBatch) ([]T, error) {
+		results, err := batch.PerAddressResults()
+		if err != nil {
+			return nil, fmt.Errorf("failed to split the result by address: %w", err)
+		}
+		rows := make([]T, 0, len(results))
+		for _, result := range results {
+			r, err := row(batch.BlockHeight, result)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, r)
+		}
+		return rows, nil
+	}
+}
+
+// Handler writes the rows of each batch to Parquet files, starting a new file every MaxRowsPerFile rows.
+// Files are written with a .tmp suffix, and renamed once they are complete,
+// so only complete files are visible to queries.
+// The handler is a Component, so when it is the ScriptResultHandler of a scan
+// the last file is completed when the scan concludes.
+type Handler[T any] struct {
+	*scanner.ComponentBase
+	Config
+
+	rows      Rows[T]
+	newWriter func(w io.Writer) RowWriter[T]
+
+	mu       sync.Mutex
+	file     *os.File
+	writer   RowWriter[T]
+	fileRows int
+	part     int
+	// resumed is true once part continues after the files that were already in Dir.
+	resumed bool
+}
+
+var _ scanner.ScriptResultHandler = (*Handler[any])(nil)
+var _ scanner.Component = (*Handler[any])(nil)
+
+func NewHandler[T any](
+	rows Rows[T],
+	newWriter func(w io.Writer) RowWriter[T],
+	config Config,
+	logger zerolog.Logger,
+) *Handler[T] {
+	h := &Handler[T]{
+		Config:    config,
+		rows:      rows,
+		newWriter: newWriter,
+	}
+	h.ComponentBase = scanner.NewComponentWithStart(
+		"parquet_handler",
+		h.start,
+		logger,
+	)
+	return h
+}
+
+func (h *Handler[T]) start(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		h.Finish(h.Close())
+	}()
+}
+
+func (h *Handler[T]) Handle(batch scanner.ProcessedAddressBatch) error {
+	rows, err := h.rows(batch)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for len(rows) > 0 {
+		if h.writer == nil {
+			err := h.openFile()
+			if err != nil {
+				return err
+			}
+		}
+
+		n := len(rows)
+		if h.MaxRowsPerFile > 0 && h.fileRows+n > h.MaxRowsPerFile {
+			n = h.MaxRowsPerFile - h.fileRows
+		}
+		_, err := h.writer.Write(rows[:n])
+		if err != nil {
+			return err
+		}
+		h.fileRows += n
+		rows = rows[n:]
+
+		if h.MaxRowsPerFile > 0 && h.fileRows >= h.MaxRowsPerFile {
+			err := h.closeFile()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close completes the current file.
+func (h *Handler[T]) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.closeFile()
+}
+
+func (h *Handler[T]) openFile() error {
+	if !h.resumed {
+		part, err := h.lastPart()
+		if err != nil {
+			return err
+		}
+		h.part = part
+		h.resumed = true
+	}
+	h.part++
+	file, err := os.Create(h.path(h.part) + ".tmp")
+	if err != nil {
+		return err
+	}
+	h.file = file
+	h.writer = h.newWriter(file)
+	h.fileRows = 0
+	return nil
+}
+
+func (h *Handler[T]) closeFile() error {
+	if h.writer == nil {
+		return nil
+	}
+	writer, file := h.writer, h.file
+	h.writer, h.file = nil, nil
+
+	err := writer.Close()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	path := h.path(h.part)
+	err = os.Rename(file.Name(), path)
+	if err != nil {
+		return err
+	}
+	h.Logger.Debug().
+		Str("path", path).
+		Int("rows", h.fileRows).
+		Msg("completed file")
+	return nil
+}
+
+// lastPart is the highest number of the files of the prefix in Dir, complete or not, or 0 if there are none.
+func (h *Handler[T]) lastPart() (int, error) {
+	entries, err := os.ReadDir(h.Dir)
+	if err != nil {
+		return 0, err
+	}
+	last := 0
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".tmp")
+		if !strings.HasPrefix(name, h.FilePrefix+"-") || !strings.HasSuffix(name, ".parquet") {
+			continue
+		}
+		part, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, h.FilePrefix+"-"), ".parquet"))
+		if err == nil && part > last {
+			last = part
+		}
+	}
+	return last, nil
+}
+
+func (h *Handler[T]) path(part int) string {
+	return filepath.Join(h.Dir, fmt.Sprintf("%s-%05d.parquet", h.FilePrefix, part))
+}
@@ -0,0 +1,127 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquetfile_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/handlers/parquetfile"
+)
+
+type row struct {
+	Address     string
+	BlockHeight uint64
+}
+
+// jsonRowWriter stands in for a Parquet writer.
+type jsonRowWriter struct {
+	w    io.Writer
+	rows []row
+}
+
+func (w *jsonRowWriter) Write(rows []row) (int, error) {
+	w.rows = append(w.rows, rows...)
+	return len(rows), nil
+}
+
+func (w *jsonRowWriter) Close() error {
+	return json.NewEncoder(w.w).Encode(w.rows)
+}
+
+func TestHandler(t *testing.T) {
+	dir := t.TempDir()
+	config := parquetfile.DefaultConfig(dir)
+	config.MaxRowsPerFile = 2
+
+	h := parquetfile.NewHandler(
+		parquetfile.PerAddress(func(blockHeight uint64, result scanner.AddressResult) (row, error) {
+			return row{Address: result.Address.Hex(), BlockHeight: blockHeight}, nil
+		}),
+		func(w io.Writer) parquetfile.RowWriter[row] {
+			return &jsonRowWriter{w: w}
+		},
+		config,
+		zerolog.Nop(),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	<-h.Start(ctx)
+
+	addresses := []flow.Address{flow.HexToAddress("0x1"), flow.HexToAddress("0x2"), flow.HexToAddress("0x3")}
+	require.NoError(t, h.Handle(scanner.ProcessedAddressBatch{
+		AddressBatch: scanner.NewAddressBatch(addresses, 9, nil, nil),
+		Result: cadence.NewArray([]cadence.Value{
+			cadence.NewInt(1),
+			cadence.NewInt(2),
+			cadence.NewInt(3),
+		}),
+	}))
+
+	// the first file is complete, the second one is still being written
+	_, err := os.Stat(filepath.Join(dir, "part-00001.parquet"))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "part-00002.parquet.tmp"))
+	require.NoError(t, err)
+
+	cancel()
+	<-h.Done()
+	require.NoError(t, h.Err())
+
+	data, err := os.ReadFile(filepath.Join(dir, "part-00002.parquet"))
+	require.NoError(t, err)
+	var rows []row
+	require.NoError(t, json.Unmarshal(data, &rows))
+	require.Equal(t, []row{{Address: addresses[2].Hex(), BlockHeight: 9}}, rows)
+}
+
+func TestHandler_ContinuesNumbering(t *testing.T) {
+	dir := t.TempDir()
+	// the files of a previous scan, and of another prefix
+	for _, name := range []string{"part-00001.parquet", "part-00007.parquet", "other-00009.parquet"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("[]"), 0o644))
+	}
+
+	h := parquetfile.NewHandler(
+		parquetfile.PerAddress(func(blockHeight uint64, result scanner.AddressResult) (row, error) {
+			return row{Address: result.Address.Hex(), BlockHeight: blockHeight}, nil
+		}),
+		func(w io.Writer) parquetfile.RowWriter[row] {
+			return &jsonRowWriter{w: w}
+		},
+		parquetfile.DefaultConfig(dir),
+		zerolog.Nop(),
+	)
+	require.NoError(t, h.Handle(scanner.ProcessedAddressBatch{
+		AddressBatch: scanner.NewAddressBatch([]flow.Address{flow.HexToAddress("0x1")}, 9, nil, nil),
+		Result:       cadence.NewArray([]cadence.Value{cadence.NewInt(1)}),
+	}))
+	require.NoError(t, h.Close())
+
+	_, err := os.Stat(filepath.Join(dir, "part-00008.parquet"))
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, "part-00007.parquet"))
+	require.NoError(t, err)
+	require.Equal(t, "[]", string(data), "the files of the previous scan are not overwritten")
+}
@@ -0,0 +1,228 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package postgres upserts the scan results into a PostgreSQL table.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+	"github.com/rs/zerolog"
+
+	scanner "github.com/onflow/flow-batch-scan"
+)
+
+type Config struct {
+	// Table is the name of the results table.
+	Table string
+	// CreateTable if true, the results table is created if it does not exist.
+	CreateTable bool
+	// MaxRowsPerStatement is the maximum number of rows upserted with one statement.
+	// The rows of one batch are upserted in a single transaction.
+	MaxRowsPerStatement int
+	// Retries is how often a batch is retried after a connection error.
+	// The connection pool reconnects before the retry.
+	Retries      int
+	RetryBackoff time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Table:               "scan_results",
+		CreateTable:         true,
+		MaxRowsPerStatement: 1000,
+		Retries:             5,
+		RetryBackoff:        time.Second,
+	}
+}
+
+// Handler upserts the result of each address of a batch into the results table,
+// keyed by (address, block_height, script_name). The result is stored as JSON-Cadence in a jsonb column.
+// See ProcessedAddressBatch.PerAddressResults.
+type Handler struct {
+	Config
+
+	db     *sql.DB
+	logger zerolog.Logger
+}
+
+var _ scanner.ScriptResultHandler = (*Handler)(nil)
+
+// Open connects to the database with the given connection string (see github.com/lib/pq).
+func Open(ctx context.Context, dsn string, config Config, logger zerolog.Logger) (*Handler, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	h, err := NewHandler(ctx, db, config, logger)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// NewHandler creates a handler for an already opened database.
+func NewHandler(ctx context.Context, db *sql.DB, config Config, logger zerolog.Logger) (*Handler, error) {
+	if config.MaxRowsPerStatement <= 0 {
+		config.MaxRowsPerStatement = DefaultConfig().MaxRowsPerStatement
+	}
+	h := &Handler{
+		Config: config,
+		db:     db,
+		logger: logger.With().Str("component", "postgres_handler").Logger(),
+	}
+	if config.CreateTable {
+		err := h.withRetries(ctx, func() error {
+			_, err := db.ExecContext(ctx, createTableStatement(config.Table))
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create table %s: %w", config.Table, err)
+		}
+	}
+	return h, nil
+}
+
+func (h *Handler) Close() error {
+	return h.db.Close()
+}
+
+func (h *Handler) Handle(batch scanner.ProcessedAddressBatch) error {
+	results, err := batch.PerAddressResults()
+	if err != nil {
+		return fmt.Errorf("failed to split the result by address: %w", err)
+	}
+
+	rows := make([]row, len(results))
+	for i, result := range results {
+		encoded, err := jsoncdc.Encode(result.Value)
+		if err != nil {
+			return err
+		}
+		rows[i] = row{
+			address:     result.Address.Hex(),
+			blockHeight: batch.BlockHeight,
+			scriptName:  batch.ScriptName,
+			result:      string(encoded),
+		}
+	}
+
+	ctx := context.Background()
+	return h.withRetries(ctx, func() error {
+		return h.upsert(ctx, rows)
+	})
+}
+
+type row struct {
+	address     string
+	blockHeight uint64
+	scriptName  string
+	result      string
+}
+
+func (h *Handler) upsert(ctx context.Context, rows []row) error {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(rows); start += h.MaxRowsPerStatement {
+		end := start + h.MaxRowsPerStatement
+		if end > len(rows) {
+			end = len(rows)
+		}
+		statement, args := upsertStatement(h.Table, rows[start:end])
+		_, err := tx.ExecContext(ctx, statement, args...)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// withRetries retries f after connection errors.
+func (h *Handler) withRetries(ctx context.Context, f func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = f()
+		if err == nil || !isConnectionError(err) || attempt >= h.Retries {
+			return err
+		}
+		h.logger.Warn().
+			Err(err).
+			Int("attempt", attempt+1).
+			Msg("connection error, retrying")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(h.RetryBackoff):
+		}
+	}
+}
+
+func createTableStatement(table string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	address      text   NOT NULL,
+	block_height bigint NOT NULL,
+	script_name  text   NOT NULL DEFAULT '',
+	result       jsonb  NOT NULL,
+	PRIMARY KEY (address, block_height, script_name)
+)`, pq.QuoteIdentifier(table))
+}
+
+func upsertStatement(table string, rows []row) (string, []interface{}) {
+	var b strings.Builder
+	args := make([]interface{}, 0, len(rows)*4)
+
+	fmt.Fprintf(&b, "INSERT INTO %s (address, block_height, script_name, result) VALUES ", pq.QuoteIdentifier(table))
+	for i, r := range rows {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		n := len(args)
+		fmt.Fprintf(&b, "($%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4)
+		args = append(args, r.address, r.blockHeight, r.scriptName, r.result)
+	}
+	b.WriteString(" ON CONFLICT (address, block_height, script_name) DO UPDATE SET result = EXCLUDED.result")
+	return b.String(), args
+}
+
+// isConnectionError is true for errors after which a new connection might succeed.
+func isConnectionError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// connection exceptions, and the server shutting down
+		return pqErr.Code.Class() == "08" || pqErr.Code.Class() == "57"
+	}
+	return false
+}
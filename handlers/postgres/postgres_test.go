@@ -0,0 +1,46 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpsertStatement(t *testing.T) {
+	statement, args := upsertStatement("results", []row{
+		{address: "01", blockHeight: 1, scriptName: "", result: "{}"},
+		{address: "02", blockHeight: 1, scriptName: "", result: "[]"},
+	})
+
+	require.Equal(t,
+		`INSERT INTO "results" (address, block_height, script_name, result) `+
+			`VALUES ($1, $2, $3, $4), ($5, $6, $7, $8) `+
+			`ON CONFLICT (address, block_height, script_name) DO UPDATE SET result = EXCLUDED.result`,
+		statement)
+	require.Len(t, args, 8)
+	require.Equal(t, "02", args[4])
+}
+
+func TestIsConnectionError(t *testing.T) {
+	require.True(t, isConnectionError(fmt.Errorf("wrapped: %w", driver.ErrBadConn)))
+	require.True(t, isConnectionError(&pq.Error{Code: "08006"}))
+	require.False(t, isConnectionError(&pq.Error{Code: "23505"}))
+	require.False(t, isConnectionError(fmt.Errorf("failed")))
+}
@@ -0,0 +1,276 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconciliation
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/onflow/flow-go-sdk"
+)
+
+// Format is the format of the exported balances file.
+type Format int
+
+const (
+	// FormatJSONLines writes a Rosetta AccountBalanceResponse per line, with the account identifier.
+	FormatJSONLines Format = iota
+	// FormatCSV writes a CSV file with a header and a row per account.
+	FormatCSV
+)
+
+// ManifestFileName is the name of the manifest written by Export.
+const ManifestFileName = "manifest.json"
+
+// ManifestVersion is the version of the manifest format.
+const ManifestVersion = 1
+
+func (f Format) fileName() string {
+	if f == FormatCSV {
+		return "balances.csv"
+	}
+	return "balances.jsonl"
+}
+
+// AccountIdentifier is the Rosetta account_identifier.
+type AccountIdentifier struct {
+	Address string `json:"address"`
+}
+
+// BlockIdentifier is the Rosetta block_identifier.
+type BlockIdentifier struct {
+	Index uint64 `json:"index"`
+	Hash  string `json:"hash"`
+}
+
+// Amount is the Rosetta amount, the value is an integer in the smallest unit of the currency.
+type Amount struct {
+	Value    string   `json:"value"`
+	Currency Currency `json:"currency"`
+}
+
+// Record is the JSON line written for each account by FormatJSONLines.
+type Record struct {
+	AccountIdentifier AccountIdentifier `json:"account_identifier"`
+	BlockIdentifier   BlockIdentifier   `json:"block_identifier"`
+	Balances          []Amount          `json:"balances"`
+}
+
+var csvHeader = []string{"address", "symbol", "decimals", "value", "block_index", "block_hash"}
+
+// Manifest describes an export, with the checksums of its files.
+type Manifest struct {
+	Version  int      `json:"version"`
+	Currency Currency `json:"currency"`
+	Accounts int      `json:"accounts"`
+	// Total is the sum of all balances, in the smallest unit of the currency.
+	Total string `json:"total"`
+	// BlockIdentifier is the block all balances are at, it is empty if they are at different blocks.
+	BlockIdentifier *BlockIdentifier `json:"block_identifier,omitempty"`
+	Files           []ManifestFile   `json:"files"`
+}
+
+// ManifestFile is a file of an export.
+type ManifestFile struct {
+	Name    string `json:"name"`
+	SHA256  string `json:"sha256"`
+	Bytes   int64  `json:"bytes"`
+	Records int    `json:"records"`
+}
+
+// Export writes the balances to dir in the format, ordered by address, and writes a manifest next to them.
+// The amounts are UFix64 values, so the currency must have 8 decimals.
+// An address must have only one balance.
+func Export(dir string, balances []Balance, currency Currency, format Format) (Manifest, error) {
+	if currency.Decimals != FLOW.Decimals {
+		return Manifest{}, fmt.Errorf("currency %s has %d decimals, UFix64 amounts have %d", currency.Symbol, currency.Decimals, FLOW.Decimals)
+	}
+	if format != FormatJSONLines && format != FormatCSV {
+		return Manifest{}, fmt.Errorf("unknown format %d", format)
+	}
+
+	sorted := append([]Balance{}, balances...)
+	sortBalances(sorted)
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Address == sorted[i-1].Address {
+			return Manifest{}, fmt.Errorf("duplicate balance of address %s", "0x"+sorted[i].Address.Hex())
+		}
+	}
+
+	manifest := Manifest{
+		Version:  ManifestVersion,
+		Currency: currency,
+		Accounts: len(sorted),
+	}
+
+	total := new(big.Int)
+	for i, balance := range sorted {
+		total.Add(total, new(big.Int).SetUint64(uint64(balance.Amount)))
+		block := blockIdentifier(balance)
+		if i == 0 {
+			manifest.BlockIdentifier = &block
+		} else if manifest.BlockIdentifier != nil && *manifest.BlockIdentifier != block {
+			manifest.BlockIdentifier = nil
+		}
+	}
+	manifest.Total = total.String()
+
+	file, err := writeFile(dir, format.fileName(), func(w io.Writer) error {
+		if format == FormatCSV {
+			return writeCSV(w, sorted, currency)
+		}
+		return writeJSONLines(w, sorted, currency)
+	})
+	if err != nil {
+		return Manifest{}, err
+	}
+	file.Records = len(sorted)
+	manifest.Files = []ManifestFile{file}
+
+	_, err = writeFile(dir, ManifestFileName, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(manifest)
+	})
+	if err != nil {
+		return Manifest{}, err
+	}
+	return manifest, nil
+}
+
+// VerifyManifest reads the manifest of an export in dir and checks the size and checksum of its files.
+func VerifyManifest(dir string) (Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFileName))
+	if err != nil {
+		return Manifest{}, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("failed to decode the manifest: %w", err)
+	}
+	if manifest.Version != ManifestVersion {
+		return Manifest{}, fmt.Errorf("unsupported manifest version %d", manifest.Version)
+	}
+
+	for _, expected := range manifest.Files {
+		f, err := os.Open(filepath.Join(dir, expected.Name))
+		if err != nil {
+			return Manifest{}, err
+		}
+		hash := sha256.New()
+		n, err := io.Copy(hash, f)
+		_ = f.Close()
+		if err != nil {
+			return Manifest{}, fmt.Errorf("failed to read %s: %w", expected.Name, err)
+		}
+		if n != expected.Bytes {
+			return Manifest{}, fmt.Errorf("%s has %d bytes, expected %d", expected.Name, n, expected.Bytes)
+		}
+		if sum := hex.EncodeToString(hash.Sum(nil)); sum != expected.SHA256 {
+			return Manifest{}, fmt.Errorf("%s has checksum %s, expected %s", expected.Name, sum, expected.SHA256)
+		}
+	}
+	return manifest, nil
+}
+
+func blockIdentifier(balance Balance) BlockIdentifier {
+	block := BlockIdentifier{Index: balance.BlockHeight}
+	if balance.BlockID != flow.EmptyID {
+		block.Hash = balance.BlockID.Hex()
+	}
+	return block
+}
+
+func writeJSONLines(w io.Writer, balances []Balance, currency Currency) error {
+	encoder := json.NewEncoder(w)
+	for _, balance := range balances {
+		err := encoder.Encode(Record{
+			AccountIdentifier: AccountIdentifier{Address: "0x" + balance.Address.Hex()},
+			BlockIdentifier:   blockIdentifier(balance),
+			Balances: []Amount{{
+				Value:    strconv.FormatUint(uint64(balance.Amount), 10),
+				Currency: currency,
+			}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCSV(w io.Writer, balances []Balance, currency Currency) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, balance := range balances {
+		block := blockIdentifier(balance)
+		err := writer.Write([]string{
+			"0x" + balance.Address.Hex(),
+			currency.Symbol,
+			strconv.Itoa(currency.Decimals),
+			strconv.FormatUint(uint64(balance.Amount), 10),
+			strconv.FormatUint(block.Index, 10),
+			block.Hash,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeFile writes a file in dir and returns its size and checksum.
+func writeFile(dir string, name string, write func(w io.Writer) error) (ManifestFile, error) {
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(f, hash)}
+	err = write(counter)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return ManifestFile{}, fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return ManifestFile{
+		Name:   name,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+		Bytes:  counter.n,
+	}, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,119 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reconciliation exports the results of balance scans in a Rosetta style format,
+// as consumed by exchanges and auditors to reconcile the balances of accounts.
+//
+// A Collector is the ScriptResultHandler of a scan that returns a {Address: UFix64} dictionary,
+// like scripts.FlowBalances or the AccountBalanceMode. After the scan concluded, Export writes the
+// collected balances, ordered by address, together with a manifest of the SHA-256 checksums of the files:
+//
+//	collector := reconciliation.NewCollector()
+//	s, err := scanner.NewScanner(flowClient, scanner.WithScript(scripts.FlowBalances), scanner.WithScriptResultHandler(collector))
+//	...
+//	manifest, err := reconciliation.Export(dir, collector.Balances(), reconciliation.FLOW, reconciliation.FormatJSONLines)
+//
+// The export is deterministic, the same balances always result in the same files and checksums.
+// VerifyManifest checks the files of an export against its manifest.
+package reconciliation
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/scripts"
+)
+
+// Currency is the currency of the balances, with the number of decimals of its smallest unit.
+type Currency struct {
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+}
+
+// FLOW is the currency of FLOW balances, a UFix64 has 8 decimals.
+var FLOW = Currency{Symbol: "FLOW", Decimals: 8}
+
+// Balance is the balance of an account at a block.
+type Balance struct {
+	Address     flow.Address
+	Amount      cadence.UFix64
+	BlockHeight uint64
+	BlockID     flow.Identifier
+}
+
+// Collector collects the balances of a balance scan.
+// If an address is scanned more than once, the balance at the highest block is kept.
+type Collector struct {
+	mu       sync.Mutex
+	balances map[flow.Address]Balance
+}
+
+var _ scanner.ScriptResultHandler = (*Collector)(nil)
+
+func NewCollector() *Collector {
+	return &Collector{
+		balances: map[flow.Address]Balance{},
+	}
+}
+
+func (c *Collector) Handle(batch scanner.ProcessedAddressBatch) error {
+	amounts, err := scripts.DecodeFlowBalances(batch.Result)
+	if err != nil {
+		return fmt.Errorf("failed to decode balances: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for address, amount := range amounts {
+		if existing, ok := c.balances[address]; ok && existing.BlockHeight > batch.BlockHeight {
+			continue
+		}
+		c.balances[address] = Balance{
+			Address:     address,
+			Amount:      amount,
+			BlockHeight: batch.BlockHeight,
+			BlockID:     batch.BlockID,
+		}
+	}
+	return nil
+}
+
+// Balances returns the collected balances, ordered by address.
+func (c *Collector) Balances() []Balance {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	balances := make([]Balance, 0, len(c.balances))
+	for _, balance := range c.balances {
+		balances = append(balances, balance)
+	}
+	sortBalances(balances)
+	return balances
+}
+
+func sortBalances(balances []Balance) {
+	sort.Slice(balances, func(i, j int) bool {
+		a, b := balances[i].Address, balances[j].Address
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return balances[i].BlockHeight < balances[j].BlockHeight
+	})
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reconciliation_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/handlers/reconciliation"
+)
+
+func balancesBatch(height uint64, balances map[flow.Address]uint64) scanner.ProcessedAddressBatch {
+	var pairs []cadence.KeyValuePair
+	for address, amount := range balances {
+		pairs = append(pairs, cadence.KeyValuePair{Key: cadence.Address(address), Value: cadence.UFix64(amount)})
+	}
+	return scanner.ProcessedAddressBatch{
+		AddressBatch: scanner.AddressBatch{BlockHeight: height},
+		Result:       cadence.NewDictionary(pairs),
+		BlockID:      flow.Identifier{byte(height)},
+	}
+}
+
+func TestCollector(t *testing.T) {
+	a1, a2 := flow.HexToAddress("02"), flow.HexToAddress("01")
+	collector := reconciliation.NewCollector()
+	require.NoError(t, collector.Handle(balancesBatch(10, map[flow.Address]uint64{a1: 100, a2: 200})))
+	require.NoError(t, collector.Handle(balancesBatch(12, map[flow.Address]uint64{a1: 150})))
+	require.NoError(t, collector.Handle(balancesBatch(11, map[flow.Address]uint64{a1: 120})))
+
+	balances := collector.Balances()
+	require.Len(t, balances, 2)
+	require.Equal(t, a2, balances[0].Address)
+	require.Equal(t, cadence.UFix64(200), balances[0].Amount)
+	require.Equal(t, a1, balances[1].Address)
+	require.Equal(t, cadence.UFix64(150), balances[1].Amount)
+	require.Equal(t, uint64(12), balances[1].BlockHeight)
+
+	require.Error(t, collector.Handle(scanner.ProcessedAddressBatch{Result: cadence.String("x")}))
+}
+
+func TestExport(t *testing.T) {
+	id := flow.Identifier{1}
+	balances := []reconciliation.Balance{
+		{Address: flow.HexToAddress("02"), Amount: 5_00000000, BlockHeight: 10, BlockID: id},
+		{Address: flow.HexToAddress("01"), Amount: 1, BlockHeight: 10, BlockID: id},
+	}
+
+	t.Run("json lines", func(t *testing.T) {
+		dir := t.TempDir()
+		manifest, err := reconciliation.Export(dir, balances, reconciliation.FLOW, reconciliation.FormatJSONLines)
+		require.NoError(t, err)
+		require.Equal(t, 2, manifest.Accounts)
+		require.Equal(t, "500000001", manifest.Total)
+		require.Equal(t, &reconciliation.BlockIdentifier{Index: 10, Hash: id.Hex()}, manifest.BlockIdentifier)
+		require.Len(t, manifest.Files, 1)
+		require.Equal(t, 2, manifest.Files[0].Records)
+
+		data, err := os.ReadFile(filepath.Join(dir, "balances.jsonl"))
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		require.Len(t, lines, 2)
+		require.JSONEq(t, `{
+			"account_identifier": {"address": "0x0000000000000001"},
+			"block_identifier": {"index": 10, "hash": "`+id.Hex()+`"},
+			"balances": [{"value": "1", "currency": {"symbol": "FLOW", "decimals": 8}}]
+		}`, lines[0])
+
+		verified, err := reconciliation.VerifyManifest(dir)
+		require.NoError(t, err)
+		require.Equal(t, manifest, verified)
+
+		// the export is deterministic
+		other := t.TempDir()
+		reversed := []reconciliation.Balance{balances[1], balances[0]}
+		otherManifest, err := reconciliation.Export(other, reversed, reconciliation.FLOW, reconciliation.FormatJSONLines)
+		require.NoError(t, err)
+		require.Equal(t, manifest, otherManifest)
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "balances.jsonl"), append(data[:len(data)-2], '0', '\n'), 0o644))
+		_, err = reconciliation.VerifyManifest(dir)
+		require.ErrorContains(t, err, "checksum")
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		dir := t.TempDir()
+		mixed := append([]reconciliation.Balance{}, balances...)
+		mixed[0].BlockHeight = 11
+		manifest, err := reconciliation.Export(dir, mixed, reconciliation.FLOW, reconciliation.FormatCSV)
+		require.NoError(t, err)
+		require.Nil(t, manifest.BlockIdentifier)
+
+		data, err := os.ReadFile(filepath.Join(dir, "balances.csv"))
+		require.NoError(t, err)
+		require.Equal(t, "address,symbol,decimals,value,block_index,block_hash\n"+
+			"0x0000000000000001,FLOW,8,1,10,"+id.Hex()+"\n"+
+			"0x0000000000000002,FLOW,8,500000000,11,"+id.Hex()+"\n", string(data))
+
+		_, err = reconciliation.VerifyManifest(dir)
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := reconciliation.Export(t.TempDir(), append(balances, balances[0]), reconciliation.FLOW, reconciliation.FormatCSV)
+		require.ErrorContains(t, err, "duplicate")
+
+		_, err = reconciliation.Export(t.TempDir(), balances, reconciliation.Currency{Symbol: "USDC", Decimals: 6}, reconciliation.FormatCSV)
+		require.Error(t, err)
+	})
+}
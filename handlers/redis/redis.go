@@ -0,0 +1,134 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redis writes the scan results to redis, in a hash per address.
+// Use it with the redis stores of the progress package to share the state of a scan without a database.
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/handlers"
+)
+
+// updateResult sets the fields of the hash, unless it already has the result of a later block.
+// Results can be handled out of order, e.g. a retried batch after the batch of the next incremental scan.
+var updateResult = goredis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'block_height')
+if current and tonumber(current) > tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'block_height', ARGV[1], 'block_id', ARGV[2], 'result', ARGV[3])
+if tonumber(ARGV[4]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[4])
+end
+return 1
+`)
+
+type Config struct {
+	// KeyPrefix is the prefix of the keys of the hashes, see Handler.Key.
+	KeyPrefix string
+	// TTL expires the hash of an address if it is not updated for the ttl. The hashes do not expire if it is 0.
+	TTL time.Duration
+	// Retries is how often the results of a batch are written again, after a redis error.
+	Retries      int
+	RetryBackoff time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		KeyPrefix:    "flow-batch-scan:results:",
+		Retries:      5,
+		RetryBackoff: time.Second,
+	}
+}
+
+// Handler writes the result of each address to a hash with the fields block_height, block_id and result.
+// The result is encoded as JSON-Cadence. A hash is only updated with the result of the same or a later block.
+type Handler struct {
+	Config
+
+	client goredis.UniversalClient
+	logger zerolog.Logger
+}
+
+var _ scanner.ScriptResultHandler = (*Handler)(nil)
+
+func NewHandler(client goredis.UniversalClient, config Config, logger zerolog.Logger) *Handler {
+	return &Handler{
+		Config: config,
+		client: client,
+		logger: logger.With().Str("component", "redis_handler").Logger(),
+	}
+}
+
+// Key is the key of the hash of the address: <prefix><address> for the scan script,
+// and <prefix><script name>:<address> for the other scripts.
+func (h *Handler) Key(address flow.Address, scriptName string) string {
+	if scriptName == "" {
+		return h.KeyPrefix + address.Hex()
+	}
+	return h.KeyPrefix + scriptName + ":" + address.Hex()
+}
+
+func (h *Handler) Handle(batch scanner.ProcessedAddressBatch) error {
+	records, err := handlers.NewAddressRecords(batch)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	for attempt := 0; ; attempt++ {
+		err = h.write(ctx, records)
+		if err == nil || attempt >= h.Retries {
+			return err
+		}
+		h.logger.Warn().
+			Err(err).
+			Int("attempt", attempt+1).
+			Msg("failed to write results, retrying")
+		time.Sleep(h.RetryBackoff)
+	}
+}
+
+// write writes the records in one pipeline. The updates are idempotent, so a failed pipeline can be retried.
+func (h *Handler) write(ctx context.Context, records []handlers.AddressRecord) error {
+	pipe := h.client.Pipeline()
+	for _, record := range records {
+		updateResult.Eval(
+			ctx,
+			pipe,
+			[]string{h.Key(record.Address, record.ScriptName)},
+			record.BlockHeight,
+			record.BlockID,
+			string(record.Result),
+			h.TTL.Milliseconds(),
+		)
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to write %d results to redis: %w", len(records), err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/handlers/redis"
+)
+
+func TestHandler(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	config := redis.DefaultConfig()
+	config.TTL = time.Hour
+	h := redis.NewHandler(client, config, zerolog.Nop())
+
+	a1 := flow.HexToAddress("0x1")
+	batch := func(height uint64, value int) scanner.ProcessedAddressBatch {
+		return scanner.ProcessedAddressBatch{
+			AddressBatch: scanner.NewAddressBatch([]flow.Address{a1}, height, nil, nil),
+			Result:       cadence.NewArray([]cadence.Value{cadence.NewInt(value)}),
+		}
+	}
+	result := func() map[string]string {
+		fields, err := client.HGetAll(context.Background(), h.Key(a1, "")).Result()
+		require.NoError(t, err)
+		return fields
+	}
+
+	require.NoError(t, h.Handle(batch(7, 1)))
+	require.Equal(t, "7", result()["block_height"])
+	require.JSONEq(t, `{"type":"Int","value":"1"}`, result()["result"])
+	require.Equal(t, time.Hour, server.TTL(h.Key(a1, "")))
+
+	// the result of an earlier block does not overwrite the hash
+	require.NoError(t, h.Handle(batch(5, 2)))
+	require.JSONEq(t, `{"type":"Int","value":"1"}`, result()["result"])
+
+	require.NoError(t, h.Handle(batch(9, 3)))
+	require.Equal(t, "9", result()["block_height"])
+	require.JSONEq(t, `{"type":"Int","value":"3"}`, result()["result"])
+}
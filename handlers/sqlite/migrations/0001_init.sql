@@ -0,0 +1,23 @@
+-- results contains the latest result of each address (and script).
+CREATE TABLE results (
+    address      TEXT    NOT NULL,
+    script_name  TEXT    NOT NULL DEFAULT '',
+    block_height INTEGER NOT NULL,
+    -- result is encoded as JSON-Cadence
+    result       TEXT    NOT NULL,
+    PRIMARY KEY (address, script_name)
+);
+
+-- scan_state has a single row with the state of the scan.
+CREATE TABLE scan_state (
+    id                            INTEGER PRIMARY KEY CHECK (id = 1),
+    reference_block_height        INTEGER NOT NULL DEFAULT 0,
+    latest_scanned_block_height   INTEGER NOT NULL DEFAULT 0,
+    complete                      INTEGER NOT NULL DEFAULT 0,
+    progress_height               INTEGER,
+    checkpoint_address_index      INTEGER,
+    checkpoint_incremental_height INTEGER,
+    updated_at                    TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO scan_state (id) VALUES (1);
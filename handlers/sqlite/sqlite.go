@@ -0,0 +1,333 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqlite stores the scan results and the scan state in a local SQLite database.
+// The DB is a ScriptResultHandler, a ProgressStore and a CheckpointStore,
+// so a scanner configured with Configure can be restarted without losing its progress.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	_ "modernc.org/sqlite"
+
+	scanner "github.com/onflow/flow-batch-scan"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// ScanState is the state of the scan stored in the database.
+type ScanState struct {
+	// ReferenceBlockHeight is the reference block height of the latest full scan.
+	ReferenceBlockHeight uint64
+	// LatestScannedBlockHeight is the block height the results are up-to-date at, once the scan concluded.
+	LatestScannedBlockHeight uint64
+	// Complete is true if the latest full scan scanned all the addresses.
+	Complete bool
+}
+
+type DB struct {
+	db     *sql.DB
+	logger zerolog.Logger
+}
+
+var _ scanner.ScriptResultHandler = (*DB)(nil)
+var _ scanner.ProgressStore = (*DB)(nil)
+var _ scanner.CheckpointStore = (*DB)(nil)
+
+// Open opens (or creates) the database at path, and migrates it to the latest schema.
+func Open(path string, logger zerolog.Logger) (*DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite only supports a single writer
+	db.SetMaxOpenConns(1)
+
+	d := &DB{
+		db:     db,
+		logger: logger.With().Str("component", "sqlite").Logger(),
+	}
+	err = d.migrate(context.Background())
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// Configure sets the DB as the result handler, progress store and checkpoint store of the config,
+// and records the full scan state using the hooks. Hooks that were already configured are still called.
+func (d *DB) Configure(config scanner.Config) scanner.Config {
+	onFullScanStarted := config.Hooks.OnFullScanStarted
+	onFullScanCompleted := config.Hooks.OnFullScanCompleted
+
+	return config.
+		WithScriptResultHandler(d).
+		WithProgressStore(d).
+		WithResumeFromProgress(true).
+		WithResumeFromCheckpoint(d).
+		WithOnFullScanStarted(func(referenceBlockHeight uint64) {
+			err := d.SaveFullScanStarted(context.Background(), referenceBlockHeight)
+			if err != nil {
+				d.logger.Error().Err(err).Msg("failed to save full scan start")
+			}
+			if onFullScanStarted != nil {
+				onFullScanStarted(referenceBlockHeight)
+			}
+		}).
+		WithOnFullScanCompleted(func(stats scanner.FullScanStats) {
+			err := d.SaveFullScanCompleted(context.Background(), !stats.StoppedAtLimit)
+			if err != nil {
+				d.logger.Error().Err(err).Msg("failed to save full scan completion")
+			}
+			if onFullScanCompleted != nil {
+				onFullScanCompleted(stats)
+			}
+		})
+}
+
+// migrate applies the migrations that were not applied yet. The schema version is the user_version pragma.
+func (d *DB) migrate(ctx context.Context) error {
+	entries, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	var version int
+	err = d.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	for i := version; i < len(entries); i++ {
+		migration, err := migrations.ReadFile("migrations/" + entries[i].Name())
+		if err != nil {
+			return err
+		}
+		tx, err := d.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, string(migration))
+		if err == nil {
+			_, err = tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1))
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration %s failed: %w", entries[i].Name(), err)
+		}
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+		d.logger.Info().Str("migration", entries[i].Name()).Msg("applied migration")
+	}
+	return nil
+}
+
+// Handle stores the result of each address of the batch, see ProcessedAddressBatch.PerAddressResults.
+// Results from lower block heights do not overwrite newer results.
+func (d *DB) Handle(batch scanner.ProcessedAddressBatch) error {
+	results, err := batch.PerAddressResults()
+	if err != nil {
+		return fmt.Errorf("failed to split the result by address: %w", err)
+	}
+
+	ctx := context.Background()
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		encoded, err := jsoncdc.Encode(result.Value)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO results (address, script_name, block_height, result)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (address, script_name) DO UPDATE
+			SET block_height = excluded.block_height, result = excluded.result
+			WHERE excluded.block_height >= results.block_height`,
+			result.Address.Hex(),
+			batch.ScriptName,
+			batch.BlockHeight,
+			string(encoded),
+		)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Result returns the stored result of the address, and the block height it was scanned at.
+// scriptName is empty if the scan has a single script.
+func (d *DB) Result(
+	ctx context.Context,
+	address flow.Address,
+	scriptName string,
+) (value cadence.Value, blockHeight uint64, ok bool, err error) {
+	var encoded string
+	err = d.db.QueryRowContext(ctx,
+		"SELECT block_height, result FROM results WHERE address = ? AND script_name = ?",
+		address.Hex(),
+		scriptName,
+	).Scan(&blockHeight, &encoded)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, 0, false, err
+	}
+	value, err = jsoncdc.Decode(nil, []byte(encoded))
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return value, blockHeight, true, nil
+}
+
+// Results passes the stored result of each address of the script to fn, with the block height it was scanned at.
+// scriptName is empty if the scan has a single script. Iteration stops at the first error of fn.
+func (d *DB) Results(
+	ctx context.Context,
+	scriptName string,
+	fn func(result scanner.AddressResult, blockHeight uint64) error,
+) error {
+	rows, err := d.db.QueryContext(ctx,
+		"SELECT address, block_height, result FROM results WHERE script_name = ?",
+		scriptName,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var address, encoded string
+		var blockHeight uint64
+		err = rows.Scan(&address, &blockHeight, &encoded)
+		if err != nil {
+			return err
+		}
+		value, err := jsoncdc.Decode(nil, []byte(encoded))
+		if err != nil {
+			return err
+		}
+		err = fn(scanner.AddressResult{Address: flow.HexToAddress(address), Value: value}, blockHeight)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ScanState returns the stored state of the scan.
+func (d *DB) ScanState(ctx context.Context) (ScanState, error) {
+	var state ScanState
+	err := d.db.QueryRowContext(ctx, `
+		SELECT reference_block_height, latest_scanned_block_height, complete
+		FROM scan_state WHERE id = 1`,
+	).Scan(&state.ReferenceBlockHeight, &state.LatestScannedBlockHeight, &state.Complete)
+	return state, err
+}
+
+// SaveFullScanStarted stores the reference block height of a full scan that started.
+func (d *DB) SaveFullScanStarted(ctx context.Context, referenceBlockHeight uint64) error {
+	return d.updateState(ctx,
+		"reference_block_height = ?, complete = 0",
+		referenceBlockHeight)
+}
+
+// SaveFullScanCompleted stores if the full scan scanned all the addresses.
+func (d *DB) SaveFullScanCompleted(ctx context.Context, complete bool) error {
+	return d.updateState(ctx, "complete = ?", complete)
+}
+
+// SaveScanConcluded stores the result of a concluded scan.
+func (d *DB) SaveScanConcluded(ctx context.Context, concluded scanner.ScanConcluded) error {
+	return d.updateState(ctx,
+		"latest_scanned_block_height = ?, complete = ?",
+		concluded.LatestScannedBlockHeight,
+		concluded.ScanIsComplete)
+}
+
+func (d *DB) LoadProgress(ctx context.Context) (uint64, bool, error) {
+	var height sql.NullInt64
+	err := d.db.QueryRowContext(ctx, "SELECT progress_height FROM scan_state WHERE id = 1").Scan(&height)
+	if err != nil {
+		return 0, false, err
+	}
+	return uint64(height.Int64), height.Valid, nil
+}
+
+func (d *DB) SaveProgress(ctx context.Context, height uint64) error {
+	return d.updateState(ctx, "progress_height = ?", height)
+}
+
+func (d *DB) LoadCheckpoint(ctx context.Context) (scanner.FullScanCheckpoint, bool, error) {
+	var addressIndex, incrementalHeight sql.NullInt64
+	err := d.db.QueryRowContext(ctx, `
+		SELECT checkpoint_address_index, checkpoint_incremental_height
+		FROM scan_state WHERE id = 1`,
+	).Scan(&addressIndex, &incrementalHeight)
+	if err != nil {
+		return scanner.FullScanCheckpoint{}, false, err
+	}
+	if !addressIndex.Valid {
+		return scanner.FullScanCheckpoint{}, false, nil
+	}
+	return scanner.FullScanCheckpoint{
+		AddressIndex:      uint(addressIndex.Int64),
+		IncrementalHeight: uint64(incrementalHeight.Int64),
+	}, true, nil
+}
+
+func (d *DB) SaveCheckpoint(ctx context.Context, checkpoint scanner.FullScanCheckpoint) error {
+	return d.updateState(ctx,
+		"checkpoint_address_index = ?, checkpoint_incremental_height = ?",
+		checkpoint.AddressIndex,
+		checkpoint.IncrementalHeight)
+}
+
+func (d *DB) ClearCheckpoint(ctx context.Context) error {
+	return d.updateState(ctx, "checkpoint_address_index = NULL, checkpoint_incremental_height = NULL")
+}
+
+func (d *DB) updateState(ctx context.Context, set string, args ...interface{}) error {
+	_, err := d.db.ExecContext(ctx,
+		"UPDATE scan_state SET "+set+", updated_at = CURRENT_TIMESTAMP WHERE id = 1",
+		args...)
+	return err
+}
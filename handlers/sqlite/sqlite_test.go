@@ -0,0 +1,109 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/handlers/sqlite"
+)
+
+func TestDB(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "scan.db")
+
+	db, err := sqlite.Open(path, zerolog.Nop())
+	require.NoError(t, err)
+
+	a1 := flow.HexToAddress("0x1")
+	handle := func(height uint64, value cadence.Value) {
+		batch := scanner.ProcessedAddressBatch{
+			AddressBatch: scanner.NewAddressBatch(nil, height, nil, nil),
+			Result: cadence.NewDictionary([]cadence.KeyValuePair{
+				{Key: cadence.NewAddress(a1), Value: value},
+			}),
+		}
+		require.NoError(t, db.Handle(batch))
+	}
+
+	t.Run("older results do not overwrite newer results", func(t *testing.T) {
+		handle(10, cadence.NewInt(10))
+		handle(9, cadence.NewInt(9))
+
+		value, height, ok, err := db.Result(ctx, a1, "")
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, uint64(10), height)
+		require.Equal(t, cadence.NewInt(10), value)
+	})
+
+	t.Run("results", func(t *testing.T) {
+		heights := map[flow.Address]uint64{}
+		err := db.Results(ctx, "", func(result scanner.AddressResult, blockHeight uint64) error {
+			heights[result.Address] = blockHeight
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[flow.Address]uint64{a1: 10}, heights)
+	})
+
+	t.Run("scan state", func(t *testing.T) {
+		_, ok, err := db.LoadProgress(ctx)
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		require.NoError(t, db.SaveProgress(ctx, 42))
+		require.NoError(t, db.SaveCheckpoint(ctx, scanner.FullScanCheckpoint{AddressIndex: 3, IncrementalHeight: 40}))
+		require.NoError(t, db.SaveFullScanStarted(ctx, 30))
+	})
+
+	require.NoError(t, db.Close())
+
+	t.Run("state survives a restart", func(t *testing.T) {
+		db, err := sqlite.Open(path, zerolog.Nop())
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, db.Close())
+		}()
+
+		height, ok, err := db.LoadProgress(ctx)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, uint64(42), height)
+
+		checkpoint, ok, err := db.LoadCheckpoint(ctx)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, uint(3), checkpoint.AddressIndex)
+
+		require.NoError(t, db.ClearCheckpoint(ctx))
+		_, ok, err = db.LoadCheckpoint(ctx)
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		state, err := db.ScanState(ctx)
+		require.NoError(t, err)
+		require.Equal(t, uint64(30), state.ReferenceBlockHeight)
+		require.False(t, state.Complete)
+	})
+}
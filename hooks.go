@@ -0,0 +1,99 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"time"
+)
+
+// ScanHooks are called when the scan reaches certain phases.
+// They can be used to emit metrics or trigger downstream jobs.
+// Hooks are called synchronously, so they should return quickly.
+type ScanHooks struct {
+	// OnFullScanStarted is called when a full scan starts at the given reference block height.
+	OnFullScanStarted func(referenceBlockHeight uint64)
+	// OnFullScanCompleted is called when a full scan handled all the addresses.
+	// It is not called for full scans that were cancelled or failed.
+	OnFullScanCompleted func(stats FullScanStats)
+	// OnBatchProcessed is called when the result of a batch was handled by the ScriptResultHandler.
+	// duration is the time it took to run the script and handle the result.
+	OnBatchProcessed func(batch ProcessedAddressBatch, duration time.Duration)
+	// OnIncrementalRangeScanned is called when the incremental scanner found the candidates in a block range.
+	// start and end are inclusive. The candidates might not be scanned yet.
+	OnIncrementalRangeScanned func(start uint64, end uint64)
+	// OnStall is called when a pipeline stage (IncrementalScannerStage or FullScanStage)
+	// made no progress for the WatchdogStallTimeout. since is the time since its last progress.
+	OnStall func(stage string, since time.Duration)
+	// OnScanConcluded is called when the scan concluded, with the error of the scan if it failed.
+	// It is also called before the process exits because a full scan failed.
+	// With a Schedule it is called for every run.
+	OnScanConcluded func(concluded ScanConcluded, err error)
+	// OnScheduledRunSkipped is called when a run of the Schedule was skipped,
+	// because the previous run was still in progress. scheduled is the time the run was scheduled at.
+	OnScheduledRunSkipped func(scheduled time.Time)
+}
+
+// FullScanStats describe a completed full scan.
+type FullScanStats struct {
+	// ReferenceBlockHeight is the height the full scan started at.
+	ReferenceBlockHeight uint64
+	Addresses            uint64
+	Batches              uint64
+	Duration             time.Duration
+	// StoppedAtLimit is true if the full scan stopped at MaxAddresses or MaxBatches.
+	StoppedAtLimit bool
+}
+
+func (h ScanHooks) fullScanStarted(referenceBlockHeight uint64) {
+	if h.OnFullScanStarted != nil {
+		h.OnFullScanStarted(referenceBlockHeight)
+	}
+}
+
+func (h ScanHooks) fullScanCompleted(stats FullScanStats) {
+	if h.OnFullScanCompleted != nil {
+		h.OnFullScanCompleted(stats)
+	}
+}
+
+func (h ScanHooks) batchProcessed(batch ProcessedAddressBatch, duration time.Duration) {
+	if h.OnBatchProcessed != nil {
+		h.OnBatchProcessed(batch, duration)
+	}
+}
+
+func (h ScanHooks) incrementalRangeScanned(start uint64, end uint64) {
+	if h.OnIncrementalRangeScanned != nil {
+		h.OnIncrementalRangeScanned(start, end)
+	}
+}
+
+func (h ScanHooks) stalled(stage string, since time.Duration) {
+	if h.OnStall != nil {
+		h.OnStall(stage, since)
+	}
+}
+
+func (h ScanHooks) scanConcluded(concluded ScanConcluded, err error) {
+	if h.OnScanConcluded != nil {
+		h.OnScanConcluded(concluded, err)
+	}
+}
+
+func (h ScanHooks) scheduledRunSkipped(scheduled time.Time) {
+	if h.OnScheduledRunSkipped != nil {
+		h.OnScheduledRunSkipped(scheduled)
+	}
+}
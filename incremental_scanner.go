@@ -21,8 +21,11 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/onflow/cadence"
 	"github.com/onflow/flow-go-sdk"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/onflow/flow-batch-scan/candidates"
 	"github.com/onflow/flow-batch-scan/client"
@@ -30,6 +33,11 @@ import (
 
 const DefaultIncrementalScannerBlockLag = 5
 
+const DefaultCatchUpConcurrency = 4
+
+// DefaultIncrementalScanInterval is how often the incremental scanner checks for new blocks.
+const DefaultIncrementalScanInterval = 2 * time.Second
+
 // DefaultIncrementalScannerMaxBlockGap is the maximum number of blocks that can scanned by the incremental scanner.
 // If the gap is larger than this, the incremental scanner will request a full scan.
 const DefaultIncrementalScannerMaxBlockGap = 100
@@ -39,11 +47,87 @@ type IncrementalScannerConfig struct {
 	// IncrementalScannerBlockLag is the number of blocks the incremental scanner lag behind the latest block from
 	// GetLatestBlockHeader. This is to avoid most of the "retry for collection in finalized block" errors.
 	// Another way to avoid them is to always use the same access node.
+	// If only streaming candidate scanners (e.g. candidates.StreamingEventCandidatesScanner) are used,
+	// this can be 0.
 	IncrementalScannerBlockLag uint64
 
+	// FollowFinalized makes the incremental scanner follow the latest finalized block instead of
+	// the latest sealed block. This lowers the latency, but the execution results of the newest blocks
+	// might not be available yet, so more requests will be retried.
+	// The full scan always uses sealed blocks.
+	FollowFinalized bool
+
 	// IncrementalScannerMaxBlockGap is the maximum number of blocks that can scanned by the incremental scanner.
 	// If the gap is larger than this, the incremental scanner will skip ahead and request a full scan.
 	IncrementalScannerMaxBlockGap uint64
+
+	// IncrementalStartHeight if set, the incremental scanner starts after this height instead of requesting
+	// a full scan, and backfills all the blocks up to the latest block.
+	// This is the height at which the caller already has complete results.
+	IncrementalStartHeight uint64
+	// IncrementalOnly disables full scans. The incremental scanner starts after IncrementalStartHeight.
+	// If the incremental scanner falls behind more than IncrementalScannerMaxBlockGap blocks,
+	// it catches up (like a backfill) instead of requesting a full scan.
+	IncrementalOnly bool
+	// BackfillBlockRange is the number of blocks scanned at once while backfilling.
+	// If 0, IncrementalScannerMaxBlockGap is used.
+	BackfillBlockRange uint64
+	// BackfillInterval is the minimum time between two block ranges while backfilling,
+	// to limit the load on the access node.
+	BackfillInterval time.Duration
+
+	// CatchUpBlockRange if set, the block ranges larger than this are split into sub-ranges of this size,
+	// which are scanned for candidates concurrently. This helps the incremental scanner catch up
+	// when it falls behind, before IncrementalScannerMaxBlockGap is reached.
+	// The candidates of the sub-ranges are merged in block order, and scanned at the end of the block range.
+	// Streaming candidate scanners need consecutive block ranges, so they should not be used with this.
+	CatchUpBlockRange uint64
+	// CatchUpConcurrency is the maximum number of sub-ranges scanned concurrently.
+	CatchUpConcurrency int
+
+	// ProgressStore if set, the latest handled block height is saved to it after every scan interval.
+	ProgressStore ProgressStore
+	// ResumeFromProgress starts the incremental scanner after the height in the ProgressStore,
+	// instead of doing a full scan. If the ProgressStore is empty a full scan is done.
+	ResumeFromProgress bool
+
+	// OnFullScanRequest if set, is called before the incremental scanner requests a full scan.
+	// If it returns false, the full scan is vetoed and the incremental scanner catches up instead.
+	OnFullScanRequest func(request FullScanRequest) bool
+
+	// IncrementalScannerMode selects how the incremental scanner follows new blocks.
+	IncrementalScannerMode IncrementalScannerMode
+	// SubscriptionEvents are the events the incremental scanner subscribes to in IncrementalScannerSubscription mode,
+	// with a function to get the candidates from each event type.
+	SubscriptionEvents map[string]func(event cadence.Event) ([]flow.Address, error)
+
+	// BackpressurePolicy decides what happens when the address batch queue is full.
+	BackpressurePolicy BackpressurePolicy
+
+	// IncrementalScanInterval is how often the incremental scanner checks for new blocks.
+	IncrementalScanInterval time.Duration
+
+	// CandidateCoalesceBlocks is the number of blocks candidates are collected for before they are scanned.
+	// An address that is a candidate multiple times within this window is only scanned once.
+	// 0 means candidates are scanned as soon as they are found.
+	CandidateCoalesceBlocks uint64
+	// CandidateCoalesceDuration is like CandidateCoalesceBlocks, but time based.
+	// If both are set, candidates are scanned when either of them is reached.
+	CandidateCoalesceDuration time.Duration
+
+	// CandidateDebounceBlocks is the number of blocks after an address was scanned,
+	// during which it is not scanned again. If it is a candidate again during this cool-down,
+	// it is held back and scanned when the cool-down has elapsed.
+	// This avoids scanning very active accounts on every block range.
+	CandidateDebounceBlocks uint64
+	// CandidateDebounceDuration is like CandidateDebounceBlocks, but time based.
+	// If both are set, the cool-down ends when either of them is reached.
+	CandidateDebounceDuration time.Duration
+
+	// EventDedupSize if set, is the number of events (by transaction ID and event index) remembered
+	// to drop the candidates of events that were already handled, e.g. when a block range is scanned again
+	// after a retry or a failover to another access node.
+	EventDedupSize int
 }
 
 func DefaultIncrementalScannerConfig() IncrementalScannerConfig {
@@ -51,9 +135,32 @@ func DefaultIncrementalScannerConfig() IncrementalScannerConfig {
 		CandidateScanners:             []candidates.CandidateScanner{},
 		IncrementalScannerBlockLag:    DefaultIncrementalScannerBlockLag,
 		IncrementalScannerMaxBlockGap: DefaultIncrementalScannerMaxBlockGap,
+		IncrementalScanInterval:       DefaultIncrementalScanInterval,
+		CatchUpConcurrency:            DefaultCatchUpConcurrency,
 	}
 }
 
+// FullScanReason is why the incremental scanner requested a full scan.
+type FullScanReason string
+
+const (
+	// FullScanReasonBlockGap means the incremental scanner fell behind more than IncrementalScannerMaxBlockGap blocks.
+	FullScanReasonBlockGap FullScanReason = "block_gap"
+	// FullScanReasonBackpressure means candidates were dropped because the address batch queue was full.
+	FullScanReasonBackpressure FullScanReason = "backpressure"
+	// FullScanReasonInterval means a periodic full scan is due, see FullScanInterval and FullScanBlockInterval.
+	FullScanReasonInterval FullScanReason = "interval"
+)
+
+// FullScanRequest describes a full scan the incremental scanner is about to request.
+type FullScanRequest struct {
+	Reason FullScanReason
+	// Height is the block height the full scan would start at.
+	Height uint64
+	// BlocksBehind is how many blocks the incremental scanner is behind Height.
+	BlocksBehind uint64
+}
+
 type IncrementalScanner struct {
 	*ComponentBase
 	IncrementalScannerConfig
@@ -67,6 +174,25 @@ type IncrementalScanner struct {
 	latestBlock             uint64
 	latestHandledBlock      atomic.Uint64
 	pendingIncrementalScans atomic.Int32
+	coalescer               *candidateCoalescer
+	debouncer               *candidateDebouncer
+	deduplicator            *eventDeduplicator
+	flushRequests           chan chan struct{}
+	savedProgress           uint64
+	backfilling             bool
+	overflow                *overflowBuffer
+	// addressFilter removes candidates before they are batched.
+	addressFilter *addressFilter
+	// pause stops the scanning of new blocks while the scan is paused.
+	pause     *pauseGate
+	wasPaused bool
+	hooks     ScanHooks
+	stats     *statsCollector
+	tracer    trace.Tracer
+	// batchSizeController adapts the batch size, if the batch size is adaptive.
+	batchSizeController *batchSizeController
+	// scanInterval if set, is the IncrementalScanInterval that can be changed while the scan is running.
+	scanInterval func() time.Duration
 
 	reporter StatusReporter
 }
@@ -93,10 +219,28 @@ func NewIncrementalScanner(
 		pendingIncrementalScans:  atomic.Int32{},
 		batchSize:                batchSize,
 		IncrementalScannerConfig: config,
+		coalescer: newCandidateCoalescer(
+			config.CandidateCoalesceBlocks,
+			config.CandidateCoalesceDuration,
+		),
+		debouncer: newCandidateDebouncer(
+			config.CandidateDebounceBlocks,
+			config.CandidateDebounceDuration,
+		),
+		deduplicator:  newEventDeduplicator(config.EventDedupSize),
+		overflow:      newOverflowBuffer(),
+		flushRequests: make(chan chan struct{}),
 
 		reporter: reporter,
 	}
 
+	if config.IncrementalOnly || config.IncrementalStartHeight > 0 {
+		r.latestBlock = config.IncrementalStartHeight
+		r.latestHandledBlock.Store(config.IncrementalStartHeight)
+		r.backfilling = true
+		r.savedProgress = config.IncrementalStartHeight
+	}
+
 	r.ComponentBase = NewComponentWithStart(
 		"incremental_scanner",
 		r.run,
@@ -106,6 +250,15 @@ func NewIncrementalScanner(
 }
 
 func (r *IncrementalScanner) run(ctx context.Context) {
+	if r.BackpressurePolicy == BackpressureSpill {
+		go r.overflow.drain(ctx, r.addressBatchChan)
+	}
+
+	if r.IncrementalScannerMode == IncrementalScannerSubscription {
+		go r.runSubscription(ctx)
+		return
+	}
+
 	go func() {
 		next := time.After(0)
 		for {
@@ -114,18 +267,38 @@ func (r *IncrementalScanner) run(ctx context.Context) {
 				r.Finish(ctx.Err())
 				return
 			case <-next:
-				next = time.After(2 * time.Second)
+				next = time.After(r.interval())
+				if r.pause.isPaused() {
+					r.wasPaused = true
+					continue
+				}
+				if r.wasPaused {
+					// catch up on the blocks missed while paused, instead of requesting a full scan
+					r.wasPaused = false
+					r.backfilling = r.latestBlock > 0
+				}
 				err := r.scanNewBlocks(ctx)
 				if err != nil {
 					r.Finish(err)
 				}
+				r.saveProgress(ctx)
+			case done := <-r.flushRequests:
+				r.flush(done)
 			}
 		}
 	}()
 }
 
+// interval is how long to wait before checking for new blocks again.
+func (r *IncrementalScanner) interval() time.Duration {
+	if r.scanInterval != nil {
+		return r.scanInterval()
+	}
+	return r.IncrementalScanInterval
+}
+
 func (r *IncrementalScanner) scanNewBlocks(ctx context.Context) error {
-	header, err := r.client.GetLatestBlockHeader(ctx, true)
+	header, err := r.client.GetLatestBlockHeader(ctx, !r.FollowFinalized)
 	if err != nil {
 		return err
 	}
@@ -137,15 +310,42 @@ func (r *IncrementalScanner) scanNewBlocks(ctx context.Context) error {
 
 	r.reporter.ReportIncrementalBlockDiff(height - r.latestBlock)
 
+	if r.backfilling || r.IncrementalOnly {
+		err = r.backfill(ctx, height)
+		if err != nil {
+			return err
+		}
+		r.backfilling = false
+		if height <= r.latestBlock {
+			return nil
+		}
+	}
+
 	if height-r.latestBlock > r.IncrementalScannerMaxBlockGap {
-		r.Logger.Info().
-			Uint64("latest_block", r.latestBlock).
-			Uint64("current_block", height).
-			Uint64("diff", height-r.latestBlock).
-			Msg("skipping blocks and requesting batch")
-		r.latestBlock = height
-		r.requestFullScan <- r.latestBlock
-		return nil
+		// the first full scan is not a fallback, so it can not be vetoed
+		allowed := r.latestBlock == 0 || r.allowFullScan(FullScanRequest{
+			Reason:       FullScanReasonBlockGap,
+			Height:       height,
+			BlocksBehind: height - r.latestBlock,
+		})
+		if allowed {
+			r.Logger.Info().
+				Uint64("latest_block", r.latestBlock).
+				Uint64("current_block", height).
+				Uint64("diff", height-r.latestBlock).
+				Msg("skipping blocks and requesting batch")
+			r.latestBlock = height
+			r.requestFullScan <- r.latestBlock
+			return nil
+		}
+
+		err = r.backfill(ctx, height)
+		if err != nil {
+			return err
+		}
+		if height <= r.latestBlock {
+			return nil
+		}
 	}
 
 	r.Logger.Info().
@@ -159,20 +359,109 @@ func (r *IncrementalScanner) scanNewBlocks(ctx context.Context) error {
 	return err
 }
 
+// backfill scans the blocks up to height in block ranges of BackfillBlockRange blocks,
+// until the remaining gap can be scanned in one block range.
+func (r *IncrementalScanner) backfill(ctx context.Context, height uint64) error {
+	blockRange := r.BackfillBlockRange
+	if blockRange == 0 {
+		blockRange = r.IncrementalScannerMaxBlockGap
+	}
+
+	for height-r.latestBlock > r.IncrementalScannerMaxBlockGap {
+		end := r.latestBlock + blockRange
+		if end > height {
+			end = height
+		}
+		r.Logger.Info().
+			Uint64("start", r.latestBlock+1).
+			Uint64("end", end).
+			Uint64("diff", height-r.latestBlock).
+			Msg("backfilling block range")
+
+		next := time.After(r.BackfillInterval)
+		err := r.scanBlockRange(ctx, r.latestBlock+1, end)
+		if err != nil {
+			return err
+		}
+		r.latestBlock = end
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-next:
+		}
+	}
+	return nil
+}
+
 // scanBlockRange scans a range of blocks for any candidates for which a script should be run.
 // start and end are inclusive.
-func (r *IncrementalScanner) scanBlockRange(ctx context.Context, start uint64, end uint64) error {
+func (r *IncrementalScanner) scanBlockRange(ctx context.Context, start uint64, end uint64) (err error) {
+	ctx, span := startSpan(ctx, r.tracer, "scan_block_range",
+		attribute.Int64("start_height", int64(start)),
+		attribute.Int64("end_height", int64(end)),
+	)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	scanStart := time.Now()
 	candidatesResult := r.runBlockCandidateScanners(ctx, start, end)
 	if candidatesResult.Err() != nil {
-		return candidatesResult.Err()
+		return newBlockRangeError(start, end, candidatesResult.Err())
+	}
+	r.stats.candidatesFound(candidatesResult, time.Since(scanStart))
+	span.SetAttributes(attribute.Int("candidates", len(candidatesResult.Addresses)))
+
+	r.handleCandidates(ctx, candidatesResult, start, end)
+	return nil
+}
+
+// handleCandidates queues the candidates found in the block range,
+// unless they are held back by the coalescing or debounce windows.
+// The batches are traced as part of the span of ctx.
+func (r *IncrementalScanner) handleCandidates(
+	ctx context.Context,
+	candidatesResult candidates.CandidatesResult,
+	start uint64,
+	end uint64,
+) {
+	now := time.Now()
+	candidatesResult = r.deduplicator.filterCandidates(candidatesResult)
+	candidatesResult = r.addressFilter.filterCandidates(candidatesResult)
+	r.hooks.incrementalRangeScanned(start, end)
+	coalesced := r.coalescer.add(candidatesResult, start, now)
+	r.reporter.ReportCandidates(len(candidatesResult.Addresses), coalesced)
+	if !r.coalescer.ready(end, now) {
+		// the block range is not handled until the pending candidates are scanned
+		return
 	}
+	candidatesResult = r.coalescer.flush()
+	candidatesResult = r.debouncer.filter(candidatesResult, start, end, now)
+
+	r.queueCandidates(ctx, candidatesResult, start, end, r.debouncer.handledHeight(end))
+}
+
+// queueCandidates sends the candidates to be scanned at the end height.
+// Once they are scanned, the handled height is stored as the latest handled block.
+// The returned channel is closed when all the candidates are scanned.
+func (r *IncrementalScanner) queueCandidates(
+	ctx context.Context,
+	candidatesResult candidates.CandidatesResult,
+	start uint64,
+	end uint64,
+	handledHeight uint64,
+) <-chan struct{} {
+	done := make(chan struct{})
 
 	if len(candidatesResult.Addresses) == 0 {
 		if r.pendingIncrementalScans.Load() == 0 {
-			r.latestHandledBlock.Store(end)
-			r.reporter.ReportIncrementalBlockHeight(end)
+			r.latestHandledBlock.Store(handledHeight)
+			r.Heartbeat()
+			r.reporter.ReportIncrementalBlockHeight(handledHeight)
 		}
-		return nil
+		close(done)
+		return done
 	}
 
 	addresses := make([]flow.Address, 0, len(candidatesResult.Addresses))
@@ -189,14 +478,15 @@ func (r *IncrementalScanner) scanBlockRange(ctx context.Context, start uint64, e
 
 	wg := sync.WaitGroup{}
 	r.pendingIncrementalScans.Add(1)
-	for i := 0; i < len(addresses); i += r.batchSize {
+	batchSize := r.batchSizeController.size(r.batchSize)
+	for i := 0; i < len(addresses); i += batchSize {
 		startIndex := i
-		endIndex := i + r.batchSize
+		endIndex := i + batchSize
 		if endIndex > len(addresses) {
 			endIndex = len(addresses)
 		}
 		wg.Add(1)
-		r.addressBatchChan <- NewAddressBatch(
+		batch := NewAddressBatch(
 			addresses[startIndex:endIndex],
 			end,
 			func() {
@@ -204,29 +494,159 @@ func (r *IncrementalScanner) scanBlockRange(ctx context.Context, start uint64, e
 			},
 			nil,
 		)
+		batch.Provenance = provenanceOf(batch.Addresses, candidatesResult.Provenance)
+		batch.spanContext = trace.SpanContextFromContext(ctx)
+		if r.sendBatch(batch) {
+			continue
+		}
+		allowed := r.allowFullScan(FullScanRequest{
+			Reason:       FullScanReasonBackpressure,
+			Height:       end,
+			BlocksBehind: end - r.latestHandledBlock.Load(),
+		})
+		if !allowed {
+			// wait for room in the queue instead
+			r.addressBatchChan <- batch
+			continue
+		}
+		wg.Done()
+		r.Logger.Warn().
+			Uint64("start", start).
+			Uint64("end", end).
+			Int("dropped", len(addresses)-startIndex).
+			Msg("address batch queue is full, dropping candidates and requesting full scan")
+		r.requestFullScan <- end
+		break
 	}
 
 	go func() {
 		wg.Wait()
 		r.pendingIncrementalScans.Add(-1)
-		r.latestHandledBlock.Store(end)
-		r.reporter.ReportIncrementalBlockHeight(end)
+		r.latestHandledBlock.Store(handledHeight)
+		r.Heartbeat()
+		r.reporter.ReportIncrementalBlockHeight(handledHeight)
+		close(done)
 	}()
 
-	return nil
+	return done
+}
+
+// Flush scans all the candidates that are held back by the coalescing and debounce windows,
+// and waits until they are scanned. It is used before shutting down.
+func (r *IncrementalScanner) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.Done():
+		return r.Err()
+	case r.flushRequests <- done:
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+		return nil
+	}
+}
+
+func (r *IncrementalScanner) flush(done chan<- struct{}) {
+	candidatesResult := r.coalescer.flush()
+	candidatesResult.MergeWith(r.debouncer.flush())
+
+	r.Logger.Info().
+		Int("count", len(candidatesResult.Addresses)).
+		Msg("flushing held back candidates")
+
+	handled := r.queueCandidates(context.Background(), candidatesResult, r.latestBlock, r.latestBlock, r.latestBlock)
+	go func() {
+		<-handled
+		close(done)
+	}()
+}
+
+// allowFullScan asks OnFullScanRequest if the full scan can be requested.
+func (r *IncrementalScanner) allowFullScan(request FullScanRequest) bool {
+	if r.OnFullScanRequest == nil {
+		return true
+	}
+	allowed := r.OnFullScanRequest(request)
+	if !allowed {
+		r.Logger.Info().
+			Str("reason", string(request.Reason)).
+			Uint64("height", request.Height).
+			Uint64("blocks_behind", request.BlocksBehind).
+			Msg("full scan vetoed")
+	}
+	return allowed
+}
+
+// sendBatch sends the batch to the script runner according to the BackpressurePolicy.
+// It returns false if the batch was dropped.
+func (r *IncrementalScanner) sendBatch(batch AddressBatch) bool {
+	switch r.BackpressurePolicy {
+	case BackpressureDropAndRequestFullScan:
+		select {
+		case r.addressBatchChan <- batch:
+			return true
+		default:
+			return false
+		}
+	case BackpressureSpill:
+		// keep the batches in order, once batches are spilled
+		if r.overflow.len() > 0 {
+			r.overflow.push(batch)
+			return true
+		}
+		select {
+		case r.addressBatchChan <- batch:
+		default:
+			r.overflow.push(batch)
+		}
+		return true
+	default:
+		r.addressBatchChan <- batch
+		return true
+	}
+}
+
+// OverflowLen is the number of address batches waiting in the overflow buffer.
+func (r *IncrementalScanner) OverflowLen() int {
+	return r.overflow.len()
 }
 
 func (r *IncrementalScanner) runBlockCandidateScanners(ctx context.Context, start uint64, end uint64) candidates.CandidatesResult {
-	results := make(chan candidates.CandidatesResult, len(r.CandidateScanners))
-	defer close(results)
+	return candidates.ScanChunks(
+		candidates.BlockRange{Start: start, End: end},
+		r.CatchUpBlockRange,
+		r.CatchUpConcurrency,
+		func(blocks candidates.BlockRange) candidates.CandidatesResult {
+			return candidates.CandidateScanners(r.CandidateScanners).Scan(ctx, r.client, blocks)
+		},
+	)
+}
 
-	for _, scanner := range r.CandidateScanners {
-		go func(scanner candidates.CandidateScanner) {
-			results <- scanner.Scan(ctx, r.client, candidates.BlockRange{Start: start, End: end})
-		}(scanner)
+// saveProgress saves the latest handled block to the ProgressStore, if it changed.
+// Failing to save is not fatal, the progress is saved again after the next scan interval.
+func (r *IncrementalScanner) saveProgress(ctx context.Context) {
+	if r.ProgressStore == nil {
+		return
+	}
+	height := r.latestHandledBlock.Load()
+	if height <= r.savedProgress {
+		return
 	}
 
-	return candidates.WaitForCandidateResults(results, len(r.CandidateScanners))
+	err := r.ProgressStore.SaveProgress(ctx, height)
+	if err != nil {
+		r.Logger.Warn().
+			Err(err).
+			Uint64("height", height).
+			Msg("could not save progress")
+		return
+	}
+	r.savedProgress = height
 }
 
 func (r *IncrementalScanner) LatestHandledBlock() uint64 {
@@ -0,0 +1,479 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+)
+
+// blockCandidatesScanner finds the address of each block height as a candidate, and records the scanned block ranges.
+// The scans of the later block ranges finish first.
+type blockCandidatesScanner struct {
+	mu     sync.Mutex
+	ranges []candidates.BlockRange
+}
+
+func (s *blockCandidatesScanner) Scan(_ context.Context, _ client.Client, blocks candidates.BlockRange) candidates.CandidatesResult {
+	s.mu.Lock()
+	s.ranges = append(s.ranges, blocks)
+	s.mu.Unlock()
+	time.Sleep(time.Duration(20-blocks.Start) * time.Millisecond)
+
+	result := candidates.NewCandidatesResult(make(map[flow.Address]struct{}))
+	for height := blocks.Start; height <= blocks.End; height++ {
+		result.Add(flow.HexToAddress("0x1"), candidates.Provenance{Scanner: "blocks", BlockHeight: height})
+	}
+	return result
+}
+
+func TestIncrementalScanner_CatchUp(t *testing.T) {
+	blockScanner := &blockCandidatesScanner{}
+	config := DefaultIncrementalScannerConfig()
+	config.CandidateScanners = []candidates.CandidateScanner{blockScanner}
+	config.CatchUpBlockRange = 3
+	config.CatchUpConcurrency = 2
+
+	batches := make(chan AddressBatch, 1)
+	r := NewIncrementalScanner(
+		clienttest.New(),
+		batches,
+		make(chan uint64),
+		100,
+		config,
+		NoOpStatusReporter{},
+		zerolog.Nop(),
+	)
+
+	require.NoError(t, r.scanBlockRange(context.Background(), 1, 10))
+
+	// every block is scanned once, in sub-ranges of CatchUpBlockRange blocks
+	require.ElementsMatch(t, []candidates.BlockRange{
+		{Start: 1, End: 3},
+		{Start: 4, End: 6},
+		{Start: 7, End: 9},
+		{Start: 10, End: 10},
+	}, blockScanner.ranges)
+
+	// the candidates of all sub-ranges are scanned at the end of the block range,
+	// with their provenance in block order
+	batch := <-batches
+	require.Equal(t, uint64(10), batch.BlockHeight)
+	require.Equal(t, []flow.Address{flow.HexToAddress("0x1")}, batch.Addresses)
+	provenance := batch.Provenance[flow.HexToAddress("0x1")]
+	require.Len(t, provenance, 10)
+	for i, p := range provenance {
+		require.Equal(t, uint64(i+1), p.BlockHeight)
+	}
+
+	// the block range is handled once the batch is done
+	batch.DoneHandling()
+	require.Eventually(t, func() bool {
+		return r.LatestHandledBlock() == 10
+	}, time.Second, time.Millisecond)
+}
+
+func TestIncrementalScanner_Backpressure(t *testing.T) {
+	addresses := []flow.Address{
+		flow.HexToAddress("0x1"),
+		flow.HexToAddress("0x2"),
+		flow.HexToAddress("0x3"),
+	}
+	newScanner := func(policy BackpressurePolicy) (*IncrementalScanner, chan AddressBatch, chan uint64) {
+		config := DefaultIncrementalScannerConfig()
+		config.CandidateScanners = []candidates.CandidateScanner{
+			candidates.NewCallbackCandidatesScanner(func(context.Context, candidates.BlockRange) ([]flow.Address, error) {
+				return addresses, nil
+			}),
+		}
+		config.BackpressurePolicy = policy
+
+		batches := make(chan AddressBatch, 1)
+		fullScans := make(chan uint64, 1)
+		r := NewIncrementalScanner(
+			clienttest.New(),
+			batches,
+			fullScans,
+			1,
+			config,
+			NoOpStatusReporter{},
+			zerolog.Nop(),
+		)
+		return r, batches, fullScans
+	}
+
+	t.Run("drop and request full scan", func(t *testing.T) {
+		r, batches, fullScans := newScanner(BackpressureDropAndRequestFullScan)
+		require.NoError(t, r.scanBlockRange(context.Background(), 1, 10))
+
+		require.Len(t, batches, 1)
+		require.Equal(t, uint64(10), <-fullScans)
+
+		// the block range is handled once the queued batch is done
+		batch := <-batches
+		batch.DoneHandling()
+		require.Eventually(t, func() bool {
+			return r.LatestHandledBlock() == 10
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("spill", func(t *testing.T) {
+		r, batches, fullScans := newScanner(BackpressureSpill)
+		require.NoError(t, r.scanBlockRange(context.Background(), 1, 10))
+
+		require.Len(t, batches, 1)
+		require.Equal(t, 2, r.OverflowLen())
+		require.Len(t, fullScans, 0)
+
+		// the spilled batches are drained into the queue
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go r.overflow.drain(ctx, batches)
+		var scanned []flow.Address
+		for i := 0; i < len(addresses); i++ {
+			batch := <-batches
+			scanned = append(scanned, batch.Addresses...)
+			batch.DoneHandling()
+		}
+		require.ElementsMatch(t, addresses, scanned)
+		require.Equal(t, 0, r.OverflowLen())
+		require.Eventually(t, func() bool {
+			return r.LatestHandledBlock() == 10
+		}, time.Second, time.Millisecond)
+	})
+}
+
+func TestIncrementalScanner_OnFullScanRequest(t *testing.T) {
+	newScanner := func(allow bool) (*IncrementalScanner, *blockCandidatesScanner, *[]FullScanRequest, chan uint64) {
+		c := clienttest.New()
+		c.AddBlocks(1, 16)
+		blockScanner := &blockCandidatesScanner{}
+		var requests []FullScanRequest
+		config := DefaultIncrementalScannerConfig()
+		config.CandidateScanners = []candidates.CandidateScanner{blockScanner}
+		config.IncrementalScannerMaxBlockGap = 5
+		config.IncrementalScannerBlockLag = 0
+		config.OnFullScanRequest = func(request FullScanRequest) bool {
+			requests = append(requests, request)
+			return allow
+		}
+
+		fullScans := make(chan uint64, 1)
+		r := NewIncrementalScanner(
+			c,
+			make(chan AddressBatch, 10),
+			fullScans,
+			100,
+			config,
+			NoOpStatusReporter{},
+			zerolog.Nop(),
+		)
+		r.latestBlock = 2
+		return r, blockScanner, &requests, fullScans
+	}
+	gapRequest := FullScanRequest{Reason: FullScanReasonBlockGap, Height: 16, BlocksBehind: 14}
+
+	t.Run("allowed", func(t *testing.T) {
+		r, blockScanner, requests, fullScans := newScanner(true)
+		require.NoError(t, r.scanNewBlocks(context.Background()))
+
+		require.Equal(t, []FullScanRequest{gapRequest}, *requests)
+		require.Equal(t, uint64(16), <-fullScans)
+		require.Empty(t, blockScanner.ranges)
+	})
+
+	t.Run("vetoed", func(t *testing.T) {
+		r, blockScanner, requests, fullScans := newScanner(false)
+		require.NoError(t, r.scanNewBlocks(context.Background()))
+
+		// the incremental scanner catches up instead
+		require.Equal(t, []FullScanRequest{gapRequest}, *requests)
+		require.Len(t, fullScans, 0)
+		require.Equal(t, []candidates.BlockRange{
+			{Start: 3, End: 7},
+			{Start: 8, End: 12},
+			{Start: 13, End: 16},
+		}, blockScanner.ranges)
+		require.Equal(t, uint64(16), r.latestBlock)
+	})
+
+	t.Run("first full scan", func(t *testing.T) {
+		r, _, requests, fullScans := newScanner(false)
+		r.latestBlock = 0
+		require.NoError(t, r.scanNewBlocks(context.Background()))
+
+		// the first full scan can not be vetoed
+		require.Empty(t, *requests)
+		require.Equal(t, uint64(16), <-fullScans)
+	})
+
+	t.Run("backpressure vetoed", func(t *testing.T) {
+		requests := make(chan FullScanRequest, 1)
+		config := DefaultIncrementalScannerConfig()
+		config.CandidateScanners = []candidates.CandidateScanner{
+			candidates.NewCallbackCandidatesScanner(func(context.Context, candidates.BlockRange) ([]flow.Address, error) {
+				return []flow.Address{flow.HexToAddress("0x1"), flow.HexToAddress("0x2")}, nil
+			}),
+		}
+		config.BackpressurePolicy = BackpressureDropAndRequestFullScan
+		config.OnFullScanRequest = func(request FullScanRequest) bool {
+			requests <- request
+			return false
+		}
+
+		batches := make(chan AddressBatch, 1)
+		fullScans := make(chan uint64, 1)
+		r := NewIncrementalScanner(clienttest.New(), batches, fullScans, 1, config, NoOpStatusReporter{}, zerolog.Nop())
+		r.latestHandledBlock.Store(2)
+
+		scanned := make(chan error, 1)
+		go func() {
+			scanned <- r.scanBlockRange(context.Background(), 3, 10)
+		}()
+		require.Equal(t, FullScanRequest{Reason: FullScanReasonBackpressure, Height: 10, BlocksBehind: 8}, <-requests)
+
+		// the batch that did not fit waits for room in the queue instead of being dropped
+		for i := 0; i < 2; i++ {
+			batch := <-batches
+			batch.DoneHandling()
+		}
+		require.NoError(t, <-scanned)
+		require.Len(t, fullScans, 0)
+	})
+}
+
+func TestIncrementalScanner_IncrementalStartHeight(t *testing.T) {
+	c := clienttest.New()
+	c.AddBlocks(1, 16)
+	blockScanner := &blockCandidatesScanner{}
+	config := DefaultIncrementalScannerConfig()
+	config.CandidateScanners = []candidates.CandidateScanner{blockScanner}
+	config.IncrementalScannerMaxBlockGap = 5
+	config.IncrementalScannerBlockLag = 0
+	config.IncrementalStartHeight = 2
+	config.BackfillBlockRange = 4
+
+	fullScans := make(chan uint64, 1)
+	r := NewIncrementalScanner(
+		c,
+		make(chan AddressBatch, 10),
+		fullScans,
+		100,
+		config,
+		NoOpStatusReporter{},
+		zerolog.Nop(),
+	)
+	require.Equal(t, uint64(2), r.LatestHandledBlock())
+
+	// the blocks after the start height are backfilled instead of doing a full scan
+	require.NoError(t, r.scanNewBlocks(context.Background()))
+	require.Len(t, fullScans, 0)
+	require.Equal(t, []candidates.BlockRange{
+		{Start: 3, End: 6},
+		{Start: 7, End: 10},
+		{Start: 11, End: 14},
+		{Start: 15, End: 16},
+	}, blockScanner.ranges)
+
+	// once backfilled, falling behind requests a full scan again
+	c.AddBlocks(17, 30)
+	require.NoError(t, r.scanNewBlocks(context.Background()))
+	require.Equal(t, uint64(30), <-fullScans)
+	require.Len(t, blockScanner.ranges, 4)
+}
+
+func TestIncrementalScanner_IncrementalOnly(t *testing.T) {
+	c := clienttest.New()
+	c.AddBlocks(1, 8)
+	blockScanner := &blockCandidatesScanner{}
+	config := DefaultIncrementalScannerConfig()
+	config.CandidateScanners = []candidates.CandidateScanner{blockScanner}
+	config.IncrementalScannerMaxBlockGap = 5
+	config.IncrementalScannerBlockLag = 0
+	config.IncrementalOnly = true
+	config.IncrementalStartHeight = 2
+
+	fullScans := make(chan uint64, 1)
+	r := NewIncrementalScanner(
+		c,
+		make(chan AddressBatch, 10),
+		fullScans,
+		100,
+		config,
+		NoOpStatusReporter{},
+		zerolog.Nop(),
+	)
+
+	require.NoError(t, r.scanNewBlocks(context.Background()))
+	// falling behind catches up in block ranges of the maximum size, instead of requesting a full scan
+	c.AddBlocks(9, 19)
+	require.NoError(t, r.scanNewBlocks(context.Background()))
+
+	require.Len(t, fullScans, 0)
+	require.Equal(t, []candidates.BlockRange{
+		{Start: 3, End: 7},
+		{Start: 8, End: 8},
+		{Start: 9, End: 13},
+		{Start: 14, End: 18},
+		{Start: 19, End: 19},
+	}, blockScanner.ranges)
+}
+
+func TestIncrementalScanner_BlockLagAndMaxBlockGap(t *testing.T) {
+	c := clienttest.New()
+	c.AddBlocks(1, 10)
+	blockScanner := &blockCandidatesScanner{}
+	config := DefaultConfig().
+		WithIncrementalScannerBlockLag(3).
+		WithIncrementalScannerMaxBlockGap(5).
+		IncrementalScannerConfig
+	config.CandidateScanners = []candidates.CandidateScanner{blockScanner}
+
+	fullScans := make(chan uint64, 1)
+	r := NewIncrementalScanner(
+		c,
+		make(chan AddressBatch, 10),
+		fullScans,
+		100,
+		config,
+		NoOpStatusReporter{},
+		zerolog.Nop(),
+	)
+	r.latestBlock = 2
+
+	// the incremental scanner stays the block lag behind the latest block
+	require.NoError(t, r.scanNewBlocks(context.Background()))
+	require.Equal(t, []candidates.BlockRange{{Start: 3, End: 7}}, blockScanner.ranges)
+	require.Len(t, fullScans, 0)
+
+	// falling behind more than the max block gap requests a full scan
+	c.AddBlocks(11, 16)
+	require.NoError(t, r.scanNewBlocks(context.Background()))
+	require.Equal(t, uint64(13), <-fullScans)
+	require.Len(t, blockScanner.ranges, 1)
+}
+
+func TestIncrementalScanner_IncrementalScanInterval(t *testing.T) {
+	c := clienttest.New()
+	c.AddBlocks(1, 10)
+	config := DefaultIncrementalScannerConfig()
+	config.CandidateScanners = []candidates.CandidateScanner{&blockCandidatesScanner{}}
+	config.IncrementalScannerBlockLag = 0
+	config.IncrementalOnly = true
+	config.IncrementalStartHeight = 10
+	config.IncrementalScanInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	batches := make(chan AddressBatch)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case batch := <-batches:
+				batch.DoneHandling()
+			}
+		}
+	}()
+	r := NewIncrementalScanner(
+		c,
+		batches,
+		make(chan uint64),
+		100,
+		config,
+		NoOpStatusReporter{},
+		zerolog.Nop(),
+	)
+	<-r.Start(ctx)
+	require.Eventually(t, func() bool {
+		return c.Calls(client.MethodGetLatestBlockHeader) > 0
+	}, time.Second, time.Millisecond)
+
+	// the new blocks are scanned well before the default interval
+	c.AddBlocks(11, 12)
+	require.Eventually(t, func() bool {
+		return r.LatestHandledBlock() == 12
+	}, DefaultIncrementalScanInterval/2, time.Millisecond)
+}
+
+// latestHeaderClient records if the latest sealed or finalized block header was requested.
+type latestHeaderClient struct {
+	*clienttest.Client
+	isSealed []bool
+}
+
+func (c *latestHeaderClient) GetLatestBlockHeader(ctx context.Context, isSealed bool) (*flow.BlockHeader, error) {
+	c.isSealed = append(c.isSealed, isSealed)
+	return c.Client.GetLatestBlockHeader(ctx, isSealed)
+}
+
+func TestIncrementalScanner_FollowFinalized(t *testing.T) {
+	for _, followFinalized := range []bool{false, true} {
+		c := &latestHeaderClient{Client: clienttest.New()}
+		c.AddBlocks(1, 10)
+		config := DefaultIncrementalScannerConfig()
+		config.FollowFinalized = followFinalized
+		r := NewIncrementalScanner(
+			c,
+			make(chan AddressBatch),
+			make(chan uint64, 1),
+			100,
+			config,
+			NoOpStatusReporter{},
+			zerolog.Nop(),
+		)
+
+		require.NoError(t, r.scanNewBlocks(context.Background()))
+		require.Equal(t, []bool{!followFinalized}, c.isSealed)
+	}
+}
+
+func TestIncrementalScanner_OnIncrementalRangeScanned(t *testing.T) {
+	c := clienttest.New()
+	c.AddBlocks(1, 10)
+	config := DefaultIncrementalScannerConfig()
+	config.IncrementalScannerBlockLag = 0
+	config.IncrementalStartHeight = 4
+
+	r := NewIncrementalScanner(
+		c,
+		make(chan AddressBatch, 1),
+		make(chan uint64, 1),
+		100,
+		config,
+		NoOpStatusReporter{},
+		zerolog.Nop(),
+	)
+	var scanned []candidates.BlockRange
+	r.hooks = ScanHooks{
+		OnIncrementalRangeScanned: func(start uint64, end uint64) {
+			scanned = append(scanned, candidates.BlockRange{Start: start, End: end})
+		},
+	}
+
+	require.NoError(t, r.scanNewBlocks(context.Background()))
+	require.Equal(t, []candidates.BlockRange{{Start: 5, End: 10}}, scanned)
+}
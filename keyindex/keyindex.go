@@ -0,0 +1,178 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keyindex is a scan preset that indexes the keys of all accounts,
+// to find the accounts of a public key, e.g. for key rotation audits and account recovery.
+//
+// The full scan collects the keys of every account with the scripts.AccountKeys script,
+// and the incremental scan keeps the index fresh by rescanning the accounts that had keys added or removed:
+//
+//	index := keyindex.New()
+//	s, err := scanner.NewScanner(flowClient, append(options, index.Options(logger)...)...)
+//	...
+//	matches := index.FindAccountsByPublicKey(publicKey)
+package keyindex
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/candidates"
+	"github.com/onflow/flow-batch-scan/scripts"
+)
+
+// Match is a key of an account with the searched public key.
+type Match struct {
+	Address flow.Address
+	Key     scripts.AccountKey
+}
+
+// Index is an in-memory index of the keys of the scanned accounts. It is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	accounts map[flow.Address]account
+	// byPublicKey are the accounts with each public key.
+	byPublicKey map[string]map[flow.Address]struct{}
+}
+
+type account struct {
+	// height is the block height the keys were scanned at.
+	height uint64
+	keys   []scripts.AccountKey
+}
+
+var _ scanner.ScriptResultHandler = (*Index)(nil)
+
+func New() *Index {
+	return &Index{
+		accounts:    make(map[flow.Address]account),
+		byPublicKey: make(map[string]map[flow.Address]struct{}),
+	}
+}
+
+// Options are the options of the scan that fills the index:
+// the AccountKeys script, the index as its result handler,
+// and a candidate scanner for the accounts that had keys added or removed.
+func (i *Index) Options(logger zerolog.Logger) []scanner.Option {
+	return []scanner.Option{
+		scanner.WithScript(scripts.AccountKeys),
+		scanner.WithScriptResultHandler(i),
+		scanner.WithCandidateScanners([]candidates.CandidateScanner{
+			candidates.NewAccountKeyCandidatesScanner(logger),
+		}),
+	}
+}
+
+// Handle indexes the keys of the accounts of the batch.
+// Results of a lower block height than the indexed keys of an account are ignored,
+// since the full scan and the incremental scan handle their batches concurrently.
+func (i *Index) Handle(batch scanner.ProcessedAddressBatch) error {
+	keys, err := scripts.DecodeAccountKeys(batch.Result)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for address, accountKeys := range keys {
+		existing, ok := i.accounts[address]
+		if ok && existing.height > batch.BlockHeight {
+			continue
+		}
+		for _, key := range existing.keys {
+			i.remove(normalizePublicKey(key.PublicKey), address)
+		}
+		for _, key := range accountKeys {
+			i.add(normalizePublicKey(key.PublicKey), address)
+		}
+		i.accounts[address] = account{
+			height: batch.BlockHeight,
+			keys:   accountKeys,
+		}
+	}
+	return nil
+}
+
+// FindAccountsByPublicKey returns the keys with the hex encoded public key, ordered by address and key index.
+// Revoked keys are included, see scripts.AccountKey.IsRevoked.
+func (i *Index) FindAccountsByPublicKey(publicKey string) []Match {
+	publicKey = normalizePublicKey(publicKey)
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var matches []Match
+	for address := range i.byPublicKey[publicKey] {
+		for _, key := range i.accounts[address].keys {
+			if normalizePublicKey(key.PublicKey) == publicKey {
+				matches = append(matches, Match{
+					Address: address,
+					Key:     key,
+				})
+			}
+		}
+	}
+	sort.Slice(matches, func(a, b int) bool {
+		if c := bytes.Compare(matches[a].Address[:], matches[b].Address[:]); c != 0 {
+			return c < 0
+		}
+		return matches[a].Key.KeyIndex < matches[b].Key.KeyIndex
+	})
+	return matches
+}
+
+// Keys returns the indexed keys of the address.
+func (i *Index) Keys(address flow.Address) ([]scripts.AccountKey, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	account, ok := i.accounts[address]
+	return account.keys, ok
+}
+
+// Accounts returns the number of indexed accounts.
+func (i *Index) Accounts() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return len(i.accounts)
+}
+
+func (i *Index) add(publicKey string, address flow.Address) {
+	addresses, ok := i.byPublicKey[publicKey]
+	if !ok {
+		addresses = make(map[flow.Address]struct{})
+		i.byPublicKey[publicKey] = addresses
+	}
+	addresses[address] = struct{}{}
+}
+
+func (i *Index) remove(publicKey string, address flow.Address) {
+	addresses := i.byPublicKey[publicKey]
+	delete(addresses, address)
+	if len(addresses) == 0 {
+		delete(i.byPublicKey, publicKey)
+	}
+}
+
+func normalizePublicKey(publicKey string) string {
+	return strings.ToLower(strings.TrimPrefix(publicKey, "0x"))
+}
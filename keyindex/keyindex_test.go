@@ -0,0 +1,104 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyindex_test
+
+import (
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/keyindex"
+	"github.com/onflow/flow-batch-scan/scripts"
+)
+
+var keyType = &cadence.StructType{
+	QualifiedIdentifier: "KeyInfo",
+	Fields: []cadence.Field{
+		{Identifier: "keyIndex", Type: cadence.IntType{}},
+		{Identifier: "publicKey", Type: cadence.StringType{}},
+		{Identifier: "signatureAlgorithm", Type: cadence.UInt8Type{}},
+		{Identifier: "hashAlgorithm", Type: cadence.UInt8Type{}},
+		{Identifier: "weight", Type: cadence.UFix64Type{}},
+		{Identifier: "isRevoked", Type: cadence.BoolType{}},
+	},
+}
+
+func keysBatch(height uint64, keys map[flow.Address][]string) scanner.ProcessedAddressBatch {
+	pairs := make([]cadence.KeyValuePair, 0, len(keys))
+	for address, publicKeys := range keys {
+		values := make([]cadence.Value, 0, len(publicKeys))
+		for i, publicKey := range publicKeys {
+			values = append(values, cadence.NewStruct([]cadence.Value{
+				cadence.NewInt(i),
+				cadence.String(publicKey),
+				cadence.UInt8(1),
+				cadence.UInt8(3),
+				cadence.UFix64(100000000000),
+				cadence.Bool(false),
+			}).WithType(keyType))
+		}
+		pairs = append(pairs, cadence.KeyValuePair{Key: cadence.Address(address), Value: cadence.NewArray(values)})
+	}
+	return scanner.ProcessedAddressBatch{
+		AddressBatch: scanner.AddressBatch{BlockHeight: height},
+		Result:       cadence.NewDictionary(pairs),
+	}
+}
+
+func matchedAddresses(matches []keyindex.Match) []flow.Address {
+	addresses := make([]flow.Address, 0, len(matches))
+	for _, match := range matches {
+		addresses = append(addresses, match.Address)
+	}
+	return addresses
+}
+
+func TestIndex(t *testing.T) {
+	a1 := flow.HexToAddress("01")
+	a2 := flow.HexToAddress("02")
+
+	index := keyindex.New()
+	require.NoError(t, index.Handle(keysBatch(100, map[flow.Address][]string{
+		a1: {"aa", "bb"},
+		a2: {"aa"},
+	})))
+	require.Equal(t, 2, index.Accounts())
+
+	matches := index.FindAccountsByPublicKey("0xAA")
+	require.Equal(t, []flow.Address{a1, a2}, matchedAddresses(matches))
+	require.Equal(t, scripts.AccountKey{
+		KeyIndex:           0,
+		PublicKey:          "aa",
+		SignatureAlgorithm: 1,
+		HashAlgorithm:      3,
+		Weight:             100000000000,
+	}, matches[0].Key)
+
+	// the key of a2 was rotated
+	require.NoError(t, index.Handle(keysBatch(110, map[flow.Address][]string{a2: {"aa", "cc"}})))
+	require.NoError(t, index.Handle(keysBatch(120, map[flow.Address][]string{a2: {"cc"}})))
+	require.Equal(t, []flow.Address{a1}, matchedAddresses(index.FindAccountsByPublicKey("aa")))
+	require.Equal(t, []flow.Address{a2}, matchedAddresses(index.FindAccountsByPublicKey("cc")))
+
+	// an older result does not replace the keys
+	require.NoError(t, index.Handle(keysBatch(105, map[flow.Address][]string{a2: {"aa"}})))
+	require.Equal(t, []flow.Address{a2}, matchedAddresses(index.FindAccountsByPublicKey("cc")))
+	require.Empty(t, index.FindAccountsByPublicKey("dd"))
+
+	require.Error(t, index.Handle(scanner.ProcessedAddressBatch{Result: cadence.String("nope")}))
+}
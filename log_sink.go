@@ -0,0 +1,101 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/rs/zerolog"
+)
+
+// LogLevel is the level of a log event passed to a LogSink.
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "debug"
+	case LogLevelInfo:
+		return "info"
+	case LogLevelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// LogSink receives the structured log events of the scan.
+// It is the minimal interface to plug the scan into a logging library other than zerolog, e.g. zap.
+// For log/slog use NewSlogSink.
+type LogSink interface {
+	// Log is called for each log event. fields are the structured fields of the event,
+	// e.g. "component" with the name of the component that logged it.
+	Log(level LogLevel, msg string, fields map[string]any)
+}
+
+// NewSinkLogger creates a zerolog.Logger that passes its events to the sink.
+// The zerolog.Logger can be used wherever the scan takes a logger, e.g. Config.Logger or client.WithLog.
+func NewSinkLogger(sink LogSink) zerolog.Logger {
+	return zerolog.New(sinkWriter{sink: sink}).With().Timestamp().Logger()
+}
+
+// sinkWriter decodes the JSON events written by zerolog, and passes them to the sink.
+type sinkWriter struct {
+	sink LogSink
+}
+
+var _ zerolog.LevelWriter = sinkWriter{}
+
+func (w sinkWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+func (w sinkWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	fields := map[string]any{}
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return 0, err
+	}
+
+	msg, _ := fields[zerolog.MessageFieldName].(string)
+	delete(fields, zerolog.MessageFieldName)
+	delete(fields, zerolog.LevelFieldName)
+	delete(fields, zerolog.TimestampFieldName)
+
+	w.sink.Log(logLevel(level), msg, fields)
+	return len(p), nil
+}
+
+func logLevel(level zerolog.Level) LogLevel {
+	switch level {
+	case zerolog.TraceLevel, zerolog.DebugLevel:
+		return LogLevelDebug
+	case zerolog.InfoLevel, zerolog.NoLevel:
+		return LogLevelInfo
+	case zerolog.WarnLevel:
+		return LogLevelWarn
+	default:
+		return LogLevelError
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+
+package scanner
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+)
+
+// NewSlogSink creates a LogSink that logs to the slog.Logger.
+func NewSlogSink(logger *slog.Logger) LogSink {
+	return slogSink{logger: logger}
+}
+
+type slogSink struct {
+	logger *slog.Logger
+}
+
+func (s slogSink) Log(level LogLevel, msg string, fields map[string]any) {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, key := range keys {
+		attrs = append(attrs, slog.Any(key, fields[key]))
+	}
+	s.logger.LogAttrs(context.Background(), slogLevel(level), msg, attrs...)
+}
+
+func slogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelInfo:
+		return slog.LevelInfo
+	case LogLevelWarn:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+// WithSlogLogger logs the scan to the slog.Logger.
+func (c Config) WithSlogLogger(
+	logger *slog.Logger,
+) Config {
+	return c.WithLogSink(NewSlogSink(logger))
+}
+
+// WithSlogLogger is the Option of Config.WithSlogLogger.
+func WithSlogLogger(logger *slog.Logger) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithSlogLogger(logger)
+	})
+}
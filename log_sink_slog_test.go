@@ -0,0 +1,44 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+
+package scanner
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlogSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewSinkLogger(NewSlogSink(slog.New(slog.NewJSONHandler(buf, nil))))
+
+	c := NewComponentWithStart("test_component", nil, logger)
+	c.Logger.Debug().Msg("not logged")
+	c.Logger.Warn().
+		Uint64("block_height", 100).
+		Msg("something happened")
+
+	record := map[string]any{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, "WARN", record["level"])
+	require.Equal(t, "something happened", record["msg"])
+	require.Equal(t, "test_component", record["component"])
+	require.Equal(t, float64(100), record["block_height"])
+}
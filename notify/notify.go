@@ -0,0 +1,285 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notify sends notifications about a scan to a webhook,
+// so operators do not need to tail the logs to know if a scan completed or died.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	scanner "github.com/onflow/flow-batch-scan"
+)
+
+// Format is the format of the webhook payload.
+type Format string
+
+const (
+	// FormatJSON posts the Event as JSON.
+	FormatJSON Format = "json"
+	// FormatSlack posts the text of the event as a Slack incoming webhook message.
+	FormatSlack Format = "slack"
+	// FormatDiscord posts the text of the event as a Discord webhook message.
+	FormatDiscord Format = "discord"
+)
+
+// DefaultTimeout is the default timeout of a webhook request.
+const DefaultTimeout = 10 * time.Second
+
+// EventKind is what happened to the scan.
+type EventKind string
+
+const (
+	// EventFullScanCompleted is sent when a full scan handled all the addresses.
+	EventFullScanCompleted EventKind = "full_scan_completed"
+	// EventScanIncomplete is sent when the scan concluded without completing a full scan,
+	// so some accounts may have stale data.
+	EventScanIncomplete EventKind = "scan_incomplete"
+	// EventScanFailed is sent when the scan failed.
+	EventScanFailed EventKind = "scan_failed"
+	// EventBlockGap is sent when the incremental scanner fell too far behind, skipped blocks,
+	// and requested a full scan. It is only sent if NotifyBlockGaps is set.
+	EventBlockGap EventKind = "block_gap"
+	// EventScheduledRunSkipped is sent when a run of the schedule was skipped,
+	// because the previous run was still in progress.
+	EventScheduledRunSkipped EventKind = "scheduled_run_skipped"
+)
+
+type Config struct {
+	// WebhookURL is the URL the notifications are posted to.
+	WebhookURL string
+	Format     Format
+	// Name identifies the scan in the notifications.
+	Name string
+	// NotifyBlockGaps also notifies when the incremental scanner skipped blocks.
+	NotifyBlockGaps bool
+	// Timeout is the timeout of a webhook request.
+	Timeout time.Duration
+}
+
+func DefaultConfig(webhookURL string) Config {
+	return Config{
+		WebhookURL: webhookURL,
+		Format:     FormatJSON,
+		Name:       "flow-batch-scan",
+		Timeout:    DefaultTimeout,
+	}
+}
+
+// Event is a notification about the scan.
+type Event struct {
+	Kind EventKind `json:"kind"`
+	Name string    `json:"name"`
+	Time time.Time `json:"time"`
+	// Text is a human-readable description of the event.
+	Text string `json:"text"`
+
+	// FullScan is set for EventFullScanCompleted.
+	FullScan *scanner.FullScanStats `json:"full_scan,omitempty"`
+	// Concluded is set for EventScanIncomplete and EventScanFailed.
+	Concluded *scanner.ScanConcluded `json:"concluded,omitempty"`
+	// Error is set for EventScanFailed.
+	Error string `json:"error,omitempty"`
+	// FullScanRequest is set for EventBlockGap.
+	FullScanRequest *scanner.FullScanRequest `json:"full_scan_request,omitempty"`
+	// Scheduled is set for EventScheduledRunSkipped.
+	Scheduled *time.Time `json:"scheduled,omitempty"`
+}
+
+// Notifier posts the events of a scan to a webhook. See Notifier.Configure.
+type Notifier struct {
+	Config
+
+	client *http.Client
+	logger zerolog.Logger
+	// inFlight are the notifications that are sent in the background.
+	inFlight sync.WaitGroup
+}
+
+func NewNotifier(
+	config Config,
+	logger zerolog.Logger,
+) *Notifier {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Notifier{
+		Config: config,
+		client: &http.Client{Timeout: timeout},
+		logger: logger.With().Str("component", "notifier").Logger(),
+	}
+}
+
+// Configure adds the hooks of the notifier to the scan config.
+// Hooks that are already set are still called.
+func (n *Notifier) Configure(config scanner.Config) scanner.Config {
+	hooks := config.Hooks
+
+	onFullScanCompleted := hooks.OnFullScanCompleted
+	config.Hooks.OnFullScanCompleted = func(stats scanner.FullScanStats) {
+		if onFullScanCompleted != nil {
+			onFullScanCompleted(stats)
+		}
+		n.FullScanCompleted(stats)
+	}
+
+	onScanConcluded := hooks.OnScanConcluded
+	config.Hooks.OnScanConcluded = func(concluded scanner.ScanConcluded, err error) {
+		if onScanConcluded != nil {
+			onScanConcluded(concluded, err)
+		}
+		n.ScanConcluded(concluded, err)
+	}
+
+	onScheduledRunSkipped := hooks.OnScheduledRunSkipped
+	config.Hooks.OnScheduledRunSkipped = func(scheduled time.Time) {
+		if onScheduledRunSkipped != nil {
+			onScheduledRunSkipped(scheduled)
+		}
+		n.ScheduledRunSkipped(scheduled)
+	}
+
+	if n.NotifyBlockGaps {
+		onFullScanRequest := config.OnFullScanRequest
+		config.OnFullScanRequest = func(request scanner.FullScanRequest) bool {
+			allowed := onFullScanRequest == nil || onFullScanRequest(request)
+			if allowed && request.Reason == scanner.FullScanReasonBlockGap {
+				n.BlockGap(request)
+			}
+			return allowed
+		}
+	}
+	return config
+}
+
+// FullScanCompleted notifies that a full scan completed. The notification is sent in the background.
+func (n *Notifier) FullScanCompleted(stats scanner.FullScanStats) {
+	n.notifyAsync(Event{
+		Kind: EventFullScanCompleted,
+		Text: fmt.Sprintf("full scan at block height %d completed: %d addresses in %d batches in %s",
+			stats.ReferenceBlockHeight, stats.Addresses, stats.Batches, stats.Duration.Round(time.Second)),
+		FullScan: &stats,
+	})
+}
+
+// BlockGap notifies that the incremental scanner skipped blocks. The notification is sent in the background.
+func (n *Notifier) BlockGap(request scanner.FullScanRequest) {
+	n.notifyAsync(Event{
+		Kind: EventBlockGap,
+		Text: fmt.Sprintf("incremental scanner fell %d blocks behind, requesting a full scan at block height %d",
+			request.BlocksBehind, request.Height),
+		FullScanRequest: &request,
+	})
+}
+
+// ScheduledRunSkipped notifies that a run of the schedule was skipped. The notification is sent in the background.
+func (n *Notifier) ScheduledRunSkipped(scheduled time.Time) {
+	n.notifyAsync(Event{
+		Kind:      EventScheduledRunSkipped,
+		Text:      fmt.Sprintf("skipped the scan scheduled at %s, the previous scan is still in progress", scheduled.Format(time.RFC3339)),
+		Scheduled: &scheduled,
+	})
+}
+
+// ScanConcluded notifies if the scan failed, or concluded without completing a full scan.
+// The notification is sent before it returns, and it waits for the notifications sent in the background,
+// so it is safe to exit the process afterwards.
+func (n *Notifier) ScanConcluded(concluded scanner.ScanConcluded, err error) {
+	defer n.inFlight.Wait()
+
+	switch {
+	case err != nil && !errors.Is(err, context.Canceled):
+		n.notify(Event{
+			Kind:      EventScanFailed,
+			Text:      fmt.Sprintf("scan failed at block height %d: %v", concluded.LatestScannedBlockHeight, err),
+			Concluded: &concluded,
+			Error:     err.Error(),
+		})
+	case !concluded.ScanIsComplete:
+		n.notify(Event{
+			Kind: EventScanIncomplete,
+			Text: fmt.Sprintf("scan concluded at block height %d without completing a full scan, some accounts may have stale data",
+				concluded.LatestScannedBlockHeight),
+			Concluded: &concluded,
+		})
+	}
+}
+
+func (n *Notifier) notifyAsync(event Event) {
+	n.inFlight.Add(1)
+	go func() {
+		defer n.inFlight.Done()
+		n.notify(event)
+	}()
+}
+
+// notify sends the event, and logs if it could not be sent.
+func (n *Notifier) notify(event Event) {
+	event.Name = n.Name
+	event.Time = time.Now()
+	err := n.Send(context.Background(), event)
+	if err != nil {
+		n.logger.Warn().
+			Err(err).
+			Str("kind", string(event.Kind)).
+			Msg("failed to send notification")
+	}
+}
+
+// Send posts the event to the webhook in the configured format.
+func (n *Notifier) Send(ctx context.Context, event Event) error {
+	payload, err := n.payload(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (n *Notifier) payload(event Event) ([]byte, error) {
+	text := fmt.Sprintf("[%s] %s", event.Name, event.Text)
+	switch n.Format {
+	case FormatSlack:
+		return json.Marshal(map[string]string{"text": text})
+	case FormatDiscord:
+		return json.Marshal(map[string]string{"content": text})
+	case FormatJSON, "":
+		return json.Marshal(event)
+	default:
+		return nil, fmt.Errorf("unknown notification format %q", n.Format)
+	}
+}
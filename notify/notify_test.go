@@ -0,0 +1,108 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+)
+
+type webhook struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	payloads []map[string]any
+}
+
+func newWebhook(t *testing.T) *webhook {
+	w := &webhook{}
+	w.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		payload := map[string]any{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.mu.Lock()
+		w.payloads = append(w.payloads, payload)
+		w.mu.Unlock()
+	}))
+	t.Cleanup(w.Close)
+	return w
+}
+
+func TestNotifier_Configure(t *testing.T) {
+	w := newWebhook(t)
+	config := DefaultConfig(w.URL)
+	config.NotifyBlockGaps = true
+	n := NewNotifier(config, zerolog.Nop())
+
+	completed := false
+	scanConfig := scanner.DefaultConfig().
+		WithOnFullScanCompleted(func(scanner.FullScanStats) { completed = true })
+	scanConfig = n.Configure(scanConfig)
+
+	scanConfig.Hooks.OnFullScanCompleted(scanner.FullScanStats{ReferenceBlockHeight: 100, Addresses: 10})
+	require.True(t, scanConfig.OnFullScanRequest(scanner.FullScanRequest{
+		Reason:       scanner.FullScanReasonBlockGap,
+		Height:       200,
+		BlocksBehind: 150,
+	}))
+	scanConfig.Hooks.OnScanConcluded(scanner.ScanConcluded{LatestScannedBlockHeight: 300}, fmt.Errorf("boom"))
+
+	require.True(t, completed)
+	kinds := map[any]bool{}
+	for _, payload := range w.payloads {
+		kinds[payload["kind"]] = true
+		require.Equal(t, "flow-batch-scan", payload["name"])
+	}
+	require.Equal(t, map[any]bool{
+		string(EventFullScanCompleted): true,
+		string(EventBlockGap):          true,
+		string(EventScanFailed):        true,
+	}, kinds)
+}
+
+func TestNotifier_ScanConcluded(t *testing.T) {
+	w := newWebhook(t)
+	n := NewNotifier(DefaultConfig(w.URL), zerolog.Nop())
+
+	n.ScanConcluded(scanner.ScanConcluded{ScanIsComplete: true}, nil)
+	require.Empty(t, w.payloads)
+
+	n.ScanConcluded(scanner.ScanConcluded{ScanIsComplete: false}, nil)
+	require.Len(t, w.payloads, 1)
+	require.Equal(t, string(EventScanIncomplete), w.payloads[0]["kind"])
+}
+
+func TestNotifier_Formats(t *testing.T) {
+	for format, key := range map[Format]string{FormatSlack: "text", FormatDiscord: "content"} {
+		w := newWebhook(t)
+		config := DefaultConfig(w.URL)
+		config.Format = format
+		config.Name = "nightly"
+		n := NewNotifier(config, zerolog.Nop())
+
+		n.ScanConcluded(scanner.ScanConcluded{}, fmt.Errorf("boom"))
+		require.Len(t, w.payloads, 1)
+		require.Len(t, w.payloads[0], 1)
+		require.Contains(t, w.payloads[0][key], "[nightly] scan failed")
+	}
+}
@@ -0,0 +1,755 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+	"github.com/onflow/flow-batch-scan/client"
+)
+
+// Option configures a scanner, see NewScanner.
+// The options are the builders of Config, e.g. WithBatchSize is the Option of Config.WithBatchSize.
+// A Config is an Option as well, it replaces the config built so far, so the options after it change it.
+type Option interface {
+	apply(config Config) Config
+}
+
+var _ Option = Config{}
+
+func (c Config) apply(Config) Config {
+	return c
+}
+
+type optionFunc func(config Config) Config
+
+func (f optionFunc) apply(config Config) Config {
+	return f(config)
+}
+
+// WithLogger is the Option of Config.WithLogger.
+func WithLogger(value zerolog.Logger) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithLogger(value)
+	})
+}
+
+// WithLogSink is the Option of Config.WithLogSink.
+func WithLogSink(sink LogSink) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithLogSink(sink)
+	})
+}
+
+// WithBatchSize is the Option of Config.WithBatchSize.
+func WithBatchSize(value int) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithBatchSize(value)
+	})
+}
+
+// WithFullScanThrottle is the Option of Config.WithFullScanThrottle.
+func WithFullScanThrottle(config FullScanThrottleConfig) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithFullScanThrottle(config)
+	})
+}
+
+// WithAdaptiveBatchSize is the Option of Config.WithAdaptiveBatchSize.
+func WithAdaptiveBatchSize(minBatchSize int) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithAdaptiveBatchSize(minBatchSize)
+	})
+}
+
+// WithAddressBatchQueueSize is the Option of Config.WithAddressBatchQueueSize.
+func WithAddressBatchQueueSize(value int) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithAddressBatchQueueSize(value)
+	})
+}
+
+// WithBatchPriority is the Option of Config.WithBatchPriority.
+func WithBatchPriority(priority BatchPriority) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithBatchPriority(priority)
+	})
+}
+
+// WithScriptResultQueueSize is the Option of Config.WithScriptResultQueueSize.
+func WithScriptResultQueueSize(value int) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithScriptResultQueueSize(value)
+	})
+}
+
+// WithFullScanRequestQueueSize is the Option of Config.WithFullScanRequestQueueSize.
+func WithFullScanRequestQueueSize(value int) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithFullScanRequestQueueSize(value)
+	})
+}
+
+// WithDurableQueue is the Option of Config.WithDurableQueue.
+func WithDurableQueue(path string) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithDurableQueue(path)
+	})
+}
+
+// WithMaxInFlightBatches is the Option of Config.WithMaxInFlightBatches.
+func WithMaxInFlightBatches(value int) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithMaxInFlightBatches(value)
+	})
+}
+
+// WithResultSpillDir is the Option of Config.WithResultSpillDir.
+func WithResultSpillDir(dir string) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithResultSpillDir(dir)
+	})
+}
+
+// WithResultReconciliation is the Option of Config.WithResultReconciliation.
+func WithResultReconciliation(policy ResultReconciliation) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithResultReconciliation(policy)
+	})
+}
+
+// WithCatchUpBlockRange is the Option of Config.WithCatchUpBlockRange.
+func WithCatchUpBlockRange(value uint64) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithCatchUpBlockRange(value)
+	})
+}
+
+// WithCatchUpConcurrency is the Option of Config.WithCatchUpConcurrency.
+func WithCatchUpConcurrency(value int) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithCatchUpConcurrency(value)
+	})
+}
+
+// WithProgressStore is the Option of Config.WithProgressStore.
+func WithProgressStore(value ProgressStore) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithProgressStore(value)
+	})
+}
+
+// WithResumeFromProgress is the Option of Config.WithResumeFromProgress.
+func WithResumeFromProgress(value bool) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithResumeFromProgress(value)
+	})
+}
+
+// WithOnFullScanRequest is the Option of Config.WithOnFullScanRequest.
+func WithOnFullScanRequest(value func(request FullScanRequest) bool) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithOnFullScanRequest(value)
+	})
+}
+
+// WithSubscription is the Option of Config.WithSubscription.
+func WithSubscription(events map[string]func(event cadence.Event) ([]flow.Address, error)) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithSubscription(events)
+	})
+}
+
+// WithResumeFromCheckpoint is the Option of Config.WithResumeFromCheckpoint.
+func WithResumeFromCheckpoint(store CheckpointStore) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithResumeFromCheckpoint(store)
+	})
+}
+
+// WithFullScanCheckpointInterval is the Option of Config.WithFullScanCheckpointInterval.
+func WithFullScanCheckpointInterval(value time.Duration) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithFullScanCheckpointInterval(value)
+	})
+}
+
+// WithFullScanProgressLogInterval is the Option of Config.WithFullScanProgressLogInterval.
+func WithFullScanProgressLogInterval(value time.Duration) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithFullScanProgressLogInterval(value)
+	})
+}
+
+// WithFullScanInterval is the Option of Config.WithFullScanInterval.
+func WithFullScanInterval(value time.Duration) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithFullScanInterval(value)
+	})
+}
+
+// WithFullScanBlockInterval is the Option of Config.WithFullScanBlockInterval.
+func WithFullScanBlockInterval(value uint64) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithFullScanBlockInterval(value)
+	})
+}
+
+// WithReferenceBlockHeight is the Option of Config.WithReferenceBlockHeight.
+func WithReferenceBlockHeight(height uint64) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithReferenceBlockHeight(height)
+	})
+}
+
+// WithBackpressurePolicy is the Option of Config.WithBackpressurePolicy.
+func WithBackpressurePolicy(value BackpressurePolicy) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithBackpressurePolicy(value)
+	})
+}
+
+// WithContinuousScan is the Option of Config.WithContinuousScan.
+func WithContinuousScan(value bool) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithContinuousScan(value)
+	})
+}
+
+// WithSchedule is the Option of Config.WithSchedule.
+func WithSchedule(schedule string) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithSchedule(schedule)
+	})
+}
+
+// WithCandidateScanners is the Option of Config.WithCandidateScanners.
+func WithCandidateScanners(value []candidates.CandidateScanner) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithCandidateScanners(value)
+	})
+}
+
+// WithIncrementalOnly is the Option of Config.WithIncrementalOnly.
+func WithIncrementalOnly(startHeight uint64) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithIncrementalOnly(startHeight)
+	})
+}
+
+// WithIncrementalStartHeight is the Option of Config.WithIncrementalStartHeight.
+func WithIncrementalStartHeight(startHeight uint64) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithIncrementalStartHeight(startHeight)
+	})
+}
+
+// WithBackfillBlockRange is the Option of Config.WithBackfillBlockRange.
+func WithBackfillBlockRange(value uint64) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithBackfillBlockRange(value)
+	})
+}
+
+// WithBackfillInterval is the Option of Config.WithBackfillInterval.
+func WithBackfillInterval(value time.Duration) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithBackfillInterval(value)
+	})
+}
+
+// WithIncrementalScannerBlockLag is the Option of Config.WithIncrementalScannerBlockLag.
+func WithIncrementalScannerBlockLag(value uint64) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithIncrementalScannerBlockLag(value)
+	})
+}
+
+// WithFollowFinalized is the Option of Config.WithFollowFinalized.
+func WithFollowFinalized(value bool) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithFollowFinalized(value)
+	})
+}
+
+// WithIncrementalScannerMaxBlockGap is the Option of Config.WithIncrementalScannerMaxBlockGap.
+func WithIncrementalScannerMaxBlockGap(value uint64) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithIncrementalScannerMaxBlockGap(value)
+	})
+}
+
+// WithIncrementalScanInterval is the Option of Config.WithIncrementalScanInterval.
+func WithIncrementalScanInterval(value time.Duration) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithIncrementalScanInterval(value)
+	})
+}
+
+// WithCandidateCoalesceBlocks is the Option of Config.WithCandidateCoalesceBlocks.
+func WithCandidateCoalesceBlocks(value uint64) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithCandidateCoalesceBlocks(value)
+	})
+}
+
+// WithCandidateCoalesceDuration is the Option of Config.WithCandidateCoalesceDuration.
+func WithCandidateCoalesceDuration(value time.Duration) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithCandidateCoalesceDuration(value)
+	})
+}
+
+// WithCandidateDebounceBlocks is the Option of Config.WithCandidateDebounceBlocks.
+func WithCandidateDebounceBlocks(value uint64) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithCandidateDebounceBlocks(value)
+	})
+}
+
+// WithCandidateDebounceDuration is the Option of Config.WithCandidateDebounceDuration.
+func WithCandidateDebounceDuration(value time.Duration) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithCandidateDebounceDuration(value)
+	})
+}
+
+// WithEventDedup is the Option of Config.WithEventDedup.
+func WithEventDedup(size int) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithEventDedup(size)
+	})
+}
+
+// WithOnFullScanStarted is the Option of Config.WithOnFullScanStarted.
+func WithOnFullScanStarted(value func(referenceBlockHeight uint64)) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithOnFullScanStarted(value)
+	})
+}
+
+// WithOnFullScanCompleted is the Option of Config.WithOnFullScanCompleted.
+func WithOnFullScanCompleted(value func(stats FullScanStats)) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithOnFullScanCompleted(value)
+	})
+}
+
+// WithOnBatchProcessed is the Option of Config.WithOnBatchProcessed.
+func WithOnBatchProcessed(value func(batch ProcessedAddressBatch, duration time.Duration)) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithOnBatchProcessed(value)
+	})
+}
+
+// WithOnIncrementalRangeScanned is the Option of Config.WithOnIncrementalRangeScanned.
+func WithOnIncrementalRangeScanned(value func(start uint64, end uint64)) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithOnIncrementalRangeScanned(value)
+	})
+}
+
+// WithOnStall is the Option of Config.WithOnStall.
+func WithOnStall(value func(stage string, since time.Duration)) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithOnStall(value)
+	})
+}
+
+// WithOnScanConcluded is the Option of Config.WithOnScanConcluded.
+func WithOnScanConcluded(value func(concluded ScanConcluded, err error)) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithOnScanConcluded(value)
+	})
+}
+
+// WithOnScheduledRunSkipped is the Option of Config.WithOnScheduledRunSkipped.
+func WithOnScheduledRunSkipped(value func(scheduled time.Time)) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithOnScheduledRunSkipped(value)
+	})
+}
+
+// WithStatusServer is the Option of Config.WithStatusServer.
+func WithStatusServer(addr string) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithStatusServer(addr)
+	})
+}
+
+// WithConsoleProgress is the Option of Config.WithConsoleProgress.
+func WithConsoleProgress(value bool) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithConsoleProgress(value)
+	})
+}
+
+// WithWatchdog is the Option of Config.WithWatchdog.
+func WithWatchdog(stallTimeout time.Duration, failOnStall bool) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithWatchdog(stallTimeout, failOnStall)
+	})
+}
+
+// WithProfiling is the Option of Config.WithProfiling.
+func WithProfiling(addr string) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithProfiling(addr)
+	})
+}
+
+// WithTracerProvider is the Option of Config.WithTracerProvider.
+func WithTracerProvider(value trace.TracerProvider) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithTracerProvider(value)
+	})
+}
+
+// WithStatusReporter is the Option of Config.WithStatusReporter.
+func WithStatusReporter(value StatusReporter) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithStatusReporter(value)
+	})
+}
+
+// WithScriptResultHandler is the Option of Config.WithScriptResultHandler.
+func WithScriptResultHandler(value ScriptResultHandler) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithScriptResultHandler(value)
+	})
+}
+
+// WithScriptResultHandlers is the Option of Config.WithScriptResultHandlers.
+func WithScriptResultHandlers(handlers ...ScriptResultHandler) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithScriptResultHandlers(handlers...)
+	})
+}
+
+// WithCompletenessAudit is the Option of Config.WithCompletenessAudit.
+func WithCompletenessAudit() Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithCompletenessAudit()
+	})
+}
+
+// WithAggregates is the Option of Config.WithAggregates.
+func WithAggregates(aggregates ...AggregateHandler) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithAggregates(aggregates...)
+	})
+}
+
+// WithChainID is the Option of Config.WithChainID.
+func WithChainID(value flow.ChainID) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithChainID(value)
+	})
+}
+
+// ForNetwork is the Option of Config.ForNetwork.
+func ForNetwork(preset client.Preset) Option {
+	return optionFunc(func(c Config) Config {
+		return c.ForNetwork(preset)
+	})
+}
+
+// WithExcludeAddress is the Option of Config.WithExcludeAddress.
+func WithExcludeAddress(value func(id flow.ChainID, address flow.Address) bool) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithExcludeAddress(value)
+	})
+}
+
+// WithIncludeAddresses is the Option of Config.WithIncludeAddresses.
+func WithIncludeAddresses(value ...flow.Address) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithIncludeAddresses(value...)
+	})
+}
+
+// WithIncludeAddressRange is the Option of Config.WithIncludeAddressRange.
+func WithIncludeAddressRange(from flow.Address, to flow.Address) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithIncludeAddressRange(from, to)
+	})
+}
+
+// WithExcludeAddresses is the Option of Config.WithExcludeAddresses.
+func WithExcludeAddresses(value ...flow.Address) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithExcludeAddresses(value...)
+	})
+}
+
+// WithExcludeAddressRange is the Option of Config.WithExcludeAddressRange.
+func WithExcludeAddressRange(from flow.Address, to flow.Address) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithExcludeAddressRange(from, to)
+	})
+}
+
+// WithSampleRate is the Option of Config.WithSampleRate.
+func WithSampleRate(fraction float64) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithSampleRate(fraction)
+	})
+}
+
+// WithSampleEvery is the Option of Config.WithSampleEvery.
+func WithSampleEvery(n uint) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithSampleEvery(n)
+	})
+}
+
+// WithPartitions is the Option of Config.WithPartitions.
+func WithPartitions(total uint, index uint) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithPartitions(total, index)
+	})
+}
+
+// WithMaxAddresses is the Option of Config.WithMaxAddresses.
+func WithMaxAddresses(value uint) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithMaxAddresses(value)
+	})
+}
+
+// WithMaxBatches is the Option of Config.WithMaxBatches.
+func WithMaxBatches(value uint) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithMaxBatches(value)
+	})
+}
+
+// WithAddressProvider is the Option of Config.WithAddressProvider.
+func WithAddressProvider(value func(ctx context.Context, blockHeight uint64) (AddressProvider, error)) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithAddressProvider(value)
+	})
+}
+
+// WithScript is the Option of Config.WithScript.
+func WithScript(value []byte) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithScript(value)
+	})
+}
+
+// WithScripts is the Option of Config.WithScripts.
+func WithScripts(value map[string][]byte) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithScripts(value)
+	})
+}
+
+// WithJobs is the Option of Config.WithJobs.
+func WithJobs(jobs ...ScanJob) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithJobs(jobs...)
+	})
+}
+
+// WithScriptUpgrade is the Option of Config.WithScriptUpgrade.
+func WithScriptUpgrade(height uint64, script []byte) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithScriptUpgrade(height, script)
+	})
+}
+
+// WithScriptUpgrades is the Option of Config.WithScriptUpgrades.
+func WithScriptUpgrades(upgrades ...ScriptUpgrade) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithScriptUpgrades(upgrades...)
+	})
+}
+
+// WithPerAddress is the Option of Config.WithPerAddress.
+func WithPerAddress(concurrency int) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithPerAddress(concurrency)
+	})
+}
+
+// WithAccountBalanceMode is the Option of Config.WithAccountBalanceMode.
+func WithAccountBalanceMode() Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithAccountBalanceMode()
+	})
+}
+
+// WithAccountKeysMode is the Option of Config.WithAccountKeysMode.
+func WithAccountKeysMode() Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithAccountKeysMode()
+	})
+}
+
+// WithScriptArguments is the Option of Config.WithScriptArguments.
+func WithScriptArguments(value ...cadence.Value) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithScriptArguments(value...)
+	})
+}
+
+// WithScriptArgumentsForBatch is the Option of Config.WithScriptArgumentsForBatch.
+func WithScriptArgumentsForBatch(value func(batch AddressBatch) []cadence.Value) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithScriptArgumentsForBatch(value)
+	})
+}
+
+// WithExpectedResultType is the Option of Config.WithExpectedResultType.
+func WithExpectedResultType(scriptName string, typ cadence.Type) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithExpectedResultType(scriptName, typ)
+	})
+}
+
+// WithExpectedResultTypeString is the Option of Config.WithExpectedResultTypeString.
+func WithExpectedResultTypeString(scriptName string, typ string) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithExpectedResultTypeString(scriptName, typ)
+	})
+}
+
+// WithResolveImports is the Option of Config.WithResolveImports.
+func WithResolveImports(aliases ContractAliases) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithResolveImports(aliases)
+	})
+}
+
+// WithScriptClient is the Option of Config.WithScriptClient.
+func WithScriptClient(value client.Client) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithScriptClient(value)
+	})
+}
+
+// WithScriptTimeout is the Option of Config.WithScriptTimeout.
+func WithScriptTimeout(timeout time.Duration, retries int) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithScriptTimeout(timeout, retries)
+	})
+}
+
+// WithMaxConcurrentScripts is the Option of Config.WithMaxConcurrentScripts.
+func WithMaxConcurrentScripts(value int) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithMaxConcurrentScripts(value)
+	})
+}
+
+// WithDryRun is the Option of Config.WithDryRun.
+func WithDryRun(value bool) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithDryRun(value)
+	})
+}
+
+// WithConcurrencySchedule is the Option of Config.WithConcurrencySchedule.
+func WithConcurrencySchedule(value ...ConcurrencyWindow) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithConcurrencySchedule(value...)
+	})
+}
+
+// WithHandlerErrorPolicy is the Option of Config.WithHandlerErrorPolicy.
+func WithHandlerErrorPolicy(value HandlerErrorPolicy) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithHandlerErrorPolicy(value)
+	})
+}
+
+// WithHandleScriptError is the Option of Config.WithHandleScriptError.
+func WithHandleScriptError(value func(AddressBatch, error) ScriptErrorAction) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithHandleScriptError(value)
+	})
+}
+
+// WithHandleAddressError is the Option of Config.WithHandleAddressError.
+func WithHandleAddressError(value func(flow.Address, error) AddressErrorAction) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithHandleAddressError(value)
+	})
+}
+
+// WithBisectFailedBatches is the Option of Config.WithBisectFailedBatches.
+func WithBisectFailedBatches(value bool, maxFailedAddresses int) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithBisectFailedBatches(value, maxFailedAddresses)
+	})
+}
+
+// WithScriptErrorPolicy is the Option of Config.WithScriptErrorPolicy.
+func WithScriptErrorPolicy(class error, policy ScriptErrorPolicy) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithScriptErrorPolicy(class, policy)
+	})
+}
+
+// WithoutScriptErrorPolicy is the Option of Config.WithoutScriptErrorPolicy.
+func WithoutScriptErrorPolicy(class error) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithoutScriptErrorPolicy(class)
+	})
+}
+
+// WithScriptErrorRetries is the Option of Config.WithScriptErrorRetries.
+func WithScriptErrorRetries(retries int, backoff time.Duration) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithScriptErrorRetries(retries, backoff)
+	})
+}
+
+// WithFailedBatchHandler is the Option of Config.WithFailedBatchHandler.
+func WithFailedBatchHandler(handler FailedBatchHandler) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithFailedBatchHandler(handler)
+	})
+}
+
+// WithBatchMetricsObserver is the Option of Config.WithBatchMetricsObserver.
+func WithBatchMetricsObserver(observer BatchMetricsObserver) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithBatchMetricsObserver(observer)
+	})
+}
+
+// WithLinkedAddresses is the Option of Config.WithLinkedAddresses.
+func WithLinkedAddresses(value func(batch ProcessedAddressBatch) []flow.Address) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithLinkedAddresses(value)
+	})
+}
+
+// WithBatchSigner is the Option of Config.WithBatchSigner.
+func WithBatchSigner(value crypto.Signer) Option {
+	return optionFunc(func(c Config) Config {
+		return c.WithBatchSigner(value)
+	})
+}
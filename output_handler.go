@@ -16,30 +16,122 @@ package scanner
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
+	"github.com/hashicorp/go-multierror"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/crypto"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/onflow/flow-batch-scan/candidates"
 )
 
+// LinkedAddressesScannerName is the scanner name in the provenance of linked addresses.
+const LinkedAddressesScannerName = "linked_addresses"
+
+// LinkedAddressesWindow is the number of blocks below the highest expanded block height
+// for which the heights the addresses were expanded at are remembered.
+const LinkedAddressesWindow = 1000
+
+type ScriptResultProcessorConfig struct {
+	// LinkedAddresses returns the addresses that are linked to the addresses in the processed batch
+	// (e.g. HybridCustody child accounts). If set, the linked addresses are scanned at the same block height,
+	// and the batch is only considered done, once the linked addresses are done as well.
+	// Addresses that were already expanded at the same or a higher block height are skipped, to protect against cycles.
+	// The heights are remembered for LinkedAddressesWindow blocks below the highest expanded height.
+	LinkedAddresses func(batch ProcessedAddressBatch) []flow.Address
+
+	// BatchSigner if set, is used to sign each processed batch before it is handled.
+	// The signature is available to the handler as ProcessedAddressBatch.Signature.
+	BatchSigner crypto.Signer
+
+	// HandlerErrorPolicy decides what happens when the ScriptResultHandler returns an error.
+	// Policies for single handlers can be set with a PolicyResultHandler.
+	HandlerErrorPolicy HandlerErrorPolicy
+
+	// MaxInFlightBatches if positive, is the maximum number of batches that are handled concurrently.
+	// While the limit is reached, the results wait in the script result queue (see Config.ScriptResultQueueSize),
+	// and once that is full the scripts wait, so the number of results held in memory is bounded.
+	// 0 means every result is handled as soon as it arrives.
+	MaxInFlightBatches int
+	// ResultSpillDir if set, writes the results that arrive while MaxInFlightBatches batches are handled
+	// to files in this directory, and reads them back once there is room, instead of making the scripts wait.
+	// Only the results are written, the rest of the batches stays in memory. Requires MaxInFlightBatches.
+	ResultSpillDir string
+
+	// ResultReconciliation decides which results are handled, when an address is scanned more than once in a scan,
+	// e.g. by the full scan and the incremental scanner. The default ReconcileNone handles all of them.
+	ResultReconciliation ResultReconciliation
+}
+
+func DefaultScriptResultProcessorConfig() ScriptResultProcessorConfig {
+	return ScriptResultProcessorConfig{
+		LinkedAddresses:    nil,
+		BatchSigner:        nil,
+		HandlerErrorPolicy: DefaultHandlerErrorPolicy(),
+		MaxInFlightBatches: 0,
+		ResultSpillDir:     "",
+
+		ResultReconciliation: ReconcileNone,
+	}
+}
+
 type ScriptResultProcessor struct {
 	*ComponentBase
+	ScriptResultProcessorConfig
 
 	scriptResultsChan <-chan ProcessedAddressBatch
+	addressBatchChan  chan<- AddressBatch
+
+	handler  ScriptResultHandler
+	hooks    ScanHooks
+	stats    *statsCollector
+	reporter StatusReporter
+	tracer   trace.Tracer
+	// audit if set, counts the handled results for the CompletenessAudit.
+	audit *completenessAuditor
+	// results if set, receives every batch after it was handled.
+	results chan<- ProcessedAddressBatch
+	// inFlight are the batches that are being handled.
+	inFlight sync.WaitGroup
+	// slots limits the batches that are handled to MaxInFlightBatches, it is nil without a limit.
+	slots chan struct{}
+	// spill holds the results that do not fit in the slots, if ResultSpillDir is set.
+	spill *resultSpill
+	// reconciler drops the duplicate results of the scan, it is nil with ReconcileNone.
+	reconciler *resultReconciler
 
-	handler ScriptResultHandler
+	mu         sync.Mutex
+	expandedAt *addressHeights
 }
 
 var _ Component = (*ScriptResultProcessor)(nil)
 
 func NewScriptResultProcessor(
 	outChan <-chan ProcessedAddressBatch,
+	addressBatchChan chan<- AddressBatch,
 	handler ScriptResultHandler,
+	config ScriptResultProcessorConfig,
 	logger zerolog.Logger,
 ) *ScriptResultProcessor {
 	r := &ScriptResultProcessor{
+		ScriptResultProcessorConfig: config,
 
 		scriptResultsChan: outChan,
+		addressBatchChan:  addressBatchChan,
 
 		handler: handler,
+
+		reconciler: newResultReconciler(config.ResultReconciliation),
+		expandedAt: newAddressHeights(LinkedAddressesWindow),
+	}
+	if config.MaxInFlightBatches > 0 {
+		r.slots = make(chan struct{}, config.MaxInFlightBatches)
 	}
 	r.ComponentBase = NewComponentWithStart(
 		"script_result_processor",
@@ -51,6 +143,20 @@ func NewScriptResultProcessor(
 }
 
 func (r *ScriptResultProcessor) start(ctx context.Context) {
+	handler := r.handler
+	if r.HandlerErrorPolicy.Action != HandlerErrorFailScan {
+		handler = NewPolicyResultHandler(handler, r.HandlerErrorPolicy, r.Logger)
+	}
+	setPolicyContext(handler, ctx)
+	if r.ResultSpillDir != "" && r.slots != nil {
+		spill, err := newResultSpill(r.ResultSpillDir)
+		if err != nil {
+			r.Finish(err)
+			return
+		}
+		r.spill = spill
+		go r.handleSpilled(ctx, handler)
+	}
 	go func() {
 		for {
 			select {
@@ -65,19 +171,367 @@ func (r *ScriptResultProcessor) start(ctx context.Context) {
 				if !result.IsValid() {
 					continue
 				}
-				go func(result ProcessedAddressBatch) {
-					err := r.handler.Handle(result)
-					result.DoneHandling()
-					if err != nil {
-						r.Finish(err)
+				if r.spill != nil {
+					// the spilled results are handled first, so the results are handled roughly in order
+					if r.spill.len() > 0 || !r.tryAcquireSlot() {
+						if err := r.spill.push(result); err != nil {
+							result.DoneHandling()
+							r.Finish(err)
+							return
+						}
+						continue
 					}
-				}(result)
+				} else if !r.acquireSlot(ctx) {
+					r.Finish(ctx.Err())
+					return
+				}
+				r.inFlight.Add(1)
+				go r.handle(ctx, handler, result)
 			}
 		}
 	}()
 }
 
+// handleSpilled handles the spilled results once there is a free slot, until the context is done.
+func (r *ScriptResultProcessor) handleSpilled(ctx context.Context, handler ScriptResultHandler) {
+	defer func() {
+		if err := r.spill.close(); err != nil {
+			r.Logger.Warn().Err(err).Msg("failed to remove the spilled results")
+		}
+	}()
+	for {
+		if r.spill.wait(ctx) != nil || !r.acquireSlot(ctx) {
+			return
+		}
+		result, err := r.spill.take()
+		if err != nil {
+			r.releaseSlot()
+			r.Finish(err)
+			return
+		}
+		if !result.IsValid() {
+			r.releaseSlot()
+			continue
+		}
+		r.inFlight.Add(1)
+		go r.handle(ctx, handler, result)
+	}
+}
+
+// handle handles the result in a slot, and releases the slot.
+func (r *ScriptResultProcessor) handle(ctx context.Context, handler ScriptResultHandler, result ProcessedAddressBatch) {
+	defer r.inFlight.Done()
+	result, reconciled, err := r.reconciler.reconcile(ctx, result)
+	if err != nil {
+		r.releaseSlot()
+		result.DoneHandling()
+		return
+	}
+	if reconciled > 0 {
+		r.stats.addressesReconciled(reconciled)
+		if len(result.Addresses) == 0 {
+			r.releaseSlot()
+			result.DoneHandling()
+			return
+		}
+	}
+	start := time.Now()
+	_, span := startSpan(
+		trace.ContextWithSpanContext(ctx, result.spanContext),
+		r.tracer,
+		"handle_result",
+		batchAttributes(result.AddressBatch)...,
+	)
+	err = r.sign(&result)
+	handled := false
+	if err == nil {
+		err = handler.Handle(result)
+		if err == nil {
+			handled = true
+			r.audit.handled(result)
+		}
+		err = r.ignoreHandlerError(err)
+		if err != nil {
+			err = newBatchError(result.AddressBatch, ErrHandlerFailed, err)
+		}
+	}
+	r.reconciler.done(result, handled)
+	endSpan(span, err)
+	if err != nil {
+		r.releaseSlot()
+		result.DoneHandling()
+		r.Finish(err)
+		return
+	}
+	duration := result.ScriptDuration + time.Since(start)
+	r.hooks.batchProcessed(result, duration)
+	if r.reporter != nil {
+		r.reporter.ReportBatchProcessed(len(result.Addresses), duration)
+	}
+	sent := r.sendResult(ctx, result)
+	// the slot is released before the linked addresses are sent to the script runner,
+	// which might be waiting for a slot itself
+	r.releaseSlot()
+	if !sent {
+		result.DoneHandling()
+		return
+	}
+	r.expandLinkedAddresses(ctx, result)
+}
+
+// acquireSlot waits for a free slot. It returns false if the context was cancelled before.
+func (r *ScriptResultProcessor) acquireSlot(ctx context.Context) bool {
+	if r.slots == nil {
+		return true
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case r.slots <- struct{}{}:
+		return true
+	}
+}
+
+func (r *ScriptResultProcessor) tryAcquireSlot() bool {
+	if r.slots == nil {
+		return true
+	}
+	select {
+	case r.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *ScriptResultProcessor) releaseSlot() {
+	if r.slots != nil {
+		<-r.slots
+	}
+}
+
+// SpilledLen is the number of results that were spilled to disk and are waiting to be handled.
+func (r *ScriptResultProcessor) SpilledLen() int {
+	if r.spill == nil {
+		return 0
+	}
+	return r.spill.len()
+}
+
+// sendResult sends the result to the results channel, if there is one.
+// It returns false if the context was cancelled before the result was received.
+func (r *ScriptResultProcessor) sendResult(ctx context.Context, result ProcessedAddressBatch) bool {
+	if r.results == nil {
+		return true
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case r.results <- result:
+		return true
+	}
+}
+
+// ignoreHandlerError counts the ignored handler errors, and returns the errors that were not ignored.
+func (r *ScriptResultProcessor) ignoreHandlerError(err error) error {
+	var ignored *IgnoredHandlerError
+	if !errors.As(err, &ignored) {
+		return err
+	}
+	count := ignored.count()
+	r.stats.handlerErrors(count)
+	if r.reporter != nil {
+		r.reporter.ReportHandlerErrors(count)
+	}
+	return nil
+}
+
+func (r *ScriptResultProcessor) sign(result *ProcessedAddressBatch) error {
+	if r.BatchSigner == nil {
+		return nil
+	}
+	signature, err := SignProcessedAddressBatch(*result, r.BatchSigner)
+	if err != nil {
+		return err
+	}
+	result.Signature = signature
+	return nil
+}
+
+// expandLinkedAddresses sends the addresses linked to the batch to be scanned at the same block height.
+// The batch is marked as done once the linked addresses are done.
+// If the context is cancelled before the linked addresses were sent, the batch is marked as done.
+func (r *ScriptResultProcessor) expandLinkedAddresses(ctx context.Context, result ProcessedAddressBatch) {
+	if r.LinkedAddresses == nil {
+		result.DoneHandling()
+		return
+	}
+
+	linked := r.newLinkedAddresses(result)
+	if len(linked) == 0 {
+		result.DoneHandling()
+		return
+	}
+
+	r.Logger.Debug().
+		Int("count", len(linked)).
+		Uint64("block_height", result.BlockHeight).
+		Msg("scanning linked addresses")
+
+	batch := NewAddressBatch(
+		linked,
+		result.BlockHeight,
+		result.DoneHandling,
+		result.isValid,
+	)
+	batch.spanContext = result.spanContext
+	batch.Provenance = make(map[flow.Address][]candidates.Provenance, len(linked))
+	for _, address := range linked {
+		batch.Provenance[address] = []candidates.Provenance{{
+			Scanner:     LinkedAddressesScannerName,
+			BlockHeight: result.BlockHeight,
+		}}
+	}
+	select {
+	case <-ctx.Done():
+		result.DoneHandling()
+	case r.addressBatchChan <- batch:
+	}
+}
+
+func (r *ScriptResultProcessor) newLinkedAddresses(result ProcessedAddressBatch) []flow.Address {
+	candidates := r.LinkedAddresses(result)
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the addresses in the batch were just scanned at this height,
+	// so they should not be expanded to again
+	for _, address := range result.Addresses {
+		r.expandedAt.set(address, result.BlockHeight)
+	}
+
+	linked := make([]flow.Address, 0, len(candidates))
+	for _, address := range candidates {
+		if height, ok := r.expandedAt.get(address); ok && height >= result.BlockHeight {
+			continue
+		}
+		r.expandedAt.set(address, result.BlockHeight)
+		linked = append(linked, address)
+	}
+	return linked
+}
+
 type ScriptResultHandler interface {
 	// Handle will be called concurrently for each ProcessedAddressBatch.
 	Handle(batch ProcessedAddressBatch) error
 }
+
+// ScriptResultHandlerMux dispatches the results to a handler per script name,
+// when multiple Scripts are configured.
+type ScriptResultHandlerMux map[string]ScriptResultHandler
+
+var _ ScriptResultHandler = ScriptResultHandlerMux{}
+
+func (m ScriptResultHandlerMux) Handle(batch ProcessedAddressBatch) error {
+	handler, ok := m[batch.ScriptName]
+	if !ok {
+		return fmt.Errorf("no result handler for script %q", batch.ScriptName)
+	}
+	return handler.Handle(batch)
+}
+
+// TypedResultHandler decodes the result of each batch into Go values before handling it.
+// See the decoders in the scripts package.
+type TypedResultHandler[T any] struct {
+	decode func(cadence.Value) ([]T, error)
+	handle func([]T, ProcessedAddressBatch) error
+}
+
+var _ ScriptResultHandler = (*TypedResultHandler[any])(nil)
+
+func NewTypedResultHandler[T any](
+	decode func(cadence.Value) ([]T, error),
+	handle func([]T, ProcessedAddressBatch) error,
+) *TypedResultHandler[T] {
+	return &TypedResultHandler[T]{
+		decode: decode,
+		handle: handle,
+	}
+}
+
+func (h *TypedResultHandler[T]) Handle(batch ProcessedAddressBatch) error {
+	values, err := h.decode(batch.Result)
+	if err != nil {
+		return fmt.Errorf("failed to decode the result of the batch at height %d: %w", batch.BlockHeight, err)
+	}
+	return h.handle(values, batch)
+}
+
+// FanOutResultHandler passes each batch to all of its handlers concurrently.
+// A failing handler does not stop the other handlers.
+// If IsolateErrors is true the errors are logged and returned as an IgnoredHandlerError,
+// so a failing handler does not stop the scan either.
+type FanOutResultHandler struct {
+	Handlers      []ScriptResultHandler
+	IsolateErrors bool
+
+	logger zerolog.Logger
+}
+
+var _ ScriptResultHandler = (*FanOutResultHandler)(nil)
+
+func NewFanOutResultHandler(
+	handlers []ScriptResultHandler,
+	isolateErrors bool,
+	logger zerolog.Logger,
+) *FanOutResultHandler {
+	return &FanOutResultHandler{
+		Handlers:      handlers,
+		IsolateErrors: isolateErrors,
+		logger:        logger.With().Str("component", "fan_out_result_handler").Logger(),
+	}
+}
+
+func (h *FanOutResultHandler) Handle(batch ProcessedAddressBatch) error {
+	errs := make([]error, len(h.Handlers))
+	wg := sync.WaitGroup{}
+	wg.Add(len(h.Handlers))
+	for i, handler := range h.Handlers {
+		i, handler := i, handler
+		go func() {
+			defer wg.Done()
+			errs[i] = handler.Handle(batch)
+		}()
+	}
+	wg.Wait()
+
+	merr := &multierror.Error{}
+	ignored := &multierror.Error{}
+	for i, err := range errs {
+		switch {
+		case err == nil:
+		case isIgnoredHandlerError(err):
+			// the handler has its own HandlerErrorPolicy, and already logged the error
+			ignored = multierror.Append(ignored, err)
+		case h.IsolateErrors:
+			h.logger.Error().
+				Err(err).
+				Int("handler", i).
+				Str("handler_type", fmt.Sprintf("%T", h.Handlers[i])).
+				Uint64("block_height", batch.BlockHeight).
+				Int("addresses", len(batch.Addresses)).
+				Msg("result handler failed")
+			ignored = multierror.Append(ignored, err)
+		default:
+			merr = multierror.Append(merr, err)
+		}
+	}
+	if merr.ErrorOrNil() != nil || ignored.ErrorOrNil() == nil {
+		return merr.ErrorOrNil()
+	}
+	return &IgnoredHandlerError{Err: ignored}
+}
@@ -0,0 +1,413 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScriptResultProcessor_LinkedAddresses(t *testing.T) {
+	a1, a2, a3 :=
+		flow.HexToAddress("0x1"),
+		flow.HexToAddress("0x2"),
+		flow.HexToAddress("0x3")
+
+	// a1 -> a2 -> a1, a3 -> a1
+	links := map[flow.Address][]flow.Address{
+		a1: {a2},
+		a2: {a1, a3},
+		a3: {a1},
+	}
+
+	newProcessor := func() *ScriptResultProcessor {
+		return NewScriptResultProcessor(
+			nil,
+			nil,
+			NoOpScriptResultHandler{},
+			ScriptResultProcessorConfig{
+				LinkedAddresses: func(batch ProcessedAddressBatch) []flow.Address {
+					var linked []flow.Address
+					for _, address := range batch.Addresses {
+						linked = append(linked, links[address]...)
+					}
+					return linked
+				},
+			},
+			zerolog.Nop(),
+		)
+	}
+	batchAt := func(height uint64, addresses ...flow.Address) ProcessedAddressBatch {
+		return ProcessedAddressBatch{
+			AddressBatch: NewAddressBatch(addresses, height, nil, nil),
+		}
+	}
+
+	t.Run("cycles are not expanded", func(t *testing.T) {
+		r := newProcessor()
+
+		require.Equal(t, []flow.Address{a2}, r.newLinkedAddresses(batchAt(10, a1)))
+		require.Equal(t, []flow.Address{a3}, r.newLinkedAddresses(batchAt(10, a2)))
+		require.Empty(t, r.newLinkedAddresses(batchAt(10, a3)))
+	})
+
+	t.Run("addresses are expanded again at a higher height", func(t *testing.T) {
+		r := newProcessor()
+
+		require.Equal(t, []flow.Address{a2}, r.newLinkedAddresses(batchAt(10, a1)))
+		require.Empty(t, r.newLinkedAddresses(batchAt(9, a1)))
+		require.Equal(t, []flow.Address{a2}, r.newLinkedAddresses(batchAt(11, a1)))
+	})
+
+	t.Run("heights below the window are pruned", func(t *testing.T) {
+		r := newProcessor()
+
+		require.Equal(t, []flow.Address{a2}, r.newLinkedAddresses(batchAt(10, a1)))
+		require.Equal(t, 2, r.expandedAt.len())
+		require.Equal(t, []flow.Address{a1}, r.newLinkedAddresses(batchAt(10+2*LinkedAddressesWindow, a3)))
+		// a1 and a3 were expanded at the higher height, a2 was pruned
+		_, ok := r.expandedAt.get(a2)
+		require.False(t, ok)
+		require.Equal(t, 2, r.expandedAt.len())
+	})
+
+	t.Run("sending linked addresses stops when the context is cancelled", func(t *testing.T) {
+		r := newProcessor()
+		r.addressBatchChan = make(chan AddressBatch)
+
+		done := false
+		batch := batchAt(10, a1)
+		batch.AddressBatch = NewAddressBatch(batch.Addresses, 10, func() { done = true }, nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		r.expandLinkedAddresses(ctx, batch)
+		require.True(t, done)
+	})
+}
+
+type recordingResultHandler struct {
+	batches []ProcessedAddressBatch
+}
+
+func (h *recordingResultHandler) Handle(batch ProcessedAddressBatch) error {
+	h.batches = append(h.batches, batch)
+	return nil
+}
+
+func TestScriptResultHandlerMux(t *testing.T) {
+	balances := &recordingResultHandler{}
+	storage := &recordingResultHandler{}
+	mux := ScriptResultHandlerMux{
+		"balances": balances,
+		"storage":  storage,
+	}
+
+	batch := ProcessedAddressBatch{AddressBatch: NewAddressBatch(nil, 1, nil, nil)}
+	batch.ScriptName = "storage"
+	require.NoError(t, mux.Handle(batch))
+	require.Len(t, storage.batches, 1)
+	require.Empty(t, balances.batches)
+
+	batch.ScriptName = "unknown"
+	require.Error(t, mux.Handle(batch))
+}
+
+func TestTypedResultHandler(t *testing.T) {
+	decode := func(value cadence.Value) ([]int, error) {
+		array, ok := value.(cadence.Array)
+		if !ok {
+			return nil, fmt.Errorf("expected an array, got %T", value)
+		}
+		values := make([]int, len(array.Values))
+		for i, v := range array.Values {
+			values[i] = int(v.(cadence.Int).Int())
+		}
+		return values, nil
+	}
+
+	var handled []int
+	handler := NewTypedResultHandler(decode, func(values []int, _ ProcessedAddressBatch) error {
+		handled = append(handled, values...)
+		return nil
+	})
+
+	batch := ProcessedAddressBatch{
+		AddressBatch: NewAddressBatch(nil, 1, nil, nil),
+		Result:       cadence.NewArray([]cadence.Value{cadence.NewInt(1), cadence.NewInt(2)}),
+	}
+	require.NoError(t, handler.Handle(batch))
+	require.Equal(t, []int{1, 2}, handled)
+
+	batch.Result = cadence.NewInt(1)
+	require.Error(t, handler.Handle(batch))
+}
+
+func TestScriptResultProcessor_Results(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	scriptResults := make(chan ProcessedAddressBatch)
+	results := make(chan ProcessedAddressBatch)
+	r := NewScriptResultProcessor(
+		scriptResults,
+		nil,
+		NoOpScriptResultHandler{},
+		DefaultScriptResultProcessorConfig(),
+		zerolog.Nop(),
+	)
+	r.results = results
+	<-r.Start(ctx)
+
+	done := make(chan struct{})
+	scriptResults <- ProcessedAddressBatch{
+		AddressBatch: NewAddressBatch(nil, 7, func() { close(done) }, nil),
+	}
+
+	result := <-results
+	require.Equal(t, uint64(7), result.BlockHeight)
+	// the batch is done after the result was received
+	<-done
+}
+
+// blockingResultHandler handles the batches once release is closed.
+type blockingResultHandler struct {
+	release chan struct{}
+	handled chan ProcessedAddressBatch
+}
+
+func (h *blockingResultHandler) Handle(batch ProcessedAddressBatch) error {
+	<-h.release
+	h.handled <- batch
+	return nil
+}
+
+func TestScriptResultProcessor_ResultSpill(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	scriptResults := make(chan ProcessedAddressBatch)
+	handler := &blockingResultHandler{
+		release: make(chan struct{}),
+		handled: make(chan ProcessedAddressBatch, 3),
+	}
+	config := DefaultScriptResultProcessorConfig()
+	config.MaxInFlightBatches = 1
+	config.ResultSpillDir = t.TempDir()
+	r := NewScriptResultProcessor(scriptResults, nil, handler, config, zerolog.Nop())
+	<-r.Start(ctx)
+
+	for height := uint64(1); height <= 3; height++ {
+		scriptResults <- ProcessedAddressBatch{
+			AddressBatch: NewAddressBatch(nil, height, nil, nil),
+			Result:       cadence.NewUInt64(height),
+		}
+	}
+	// the first batch is being handled, the others are spilled
+	require.Eventually(t, func() bool { return r.SpilledLen() == 2 }, time.Second, time.Millisecond)
+
+	close(handler.release)
+	results := map[uint64]cadence.Value{}
+	for i := 0; i < 3; i++ {
+		batch := <-handler.handled
+		results[batch.BlockHeight] = batch.Result
+	}
+	for height := uint64(1); height <= 3; height++ {
+		require.Equal(t, cadence.NewUInt64(height), results[height])
+	}
+	require.Equal(t, 0, r.SpilledLen())
+}
+
+func TestResultReconciler(t *testing.T) {
+	a := flow.HexToAddress("0x1")
+	b := flow.HexToAddress("0x2")
+	batch := func(height uint64, addresses ...flow.Address) ProcessedAddressBatch {
+		pairs := make([]cadence.KeyValuePair, len(addresses))
+		for i, address := range addresses {
+			pairs[i] = cadence.KeyValuePair{Key: cadence.NewAddress(address), Value: cadence.NewUInt64(height)}
+		}
+		return ProcessedAddressBatch{
+			AddressBatch: NewAddressBatch(addresses, height, nil, nil),
+			Result:       cadence.NewDictionary(pairs),
+		}
+	}
+	ctx := context.Background()
+	handle := func(r *resultReconciler, batch ProcessedAddressBatch) (ProcessedAddressBatch, int) {
+		result, dropped, err := r.reconcile(ctx, batch)
+		require.NoError(t, err)
+		r.done(result, true)
+		return result, dropped
+	}
+
+	t.Run("highest height", func(t *testing.T) {
+		r := newResultReconciler(ReconcileHighestHeight)
+
+		result, dropped := handle(r, batch(10, a, b))
+		require.Equal(t, 0, dropped)
+		require.Equal(t, []flow.Address{a, b}, result.Addresses)
+
+		// a was already handled at a higher height
+		result, dropped = handle(r, batch(5, a))
+		require.Equal(t, 1, dropped)
+		require.Empty(t, result.Addresses)
+
+		result, dropped = handle(r, batch(10, a, b))
+		require.Equal(t, 2, dropped)
+		require.Empty(t, result.Addresses)
+
+		result, dropped = handle(r, batch(11, a, b))
+		require.Equal(t, 0, dropped)
+		require.Equal(t, []flow.Address{a, b}, result.Addresses)
+	})
+
+	t.Run("first", func(t *testing.T) {
+		r := newResultReconciler(ReconcileFirst)
+
+		_, dropped := handle(r, batch(10, a))
+		require.Equal(t, 0, dropped)
+
+		result, dropped := handle(r, batch(11, a, b))
+		require.Equal(t, 1, dropped)
+		require.Equal(t, []flow.Address{b}, result.Addresses)
+		results, err := result.PerAddressResults()
+		require.NoError(t, err)
+		require.Equal(t, []AddressResult{{Address: b, Value: cadence.NewUInt64(11)}}, results)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		r := newResultReconciler(ReconcileNone)
+
+		handle(r, batch(10, a))
+		result, dropped := handle(r, batch(10, a))
+		require.Equal(t, 0, dropped)
+		require.Equal(t, []flow.Address{a}, result.Addresses)
+	})
+
+	t.Run("failed batches are not remembered", func(t *testing.T) {
+		r := newResultReconciler(ReconcileHighestHeight)
+
+		result, _, err := r.reconcile(ctx, batch(10, a))
+		require.NoError(t, err)
+		r.done(result, false)
+
+		result, dropped := handle(r, batch(10, a))
+		require.Equal(t, 0, dropped)
+		require.Equal(t, []flow.Address{a}, result.Addresses)
+	})
+
+	t.Run("batches with the same addresses are handled one after the other", func(t *testing.T) {
+		r := newResultReconciler(ReconcileHighestHeight)
+
+		first, _, err := r.reconcile(ctx, batch(20, a, b))
+		require.NoError(t, err)
+
+		reconciled := make(chan int)
+		go func() {
+			_, dropped, err := r.reconcile(ctx, batch(10, a))
+			require.NoError(t, err)
+			reconciled <- dropped
+		}()
+		select {
+		case <-reconciled:
+			require.Fail(t, "a was reconciled while it was being handled")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		r.done(first, true)
+		require.Equal(t, 1, <-reconciled)
+
+		// waiting is cancelled with the context
+		_, _, err = r.reconcile(ctx, batch(30, b))
+		require.NoError(t, err)
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, _, err = r.reconcile(cancelled, batch(30, b))
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("old heights are pruned", func(t *testing.T) {
+		r := newResultReconciler(ReconcileHighestHeight)
+
+		// the heights are pruned each time the highest height moved by the window
+		handle(r, batch(10, a))
+		handle(r, batch(10+ResultReconciliationWindow, b))
+		require.Equal(t, 2, r.handledAt.len())
+		handle(r, batch(10+2*ResultReconciliationWindow, b))
+		require.Equal(t, 1, r.handledAt.len())
+	})
+}
+
+type failingResultHandler struct{}
+
+func (failingResultHandler) Handle(ProcessedAddressBatch) error {
+	return fmt.Errorf("failed")
+}
+
+func TestFanOutResultHandler(t *testing.T) {
+	batch := ProcessedAddressBatch{AddressBatch: NewAddressBatch(nil, 1, nil, nil)}
+
+	t.Run("a failing handler does not stop the others", func(t *testing.T) {
+		recording := &recordingResultHandler{}
+		h := NewFanOutResultHandler(
+			[]ScriptResultHandler{failingResultHandler{}, recording},
+			false,
+			zerolog.Nop(),
+		)
+		require.Error(t, h.Handle(batch))
+		require.Len(t, recording.batches, 1)
+	})
+
+	t.Run("isolated errors are ignored", func(t *testing.T) {
+		recording := &recordingResultHandler{}
+		h := NewFanOutResultHandler(
+			[]ScriptResultHandler{failingResultHandler{}, recording},
+			true,
+			zerolog.Nop(),
+		)
+		var ignored *IgnoredHandlerError
+		require.ErrorAs(t, h.Handle(batch), &ignored)
+		require.Len(t, recording.batches, 1)
+	})
+}
+
+func TestScriptRunner_BatchPerScript(t *testing.T) {
+	r := &ScriptRunner{
+		ScriptRunnerConfig: ScriptRunnerConfig{
+			Scripts: map[string][]byte{
+				"storage":  nil,
+				"balances": nil,
+			},
+		},
+	}
+
+	done := 0
+	input := NewAddressBatch([]flow.Address{flow.HexToAddress("0x1")}, 1, func() { done++ }, nil)
+	batches := r.batchPerScript(input)
+	require.Len(t, batches, 2)
+	require.Equal(t, "balances", batches[0].ScriptName)
+	require.Equal(t, "storage", batches[1].ScriptName)
+
+	batches[0].DoneHandling()
+	require.Equal(t, 0, done)
+	batches[1].DoneHandling()
+	require.Equal(t, 1, done)
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"github.com/onflow/flow-go-sdk"
+)
+
+// Partition is one of Total disjoint parts of the address space, Index is from 0 to Total-1.
+// Scanner processes with the same Total and different Index each scan their own part of the addresses,
+// see Config.WithPartitions. The zero value is the whole address space.
+type Partition struct {
+	Total uint
+	Index uint
+}
+
+// IsPartitioned is true if the address space is split into more than one partition.
+func (p Partition) IsPartitioned() bool {
+	return p.Total > 1
+}
+
+// Contains is true if the address belongs to the partition.
+// The addresses are assigned by a hash of the address, so the partitions do not depend on
+// the order the addresses are enumerated in, and the old and busy accounts are spread over all partitions.
+func (p Partition) Contains(address flow.Address) bool {
+	if !p.IsPartitioned() {
+		return true
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(address[:])
+	return uint(h.Sum64()%uint64(p.Total)) == p.Index
+}
+
+func (p Partition) String() string {
+	return fmt.Sprintf("%d/%d", p.Index, p.Total)
+}
+
+// MergePartitions merges the results of the scans of all the partitions of a partitioned scan.
+// The merged scan is complete if all the partition scans are, and the stats are the sum of the stats,
+// except for the Duration, which is the longest of the partition scans.
+// The Aggregates cannot be merged in general, merge them with the fold of the aggregates instead.
+// The audits are merged if every partition scan has one.
+// It returns an error if the partitions were not scanned at the same block height.
+func MergePartitions(results ...ScanConcluded) (ScanConcluded, error) {
+	if len(results) == 0 {
+		return ScanConcluded{}, fmt.Errorf("no partition results to merge")
+	}
+	merged := ScanConcluded{
+		LatestScannedBlockHeight: results[0].LatestScannedBlockHeight,
+		ScanIsComplete:           true,
+		Stats: ScanStats{
+			Candidates: make(map[string]uint64),
+		},
+	}
+	for i, result := range results {
+		if result.LatestScannedBlockHeight != merged.LatestScannedBlockHeight {
+			return ScanConcluded{}, fmt.Errorf(
+				"partition result %d was scanned at block height %d, expected %d",
+				i,
+				result.LatestScannedBlockHeight,
+				merged.LatestScannedBlockHeight)
+		}
+		merged.ScanIsComplete = merged.ScanIsComplete && result.ScanIsComplete
+		merged.Stats = merged.Stats.Merge(result.Stats)
+	}
+	for i, result := range results {
+		if result.Audit == nil {
+			merged.Audit = nil
+			break
+		}
+		if i == 0 {
+			audit := *result.Audit
+			merged.Audit = &audit
+			continue
+		}
+		audit := merged.Audit.merge(*result.Audit)
+		merged.Audit = &audit
+	}
+	return merged, nil
+}
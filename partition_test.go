@@ -0,0 +1,84 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartition(t *testing.T) {
+	generator := flow.NewAddressGenerator(flow.Emulator)
+	partitions := []Partition{{Total: 3, Index: 0}, {Total: 3, Index: 1}, {Total: 3, Index: 2}}
+	counts := make([]int, len(partitions))
+	for i := 0; i < 3000; i++ {
+		address := generator.NextAddress()
+		contained := 0
+		for j, partition := range partitions {
+			if partition.Contains(address) {
+				contained++
+				counts[j]++
+			}
+		}
+		require.Equal(t, 1, contained, "address %s is in %d partitions", address, contained)
+		require.True(t, Partition{}.Contains(address))
+	}
+	for _, count := range counts {
+		require.InDelta(t, 1000, count, 200)
+	}
+}
+
+func TestMergePartitions(t *testing.T) {
+	failed := FailedBatch{Addresses: []flow.Address{flow.HexToAddress("01")}, BlockHeight: 10, Err: fmt.Errorf("failed")}
+
+	merged, err := MergePartitions(
+		ScanConcluded{
+			LatestScannedBlockHeight: 10,
+			ScanIsComplete:           true,
+			Stats: ScanStats{
+				AddressesScanned: 5,
+				FullScans:        1,
+				Duration:         time.Minute,
+			},
+		},
+		ScanConcluded{
+			LatestScannedBlockHeight: 10,
+			ScanIsComplete:           false,
+			Stats: ScanStats{
+				AddressesScanned: 3,
+				FailedBatches:    []FailedBatch{failed},
+				FullScans:        1,
+				Duration:         time.Second,
+			},
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), merged.LatestScannedBlockHeight)
+	require.False(t, merged.ScanIsComplete)
+	require.Equal(t, uint64(8), merged.Stats.AddressesScanned)
+	require.Equal(t, uint64(2), merged.Stats.FullScans)
+	require.Equal(t, []FailedBatch{failed}, merged.Stats.FailedBatches)
+	require.Equal(t, time.Minute, merged.Stats.Duration)
+
+	_, err = MergePartitions(
+		ScanConcluded{LatestScannedBlockHeight: 10},
+		ScanConcluded{LatestScannedBlockHeight: 11},
+	)
+	require.Error(t, err)
+}
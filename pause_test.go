@@ -0,0 +1,58 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseGate(t *testing.T) {
+	t.Run("nil gate is never paused", func(t *testing.T) {
+		var g *pauseGate
+		require.False(t, g.isPaused())
+		require.NoError(t, g.wait(context.Background()))
+	})
+	t.Run("wait blocks until resumed", func(t *testing.T) {
+		g := newPauseGate()
+		require.True(t, g.pause())
+		require.False(t, g.pause())
+
+		waited := make(chan error)
+		go func() {
+			waited <- g.wait(context.Background())
+		}()
+
+		select {
+		case <-waited:
+			require.Fail(t, "wait should block while paused")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		require.True(t, g.resume())
+		require.NoError(t, <-waited)
+		require.False(t, g.resume())
+	})
+	t.Run("wait stops when the context is done", func(t *testing.T) {
+		g := newPauseGate()
+		g.pause()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		require.ErrorIs(t, g.wait(ctx), context.Canceled)
+	})
+}
@@ -0,0 +1,197 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultProfilingLogInterval is how often the profiler logs the memory and goroutine usage.
+const DefaultProfilingLogInterval = time.Minute
+
+// componentProfileLabel is the pprof label with the name of the component that started a goroutine.
+const componentProfileLabel = "component"
+
+// profiler serves the net/http/pprof endpoints under /debug/pprof/ and the expvar vars on /debug/vars,
+// and periodically logs the heap usage and the number of goroutines per component.
+type profiler struct {
+	*ComponentBase
+
+	addr        string
+	logInterval time.Duration
+}
+
+var _ Component = (*profiler)(nil)
+
+func newProfiler(
+	addr string,
+	logInterval time.Duration,
+	logger zerolog.Logger,
+) *profiler {
+	p := &profiler{
+		addr:        addr,
+		logInterval: logInterval,
+	}
+	p.ComponentBase = NewComponentWithStart(
+		"profiler",
+		p.start,
+		logger,
+	)
+	return p
+}
+
+func (p *profiler) start(ctx context.Context) {
+	server := &http.Server{
+		Addr:              p.addr,
+		Handler:           profilingHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	p.Logger.Info().
+		Str("addr", p.addr).
+		Msg("serving /debug/pprof/ and /debug/vars")
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			p.Logger.Error().
+				Err(err).
+				Msg("profiling server error")
+		}
+	}()
+
+	go func() {
+		var tick <-chan time.Time
+		if p.logInterval > 0 {
+			ticker := time.NewTicker(p.logInterval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				if err := server.Close(); err != nil {
+					p.Logger.Warn().
+						Err(err).
+						Msg("error while closing profiling server")
+				}
+				p.Finish(ctx.Err())
+				return
+			case <-tick:
+				p.logUsage()
+			}
+		}
+	}()
+}
+
+func profilingHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+func (p *profiler) logUsage() {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	event := p.Logger.Info().
+		Uint64("heap_alloc_bytes", mem.HeapAlloc).
+		Uint64("heap_objects", mem.HeapObjects).
+		Uint64("heap_sys_bytes", mem.HeapSys).
+		Uint32("gc_cycles", mem.NumGC).
+		Int("goroutines", runtime.NumGoroutine())
+
+	perComponent, err := goroutinesPerComponent()
+	if err != nil {
+		p.Logger.Debug().
+			Err(err).
+			Msg("failed to count goroutines per component")
+	} else {
+		dict := zerolog.Dict()
+		names := make([]string, 0, len(perComponent))
+		for name := range perComponent {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			dict = dict.Int(name, perComponent[name])
+		}
+		event = event.Dict("component_goroutines", dict)
+	}
+	event.Msg("resource usage")
+}
+
+// goroutinesPerComponent counts the goroutines by the component label they were started with.
+// Goroutines without a component label are not counted.
+func goroutinesPerComponent() (map[string]int, error) {
+	buf := &bytes.Buffer{}
+	err := runtimepprof.Lookup("goroutine").WriteTo(buf, 1)
+	if err != nil {
+		return nil, err
+	}
+	return parseGoroutineLabels(buf), nil
+}
+
+// parseGoroutineLabels parses the component labels out of a goroutine profile in the debug=1 text format,
+// where each group of goroutines starts with "<count> @ <stack>", optionally followed by "# labels: {...}".
+func parseGoroutineLabels(profile *bytes.Buffer) map[string]int {
+	counts := make(map[string]int)
+	labelPrefix := "# labels: "
+	componentKey := strconv.Quote(componentProfileLabel) + ":"
+
+	count := 0
+	s := bufio.NewScanner(profile)
+	for s.Scan() {
+		line := s.Text()
+		if n, _, ok := strings.Cut(line, " @ "); ok {
+			count, _ = strconv.Atoi(n)
+			continue
+		}
+		if !strings.HasPrefix(line, labelPrefix) {
+			continue
+		}
+		labels := strings.TrimPrefix(line, labelPrefix)
+		_, value, ok := strings.Cut(labels, componentKey)
+		if !ok {
+			continue
+		}
+		// the value is quoted, and followed by either ", " or "}"
+		name, err := strconv.QuotedPrefix(value)
+		if err != nil {
+			continue
+		}
+		name, err = strconv.Unquote(name)
+		if err != nil {
+			continue
+		}
+		counts[name] += count
+	}
+	return counts
+}
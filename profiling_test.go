@@ -0,0 +1,77 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoroutinesPerComponent(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	c := NewComponentWithStart("profiled_component", func(ctx context.Context) {
+		for i := 0; i < 3; i++ {
+			go func() { <-release }()
+		}
+	}, zerolog.Nop())
+	<-c.Start(context.Background())
+
+	require.Eventually(t, func() bool {
+		counts, err := goroutinesPerComponent()
+		require.NoError(t, err)
+		return counts["profiled_component"] == 3
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestProfilingHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	profilingHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), "goroutine profile")
+}
+
+func TestProfilingHandler_ScanVars(t *testing.T) {
+	publishScanVars(func() ScanVars {
+		return newScanVars(ScanStats{
+			BatchesExecuted: 2,
+			ScriptErrors:    1,
+			Candidates:      map[string]uint64{"a": 3, "b": 4},
+		}, 100)
+	})
+
+	recorder := httptest.NewRecorder()
+	profilingHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	vars := map[string]json.RawMessage{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &vars))
+	scanVars := ScanVars{}
+	require.NoError(t, json.Unmarshal(vars[ExpvarName], &scanVars))
+	require.Equal(t, ScanVars{
+		BatchesScanned:     2,
+		CandidatesFound:    7,
+		ScriptErrors:       1,
+		LatestHandledBlock: 100,
+	}, scanVars)
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/eventfinder"
+)
+
+type redisOptions struct {
+	ttl time.Duration
+}
+
+type RedisOption func(*redisOptions)
+
+// WithRedisTTL expires the stored value after the ttl, unless it is saved again before.
+// Use it so the state of scanners that were removed does not stay in a shared redis forever.
+// The ttl has to be longer than the interval the value is saved at.
+func WithRedisTTL(ttl time.Duration) RedisOption {
+	return func(o *redisOptions) {
+		o.ttl = ttl
+	}
+}
+
+func newRedisOptions(opts []RedisOption) redisOptions {
+	var options redisOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// RedisProgressStore stores the progress in a redis key.
+type RedisProgressStore struct {
+	client redis.UniversalClient
+	key    string
+	redisOptions
+}
+
+var _ scanner.ProgressStore = (*RedisProgressStore)(nil)
+
+func NewRedisProgressStore(client redis.UniversalClient, key string, opts ...RedisOption) *RedisProgressStore {
+	return &RedisProgressStore{
+		client:       client,
+		key:          key,
+		redisOptions: newRedisOptions(opts),
+	}
+}
+
+func (s *RedisProgressStore) LoadProgress(ctx context.Context) (uint64, bool, error) {
+	value, err := s.client.Get(ctx, s.key).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	height, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid progress in redis key %s: %w", s.key, err)
+	}
+	return height, true, nil
+}
+
+func (s *RedisProgressStore) SaveProgress(ctx context.Context, height uint64) error {
+	return s.client.Set(ctx, s.key, strconv.FormatUint(height, 10), s.ttl).Err()
+}
+
+// RedisCheckpointStore stores the full scan checkpoint as JSON in a redis key.
+type RedisCheckpointStore struct {
+	client redis.UniversalClient
+	key    string
+	redisOptions
+}
+
+var _ scanner.CheckpointStore = (*RedisCheckpointStore)(nil)
+
+func NewRedisCheckpointStore(client redis.UniversalClient, key string, opts ...RedisOption) *RedisCheckpointStore {
+	return &RedisCheckpointStore{
+		client:       client,
+		key:          key,
+		redisOptions: newRedisOptions(opts),
+	}
+}
+
+func (s *RedisCheckpointStore) LoadCheckpoint(ctx context.Context) (scanner.FullScanCheckpoint, bool, error) {
+	var checkpoint scanner.FullScanCheckpoint
+	ok, err := loadRedisJSON(ctx, s.client, s.key, &checkpoint)
+	return checkpoint, ok, err
+}
+
+func (s *RedisCheckpointStore) SaveCheckpoint(ctx context.Context, checkpoint scanner.FullScanCheckpoint) error {
+	return saveRedisJSON(ctx, s.client, s.key, checkpoint, s.ttl)
+}
+
+func (s *RedisCheckpointStore) ClearCheckpoint(ctx context.Context) error {
+	return s.client.Del(ctx, s.key).Err()
+}
+
+// RedisCursorStore stores the cursor of an eventfinder.Tail as JSON in a redis key.
+type RedisCursorStore struct {
+	client redis.UniversalClient
+	key    string
+	redisOptions
+}
+
+var _ eventfinder.CursorStore = (*RedisCursorStore)(nil)
+
+func NewRedisCursorStore(client redis.UniversalClient, key string, opts ...RedisOption) *RedisCursorStore {
+	return &RedisCursorStore{
+		client:       client,
+		key:          key,
+		redisOptions: newRedisOptions(opts),
+	}
+}
+
+func (s *RedisCursorStore) LoadCursor(ctx context.Context) (eventfinder.Cursor, bool, error) {
+	var cursor eventfinder.Cursor
+	ok, err := loadRedisJSON(ctx, s.client, s.key, &cursor)
+	return cursor, ok, err
+}
+
+func (s *RedisCursorStore) SaveCursor(ctx context.Context, cursor eventfinder.Cursor) error {
+	return saveRedisJSON(ctx, s.client, s.key, cursor, s.ttl)
+}
+
+func loadRedisJSON(ctx context.Context, client redis.UniversalClient, key string, value any) (bool, error) {
+	data, err := client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return false, fmt.Errorf("invalid value in redis key %s: %w", key, err)
+	}
+	return true, nil
+}
+
+func saveRedisJSON(ctx context.Context, client redis.UniversalClient, key string, value any, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return client.Set(ctx, key, data, ttl).Err()
+}
@@ -0,0 +1,65 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progress_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/eventfinder"
+	"github.com/onflow/flow-batch-scan/progress"
+)
+
+func TestRedisStores(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	checkpoints := progress.NewRedisCheckpointStore(client, "checkpoint")
+	_, ok, err := checkpoints.LoadCheckpoint(ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+	checkpoint := scanner.FullScanCheckpoint{AddressIndex: 10, IncrementalHeight: 7}
+	require.NoError(t, checkpoints.SaveCheckpoint(ctx, checkpoint))
+	loaded, ok, err := checkpoints.LoadCheckpoint(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, checkpoint, loaded)
+	require.NoError(t, checkpoints.ClearCheckpoint(ctx))
+	_, ok, err = checkpoints.LoadCheckpoint(ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	cursors := progress.NewRedisCursorStore(client, "cursor", progress.WithRedisTTL(time.Minute))
+	cursor := eventfinder.Cursor{BlockHeight: 5, TransactionIndex: 1, EventIndex: 2}
+	require.NoError(t, cursors.SaveCursor(ctx, cursor))
+	loadedCursor, ok, err := cursors.LoadCursor(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, cursor, loadedCursor)
+
+	// the cursor expires if it is not saved again
+	server.FastForward(2 * time.Minute)
+	_, ok, err = cursors.LoadCursor(ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
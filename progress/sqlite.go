@@ -0,0 +1,92 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package progress
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+
+	scanner "github.com/onflow/flow-batch-scan"
+)
+
+// tableNameRegex matches the table names that can be used without quoting.
+var tableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLiteProgressStore stores the progress in a SQLite table, with one row per key.
+// The database driver has to be registered by the caller.
+type SQLiteProgressStore struct {
+	db    *sql.DB
+	table string
+	key   string
+}
+
+var _ scanner.ProgressStore = (*SQLiteProgressStore)(nil)
+
+// NewSQLiteProgressStore creates the table if it does not exist.
+// The key allows multiple scanners to share the same table.
+// The table name is part of the statements, so it may only contain letters, digits and underscores.
+func NewSQLiteProgressStore(
+	ctx context.Context,
+	db *sql.DB,
+	table string,
+	key string,
+) (*SQLiteProgressStore, error) {
+	if !tableNameRegex.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name %q", table)
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS %s (key TEXT PRIMARY KEY, height INTEGER NOT NULL)`,
+		table,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLiteProgressStore{
+		db:    db,
+		table: table,
+		key:   key,
+	}, nil
+}
+
+func (s *SQLiteProgressStore) LoadProgress(ctx context.Context) (uint64, bool, error) {
+	var height uint64
+	err := s.db.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT height FROM %s WHERE key = ?`, s.table),
+		s.key,
+	).Scan(&height)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return height, true, nil
+}
+
+func (s *SQLiteProgressStore) SaveProgress(ctx context.Context, height uint64) error {
+	_, err := s.db.ExecContext(ctx,
+		fmt.Sprintf(
+			`INSERT INTO %s (key, height) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET height = excluded.height`,
+			s.table,
+		),
+		s.key,
+		height,
+	)
+	return err
+}
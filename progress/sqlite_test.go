@@ -0,0 +1,62 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progress_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+
+	"github.com/onflow/flow-batch-scan/progress"
+)
+
+func TestSQLiteProgressStore(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "progress.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	t.Run("progress is saved per key", func(t *testing.T) {
+		store, err := progress.NewSQLiteProgressStore(ctx, db, "scan_progress", "a")
+		require.NoError(t, err)
+		_, ok, err := store.LoadProgress(ctx)
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		require.NoError(t, store.SaveProgress(ctx, 10))
+		require.NoError(t, store.SaveProgress(ctx, 11))
+		height, ok, err := store.LoadProgress(ctx)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, uint64(11), height)
+
+		other, err := progress.NewSQLiteProgressStore(ctx, db, "scan_progress", "b")
+		require.NoError(t, err)
+		_, ok, err = other.LoadProgress(ctx)
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("invalid table names are rejected", func(t *testing.T) {
+		for _, table := range []string{"", "1progress", "progress; DROP TABLE scan_progress", "scan-progress"} {
+			_, err := progress.NewSQLiteProgressStore(ctx, db, table, "a")
+			require.ErrorContains(t, err, "invalid table name", table)
+		}
+	})
+}
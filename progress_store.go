@@ -0,0 +1,88 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ProgressStore persists the latest block height handled by the incremental scanner,
+// so that a restarted scanner can resume from it instead of doing a full scan.
+type ProgressStore interface {
+	// LoadProgress returns the stored block height. ok is false if no height was stored yet.
+	LoadProgress(ctx context.Context) (height uint64, ok bool, err error)
+	// SaveProgress stores the block height.
+	SaveProgress(ctx context.Context, height uint64) error
+}
+
+// FileProgressStore stores the progress in a file.
+type FileProgressStore struct {
+	path string
+}
+
+var _ ProgressStore = (*FileProgressStore)(nil)
+
+func NewFileProgressStore(path string) *FileProgressStore {
+	return &FileProgressStore{
+		path: path,
+	}
+}
+
+func (s *FileProgressStore) LoadProgress(_ context.Context) (uint64, bool, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	height, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid progress in %s: %w", s.path, err)
+	}
+	return height, true, nil
+}
+
+func (s *FileProgressStore) SaveProgress(_ context.Context, height uint64) error {
+	return writeFileAtomic(s.path, []byte(strconv.FormatUint(height, 10)))
+}
+
+// writeFileAtomic writes the data to a temporary file first, so that the file is never partially written.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,41 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package scanner_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	scan "github.com/onflow/flow-batch-scan"
+)
+
+func TestFileProgressStore(t *testing.T) {
+	ctx := context.Background()
+	store := scan.NewFileProgressStore(filepath.Join(t.TempDir(), "progress"))
+
+	_, ok, err := store.LoadProgress(ctx)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, store.SaveProgress(ctx, 10))
+	require.NoError(t, store.SaveProgress(ctx, 12))
+
+	height, ok, err := store.LoadProgress(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(12), height)
+}
@@ -0,0 +1,115 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// QueueSampleInterval is how often the depth of the internal queues is sampled, for their high-water marks.
+const QueueSampleInterval = 100 * time.Millisecond
+
+// UnboundedQueueCapacity is the QueueStats.Capacity of the queues that grow as needed.
+const UnboundedQueueCapacity = -1
+
+// QueueStats is the state of one of the internal queues of a scan.
+// A queue that is full, while the queue after it is empty, points to the stage between them as the bottleneck.
+type QueueStats struct {
+	Name string `json:"name"`
+	// Depth is the number of items waiting in the queue.
+	Depth int `json:"depth"`
+	// Capacity is the size of the queue, 0 if it is unbuffered, or UnboundedQueueCapacity.
+	Capacity int `json:"capacity"`
+	// HighWaterMark is the highest sampled depth since the scan started.
+	HighWaterMark int `json:"high_water_mark"`
+}
+
+// queueMonitor samples the depths of the internal queues of a scan, and reports them.
+type queueMonitor struct {
+	mu     sync.Mutex
+	queues []monitoredQueue
+}
+
+type monitoredQueue struct {
+	QueueStats
+	depth func() int
+}
+
+func newQueueMonitor() *queueMonitor {
+	return &queueMonitor{}
+}
+
+// add monitors the queue with the name. depth returns the current depth of the queue.
+func (m *queueMonitor) add(name string, capacity int, depth func() int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queues = append(m.queues, monitoredQueue{
+		QueueStats: QueueStats{
+			Name:     name,
+			Capacity: capacity,
+		},
+		depth: depth,
+	})
+}
+
+// sample updates the depths and the high-water marks of the queues.
+func (m *queueMonitor) sample() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.queues {
+		queue := &m.queues[i]
+		queue.Depth = queue.depth()
+		if queue.Depth > queue.HighWaterMark {
+			queue.HighWaterMark = queue.Depth
+		}
+	}
+}
+
+// stats samples the queues, and returns their stats in the order they were added.
+func (m *queueMonitor) stats() []QueueStats {
+	m.sample()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := make([]QueueStats, len(m.queues))
+	for i, queue := range m.queues {
+		stats[i] = queue.QueueStats
+	}
+	return stats
+}
+
+// run samples the queues every QueueSampleInterval, and reports them every QueueDepthReportInterval,
+// until the context is done.
+func (m *queueMonitor) run(ctx context.Context, reporter StatusReporter) {
+	sampleTicker := time.NewTicker(QueueSampleInterval)
+	defer sampleTicker.Stop()
+	reportTicker := time.NewTicker(QueueDepthReportInterval)
+	defer reportTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sampleTicker.C:
+			m.sample()
+		case <-reportTicker.C:
+			for _, queue := range m.stats() {
+				reporter.ReportQueueDepth(queue.Name, queue.Depth)
+				reporter.ReportQueueHighWaterMark(queue.Name, queue.HighWaterMark)
+			}
+		}
+	}
+}
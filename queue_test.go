@@ -0,0 +1,40 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueMonitor(t *testing.T) {
+	queue := make(chan int, 4)
+	m := newQueueMonitor()
+	m.add("numbers", cap(queue), func() int { return len(queue) })
+	m.add("overflow", UnboundedQueueCapacity, func() int { return 0 })
+
+	queue <- 1
+	queue <- 2
+	queue <- 3
+	m.sample()
+	<-queue
+	<-queue
+
+	require.Equal(t, []QueueStats{
+		{Name: "numbers", Depth: 1, Capacity: 4, HighWaterMark: 3},
+		{Name: "overflow", Depth: 0, Capacity: UnboundedQueueCapacity, HighWaterMark: 0},
+	}, m.stats())
+}
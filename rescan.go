@@ -0,0 +1,165 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/onflow/flow-go-sdk"
+)
+
+// FailedAddresses returns the addresses of the batches that failed during the scan.
+func (c ScanConcluded) FailedAddresses() []flow.Address {
+	return FailedAddresses(c.Stats.FailedBatches)
+}
+
+// Rescan runs the scripts again for the addresses of the failed batches (see ScanConcluded.Stats and ReadFailedBatches),
+// each at the block height it originally failed at. The results are handled by the same ScriptResultHandler.
+// Only the scripts are run, there is no full or incremental scan.
+// The batches that fail again are in the Stats of the returned ScanConcluded.
+func (scanner *Scanner) Rescan(ctx context.Context, failed []FailedBatch) (ScanConcluded, error) {
+	scanStart := time.Now()
+	stats := newStatsCollector()
+	tracer := newTracer(scanner.config.TracerProvider)
+
+	scriptRequestChan := make(chan AddressBatch, scanner.config.AddressBatchQueueSize)
+	scriptResultChan := make(chan ProcessedAddressBatch, scanner.config.ScriptResultQueueSize)
+
+	chainID, err := scanner.chainID(ctx)
+	if err != nil {
+		return ScanConcluded{}, err
+	}
+	scriptRunnerConfig, err := scanner.config.ScriptRunnerConfig.resolveImports(chainID)
+	if err != nil {
+		return ScanConcluded{}, err
+	}
+	scriptRunner := NewScriptRunner(
+		scanner.scriptClient(),
+		scriptRequestChan,
+		scriptResultChan,
+		scriptRunnerConfig,
+		scanner.logger,
+	)
+	scriptRunner.pause = scanner.pause
+	scriptRunner.stats = stats
+	scriptRunner.reporter = scanner.config.Reporter
+	scriptRunner.tracer = tracer
+	scriptResultProcessor := NewScriptResultProcessor(
+		scriptResultChan,
+		scriptRequestChan,
+		scanner.resultHandler(),
+		scanner.config.ScriptResultProcessorConfig,
+		scanner.logger,
+	)
+	scriptResultProcessor.hooks = scanner.config.Hooks
+	scriptResultProcessor.stats = stats
+	scriptResultProcessor.reporter = scanner.config.Reporter
+	scriptResultProcessor.tracer = tracer
+	components := []Component{scriptRunner, scriptResultProcessor}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for _, component := range components {
+		<-component.Start(ctx)
+	}
+
+	wg := &sync.WaitGroup{}
+	batches := rescanBatches(failed, scanner.tuning.getBatchSize(), wg.Done)
+	wg.Add(len(batches))
+	scanner.logger.Info().
+		Int("batches", len(batches)).
+		Int("addresses", len(FailedAddresses(failed))).
+		Msg("Rescanning failed addresses")
+
+	go func() {
+		for _, batch := range batches {
+			select {
+			case <-ctx.Done():
+				return
+			case scriptRequestChan <- batch:
+			}
+		}
+	}()
+
+	rescanned := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(rescanned)
+	}()
+	anyFinished := make(chan struct{})
+	go func() {
+		waitForAnyComponentToFinish(components...)
+		close(anyFinished)
+	}()
+	select {
+	case <-rescanned:
+	case <-anyFinished:
+	}
+	cancel()
+
+	merr := &multierror.Error{}
+	for _, component := range components {
+		<-component.Done()
+		if component.Err() != nil && !errors.Is(component.Err(), context.Canceled) {
+			merr = multierror.Append(merr, component.Err())
+		}
+	}
+
+	concluded := ScanConcluded{
+		Stats:      stats.snapshot(time.Since(scanStart)),
+		Aggregates: aggregates(scanner.config.Aggregates),
+	}
+	concluded.ScanIsComplete = len(concluded.Stats.FailedBatches) == 0 && merr.ErrorOrNil() == nil
+	return concluded, merr.ErrorOrNil()
+}
+
+// rescanBatches groups the addresses of the failed batches by block height and script,
+// into batches of at most batchSize addresses.
+func rescanBatches(failed []FailedBatch, batchSize int, done func()) []AddressBatch {
+	type key struct {
+		blockHeight uint64
+		scriptName  string
+	}
+	var keys []key
+	addresses := make(map[key][]flow.Address)
+	for _, batch := range failed {
+		k := key{blockHeight: batch.BlockHeight, scriptName: batch.ScriptName}
+		if _, ok := addresses[k]; !ok {
+			keys = append(keys, k)
+		}
+		addresses[k] = append(addresses[k], batch.Addresses...)
+	}
+
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	var batches []AddressBatch
+	for _, k := range keys {
+		for start := 0; start < len(addresses[k]); start += batchSize {
+			end := start + batchSize
+			if end > len(addresses[k]) {
+				end = len(addresses[k])
+			}
+			batch := NewAddressBatch(addresses[k][start:end], k.blockHeight, done, nil)
+			batch.ScriptName = k.scriptName
+			batches = append(batches, batch)
+		}
+	}
+	return batches
+}
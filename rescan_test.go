@@ -0,0 +1,44 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRescanBatches(t *testing.T) {
+	a := flow.HexToAddress("01")
+	b := flow.HexToAddress("02")
+	c := flow.HexToAddress("03")
+
+	batches := rescanBatches([]FailedBatch{
+		{Addresses: []flow.Address{a}, BlockHeight: 10},
+		{Addresses: []flow.Address{c}, BlockHeight: 11},
+		{Addresses: []flow.Address{b, c}, BlockHeight: 10},
+		{Addresses: []flow.Address{a}, BlockHeight: 10, ScriptName: "other"},
+	}, 2, nil)
+
+	require.Len(t, batches, 4)
+	require.Equal(t, []flow.Address{a, b}, batches[0].Addresses)
+	require.Equal(t, uint64(10), batches[0].BlockHeight)
+	require.Equal(t, []flow.Address{c}, batches[1].Addresses)
+	require.Equal(t, uint64(10), batches[1].BlockHeight)
+	require.Equal(t, []flow.Address{c}, batches[2].Addresses)
+	require.Equal(t, uint64(11), batches[2].BlockHeight)
+	require.Equal(t, "other", batches[3].ScriptName)
+}
@@ -0,0 +1,275 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+)
+
+// ResultReconciliation decides which results of an address are handled, when the address is scanned
+// more than once in a scan, e.g. by the full scan and right after by the incremental scanner.
+// The addresses whose results are not handled are removed from the batch and its result,
+// which has to follow one of the conventions of ProcessedAddressBatch.PerAddressResults.
+// Batches with other results are handled as they are.
+type ResultReconciliation int
+
+const (
+	// ReconcileNone handles every result, the handler has to deduplicate the results itself. This is the default.
+	ReconcileNone ResultReconciliation = iota
+	// ReconcileHighestHeight only handles the result of an address, if no result of the address
+	// at the same or a higher block height was handled in the scan.
+	// The batches with the same addresses are handled one after the other, so the handler sees the results
+	// of each address with increasing block heights, and no duplicates.
+	// The heights are remembered for ResultReconciliationWindow blocks below the highest handled height.
+	ReconcileHighestHeight
+	// ReconcileFirst only handles the first result of each address in the scan.
+	// The addresses are remembered for the whole scan, so it can not be combined with a continuous scan.
+	ReconcileFirst
+)
+
+// ResultReconciliationWindow is the number of blocks below the highest handled block height
+// for which ReconcileHighestHeight remembers the heights the addresses were handled at.
+// The full scan and the incremental scanner both scan close to the latest block,
+// so older heights are not compared against anymore.
+const ResultReconciliationWindow = 1000
+
+// resultReconciler remembers the block height each address was handled at in a scan.
+// A nil reconciler keeps all the results.
+type resultReconciler struct {
+	policy ResultReconciliation
+
+	mu        sync.Mutex
+	handledAt *addressHeights
+	// handling are the addresses of the batches that are being handled,
+	// with a channel per batch that is closed once the batch was handled.
+	handling map[flow.Address]chan struct{}
+}
+
+func newResultReconciler(policy ResultReconciliation) *resultReconciler {
+	if policy == ReconcileNone {
+		return nil
+	}
+	var window uint64
+	if policy == ReconcileHighestHeight {
+		window = ResultReconciliationWindow
+	}
+	return &resultReconciler{
+		policy:    policy,
+		handledAt: newAddressHeights(window),
+		handling:  make(map[flow.Address]chan struct{}),
+	}
+}
+
+// reconcile waits until no other batch with the same addresses is being handled,
+// removes the addresses whose results should not be handled from the batch,
+// and returns the number of removed addresses. The remaining addresses are being handled until done is called.
+// It fails if the context is cancelled while waiting.
+func (r *resultReconciler) reconcile(ctx context.Context, batch ProcessedAddressBatch) (ProcessedAddressBatch, int, error) {
+	if r == nil {
+		return batch, 0, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for {
+		handled := r.handlingAny(batch.Addresses)
+		if handled == nil {
+			break
+		}
+		r.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			r.mu.Lock()
+			return batch, 0, ctx.Err()
+		case <-handled:
+		}
+		r.mu.Lock()
+	}
+
+	keep := make(map[flow.Address]struct{}, len(batch.Addresses))
+	for _, address := range batch.Addresses {
+		height, handled := r.handledAt.get(address)
+		switch {
+		case !handled:
+		case r.policy == ReconcileHighestHeight && batch.BlockHeight > height:
+		default:
+			continue
+		}
+		keep[address] = struct{}{}
+	}
+
+	dropped := len(batch.Addresses) - len(keep)
+	if dropped > 0 {
+		filtered, err := filterResult(batch, keep)
+		if err != nil {
+			// the result cannot be split by address, so it is handled as it is
+			dropped = 0
+		} else {
+			batch = filtered
+		}
+	}
+	if len(batch.Addresses) > 0 {
+		handling := make(chan struct{})
+		for _, address := range batch.Addresses {
+			r.handling[address] = handling
+		}
+	}
+	return batch, dropped, nil
+}
+
+// handlingAny returns the channel of a batch that is being handled with one of the addresses, or nil.
+func (r *resultReconciler) handlingAny(addresses []flow.Address) <-chan struct{} {
+	for _, address := range addresses {
+		if handling, ok := r.handling[address]; ok {
+			return handling
+		}
+	}
+	return nil
+}
+
+// done ends the handling of a reconciled batch. The block height of the batch is remembered,
+// if the batch was handled successfully.
+func (r *resultReconciler) done(batch ProcessedAddressBatch, handled bool) {
+	if r == nil || len(batch.Addresses) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	handling := r.handling[batch.Addresses[0]]
+	for _, address := range batch.Addresses {
+		delete(r.handling, address)
+		if handled {
+			r.handledAt.set(address, batch.BlockHeight)
+		}
+	}
+	if handling != nil {
+		close(handling)
+	}
+}
+
+// addressHeights are the latest block heights of addresses. With a window, the heights more than
+// window blocks below the highest height are pruned, so the memory is bounded by the recent addresses.
+// It is not safe for concurrent use.
+type addressHeights struct {
+	window  uint64
+	heights map[flow.Address]uint64
+	highest uint64
+	// prunedAt is the highest height at the last pruning.
+	prunedAt uint64
+}
+
+func newAddressHeights(window uint64) *addressHeights {
+	return &addressHeights{
+		window:  window,
+		heights: make(map[flow.Address]uint64),
+	}
+}
+
+func (h *addressHeights) get(address flow.Address) (uint64, bool) {
+	height, ok := h.heights[address]
+	return height, ok
+}
+
+// set raises the height of the address, and prunes the old heights once the highest height moved a window.
+func (h *addressHeights) set(address flow.Address, height uint64) {
+	if current, ok := h.heights[address]; ok && current >= height {
+		return
+	}
+	h.heights[address] = height
+	if height <= h.highest {
+		return
+	}
+	h.highest = height
+	if h.window == 0 || h.highest < h.prunedAt+h.window {
+		return
+	}
+	h.prunedAt = h.highest
+	for address, height := range h.heights {
+		if height+h.window < h.highest {
+			delete(h.heights, address)
+		}
+	}
+}
+
+func (h *addressHeights) len() int {
+	return len(h.heights)
+}
+
+// filterResult removes the addresses that are not in keep from the batch and its result.
+func filterResult(batch ProcessedAddressBatch, keep map[flow.Address]struct{}) (ProcessedAddressBatch, error) {
+	kept := func(address flow.Address) bool {
+		_, ok := keep[address]
+		return ok
+	}
+
+	switch result := batch.Result.(type) {
+	case cadence.Dictionary:
+		pairs := make([]cadence.KeyValuePair, 0, len(result.Pairs))
+		for _, pair := range result.Pairs {
+			address, ok := pair.Key.(cadence.Address)
+			if !ok {
+				return batch, fmt.Errorf("expected Address key, got %T", pair.Key)
+			}
+			if kept(flow.Address(address)) {
+				pairs = append(pairs, pair)
+			}
+		}
+		batch.Result = cadence.NewDictionary(pairs).WithType(result.DictionaryType)
+	case cadence.Array:
+		values := make([]cadence.Value, 0, len(result.Values))
+		if len(result.Values) > 0 && addressField(result.Values[0]) != nil {
+			for _, value := range result.Values {
+				address, ok := addressField(value).(cadence.Address)
+				if !ok {
+					return batch, fmt.Errorf("expected an address field, got %T", value)
+				}
+				if kept(flow.Address(address)) {
+					values = append(values, value)
+				}
+			}
+		} else {
+			if len(result.Values) != len(batch.Addresses) {
+				return batch, fmt.Errorf(
+					"expected %d results, one for each address, got %d",
+					len(batch.Addresses),
+					len(result.Values))
+			}
+			for i, value := range result.Values {
+				if kept(batch.Addresses[i]) {
+					values = append(values, value)
+				}
+			}
+		}
+		batch.Result = cadence.NewArray(values).WithType(result.ArrayType)
+	default:
+		return batch, fmt.Errorf("cannot split result of type %T by address", batch.Result)
+	}
+
+	addresses := make([]flow.Address, 0, len(keep))
+	for _, address := range batch.Addresses {
+		if kept(address) {
+			addresses = append(addresses, address)
+		}
+	}
+	batch.Addresses = addresses
+	batch.Provenance = provenanceOf(addresses, batch.Provenance)
+	return batch, nil
+}
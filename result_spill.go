@@ -0,0 +1,132 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+)
+
+// resultSpill is an unbounded queue of processed batches, whose results are kept in files instead of in memory.
+// The rest of the batch (e.g. the addresses) stays in memory.
+// It has a single consumer, see ScriptResultProcessorConfig.ResultSpillDir.
+type resultSpill struct {
+	dir string
+
+	mu      sync.Mutex
+	batches []spilledBatch
+	notify  chan struct{}
+}
+
+type spilledBatch struct {
+	// batch is the batch without its Result.
+	batch ProcessedAddressBatch
+	path  string
+}
+
+// newResultSpill creates the queue in a new directory in parent, which is removed by close.
+func newResultSpill(parent string) (*resultSpill, error) {
+	dir, err := os.MkdirTemp(parent, "result-spill-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the result spill directory: %w", err)
+	}
+	return &resultSpill{
+		dir:    dir,
+		notify: make(chan struct{}, 1),
+	}, nil
+}
+
+// push writes the result of the batch to a file, and queues the rest of the batch.
+func (s *resultSpill) push(batch ProcessedAddressBatch) error {
+	encoded, err := jsoncdc.Encode(batch.Result)
+	if err != nil {
+		return fmt.Errorf("failed to encode the result of the batch at height %d: %w", batch.BlockHeight, err)
+	}
+	file, err := os.CreateTemp(s.dir, "batch-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to spill the result of the batch at height %d: %w", batch.BlockHeight, err)
+	}
+	_, err = file.Write(encoded)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return fmt.Errorf("failed to spill the result of the batch at height %d: %w", batch.BlockHeight, err)
+	}
+
+	batch.Result = nil
+	s.mu.Lock()
+	s.batches = append(s.batches, spilledBatch{batch: batch, path: file.Name()})
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (s *resultSpill) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.batches)
+}
+
+// wait waits until there is a batch in the queue, or the context is done.
+func (s *resultSpill) wait(ctx context.Context) error {
+	for s.len() == 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.notify:
+		}
+	}
+	return nil
+}
+
+// take removes the first batch from the queue, and reads its result back.
+// The queue must not be empty, see wait.
+func (s *resultSpill) take() (ProcessedAddressBatch, error) {
+	s.mu.Lock()
+	spilled := s.batches[0]
+	s.batches[0] = spilledBatch{}
+	s.batches = s.batches[1:]
+	s.mu.Unlock()
+
+	defer func() { _ = os.Remove(spilled.path) }()
+	encoded, err := os.ReadFile(spilled.path)
+	if err != nil {
+		return ProcessedAddressBatch{}, fmt.Errorf("failed to read the spilled result of the batch at height %d: %w",
+			spilled.batch.BlockHeight, err)
+	}
+	result, err := jsoncdc.Decode(nil, encoded)
+	if err != nil {
+		return ProcessedAddressBatch{}, fmt.Errorf("failed to decode the spilled result of the batch at height %d: %w",
+			spilled.batch.BlockHeight, err)
+	}
+	batch := spilled.batch
+	batch.Result = result
+	return batch, nil
+}
+
+// close removes the directory of the queue, with the results that were not taken.
+func (s *resultSpill) close() error {
+	return os.RemoveAll(s.dir)
+}
@@ -0,0 +1,306 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/cadence/runtime/ast"
+	"github.com/onflow/cadence/runtime/parser"
+)
+
+// ResultTypeError is the error of a script result that does not have the expected type,
+// e.g. because the script or a contract it uses changed. See WithExpectedResultType.
+// Path is where in the result the mismatch is, e.g. `[3].balance`.
+type ResultTypeError struct {
+	Path     string
+	Expected cadence.Type
+	Value    cadence.Value
+}
+
+var _ error = (*ResultTypeError)(nil)
+
+func (e *ResultTypeError) Error() string {
+	actual := "nil"
+	if e.Value != nil && e.Value.Type() != nil {
+		actual = e.Value.Type().ID()
+	} else if e.Value != nil {
+		actual = fmt.Sprintf("%T", e.Value)
+	}
+	if e.Path == "" {
+		return fmt.Sprintf("unexpected result type: expected %s, got %s", e.Expected.ID(), actual)
+	}
+	return fmt.Sprintf("unexpected result type at %s: expected %s, got %s", e.Path, e.Expected.ID(), actual)
+}
+
+// CheckResultType checks that the value has the type, e.g. that the result of a script has its expected type.
+//   - AnyStruct and AnyResource match any value,
+//   - the abstract number types, e.g. Integer or FixedPoint, match the values of their subtypes,
+//   - a composite type matches the composites with the same qualified identifier, e.g. `FungibleToken.Vault`,
+//     and their fields are checked against the fields of the type, if it has any. Extra fields are ignored,
+//   - a cadence.TypeID, like the composite types of ParseResultType, matches the composites
+//     with that qualified identifier or type ID, without checking their fields.
+//
+// It returns a ResultTypeError for the first mismatch.
+func CheckResultType(value cadence.Value, typ cadence.Type) error {
+	err := checkResultType(value, typ)
+	if err == nil {
+		return nil
+	}
+	return err
+}
+
+func checkResultType(value cadence.Value, typ cadence.Type) *ResultTypeError {
+	mismatch := &ResultTypeError{Expected: typ, Value: value}
+	if value == nil {
+		return mismatch
+	}
+
+	switch typ := typ.(type) {
+	case cadence.AnyType, cadence.AnyStructType, cadence.AnyResourceType:
+		return nil
+
+	case *cadence.OptionalType:
+		optional, ok := value.(cadence.Optional)
+		if !ok {
+			return mismatch
+		}
+		if optional.Value == nil {
+			return nil
+		}
+		return checkResultType(optional.Value, typ.Type)
+
+	case *cadence.VariableSizedArrayType:
+		array, ok := value.(cadence.Array)
+		if !ok {
+			return mismatch
+		}
+		return checkElementTypes(array, typ.ElementType)
+
+	case *cadence.ConstantSizedArrayType:
+		array, ok := value.(cadence.Array)
+		if !ok || uint(len(array.Values)) != typ.Size {
+			return mismatch
+		}
+		return checkElementTypes(array, typ.ElementType)
+
+	case *cadence.DictionaryType:
+		dictionary, ok := value.(cadence.Dictionary)
+		if !ok {
+			return mismatch
+		}
+		for _, pair := range dictionary.Pairs {
+			if err := checkResultType(pair.Key, typ.KeyType); err != nil {
+				return err
+			}
+			if err := checkResultType(pair.Value, typ.ElementType); err != nil {
+				return err.withPath(fmt.Sprintf("[%s]", pair.Key))
+			}
+		}
+		return nil
+
+	case cadence.TypeID:
+		composite, ok := value.Type().(cadence.CompositeType)
+		if !ok || (composite.CompositeTypeQualifiedIdentifier() != string(typ) && composite.ID() != string(typ)) {
+			return mismatch
+		}
+		return nil
+
+	case cadence.CompositeType:
+		composite, ok := value.Type().(cadence.CompositeType)
+		if !ok || composite.CompositeTypeQualifiedIdentifier() != typ.CompositeTypeQualifiedIdentifier() {
+			return mismatch
+		}
+		return checkFieldTypes(value, typ)
+
+	case cadence.NumberType, cadence.SignedNumberType,
+		cadence.IntegerType, cadence.SignedIntegerType,
+		cadence.FixedPointType, cadence.SignedFixedPointType:
+		if value.Type() == nil || !isNumberSubtype(value.Type().ID(), typ.ID()) {
+			return mismatch
+		}
+		return nil
+
+	case cadence.PathType:
+		if _, ok := value.(cadence.Path); !ok {
+			return mismatch
+		}
+		return nil
+
+	case cadence.CapabilityPathType:
+		path, ok := value.(cadence.Path)
+		if !ok || (path.Type() != cadence.ThePublicPathType && path.Type() != cadence.ThePrivatePathType) {
+			return mismatch
+		}
+		return nil
+	}
+
+	if value.Type() == nil || value.Type().ID() != typ.ID() {
+		return mismatch
+	}
+	return nil
+}
+
+func checkElementTypes(array cadence.Array, elementType cadence.Type) *ResultTypeError {
+	for i, element := range array.Values {
+		if err := checkResultType(element, elementType); err != nil {
+			return err.withPath(fmt.Sprintf("[%d]", i))
+		}
+	}
+	return nil
+}
+
+func checkFieldTypes(value cadence.Value, typ cadence.CompositeType) *ResultTypeError {
+	composite, ok := value.(cadence.HasFields)
+	if !ok {
+		return &ResultTypeError{Expected: typ, Value: value}
+	}
+	actualFields := composite.GetFields()
+	values := composite.GetFieldValues()
+	for _, field := range typ.CompositeFields() {
+		index := -1
+		for i, actual := range actualFields {
+			if actual.Identifier == field.Identifier {
+				index = i
+				break
+			}
+		}
+		if index < 0 || index >= len(values) {
+			return (&ResultTypeError{Expected: field.Type}).withPath("." + field.Identifier)
+		}
+		if err := checkResultType(values[index], field.Type); err != nil {
+			return err.withPath("." + field.Identifier)
+		}
+	}
+	return nil
+}
+
+// withPath prefixes the path of the error with segment.
+func (e *ResultTypeError) withPath(segment string) *ResultTypeError {
+	e.Path = segment + e.Path
+	return e
+}
+
+// isNumberSubtype is true if the number type is a subtype of the abstract number type.
+func isNumberSubtype(id string, abstract string) bool {
+	signedInteger := strings.HasPrefix(id, "Int")
+	integer := signedInteger || strings.HasPrefix(id, "UInt") || strings.HasPrefix(id, "Word")
+	signedFixedPoint := id == "Fix64"
+	fixedPoint := signedFixedPoint || id == "UFix64"
+
+	switch abstract {
+	case "Number":
+		return integer || fixedPoint
+	case "SignedNumber":
+		return signedInteger || signedFixedPoint
+	case "Integer":
+		return integer
+	case "SignedInteger":
+		return signedInteger
+	case "FixedPoint":
+		return fixedPoint
+	case "SignedFixedPoint":
+		return signedFixedPoint
+	}
+	return false
+}
+
+// primitiveTypes are the types of ParseResultType that are not composites, by name.
+var primitiveTypes = func() map[string]cadence.Type {
+	types := []cadence.Type{
+		cadence.AnyType{}, cadence.AnyStructType{}, cadence.AnyResourceType{}, cadence.MetaType{},
+		cadence.VoidType{}, cadence.NeverType{}, cadence.BoolType{}, cadence.StringType{}, cadence.CharacterType{},
+		cadence.AddressType{}, cadence.NumberType{}, cadence.SignedNumberType{},
+		cadence.IntegerType{}, cadence.SignedIntegerType{}, cadence.FixedPointType{}, cadence.SignedFixedPointType{},
+		cadence.IntType{}, cadence.Int8Type{}, cadence.Int16Type{}, cadence.Int32Type{}, cadence.Int64Type{},
+		cadence.Int128Type{}, cadence.Int256Type{},
+		cadence.UIntType{}, cadence.UInt8Type{}, cadence.UInt16Type{}, cadence.UInt32Type{}, cadence.UInt64Type{},
+		cadence.UInt128Type{}, cadence.UInt256Type{},
+		cadence.Word8Type{}, cadence.Word16Type{}, cadence.Word32Type{}, cadence.Word64Type{},
+		cadence.Word128Type{}, cadence.Word256Type{},
+		cadence.Fix64Type{}, cadence.UFix64Type{},
+		cadence.PathType{}, cadence.CapabilityPathType{},
+		cadence.StoragePathType{}, cadence.PublicPathType{}, cadence.PrivatePathType{},
+	}
+	byName := make(map[string]cadence.Type, len(types))
+	for _, typ := range types {
+		byName[typ.ID()] = typ
+	}
+	return byName
+}()
+
+// ParseResultType parses a Cadence type, e.g. `{Address: UFix64}` or `[FlowIDTableStaking.DelegatorInfo?]`,
+// for WithExpectedResultType. The other named types are composites, which are matched by their qualified identifier.
+// Arrays, dictionaries and optionals are supported, references, restricted and function types are not.
+func ParseResultType(typ string) (cadence.Type, error) {
+	parsed, errs := parser.ParseType(nil, []byte(typ), parser.Config{})
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to parse type %q: %w", typ, errs[0])
+	}
+	converted, err := convertType(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse type %q: %w", typ, err)
+	}
+	return converted, nil
+}
+
+func convertType(typ ast.Type) (cadence.Type, error) {
+	switch typ := typ.(type) {
+	case *ast.NominalType:
+		name := typ.String()
+		if primitive, ok := primitiveTypes[name]; ok {
+			return primitive, nil
+		}
+		return cadence.TypeID(name), nil
+
+	case *ast.OptionalType:
+		inner, err := convertType(typ.Type)
+		if err != nil {
+			return nil, err
+		}
+		return cadence.NewOptionalType(inner), nil
+
+	case *ast.VariableSizedType:
+		element, err := convertType(typ.Type)
+		if err != nil {
+			return nil, err
+		}
+		return cadence.NewVariableSizedArrayType(element), nil
+
+	case *ast.ConstantSizedType:
+		element, err := convertType(typ.Type)
+		if err != nil {
+			return nil, err
+		}
+		if typ.Size == nil || typ.Size.Value == nil || !typ.Size.Value.IsUint64() {
+			return nil, fmt.Errorf("invalid size of the array type %s", typ)
+		}
+		return cadence.NewConstantSizedArrayType(uint(typ.Size.Value.Uint64()), element), nil
+
+	case *ast.DictionaryType:
+		key, err := convertType(typ.KeyType)
+		if err != nil {
+			return nil, err
+		}
+		value, err := convertType(typ.ValueType)
+		if err != nil {
+			return nil, err
+		}
+		return cadence.NewDictionaryType(key, value), nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", typ)
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+)
+
+func TestCheckResultType(t *testing.T) {
+	balance, _ := cadence.NewUFix64("12.5")
+	balances := cadence.NewDictionary([]cadence.KeyValuePair{
+		{Key: cadence.NewAddress(flow.HexToAddress("1")), Value: balance},
+	})
+	accounts := cadence.NewArray([]cadence.Value{newAccountValue(0), newAccountValue(1)})
+
+	for _, c := range []struct {
+		typ   string
+		value cadence.Value
+		err   string
+	}{
+		{typ: "{Address: UFix64}", value: balances},
+		{typ: "{Address: FixedPoint}", value: balances},
+		{typ: "{Address: AnyStruct}", value: balances},
+		{typ: "{Address: UInt64}", value: balances, err: "at [0x0000000000000001]: expected UInt64, got UFix64"},
+		{typ: "[Account]", value: accounts},
+		{typ: "[Account; 2]", value: accounts},
+		{typ: "[Account; 3]", value: accounts, err: "expected [Account;3]"},
+		{typ: "[Other]", value: accounts, err: "at [0]: expected Other"},
+		{typ: "Int?", value: cadence.NewOptional(nil)},
+		{typ: "Int?", value: cadence.NewOptional(cadence.NewInt(1))},
+		{typ: "Integer", value: cadence.NewUInt8(1)},
+		{typ: "SignedInteger", value: cadence.NewUInt8(1), err: "expected SignedInteger, got UInt8"},
+		{typ: "String", value: cadence.NewOptional(cadence.String("a")), err: "expected String"},
+	} {
+		t.Run(c.typ, func(t *testing.T) {
+			typ, err := ParseResultType(c.typ)
+			require.NoError(t, err)
+			err = CheckResultType(c.value, typ)
+			if c.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, c.err)
+			require.Equal(t, ErrUnexpectedResultType, ClassifyError(err))
+		})
+	}
+
+	t.Run("fields", func(t *testing.T) {
+		expected := &cadence.StructType{
+			QualifiedIdentifier: "Account",
+			Fields: []cadence.Field{
+				{Identifier: "address", Type: cadence.AddressType{}},
+				{Identifier: "used", Type: cadence.UInt64Type{}},
+			},
+		}
+		require.NoError(t, CheckResultType(newAccountValue(1), expected))
+
+		expected.Fields[1].Type = cadence.StringType{}
+		require.ErrorContains(t, CheckResultType(newAccountValue(1), expected), "at .used: expected String, got UInt64")
+
+		expected.Fields[1].Identifier = "missing"
+		require.ErrorContains(t, CheckResultType(newAccountValue(1), expected), "at .missing")
+	})
+
+	_, err := ParseResultType("&Account")
+	require.ErrorContains(t, err, "unsupported type")
+	_, err = ParseResultType("{Address: ")
+	require.Error(t, err)
+}
+
+func TestScriptRunner_ExpectedResultType(t *testing.T) {
+	c := clienttest.New()
+	c.AddBlock(10)
+	c.HandleScripts(func(uint64, []byte, []cadence.Value) (cadence.Value, error) {
+		return cadence.NewArray([]cadence.Value{cadence.String("not a balance")}), nil
+	})
+
+	config := DefaultConfig().WithExpectedResultTypeString("", "{Address: UFix64}")
+	require.NoError(t, config.Validate())
+	require.Error(t, DefaultConfig().WithExpectedResultTypeString("", "{Address").Validate())
+	require.Error(t, DefaultConfig().WithExpectedResultTypeString("unknown", "Int").Validate())
+
+	batches := make(chan AddressBatch, 1)
+	results := make(chan ProcessedAddressBatch, 1)
+	runner := NewScriptRunner(c, batches, results, config.ScriptRunnerConfig, zerolog.Nop())
+	<-runner.Start(context.Background())
+
+	batches <- NewAddressBatch([]flow.Address{flow.HexToAddress("01")}, 10, func() {}, nil)
+	select {
+	case <-runner.Done():
+		require.ErrorIs(t, runner.Err(), ErrUnexpectedResultType)
+	case <-results:
+		require.Fail(t, "the result was handled")
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the scan did not fail")
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+// ScanJob is an independent scan that shares the pipeline of a Scanner with the other jobs:
+// the client, the address enumeration of the full scans and the candidates of the incremental scanner.
+// Every batch is run against the script of each job, so the addresses are only traversed once,
+// however many jobs there are. See Config.WithJobs.
+type ScanJob struct {
+	// Name is the name of the job, which is the ScriptName of its batches.
+	Name   string
+	Script []byte
+	// BatchSize if set, is the maximum number of addresses per script execution of the job,
+	// e.g. for a script that exceeds the computation limit with the batch size of the scan.
+	// It can not be larger than the BatchSize of the scan.
+	BatchSize int
+	// Handler handles the results of the job's script, and only those.
+	Handler ScriptResultHandler
+}
+
+// jobResultHandler passes the batches of a job's script to the job's handler, and ignores the other batches.
+type jobResultHandler struct {
+	name    string
+	handler ScriptResultHandler
+}
+
+var _ ScriptResultHandler = (*jobResultHandler)(nil)
+
+func (h *jobResultHandler) Unwrap() ScriptResultHandler {
+	return h.handler
+}
+
+func (h *jobResultHandler) Handle(batch ProcessedAddressBatch) error {
+	if batch.ScriptName != h.name {
+		return nil
+	}
+	return h.handler.Handle(batch)
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigWithJobs(t *testing.T) {
+	balances := &recordingResultHandler{}
+	keys := &recordingResultHandler{}
+	config := DefaultConfig().
+		WithBatchSize(4).
+		WithJobs(
+			ScanJob{Name: "balances", Script: []byte("balances"), Handler: balances},
+			ScanJob{Name: "keys", Script: []byte("keys"), BatchSize: 3, Handler: keys},
+		)
+	require.NoError(t, config.Validate())
+	require.Len(t, config.Scripts, 2)
+	require.Equal(t, map[string]int{"keys": 3}, config.ScriptBatchSizes)
+	require.Len(t, config.ScriptResultHandlers, 2)
+
+	// the handler of a job only handles the batches of its script
+	for _, handler := range config.ScriptResultHandlers {
+		require.NoError(t, handler.Handle(ProcessedAddressBatch{AddressBatch: AddressBatch{ScriptName: "keys"}}))
+	}
+	require.Empty(t, balances.batches)
+	require.Len(t, keys.batches, 1)
+
+	job := ScanJob{Name: "keys", Script: []byte("keys"), Handler: keys}
+	require.ErrorContains(t, config.WithJobs(job).Validate(), `duplicate script name "keys"`)
+	require.Error(t, DefaultConfig().WithJobs(ScanJob{Name: "keys", Script: []byte("keys")}).Validate())
+	require.ErrorContains(t, config.WithBatchSize(2).Validate(), "at most BatchSize")
+}
+
+func TestScriptRunner_BatchPerScriptBatchSizes(t *testing.T) {
+	r := &ScriptRunner{ScriptRunnerConfig: ScriptRunnerConfig{
+		Scripts:          map[string][]byte{"balances": []byte("balances"), "keys": []byte("keys")},
+		ScriptBatchSizes: map[string]int{"keys": 2},
+	}}
+
+	done := false
+	addresses := []flow.Address{flow.HexToAddress("01"), flow.HexToAddress("02"), flow.HexToAddress("03")}
+	input := NewAddressBatch(addresses, 7, func() { done = true }, nil)
+	input.fromFullScan = true
+
+	batches := r.batchPerScript(input)
+	require.Len(t, batches, 3)
+	require.Equal(t, "balances", batches[0].ScriptName)
+	require.Len(t, batches[0].Addresses, 3)
+	require.Equal(t, "keys", batches[1].ScriptName)
+	require.Equal(t, addresses[:2], batches[1].Addresses)
+	require.Equal(t, addresses[2:], batches[2].Addresses)
+	require.True(t, batches[2].fromFullScan)
+
+	// the input batch is done once all the script batches are done
+	for _, batch := range batches {
+		require.False(t, done)
+		batch.DoneHandling()
+	}
+	require.True(t, done)
+}
@@ -17,95 +17,613 @@ package scanner
 import (
 	"context"
 	"errors"
+	"os"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog"
 
 	"github.com/onflow/flow-batch-scan/client"
 )
 
+// QueueDepthReportInterval is how often the depth of the internal queues and the script worker utilization are reported.
+const QueueDepthReportInterval = 5 * time.Second
+
+// periodicFullScanCheckInterval is how often it is checked if a periodic full scan is due.
+const periodicFullScanCheckInterval = time.Second
+
 type Scanner struct {
-	Config
+	// config is a snapshot of the config the scanner was created with, it does not change.
+	// The settings that change while the scan is running are in tuning, see Config.
+	config Config
+	logger zerolog.Logger
 	client client.Client
+
+	pause *pauseGate
+	// tuning are the settings that can be changed while the scan is running.
+	tuning *tuning
+
+	mu sync.Mutex
+	// results is the channel returned by Results, it is taken by the next Scan and closed when it returns.
+	results chan ProcessedAddressBatch
+	// running is the running scan, nil while no scan is running.
+	running *runningScan
+	// lastState is the state of the last scan, see ExportState.
+	lastState *scanState
+	// restored is the state the next scan continues from, see RestoreState.
+	restored *scanState
+}
+
+// runningScan reports the progress of a running scan.
+type runningScan struct {
+	status func() ScanStatus
+	stats  func() ScanStats
+	state  func() scanState
 }
 
+// NewScanner creates a scanner with the options applied to the DefaultConfig, e.g.
+//
+//	scanner.NewScanner(client, scanner.WithScript(script), scanner.WithBatchSize(500))
+//
+// A Config is an Option too, so a config built with the Config builders can be passed instead,
+// optionally followed by options that change it.
+// The scanner keeps a snapshot of the config, so changing the config (or its slices and maps) afterwards
+// does not affect the scanner.
+// It returns an *InvalidConfigError if the config is not valid, see Config.Validate.
 func NewScanner(
 	client client.Client,
-	config Config,
-) *Scanner {
+	opts ...Option,
+) (*Scanner, error) {
+	config := DefaultConfig()
+	for _, opt := range opts {
+		config = opt.apply(config)
+	}
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	config = config.clone()
 	scanner := &Scanner{
-		Config: config,
+		config: config,
 		client: client,
+		pause:  newPauseGate(),
+		tuning: newTuning(config),
+	}
+	scanner.logger = scanner.tuning.logLevel.hook(config.Logger)
+
+	return scanner, nil
+}
+
+// Config returns a copy of the effective config of the scanner: the config it was created with,
+// with the chain ID detected from the access node, and the settings changed while the scan is running.
+func (scanner *Scanner) Config() Config {
+	config := scanner.config.clone()
+	config.Logger = scanner.logger
+	scanner.tuning.apply(&config)
+	return config
+}
+
+// Pause stops running scripts and scanning new blocks, without losing the progress of the scan.
+// Scripts that are already running are allowed to finish.
+// Pausing can be used to back off while the access node is having problems.
+func (scanner *Scanner) Pause() {
+	if scanner.pause.pause() {
+		scanner.logger.Info().Msg("Scan paused")
+	}
+}
+
+// Resume continues a paused scan. The blocks sealed while the scan was paused are backfilled.
+func (scanner *Scanner) Resume() {
+	if scanner.pause.resume() {
+		scanner.logger.Info().Msg("Scan resumed")
 	}
+}
+
+// Results returns a channel that receives every processed batch, after the ScriptResultHandler handled it.
+// This can be used instead of (or in addition to) a ScriptResultHandler.
+// It has to be called before Scan, and the channel has to be drained, as the scan waits for the results to be received.
+// The channel is closed when Scan returns, so Results has to be called again before the next Scan.
+func (scanner *Scanner) Results() <-chan ProcessedAddressBatch {
+	scanner.mu.Lock()
+	defer scanner.mu.Unlock()
+	if scanner.results == nil {
+		scanner.results = make(chan ProcessedAddressBatch)
+	}
+	return scanner.results
+}
+
+// takeResults takes the channel returned by Results for a scan, so the next Results call creates a new one.
+func (scanner *Scanner) takeResults() chan ProcessedAddressBatch {
+	scanner.mu.Lock()
+	defer scanner.mu.Unlock()
+	results := scanner.results
+	scanner.results = nil
+	return results
+}
+
+// resultHandler is the handler of the script results,
+// a FanOutResultHandler if there are additional ScriptResultHandlers.
+func (scanner *Scanner) resultHandler() ScriptResultHandler {
+	if len(scanner.config.ScriptResultHandlers) == 0 {
+		return scanner.config.ScriptResultHandler
+	}
+	handlers := append([]ScriptResultHandler{scanner.config.ScriptResultHandler}, scanner.config.ScriptResultHandlers...)
+	return NewFanOutResultHandler(handlers, true, scanner.logger)
+}
+
+// scriptClient is the client used to execute the scripts.
+func (scanner *Scanner) scriptClient() client.Client {
+	if scanner.config.ScriptClient != nil {
+		return scanner.config.ScriptClient
+	}
+	return scanner.client
+}
+
+// Status returns the status of the running scan, or false if no scan is running.
+// Healthy is only tracked by the status server, see WithStatusServer.
+func (scanner *Scanner) Status() (ScanStatus, bool) {
+	running := scanner.runningScan()
+	if running == nil {
+		return ScanStatus{}, false
+	}
+	status := running.status()
+	status.Ready = !status.Paused
+	return status, true
+}
+
+// Stats returns the stats of the running scan so far, or false if no scan is running.
+// The stats of a finished scan are in its ScanConcluded.
+func (scanner *Scanner) Stats() (ScanStats, bool) {
+	running := scanner.runningScan()
+	if running == nil {
+		return ScanStats{}, false
+	}
+	return running.stats(), true
+}
 
-	return scanner
+func (scanner *Scanner) runningScan() *runningScan {
+	scanner.mu.Lock()
+	defer scanner.mu.Unlock()
+	return scanner.running
+}
+
+func (scanner *Scanner) setRunningScan(running *runningScan) {
+	scanner.mu.Lock()
+	defer scanner.mu.Unlock()
+	scanner.running = running
+}
+
+// IsPaused is true if the scan is paused.
+func (scanner *Scanner) IsPaused() bool {
+	return scanner.pause.isPaused()
 }
 
 type ScanConcluded struct {
 	LatestScannedBlockHeight uint64
-	// ScanIsComplete is false if a full scan was not completed,
+	// ScanIsComplete is false if a full scan was not completed (or stopped at MaxAddresses or MaxBatches),
 	// this means some accounts may have stale data, or have been missed all together.
 	ScanIsComplete bool
+	// Stats describe what happened during the scan.
+	Stats ScanStats
+	// Aggregates are the final values of the AggregateHandlers, by name. See WithAggregates.
+	Aggregates map[string]any
+	// Audit is the CompletenessAudit of the last full scan, if WithCompletenessAudit is set and a full scan ran.
+	Audit *CompletenessAudit
 }
 
+// Scan runs the scan. With a Schedule it runs a scan at every time of the schedule until the context is cancelled,
+// and returns the result of the last run.
 func (scanner *Scanner) Scan(ctx context.Context) (ScanConcluded, error) {
-	// small buffer so that the pending requests in the buffer don't encounter "state commitment not found" errors.
-	scriptRequestChan := make(chan AddressBatch, 10)
+	results := scanner.takeResults()
+	if results != nil {
+		defer close(results)
+	}
+	scanOnce := func(ctx context.Context) (ScanConcluded, error) {
+		return scanner.scanOnce(ctx, results)
+	}
+	if scanner.config.Schedule != "" {
+		schedule, err := cron.ParseStandard(scanner.config.Schedule)
+		if err != nil {
+			return ScanConcluded{}, err
+		}
+		return scanner.scanOnSchedule(ctx, schedule, scanOnce)
+	}
+	return scanOnce(ctx)
+}
+
+// scanOnce runs one scan. results if not nil, receives the processed batches, see Results.
+func (scanner *Scanner) scanOnce(ctx context.Context, results chan<- ProcessedAddressBatch) (ScanConcluded, error) {
+	concluded, err := scanner.scan(ctx, results)
+	scanner.config.Hooks.scanConcluded(concluded, err)
+	return concluded, err
+}
+
+func (scanner *Scanner) scan(ctx context.Context, results chan<- ProcessedAddressBatch) (ScanConcluded, error) {
+	scanStart := time.Now()
+	chainID, err := scanner.chainID(ctx)
+	if err != nil {
+		return ScanConcluded{}, err
+	}
+
+	restored, err := scanner.takeRestoredState(chainID)
+	if err != nil {
+		return ScanConcluded{}, err
+	}
+
+	stats := newStatsCollector()
+	for _, aggregate := range scanner.config.Aggregates {
+		aggregate.reset()
+	}
+	tracer := newTracer(scanner.config.TracerProvider)
+	batchSize := scanner.tuning.getBatchSize()
+	batchSizeController := scanner.tuning.newBatchSizeController(scanner.config.AdaptiveBatchSize, scanner.config.Reporter)
+	throttle := newFullScanThrottle(scanner.config.FullScanThrottle, scanner.config.Reporter)
+	audit := newCompletenessAuditor(scanner.config.CompletenessAudit, scanner.config.ScriptRunnerConfig)
+
+	scriptRequestChan := make(chan AddressBatch, scanner.config.AddressBatchQueueSize)
 
-	scriptResultChan := make(chan ProcessedAddressBatch, 10000)
+	scriptResultChan := make(chan ProcessedAddressBatch, scanner.config.ScriptResultQueueSize)
 
 	// this channel will be used to request a full scan
-	requestBatchChan := make(chan uint64)
+	requestBatchChan := make(chan uint64, scanner.config.FullScanRequestQueueSize)
 
 	var components []Component
-	if c, ok := scanner.Reporter.(Component); ok {
+	if c, ok := scanner.config.Reporter.(Component); ok {
 		components = append(components, c)
 	}
-	if c, ok := scanner.ScriptResultHandler.(Component); ok {
+	if c, ok := resultHandlerComponent(scanner.config.ScriptResultHandler); ok {
 		components = append(components, c)
 	}
+	for _, handler := range scanner.config.ScriptResultHandlers {
+		if c, ok := resultHandlerComponent(handler); ok {
+			components = append(components, c)
+		}
+	}
+
+	incrementalScannerConfig := scanner.config.IncrementalScannerConfig
+	if scanner.config.ProgressStore != nil && scanner.config.ResumeFromProgress {
+		height, ok, err := scanner.config.ProgressStore.LoadProgress(ctx)
+		if err != nil {
+			return ScanConcluded{}, err
+		}
+		if ok {
+			scanner.logger.Info().
+				Uint64("height", height).
+				Msg("Resuming from saved progress")
+			incrementalScannerConfig.IncrementalStartHeight = height
+		}
+	}
+
+	// a point in time scan only runs a full scan at the reference block height, without the incremental scanner
+	pointInTime := scanner.config.ReferenceBlockHeight > 0
+
+	var resumedFullScan *FullScanCheckpoint
+	var resumedFullScanHeight uint64
+	if scanner.config.CheckpointStore != nil {
+		checkpoint, ok, err := scanner.config.CheckpointStore.LoadCheckpoint(ctx)
+		if err != nil {
+			return ScanConcluded{}, err
+		}
+		if ok {
+			header, err := scanner.client.GetLatestBlockHeader(ctx, true)
+			if err != nil {
+				return ScanConcluded{}, err
+			}
+			scanner.logger.Info().
+				Uint("address_index", checkpoint.AddressIndex).
+				Uint64("incremental_height", checkpoint.IncrementalHeight).
+				Msg("Resuming full scan from checkpoint")
+
+			// backfill the blocks after the checkpoint, to catch changes to the already scanned addresses
+			startHeight := incrementalScannerConfig.IncrementalStartHeight
+			if startHeight == 0 || checkpoint.IncrementalHeight < startHeight {
+				incrementalScannerConfig.IncrementalStartHeight = checkpoint.IncrementalHeight
+			}
+			resumedFullScan = &checkpoint
+			resumedFullScanHeight = header.Height
+			if pointInTime {
+				resumedFullScanHeight = scanner.config.ReferenceBlockHeight
+			}
+		}
+	}
+	if restored != nil {
+		scanner.logger.Info().
+			Uint64("incremental_height", restored.IncrementalHeight).
+			Bool("full_scan", restored.FullScan != nil).
+			Int("pending_batches", len(restored.PendingBatches)).
+			Int("failed_batches", len(restored.FailedBatches)).
+			Time("exported_at", restored.ExportedAt).
+			Msg("Continuing from the restored scan state")
+		if restored.IncrementalHeight > 0 {
+			incrementalScannerConfig.IncrementalStartHeight = restored.IncrementalHeight
+		}
+		resumedFullScan = nil
+		if restored.FullScan != nil {
+			header, err := scanner.client.GetLatestBlockHeader(ctx, true)
+			if err != nil {
+				return ScanConcluded{}, err
+			}
+			// backfill the blocks after the checkpoint, to catch changes to the already scanned addresses
+			startHeight := incrementalScannerConfig.IncrementalStartHeight
+			if startHeight == 0 || restored.FullScan.IncrementalHeight < startHeight {
+				incrementalScannerConfig.IncrementalStartHeight = restored.FullScan.IncrementalHeight
+			}
+			resumedFullScan = restored.FullScan
+			resumedFullScanHeight = header.Height
+			if pointInTime {
+				resumedFullScanHeight = scanner.config.ReferenceBlockHeight
+			}
+		}
+		for _, record := range restored.FailedBatches {
+			batch := record.failedBatch()
+			stats.update(func(stats *ScanStats) {
+				stats.FailedBatches = append(stats.FailedBatches, batch)
+			})
+		}
+	}
 
 	incrementalScanner := NewIncrementalScanner(
 		scanner.client,
 		scriptRequestChan,
 		requestBatchChan,
-		scanner.BatchSize,
-		scanner.IncrementalScannerConfig,
-		scanner.Reporter,
-		scanner.Logger,
+		batchSize,
+		incrementalScannerConfig,
+		scanner.config.Reporter,
+		scanner.logger,
 	)
-	components = append(components, incrementalScanner)
-
-	components = append(components,
-		NewScriptRunner(
-			scanner.client,
-			scriptRequestChan,
-			scriptResultChan,
-			scanner.ScriptRunnerConfig,
-			scanner.Logger,
-		),
+	addressFilter := scanner.config.AddressFilter.compile()
+	incrementalScanner.addressFilter = addressFilter
+	incrementalScanner.pause = scanner.pause
+	incrementalScanner.hooks = scanner.config.Hooks
+	incrementalScanner.stats = stats
+	incrementalScanner.tracer = tracer
+	incrementalScanner.batchSizeController = batchSizeController
+	incrementalScanner.scanInterval = scanner.tuning.getIncrementalScanInterval
+	if !pointInTime {
+		components = append(components, incrementalScanner)
+	}
+
+	scriptRunnerConfig, err := scanner.config.ScriptRunnerConfig.resolveImports(chainID)
+	if err != nil {
+		return ScanConcluded{}, err
+	}
+	// the full scan batches wait in their own queue, and are prioritized against the other batches,
+	// unless the script runner reads the batches from the durable queue
+	scriptRunnerChan := scriptRequestChan
+	fullScanBatchChan := scriptRequestChan
+	if scanner.config.BatchPriority != PrioritizeNone && scanner.config.DurableQueuePath == "" {
+		fullScanBatchChan = make(chan AddressBatch, scanner.config.AddressBatchQueueSize)
+		high, low := scriptRequestChan, fullScanBatchChan
+		if scanner.config.BatchPriority == PrioritizeFullScan {
+			high, low = low, high
+		}
+		prioritized := make(chan AddressBatch)
+		components = append(components, newBatchPrioritizer(high, low, prioritized, scanner.logger))
+		scriptRunnerChan = prioritized
+	}
+	var durable *durableQueue
+	if scanner.config.DurableQueuePath != "" {
+		durableOut := make(chan AddressBatch, scanner.config.AddressBatchQueueSize)
+		durable, err = openDurableQueue(scanner.config.DurableQueuePath, scriptRequestChan, durableOut, scanner.logger)
+		if err != nil {
+			return ScanConcluded{}, err
+		}
+		defer func() {
+			if err := durable.Close(); err != nil {
+				scanner.logger.Warn().Err(err).Msg("failed to close the durable queue")
+			}
+		}()
+		scriptRunnerChan = durableOut
+		components = append(components, durable)
+	}
+	scriptRunner := NewScriptRunner(
+		scanner.scriptClient(),
+		scriptRunnerChan,
+		scriptResultChan,
+		scriptRunnerConfig,
+		scanner.logger,
 	)
-	components = append(components,
-		NewScriptResultProcessor(
-			scriptResultChan,
-			scanner.ScriptResultHandler,
-			scanner.Logger,
-		),
+	scriptRunner.pause = scanner.pause
+	scriptRunner.stats = stats
+	scriptRunner.reporter = scanner.config.Reporter
+	scriptRunner.tracer = tracer
+	scriptRunner.batchSizeController = batchSizeController
+	scriptRunner.throttle = throttle
+	components = append(components, scriptRunner)
+	scriptResultProcessor := NewScriptResultProcessor(
+		scriptResultChan,
+		scriptRequestChan,
+		scanner.resultHandler(),
+		scanner.config.ScriptResultProcessorConfig,
+		scanner.logger,
 	)
+	scriptResultProcessor.hooks = scanner.config.Hooks
+	scriptResultProcessor.stats = stats
+	scriptResultProcessor.reporter = scanner.config.Reporter
+	scriptResultProcessor.tracer = tracer
+	scriptResultProcessor.audit = audit
+	if results != nil {
+		scriptResultProcessor.results = results
+		defer scriptResultProcessor.inFlight.Wait()
+	}
+	components = append(components, scriptResultProcessor)
 
+	fullScanRunnerConfig := scanner.config.FullScanRunnerConfig
+	fullScanRunnerConfig.ChainID = chainID
 	fullScanRunner := NewFullScanRunner(
 		scanner.client,
-		scriptRequestChan,
-		scanner.BatchSize,
-		scanner.FullScanRunnerConfig,
-		scanner.Reporter,
-		scanner.Logger,
+		fullScanBatchChan,
+		batchSize,
+		fullScanRunnerConfig,
+		scanner.config.Reporter,
+		scanner.logger,
 	)
+	fullScanRunner.incrementalHeight = incrementalScanner.LatestHandledBlock
+	fullScanRunner.scriptClient = scanner.config.ScriptClient
+	if pointInTime {
+		fullScanRunner.incrementalHeight = func() uint64 { return scanner.config.ReferenceBlockHeight }
+	}
+	fullScanRunner.addressFilter = addressFilter
+	fullScanRunner.hooks = scanner.config.Hooks
+	fullScanRunner.stats = stats
+	fullScanRunner.tracer = tracer
+	fullScanRunner.batchSizeController = batchSizeController
+	fullScanRunner.throttle = throttle
+	fullScanRunner.audit = audit
+
+	queues := newQueueMonitor()
+	queues.add(FullScanRequestQueueName, cap(requestBatchChan), func() int { return len(requestBatchChan) })
+	queues.add(AddressBatchQueueName, cap(scriptRequestChan), func() int { return len(scriptRequestChan) })
+	queues.add(AddressBatchOverflowQueueName, UnboundedQueueCapacity, incrementalScanner.OverflowLen)
+	if fullScanBatchChan != scriptRequestChan {
+		queues.add(FullScanBatchQueueName, cap(fullScanBatchChan), func() int { return len(fullScanBatchChan) })
+	}
+	if durable != nil {
+		queues.add(DurableAddressBatchQueueName, UnboundedQueueCapacity, durable.len)
+	}
+	queues.add(ScriptResultQueueName, cap(scriptResultChan), func() int { return len(scriptResultChan) })
+	queues.add(ScriptResultSpillQueueName, UnboundedQueueCapacity, scriptResultProcessor.SpilledLen)
+
+	scanStatus := func() ScanStatus {
+		snapshot := stats.snapshot(time.Since(scanStart))
+		status := ScanStatus{
+			Paused:                 scanner.IsPaused(),
+			IncrementalScanRunning: !pointInTime,
+			IncrementalBlockHeight: incrementalScanner.LatestHandledBlock(),
+			AddressesScanned:       snapshot.AddressesScanned,
+			BatchesExecuted:        snapshot.BatchesExecuted,
+			Retries:                snapshot.Retries,
+			ScriptErrors:           snapshot.ScriptErrors,
+			FailedBatches:          len(snapshot.FailedBatches),
+			HandlerErrors:          snapshot.HandlerErrors,
+			Uptime:                 snapshot.Duration,
+			Queues:                 queues.stats(),
+		}
+		if fullScan := fullScanRunner.running(); fullScan != nil {
+			progress := fullScan.Progress()
+			status.FullScan = &progress
+		}
+		return status
+	}
+	currentState := func() scanState {
+		state := scanState{
+			Version:           scanStateVersion,
+			ChainID:           string(chainID),
+			IncrementalHeight: incrementalScanner.LatestHandledBlock(),
+			PendingBatches:    []pendingBatchRecord{},
+			FailedBatches:     []failedBatchRecord{},
+		}
+		if pointInTime {
+			state.IncrementalHeight = 0
+		}
+		if checkpoint, ok := fullScanRunner.checkpoint(); ok {
+			state.FullScan = &checkpoint
+		}
+		if durable != nil {
+			pending, err := durable.pending()
+			if err != nil {
+				scanner.logger.Warn().Err(err).Msg("failed to read the pending batches")
+			}
+			state.PendingBatches = append(state.PendingBatches, pending...)
+		}
+		for _, batch := range stats.snapshot(time.Since(scanStart)).FailedBatches {
+			state.FailedBatches = append(state.FailedBatches, newFailedBatchRecord(batch))
+		}
+		return state
+	}
+	scanner.setRunningScan(&runningScan{
+		status: scanStatus,
+		stats: func() ScanStats {
+			return stats.snapshot(time.Since(scanStart))
+		},
+		state: currentState,
+	})
+	defer func() {
+		state := currentState()
+		scanner.mu.Lock()
+		scanner.running = nil
+		scanner.lastState = &state
+		scanner.mu.Unlock()
+	}()
+	if scanner.config.StatusServerAddr != "" {
+		components = append(components, newStatusServer(
+			scanner.config.StatusServerAddr,
+			scanner.config.StatusServerStallTimeout,
+			scanStatus,
+			scanner.logger,
+		))
+	}
+	if scanner.config.ConsoleProgress {
+		components = append(components, newConsoleProgress(
+			os.Stderr,
+			consoleProgressInterval,
+			scanStatus,
+			scanner.logger,
+		))
+	}
+	if scanner.config.WatchdogStallTimeout > 0 {
+		stages := []watchedStage{{
+			name: FullScanStage,
+			lastHeartbeat: func() (time.Time, bool) {
+				fullScan := fullScanRunner.running()
+				if fullScan == nil || scanner.IsPaused() {
+					return time.Time{}, false
+				}
+				return fullScan.LastHeartbeat(), true
+			},
+		}}
+		if !pointInTime {
+			stages = append(stages, watchedStage{
+				name: IncrementalScannerStage,
+				lastHeartbeat: func() (time.Time, bool) {
+					return incrementalScanner.LastHeartbeat(), !scanner.IsPaused()
+				},
+			})
+		}
+		components = append(components, newWatchdog(
+			scanner.config.WatchdogStallTimeout,
+			scanner.config.WatchdogFailOnStall,
+			stages,
+			scanner.config.Hooks,
+			scanner.logger,
+		))
+	}
+	if scanner.config.ProfilingAddr != "" {
+		publishScanVars(func() ScanVars {
+			return newScanVars(stats.snapshot(time.Since(scanStart)), incrementalScanner.LatestHandledBlock())
+		})
+		components = append(components, newProfiler(
+			scanner.config.ProfilingAddr,
+			scanner.config.ProfilingLogInterval,
+			scanner.logger,
+		))
+	}
 
 	ctx, cancel := context.WithCancel(ctx)
 	for _, component := range components {
 		<-component.Start(ctx)
 	}
+	// the scan is not over before the pending batches of the restored state are done
+	var pendingDone <-chan struct{}
+	if restored != nil && len(restored.PendingBatches) > 0 {
+		pendingDone = sendPendingBatches(ctx, restored.PendingBatches, scriptRequestChan)
+	}
+
+	go queues.run(ctx, scanner.config.Reporter)
+	go func() {
+		ticker := time.NewTicker(QueueDepthReportInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				scanner.config.Reporter.ReportScriptWorkers(scriptRunner.BusyWorkers(), scriptRunner.WorkersLimit())
+			}
+		}
+	}()
 
 	type fullScan struct {
 		*FullScan
@@ -114,22 +632,62 @@ func (scanner *Scanner) Scan(ctx context.Context) (ScanConcluded, error) {
 
 	continueScan := true
 	var runningFullScan *fullScan
+	// fullScanStoppedAtLimit is true if the last finished full scan did not scan all the addresses
+	fullScanStoppedAtLimit := false
+	// when and at what height the last full scan was started, used to schedule periodic full scans
+	lastFullScan := &periodicFullScanMark{}
+	if resumedFullScan != nil {
+		fullScanCtx, cancel := context.WithCancel(ctx)
+		runningFullScan = &fullScan{
+			FullScan: fullScanRunner.NewBatchFrom(resumedFullScanHeight, resumedFullScan.AddressIndex),
+			cancel:   cancel,
+		}
+		lastFullScan.mark(resumedFullScanHeight)
+		<-runningFullScan.Start(fullScanCtx)
+	} else if pointInTime {
+		scanner.logger.Info().
+			Uint64("height", scanner.config.ReferenceBlockHeight).
+			Msg("Starting point in time scan")
+		fullScanCtx, cancel := context.WithCancel(ctx)
+		runningFullScan = &fullScan{
+			FullScan: fullScanRunner.NewBatch(scanner.config.ReferenceBlockHeight),
+			cancel:   cancel,
+		}
+		<-runningFullScan.Start(fullScanCtx)
+	}
 	go func() {
+		var periodicFullScanChan <-chan time.Time
+		if scanner.config.ContinuousScan && (scanner.config.FullScanInterval > 0 || scanner.config.FullScanBlockInterval > 0) {
+			ticker := time.NewTicker(periodicFullScanCheckInterval)
+			defer ticker.Stop()
+			periodicFullScanChan = ticker.C
+		}
+
 		for continueScan {
 			switch runningFullScan {
 			case nil:
 
-				scanner.Reporter.ReportIsFullScanRunning(false)
-				height := <-requestBatchChan
+				scanner.config.Reporter.ReportIsFullScanRunning(false)
+				var height uint64
+				select {
+				case height = <-requestBatchChan:
+				case <-periodicFullScanChan:
+					var due bool
+					height, due = scanner.periodicFullScanDue(incrementalScanner, lastFullScan)
+					if !due {
+						continue
+					}
+				}
 				fullScanCtx, cancel := context.WithCancel(ctx)
 				runningFullScan = &fullScan{
 					FullScan: fullScanRunner.NewBatch(height),
 					cancel:   cancel,
 				}
+				lastFullScan.mark(height)
 				<-runningFullScan.Start(fullScanCtx)
 
 			default:
-				scanner.Reporter.ReportIsFullScanRunning(true)
+				scanner.config.Reporter.ReportIsFullScanRunning(true)
 				select {
 				case height := <-requestBatchChan:
 					runningFullScan.cancel()
@@ -139,21 +697,40 @@ func (scanner *Scanner) Scan(ctx context.Context) (ScanConcluded, error) {
 						FullScan: fullScanRunner.NewBatch(height),
 						cancel:   cancel,
 					}
+					lastFullScan.mark(height)
 					<-runningFullScan.Start(fullScanCtx)
 
 				case <-runningFullScan.Done():
 					if runningFullScan.Err() != nil {
 						// TODO: handle error
-						scanner.Logger.Fatal().Err(runningFullScan.Err()).Msg("Failed batch")
+						scanner.config.Hooks.scanConcluded(ScanConcluded{
+							LatestScannedBlockHeight: incrementalScanner.LatestHandledBlock(),
+							Stats:                    stats.snapshot(time.Since(scanStart)),
+						}, runningFullScan.Err())
+						scanner.logger.Fatal().Err(runningFullScan.Err()).Msg("Failed batch")
 					}
 					runningFullScan.cancel()
+					fullScanStoppedAtLimit = runningFullScan.StoppedAtLimit()
 					runningFullScan = nil
-					if !scanner.ContinuousScan {
+					if !scanner.config.ContinuousScan || pointInTime {
 						continueScan = false
 					}
 				}
 			}
 		}
+		if !pointInTime {
+			// scan the candidates that are still held back before shutting down
+			err := incrementalScanner.Flush(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				scanner.logger.Warn().Err(err).Msg("Could not flush held back candidates")
+			}
+		}
+		if pendingDone != nil {
+			select {
+			case <-ctx.Done():
+			case <-pendingDone:
+			}
+		}
 		cancel()
 	}()
 
@@ -168,10 +745,79 @@ func (scanner *Scanner) Scan(ctx context.Context) (ScanConcluded, error) {
 		}
 	}
 
-	return ScanConcluded{
-		LatestScannedBlockHeight: incrementalScanner.LatestHandledBlock(),
-		ScanIsComplete:           runningFullScan == nil,
-	}, merr.ErrorOrNil()
+	latestScannedBlockHeight := incrementalScanner.LatestHandledBlock()
+	if pointInTime {
+		latestScannedBlockHeight = scanner.config.ReferenceBlockHeight
+	}
+	concluded := ScanConcluded{
+		LatestScannedBlockHeight: latestScannedBlockHeight,
+		ScanIsComplete:           runningFullScan == nil && !fullScanStoppedAtLimit,
+		Stats:                    stats.snapshot(time.Since(scanStart)),
+		Aggregates:               aggregates(scanner.config.Aggregates),
+		Audit:                    audit.audit(),
+	}
+	if concluded.Audit != nil && !concluded.Audit.IsComplete() {
+		scanner.logger.Warn().
+			Uint64("reference_block_height", concluded.Audit.ReferenceBlockHeight).
+			Int("missing", len(concluded.Audit.Missing)).
+			Int("duplicated", len(concluded.Audit.Duplicated)).
+			Msg("The completeness audit of the full scan failed")
+	}
+	if scanner.config.DryRun {
+		scanner.logger.Info().
+			Uint64("addresses", concluded.Stats.DryRunAddresses).
+			Uint64("batches", concluded.Stats.DryRunBatches).
+			Dur("duration", concluded.Stats.Duration).
+			Msg("Dry run concluded")
+	}
+	return concluded, merr.ErrorOrNil()
+}
+
+// periodicFullScanMark is when and at what height the last full scan was started (or vetoed).
+type periodicFullScanMark struct {
+	time   time.Time
+	height uint64
+}
+
+func (m *periodicFullScanMark) mark(height uint64) {
+	m.time = time.Now()
+	m.height = height
+}
+
+// periodicFullScanDue checks if a periodic full scan should be started.
+// The full scan starts at the block last handled by the incremental scanner,
+// so the blocks after it are still covered by the incremental scanner.
+func (scanner *Scanner) periodicFullScanDue(
+	incrementalScanner *IncrementalScanner,
+	last *periodicFullScanMark,
+) (uint64, bool) {
+	height := incrementalScanner.LatestHandledBlock()
+	if height == 0 {
+		return 0, false
+	}
+
+	timeDue := scanner.config.FullScanInterval > 0 &&
+		time.Since(last.time) >= scanner.config.FullScanInterval
+	blocksDue := scanner.config.FullScanBlockInterval > 0 &&
+		height >= last.height+scanner.config.FullScanBlockInterval
+	if !timeDue && !blocksDue {
+		return 0, false
+	}
+
+	allowed := incrementalScanner.allowFullScan(FullScanRequest{
+		Reason: FullScanReasonInterval,
+		Height: height,
+	})
+	if !allowed {
+		// try again after the next interval, instead of at the next check
+		last.mark(height)
+		return 0, false
+	}
+
+	scanner.logger.Info().
+		Uint64("height", height).
+		Msg("Starting periodic full scan")
+	return height, true
 }
 
 func waitForAnyComponentToFinish(components ...Component) struct{} {
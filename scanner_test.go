@@ -0,0 +1,336 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+)
+
+func TestNewScanner_Snapshot(t *testing.T) {
+	scripts := map[string][]byte{"a": []byte("access(all) fun main() {}")}
+	config := DefaultConfig().
+		WithScripts(scripts).
+		WithIncludeAddresses(flow.HexToAddress("01"))
+
+	scanner, err := NewScanner(nil, config)
+	require.NoError(t, err)
+
+	// changing the config afterwards does not change the scanner
+	config.AddressFilter.Include[0] = flow.HexToAddress("02")
+	scripts["b"] = []byte("access(all) fun main() {}")
+	config.BatchSize = 1
+
+	effective := scanner.Config()
+	require.Equal(t, []flow.Address{flow.HexToAddress("01")}, effective.AddressFilter.Include)
+	require.Len(t, effective.Scripts, 1)
+	require.Equal(t, DefaultBatchSize, effective.BatchSize)
+
+	// the effective config has the settings changed while the scan is running
+	require.NoError(t, scanner.SetBatchSize(200))
+	require.NoError(t, scanner.SetIncrementalScanInterval(time.Minute))
+	effective = scanner.Config()
+	require.Equal(t, 200, effective.BatchSize)
+	require.Equal(t, time.Minute, effective.IncrementalScanInterval)
+
+	require.Error(t, scanner.SetBatchSize(0))
+}
+
+func TestNewScanner_Options(t *testing.T) {
+	script := []byte("access(all) fun main() {}")
+	scanner, err := NewScanner(nil,
+		WithScript(script),
+		WithBatchSize(200),
+		WithIncludeAddresses(flow.HexToAddress("01"), flow.HexToAddress("02")),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 200, scanner.Config().BatchSize)
+	require.Len(t, scanner.Config().AddressFilter.Include, 2)
+
+	// the options after a config change it
+	config := DefaultConfig().
+		WithScript(script).
+		WithBatchSize(200)
+	scanner, err = NewScanner(nil, config, WithBatchSize(300))
+	require.NoError(t, err)
+	require.Equal(t, 300, scanner.Config().BatchSize)
+
+	// rejected options make the config invalid
+	_, err = NewScanner(nil, WithScript(script), WithBatchSize(0))
+	var invalid *InvalidConfigError
+	require.ErrorAs(t, err, &invalid)
+}
+
+func TestScan_ReferenceBlockHeight(t *testing.T) {
+	addresses := []flow.Address{flow.HexToAddress("01"), flow.HexToAddress("02"), flow.HexToAddress("03")}
+
+	c := clienttest.New()
+	c.AddBlocks(1, 20)
+	var mu sync.Mutex
+	heights := map[uint64]int{}
+	c.HandleScripts(func(height uint64, _ []byte, _ []cadence.Value) (cadence.Value, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		heights[height]++
+		return cadence.NewBool(true), nil
+	})
+
+	s, err := NewScanner(c,
+		WithScript([]byte("pub fun main(addresses: [Address]): Bool { return true }")),
+		WithReferenceBlockHeight(7),
+		WithAddressProvider(StaticAddresses(addresses)),
+		WithBatchSize(2),
+	)
+	require.NoError(t, err)
+
+	concluded, err := s.Scan(context.Background())
+	require.NoError(t, err)
+	require.True(t, concluded.ScanIsComplete)
+	require.Equal(t, uint64(7), concluded.LatestScannedBlockHeight)
+	// all scripts ran at the reference block height, not at the latest block
+	require.Equal(t, map[uint64]int{7: 2}, heights)
+}
+
+func TestScan_ScriptClient(t *testing.T) {
+	addresses := []flow.Address{flow.HexToAddress("01"), flow.HexToAddress("02")}
+
+	c := clienttest.New()
+	c.AddBlocks(1, 10)
+	scriptClient := clienttest.New()
+	scriptClient.AddBlocks(1, 10)
+	scriptClient.HandleScripts(func(uint64, []byte, []cadence.Value) (cadence.Value, error) {
+		return cadence.NewBool(true), nil
+	})
+
+	s, err := NewScanner(c,
+		WithScript([]byte("pub fun main(addresses: [Address]): Bool { return true }")),
+		WithReferenceBlockHeight(10),
+		WithAddressProvider(StaticAddresses(addresses)),
+		WithScriptClient(scriptClient),
+	)
+	require.NoError(t, err)
+
+	concluded, err := s.Scan(context.Background())
+	require.NoError(t, err)
+	require.True(t, concluded.ScanIsComplete)
+	// the scripts only run on the script client
+	require.Equal(t, 1, scriptClient.Calls(client.MethodExecuteScriptAtBlockHeight))
+	require.Equal(t, 0, c.Calls(client.MethodExecuteScriptAtBlockHeight))
+}
+
+func TestScan_DryRun(t *testing.T) {
+	addresses := []flow.Address{flow.HexToAddress("01"), flow.HexToAddress("02"), flow.HexToAddress("03")}
+
+	c := clienttest.New()
+	c.AddBlocks(1, 10)
+
+	s, err := NewScanner(c,
+		WithScript([]byte("pub fun main(addresses: [Address]): Bool { return true }")),
+		WithReferenceBlockHeight(10),
+		WithAddressProvider(StaticAddresses(addresses)),
+		WithBatchSize(2),
+		WithDryRun(true),
+	)
+	require.NoError(t, err)
+
+	concluded, err := s.Scan(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), concluded.Stats.DryRunAddresses)
+	require.Equal(t, uint64(2), concluded.Stats.DryRunBatches)
+	require.Equal(t, 0, c.Calls(client.MethodExecuteScriptAtBlockHeight))
+}
+
+func TestScan_Hooks(t *testing.T) {
+	addresses := []flow.Address{flow.HexToAddress("01"), flow.HexToAddress("02"), flow.HexToAddress("03")}
+
+	c := clienttest.New()
+	c.AddBlocks(1, 10)
+	c.HandleScripts(func(uint64, []byte, []cadence.Value) (cadence.Value, error) {
+		return cadence.NewBool(true), nil
+	})
+
+	var mu sync.Mutex
+	var started []uint64
+	var completed []FullScanStats
+	var processed []flow.Address
+	config := DefaultConfig().
+		WithScript([]byte("pub fun main(addresses: [Address]): Bool { return true }")).
+		WithReferenceBlockHeight(10).
+		WithAddressProvider(StaticAddresses(addresses)).
+		WithBatchSize(2).
+		WithOnFullScanStarted(func(referenceBlockHeight uint64) {
+			started = append(started, referenceBlockHeight)
+		}).
+		WithOnFullScanCompleted(func(stats FullScanStats) {
+			completed = append(completed, stats)
+		}).
+		WithOnBatchProcessed(func(batch ProcessedAddressBatch, _ time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			processed = append(processed, batch.Addresses...)
+		})
+	s, err := NewScanner(c, config)
+	require.NoError(t, err)
+
+	_, err = s.Scan(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []uint64{10}, started)
+	require.Len(t, completed, 1)
+	require.Equal(t, uint64(10), completed[0].ReferenceBlockHeight)
+	require.Equal(t, uint64(3), completed[0].Addresses)
+	require.Equal(t, uint64(2), completed[0].Batches)
+	require.False(t, completed[0].StoppedAtLimit)
+	require.ElementsMatch(t, addresses, processed)
+}
+
+func TestScanner_PeriodicFullScanDue(t *testing.T) {
+	s, err := NewScanner(nil, DefaultConfig().
+		WithScript([]byte("access(all) fun main() {}")).
+		WithContinuousScan(true).
+		WithFullScanInterval(time.Hour).
+		WithFullScanBlockInterval(100))
+	require.NoError(t, err)
+
+	var requests []FullScanRequest
+	allow := true
+	config := DefaultIncrementalScannerConfig()
+	config.OnFullScanRequest = func(request FullScanRequest) bool {
+		requests = append(requests, request)
+		return allow
+	}
+	incrementalScanner := NewIncrementalScanner(nil, nil, nil, 100, config, NoOpStatusReporter{}, zerolog.Nop())
+	mark := func(ago time.Duration, height uint64) *periodicFullScanMark {
+		return &periodicFullScanMark{time: time.Now().Add(-ago), height: height}
+	}
+
+	// nothing was handled by the incremental scanner yet
+	_, due := s.periodicFullScanDue(incrementalScanner, mark(2*time.Hour, 0))
+	require.False(t, due)
+
+	incrementalScanner.latestHandledBlock.Store(150)
+	_, due = s.periodicFullScanDue(incrementalScanner, mark(0, 100))
+	require.False(t, due)
+
+	height, due := s.periodicFullScanDue(incrementalScanner, mark(0, 50))
+	require.True(t, due, "the block interval passed")
+	require.Equal(t, uint64(150), height)
+
+	height, due = s.periodicFullScanDue(incrementalScanner, mark(2*time.Hour, 100))
+	require.True(t, due, "the time interval passed")
+	require.Equal(t, uint64(150), height)
+
+	allow = false
+	last := mark(2*time.Hour, 50)
+	_, due = s.periodicFullScanDue(incrementalScanner, last)
+	require.False(t, due, "the full scan was vetoed")
+	require.Len(t, requests, 3)
+	require.Equal(t, FullScanRequest{Reason: FullScanReasonInterval, Height: 150}, requests[2])
+
+	// after a veto, the hook is asked again one interval later, not at every check
+	_, due = s.periodicFullScanDue(incrementalScanner, last)
+	require.False(t, due)
+	require.Len(t, requests, 3)
+	require.Equal(t, uint64(150), last.height)
+
+	last.time = last.time.Add(-time.Hour)
+	_, due = s.periodicFullScanDue(incrementalScanner, last)
+	require.False(t, due)
+	require.Len(t, requests, 4)
+}
+
+func TestScan_Results(t *testing.T) {
+	addresses := []flow.Address{flow.HexToAddress("01"), flow.HexToAddress("02"), flow.HexToAddress("03")}
+
+	c := clienttest.New()
+	c.AddBlocks(1, 10)
+	c.HandleScripts(func(uint64, []byte, []cadence.Value) (cadence.Value, error) {
+		return cadence.NewBool(true), nil
+	})
+	s, err := NewScanner(c,
+		WithScript([]byte("pub fun main(addresses: [Address]): Bool { return true }")),
+		WithReferenceBlockHeight(10),
+		WithAddressProvider(StaticAddresses(addresses)),
+		WithBatchSize(2),
+	)
+	require.NoError(t, err)
+
+	// every scan closes its results channel, a second scan uses a new one
+	for i := 0; i < 2; i++ {
+		results := s.Results()
+		received := make(chan []flow.Address)
+		go func() {
+			var scanned []flow.Address
+			for result := range results {
+				scanned = append(scanned, result.Addresses...)
+			}
+			received <- scanned
+		}()
+
+		_, err = s.Scan(context.Background())
+		require.NoError(t, err)
+		require.ElementsMatch(t, addresses, <-received)
+	}
+
+	// a scan without Results does not send the results anywhere
+	_, err = s.Scan(context.Background())
+	require.NoError(t, err)
+}
+
+func TestScan_BlockID(t *testing.T) {
+	addresses := []flow.Address{flow.HexToAddress("01"), flow.HexToAddress("02"), flow.HexToAddress("03")}
+
+	c := clienttest.New()
+	c.AddBlocks(1, 10)
+	c.HandleScripts(func(uint64, []byte, []cadence.Value) (cadence.Value, error) {
+		return cadence.NewBool(true), nil
+	})
+	s, err := NewScanner(c,
+		WithScript([]byte("pub fun main(addresses: [Address]): Bool { return true }")),
+		WithReferenceBlockHeight(10),
+		WithAddressProvider(StaticAddresses(addresses)),
+		WithBatchSize(1),
+	)
+	require.NoError(t, err)
+
+	results := s.Results()
+	received := make(chan []flow.Identifier)
+	go func() {
+		var ids []flow.Identifier
+		for result := range results {
+			ids = append(ids, result.BlockID)
+		}
+		received <- ids
+	}()
+
+	_, err = s.Scan(context.Background())
+	require.NoError(t, err)
+	ids := <-received
+	require.Len(t, ids, len(addresses))
+	for _, id := range ids {
+		require.Equal(t, clienttest.BlockID(10), id)
+	}
+	// the full scan fetches the ID of its reference block once, not once per batch
+	require.Equal(t, 1, c.Calls(client.MethodGetBlockHeaderByHeight))
+}
@@ -0,0 +1,261 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scantest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/templates"
+
+	scanner "github.com/onflow/flow-batch-scan"
+)
+
+// ServiceAccountName is the name of the service account in TransactionFixture.Authorizers and Harness.Address.
+const ServiceAccountName = "service"
+
+// transactionGasLimit is the gas limit of the fixture transactions.
+const transactionGasLimit = 9999
+
+// Fixtures are the accounts, contracts and transactions seeded into the emulator before a scan.
+// The imports of the contracts and transactions are resolved with the Harness.ContractAliases,
+// so they can import the contracts of accounts created before them, e.g. `import "Counter"`.
+type Fixtures struct {
+	// Accounts are created in order, and their contracts are deployed in order.
+	Accounts []AccountFixture `json:"accounts"`
+	// Transactions are sent in order after the accounts were created, e.g. to emit the events the scan should find.
+	Transactions []TransactionFixture `json:"transactions"`
+}
+
+type AccountFixture struct {
+	// Name identifies the account in Harness.Address and TransactionFixture.Authorizers.
+	Name      string            `json:"name"`
+	Contracts []ContractFixture `json:"contracts"`
+}
+
+type ContractFixture struct {
+	Name string `json:"name"`
+	Code string `json:"code"`
+	// CodeFile is read instead of Code if set. ReadFixtures resolves it relative to the fixtures file.
+	CodeFile string `json:"code_file"`
+}
+
+type TransactionFixture struct {
+	Code string `json:"code"`
+	// CodeFile is read instead of Code if set. ReadFixtures resolves it relative to the fixtures file.
+	CodeFile string `json:"code_file"`
+	// Arguments are JSON-Cadence encoded.
+	Arguments []json.RawMessage `json:"arguments"`
+	// Authorizers are the names of the accounts that authorize the transaction.
+	Authorizers []string `json:"authorizers"`
+}
+
+// ReadFixtures reads the fixtures from a JSON file.
+func ReadFixtures(path string) (Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Fixtures{}, fmt.Errorf("could not read the fixtures: %w", err)
+	}
+	var fixtures Fixtures
+	err = json.Unmarshal(data, &fixtures)
+	if err != nil {
+		return Fixtures{}, fmt.Errorf("could not parse the fixtures %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for i := range fixtures.Accounts {
+		for j := range fixtures.Accounts[i].Contracts {
+			contract := &fixtures.Accounts[i].Contracts[j]
+			if contract.CodeFile != "" && !filepath.IsAbs(contract.CodeFile) {
+				contract.CodeFile = filepath.Join(dir, contract.CodeFile)
+			}
+		}
+	}
+	for i := range fixtures.Transactions {
+		transaction := &fixtures.Transactions[i]
+		if transaction.CodeFile != "" && !filepath.IsAbs(transaction.CodeFile) {
+			transaction.CodeFile = filepath.Join(dir, transaction.CodeFile)
+		}
+	}
+	return fixtures, nil
+}
+
+// Seed creates the accounts, deploys the contracts and sends the transactions of the fixtures.
+// Every account has the key of the service account.
+func (h *Harness) Seed(ctx context.Context, fixtures Fixtures) error {
+	for _, account := range fixtures.Accounts {
+		err := h.seedAccount(ctx, account)
+		if err != nil {
+			return err
+		}
+	}
+	for i, transaction := range fixtures.Transactions {
+		err := h.seedTransaction(ctx, transaction)
+		if err != nil {
+			return fmt.Errorf("transaction %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (h *Harness) seedAccount(ctx context.Context, account AccountFixture) error {
+	if _, ok := h.Address(account.Name); ok {
+		return fmt.Errorf("account %s: already exists", account.Name)
+	}
+
+	key := flow.NewAccountKey().
+		SetPublicKey(h.serviceAccount.PrivateKey.PublicKey()).
+		SetSigAlgo(h.serviceAccount.PrivateKey.Algorithm()).
+		SetHashAlgo(h.serviceAccount.HashAlgo).
+		SetWeight(flow.AccountKeyWeightThreshold)
+	tx, err := templates.CreateAccount([]*flow.AccountKey{key}, nil, h.serviceAccount.Address)
+	if err != nil {
+		return fmt.Errorf("account %s: %w", account.Name, err)
+	}
+	result, err := h.sendTransaction(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("account %s: could not create the account: %w", account.Name, err)
+	}
+	var address flow.Address
+	for _, event := range result.Events {
+		if event.Type == flow.EventAccountCreated {
+			address = flow.AccountCreatedEvent(event).Address()
+		}
+	}
+	if address == flow.EmptyAddress {
+		return fmt.Errorf("account %s: the account was not created", account.Name)
+	}
+	h.mu.Lock()
+	h.accounts[account.Name] = address
+	h.mu.Unlock()
+	h.logger.Debug().
+		Str("account", account.Name).
+		Str("address", "0x"+address.Hex()).
+		Msg("created fixture account")
+
+	for _, contract := range account.Contracts {
+		code, err := h.code(contract.Code, contract.CodeFile)
+		if err != nil {
+			return fmt.Errorf("account %s: contract %s: %w", account.Name, contract.Name, err)
+		}
+		tx := templates.AddAccountContract(address, templates.Contract{
+			Name:   contract.Name,
+			Source: string(code),
+		})
+		_, err = h.sendTransaction(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("account %s: could not deploy contract %s: %w", account.Name, contract.Name, err)
+		}
+		h.mu.Lock()
+		h.aliases = h.aliases.With(contract.Name, h.chainID, address)
+		h.mu.Unlock()
+	}
+	return nil
+}
+
+func (h *Harness) seedTransaction(ctx context.Context, transaction TransactionFixture) error {
+	code, err := h.code(transaction.Code, transaction.CodeFile)
+	if err != nil {
+		return err
+	}
+	tx := flow.NewTransaction().SetScript(code)
+	for _, argument := range transaction.Arguments {
+		tx.AddRawArgument(argument)
+	}
+	for _, name := range transaction.Authorizers {
+		address, ok := h.Address(name)
+		if !ok {
+			return fmt.Errorf("unknown authorizer %s", name)
+		}
+		tx.AddAuthorizer(address)
+	}
+	_, err = h.sendTransaction(ctx, tx)
+	return err
+}
+
+// code reads the code from the file if there is one, and resolves its imports.
+func (h *Harness) code(code string, file string) ([]byte, error) {
+	source := []byte(code)
+	if file != "" {
+		var err error
+		source, err = os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return scanner.ResolveImports(source, h.chainID, h.ContractAliases())
+}
+
+// sendTransaction sends the transaction with the service account as the proposer and payer,
+// and waits until it is sealed. All authorizers are signed for with the key of the service account.
+func (h *Harness) sendTransaction(ctx context.Context, tx *flow.Transaction) (*flow.TransactionResult, error) {
+	block, err := h.flowClient.GetLatestBlock(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	service, err := h.flowClient.GetAccount(ctx, h.serviceAccount.Address)
+	if err != nil {
+		return nil, err
+	}
+	if len(service.Keys) == 0 {
+		return nil, fmt.Errorf("the service account has no keys")
+	}
+	tx.SetReferenceBlockID(block.ID).
+		SetGasLimit(transactionGasLimit).
+		SetProposalKey(h.serviceAccount.Address, service.Keys[0].Index, service.Keys[0].SequenceNumber).
+		SetPayer(h.serviceAccount.Address)
+
+	signer, err := h.serviceAccount.signer()
+	if err != nil {
+		return nil, err
+	}
+	for _, authorizer := range tx.Authorizers {
+		if authorizer == h.serviceAccount.Address {
+			continue
+		}
+		err = tx.SignPayload(authorizer, 0, signer)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = tx.SignEnvelope(h.serviceAccount.Address, service.Keys[0].Index, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.flowClient.SendTransaction(ctx, *tx)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		result, err := h.flowClient.GetTransactionResult(ctx, tx.ID())
+		if err != nil {
+			return nil, err
+		}
+		if result.Status == flow.TransactionStatusSealed {
+			return result, result.Error
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
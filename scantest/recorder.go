@@ -0,0 +1,121 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scantest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+
+	scanner "github.com/onflow/flow-batch-scan"
+)
+
+// Recorder records the batches handled during a scan, to assert on the results.
+// Recorder is safe for concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	batches []scanner.ProcessedAddressBatch
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Record adds the batch to the recorded batches.
+func (r *Recorder) Record(batch scanner.ProcessedAddressBatch) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, batch)
+}
+
+// Batches are the recorded batches, in the order they were handled.
+func (r *Recorder) Batches() []scanner.ProcessedAddressBatch {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	batches := make([]scanner.ProcessedAddressBatch, len(r.batches))
+	copy(batches, r.batches)
+	return batches
+}
+
+// Results are the results of each address, see ProcessedAddressBatch.PerAddressResults.
+// If an address was scanned more than once, the result at the highest block height is used.
+func (r *Recorder) Results() (map[flow.Address]cadence.Value, error) {
+	results := make(map[flow.Address]cadence.Value)
+	heights := make(map[flow.Address]uint64)
+	for _, batch := range r.Batches() {
+		addressResults, err := batch.PerAddressResults()
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range addressResults {
+			if height, ok := heights[result.Address]; ok && height > batch.BlockHeight {
+				continue
+			}
+			results[result.Address] = result.Value
+			heights[result.Address] = batch.BlockHeight
+		}
+	}
+	return results, nil
+}
+
+// Result is the result of the address, see Results.
+func (r *Recorder) Result(address flow.Address) (cadence.Value, bool, error) {
+	results, err := r.Results()
+	if err != nil {
+		return nil, false, err
+	}
+	value, ok := results[address]
+	return value, ok, nil
+}
+
+// AssertResults returns an error describing the differences, if the results of the addresses are not the expected values.
+// Addresses that are not in expected are not checked, a nil expected value asserts that the address has no result.
+func (r *Recorder) AssertResults(expected map[flow.Address]cadence.Value) error {
+	results, err := r.Results()
+	if err != nil {
+		return err
+	}
+
+	addresses := make([]flow.Address, 0, len(expected))
+	for address := range expected {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i].Hex() < addresses[j].Hex()
+	})
+
+	var diffs []string
+	for _, address := range addresses {
+		want := expected[address]
+		got, ok := results[address]
+		switch {
+		case want == nil && ok:
+			diffs = append(diffs, fmt.Sprintf("0x%s: expected no result, got %s", address.Hex(), got))
+		case want == nil:
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("0x%s: expected %s, got no result", address.Hex(), want))
+		case got.String() != want.String():
+			diffs = append(diffs, fmt.Sprintf("0x%s: expected %s, got %s", address.Hex(), want, got))
+		}
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("unexpected scan results:\n%s", strings.Join(diffs, "\n"))
+	}
+	return nil
+}
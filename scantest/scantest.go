@@ -0,0 +1,280 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scantest runs a scan against a Flow emulator, for integration tests of scripts and result handlers:
+//
+//	harness, err := scantest.Start(ctx, scantest.Config{})
+//	defer harness.Stop()
+//	err = harness.Seed(ctx, fixtures)
+//	recorder, concluded, err := harness.Scan(ctx, scanner.WithScript(script))
+//	err = recorder.AssertResults(expected)
+//
+// By default an in-process emulator is started. Set Config.AccessNode to use an emulator that is already running.
+package scantest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/onflow/flow-emulator/emulator"
+	"github.com/onflow/flow-emulator/server"
+	"github.com/onflow/flow-emulator/server/access"
+	"github.com/onflow/flow-go-sdk"
+	flowgrpc "github.com/onflow/flow-go-sdk/access/grpc"
+	"github.com/onflow/flow-go-sdk/crypto"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/client"
+)
+
+// DefaultStartTimeout is how long Start waits for the emulator to accept requests.
+const DefaultStartTimeout = 30 * time.Second
+
+// ServiceAccount is the account that pays for the fixtures, and the key of every account created from them.
+type ServiceAccount struct {
+	Address    flow.Address
+	PrivateKey crypto.PrivateKey
+	HashAlgo   crypto.HashAlgorithm
+}
+
+// DefaultServiceAccount is the service account of an emulator started without a service key.
+func DefaultServiceAccount() ServiceAccount {
+	key := emulator.DefaultServiceKey()
+	return ServiceAccount{
+		Address:    flow.ServiceAddress(flow.Emulator),
+		PrivateKey: key.PrivateKey,
+		HashAlgo:   key.HashAlgo,
+	}
+}
+
+func (a ServiceAccount) signer() (crypto.Signer, error) {
+	return crypto.NewInMemorySigner(a.PrivateKey, a.HashAlgo)
+}
+
+type Config struct {
+	// AccessNode is the gRPC address of an emulator that is already running.
+	// If empty an in-process emulator is started on a free port, and stopped by Harness.Stop.
+	AccessNode string
+	// ServiceAccount of the emulator at AccessNode. Defaults to DefaultServiceAccount.
+	// It is ignored for the in-process emulator.
+	ServiceAccount *ServiceAccount
+	// StartTimeout is how long Start waits for the emulator to accept requests. Defaults to DefaultStartTimeout.
+	StartTimeout time.Duration
+	// Logger is the logger of the emulator and of the scans.
+	Logger zerolog.Logger
+}
+
+// Harness is a running emulator, with the accounts seeded from fixtures.
+type Harness struct {
+	client         client.ClosableClient
+	flowClient     *flowgrpc.Client
+	serviceAccount ServiceAccount
+	chainID        flow.ChainID
+	logger         zerolog.Logger
+
+	// emulator is the gRPC server of the in-process emulator, served is closed once it stopped serving.
+	emulator *grpc.Server
+	served   chan struct{}
+	// scans are the scans that are running, Stop waits for them to return.
+	scans sync.WaitGroup
+
+	mu       sync.Mutex
+	accounts map[string]flow.Address
+	aliases  scanner.ContractAliases
+}
+
+// Start starts an in-process emulator, or connects to the emulator at Config.AccessNode,
+// and waits until it accepts requests.
+func Start(ctx context.Context, config Config) (*Harness, error) {
+	if config.StartTimeout <= 0 {
+		config.StartTimeout = DefaultStartTimeout
+	}
+	h := &Harness{
+		logger:   config.Logger,
+		accounts: make(map[string]flow.Address),
+		aliases:  scanner.DefaultContractAliases(),
+	}
+
+	accessNode := config.AccessNode
+	if accessNode == "" {
+		var err error
+		accessNode, err = h.startEmulator()
+		if err != nil {
+			return nil, err
+		}
+	} else if config.ServiceAccount != nil {
+		h.serviceAccount = *config.ServiceAccount
+	} else {
+		h.serviceAccount = DefaultServiceAccount()
+	}
+
+	err := h.connect(ctx, accessNode, config.StartTimeout)
+	if err != nil {
+		h.Stop()
+		return nil, err
+	}
+	h.accounts[ServiceAccountName] = h.serviceAccount.Address
+	return h, nil
+}
+
+// startEmulator starts an in-process emulator that only serves the access API over gRPC.
+// The other routines of the emulator server (e.g. the debugger) are not started,
+// they can not be stopped safely while they are starting.
+func (h *Harness) startEmulator() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("could not start the emulator: %w", err)
+	}
+	logger := h.logger.With().Str("component", "emulator").Logger()
+	emulatorServer := server.NewEmulatorServer(&logger, &server.Config{
+		Host: "127.0.0.1",
+	})
+	if emulatorServer == nil {
+		_ = listener.Close()
+		return "", fmt.Errorf("could not create the emulator")
+	}
+	blockchain := emulatorServer.Emulator()
+	h.emulator = access.NewGRPCServer(
+		&logger,
+		emulatorServer.AccessAdapter(),
+		blockchain.GetNetworkParameters().ChainID.Chain(),
+		"127.0.0.1",
+		0,
+		false,
+	).Server()
+	h.served = make(chan struct{})
+	go func() {
+		defer close(h.served)
+		_ = h.emulator.Serve(listener)
+	}()
+
+	key := blockchain.ServiceKey()
+	h.serviceAccount = ServiceAccount{
+		Address:    key.Address,
+		PrivateKey: key.PrivateKey,
+		HashAlgo:   key.HashAlgo,
+	}
+	return listener.Addr().String(), nil
+}
+
+func (h *Harness) connect(ctx context.Context, accessNode string, timeout time.Duration) error {
+	preset := client.EmulatorPreset()
+	preset.AccessNodes = []string{accessNode}
+	var err error
+	h.client, err = preset.NewClient(client.WithLog(h.logger))
+	if err != nil {
+		return fmt.Errorf("could not connect to the emulator at %s: %w", accessNode, err)
+	}
+	h.flowClient, err = flowgrpc.NewClient(accessNode, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("could not connect to the emulator at %s: %w", accessNode, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		parameters, err := h.client.GetNetworkParameters(ctx)
+		if err == nil {
+			h.chainID = parameters.ChainID
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("the emulator at %s did not accept requests: %w", accessNode, err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Stop waits for the running scans to return, closes the connections, and stops the in-process emulator.
+func (h *Harness) Stop() {
+	h.scans.Wait()
+	if h.client != nil {
+		_ = h.client.Close()
+	}
+	if h.flowClient != nil {
+		_ = h.flowClient.Close()
+	}
+	if h.emulator != nil {
+		h.emulator.Stop()
+		<-h.served
+	}
+}
+
+// Client is the client connected to the emulator.
+func (h *Harness) Client() client.Client {
+	return h.client
+}
+
+// ChainID is the chain ID of the emulator.
+func (h *Harness) ChainID() flow.ChainID {
+	return h.chainID
+}
+
+// Address returns the address of the account created from the fixture with the name.
+func (h *Harness) Address(name string) (flow.Address, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	address, ok := h.accounts[name]
+	return address, ok
+}
+
+// ContractAliases are the DefaultContractAliases, with the contracts deployed from the fixtures added.
+func (h *Harness) ContractAliases() scanner.ContractAliases {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.aliases
+}
+
+// Scan runs a full scan of the emulator at its latest sealed block (see Config.WithReferenceBlockHeight)
+// with the options, and records the handled batches.
+// The imports of the scripts are resolved with the ContractAliases, unless the options resolve them differently.
+// The ScriptResultHandler of the options (if any) handles the batches as usual, before they are recorded.
+func (h *Harness) Scan(ctx context.Context, opts ...scanner.Option) (*Recorder, scanner.ScanConcluded, error) {
+	h.scans.Add(1)
+	defer h.scans.Done()
+
+	header, err := h.client.GetLatestBlockHeader(ctx, true)
+	if err != nil {
+		return nil, scanner.ScanConcluded{}, err
+	}
+	options := append([]scanner.Option{
+		scanner.WithReferenceBlockHeight(header.Height),
+		scanner.WithResolveImports(h.ContractAliases()),
+		scanner.WithLogger(h.logger),
+	}, opts...)
+	scan, err := scanner.NewScanner(h.client, options...)
+	if err != nil {
+		return nil, scanner.ScanConcluded{}, err
+	}
+
+	recorder := NewRecorder()
+	recorded := make(chan struct{})
+	results := scan.Results()
+	go func() {
+		defer close(recorded)
+		for batch := range results {
+			recorder.Record(batch)
+		}
+	}()
+	concluded, err := scan.Scan(ctx)
+	<-recorded
+	return recorder, concluded, err
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scantest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	scanner "github.com/onflow/flow-batch-scan"
+	"github.com/onflow/flow-batch-scan/scantest"
+	"github.com/onflow/flow-batch-scan/scripts"
+)
+
+func TestHarness(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts an emulator")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	harness, err := scantest.Start(ctx, scantest.Config{})
+	require.NoError(t, err)
+	defer harness.Stop()
+	require.Equal(t, flow.Emulator, harness.ChainID())
+
+	err = harness.Seed(ctx, scantest.Fixtures{
+		Accounts: []scantest.AccountFixture{{
+			Name: "alice",
+			Contracts: []scantest.ContractFixture{{
+				Name: "Counter",
+				Code: `
+					pub contract Counter {
+						pub event Incremented(count: Int)
+						pub var count: Int
+						init() { self.count = 0 }
+						pub fun increment() {
+							self.count = self.count + 1
+							emit Incremented(count: self.count)
+						}
+					}`,
+			}},
+		}, {
+			Name: "bob",
+		}},
+		Transactions: []scantest.TransactionFixture{{
+			Code: `
+				import "Counter"
+				transaction {
+					prepare(signer: AuthAccount) { Counter.increment() }
+				}`,
+			Authorizers: []string{"bob"},
+		}},
+	})
+	require.NoError(t, err)
+	alice, ok := harness.Address("alice")
+	require.True(t, ok)
+	bob, ok := harness.Address("bob")
+	require.True(t, ok)
+
+	recorder, concluded, err := harness.Scan(ctx, scanner.WithScript(scripts.ContractNames))
+	require.NoError(t, err)
+	require.True(t, concluded.ScanIsComplete)
+	require.NoError(t, recorder.AssertResults(map[flow.Address]cadence.Value{
+		alice: cadence.NewArray([]cadence.Value{cadence.String("Counter")}),
+		bob:   cadence.NewArray([]cadence.Value{}),
+	}))
+	require.Error(t, recorder.AssertResults(map[flow.Address]cadence.Value{
+		bob: cadence.NewArray([]cadence.Value{cadence.String("Counter")}),
+	}))
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// scheduledRun is a run of the Schedule.
+type scheduledRun struct {
+	done      chan struct{}
+	concluded ScanConcluded
+	err       error
+}
+
+// scanOnSchedule calls run at every time of the schedule, until the context is cancelled.
+// A run is skipped if the previous run is still in progress.
+// A failed run does not stop the schedule. It returns the result of the last run, once it finished.
+func (scanner *Scanner) scanOnSchedule(
+	ctx context.Context,
+	schedule cron.Schedule,
+	run func(ctx context.Context) (ScanConcluded, error),
+) (ScanConcluded, error) {
+	var last *scheduledRun
+	for {
+		next := schedule.Next(time.Now())
+		scanner.logger.Info().
+			Time("next_run", next).
+			Msg("Waiting for the next scheduled scan")
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if last == nil {
+				return ScanConcluded{}, nil
+			}
+			<-last.done
+			if errors.Is(last.err, context.Canceled) {
+				return last.concluded, nil
+			}
+			return last.concluded, last.err
+		case <-timer.C:
+		}
+
+		if last != nil && !last.isDone() {
+			scanner.logger.Warn().
+				Time("scheduled", next).
+				Msg("Skipping the scheduled scan, the previous scan is still in progress")
+			scanner.config.Hooks.scheduledRunSkipped(next)
+			continue
+		}
+
+		scanner.logger.Info().
+			Time("scheduled", next).
+			Msg("Starting the scheduled scan")
+		current := &scheduledRun{done: make(chan struct{})}
+		last = current
+		go func() {
+			defer close(current.done)
+			current.concluded, current.err = run(ctx)
+			if current.err != nil && !errors.Is(current.err, context.Canceled) {
+				scanner.logger.Error().
+					Err(current.err).
+					Msg("Scheduled scan failed")
+			}
+		}()
+	}
+}
+
+func (r *scheduledRun) isDone() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// everySchedule is a cron.Schedule with an interval shorter than a second.
+type everySchedule time.Duration
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+func TestScanner_ScanOnSchedule(t *testing.T) {
+	var skipped atomic.Int64
+	scanner, err := NewScanner(nil, DefaultConfig().
+		WithSchedule("@every 1h").
+		WithOnScheduledRunSkipped(func(time.Time) { skipped.Add(1) }))
+	require.NoError(t, err)
+
+	var runs atomic.Int64
+	release := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	var concluded ScanConcluded
+	go func() {
+		defer close(done)
+		concluded, err = scanner.scanOnSchedule(ctx, everySchedule(10*time.Millisecond), func(ctx context.Context) (ScanConcluded, error) {
+			run := runs.Add(1)
+			if run == 1 {
+				// the first run takes a while, the next scheduled runs are skipped
+				<-release
+			}
+			return ScanConcluded{LatestScannedBlockHeight: uint64(run), ScanIsComplete: true}, nil
+		})
+	}()
+
+	require.Eventually(t, func() bool { return skipped.Load() >= 2 }, time.Second, 5*time.Millisecond)
+	require.Equal(t, int64(1), runs.Load())
+	close(release)
+	require.Eventually(t, func() bool { return runs.Load() >= 3 }, time.Second, 5*time.Millisecond)
+
+	cancel()
+	<-done
+	require.NoError(t, err)
+	require.True(t, concluded.ScanIsComplete)
+	require.GreaterOrEqual(t, concluded.LatestScannedBlockHeight, uint64(3))
+}
+
+func TestConfig_WithSchedule(t *testing.T) {
+	require.NoError(t, DefaultConfig().WithSchedule("0 3 * * *").Validate())
+	require.NoError(t, DefaultConfig().WithSchedule("@every 6h").Validate())
+	require.ErrorContains(t, DefaultConfig().WithSchedule("every day").Validate(), "WithSchedule: invalid schedule")
+	require.ErrorContains(t, DefaultConfig().WithSchedule("@daily").WithContinuousScan(true).Validate(),
+		"Schedule (a scan per scheduled time) and ContinuousScan can not be combined")
+}
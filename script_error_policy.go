@@ -0,0 +1,167 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ScriptErrorPolicy is how the script errors of a class are handled, see Config.WithScriptErrorPolicy.
+// The errors of classes without a policy are handled by HandleScriptError.
+type ScriptErrorPolicy int
+
+const (
+	// ScriptErrorPolicyShrinkBatch splits the batch in half and runs both halves again.
+	// The error of a batch of a single address is handled by HandleScriptError.
+	ScriptErrorPolicyShrinkBatch ScriptErrorPolicy = iota
+	// ScriptErrorPolicySkipAddress splits the batch until the failing addresses are found, and skips them.
+	// The skipped addresses are recorded in ScanStats.FailedBatches and passed to the FailedBatchHandler.
+	// The scan fails if more than MaxFailedAddresses addresses are skipped.
+	ScriptErrorPolicySkipAddress
+	// ScriptErrorPolicyRetry runs the batch again after a backoff, at most ScriptErrorRetries times.
+	// After that, the error is handled by HandleScriptError.
+	ScriptErrorPolicyRetry
+	// ScriptErrorPolicyAbort fails the scan, the batch is not retried or passed to the FailedBatchHandler.
+	ScriptErrorPolicyAbort
+)
+
+// DefaultScriptErrorRetries is how often a batch is retried with ScriptErrorPolicyRetry.
+const DefaultScriptErrorRetries = 3
+
+// DefaultScriptErrorRetryBackoff is the backoff before the first retry of ScriptErrorPolicyRetry,
+// it doubles with every retry.
+const DefaultScriptErrorRetryBackoff = time.Second
+
+// maxScriptErrorRetryBackoff is the longest backoff of ScriptErrorPolicyRetry.
+const maxScriptErrorRetryBackoff = time.Minute
+
+// DefaultScriptErrorPolicies retry the transient errors of the access nodes and its rate limits,
+// instead of splitting the batch, and abort the scan if a contract or type is missing,
+// since every batch will fail the same way.
+func DefaultScriptErrorPolicies() map[error]ScriptErrorPolicy {
+	return map[error]ScriptErrorPolicy{
+		ErrTransient:       ScriptErrorPolicyRetry,
+		ErrRateLimited:     ScriptErrorPolicyRetry,
+		ErrMissingContract: ScriptErrorPolicyAbort,
+	}
+}
+
+func (p ScriptErrorPolicy) String() string {
+	switch p {
+	case ScriptErrorPolicyShrinkBatch:
+		return "shrink_batch"
+	case ScriptErrorPolicySkipAddress:
+		return "skip_address"
+	case ScriptErrorPolicyRetry:
+		return "retry"
+	case ScriptErrorPolicyAbort:
+		return "abort"
+	}
+	return fmt.Sprintf("ScriptErrorPolicy(%d)", int(p))
+}
+
+func (p ScriptErrorPolicy) valid() bool {
+	return p >= ScriptErrorPolicyShrinkBatch && p <= ScriptErrorPolicyAbort
+}
+
+// applyScriptErrorPolicy handles the error of the batch with the policy of its class.
+// It returns false if the error has to be handled by HandleScriptError,
+// because its class has no policy, or the policy does not apply to the batch.
+func (r *ScriptRunner) applyScriptErrorPolicy(ctx context.Context, input AddressBatch, err error) bool {
+	policy, ok := r.ScriptErrorPolicies[ClassifyError(err)]
+	if !ok {
+		return false
+	}
+
+	switch policy {
+	case ScriptErrorPolicyShrinkBatch, ScriptErrorPolicySkipAddress:
+		if len(input.Addresses) > 1 {
+			if r.batchSizeController.isAdaptive() && isLimitError(err) {
+				r.batchSizeController.limitExceeded(len(input.Addresses))
+			}
+			r.Logger.
+				Info().
+				Int("addresses", len(input.Addresses)).
+				Stringer("policy", policy).
+				Msg("retrying by splitting")
+			left, right := input.Split()
+			left.retries++
+			right.retries++
+			r.stats.batchRetried()
+			go func() {
+				r.handleBatch(ctx, left)
+				r.handleBatch(ctx, right)
+			}()
+			return true
+		}
+		if policy == ScriptErrorPolicyShrinkBatch {
+			return false
+		}
+		if r.skipFailedAddress(input, err) {
+			return true
+		}
+		// too many addresses failed
+		r.stats.batchFailed(input, err)
+		r.Finish(err)
+		return true
+	case ScriptErrorPolicyRetry:
+		if input.errorRetries >= r.ScriptErrorRetries {
+			return false
+		}
+		backoff := r.ScriptErrorRetryBackoff << input.errorRetries
+		if backoff <= 0 || backoff > maxScriptErrorRetryBackoff {
+			backoff = maxScriptErrorRetryBackoff
+		}
+		input.errorRetries++
+		input.retries++
+		r.Logger.
+			Info().
+			Int("retries", input.errorRetries).
+			Dur("backoff", backoff).
+			Msg("retrying after backoff")
+		r.stats.batchRetried()
+		go func() {
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+				r.handleBatch(ctx, input)
+			}
+		}()
+		return true
+	case ScriptErrorPolicyAbort:
+		r.Logger.
+			Error().
+			Err(err).
+			Msg("aborting the scan")
+		r.stats.batchFailed(input, err)
+		r.Finish(err)
+		return true
+	}
+	return false
+}
+
+// cloneScriptErrorPolicies clones the policies, utils.CloneMap can not be used with error keys before Go 1.20.
+func cloneScriptErrorPolicies(policies map[error]ScriptErrorPolicy) map[error]ScriptErrorPolicy {
+	if policies == nil {
+		return nil
+	}
+	cloned := make(map[error]ScriptErrorPolicy, len(policies))
+	for class, policy := range policies {
+		cloned[class] = policy
+	}
+	return cloned
+}
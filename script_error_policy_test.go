@@ -0,0 +1,141 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+)
+
+func TestClassifyError_ScriptErrorPolicyClasses(t *testing.T) {
+	require.Equal(t, ErrTransient, ClassifyError(status.Error(codes.Unavailable, "connection refused")))
+	require.Equal(t, ErrMissingContract, ClassifyError(errors.New(
+		"[Error Code: 1101] cadence runtime error: error: cannot find declaration `FungibleToken` in `f233dcee88fe0abe.FungibleToken`")))
+	require.Equal(t, ErrMissingContract, ClassifyError(errors.New("[Error Code: 1251] contract Foo not found")))
+}
+
+type failedBatchHandlerFunc func(batch FailedBatch) error
+
+func (f failedBatchHandlerFunc) HandleFailedBatch(batch FailedBatch) error {
+	return f(batch)
+}
+
+func runScriptErrorPolicyBatch(
+	t *testing.T,
+	config ScriptRunnerConfig,
+	script func(addresses []cadence.Value) (cadence.Value, error),
+) (*ScriptRunner, chan ProcessedAddressBatch) {
+	c := clienttest.New()
+	c.AddBlock(10)
+	c.HandleScripts(func(_ uint64, _ []byte, arguments []cadence.Value) (cadence.Value, error) {
+		return script(arguments[0].(cadence.Array).Values)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	batches := make(chan AddressBatch, 1)
+	results := make(chan ProcessedAddressBatch, 2)
+	runner := NewScriptRunner(c, batches, results, config, zerolog.Nop())
+	<-runner.Start(ctx)
+
+	batches <- NewAddressBatch([]flow.Address{flow.HexToAddress("01"), flow.HexToAddress("02")}, 10, func() {}, nil)
+	return runner, results
+}
+
+func TestScriptRunner_ScriptErrorPolicies(t *testing.T) {
+	t.Run("retry transient errors", func(t *testing.T) {
+		config := DefaultScriptRunnerConfig()
+		config.ScriptErrorRetryBackoff = time.Millisecond
+		var calls atomic.Int32
+		_, results := runScriptErrorPolicyBatch(t, config, func(addresses []cadence.Value) (cadence.Value, error) {
+			if calls.Add(1) == 1 {
+				return nil, status.Error(codes.Unavailable, "unavailable")
+			}
+			return cadence.NewBool(true), nil
+		})
+
+		select {
+		case result := <-results:
+			// retried as a whole, not split
+			require.Len(t, result.Addresses, 2)
+			require.Equal(t, 1, result.Retries)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "batch was not handled")
+		}
+	})
+
+	t.Run("abort on missing contracts", func(t *testing.T) {
+		config := DefaultScriptRunnerConfig()
+		var calls atomic.Int32
+		runner, _ := runScriptErrorPolicyBatch(t, config, func(addresses []cadence.Value) (cadence.Value, error) {
+			calls.Add(1)
+			return nil, errors.New("error: cannot find type in this scope: `Foo`")
+		})
+
+		select {
+		case <-runner.Done():
+			require.ErrorIs(t, runner.Err(), ErrMissingContract)
+			require.Equal(t, int32(1), calls.Load())
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "scan was not aborted")
+		}
+	})
+
+	t.Run("skip address", func(t *testing.T) {
+		config := DefaultScriptRunnerConfig()
+		config.BisectFailedBatches = false
+		config.ScriptErrorPolicies = map[error]ScriptErrorPolicy{ErrAccountNotFound: ScriptErrorPolicySkipAddress}
+		failed := make(chan FailedBatch, 1)
+		config.FailedBatchHandler = failedBatchHandlerFunc(func(batch FailedBatch) error {
+			failed <- batch
+			return nil
+		})
+		_, results := runScriptErrorPolicyBatch(t, config, func(addresses []cadence.Value) (cadence.Value, error) {
+			for _, address := range addresses {
+				if flow.Address(address.(cadence.Address)) == flow.HexToAddress("02") {
+					return nil, errors.New("[Error Code: 1201] account not found")
+				}
+			}
+			return cadence.NewBool(true), nil
+		})
+
+		select {
+		case result := <-results:
+			require.Equal(t, []flow.Address{flow.HexToAddress("01")}, result.Addresses)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "batch was not handled")
+		}
+		select {
+		case batch := <-failed:
+			require.Equal(t, []flow.Address{flow.HexToAddress("02")}, batch.Addresses)
+			require.ErrorIs(t, batch.Err, ErrAccountNotFound)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "address was not skipped")
+		}
+	})
+}
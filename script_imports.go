@@ -0,0 +1,165 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/onflow/flow-go-sdk"
+
+	"github.com/onflow/flow-batch-scan/utils"
+)
+
+// ContractAliases are the addresses of contracts on each chain, like the aliases in flow.json.
+// They are used to resolve placeholder imports in scripts, see ResolveImports.
+type ContractAliases map[string]map[flow.ChainID]flow.Address
+
+// DefaultContractAliases returns the aliases of the core contracts.
+func DefaultContractAliases() ContractAliases {
+	fungibleToken := map[flow.ChainID]flow.Address{
+		flow.Emulator: flow.HexToAddress("ee82856bf20e2aa6"),
+		flow.Testnet:  flow.HexToAddress("9a0766d93b6608b7"),
+		flow.Mainnet:  flow.HexToAddress("f233dcee88fe0abe"),
+	}
+	nonFungibleToken := map[flow.ChainID]flow.Address{
+		flow.Emulator: flow.HexToAddress("f8d6e0586b0a20c7"),
+		flow.Testnet:  flow.HexToAddress("631e88ae7f1d7c20"),
+		flow.Mainnet:  flow.HexToAddress("1d7e57aa55817448"),
+	}
+	serviceAccount := map[flow.ChainID]flow.Address{
+		flow.Emulator: flow.HexToAddress("f8d6e0586b0a20c7"),
+		flow.Testnet:  flow.HexToAddress("8c5303eaa26202d6"),
+		flow.Mainnet:  flow.HexToAddress("e467b9dd11fa00df"),
+	}
+	return ContractAliases{
+		"FungibleToken":              fungibleToken,
+		"FungibleTokenMetadataViews": fungibleToken,
+		"FlowToken": {
+			flow.Emulator: flow.HexToAddress("0ae53cb6e3f42a79"),
+			flow.Testnet:  flow.HexToAddress("7e60df042a9c0868"),
+			flow.Mainnet:  flow.HexToAddress("1654653399040a61"),
+		},
+		"FlowFees": {
+			flow.Emulator: flow.HexToAddress("e5a8b7f23e8b548f"),
+			flow.Testnet:  flow.HexToAddress("912d5440f7e3769e"),
+			flow.Mainnet:  flow.HexToAddress("f919ee77447b7497"),
+		},
+		"NonFungibleToken":   nonFungibleToken,
+		"MetadataViews":      nonFungibleToken,
+		"ViewResolver":       nonFungibleToken,
+		"FlowStorageFees":    serviceAccount,
+		"FlowServiceAccount": serviceAccount,
+	}
+}
+
+// With returns a copy of the aliases with the address of the contract on the chain added.
+func (a ContractAliases) With(contractName string, chainID flow.ChainID, address flow.Address) ContractAliases {
+	aliases := make(ContractAliases, len(a)+1)
+	for name, addresses := range a {
+		aliases[name] = addresses
+	}
+	addresses := make(map[flow.ChainID]flow.Address, len(aliases[contractName])+1)
+	for id, address := range aliases[contractName] {
+		addresses[id] = address
+	}
+	addresses[chainID] = address
+	aliases[contractName] = addresses
+	return aliases
+}
+
+// clone returns a copy of the aliases, including the addresses of each contract.
+func (a ContractAliases) clone() ContractAliases {
+	if a == nil {
+		return nil
+	}
+	aliases := make(ContractAliases, len(a))
+	for name, addresses := range a {
+		aliases[name] = utils.CloneMap(addresses)
+	}
+	return aliases
+}
+
+func (a ContractAliases) address(contractName string, chainID flow.ChainID) (flow.Address, error) {
+	address, ok := a[contractName][chainID]
+	if !ok {
+		return flow.EmptyAddress, fmt.Errorf("no address for contract %s on chain %s", contractName, chainID)
+	}
+	return address, nil
+}
+
+// stringImportRegex matches `import "Name"`.
+var stringImportRegex = regexp.MustCompile(`(?m)^(\s*)import\s+"(\w+)"`)
+
+// placeholderImportRegex matches `import Name from 0xPLACEHOLDER`, where the placeholder is not an address.
+var placeholderImportRegex = regexp.MustCompile(`(?m)^(\s*)import\s+(\w+)\s+from\s+0x(\w*[g-zG-Z_]\w*)\b`)
+
+// ResolveImports replaces the placeholder imports in the script with the addresses of the contracts on the chain.
+// Both `import "FungibleToken"` and `import FungibleToken from 0xFUNGIBLETOKEN` are resolved to
+// `import FungibleToken from 0x<address>`. Imports from actual addresses are left as they are.
+func ResolveImports(script []byte, chainID flow.ChainID, aliases ContractAliases) ([]byte, error) {
+	var err error
+	resolve := func(re *regexp.Regexp) func([]byte) []byte {
+		return func(match []byte) []byte {
+			groups := re.FindSubmatch(match)
+			indent, name := groups[1], string(groups[2])
+			address, addressErr := aliases.address(name, chainID)
+			if addressErr != nil {
+				if err == nil {
+					err = addressErr
+				}
+				return match
+			}
+			return []byte(fmt.Sprintf("%simport %s from 0x%s", indent, name, address.Hex()))
+		}
+	}
+
+	script = stringImportRegex.ReplaceAllFunc(script, resolve(stringImportRegex))
+	script = placeholderImportRegex.ReplaceAllFunc(script, resolve(placeholderImportRegex))
+	if err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// resolveImports resolves the imports of Script, Scripts and ScriptUpgrades, if ContractAliases are set.
+func (c ScriptRunnerConfig) resolveImports(chainID flow.ChainID) (ScriptRunnerConfig, error) {
+	if c.ContractAliases == nil {
+		return c, nil
+	}
+
+	script, err := ResolveImports(c.Script, chainID, c.ContractAliases)
+	if err != nil {
+		return c, fmt.Errorf("could not resolve imports of the script: %w", err)
+	}
+	c.Script = script
+
+	if c.Scripts != nil {
+		scripts := make(map[string][]byte, len(c.Scripts))
+		for name, script := range c.Scripts {
+			scripts[name], err = ResolveImports(script, chainID, c.ContractAliases)
+			if err != nil {
+				return c, fmt.Errorf("could not resolve imports of script %s: %w", name, err)
+			}
+		}
+		c.Scripts = scripts
+	}
+
+	c.ScriptUpgrades, err = c.resolveUpgradeImports(chainID)
+	if err != nil {
+		return c, err
+	}
+	return c, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveImports(t *testing.T) {
+	script := []byte(`import "FungibleToken"
+import FlowToken from 0xFLOWTOKEN
+import MyContract from 0x0b2a3299cc857e29
+
+pub fun main(addresses: [Address]): [UFix64] { return [] }
+`)
+
+	t.Run("resolves placeholders", func(t *testing.T) {
+		resolved, err := ResolveImports(script, flow.Testnet, DefaultContractAliases())
+		require.NoError(t, err)
+		require.Equal(t, `import FungibleToken from 0x9a0766d93b6608b7
+import FlowToken from 0x7e60df042a9c0868
+import MyContract from 0x0b2a3299cc857e29
+
+pub fun main(addresses: [Address]): [UFix64] { return [] }
+`, string(resolved))
+	})
+	t.Run("custom aliases", func(t *testing.T) {
+		aliases := ContractAliases{}.
+			With("FungibleToken", flow.Emulator, flow.HexToAddress("01")).
+			With("FlowToken", flow.Emulator, flow.HexToAddress("02"))
+		resolved, err := ResolveImports(script, flow.Emulator, aliases)
+		require.NoError(t, err)
+		require.Contains(t, string(resolved), "import FungibleToken from 0x0000000000000001")
+		require.Contains(t, string(resolved), "import FlowToken from 0x0000000000000002")
+	})
+	t.Run("unknown contract", func(t *testing.T) {
+		_, err := ResolveImports([]byte(`import "Unknown"`), flow.Mainnet, DefaultContractAliases())
+		require.Error(t, err)
+	})
+}
@@ -17,14 +17,21 @@ package scanner
 import (
 	"context"
 	"errors"
+	"fmt"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/onflow/cadence"
 	"github.com/onflow/flow-go-sdk"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/utils"
 )
 
 // DefaultScriptRunnerMaxConcurrentScripts is the maximum number of scripts that can be running concurrently
@@ -32,11 +39,107 @@ import (
 // As long as they don't wait too long, this is not a problem.
 const DefaultScriptRunnerMaxConcurrentScripts = 20
 
+// DefaultScriptTimeoutRetries is how often a batch is retried after its script timed out,
+// before the timeout is handled like any other script error.
+const DefaultScriptTimeoutRetries = 2
+
+// ErrScriptTimeout is returned when the script of a batch took longer than the ScriptTimeout.
+var ErrScriptTimeout = errors.New("script execution timed out")
+
+// DefaultMaxFailedAddresses is the number of addresses that can fail, before the scan fails.
+const DefaultMaxFailedAddresses = 100
+
+// DefaultPerAddressConcurrency is the maximum number of concurrent scripts of one batch in per address mode.
+const DefaultPerAddressConcurrency = 5
+
+// ConcurrencyWindow overrides MaxConcurrentScripts during a time of day window.
+type ConcurrencyWindow struct {
+	utils.TimeOfDayWindow
+
+	MaxConcurrentScripts int
+}
+
 type ScriptRunnerConfig struct {
 	Script []byte
+	// Scripts if set, every batch is run against each of the scripts instead of Script.
+	// The name of the script is set on the ProcessedAddressBatch, see ScriptResultHandlerMux.
+	Scripts map[string][]byte
+	// ScriptUpgrades replace Script, or a script of Scripts, for the batches at or above their heights.
+	// They are ordered by height. See ScriptUpgrade.
+	ScriptUpgrades []ScriptUpgrade
+	// ContractAliases if set, are used to resolve the placeholder imports of the scripts
+	// for the ChainID when the scan starts. See ResolveImports.
+	ContractAliases ContractAliases
+	// ScriptArguments are passed to the script after the addresses.
+	ScriptArguments []cadence.Value
+	// ScriptArgumentsForBatch if set, is used instead of ScriptArguments to get the arguments for each batch.
+	ScriptArgumentsForBatch func(batch AddressBatch) []cadence.Value
+	// ScriptBatchSizes if set, are the maximum numbers of addresses per execution of the Scripts, by name.
+	// The batches are split for the scripts with a smaller batch size. See ScanJob.BatchSize.
+	ScriptBatchSizes map[string]int
+	// ExpectedResultTypes if set, are the types the results of the scripts must have, by script name
+	// ("" is Script). A result of another type fails the scan with an ErrUnexpectedResultType,
+	// instead of being handled. See CheckResultType.
+	ExpectedResultTypes map[string]cadence.Type
 
 	MaxConcurrentScripts int
-	HandleScriptError    func(AddressBatch, error) ScriptErrorAction
+	// ConcurrencySchedule overrides MaxConcurrentScripts during certain times of the day.
+	// The first window that contains the current time is used.
+	ConcurrencySchedule []ConcurrencyWindow
+	HandleScriptError   func(AddressBatch, error) ScriptErrorAction
+	// HandleAddressError decides if an address that fails on its own is skipped, see AddressErrorAction.
+	HandleAddressError func(flow.Address, error) AddressErrorAction
+
+	// ScriptTimeout if set, is the deadline for one script execution, independent of the client timeout.
+	// A batch that times out is retried ScriptTimeoutRetries times,
+	// after that ErrScriptTimeout is handled by HandleScriptError (or BisectFailedBatches).
+	ScriptTimeout        time.Duration
+	ScriptTimeoutRetries int
+
+	// ScriptErrorPolicies are how the script errors of each class, e.g. ErrTransient, are handled.
+	// The errors of classes without a policy are handled by HandleScriptError. See ScriptErrorPolicy.
+	ScriptErrorPolicies map[error]ScriptErrorPolicy
+	// ScriptErrorRetries is how often a batch is retried with ScriptErrorPolicyRetry,
+	// with a backoff that starts at ScriptErrorRetryBackoff and doubles with every retry.
+	ScriptErrorRetries      int
+	ScriptErrorRetryBackoff time.Duration
+
+	// BisectFailedBatches if true, batches that fail with an error HandleScriptError does not handle
+	// are split in half and retried, until the failing addresses are found. It is off by default,
+	// see Config.WithBisectFailedBatches.
+	// The failing addresses are skipped and returned in ScanStats.FailedBatches.
+	// If more than MaxFailedAddresses addresses fail (and MaxFailedAddresses is not 0), the scan fails.
+	BisectFailedBatches bool
+	MaxFailedAddresses  int
+
+	// FailedBatchHandler if set, receives the batches that failed after all retries,
+	// and the scan continues without them instead of stopping with the error.
+	// See FileFailedBatchHandler.
+	FailedBatchHandler FailedBatchHandler
+
+	// BatchMetricsObserver if set, observes every execution of the script of a batch.
+	BatchMetricsObserver BatchMetricsObserver
+
+	// PerAddress if true, the script is run once for each address in the batch, with a single `Address` argument,
+	// instead of once with an `[Address]` argument. The result of the batch is an array of the results
+	// in the same order as the addresses. At most PerAddressConcurrency scripts of one batch run concurrently.
+	PerAddress            bool
+	PerAddressConcurrency int
+
+	// AccountBalanceMode if true, no script is run. The FLOW balance of each address is read with
+	// GetAccountAtBlockHeight instead, which is cheaper for the access nodes and not subject to the computation limits.
+	// The result of a batch is a `{Address: UFix64}` dictionary, like the result of scripts.FlowBalances.
+	// At most PerAddressConcurrency accounts of one batch are requested concurrently.
+	AccountBalanceMode bool
+
+	// AccountKeysMode if true, no script is run. The keys of each address are read with GetAccountAtBlockHeight,
+	// like in AccountBalanceMode. The result of a batch is a `{Address: [KeyInfo]}` dictionary,
+	// like the result of scripts.AccountKeys, with the sequence number of each key, which a script can not read.
+	AccountKeysMode bool
+
+	// DryRun if true, scripts are not run. The batches are counted in ScanStats and marked as done.
+	// This can be used to check the candidate scanners and estimate how long a scan would take.
+	DryRun bool
 }
 
 func DefaultScriptRunnerConfig() ScriptRunnerConfig {
@@ -44,7 +147,20 @@ func DefaultScriptRunnerConfig() ScriptRunnerConfig {
 		Script: []byte(defaultScript),
 
 		MaxConcurrentScripts: DefaultScriptRunnerMaxConcurrentScripts,
+		ConcurrencySchedule:  nil,
 		HandleScriptError:    DefaultHandleScriptError,
+		HandleAddressError:   DefaultHandleAddressError,
+
+		ScriptTimeoutRetries: DefaultScriptTimeoutRetries,
+
+		ScriptErrorPolicies:     DefaultScriptErrorPolicies(),
+		ScriptErrorRetries:      DefaultScriptErrorRetries,
+		ScriptErrorRetryBackoff: DefaultScriptErrorRetryBackoff,
+
+		BisectFailedBatches: false,
+		MaxFailedAddresses:  DefaultMaxFailedAddresses,
+
+		PerAddressConcurrency: DefaultPerAddressConcurrency,
 	}
 }
 
@@ -58,7 +174,20 @@ type ScriptRunner struct {
 	addressBatchChan <-chan AddressBatch
 	resultsChan      chan<- ProcessedAddressBatch
 
-	limitChan chan struct{}
+	limiter *utils.DynamicSemaphore
+	// pause stops new batches from being run while the scan is paused.
+	pause *pauseGate
+	stats *statsCollector
+	// reporter if set, receives the script errors.
+	reporter StatusReporter
+	tracer   trace.Tracer
+	// failedAddresses is the number of addresses skipped because of BisectFailedBatches
+	failedAddresses atomic.Int32
+	tooManyFailed   atomic.Bool
+	// batchSizeController adapts the batch size, if the batch size is adaptive.
+	batchSizeController *batchSizeController
+	// throttle observes the script latencies and errors, to throttle the full scan.
+	throttle *fullScanThrottle
 }
 
 var _ Component = (*ScriptRunner)(nil)
@@ -77,9 +206,10 @@ func NewScriptRunner(
 		client:           client,
 		addressBatchChan: addressBatchChan,
 		resultsChan:      resultsChan,
-
-		limitChan: make(chan struct{}, config.MaxConcurrentScripts),
 	}
+	r.limiter = utils.NewDynamicSemaphore(func() int {
+		return r.maxConcurrentScripts(time.Now())
+	})
 	r.ComponentBase = NewComponentWithStart(
 		"script_runner",
 		r.start,
@@ -101,6 +231,10 @@ func (r *ScriptRunner) start(ctx context.Context) {
 					r.Finish(nil)
 					return
 				}
+				if err := r.pause.wait(ctx); err != nil {
+					r.Finish(err)
+					return
+				}
 				r.handleBatch(ctx, input)
 			}
 		}
@@ -115,18 +249,63 @@ func (r *ScriptRunner) handleBatch(ctx context.Context, input AddressBatch) {
 		input.DoneHandling()
 		return
 	}
+	if r.DryRun {
+		r.stats.batchSkipped(input)
+		input.DoneHandling()
+		return
+	}
+	if len(r.Scripts) > 0 && input.ScriptName == "" {
+		for _, batch := range r.batchPerScript(input) {
+			r.handleBatch(ctx, batch)
+		}
+		return
+	}
 
-	r.limitChan <- struct{}{}
+	r.limiter.Acquire()
 	go func() {
-		defer func() { <-r.limitChan }()
+		defer r.limiter.Release()
+
+		start := time.Now()
+		spanCtx, span := startSpan(
+			trace.ContextWithSpanContext(ctx, input.spanContext),
+			r.tracer,
+			"execute_script",
+			batchAttributes(input)...,
+		)
+		result, err := r.executeScriptWithTimeout(spanCtx, input)
+		endSpan(span, err)
+		r.throttle.observe(time.Since(start), err)
+		r.observeBatch(input, time.Since(start), result, err)
 
-		result, err := r.executeScript(ctx, input)
+		if err == nil {
+			if expected, ok := r.ExpectedResultTypes[input.ScriptName]; ok {
+				if typeErr := CheckResultType(result, expected); typeErr != nil {
+					// the script or a contract changed, running it again does not help
+					err = newBatchError(input, ErrUnexpectedResultType, typeErr)
+					r.Logger.
+						Error().
+						Err(err).
+						Msg("unexpected result type")
+					r.stats.batchFailed(input, err)
+					r.Finish(err)
+					return
+				}
+			}
+		}
 
 		if err == nil {
-			r.resultsChan <- ProcessedAddressBatch{
-				AddressBatch: input,
-				Result:       result,
+			processed := ProcessedAddressBatch{
+				AddressBatch:   input,
+				Result:         result,
+				ScriptDuration: time.Since(start),
+				BlockID:        r.blockID(ctx, input),
+				Retries:        input.retries,
 			}
+			// the result is handled in a child span of the script execution
+			processed.spanContext = span.SpanContext()
+			r.stats.batchExecuted(processed)
+			r.batchSizeController.succeeded(len(input.Addresses))
+			r.resultsChan <- processed
 			return
 		}
 
@@ -135,12 +314,48 @@ func (r *ScriptRunner) handleBatch(ctx context.Context, input AddressBatch) {
 			return
 		}
 
+		err = newBatchError(input, nil, err)
 		r.Logger.
 			Warn().
 			Err(err).
 			Msg("failed to run script")
+		r.stats.scriptError()
+		if r.reporter != nil {
+			r.reporter.ReportScriptError()
+		}
 
-		action := r.HandleScriptError(input, err)
+		if errors.Is(err, ErrScriptTimeout) && input.timeouts < r.ScriptTimeoutRetries {
+			input.timeouts++
+			input.retries++
+			r.Logger.
+				Info().
+				Int("timeouts", input.timeouts).
+				Msg("retrying after timeout")
+			r.stats.batchRetried()
+			go func() {
+				r.handleBatch(ctx, input)
+			}()
+			return
+		}
+
+		if r.skipAddressError(ctx, input, err) {
+			return
+		}
+		if r.applyScriptErrorPolicy(ctx, input, err) {
+			return
+		}
+
+		var action ScriptErrorAction
+		if r.batchSizeController.isAdaptive() && isLimitError(err) {
+			// smaller batches should fit in the limits
+			r.batchSizeController.limitExceeded(len(input.Addresses))
+			action = ScriptErrorActionSplit{}
+		} else {
+			action = r.HandleScriptError(input, err)
+		}
+		if _, ok := action.(ScriptErrorActionUnhandled); ok && r.BisectFailedBatches {
+			action = ScriptErrorActionSplit{}
+		}
 
 		switch action := action.(type) {
 		case ScriptErrorActionRetry:
@@ -148,6 +363,8 @@ func (r *ScriptRunner) handleBatch(ctx context.Context, input AddressBatch) {
 			r.Logger.
 				Info().
 				Msg("retrying")
+			input.retries++
+			r.stats.batchRetried()
 			go func() {
 				r.handleBatch(ctx, input)
 			}()
@@ -163,6 +380,9 @@ func (r *ScriptRunner) handleBatch(ctx context.Context, input AddressBatch) {
 					Int("addresses", len(input.Addresses)).
 					Msg("retrying by splitting")
 				left, right := input.Split()
+				left.retries++
+				right.retries++
+				r.stats.batchRetried()
 				go func() {
 					r.handleBatch(ctx, left)
 					r.handleBatch(ctx, right)
@@ -170,6 +390,11 @@ func (r *ScriptRunner) handleBatch(ctx context.Context, input AddressBatch) {
 				return
 			}
 			r.Logger.Info().Msg("cannot split, only one address left")
+			if r.BisectFailedBatches &&
+				r.handleAddressError(input.Addresses[0], err) == AddressErrorSkip &&
+				r.skipFailedAddress(input, err) {
+				return
+			}
 			// error out
 		case ScriptErrorActionExclude:
 			// exclude the problematic addresses and retry
@@ -184,9 +409,19 @@ func (r *ScriptRunner) handleBatch(ctx context.Context, input AddressBatch) {
 					return r
 				}()).
 				Msg("retrying by excluding")
+			r.stats.batchRetried()
+			excluded := NewAddressBatch(addresses, input.BlockHeight, nil, nil)
+			excluded.ScriptName = input.ScriptName
+			excluded.spanContext = input.spanContext
+			if handlerErr := r.failBatch(excluded, err); handlerErr != nil {
+				input.DoneHandling()
+				r.Finish(handlerErr)
+				return
+			}
 			for _, address := range addresses {
 				input.ExcludeAddress(address)
 			}
+			input.retries++
 			go func() {
 				r.handleBatch(ctx, input)
 			}()
@@ -204,10 +439,105 @@ func (r *ScriptRunner) handleBatch(ctx context.Context, input AddressBatch) {
 
 		r.Logger.Warn().
 			Msg("unable to handle error running script")
+		if r.FailedBatchHandler != nil && !r.tooManyFailedAddresses() {
+			if handlerErr := r.failBatch(input, err); handlerErr != nil {
+				r.Finish(handlerErr)
+			}
+			return
+		}
+		r.stats.batchFailed(input, err)
 		r.Finish(err)
 	}()
 }
 
+// skipFailedAddress marks the batch of a single failing address as done, so the scan can continue without it.
+// It returns false if too many addresses failed already.
+func (r *ScriptRunner) skipFailedAddress(input AddressBatch, err error) bool {
+	failed := r.failedAddresses.Add(1)
+	if r.MaxFailedAddresses > 0 && int(failed) > r.MaxFailedAddresses {
+		r.Logger.Warn().
+			Int("max_failed_addresses", r.MaxFailedAddresses).
+			Msg("too many addresses failed")
+		r.tooManyFailed.Store(true)
+		return false
+	}
+
+	r.Logger.Warn().
+		Err(err).
+		Str("address", input.Addresses[0].String()).
+		Uint64("block_height", input.BlockHeight).
+		Msg("skipping failing address")
+	if handlerErr := r.failBatch(input, err); handlerErr != nil {
+		r.Finish(handlerErr)
+	}
+	return true
+}
+
+// tooManyFailedAddresses is true if more than MaxFailedAddresses addresses were skipped.
+func (r *ScriptRunner) tooManyFailedAddresses() bool {
+	return r.tooManyFailed.Load()
+}
+
+// failBatch records the failed batch, hands it to the FailedBatchHandler and marks it as done.
+// The batch is marked as done even if the handler fails.
+func (r *ScriptRunner) failBatch(batch AddressBatch, err error) error {
+	defer batch.DoneHandling()
+
+	r.stats.batchFailed(batch, err)
+	if r.FailedBatchHandler == nil {
+		return nil
+	}
+	handlerErr := r.FailedBatchHandler.HandleFailedBatch(FailedBatch{
+		Addresses:   batch.Addresses,
+		BlockHeight: batch.BlockHeight,
+		ScriptName:  batch.ScriptName,
+		Err:         err,
+	})
+	if handlerErr != nil {
+		return fmt.Errorf("failed to handle failed batch: %w", handlerErr)
+	}
+	return nil
+}
+
+// blockID returns the ID of the block of the batch, or an empty ID if its header cannot be fetched.
+// Batches of full scans carry the ID, the headers of the other batches are cached by the client,
+// see client.WithBlockHeaderCache.
+func (r *ScriptRunner) blockID(ctx context.Context, input AddressBatch) flow.Identifier {
+	if input.blockID != flow.EmptyID {
+		return input.blockID
+	}
+
+	header, err := r.client.GetBlockHeaderByHeight(ctx, input.BlockHeight)
+	if err != nil {
+		r.Logger.
+			Debug().
+			Err(err).
+			Uint64("block_height", input.BlockHeight).
+			Msg("failed to get the block ID of the batch")
+		return flow.EmptyID
+	}
+	return header.ID
+}
+
+// BusyWorkers is the number of batches whose scripts are currently running.
+func (r *ScriptRunner) BusyWorkers() int {
+	return r.limiter.InUse()
+}
+
+// WorkersLimit is the maximum number of batches whose scripts can run concurrently at the moment.
+func (r *ScriptRunner) WorkersLimit() int {
+	return r.limiter.Limit()
+}
+
+func (r *ScriptRunner) maxConcurrentScripts(now time.Time) int {
+	for _, window := range r.ConcurrencySchedule {
+		if window.Contains(now) {
+			return window.MaxConcurrentScripts
+		}
+	}
+	return r.MaxConcurrentScripts
+}
+
 var accountFrozenRegex = regexp.MustCompile(`\[Error Code: 1204] account (?P<address>\w{16}) is frozen`)
 
 // executeScript retries running the cadence script until we get a successful response back,
@@ -217,21 +547,169 @@ func (r *ScriptRunner) executeScript(
 	ctx context.Context,
 	input AddressBatch,
 ) (result cadence.Value, err error) {
-	arguments := convertAddressesToArguments(input.Addresses)
+	if r.AccountBalanceMode {
+		return r.getAccountBalances(ctx, input)
+	}
+	if r.AccountKeysMode {
+		return r.getAccountKeys(ctx, input)
+	}
+
 	r.Logger.
 		Debug().
 		Uint64("block_height", input.BlockHeight).
 		Int("num_addresses", len(input.Addresses)).
 		Msgf("executing script")
 
+	script := r.scriptAt(input.ScriptName, input.BlockHeight)
+
+	extraArguments := r.ScriptArguments
+	if r.ScriptArgumentsForBatch != nil {
+		extraArguments = r.ScriptArgumentsForBatch(input)
+	}
+
+	if r.PerAddress {
+		return r.executeScriptPerAddress(ctx, input, script, extraArguments)
+	}
+
+	arguments := append(convertAddressesToArguments(input.Addresses), extraArguments...)
 	return r.client.ExecuteScriptAtBlockHeight(
 		ctx,
 		input.BlockHeight,
-		r.Script,
+		script,
 		arguments,
 	)
 }
 
+// executeScriptPerAddress runs the script for each address of the batch
+// and returns the results as an array in the same order as the addresses.
+// If any of the scripts fail, the first error is returned.
+func (r *ScriptRunner) executeScriptPerAddress(
+	ctx context.Context,
+	input AddressBatch,
+	script []byte,
+	extraArguments []cadence.Value,
+) (cadence.Value, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := r.PerAddressConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	results := make([]cadence.Value, len(input.Addresses))
+	var firstErr error
+	errOnce := sync.Once{}
+	wg := sync.WaitGroup{}
+	for i, address := range input.Addresses {
+		i, address := i, address
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			// one of the scripts failed
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			result, err := r.client.ExecuteScriptAtBlockHeight(
+				ctx,
+				input.BlockHeight,
+				script,
+				append([]cadence.Value{cadence.Address(address)}, extraArguments...),
+			)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = &AddressError{Address: address, Err: fmt.Errorf("script failed: %w", err)}
+					cancel()
+				})
+				return
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return cadence.NewArray(results), nil
+}
+
+// executeScriptWithTimeout runs the script with the ScriptTimeout.
+// If the script times out ErrScriptTimeout is returned.
+func (r *ScriptRunner) executeScriptWithTimeout(
+	ctx context.Context,
+	input AddressBatch,
+) (cadence.Value, error) {
+	if r.ScriptTimeout <= 0 {
+		return r.executeScript(ctx, input)
+	}
+
+	scriptCtx, cancel := context.WithTimeout(ctx, r.ScriptTimeout)
+	defer cancel()
+
+	result, err := r.executeScript(scriptCtx, input)
+	if err != nil && ctx.Err() == nil && scriptCtx.Err() != nil {
+		// the error is not wrapped, so it is not mistaken for the scan being cancelled
+		return nil, fmt.Errorf("%w after %s: %s", ErrScriptTimeout, r.ScriptTimeout, err.Error())
+	}
+	return result, err
+}
+
+// batchPerScript creates a batch for each of the Scripts,
+// or multiple batches for the scripts with a smaller batch size in ScriptBatchSizes.
+// The input batch is done once all the script batches are done.
+func (r *ScriptRunner) batchPerScript(input AddressBatch) []AddressBatch {
+	names := make([]string, 0, len(r.Scripts))
+	for name := range r.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	remaining := &atomic.Int32{}
+	done := func() {
+		if remaining.Add(-1) == 0 {
+			input.DoneHandling()
+		}
+	}
+
+	batches := make([]AddressBatch, 0, len(names))
+	for _, name := range names {
+		size := r.ScriptBatchSizes[name]
+		if size <= 0 {
+			size = len(input.Addresses)
+		}
+		for start := 0; start < len(input.Addresses); start += size {
+			end := start + size
+			if end > len(input.Addresses) {
+				end = len(input.Addresses)
+			}
+			batch := NewAddressBatch(
+				input.Addresses[start:end],
+				input.BlockHeight,
+				done,
+				input.isValid,
+			)
+			batch.Provenance = input.Provenance
+			batch.ScriptName = name
+			batch.spanContext = input.spanContext
+			batch.fromFullScan = input.fromFullScan
+			batch.blockID = input.blockID
+			batches = append(batches, batch)
+		}
+	}
+	remaining.Store(int32(len(batches)))
+	return batches
+}
+
 // convertAddressesToArguments generates an array of cadence.Value from an array of flow.Address
 func convertAddressesToArguments(addresses []flow.Address) []cadence.Value {
 	var accounts []cadence.Value
@@ -287,7 +765,7 @@ func DefaultHandleScriptError(_ AddressBatch, err error) ScriptErrorAction {
 	}
 
 	// If the account is frozen, we can skip it
-	if strings.Contains(err.Error(), "[Error Code: 1204]") {
+	if ClassifyError(err) == ErrAccountFrozen {
 		addressIndex := accountFrozenRegex.SubexpIndex("address")
 		match := accountFrozenRegex.FindStringSubmatch(err.Error())
 		if match != nil {
@@ -0,0 +1,412 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+)
+
+func TestScriptRunner_BatchMetadata(t *testing.T) {
+	c := clienttest.New()
+	c.AddBlock(10)
+	var calls atomic.Int32
+	c.HandleScripts(func(uint64, []byte, []cadence.Value) (cadence.Value, error) {
+		if calls.Add(1) == 1 {
+			return nil, fmt.Errorf("transient")
+		}
+		return cadence.NewArray(nil), nil
+	})
+
+	config := DefaultScriptRunnerConfig()
+	config.HandleScriptError = func(AddressBatch, error) ScriptErrorAction {
+		return ScriptErrorActionRetry{}
+	}
+	batches := make(chan AddressBatch, 2)
+	results := make(chan ProcessedAddressBatch, 2)
+	runner := NewScriptRunner(c, batches, results, config, zerolog.Nop())
+	<-runner.Start(context.Background())
+
+	for _, retries := range []int{1, 0} {
+		batches <- NewAddressBatch([]flow.Address{flow.HexToAddress("01")}, 10, func() {}, nil)
+		select {
+		case result := <-results:
+			require.Equal(t, clienttest.BlockID(10), result.BlockID)
+			require.Equal(t, retries, result.Retries)
+			require.Positive(t, result.ScriptDuration)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "batch was not handled")
+		}
+	}
+	// the batches do not carry the block ID, the runner fetches it
+	require.Equal(t, 2, c.Calls(client.MethodGetBlockHeaderByHeight))
+
+	batch := NewAddressBatch([]flow.Address{flow.HexToAddress("01")}, 10, func() {}, nil)
+	batch.blockID = flow.HexToID("01")
+	batches <- batch
+	select {
+	case result := <-results:
+		require.Equal(t, flow.HexToID("01"), result.BlockID)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "batch was not handled")
+	}
+	require.Equal(t, 2, c.Calls(client.MethodGetBlockHeaderByHeight))
+}
+
+func TestScriptRunner_BisectFailedBatches(t *testing.T) {
+	addresses := []flow.Address{
+		flow.HexToAddress("01"),
+		flow.HexToAddress("02"),
+		flow.HexToAddress("03"),
+		flow.HexToAddress("04"),
+	}
+
+	// run scans the addresses with the failing addresses, and returns the handled and the failed batches
+	run := func(
+		t *testing.T,
+		config ScriptRunnerConfig,
+		failing ...flow.Address,
+	) (*ScriptRunner, <-chan ProcessedAddressBatch, <-chan FailedBatch) {
+		c := clienttest.New()
+		c.AddBlock(10)
+		c.HandleScripts(func(_ uint64, _ []byte, arguments []cadence.Value) (cadence.Value, error) {
+			for _, address := range arguments[0].(cadence.Array).Values {
+				for _, f := range failing {
+					if flow.Address(address.(cadence.Address)) == f {
+						return nil, fmt.Errorf("failing account %s", f)
+					}
+				}
+			}
+			return cadence.NewBool(true), nil
+		})
+
+		failed := make(chan FailedBatch, len(addresses))
+		config.FailedBatchHandler = failedBatchHandlerFunc(func(batch FailedBatch) error {
+			failed <- batch
+			return nil
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		batches := make(chan AddressBatch, 1)
+		results := make(chan ProcessedAddressBatch, len(addresses))
+		runner := NewScriptRunner(c, batches, results, config, zerolog.Nop())
+		<-runner.Start(ctx)
+
+		batches <- NewAddressBatch(addresses, 10, func() {}, nil)
+		return runner, results, failed
+	}
+
+	t.Run("off by default", func(t *testing.T) {
+		config := DefaultScriptRunnerConfig()
+		require.False(t, config.BisectFailedBatches)
+		_, _, failed := run(t, config, addresses[2])
+
+		select {
+		case batch := <-failed:
+			require.Equal(t, addresses, batch.Addresses)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "batch did not fail")
+		}
+	})
+
+	t.Run("skips the failing address", func(t *testing.T) {
+		config := DefaultScriptRunnerConfig()
+		config.BisectFailedBatches = true
+		runner, results, failed := run(t, config, addresses[2])
+
+		select {
+		case batch := <-failed:
+			require.Equal(t, []flow.Address{addresses[2]}, batch.Addresses)
+			require.ErrorContains(t, batch.Err, "failing account")
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "address was not skipped")
+		}
+		var handled []flow.Address
+		for len(handled) < 3 {
+			select {
+			case result := <-results:
+				handled = append(handled, result.Addresses...)
+			case <-time.After(5 * time.Second):
+				require.Fail(t, "batch was not handled")
+			}
+		}
+		require.ElementsMatch(t, []flow.Address{addresses[0], addresses[1], addresses[3]}, handled)
+		require.Equal(t, int32(1), runner.failedAddresses.Load())
+		require.False(t, runner.tooManyFailedAddresses())
+	})
+
+	t.Run("fails after MaxFailedAddresses", func(t *testing.T) {
+		config := DefaultScriptRunnerConfig()
+		config.BisectFailedBatches = true
+		config.MaxFailedAddresses = 1
+		runner, _, failed := run(t, config, addresses[0], addresses[3])
+
+		select {
+		case <-runner.Done():
+			require.ErrorContains(t, runner.Err(), "failing account")
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "scan did not fail")
+		}
+		require.True(t, runner.tooManyFailedAddresses())
+		require.Len(t, failed, 1, "only the first failing address was skipped")
+	})
+}
+
+func TestScriptRunner_PerAddress(t *testing.T) {
+	addresses := []flow.Address{
+		flow.HexToAddress("01"),
+		flow.HexToAddress("02"),
+		flow.HexToAddress("03"),
+		flow.HexToAddress("04"),
+		flow.HexToAddress("05"),
+	}
+
+	c := clienttest.New()
+	c.AddBlock(10)
+	var running, maxRunning atomic.Int32
+	c.HandleScripts(func(_ uint64, _ []byte, arguments []cadence.Value) (cadence.Value, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		// the scripts of the later addresses finish first
+		address := flow.Address(arguments[0].(cadence.Address))
+		time.Sleep(time.Duration(10-address[7]) * time.Millisecond)
+		return arguments[0], nil
+	})
+
+	config := DefaultScriptRunnerConfig()
+	config.PerAddress = true
+	config.PerAddressConcurrency = 2
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	batches := make(chan AddressBatch, 1)
+	results := make(chan ProcessedAddressBatch, 1)
+	runner := NewScriptRunner(c, batches, results, config, zerolog.Nop())
+	<-runner.Start(ctx)
+
+	batches <- NewAddressBatch(addresses, 10, func() {}, nil)
+	select {
+	case result := <-results:
+		// the results are in the order of the addresses
+		values := result.Result.(cadence.Array).Values
+		require.Len(t, values, len(addresses))
+		for i, address := range addresses {
+			require.Equal(t, cadence.Address(address), values[i])
+		}
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "batch was not handled")
+	}
+	require.Equal(t, len(addresses), c.Calls(client.MethodExecuteScriptAtBlockHeight))
+	require.LessOrEqual(t, maxRunning.Load(), int32(2))
+}
+
+func TestScriptRunner_ScriptArguments(t *testing.T) {
+	addresses := []flow.Address{
+		flow.HexToAddress("01"),
+		flow.HexToAddress("02"),
+	}
+
+	run := func(t *testing.T, config ScriptRunnerConfig) [][]cadence.Value {
+		c := clienttest.New()
+		c.AddBlock(10)
+		calls := make(chan []cadence.Value, len(addresses))
+		c.HandleScripts(func(_ uint64, _ []byte, arguments []cadence.Value) (cadence.Value, error) {
+			calls <- arguments
+			return cadence.NewInt(1), nil
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		batches := make(chan AddressBatch, 1)
+		results := make(chan ProcessedAddressBatch, 1)
+		runner := NewScriptRunner(c, batches, results, config, zerolog.Nop())
+		<-runner.Start(ctx)
+
+		batches <- NewAddressBatch(addresses, 10, func() {}, nil)
+		select {
+		case <-results:
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "batch was not handled")
+		}
+		close(calls)
+		var arguments [][]cadence.Value
+		for a := range calls {
+			arguments = append(arguments, a)
+		}
+		return arguments
+	}
+
+	extra := []cadence.Value{cadence.String("a"), cadence.NewUInt64(2)}
+	batchArguments := cadence.NewArray([]cadence.Value{
+		cadence.Address(addresses[0]),
+		cadence.Address(addresses[1]),
+	})
+
+	t.Run("appended after the addresses", func(t *testing.T) {
+		config := DefaultScriptRunnerConfig()
+		config.ScriptArguments = extra
+		arguments := run(t, config)
+		require.Equal(t, [][]cadence.Value{append([]cadence.Value{batchArguments}, extra...)}, arguments)
+	})
+
+	t.Run("appended after the address in per address mode", func(t *testing.T) {
+		config := DefaultScriptRunnerConfig()
+		config.ScriptArguments = extra
+		config.PerAddress = true
+		config.PerAddressConcurrency = 1
+		arguments := run(t, config)
+		require.Equal(t, [][]cadence.Value{
+			append([]cadence.Value{cadence.Address(addresses[0])}, extra...),
+			append([]cadence.Value{cadence.Address(addresses[1])}, extra...),
+		}, arguments)
+	})
+
+	t.Run("per batch arguments replace the static arguments", func(t *testing.T) {
+		config := DefaultScriptRunnerConfig()
+		config.ScriptArguments = extra
+		config.ScriptArgumentsForBatch = func(batch AddressBatch) []cadence.Value {
+			return []cadence.Value{cadence.NewUInt64(batch.BlockHeight)}
+		}
+		arguments := run(t, config)
+		require.Equal(t, [][]cadence.Value{{batchArguments, cadence.NewUInt64(10)}}, arguments)
+	})
+}
+
+func TestScriptRunner_ScriptTimeout(t *testing.T) {
+	addresses := []flow.Address{flow.HexToAddress("01")}
+
+	// run scans the addresses with scripts that time out the first timeouts times
+	run := func(t *testing.T, timeouts int32) (*clienttest.Client, <-chan ProcessedAddressBatch, <-chan FailedBatch) {
+		c := clienttest.New()
+		c.AddBlock(10)
+		var calls atomic.Int32
+		c.HandleScripts(func(uint64, []byte, []cadence.Value) (cadence.Value, error) {
+			if calls.Add(1) <= timeouts {
+				time.Sleep(50 * time.Millisecond)
+				return nil, context.DeadlineExceeded
+			}
+			return cadence.NewBool(true), nil
+		})
+
+		failed := make(chan FailedBatch, 1)
+		config := DefaultScriptRunnerConfig()
+		config.ScriptTimeout = 10 * time.Millisecond
+		config.ScriptTimeoutRetries = 2
+		config.FailedBatchHandler = failedBatchHandlerFunc(func(batch FailedBatch) error {
+			failed <- batch
+			return nil
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		batches := make(chan AddressBatch, 1)
+		results := make(chan ProcessedAddressBatch, 1)
+		runner := NewScriptRunner(c, batches, results, config, zerolog.Nop())
+		<-runner.Start(ctx)
+
+		batches <- NewAddressBatch(addresses, 10, func() {}, nil)
+		return c, results, failed
+	}
+
+	t.Run("retried after a timeout", func(t *testing.T) {
+		c, results, _ := run(t, 2)
+
+		select {
+		case result := <-results:
+			require.Equal(t, addresses, result.Addresses)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "batch was not handled")
+		}
+		require.Equal(t, 3, c.Calls(client.MethodExecuteScriptAtBlockHeight))
+	})
+
+	t.Run("fails after the retries", func(t *testing.T) {
+		c, _, failed := run(t, 3)
+
+		select {
+		case batch := <-failed:
+			require.ErrorIs(t, batch.Err, ErrScriptTimeout)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "batch did not fail")
+		}
+		require.Equal(t, 3, c.Calls(client.MethodExecuteScriptAtBlockHeight))
+	})
+}
+
+func TestScriptRunner_Workers(t *testing.T) {
+	c := clienttest.New()
+	c.AddBlock(10)
+	release := make(chan struct{})
+	c.HandleScripts(func(uint64, []byte, []cadence.Value) (cadence.Value, error) {
+		<-release
+		return cadence.NewBool(true), nil
+	})
+
+	config := DefaultScriptRunnerConfig()
+	config.MaxConcurrentScripts = 2
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	batches := make(chan AddressBatch, 3)
+	results := make(chan ProcessedAddressBatch, 3)
+	runner := NewScriptRunner(c, batches, results, config, zerolog.Nop())
+	<-runner.Start(ctx)
+	require.Equal(t, 0, runner.BusyWorkers())
+	require.Equal(t, 2, runner.WorkersLimit())
+
+	for i := 1; i <= 3; i++ {
+		batches <- NewAddressBatch([]flow.Address{flow.HexToAddress(fmt.Sprintf("0%d", i))}, 10, func() {}, nil)
+	}
+	// only the limit of the scripts run at once
+	require.Eventually(t, func() bool {
+		return runner.BusyWorkers() == 2
+	}, time.Second, time.Millisecond)
+	require.Never(t, func() bool {
+		return runner.BusyWorkers() > 2
+	}, 50*time.Millisecond, time.Millisecond)
+
+	close(release)
+	require.Eventually(t, func() bool {
+		return len(results) == 3 && runner.BusyWorkers() == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestDefaultHandleScriptError(t *testing.T) {
+	batch := NewAddressBatch([]flow.Address{flow.HexToAddress("01")}, 10, nil, nil)
+
+	require.Equal(t, ScriptErrorActionNone{},
+		DefaultHandleScriptError(batch, fmt.Errorf("failed to execute script: state commitment not found")))
+	require.Equal(t, ScriptErrorActionExclude{Addresses: []flow.Address{flow.HexToAddress("01")}},
+		DefaultHandleScriptError(batch, fmt.Errorf("[Error Code: 1204] account 0000000000000001 is frozen")))
+	// only the missing execution state is ignored, not everything that is not found
+	require.Equal(t, ScriptErrorActionUnhandled{},
+		DefaultHandleScriptError(batch, status.Error(codes.NotFound, "could not find contract A")))
+}
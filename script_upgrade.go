@@ -0,0 +1,72 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/onflow/flow-go-sdk"
+)
+
+// ScriptUpgrade replaces a script for the batches at or above a block height.
+// Historical scans that span a breaking change of Cadence, like the Cadence 1.0 upgrade (Crescendo),
+// need a version of the script for each side of the upgrade:
+// the batches of a reference block are run with the version of the script at that block.
+type ScriptUpgrade struct {
+	// Height is the first block height the script is run at.
+	Height uint64
+	// ScriptName is the name of the upgraded script of Scripts, or empty to upgrade Script.
+	ScriptName string
+	Script     []byte
+}
+
+// sortScriptUpgrades orders the upgrades by height.
+func sortScriptUpgrades(upgrades []ScriptUpgrade) {
+	sort.SliceStable(upgrades, func(i, j int) bool {
+		return upgrades[i].Height < upgrades[j].Height
+	})
+}
+
+// scriptAt returns the version of the script that is run at the block height.
+func (c ScriptRunnerConfig) scriptAt(scriptName string, height uint64) []byte {
+	script := c.Script
+	if scriptName != "" {
+		script = c.Scripts[scriptName]
+	}
+	for _, upgrade := range c.ScriptUpgrades {
+		if upgrade.Height > height {
+			break
+		}
+		if upgrade.ScriptName == scriptName {
+			script = upgrade.Script
+		}
+	}
+	return script
+}
+
+// resolveUpgradeImports resolves the imports of the ScriptUpgrades.
+func (c ScriptRunnerConfig) resolveUpgradeImports(chainID flow.ChainID) ([]ScriptUpgrade, error) {
+	upgrades := make([]ScriptUpgrade, len(c.ScriptUpgrades))
+	for i, upgrade := range c.ScriptUpgrades {
+		script, err := ResolveImports(upgrade.Script, chainID, c.ContractAliases)
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve imports of the script upgrade at height %d: %w", upgrade.Height, err)
+		}
+		upgrade.Script = script
+		upgrades[i] = upgrade
+	}
+	return upgrades, nil
+}
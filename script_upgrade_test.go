@@ -0,0 +1,52 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"testing"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScriptUpgrades(t *testing.T) {
+	config := DefaultConfig().
+		WithScript([]byte("pre")).
+		WithScripts(map[string][]byte{"keys": []byte("keys pre")}).
+		WithScriptUpgrades(
+			ScriptUpgrade{Height: 200, ScriptName: "keys", Script: []byte("keys post")},
+			ScriptUpgrade{Height: 300, Script: []byte("post 2")},
+		).
+		WithScriptUpgrade(100, []byte("post"))
+	require.NoError(t, config.Validate())
+
+	runner := config.ScriptRunnerConfig
+	require.Equal(t, "pre", string(runner.scriptAt("", 99)))
+	require.Equal(t, "post", string(runner.scriptAt("", 100)))
+	require.Equal(t, "post", string(runner.scriptAt("", 299)))
+	require.Equal(t, "post 2", string(runner.scriptAt("", 300)))
+	require.Equal(t, "keys pre", string(runner.scriptAt("keys", 199)))
+	require.Equal(t, "keys post", string(runner.scriptAt("keys", 200)))
+
+	aliases := ContractAliases{"FungibleToken": {flow.Mainnet: flow.HexToAddress("f233dcee88fe0abe")}}
+	runner.ContractAliases = aliases
+	runner.ScriptUpgrades = []ScriptUpgrade{{Height: 100, Script: []byte(`import "FungibleToken"`)}}
+	resolved, err := runner.resolveImports(flow.Mainnet)
+	require.NoError(t, err)
+	require.Equal(t, "import FungibleToken from 0xf233dcee88fe0abe", string(resolved.scriptAt("", 100)))
+
+	invalid := DefaultConfig().WithScriptUpgrades(ScriptUpgrade{Height: 100, ScriptName: "unknown", Script: []byte("post")})
+	require.Error(t, invalid.Validate())
+}
@@ -0,0 +1,249 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scripts contains scripts for common scans, and decoders for their results.
+// All scripts take an `[Address]` argument, and return a dictionary with a result for each address.
+package scripts
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+)
+
+// FlowBalances returns the FLOW balance of each address. Decode the result with DecodeFlowBalances.
+//
+//go:embed flow_balances.cdc
+var FlowBalances []byte
+
+// ContractNames returns the names of the contracts deployed to each address.
+// Decode the result with DecodeContractNames.
+//
+//go:embed contract_names.cdc
+var ContractNames []byte
+
+// ContractCodes returns the code of the contracts deployed to each address, by contract name.
+// Code that is not valid UTF-8 is returned hex encoded. Decode the result with DecodeContractCodes.
+//
+//go:embed contract_codes.cdc
+var ContractCodes []byte
+
+// AccountKeys returns the keys of each address. Decode the result with DecodeAccountKeys.
+//
+//go:embed account_keys.cdc
+var AccountKeys []byte
+
+// Storage returns the storage used and storage capacity of each address. Decode the result with DecodeStorage.
+//
+//go:embed storage.cdc
+var Storage []byte
+
+// PublicPaths returns the public paths of each address. Decode the result with DecodePublicPaths.
+//
+//go:embed public_paths.cdc
+var PublicPaths []byte
+
+// AccountKey is a key of an account, as returned by the AccountKeys script.
+type AccountKey struct {
+	KeyIndex int
+	// PublicKey is hex encoded.
+	PublicKey          string
+	SignatureAlgorithm uint8
+	HashAlgorithm      uint8
+	Weight             cadence.UFix64
+	IsRevoked          bool
+	// SequenceNumber is only set by scanner.Config.WithAccountKeysMode, the AccountKeys script can not read it.
+	SequenceNumber uint64
+}
+
+// StorageInfo is the storage of an account, as returned by the Storage script.
+type StorageInfo struct {
+	Used     uint64
+	Capacity uint64
+}
+
+func DecodeFlowBalances(value cadence.Value) (map[flow.Address]cadence.UFix64, error) {
+	return decodeDictionary(value, func(value cadence.Value) (cadence.UFix64, error) {
+		balance, ok := value.(cadence.UFix64)
+		if !ok {
+			return 0, fmt.Errorf("expected UFix64, got %T", value)
+		}
+		return balance, nil
+	})
+}
+
+func DecodeContractNames(value cadence.Value) (map[flow.Address][]string, error) {
+	return decodeDictionary(value, decodeStrings)
+}
+
+func DecodeContractCodes(value cadence.Value) (map[flow.Address]map[string]string, error) {
+	return decodeDictionary(value, func(value cadence.Value) (map[string]string, error) {
+		dictionary, ok := value.(cadence.Dictionary)
+		if !ok {
+			return nil, fmt.Errorf("expected dictionary, got %T", value)
+		}
+		codes := make(map[string]string, len(dictionary.Pairs))
+		for _, pair := range dictionary.Pairs {
+			name, ok := pair.Key.(cadence.String)
+			if !ok {
+				return nil, fmt.Errorf("expected String key, got %T", pair.Key)
+			}
+			code, ok := pair.Value.(cadence.String)
+			if !ok {
+				return nil, fmt.Errorf("expected String, got %T", pair.Value)
+			}
+			codes[string(name)] = string(code)
+		}
+		return codes, nil
+	})
+}
+
+func DecodePublicPaths(value cadence.Value) (map[flow.Address][]string, error) {
+	return decodeDictionary(value, decodeStrings)
+}
+
+func DecodeAccountKeys(value cadence.Value) (map[flow.Address][]AccountKey, error) {
+	return decodeDictionary(value, func(value cadence.Value) ([]AccountKey, error) {
+		array, ok := value.(cadence.Array)
+		if !ok {
+			return nil, fmt.Errorf("expected array, got %T", value)
+		}
+		keys := make([]AccountKey, 0, len(array.Values))
+		for _, value := range array.Values {
+			fields, err := structFields(value)
+			if err != nil {
+				return nil, err
+			}
+			keyIndex, ok := fields["keyIndex"].(cadence.Int)
+			if !ok {
+				return nil, fmt.Errorf("expected keyIndex to be Int")
+			}
+			publicKey, ok := fields["publicKey"].(cadence.String)
+			if !ok {
+				return nil, fmt.Errorf("expected publicKey to be String")
+			}
+			signatureAlgorithm, ok := fields["signatureAlgorithm"].(cadence.UInt8)
+			if !ok {
+				return nil, fmt.Errorf("expected signatureAlgorithm to be UInt8")
+			}
+			hashAlgorithm, ok := fields["hashAlgorithm"].(cadence.UInt8)
+			if !ok {
+				return nil, fmt.Errorf("expected hashAlgorithm to be UInt8")
+			}
+			weight, ok := fields["weight"].(cadence.UFix64)
+			if !ok {
+				return nil, fmt.Errorf("expected weight to be UFix64")
+			}
+			isRevoked, ok := fields["isRevoked"].(cadence.Bool)
+			if !ok {
+				return nil, fmt.Errorf("expected isRevoked to be Bool")
+			}
+			var sequenceNumber cadence.UInt64
+			if value, ok := fields["sequenceNumber"]; ok {
+				sequenceNumber, ok = value.(cadence.UInt64)
+				if !ok {
+					return nil, fmt.Errorf("expected sequenceNumber to be UInt64")
+				}
+			}
+			keys = append(keys, AccountKey{
+				KeyIndex:           keyIndex.Int(),
+				PublicKey:          string(publicKey),
+				SignatureAlgorithm: uint8(signatureAlgorithm),
+				HashAlgorithm:      uint8(hashAlgorithm),
+				Weight:             weight,
+				IsRevoked:          bool(isRevoked),
+				SequenceNumber:     uint64(sequenceNumber),
+			})
+		}
+		return keys, nil
+	})
+}
+
+func DecodeStorage(value cadence.Value) (map[flow.Address]StorageInfo, error) {
+	return decodeDictionary(value, func(value cadence.Value) (StorageInfo, error) {
+		fields, err := structFields(value)
+		if err != nil {
+			return StorageInfo{}, err
+		}
+		used, ok := fields["used"].(cadence.UInt64)
+		if !ok {
+			return StorageInfo{}, fmt.Errorf("expected used to be UInt64")
+		}
+		capacity, ok := fields["capacity"].(cadence.UInt64)
+		if !ok {
+			return StorageInfo{}, fmt.Errorf("expected capacity to be UInt64")
+		}
+		return StorageInfo{
+			Used:     uint64(used),
+			Capacity: uint64(capacity),
+		}, nil
+	})
+}
+
+// decodeDictionary decodes a `{Address: T}` dictionary.
+func decodeDictionary[T any](
+	value cadence.Value,
+	decodeValue func(value cadence.Value) (T, error),
+) (map[flow.Address]T, error) {
+	dictionary, ok := value.(cadence.Dictionary)
+	if !ok {
+		return nil, fmt.Errorf("expected dictionary, got %T", value)
+	}
+	result := make(map[flow.Address]T, len(dictionary.Pairs))
+	for _, pair := range dictionary.Pairs {
+		address, ok := pair.Key.(cadence.Address)
+		if !ok {
+			return nil, fmt.Errorf("expected Address key, got %T", pair.Key)
+		}
+		decoded, err := decodeValue(pair.Value)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode value for %s: %w", address, err)
+		}
+		result[flow.Address(address)] = decoded
+	}
+	return result, nil
+}
+
+func decodeStrings(value cadence.Value) ([]string, error) {
+	array, ok := value.(cadence.Array)
+	if !ok {
+		return nil, fmt.Errorf("expected array, got %T", value)
+	}
+	strings := make([]string, 0, len(array.Values))
+	for _, value := range array.Values {
+		s, ok := value.(cadence.String)
+		if !ok {
+			return nil, fmt.Errorf("expected String, got %T", value)
+		}
+		strings = append(strings, string(s))
+	}
+	return strings, nil
+}
+
+func structFields(value cadence.Value) (map[string]cadence.Value, error) {
+	s, ok := value.(cadence.Struct)
+	if !ok {
+		return nil, fmt.Errorf("expected struct, got %T", value)
+	}
+	if s.StructType == nil || len(s.StructType.Fields) != len(s.Fields) {
+		return nil, fmt.Errorf("struct fields do not match its type")
+	}
+	fields := make(map[string]cadence.Value, len(s.Fields))
+	for i, field := range s.StructType.Fields {
+		fields[field.Identifier] = s.Fields[i]
+	}
+	return fields, nil
+}
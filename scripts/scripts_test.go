@@ -0,0 +1,219 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scripts_test
+
+import (
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/cadence/runtime/common"
+	"github.com/onflow/cadence/runtime/parser"
+	"github.com/onflow/cadence/runtime/sema"
+	"github.com/onflow/cadence/runtime/stdlib"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/scripts"
+)
+
+func TestScriptsCheck(t *testing.T) {
+	baseValueActivation := sema.NewVariableActivation(sema.BaseValueActivation)
+	for _, value := range stdlib.DefaultScriptStandardLibraryValues(nil) {
+		baseValueActivation.DeclareValue(value)
+	}
+
+	publicStoragePaths, err := scripts.StoragePathsScript(10, scripts.PathDomainPublic)
+	require.NoError(t, err)
+
+	for name, script := range map[string][]byte{
+		"FlowBalances":       scripts.FlowBalances,
+		"ContractNames":      scripts.ContractNames,
+		"ContractCodes":      scripts.ContractCodes,
+		"AccountKeys":        scripts.AccountKeys,
+		"Storage":            scripts.Storage,
+		"PublicPaths":        scripts.PublicPaths,
+		"StoragePaths":       scripts.StoragePaths,
+		"PublicStoragePaths": publicStoragePaths,
+	} {
+		script := script
+		t.Run(name, func(t *testing.T) {
+			program, err := parser.ParseProgram(nil, script, parser.Config{})
+			require.NoError(t, err)
+
+			checker, err := sema.NewChecker(
+				program,
+				common.ScriptLocation{},
+				nil,
+				&sema.Config{
+					BaseValueActivation: baseValueActivation,
+					AccessCheckMode:     sema.AccessCheckModeStrict,
+				},
+			)
+			require.NoError(t, err)
+			require.NoError(t, checker.Check())
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	a1 := flow.HexToAddress("01")
+	a2 := flow.HexToAddress("02")
+
+	t.Run("balances", func(t *testing.T) {
+		balances, err := scripts.DecodeFlowBalances(cadence.NewDictionary([]cadence.KeyValuePair{
+			{Key: cadence.Address(a1), Value: cadence.UFix64(100)},
+			{Key: cadence.Address(a2), Value: cadence.UFix64(200)},
+		}))
+		require.NoError(t, err)
+		require.Equal(t, map[flow.Address]cadence.UFix64{a1: 100, a2: 200}, balances)
+	})
+	t.Run("contract names", func(t *testing.T) {
+		names, err := scripts.DecodeContractNames(cadence.NewDictionary([]cadence.KeyValuePair{
+			{Key: cadence.Address(a1), Value: cadence.NewArray([]cadence.Value{cadence.String("A"), cadence.String("B")})},
+			{Key: cadence.Address(a2), Value: cadence.NewArray(nil)},
+		}))
+		require.NoError(t, err)
+		require.Equal(t, map[flow.Address][]string{a1: {"A", "B"}, a2: {}}, names)
+	})
+	t.Run("contract codes", func(t *testing.T) {
+		codes, err := scripts.DecodeContractCodes(cadence.NewDictionary([]cadence.KeyValuePair{
+			{Key: cadence.Address(a1), Value: cadence.NewDictionary([]cadence.KeyValuePair{
+				{Key: cadence.String("A"), Value: cadence.String("pub contract A {}")},
+			})},
+			{Key: cadence.Address(a2), Value: cadence.NewDictionary(nil)},
+		}))
+		require.NoError(t, err)
+		require.Equal(t, map[flow.Address]map[string]string{a1: {"A": "pub contract A {}"}, a2: {}}, codes)
+	})
+	t.Run("storage", func(t *testing.T) {
+		storageType := &cadence.StructType{
+			QualifiedIdentifier: "StorageInfo",
+			Fields: []cadence.Field{
+				{Identifier: "used", Type: cadence.UInt64Type{}},
+				{Identifier: "capacity", Type: cadence.UInt64Type{}},
+			},
+		}
+		storage, err := scripts.DecodeStorage(cadence.NewDictionary([]cadence.KeyValuePair{
+			{
+				Key: cadence.Address(a1),
+				Value: cadence.NewStruct([]cadence.Value{cadence.UInt64(10), cadence.UInt64(100)}).
+					WithType(storageType),
+			},
+		}))
+		require.NoError(t, err)
+		require.Equal(t, map[flow.Address]scripts.StorageInfo{a1: {Used: 10, Capacity: 100}}, storage)
+	})
+	t.Run("account keys", func(t *testing.T) {
+		keyType := &cadence.StructType{
+			QualifiedIdentifier: "KeyInfo",
+			Fields: []cadence.Field{
+				{Identifier: "keyIndex", Type: cadence.IntType{}},
+				{Identifier: "publicKey", Type: cadence.StringType{}},
+				{Identifier: "signatureAlgorithm", Type: cadence.UInt8Type{}},
+				{Identifier: "hashAlgorithm", Type: cadence.UInt8Type{}},
+				{Identifier: "weight", Type: cadence.UFix64Type{}},
+				{Identifier: "isRevoked", Type: cadence.BoolType{}},
+			},
+		}
+		keys, err := scripts.DecodeAccountKeys(cadence.NewDictionary([]cadence.KeyValuePair{
+			{
+				Key: cadence.Address(a1),
+				Value: cadence.NewArray([]cadence.Value{
+					cadence.NewStruct([]cadence.Value{
+						cadence.NewInt(0),
+						cadence.String("abcd"),
+						cadence.UInt8(1),
+						cadence.UInt8(3),
+						cadence.UFix64(100000000000),
+						cadence.Bool(false),
+					}).WithType(keyType),
+				}),
+			},
+		}))
+		require.NoError(t, err)
+		require.Equal(t, map[flow.Address][]scripts.AccountKey{a1: {{
+			KeyIndex:           0,
+			PublicKey:          "abcd",
+			SignatureAlgorithm: 1,
+			HashAlgorithm:      3,
+			Weight:             100000000000,
+			IsRevoked:          false,
+		}}}, keys)
+	})
+	t.Run("storage paths", func(t *testing.T) {
+		pathType := &cadence.StructType{
+			QualifiedIdentifier: "PathInfo",
+			Fields: []cadence.Field{
+				{Identifier: "domain", Type: cadence.StringType{}},
+				{Identifier: "path", Type: cadence.StringType{}},
+				{Identifier: "typeIdentifier", Type: cadence.StringType{}},
+			},
+		}
+		accountPathsType := &cadence.StructType{
+			QualifiedIdentifier: "AccountPaths",
+			Fields: []cadence.Field{
+				{Identifier: "paths", Type: cadence.NewVariableSizedArrayType(pathType)},
+				{Identifier: "truncated", Type: cadence.BoolType{}},
+				{Identifier: "storageUsed", Type: cadence.UInt64Type{}},
+			},
+		}
+		paths, err := scripts.DecodeStoragePaths(cadence.NewDictionary([]cadence.KeyValuePair{
+			{
+				Key: cadence.Address(a1),
+				Value: cadence.NewStruct([]cadence.Value{
+					cadence.NewArray([]cadence.Value{
+						cadence.NewStruct([]cadence.Value{
+							cadence.String("storage"),
+							cadence.String("/storage/flowTokenVault"),
+							cadence.String("A.0ae53cb6e3f42a79.FlowToken.Vault"),
+						}).WithType(pathType),
+					}),
+					cadence.Bool(true),
+					cadence.UInt64(1024),
+				}).WithType(accountPathsType),
+			},
+		}))
+		require.NoError(t, err)
+		require.Equal(t, map[flow.Address]scripts.AccountPaths{a1: {
+			Paths: []scripts.StoragePath{{
+				Domain:         scripts.PathDomainStorage,
+				Path:           "/storage/flowTokenVault",
+				TypeIdentifier: "A.0ae53cb6e3f42a79.FlowToken.Vault",
+			}},
+			Truncated:   true,
+			StorageUsed: 1024,
+		}}, paths)
+	})
+	t.Run("wrong type", func(t *testing.T) {
+		_, err := scripts.DecodeFlowBalances(cadence.String("nope"))
+		require.Error(t, err)
+	})
+}
+
+func TestStoragePathsScript(t *testing.T) {
+	_, err := scripts.StoragePathsScript(0, scripts.PathDomainStorage)
+	require.Error(t, err)
+	_, err = scripts.StoragePathsScript(10)
+	require.Error(t, err)
+	_, err = scripts.StoragePathsScript(10, scripts.PathDomainStorage, scripts.PathDomainStorage)
+	require.Error(t, err)
+	_, err = scripts.StoragePathsScript(10, "contracts")
+	require.Error(t, err)
+
+	script, err := scripts.StoragePathsScript(10, scripts.PathDomainPrivate)
+	require.NoError(t, err)
+	require.Contains(t, string(script), "forEachPrivate")
+	require.NotContains(t, string(script), "forEachStored")
+}
@@ -0,0 +1,180 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scripts
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"text/template"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+)
+
+// PathDomain is a domain of the paths of an account.
+type PathDomain string
+
+const (
+	PathDomainStorage PathDomain = "storage"
+	PathDomainPublic  PathDomain = "public"
+	PathDomainPrivate PathDomain = "private"
+)
+
+// DefaultMaxPaths is the maximum number of paths the StoragePaths script returns for each address.
+//
+// Every path costs computation, so accounts with many stored objects can make the script exceed
+// the computation limit of the access node. Use a smaller batch size when scanning with a bigger maximum.
+const DefaultMaxPaths = 500
+
+//go:embed storage_paths.cdc
+var storagePathsTemplateSource string
+
+var storagePathsTemplate = template.Must(template.New("storage_paths").Parse(storagePathsTemplateSource))
+
+// StoragePaths returns the storage, public and private paths of each address, with the type stored at each path,
+// up to DefaultMaxPaths paths for each address. Decode the result with DecodeStoragePaths.
+//
+// Cadence does not expose the size of the value stored at a path,
+// so the storage used by the whole account is returned instead.
+var StoragePaths = mustStoragePathsScript(DefaultMaxPaths, PathDomainStorage, PathDomainPublic, PathDomainPrivate)
+
+// StoragePathsScript returns a StoragePaths script that iterates the given domains, in order,
+// and returns up to maxPaths paths for each address.
+// Addresses with more paths are returned with AccountPaths.Truncated set.
+func StoragePathsScript(maxPaths int, domains ...PathDomain) ([]byte, error) {
+	if maxPaths <= 0 {
+		return nil, fmt.Errorf("maxPaths must be positive, got %d", maxPaths)
+	}
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("at least one domain is required")
+	}
+
+	type templateDomain struct {
+		Name     PathDomain
+		Iterator string
+		PathType string
+	}
+	templateDomains := make([]templateDomain, 0, len(domains))
+	seen := make(map[PathDomain]struct{}, len(domains))
+	for _, domain := range domains {
+		if _, ok := seen[domain]; ok {
+			return nil, fmt.Errorf("duplicate domain %q", domain)
+		}
+		seen[domain] = struct{}{}
+
+		switch domain {
+		case PathDomainStorage:
+			templateDomains = append(templateDomains, templateDomain{domain, "forEachStored", "StoragePath"})
+		case PathDomainPublic:
+			templateDomains = append(templateDomains, templateDomain{domain, "forEachPublic", "PublicPath"})
+		case PathDomainPrivate:
+			templateDomains = append(templateDomains, templateDomain{domain, "forEachPrivate", "PrivatePath"})
+		default:
+			return nil, fmt.Errorf("unknown domain %q", domain)
+		}
+	}
+
+	var script bytes.Buffer
+	err := storagePathsTemplate.Execute(&script, struct {
+		MaxPaths int
+		Domains  []templateDomain
+	}{
+		MaxPaths: maxPaths,
+		Domains:  templateDomains,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not render the storage paths script: %w", err)
+	}
+	return script.Bytes(), nil
+}
+
+func mustStoragePathsScript(maxPaths int, domains ...PathDomain) []byte {
+	script, err := StoragePathsScript(maxPaths, domains...)
+	if err != nil {
+		panic(err)
+	}
+	return script
+}
+
+// StoragePath is a path of an account, as returned by the StoragePaths script.
+type StoragePath struct {
+	Domain PathDomain
+	// Path is the full path, e.g. `/storage/flowTokenVault`.
+	Path string
+	// TypeIdentifier is the type of the stored value for storage paths,
+	// and the type of the capability for public and private paths.
+	TypeIdentifier string
+}
+
+// AccountPaths are the paths of an account, as returned by the StoragePaths script.
+type AccountPaths struct {
+	Paths []StoragePath
+	// Truncated is set if the account has more paths than the maximum of the script.
+	Truncated bool
+	// StorageUsed is the storage used by the whole account.
+	StorageUsed uint64
+}
+
+func DecodeStoragePaths(value cadence.Value) (map[flow.Address]AccountPaths, error) {
+	return decodeDictionary(value, func(value cadence.Value) (AccountPaths, error) {
+		fields, err := structFields(value)
+		if err != nil {
+			return AccountPaths{}, err
+		}
+		array, ok := fields["paths"].(cadence.Array)
+		if !ok {
+			return AccountPaths{}, fmt.Errorf("expected paths to be an array")
+		}
+		truncated, ok := fields["truncated"].(cadence.Bool)
+		if !ok {
+			return AccountPaths{}, fmt.Errorf("expected truncated to be Bool")
+		}
+		storageUsed, ok := fields["storageUsed"].(cadence.UInt64)
+		if !ok {
+			return AccountPaths{}, fmt.Errorf("expected storageUsed to be UInt64")
+		}
+
+		paths := make([]StoragePath, 0, len(array.Values))
+		for _, value := range array.Values {
+			fields, err := structFields(value)
+			if err != nil {
+				return AccountPaths{}, err
+			}
+			domain, ok := fields["domain"].(cadence.String)
+			if !ok {
+				return AccountPaths{}, fmt.Errorf("expected domain to be String")
+			}
+			path, ok := fields["path"].(cadence.String)
+			if !ok {
+				return AccountPaths{}, fmt.Errorf("expected path to be String")
+			}
+			typeIdentifier, ok := fields["typeIdentifier"].(cadence.String)
+			if !ok {
+				return AccountPaths{}, fmt.Errorf("expected typeIdentifier to be String")
+			}
+			paths = append(paths, StoragePath{
+				Domain:         PathDomain(domain),
+				Path:           string(path),
+				TypeIdentifier: string(typeIdentifier),
+			})
+		}
+		return AccountPaths{
+			Paths:       paths,
+			Truncated:   bool(truncated),
+			StorageUsed: uint64(storageUsed),
+		}, nil
+	})
+}
@@ -0,0 +1,435 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package serviceevents decodes the service events of the Flow protocol:
+// FlowEpoch.EpochSetup, FlowEpoch.EpochCommit, NodeVersionBeacon.VersionBeacon
+// and NodeVersionBeacon.ProtocolStateVersionUpgrade.
+//
+// Service events are only effective if they were emitted by the system transaction of a block,
+// which is executed in the system chunk. See SystemChunkFilter and Find.
+// To follow them, pass EventTypes, SystemChunkFilter and a registry with RegisterIn to eventfinder.Tail.
+package serviceevents
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+
+	"github.com/onflow/flow-batch-scan/eventfinder"
+)
+
+// Contracts are the addresses of the contracts that emit the service events.
+type Contracts struct {
+	FlowEpoch         flow.Address
+	NodeVersionBeacon flow.Address
+}
+
+// ContractAddresses are the contracts of the service events on the Flow networks.
+var ContractAddresses = map[flow.ChainID]Contracts{
+	flow.Mainnet: {
+		FlowEpoch:         flow.HexToAddress("8624b52f9ddcd04a"),
+		NodeVersionBeacon: flow.HexToAddress("e467b9dd11fa00df"),
+	},
+	flow.Testnet: {
+		FlowEpoch:         flow.HexToAddress("9eca2b38b18b5dfe"),
+		NodeVersionBeacon: flow.HexToAddress("8c5303eaa26202d6"),
+	},
+	flow.Emulator: {
+		FlowEpoch:         flow.HexToAddress("f8d6e0586b0a20c7"),
+		NodeVersionBeacon: flow.HexToAddress("f8d6e0586b0a20c7"),
+	},
+}
+
+// EpochSetupEventType returns the type of the FlowEpoch.EpochSetup events of the chain.
+func EpochSetupEventType(chain flow.ChainID) string {
+	return eventType(ContractAddresses[chain].FlowEpoch, "FlowEpoch", "EpochSetup")
+}
+
+// EpochCommitEventType returns the type of the FlowEpoch.EpochCommit events of the chain.
+func EpochCommitEventType(chain flow.ChainID) string {
+	return eventType(ContractAddresses[chain].FlowEpoch, "FlowEpoch", "EpochCommit")
+}
+
+// VersionBeaconEventType returns the type of the NodeVersionBeacon.VersionBeacon events of the chain.
+func VersionBeaconEventType(chain flow.ChainID) string {
+	return eventType(ContractAddresses[chain].NodeVersionBeacon, "NodeVersionBeacon", "VersionBeacon")
+}
+
+// ProtocolStateVersionUpgradeEventType returns the type of the NodeVersionBeacon.ProtocolStateVersionUpgrade events of the chain.
+func ProtocolStateVersionUpgradeEventType(chain flow.ChainID) string {
+	return eventType(ContractAddresses[chain].NodeVersionBeacon, "NodeVersionBeacon", "ProtocolStateVersionUpgrade")
+}
+
+// EventTypes returns the types of all the service events of the chain.
+func EventTypes(chain flow.ChainID) []string {
+	return []string{
+		EpochSetupEventType(chain),
+		EpochCommitEventType(chain),
+		VersionBeaconEventType(chain),
+		ProtocolStateVersionUpgradeEventType(chain),
+	}
+}
+
+func eventType(address flow.Address, contract string, name string) string {
+	return fmt.Sprintf("A.%s.%s.%s", address.Hex(), contract, name)
+}
+
+// Role is the role of a node.
+type Role uint8
+
+const (
+	RoleCollection   Role = 1
+	RoleConsensus    Role = 2
+	RoleExecution    Role = 3
+	RoleVerification Role = 4
+	RoleAccess       Role = 5
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleCollection:
+		return "collection"
+	case RoleConsensus:
+		return "consensus"
+	case RoleExecution:
+		return "execution"
+	case RoleVerification:
+		return "verification"
+	case RoleAccess:
+		return "access"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(r))
+	}
+}
+
+// NodeInfo is a node of an epoch.
+type NodeInfo struct {
+	ID                string `cadence:"id"`
+	Role              Role   `cadence:"role"`
+	NetworkingAddress string `cadence:"networkingAddress"`
+	NetworkingKey     string `cadence:"networkingKey"`
+	StakingKey        string `cadence:"stakingKey"`
+	InitialWeight     uint64 `cadence:"initialWeight"`
+}
+
+// Cluster is a cluster of collection nodes of an epoch.
+type Cluster struct {
+	Index uint16 `cadence:"index"`
+	// NodeWeights are the weights of the nodes of the cluster by node ID.
+	NodeWeights map[string]uint64
+	TotalWeight uint64 `cadence:"totalWeight"`
+}
+
+// EpochSetup is a FlowEpoch.EpochSetup event: the setup phase of the next epoch started.
+type EpochSetup struct {
+	Counter            uint64 `cadence:"counter"`
+	Nodes              []NodeInfo
+	FirstView          uint64 `cadence:"firstView"`
+	FinalView          uint64 `cadence:"finalView"`
+	CollectorClusters  []Cluster
+	RandomSource       string `cadence:"randomSource"`
+	DKGPhase1FinalView uint64 `cadence:"DKGPhase1FinalView"`
+	DKGPhase2FinalView uint64 `cadence:"DKGPhase2FinalView"`
+	DKGPhase3FinalView uint64 `cadence:"DKGPhase3FinalView"`
+	// TargetDuration and TargetEndTime (in seconds) are zero in events of older versions of the contract.
+	TargetDuration uint64
+	TargetEndTime  uint64
+}
+
+// ClusterQC is the quorum certificate of the root block of a cluster.
+type ClusterQC struct {
+	Index          uint16 `cadence:"index"`
+	VoteSignatures []string
+	VoteMessage    string `cadence:"voteMessage"`
+	VoterIDs       []string
+}
+
+// EpochCommit is a FlowEpoch.EpochCommit event: the next epoch was committed.
+type EpochCommit struct {
+	Counter    uint64 `cadence:"counter"`
+	ClusterQCs []ClusterQC
+	// DKGGroupKey is the group public key of the random beacon.
+	DKGGroupKey string
+	// DKGParticipantKeys are the public keys of the random beacon participants.
+	DKGParticipantKeys []string
+}
+
+// VersionBoundary is the version the nodes need to run from a block height on.
+type VersionBoundary struct {
+	BlockHeight uint64
+	// Version is the semantic version, e.g. 0.37.1 or 0.38.0-rc.1.
+	Version string
+}
+
+// VersionBeacon is a NodeVersionBeacon.VersionBeacon event: the version boundaries changed.
+type VersionBeacon struct {
+	VersionBoundaries []VersionBoundary
+	Sequence          uint64 `cadence:"sequence"`
+}
+
+// ProtocolStateVersionUpgrade is a NodeVersionBeacon.ProtocolStateVersionUpgrade event:
+// the protocol state version changes at a view.
+type ProtocolStateVersionUpgrade struct {
+	NewProtocolVersion uint64 `cadence:"newProtocolVersion"`
+	ActiveView         uint64 `cadence:"activeView"`
+}
+
+// DecodeEpochSetup decodes a FlowEpoch.EpochSetup event.
+func DecodeEpochSetup(event cadence.Event) (EpochSetup, error) {
+	setup, err := eventfinder.DecodeEvent[EpochSetup](event)
+	if err != nil {
+		return EpochSetup{}, err
+	}
+	setup.Nodes, err = decodeArray(event, "nodeInfo", decodeStruct[NodeInfo])
+	if err != nil {
+		return EpochSetup{}, err
+	}
+	setup.CollectorClusters, err = decodeArray(event, "collectorClusters", decodeCluster)
+	if err != nil {
+		return EpochSetup{}, err
+	}
+	setup.TargetDuration, err = optionalUint64(event, "targetDuration")
+	if err != nil {
+		return EpochSetup{}, err
+	}
+	setup.TargetEndTime, err = optionalUint64(event, "targetEndTime")
+	if err != nil {
+		return EpochSetup{}, err
+	}
+	return setup, nil
+}
+
+// DecodeEpochCommit decodes a FlowEpoch.EpochCommit event.
+// In older versions of the event, the group key is the first of the dkgPubKeys.
+func DecodeEpochCommit(event cadence.Event) (EpochCommit, error) {
+	commit, err := eventfinder.DecodeEvent[EpochCommit](event)
+	if err != nil {
+		return EpochCommit{}, err
+	}
+	commit.ClusterQCs, err = decodeArray(event, "clusterQCs", decodeClusterQC)
+	if err != nil {
+		return EpochCommit{}, err
+	}
+	keys, err := decodeArray(event, "dkgPubKeys", decodeString)
+	if err != nil {
+		return EpochCommit{}, err
+	}
+	groupKey, err := fieldOf(event, "dkgGroupKey")
+	switch {
+	case err == nil:
+		commit.DKGGroupKey, err = decodeString(groupKey)
+		if err != nil {
+			return EpochCommit{}, fmt.Errorf("field dkgGroupKey: %w", err)
+		}
+		commit.DKGParticipantKeys = keys
+	case len(keys) > 0:
+		commit.DKGGroupKey = keys[0]
+		commit.DKGParticipantKeys = keys[1:]
+	}
+	return commit, nil
+}
+
+// DecodeVersionBeacon decodes a NodeVersionBeacon.VersionBeacon event.
+func DecodeVersionBeacon(event cadence.Event) (VersionBeacon, error) {
+	beacon, err := eventfinder.DecodeEvent[VersionBeacon](event)
+	if err != nil {
+		return VersionBeacon{}, err
+	}
+	beacon.VersionBoundaries, err = decodeArray(event, "versionBoundaries", decodeVersionBoundary)
+	if err != nil {
+		return VersionBeacon{}, err
+	}
+	return beacon, nil
+}
+
+// DecodeProtocolStateVersionUpgrade decodes a NodeVersionBeacon.ProtocolStateVersionUpgrade event.
+func DecodeProtocolStateVersionUpgrade(event cadence.Event) (ProtocolStateVersionUpgrade, error) {
+	return eventfinder.DecodeEvent[ProtocolStateVersionUpgrade](event)
+}
+
+// RegisterIn decodes the service events of the chain with the registry of the event finder,
+// so e.g. eventfinder.As[EpochSetup] can be used on the found events.
+func RegisterIn(registry *eventfinder.Registry, chain flow.ChainID) {
+	registry.RegisterFunc(EpochSetupEventType(chain), func(event cadence.Event) (any, error) {
+		return DecodeEpochSetup(event)
+	})
+	registry.RegisterFunc(EpochCommitEventType(chain), func(event cadence.Event) (any, error) {
+		return DecodeEpochCommit(event)
+	})
+	registry.RegisterFunc(VersionBeaconEventType(chain), func(event cadence.Event) (any, error) {
+		return DecodeVersionBeacon(event)
+	})
+	registry.RegisterFunc(ProtocolStateVersionUpgradeEventType(chain), func(event cadence.Event) (any, error) {
+		return DecodeProtocolStateVersionUpgrade(event)
+	})
+}
+
+func decodeCluster(value cadence.Value) (Cluster, error) {
+	cluster, err := decodeStruct[Cluster](value)
+	if err != nil {
+		return Cluster{}, err
+	}
+	weights, err := fieldOf(value, "nodeWeights")
+	if err != nil {
+		return Cluster{}, err
+	}
+	dictionary, ok := weights.(cadence.Dictionary)
+	if !ok {
+		return Cluster{}, fmt.Errorf("field nodeWeights is not a dictionary: %s", weights)
+	}
+	cluster.NodeWeights = make(map[string]uint64, len(dictionary.Pairs))
+	for _, pair := range dictionary.Pairs {
+		id, err := decodeString(pair.Key)
+		if err != nil {
+			return Cluster{}, fmt.Errorf("field nodeWeights: %w", err)
+		}
+		weight, ok := pair.Value.(cadence.UInt64)
+		if !ok {
+			return Cluster{}, fmt.Errorf("field nodeWeights: weight of %s is not a UInt64: %s", id, pair.Value)
+		}
+		cluster.NodeWeights[id] = uint64(weight)
+	}
+	return cluster, nil
+}
+
+func decodeClusterQC(value cadence.Value) (ClusterQC, error) {
+	qc, err := decodeStruct[ClusterQC](value)
+	if err != nil {
+		return ClusterQC{}, err
+	}
+	qc.VoteSignatures, err = decodeArray(value, "voteSignatures", decodeString)
+	if err != nil {
+		return ClusterQC{}, err
+	}
+	qc.VoterIDs, err = decodeArray(value, "voterIDs", decodeString)
+	if err != nil {
+		return ClusterQC{}, err
+	}
+	return qc, nil
+}
+
+func decodeVersionBoundary(value cadence.Value) (VersionBoundary, error) {
+	var boundary struct {
+		BlockHeight uint64        `cadence:"blockHeight"`
+		Version     cadence.Value `cadence:"version"`
+	}
+	fields, ok := value.(cadence.Struct)
+	if !ok {
+		return VersionBoundary{}, fmt.Errorf("version boundary is not a struct: %s", value)
+	}
+	err := cadence.DecodeFields(fields, &boundary)
+	if err != nil {
+		return VersionBoundary{}, err
+	}
+	version, err := decodeSemver(boundary.Version)
+	if err != nil {
+		return VersionBoundary{}, err
+	}
+	return VersionBoundary{BlockHeight: boundary.BlockHeight, Version: version}, nil
+}
+
+// decodeSemver formats a NodeVersionBeacon.Semver struct.
+func decodeSemver(value cadence.Value) (string, error) {
+	var semver struct {
+		Major uint8 `cadence:"major"`
+		Minor uint8 `cadence:"minor"`
+		Patch uint8 `cadence:"patch"`
+	}
+	fields, ok := value.(cadence.Struct)
+	if !ok {
+		return "", fmt.Errorf("version is not a struct: %s", value)
+	}
+	err := cadence.DecodeFields(fields, &semver)
+	if err != nil {
+		return "", err
+	}
+	version := fmt.Sprintf("%d.%d.%d", semver.Major, semver.Minor, semver.Patch)
+	preRelease, err := fieldOf(fields, "preRelease")
+	if err != nil {
+		return version, nil
+	}
+	if optional, ok := preRelease.(cadence.Optional); ok {
+		preRelease = optional.Value
+	}
+	if s, ok := preRelease.(cadence.String); ok && s != "" {
+		version += "-" + strings.TrimPrefix(string(s), "-")
+	}
+	return version, nil
+}
+
+// decodeStruct decodes the tagged fields of a Cadence struct into T.
+func decodeStruct[T any](value cadence.Value) (T, error) {
+	var decoded T
+	fields, ok := value.(cadence.Struct)
+	if !ok {
+		return decoded, fmt.Errorf("%s is not a struct", value)
+	}
+	err := cadence.DecodeFields(fields, &decoded)
+	return decoded, err
+}
+
+// decodeArray decodes each element of the array field of value.
+func decodeArray[T any](value cadence.Value, name string, decode func(cadence.Value) (T, error)) ([]T, error) {
+	field, err := fieldOf(value, name)
+	if err != nil {
+		return nil, err
+	}
+	array, ok := field.(cadence.Array)
+	if !ok {
+		return nil, fmt.Errorf("field %s is not an array: %s", name, field)
+	}
+	decoded := make([]T, len(array.Values))
+	for i, element := range array.Values {
+		decoded[i], err = decode(element)
+		if err != nil {
+			return nil, fmt.Errorf("field %s, element %d: %w", name, i, err)
+		}
+	}
+	return decoded, nil
+}
+
+func decodeString(value cadence.Value) (string, error) {
+	s, ok := value.(cadence.String)
+	if !ok {
+		return "", fmt.Errorf("%s is not a string", value)
+	}
+	return string(s), nil
+}
+
+// optionalUint64 returns the UInt64 field of the event, or 0 if the event has no such field.
+func optionalUint64(event cadence.Event, name string) (uint64, error) {
+	value, err := fieldOf(event, name)
+	if err != nil {
+		return 0, nil
+	}
+	n, ok := value.(cadence.UInt64)
+	if !ok {
+		return 0, fmt.Errorf("field %s is not a UInt64: %s", name, value)
+	}
+	return uint64(n), nil
+}
+
+func fieldOf(value cadence.Value, name string) (cadence.Value, error) {
+	fields, ok := value.(cadence.HasFields)
+	if !ok {
+		return nil, fmt.Errorf("%s has no fields", value)
+	}
+	field, ok := cadence.GetFieldsMappedByName(fields)[name]
+	if !ok {
+		return nil, fmt.Errorf("field %s not found", name)
+	}
+	return field, nil
+}
@@ -0,0 +1,213 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serviceevents_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/cadence/runtime/common"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+	"github.com/onflow/flow-batch-scan/eventfinder"
+	"github.com/onflow/flow-batch-scan/serviceevents"
+)
+
+var serviceAccount = common.Address(flow.HexToAddress("f8d6e0586b0a20c7"))
+
+func newStruct(name string, fields map[string]cadence.Value) cadence.Struct {
+	var types []cadence.Field
+	var values []cadence.Value
+	for identifier, value := range fields {
+		types = append(types, cadence.Field{Identifier: identifier})
+		values = append(values, value)
+	}
+	return cadence.NewStruct(values).WithType(&cadence.StructType{
+		Location:            common.AddressLocation{Address: serviceAccount, Name: name},
+		QualifiedIdentifier: name,
+		Fields:              types,
+	})
+}
+
+func newEvent(contract string, name string, fields map[string]cadence.Value) cadence.Event {
+	var types []cadence.Field
+	var values []cadence.Value
+	for identifier, value := range fields {
+		types = append(types, cadence.Field{Identifier: identifier})
+		values = append(values, value)
+	}
+	return cadence.NewEvent(values).WithType(&cadence.EventType{
+		Location:            common.AddressLocation{Address: serviceAccount, Name: contract},
+		QualifiedIdentifier: contract + "." + name,
+		Fields:              types,
+	})
+}
+
+func stringArray(values ...string) cadence.Array {
+	array := make([]cadence.Value, len(values))
+	for i, value := range values {
+		array[i] = cadence.String(value)
+	}
+	return cadence.NewArray(array)
+}
+
+func epochSetup() cadence.Event {
+	node := newStruct("FlowIDTableStaking.NodeInfo", map[string]cadence.Value{
+		"id":                cadence.String("aa"),
+		"role":              cadence.UInt8(1),
+		"networkingAddress": cadence.String("collection-1:3569"),
+		"networkingKey":     cadence.String("01"),
+		"stakingKey":        cadence.String("02"),
+		"initialWeight":     cadence.UInt64(100),
+		"tokensStaked":      cadence.UFix64(0),
+	})
+	cluster := newStruct("FlowClusterQC.Cluster", map[string]cadence.Value{
+		"index": cadence.UInt16(0),
+		"nodeWeights": cadence.NewDictionary([]cadence.KeyValuePair{
+			{Key: cadence.String("aa"), Value: cadence.UInt64(100)},
+		}),
+		"totalWeight": cadence.UInt64(100),
+	})
+	return newEvent("FlowEpoch", "EpochSetup", map[string]cadence.Value{
+		"counter":            cadence.UInt64(7),
+		"nodeInfo":           cadence.NewArray([]cadence.Value{node}),
+		"firstView":          cadence.UInt64(1000),
+		"finalView":          cadence.UInt64(1999),
+		"collectorClusters":  cadence.NewArray([]cadence.Value{cluster}),
+		"randomSource":       cadence.String("abcd"),
+		"DKGPhase1FinalView": cadence.UInt64(1100),
+		"DKGPhase2FinalView": cadence.UInt64(1200),
+		"DKGPhase3FinalView": cadence.UInt64(1300),
+	})
+}
+
+func TestDecodeEpochSetup(t *testing.T) {
+	setup, err := serviceevents.DecodeEpochSetup(epochSetup())
+	require.NoError(t, err)
+	require.Equal(t, serviceevents.EpochSetup{
+		Counter: 7,
+		Nodes: []serviceevents.NodeInfo{{
+			ID:                "aa",
+			Role:              serviceevents.RoleCollection,
+			NetworkingAddress: "collection-1:3569",
+			NetworkingKey:     "01",
+			StakingKey:        "02",
+			InitialWeight:     100,
+		}},
+		FirstView: 1000,
+		FinalView: 1999,
+		CollectorClusters: []serviceevents.Cluster{{
+			Index:       0,
+			NodeWeights: map[string]uint64{"aa": 100},
+			TotalWeight: 100,
+		}},
+		RandomSource:       "abcd",
+		DKGPhase1FinalView: 1100,
+		DKGPhase2FinalView: 1200,
+		DKGPhase3FinalView: 1300,
+	}, setup)
+}
+
+func TestDecodeEpochCommit(t *testing.T) {
+	qc := newStruct("FlowClusterQC.ClusterQC", map[string]cadence.Value{
+		"index":          cadence.UInt16(0),
+		"voteSignatures": stringArray("s1"),
+		"voteMessage":    cadence.String("m"),
+		"voterIDs":       stringArray("aa"),
+	})
+	fields := map[string]cadence.Value{
+		"counter":    cadence.UInt64(7),
+		"clusterQCs": cadence.NewArray([]cadence.Value{qc}),
+		"dkgPubKeys": stringArray("group", "k1", "k2"),
+	}
+
+	// older versions of the event have the group key as the first of the keys
+	commit, err := serviceevents.DecodeEpochCommit(newEvent("FlowEpoch", "EpochCommit", fields))
+	require.NoError(t, err)
+	require.Equal(t, serviceevents.EpochCommit{
+		Counter: 7,
+		ClusterQCs: []serviceevents.ClusterQC{{
+			VoteSignatures: []string{"s1"},
+			VoteMessage:    "m",
+			VoterIDs:       []string{"aa"},
+		}},
+		DKGGroupKey:        "group",
+		DKGParticipantKeys: []string{"k1", "k2"},
+	}, commit)
+
+	fields["dkgPubKeys"] = stringArray("k1", "k2")
+	fields["dkgGroupKey"] = cadence.String("group")
+	commit, err = serviceevents.DecodeEpochCommit(newEvent("FlowEpoch", "EpochCommit", fields))
+	require.NoError(t, err)
+	require.Equal(t, "group", commit.DKGGroupKey)
+	require.Equal(t, []string{"k1", "k2"}, commit.DKGParticipantKeys)
+}
+
+func TestDecodeVersionBeacon(t *testing.T) {
+	semver := newStruct("NodeVersionBeacon.Semver", map[string]cadence.Value{
+		"major":      cadence.UInt8(0),
+		"minor":      cadence.UInt8(38),
+		"patch":      cadence.UInt8(1),
+		"preRelease": cadence.NewOptional(cadence.String("rc.1")),
+	})
+	boundary := newStruct("NodeVersionBeacon.VersionBoundary", map[string]cadence.Value{
+		"blockHeight": cadence.UInt64(100),
+		"version":     semver,
+	})
+	beacon, err := serviceevents.DecodeVersionBeacon(newEvent("NodeVersionBeacon", "VersionBeacon", map[string]cadence.Value{
+		"versionBoundaries": cadence.NewArray([]cadence.Value{boundary}),
+		"sequence":          cadence.UInt64(3),
+	}))
+	require.NoError(t, err)
+	require.Equal(t, serviceevents.VersionBeacon{
+		VersionBoundaries: []serviceevents.VersionBoundary{{BlockHeight: 100, Version: "0.38.1-rc.1"}},
+		Sequence:          3,
+	}, beacon)
+}
+
+func TestFind(t *testing.T) {
+	upgrade := newEvent("NodeVersionBeacon", "ProtocolStateVersionUpgrade", map[string]cadence.Value{
+		"newProtocolVersion": cadence.UInt64(2),
+		"activeView":         cadence.UInt64(5000),
+	})
+	upgradeType := serviceevents.ProtocolStateVersionUpgradeEventType(flow.Emulator)
+	setupType := serviceevents.EpochSetupEventType(flow.Emulator)
+	require.Equal(t, upgradeType, upgrade.EventType.ID())
+
+	c := clienttest.New()
+	c.AddBlocks(1, 10)
+	c.AddBlock(5,
+		// a user transaction emitting a service event has no effect on the protocol
+		clienttest.Transaction{Events: []flow.Event{{Type: upgradeType, Value: upgrade}}},
+		clienttest.Transaction{Events: []flow.Event{
+			{Type: setupType, Value: epochSetup()},
+			{Type: upgradeType, Value: upgrade},
+		}},
+	)
+
+	events, err := serviceevents.Find(context.Background(), c, flow.Emulator, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, setupType, events[0].Type)
+	require.Equal(t, 1, events[0].TransactionIndex)
+	setup, ok := eventfinder.As[serviceevents.EpochSetup](events[0])
+	require.True(t, ok)
+	require.Equal(t, uint64(7), setup.Counter)
+	require.Equal(t, serviceevents.ProtocolStateVersionUpgrade{NewProtocolVersion: 2, ActiveView: 5000}, events[1].Decoded)
+	require.Equal(t, 1, events[1].EventIndex)
+}
@@ -0,0 +1,113 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serviceevents
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/onflow/flow-go-sdk"
+
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/eventfinder"
+)
+
+// SystemTransaction returns the ID and the index of the system transaction of the block.
+// The system transaction is the last transaction of every block, and the only transaction of the system chunk.
+func SystemTransaction(ctx context.Context, client client.Client, blockID flow.Identifier) (flow.Identifier, int, error) {
+	results, err := client.GetTransactionResultsByBlockID(ctx, blockID)
+	if err != nil {
+		return flow.EmptyID, 0, fmt.Errorf("failed to get the transaction results of block %s: %w", blockID, err)
+	}
+	if len(results) == 0 {
+		return flow.EmptyID, 0, fmt.Errorf("block %s has no transactions", blockID)
+	}
+	last := len(results) - 1
+	return results[last].TransactionID, last, nil
+}
+
+// InSystemChunk is true if the event was emitted by the system transaction of its block.
+func InSystemChunk(ctx context.Context, client client.Client, event eventfinder.Event) (bool, error) {
+	txID, _, err := SystemTransaction(ctx, client, event.BlockID)
+	if err != nil {
+		return false, err
+	}
+	return event.TransactionID == txID, nil
+}
+
+// SystemChunkFilter keeps only the events emitted by the system transaction of their block.
+// Service events emitted by other transactions, e.g. by administrative transactions, have no effect on the protocol.
+// The system transaction of each block is looked up once.
+func SystemChunkFilter(ctx context.Context, client client.Client) eventfinder.Filter {
+	var mu sync.Mutex
+	systemTransactions := make(map[flow.Identifier]flow.Identifier)
+	return func(event eventfinder.Event) (bool, error) {
+		mu.Lock()
+		txID, ok := systemTransactions[event.BlockID]
+		mu.Unlock()
+		if !ok {
+			var err error
+			txID, _, err = SystemTransaction(ctx, client, event.BlockID)
+			if err != nil {
+				return false, err
+			}
+			mu.Lock()
+			systemTransactions[event.BlockID] = txID
+			mu.Unlock()
+		}
+		return event.TransactionID == txID, nil
+	}
+}
+
+// Find returns the service events of the chain from startHeight to endHeight (inclusive),
+// that were emitted in the system chunk, ordered by block height, transaction index and event index.
+// The events are decoded into EpochSetup, EpochCommit, VersionBeacon and ProtocolStateVersionUpgrade.
+func Find(
+	ctx context.Context,
+	client client.Client,
+	chain flow.ChainID,
+	startHeight uint64,
+	endHeight uint64,
+	options ...eventfinder.Option,
+) ([]eventfinder.Event, error) {
+	registry := eventfinder.NewRegistry()
+	RegisterIn(registry, chain)
+	options = append([]eventfinder.Option{
+		eventfinder.WithRegistry(registry),
+		eventfinder.WithFilter(SystemChunkFilter(ctx, client)),
+	}, options...)
+
+	var events []eventfinder.Event
+	for _, eventType := range EventTypes(chain) {
+		found, err := eventfinder.Find(ctx, client, eventType, startHeight, endHeight, options...)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, found...)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if a.BlockHeight != b.BlockHeight {
+			return a.BlockHeight < b.BlockHeight
+		}
+		if a.TransactionIndex != b.TransactionIndex {
+			return a.TransactionIndex < b.TransactionIndex
+		}
+		return a.EventIndex < b.EventIndex
+	})
+	return events, nil
+}
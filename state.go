@@ -0,0 +1,140 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+)
+
+// scanStateVersion is the version of the format of the exported scan state.
+const scanStateVersion = 1
+
+// scanState is the state of a scan, that a scan on another machine can continue from.
+// See Scanner.ExportState.
+type scanState struct {
+	Version    int       `json:"version"`
+	ChainID    string    `json:"chain_id"`
+	ExportedAt time.Time `json:"exported_at"`
+	// IncrementalHeight is the latest block height handled by the incremental scanner, 0 if it handled none.
+	IncrementalHeight uint64 `json:"incremental_height"`
+	// FullScan is the cursor of the full scan that was not completed, if there is one.
+	FullScan *FullScanCheckpoint `json:"full_scan,omitempty"`
+	// PendingBatches are the batches of the durable queue that were not done.
+	PendingBatches []pendingBatchRecord `json:"pending_batches"`
+	FailedBatches  []failedBatchRecord  `json:"failed_batches"`
+}
+
+type pendingBatchRecord struct {
+	Addresses   []flow.Address `json:"addresses"`
+	BlockHeight uint64         `json:"block_height"`
+	ScriptName  string         `json:"script_name,omitempty"`
+}
+
+// ExportState writes the state of the running scan, or of the last scan, as JSON:
+// the cursor of the incremental scanner, the cursor of the full scan if it was not completed,
+// the batches that were pending in the durable queue (see WithDurableQueue), and the failed batches.
+// Without a durable queue the cursors only move past the batches that are done,
+// so the batches that were pending are scanned again.
+// A scanner continues from the state with RestoreState, e.g. on another machine.
+func (scanner *Scanner) ExportState(w io.Writer) error {
+	var state scanState
+	if running := scanner.runningScan(); running != nil {
+		state = running.state()
+	} else {
+		scanner.mu.Lock()
+		last := scanner.lastState
+		scanner.mu.Unlock()
+		if last == nil {
+			return errors.New("no scan state to export: the scan did not run")
+		}
+		state = *last
+	}
+	state.ExportedAt = time.Now().UTC()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(state)
+}
+
+// RestoreState reads a state written by ExportState, and the next scan continues from it:
+// the incremental scanner continues after the exported cursor, the full scan continues from its cursor
+// (backfilling the blocks after it like a CheckpointStore does), the pending batches are scanned first,
+// and the failed batches are added to the failed batches of the scan, so they can be rescanned.
+// The restored state takes precedence over the ProgressStore and the CheckpointStore.
+func (scanner *Scanner) RestoreState(r io.Reader) error {
+	var state scanState
+	err := json.NewDecoder(r).Decode(&state)
+	if err != nil {
+		return fmt.Errorf("invalid scan state: %w", err)
+	}
+	if state.Version != scanStateVersion {
+		return fmt.Errorf("unsupported scan state version %d, expected %d", state.Version, scanStateVersion)
+	}
+	if scanner.runningScan() != nil {
+		return errors.New("the state cannot be restored while a scan is running")
+	}
+
+	scanner.mu.Lock()
+	defer scanner.mu.Unlock()
+	scanner.restored = &state
+	return nil
+}
+
+// takeRestoredState returns the restored state once, for the next scan.
+func (scanner *Scanner) takeRestoredState(chainID flow.ChainID) (*scanState, error) {
+	scanner.mu.Lock()
+	defer scanner.mu.Unlock()
+	state := scanner.restored
+	if state == nil {
+		return nil, nil
+	}
+	if state.ChainID != "" && flow.ChainID(state.ChainID) != chainID {
+		return nil, fmt.Errorf("the restored scan state is of chain %s, but the scan is of chain %s", state.ChainID, chainID)
+	}
+	scanner.restored = nil
+	return state, nil
+}
+
+// sendPendingBatches sends the pending batches of the restored state to be scanned.
+// The returned channel is closed once they are done.
+func sendPendingBatches(ctx context.Context, pending []pendingBatchRecord, out chan<- AddressBatch) <-chan struct{} {
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(len(pending))
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	go func() {
+		for _, record := range pending {
+			batch := NewAddressBatch(record.Addresses, record.BlockHeight, wg.Done, nil)
+			batch.ScriptName = record.ScriptName
+			select {
+			case <-ctx.Done():
+				return
+			case out <- batch:
+			}
+		}
+	}()
+	return done
+}
@@ -0,0 +1,114 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+)
+
+func TestScanner_ExportAndRestoreState(t *testing.T) {
+	generator := flow.NewAddressGenerator(flow.Emulator)
+	accounts := map[flow.Address]struct{}{}
+	for i := 0; i < 5; i++ {
+		accounts[generator.NextAddress()] = struct{}{}
+	}
+	pending := flow.HexToAddress("0x1234")
+
+	var mu sync.Mutex
+	scanned := map[flow.Address]uint64{}
+	c := clienttest.New()
+	c.AddBlocks(1, 10)
+	c.HandleScripts(func(height uint64, script []byte, arguments []cadence.Value) (cadence.Value, error) {
+		if string(script) == accountStorageUsageScript {
+			if _, ok := accounts[flow.Address(arguments[0].(cadence.Address))]; !ok {
+				return nil, fmt.Errorf("%s: account not found", endOfAccountsError)
+			}
+			return cadence.NewUInt64(0), nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, address := range arguments[0].(cadence.Array).Values {
+			scanned[flow.Address(address.(cadence.Address))] = height
+		}
+		return cadence.NewBool(true), nil
+	})
+
+	newScanner := func() *Scanner {
+		s, err := NewScanner(c,
+			WithScript([]byte("pub fun main(addresses: [Address]): Bool { return true }")),
+			WithReferenceBlockHeight(10),
+		)
+		require.NoError(t, err)
+		return s
+	}
+
+	t.Run("no state before a scan", func(t *testing.T) {
+		require.Error(t, newScanner().ExportState(&bytes.Buffer{}))
+	})
+
+	t.Run("restore and export", func(t *testing.T) {
+		state := `{
+			"version": 1,
+			"chain_id": "flow-emulator",
+			"pending_batches": [{"addresses": ["` + pending.Hex() + `"], "block_height": 7}],
+			"failed_batches": [{"addresses": ["0x01"], "block_height": 5, "error": "timeout"}]
+		}`
+		s := newScanner()
+		require.NoError(t, s.RestoreState(strings.NewReader(state)))
+
+		concluded, err := s.Scan(context.Background())
+		require.NoError(t, err)
+		require.Len(t, concluded.Stats.FailedBatches, 1)
+		mu.Lock()
+		require.Equal(t, uint64(7), scanned[pending])
+		for address := range accounts {
+			require.Equal(t, uint64(10), scanned[address])
+		}
+		mu.Unlock()
+
+		var exported bytes.Buffer
+		require.NoError(t, s.ExportState(&exported))
+		var decoded scanState
+		require.NoError(t, json.Unmarshal(exported.Bytes(), &decoded))
+		require.Equal(t, "flow-emulator", decoded.ChainID)
+		// the full scan was completed
+		require.Nil(t, decoded.FullScan)
+		require.Len(t, decoded.FailedBatches, 1)
+		require.Equal(t, "timeout", decoded.FailedBatches[0].Error)
+	})
+
+	t.Run("other chain", func(t *testing.T) {
+		s := newScanner()
+		require.NoError(t, s.RestoreState(strings.NewReader(`{"version": 1, "chain_id": "flow-mainnet"}`)))
+		_, err := s.Scan(context.Background())
+		require.ErrorContains(t, err, "flow-mainnet")
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		require.Error(t, newScanner().RestoreState(strings.NewReader(`{"version": 2}`)))
+	})
+}
@@ -0,0 +1,241 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+)
+
+// UnknownScannerName is used in the candidate counts for candidates without provenance.
+const UnknownScannerName = "unknown"
+
+// ScanStats describe what happened during a scan. They are returned as part of ScanConcluded.
+type ScanStats struct {
+	// AddressesScanned is the number of addresses the script was successfully run for.
+	// Addresses scanned multiple times are counted every time.
+	AddressesScanned uint64
+	// BatchesExecuted is the number of successful script executions.
+	BatchesExecuted uint64
+	// Retries is the number of times a batch was retried, split or retried with excluded addresses.
+	Retries uint64
+	// ScriptErrors is the number of failed script executions, including the ones that were retried.
+	ScriptErrors uint64
+	// FailedBatches are the batches that could not be scanned.
+	// With BisectFailedBatches these are the single addresses that kept failing.
+	FailedBatches []FailedBatch
+	// HandlerErrors is the number of ScriptResultHandler errors that were ignored,
+	// because of the HandlerErrorLogAndContinue policy or isolated ScriptResultHandlers.
+	HandlerErrors uint64
+	// ReconciledAddresses is the number of address results that were not handled,
+	// because of the ScriptResultProcessorConfig.ResultReconciliation.
+	ReconciledAddresses uint64
+
+	// DryRunAddresses and DryRunBatches are the addresses and batches that would have been scanned,
+	// if the scan was not a dry run.
+	DryRunAddresses uint64
+	DryRunBatches   uint64
+
+	// Candidates is the number of candidates found by the incremental scanner, per candidate scanner.
+	Candidates map[string]uint64
+
+	// FullScans is the number of full scans that were started, and FullScansCompleted how many of them completed.
+	FullScans          uint64
+	FullScansCompleted uint64
+
+	// Duration is the wall clock duration of the whole scan.
+	Duration time.Duration
+	// FullScanDuration is the total wall clock duration of all full scans.
+	FullScanDuration time.Duration
+	// CandidateScanDuration is the total time the incremental scanner spent scanning block ranges for candidates.
+	CandidateScanDuration time.Duration
+	// ScriptDuration is the total time spent running scripts. Scripts run concurrently,
+	// so this can be longer than Duration.
+	ScriptDuration time.Duration
+}
+
+// Merge adds the stats of another scan that ran at the same time, e.g. of another partition.
+// The Duration is the longer of the two, the other durations are added up.
+func (s ScanStats) Merge(other ScanStats) ScanStats {
+	s.AddressesScanned += other.AddressesScanned
+	s.BatchesExecuted += other.BatchesExecuted
+	s.Retries += other.Retries
+	s.ScriptErrors += other.ScriptErrors
+	s.FailedBatches = append(s.FailedBatches[:len(s.FailedBatches):len(s.FailedBatches)], other.FailedBatches...)
+	s.HandlerErrors += other.HandlerErrors
+	s.ReconciledAddresses += other.ReconciledAddresses
+	s.DryRunAddresses += other.DryRunAddresses
+	s.DryRunBatches += other.DryRunBatches
+
+	candidates := make(map[string]uint64, len(s.Candidates)+len(other.Candidates))
+	for scanner, count := range s.Candidates {
+		candidates[scanner] += count
+	}
+	for scanner, count := range other.Candidates {
+		candidates[scanner] += count
+	}
+	s.Candidates = candidates
+
+	s.FullScans += other.FullScans
+	s.FullScansCompleted += other.FullScansCompleted
+	if other.Duration > s.Duration {
+		s.Duration = other.Duration
+	}
+	s.FullScanDuration += other.FullScanDuration
+	s.CandidateScanDuration += other.CandidateScanDuration
+	s.ScriptDuration += other.ScriptDuration
+	return s
+}
+
+// FailedBatch is a batch of addresses that could not be scanned.
+type FailedBatch struct {
+	Addresses   []flow.Address
+	BlockHeight uint64
+	// ScriptName is the name of the script that failed, if multiple Scripts are configured.
+	ScriptName string
+	Err        error
+}
+
+// statsCollector collects the ScanStats from all the components.
+// A nil statsCollector ignores everything.
+type statsCollector struct {
+	mu    sync.Mutex
+	stats ScanStats
+}
+
+func newStatsCollector() *statsCollector {
+	return &statsCollector{
+		stats: ScanStats{
+			Candidates: make(map[string]uint64),
+		},
+	}
+}
+
+func (c *statsCollector) update(f func(stats *ScanStats)) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f(&c.stats)
+}
+
+func (c *statsCollector) batchExecuted(batch ProcessedAddressBatch) {
+	c.update(func(stats *ScanStats) {
+		stats.AddressesScanned += uint64(len(batch.Addresses))
+		stats.BatchesExecuted++
+		stats.ScriptDuration += batch.ScriptDuration
+	})
+}
+
+func (c *statsCollector) batchSkipped(batch AddressBatch) {
+	c.update(func(stats *ScanStats) {
+		stats.DryRunAddresses += uint64(len(batch.Addresses))
+		stats.DryRunBatches++
+	})
+}
+
+func (c *statsCollector) batchRetried() {
+	c.update(func(stats *ScanStats) {
+		stats.Retries++
+	})
+}
+
+func (c *statsCollector) scriptError() {
+	c.update(func(stats *ScanStats) {
+		stats.ScriptErrors++
+	})
+}
+
+func (c *statsCollector) batchFailed(batch AddressBatch, err error) {
+	c.update(func(stats *ScanStats) {
+		stats.FailedBatches = append(stats.FailedBatches, FailedBatch{
+			Addresses:   append([]flow.Address(nil), batch.Addresses...),
+			BlockHeight: batch.BlockHeight,
+			ScriptName:  batch.ScriptName,
+			Err:         err,
+		})
+	})
+}
+
+func (c *statsCollector) handlerErrors(count int) {
+	c.update(func(stats *ScanStats) {
+		stats.HandlerErrors += uint64(count)
+	})
+}
+
+func (c *statsCollector) addressesReconciled(count int) {
+	c.update(func(stats *ScanStats) {
+		stats.ReconciledAddresses += uint64(count)
+	})
+}
+
+func (c *statsCollector) candidatesFound(result candidates.CandidatesResult, duration time.Duration) {
+	c.update(func(stats *ScanStats) {
+		stats.CandidateScanDuration += duration
+		for address := range result.Addresses {
+			provenance := result.Provenance[address]
+			if len(provenance) == 0 {
+				stats.Candidates[UnknownScannerName]++
+				continue
+			}
+			// count each address once per scanner
+			counted := make(map[string]struct{}, len(provenance))
+			for _, p := range provenance {
+				if _, ok := counted[p.Scanner]; ok {
+					continue
+				}
+				counted[p.Scanner] = struct{}{}
+				stats.Candidates[p.Scanner]++
+			}
+		}
+	})
+}
+
+func (c *statsCollector) fullScanStarted() {
+	c.update(func(stats *ScanStats) {
+		stats.FullScans++
+	})
+}
+
+func (c *statsCollector) fullScanFinished(duration time.Duration, completed bool) {
+	c.update(func(stats *ScanStats) {
+		stats.FullScanDuration += duration
+		if completed {
+			stats.FullScansCompleted++
+		}
+	})
+}
+
+// snapshot returns a copy of the collected stats.
+func (c *statsCollector) snapshot(duration time.Duration) ScanStats {
+	if c == nil {
+		return ScanStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Duration = duration
+	stats.FailedBatches = append([]FailedBatch(nil), c.stats.FailedBatches...)
+	stats.Candidates = make(map[string]uint64, len(c.stats.Candidates))
+	for scanner, count := range c.stats.Candidates {
+		stats.Candidates[scanner] = count
+	}
+	return stats
+}
@@ -0,0 +1,68 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+)
+
+func TestStatsCollector(t *testing.T) {
+	t.Run("nil collector", func(t *testing.T) {
+		var c *statsCollector
+		c.batchRetried()
+		require.Equal(t, ScanStats{}, c.snapshot(time.Second))
+	})
+	t.Run("candidates per scanner", func(t *testing.T) {
+		c := newStatsCollector()
+		a1 := flow.HexToAddress("01")
+		a2 := flow.HexToAddress("02")
+
+		result := candidates.NewCandidatesResult(map[flow.Address]struct{}{a2: {}})
+		result.Add(a1, candidates.Provenance{Scanner: "events", BlockHeight: 1})
+		result.Add(a1, candidates.Provenance{Scanner: "events", BlockHeight: 2})
+		result.Add(a1, candidates.Provenance{Scanner: "keys", BlockHeight: 2})
+		c.candidatesFound(result, time.Second)
+
+		stats := c.snapshot(2 * time.Second)
+		require.Equal(t, map[string]uint64{
+			"events":           1,
+			"keys":             1,
+			UnknownScannerName: 1,
+		}, stats.Candidates)
+		require.Equal(t, time.Second, stats.CandidateScanDuration)
+		require.Equal(t, 2*time.Second, stats.Duration)
+	})
+	t.Run("batches", func(t *testing.T) {
+		c := newStatsCollector()
+		batch := NewAddressBatch([]flow.Address{flow.HexToAddress("01")}, 5, nil, nil)
+		c.batchExecuted(ProcessedAddressBatch{AddressBatch: batch, ScriptDuration: time.Second})
+		c.batchRetried()
+		c.batchFailed(batch, fmt.Errorf("failed"))
+
+		stats := c.snapshot(0)
+		require.Equal(t, uint64(1), stats.AddressesScanned)
+		require.Equal(t, uint64(1), stats.BatchesExecuted)
+		require.Equal(t, uint64(1), stats.Retries)
+		require.Len(t, stats.FailedBatches, 1)
+		require.Equal(t, uint64(5), stats.FailedBatches[0].BlockHeight)
+	})
+}
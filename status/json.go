@@ -0,0 +1,324 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	scanner "github.com/onflow/flow-batch-scan"
+)
+
+// DefaultJSONReporterInterval is how often the JSON status is written by default.
+const DefaultJSONReporterInterval = 10 * time.Second
+
+type JSONReporterConfig struct {
+	// Path if set, is the file the status is written to. The file is replaced atomically,
+	// so readers always see a complete document.
+	Path string
+	// Writer if set, receives the status as one JSON document per line.
+	Writer io.Writer
+	// Interval is how often the status is written. It is also written when the reporter stops.
+	Interval time.Duration
+}
+
+func DefaultJSONReporterConfig(path string) JSONReporterConfig {
+	return JSONReporterConfig{
+		Path:     path,
+		Interval: DefaultJSONReporterInterval,
+	}
+}
+
+// Status is the JSON status document.
+type Status struct {
+	UpdatedAt time.Time `json:"updated_at"`
+
+	IncrementalBlockHeight uint64 `json:"incremental_block_height"`
+	IncrementalBlockDiff   uint64 `json:"incremental_block_diff"`
+
+	FullScan FullScanStatus `json:"full_scan"`
+
+	CandidatesFound     uint64 `json:"candidates_found"`
+	CandidatesCoalesced uint64 `json:"candidates_coalesced"`
+	BatchesProcessed    uint64 `json:"batches_processed"`
+	AddressesProcessed  uint64 `json:"addresses_processed"`
+	ScriptErrors        uint64 `json:"script_errors"`
+	HandlerErrors       uint64 `json:"handler_errors"`
+
+	QueueDepths map[string]int `json:"queue_depths"`
+	// QueueHighWaterMarks are the highest depths of the queues.
+	QueueHighWaterMarks map[string]int `json:"queue_high_water_marks"`
+	BatchSize           int            `json:"batch_size,omitempty"`
+	ScriptWorkersBusy   int            `json:"script_workers_busy"`
+	ScriptWorkersLimit  int            `json:"script_workers_limit"`
+	// RateLimits are the adaptive rate limits per access node and method.
+	RateLimits map[string]map[string]float64 `json:"rate_limits,omitempty"`
+}
+
+type FullScanStatus struct {
+	Running            bool    `json:"running"`
+	Scanned            uint64  `json:"scanned"`
+	Total              uint64  `json:"total"`
+	Progress           float64 `json:"progress"`
+	AddressesPerSecond float64 `json:"addresses_per_second"`
+	ETASeconds         float64 `json:"eta_seconds"`
+	// ThrottleDelaySeconds is the delay between the batches of the full scan throttle,
+	// ThrottleLatencySeconds and ThrottleErrorRate what it last adjusted the delay for.
+	ThrottleDelaySeconds   float64 `json:"throttle_delay_seconds,omitempty"`
+	ThrottleLatencySeconds float64 `json:"throttle_latency_seconds,omitempty"`
+	ThrottleErrorRate      float64 `json:"throttle_error_rate,omitempty"`
+}
+
+// JSONReporter is a scanner.StatusReporter that periodically writes the status of the scan as JSON,
+// so other tools can poll the state of the scan.
+type JSONReporter struct {
+	*scanner.ComponentBase
+	JSONReporterConfig
+
+	mu     sync.Mutex
+	status Status
+}
+
+var _ scanner.StatusReporter = (*JSONReporter)(nil)
+var _ scanner.Component = (*JSONReporter)(nil)
+
+// NewJSONReporter creates the reporter. When it is the reporter of a scan,
+// it is started and stopped with the scan.
+func NewJSONReporter(
+	config JSONReporterConfig,
+	logger zerolog.Logger,
+) *JSONReporter {
+	r := &JSONReporter{
+		JSONReporterConfig: config,
+		status: Status{
+			QueueDepths:         map[string]int{},
+			QueueHighWaterMarks: map[string]int{},
+		},
+	}
+	r.ComponentBase = scanner.NewComponentWithStart(
+		"json_reporter",
+		r.start,
+		logger,
+	)
+	return r
+}
+
+func (r *JSONReporter) start(ctx context.Context) {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultJSONReporterInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				r.write()
+				r.Finish(ctx.Err())
+				return
+			case <-ticker.C:
+				r.write()
+			}
+		}
+	}()
+}
+
+// Status is a copy of the current status.
+func (r *JSONReporter) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := r.status
+	status.QueueDepths = make(map[string]int, len(r.status.QueueDepths))
+	for queue, depth := range r.status.QueueDepths {
+		status.QueueDepths[queue] = depth
+	}
+	status.QueueHighWaterMarks = make(map[string]int, len(r.status.QueueHighWaterMarks))
+	for queue, highWaterMark := range r.status.QueueHighWaterMarks {
+		status.QueueHighWaterMarks[queue] = highWaterMark
+	}
+	if r.status.RateLimits != nil {
+		status.RateLimits = make(map[string]map[string]float64, len(r.status.RateLimits))
+		for target, methods := range r.status.RateLimits {
+			status.RateLimits[target] = make(map[string]float64, len(methods))
+			for method, rate := range methods {
+				status.RateLimits[target][method] = rate
+			}
+		}
+	}
+	return status
+}
+
+// write writes the status. Failing to write is logged, the next interval writes again.
+func (r *JSONReporter) write() {
+	status := r.Status()
+	status.UpdatedAt = time.Now().UTC()
+	data, err := json.Marshal(status)
+	if err != nil {
+		r.Logger.Warn().Err(err).Msg("failed to encode the status")
+		return
+	}
+
+	if r.Writer != nil {
+		if _, err := r.Writer.Write(append(data, '\n')); err != nil {
+			r.Logger.Warn().Err(err).Msg("failed to write the status")
+		}
+	}
+	if r.Path != "" {
+		if err := writeFileAtomic(r.Path, data); err != nil {
+			r.Logger.Warn().Err(err).Str("path", r.Path).Msg("failed to write the status")
+		}
+	}
+}
+
+// writeFileAtomic writes the file next to path and renames it, so the file at path is always complete.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", tmp.Name(), err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (r *JSONReporter) update(f func(status *Status)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f(&r.status)
+}
+
+func (r *JSONReporter) ReportIncrementalBlockDiff(diff uint64) {
+	r.update(func(status *Status) {
+		status.IncrementalBlockDiff = diff
+	})
+}
+
+func (r *JSONReporter) ReportIncrementalBlockHeight(height uint64) {
+	r.update(func(status *Status) {
+		if height > status.IncrementalBlockHeight {
+			status.IncrementalBlockHeight = height
+		}
+	})
+}
+
+func (r *JSONReporter) ReportIsFullScanRunning(running bool) {
+	r.update(func(status *Status) {
+		status.FullScan.Running = running
+	})
+}
+
+func (r *JSONReporter) ReportFullScanProgress(current uint64, total uint64) {
+	r.update(func(status *Status) {
+		status.FullScan.Scanned = current
+		status.FullScan.Total = total
+		if total > 0 {
+			status.FullScan.Progress = float64(current) / float64(total)
+		}
+	})
+}
+
+func (r *JSONReporter) ReportFullScanThroughput(addressesPerSecond float64, eta time.Duration) {
+	r.update(func(status *Status) {
+		status.FullScan.AddressesPerSecond = addressesPerSecond
+		status.FullScan.ETASeconds = eta.Seconds()
+	})
+}
+
+func (r *JSONReporter) ReportCandidates(found int, coalesced int) {
+	r.update(func(status *Status) {
+		status.CandidatesFound += uint64(found)
+		status.CandidatesCoalesced += uint64(coalesced)
+	})
+}
+
+func (r *JSONReporter) ReportQueueDepth(queue string, depth int) {
+	r.update(func(status *Status) {
+		status.QueueDepths[queue] = depth
+	})
+}
+
+func (r *JSONReporter) ReportQueueHighWaterMark(queue string, highWaterMark int) {
+	r.update(func(status *Status) {
+		status.QueueHighWaterMarks[queue] = highWaterMark
+	})
+}
+
+func (r *JSONReporter) ReportFullScanThrottle(state scanner.FullScanThrottleState) {
+	r.update(func(status *Status) {
+		status.FullScan.ThrottleDelaySeconds = state.Delay.Seconds()
+		status.FullScan.ThrottleLatencySeconds = state.Latency.Seconds()
+		status.FullScan.ThrottleErrorRate = state.ErrorRate
+	})
+}
+
+func (r *JSONReporter) ReportBatchSize(size int) {
+	r.update(func(status *Status) {
+		status.BatchSize = size
+	})
+}
+
+func (r *JSONReporter) ReportScriptWorkers(busy int, limit int) {
+	r.update(func(status *Status) {
+		status.ScriptWorkersBusy = busy
+		status.ScriptWorkersLimit = limit
+	})
+}
+
+func (r *JSONReporter) ReportHandlerErrors(count int) {
+	r.update(func(status *Status) {
+		status.HandlerErrors += uint64(count)
+	})
+}
+
+func (r *JSONReporter) ReportRateLimit(target string, method string, rate float64) {
+	r.update(func(status *Status) {
+		if status.RateLimits == nil {
+			status.RateLimits = map[string]map[string]float64{}
+		}
+		if status.RateLimits[target] == nil {
+			status.RateLimits[target] = map[string]float64{}
+		}
+		status.RateLimits[target][method] = rate
+	})
+}
+
+func (r *JSONReporter) ReportBatchProcessed(addresses int, _ time.Duration) {
+	r.update(func(status *Status) {
+		status.BatchesProcessed++
+		status.AddressesProcessed += uint64(addresses)
+	})
+}
+
+func (r *JSONReporter) ReportScriptError() {
+	r.update(func(status *Status) {
+		status.ScriptErrors++
+	})
+}
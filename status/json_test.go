@@ -0,0 +1,70 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/status"
+)
+
+func TestJSONReporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	r := status.NewJSONReporter(status.JSONReporterConfig{
+		Path:     path,
+		Interval: time.Hour,
+	}, zerolog.Nop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	<-r.Start(ctx)
+
+	r.ReportIncrementalBlockHeight(100)
+	r.ReportQueueDepth("address_batches", 3)
+	r.ReportQueueHighWaterMark("address_batches", 8)
+	r.ReportIsFullScanRunning(true)
+	r.ReportFullScanProgress(25, 100)
+	r.ReportFullScanThroughput(5, 15*time.Second)
+	r.ReportScriptError()
+
+	// the status is written when the reporter stops
+	cancel()
+	<-r.Done()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var s status.Status
+	require.NoError(t, json.Unmarshal(data, &s))
+	require.Equal(t, uint64(100), s.IncrementalBlockHeight)
+	require.Equal(t, 3, s.QueueDepths["address_batches"])
+	require.Equal(t, 8, s.QueueHighWaterMarks["address_batches"])
+	require.Equal(t, status.FullScanStatus{
+		Running:            true,
+		Scanned:            25,
+		Total:              100,
+		Progress:           0.25,
+		AddressesPerSecond: 5,
+		ETASeconds:         15,
+	}, s.FullScan)
+	require.Equal(t, uint64(1), s.ScriptErrors)
+	require.False(t, s.UpdatedAt.IsZero())
+}
@@ -0,0 +1,356 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package status reports the status of a scan.
+package status
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+
+	scanner "github.com/onflow/flow-batch-scan"
+)
+
+type PrometheusReporterConfig struct {
+	// Namespace is the namespace of all metrics.
+	Namespace string
+	// Addr is the address the /metrics endpoint is served on. If empty, no server is started,
+	// and the metrics can be served with PrometheusReporter.Handler.
+	Addr string
+	// Gatherers are also served on the /metrics endpoint.
+	// By default this is the default registry, which has the per access node request metrics
+	// of the client (see client.WithMetrics), and the Go runtime metrics.
+	Gatherers []prometheus.Gatherer
+}
+
+func DefaultPrometheusReporterConfig() PrometheusReporterConfig {
+	return PrometheusReporterConfig{
+		Namespace: "",
+		Addr:      fmt.Sprintf(":%d", scanner.DefaultStatusReporterPort),
+		Gatherers: []prometheus.Gatherer{prometheus.DefaultGatherer},
+	}
+}
+
+// PrometheusReporter is a scanner.StatusReporter that exposes the status of the scan as Prometheus metrics.
+// Unlike scanner.DefaultStatusReporter, it registers its metrics in its own registry,
+// and serves them on its own server, so multiple reporters can run in one process.
+type PrometheusReporter struct {
+	*scanner.ComponentBase
+	PrometheusReporterConfig
+
+	registry *prometheus.Registry
+
+	mu                        sync.Mutex
+	latestReportedBlockHeight uint64
+
+	incBlockDiff        prometheus.Gauge
+	incBlockHeight      prometheus.Gauge
+	fullScanRunning     prometheus.Gauge
+	fullScanProgress    prometheus.Gauge
+	fullScanThroughput  prometheus.Gauge
+	fullScanETA         prometheus.Gauge
+	throttleDelay       prometheus.Gauge
+	throttleLatency     prometheus.Gauge
+	throttleErrorRate   prometheus.Gauge
+	candidatesFound     prometheus.Counter
+	candidatesCoalesced prometheus.Counter
+	queueDepth          *prometheus.GaugeVec
+	queueHighWaterMark  *prometheus.GaugeVec
+	batchSize           prometheus.Gauge
+	scriptWorkersBusy   prometheus.Gauge
+	scriptWorkersLimit  prometheus.Gauge
+	handlerErrors       prometheus.Counter
+	rateLimit           *prometheus.GaugeVec
+	batchesProcessed    prometheus.Counter
+	addressesProcessed  prometheus.Counter
+	batchDuration       prometheus.Histogram
+	scriptErrors        prometheus.Counter
+}
+
+var _ scanner.StatusReporter = (*PrometheusReporter)(nil)
+var _ scanner.Component = (*PrometheusReporter)(nil)
+
+// NewPrometheusReporter creates the reporter. When it is the reporter of a scan,
+// it is started and stopped with the scan.
+func NewPrometheusReporter(
+	config PrometheusReporterConfig,
+	logger zerolog.Logger,
+) *PrometheusReporter {
+	r := &PrometheusReporter{
+		PrometheusReporterConfig: config,
+		registry:                 prometheus.NewRegistry(),
+	}
+	r.ComponentBase = scanner.NewComponentWithStart(
+		"prometheus_reporter",
+		r.start,
+		logger,
+	)
+	r.initMetrics()
+	return r
+}
+
+// Registry is the registry of the metrics of the reporter, e.g. to add metrics of the result handlers.
+func (r *PrometheusReporter) Registry() *prometheus.Registry {
+	return r.registry
+}
+
+// Handler serves the metrics of the reporter and the Gatherers.
+func (r *PrometheusReporter) Handler() http.Handler {
+	gatherers := append(prometheus.Gatherers{r.registry}, r.Gatherers...)
+	return promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{})
+}
+
+func (r *PrometheusReporter) start(ctx context.Context) {
+	if r.Addr == "" {
+		go func() {
+			<-ctx.Done()
+			r.Finish(ctx.Err())
+		}()
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r.Handler())
+	server := &http.Server{
+		Addr:              r.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	r.Logger.Info().
+		Str("addr", r.Addr).
+		Msg("serving /metrics")
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			r.Logger.Error().
+				Err(err).
+				Msg("server error")
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		if err := server.Close(); err != nil {
+			r.Logger.Warn().
+				Err(err).
+				Msg("error while closing server")
+		}
+		r.Finish(ctx.Err())
+	}()
+}
+
+func (r *PrometheusReporter) initMetrics() {
+	factory := promauto.With(r.registry)
+	namespace := r.Namespace
+
+	r.incBlockDiff = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "inc_block_diff",
+		Help:      "The difference between the latest block height and the block height last handled by the incremental scanner.",
+	})
+	r.incBlockHeight = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "inc_block_height",
+		Help:      "The block height last handled by the incremental scanner.",
+	})
+	r.fullScanRunning = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "full_scan_running",
+		Help:      "1 if a full scan is running. While it is, the results are not accurate at a single block height.",
+	})
+	r.fullScanProgress = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "full_scan_progress",
+		Help:      "The progress of the running full scan (from 0 to 1).",
+	})
+	r.fullScanThroughput = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "full_scan_addresses_per_second",
+		Help:      "The number of addresses the running full scan scans per second.",
+	})
+	r.fullScanETA = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "full_scan_eta_seconds",
+		Help:      "The estimated time until the running full scan is done, at the current throughput.",
+	})
+	r.candidatesFound = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "candidates_found_total",
+		Help:      "The number of candidates found by the incremental scanner.",
+	})
+	r.candidatesCoalesced = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "candidates_coalesced_total",
+		Help:      "The number of candidates that were already waiting to be scanned, and were only scanned once.",
+	})
+	r.queueDepth = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "The number of items waiting in the internal queues.",
+	}, []string{"queue"})
+	r.queueHighWaterMark = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_high_water_mark",
+		Help:      "The highest number of items that waited in the internal queues.",
+	}, []string{"queue"})
+	r.throttleDelay = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "full_scan_throttle_delay_seconds",
+		Help:      "The delay between the full scan batches, while the access node is under pressure.",
+	})
+	r.throttleLatency = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "full_scan_throttle_latency_seconds",
+		Help:      "The average script latency the full scan throttle last adjusted its delay for.",
+	})
+	r.throttleErrorRate = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "full_scan_throttle_error_rate",
+		Help:      "The fraction of scripts that failed because of the access node, when the throttle last adjusted its delay.",
+	})
+	r.batchSize = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "batch_size",
+		Help:      "The current number of addresses per batch, if the batch size is adaptive.",
+	})
+	r.scriptWorkersBusy = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "script_workers_busy",
+		Help:      "The number of scripts that are currently running.",
+	})
+	r.scriptWorkersLimit = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "script_workers_limit",
+		Help:      "The maximum number of scripts that can run concurrently.",
+	})
+	r.handlerErrors = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "handler_errors_total",
+		Help:      "The number of result handler errors that were logged and ignored.",
+	})
+	r.rateLimit = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "rate_limit",
+		Help:      "The effective rate limit in requests per second of a method, when the rate limit is adaptive.",
+	}, []string{"target", "method"})
+	r.batchesProcessed = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "batches_processed_total",
+		Help:      "The number of batches whose result was handled.",
+	})
+	r.addressesProcessed = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "addresses_processed_total",
+		Help:      "The number of addresses whose result was handled.",
+	})
+	r.batchDuration = factory.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "batch_duration_seconds",
+		Help:      "The time it took to run the script of a batch and handle the result.",
+		Buckets:   prometheus.DefBuckets,
+	})
+	r.scriptErrors = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "script_errors_total",
+		Help:      "The number of failed script executions, including the ones that were retried.",
+	})
+}
+
+func (r *PrometheusReporter) ReportIncrementalBlockDiff(diff uint64) {
+	r.incBlockDiff.Set(float64(diff))
+}
+
+func (r *PrometheusReporter) ReportIncrementalBlockHeight(height uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.latestReportedBlockHeight >= height {
+		return
+	}
+	r.latestReportedBlockHeight = height
+	r.incBlockHeight.Set(float64(height))
+}
+
+func (r *PrometheusReporter) ReportIsFullScanRunning(running bool) {
+	if running {
+		r.fullScanRunning.Set(1)
+	} else {
+		r.fullScanRunning.Set(0)
+	}
+}
+
+func (r *PrometheusReporter) ReportFullScanProgress(current uint64, total uint64) {
+	if total == 0 {
+		return
+	}
+	r.fullScanProgress.Set(float64(current) / float64(total))
+}
+
+func (r *PrometheusReporter) ReportFullScanThroughput(addressesPerSecond float64, eta time.Duration) {
+	r.fullScanThroughput.Set(addressesPerSecond)
+	r.fullScanETA.Set(eta.Seconds())
+}
+
+func (r *PrometheusReporter) ReportCandidates(found int, coalesced int) {
+	r.candidatesFound.Add(float64(found))
+	r.candidatesCoalesced.Add(float64(coalesced))
+}
+
+func (r *PrometheusReporter) ReportQueueDepth(queue string, depth int) {
+	r.queueDepth.WithLabelValues(queue).Set(float64(depth))
+}
+
+func (r *PrometheusReporter) ReportQueueHighWaterMark(queue string, highWaterMark int) {
+	r.queueHighWaterMark.WithLabelValues(queue).Set(float64(highWaterMark))
+}
+
+func (r *PrometheusReporter) ReportFullScanThrottle(state scanner.FullScanThrottleState) {
+	r.throttleDelay.Set(state.Delay.Seconds())
+	r.throttleLatency.Set(state.Latency.Seconds())
+	r.throttleErrorRate.Set(state.ErrorRate)
+}
+
+func (r *PrometheusReporter) ReportBatchSize(size int) {
+	r.batchSize.Set(float64(size))
+}
+
+func (r *PrometheusReporter) ReportScriptWorkers(busy int, limit int) {
+	r.scriptWorkersBusy.Set(float64(busy))
+	r.scriptWorkersLimit.Set(float64(limit))
+}
+
+func (r *PrometheusReporter) ReportHandlerErrors(count int) {
+	r.handlerErrors.Add(float64(count))
+}
+
+func (r *PrometheusReporter) ReportRateLimit(target string, method string, rate float64) {
+	r.rateLimit.WithLabelValues(target, method).Set(rate)
+}
+
+func (r *PrometheusReporter) ReportBatchProcessed(addresses int, duration time.Duration) {
+	r.batchesProcessed.Inc()
+	r.addressesProcessed.Add(float64(addresses))
+	r.batchDuration.Observe(duration.Seconds())
+}
+
+func (r *PrometheusReporter) ReportScriptError() {
+	r.scriptErrors.Inc()
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status_test
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/status"
+)
+
+func TestPrometheusReporter(t *testing.T) {
+	config := status.DefaultPrometheusReporterConfig()
+	config.Namespace = "test"
+	config.Addr = ""
+	config.Gatherers = nil
+	r := status.NewPrometheusReporter(config, zerolog.Nop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	<-r.Start(ctx)
+
+	r.ReportIncrementalBlockHeight(100)
+	r.ReportIncrementalBlockHeight(90)
+	r.ReportQueueDepth("address_batches", 3)
+	r.ReportBatchProcessed(10, time.Second)
+	r.ReportScriptError()
+
+	recorder := httptest.NewRecorder()
+	r.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(recorder.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "test_inc_block_height 100\n")
+	require.Contains(t, string(body), `test_queue_depth{queue="address_batches"} 3`)
+	require.Contains(t, string(body), "test_addresses_processed_total 10\n")
+	require.Contains(t, string(body), "test_script_errors_total 1\n")
+
+	// a second reporter does not conflict with the first one
+	status.NewPrometheusReporter(config, zerolog.Nop())
+
+	cancel()
+	<-r.Done()
+	require.ErrorIs(t, r.Err(), context.Canceled)
+}
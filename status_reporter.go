@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -32,6 +33,33 @@ type StatusReporter interface {
 	ReportIncrementalBlockHeight(height uint64)
 	ReportIsFullScanRunning(running bool)
 	ReportFullScanProgress(current uint64, total uint64)
+	// ReportFullScanThroughput reports the throughput of the running full scan in addresses per second,
+	// and the estimated time until it is done.
+	ReportFullScanThroughput(addressesPerSecond float64, eta time.Duration)
+	// ReportCandidates reports the number of candidates found in a block range by the incremental scanner,
+	// and how many of those were already waiting to be scanned (and were coalesced).
+	ReportCandidates(found int, coalesced int)
+	// ReportQueueDepth reports the number of items waiting in one of the internal queues.
+	ReportQueueDepth(queue string, depth int)
+	// ReportQueueHighWaterMark reports the highest number of items that waited in one of the internal queues.
+	ReportQueueHighWaterMark(queue string, highWaterMark int)
+	// ReportBatchSize reports the current batch size, when the batch size is adaptive.
+	ReportBatchSize(size int)
+	// ReportScriptWorkers reports how many of the script workers are busy, and how many there are at most.
+	ReportScriptWorkers(busy int, limit int)
+	// ReportHandlerErrors reports ScriptResultHandler errors that were ignored.
+	ReportHandlerErrors(count int)
+	// ReportRateLimit reports the effective rate limit of a method on the access node at target,
+	// when the client uses an adaptive rate limit (see client.WithAdaptiveRateLimit).
+	ReportRateLimit(target string, method string, rate float64)
+	// ReportBatchProcessed reports a batch whose result was handled,
+	// and how long it took to run the script and handle the result.
+	ReportBatchProcessed(addresses int, duration time.Duration)
+	// ReportScriptError reports a failed script execution. The batch might still be retried.
+	ReportScriptError()
+	// ReportFullScanThrottle reports the state of the full scan throttle, when it changes its delay.
+	// See Config.WithFullScanThrottle.
+	ReportFullScanThrottle(state FullScanThrottleState)
 }
 
 type DefaultStatusReporter struct {
@@ -41,10 +69,31 @@ type DefaultStatusReporter struct {
 	port                      int
 	shouldStartServer         bool
 
-	incBlockDiff     prometheus.Gauge
-	incBlockHeight   prometheus.Counter
-	fullScanRunning  prometheus.Gauge
-	fullScanProgress prometheus.Gauge
+	incBlockDiff        prometheus.Gauge
+	incBlockHeight      prometheus.Counter
+	fullScanRunning     prometheus.Gauge
+	fullScanProgress    prometheus.Gauge
+	fullScanThroughput  prometheus.Gauge
+	fullScanETA         prometheus.Gauge
+	throttleDelay       prometheus.Gauge
+	throttleLatency     prometheus.Gauge
+	throttleErrorRate   prometheus.Gauge
+	candidatesFound     prometheus.Counter
+	candidatesCoalesced prometheus.Counter
+	queueDepth          *prometheus.GaugeVec
+	queueHighWaterMark  *prometheus.GaugeVec
+	batchSize           prometheus.Gauge
+	scriptWorkersBusy   prometheus.Gauge
+	scriptWorkersLimit  prometheus.Gauge
+	scriptWorkersUsage  prometheus.Gauge
+	handlerErrors       prometheus.Counter
+	rateLimit           *prometheus.GaugeVec
+	batchesProcessed    prometheus.Counter
+	addressesProcessed  prometheus.Counter
+	batchDuration       prometheus.Histogram
+	scriptErrors        prometheus.Counter
+	handlerCalls        *prometheus.CounterVec
+	handlerDuration     *prometheus.HistogramVec
 
 	namespace string
 }
@@ -161,6 +210,115 @@ func (r *DefaultStatusReporter) initMetrics(namespace string) {
 		Name:      "full_scan_progress",
 		Help:      "If a full scan is currently running, this is the progress of the full scan.",
 	})
+	r.fullScanThroughput = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "full_scan_addresses_per_second",
+		Help:      "The number of addresses the running full scan scans per second.",
+	})
+	r.fullScanETA = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "full_scan_eta_seconds",
+		Help:      "The estimated time until the running full scan is done, at the current throughput.",
+	})
+	r.candidatesFound = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "candidates_found_total",
+		Help:      "The number of candidates found by the incremental scanner.",
+	})
+	r.candidatesCoalesced = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "candidates_coalesced_total",
+		Help: "The number of candidates found by the incremental scanner, " +
+			"that were already waiting to be scanned and were only scanned once.",
+	})
+	r.queueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "The number of items waiting in the internal queues.",
+	}, []string{"queue"})
+	r.queueHighWaterMark = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_high_water_mark",
+		Help:      "The highest number of items that waited in the internal queues.",
+	}, []string{"queue"})
+	r.throttleDelay = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "full_scan_throttle_delay_seconds",
+		Help:      "The delay between the full scan batches, while the access node is under pressure.",
+	})
+	r.throttleLatency = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "full_scan_throttle_latency_seconds",
+		Help:      "The average script latency the full scan throttle last adjusted its delay for.",
+	})
+	r.throttleErrorRate = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "full_scan_throttle_error_rate",
+		Help:      "The fraction of scripts that failed because of the access node, when the throttle last adjusted its delay.",
+	})
+	r.batchSize = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "batch_size",
+		Help:      "The current number of addresses per batch, if the batch size is adaptive.",
+	})
+	r.scriptWorkersBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "script_workers_busy",
+		Help:      "The number of scripts that are currently running.",
+	})
+	r.scriptWorkersLimit = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "script_workers_limit",
+		Help:      "The maximum number of scripts that can run concurrently.",
+	})
+	r.scriptWorkersUsage = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "script_workers_utilization",
+		Help: "The fraction of the script workers that are busy (from 0 to 1). " +
+			"If this is always 1, more concurrent scripts could speed up the scan, if the access node allows it.",
+	})
+	r.handlerErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "handler_errors_total",
+		Help:      "The number of result handler errors that were logged and ignored.",
+	})
+	r.rateLimit = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "rate_limit",
+		Help:      "The effective rate limit in requests per second of a method, when the rate limit is adaptive.",
+	}, []string{"target", "method"})
+	r.batchesProcessed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "batches_processed_total",
+		Help:      "The number of batches whose result was handled.",
+	})
+	r.addressesProcessed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "addresses_processed_total",
+		Help:      "The number of addresses whose result was handled.",
+	})
+	r.batchDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "batch_duration_seconds",
+		Help:      "The time it took to run the script of a batch and handle the result.",
+		Buckets:   prometheus.DefBuckets,
+	})
+	r.scriptErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "script_errors_total",
+		Help:      "The number of failed script executions, including the ones that were retried.",
+	})
+	r.handlerCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "handler_calls_total",
+		Help:      "The number of calls of the result handlers wrapped with handlers.WithMetrics, by result (ok or error).",
+	}, []string{"handler", "result"})
+	r.handlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "handler_call_duration_seconds",
+		Help:      "The time it took the result handlers wrapped with handlers.WithMetrics to handle a batch.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"handler"})
 }
 
 func (r *DefaultStatusReporter) ReportIncrementalBlockDiff(diff uint64) {
@@ -188,3 +346,67 @@ func (r *DefaultStatusReporter) ReportFullScanProgress(current uint64, total uin
 	progress := float64(current) / float64(total)
 	r.fullScanProgress.Set(progress)
 }
+
+func (r *DefaultStatusReporter) ReportFullScanThroughput(addressesPerSecond float64, eta time.Duration) {
+	r.fullScanThroughput.Set(addressesPerSecond)
+	r.fullScanETA.Set(eta.Seconds())
+}
+
+func (r *DefaultStatusReporter) ReportCandidates(found int, coalesced int) {
+	r.candidatesFound.Add(float64(found))
+	r.candidatesCoalesced.Add(float64(coalesced))
+}
+
+func (r *DefaultStatusReporter) ReportQueueDepth(queue string, depth int) {
+	r.queueDepth.WithLabelValues(queue).Set(float64(depth))
+}
+
+func (r *DefaultStatusReporter) ReportQueueHighWaterMark(queue string, highWaterMark int) {
+	r.queueHighWaterMark.WithLabelValues(queue).Set(float64(highWaterMark))
+}
+
+func (r *DefaultStatusReporter) ReportFullScanThrottle(state FullScanThrottleState) {
+	r.throttleDelay.Set(state.Delay.Seconds())
+	r.throttleLatency.Set(state.Latency.Seconds())
+	r.throttleErrorRate.Set(state.ErrorRate)
+}
+
+func (r *DefaultStatusReporter) ReportBatchSize(size int) {
+	r.batchSize.Set(float64(size))
+}
+
+func (r *DefaultStatusReporter) ReportScriptWorkers(busy int, limit int) {
+	r.scriptWorkersBusy.Set(float64(busy))
+	r.scriptWorkersLimit.Set(float64(limit))
+	if limit > 0 {
+		r.scriptWorkersUsage.Set(float64(busy) / float64(limit))
+	}
+}
+
+func (r *DefaultStatusReporter) ReportHandlerErrors(count int) {
+	r.handlerErrors.Add(float64(count))
+}
+
+func (r *DefaultStatusReporter) ReportRateLimit(target string, method string, rate float64) {
+	r.rateLimit.WithLabelValues(target, method).Set(rate)
+}
+
+func (r *DefaultStatusReporter) ReportBatchProcessed(addresses int, duration time.Duration) {
+	r.batchesProcessed.Inc()
+	r.addressesProcessed.Add(float64(addresses))
+	r.batchDuration.Observe(duration.Seconds())
+}
+
+func (r *DefaultStatusReporter) ReportScriptError() {
+	r.scriptErrors.Inc()
+}
+
+// ReportHandlerCall reports a call of a result handler wrapped with handlers.WithMetrics.
+func (r *DefaultStatusReporter) ReportHandlerCall(handler string, _ int, duration time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	r.handlerCalls.WithLabelValues(handler, result).Inc()
+	r.handlerDuration.WithLabelValues(handler).Observe(duration.Seconds())
+}
@@ -0,0 +1,198 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultStatusServerStallTimeout is how long the incremental scanner can go without progress,
+// before the status server reports the scan as unhealthy.
+const DefaultStatusServerStallTimeout = 5 * time.Minute
+
+// statusServerCheckInterval is how often the status server checks the progress of the incremental scanner,
+// or more often for short stall timeouts.
+const statusServerCheckInterval = time.Second
+
+// ScanStatus is the state of a running scan, as served on /status of the status server.
+type ScanStatus struct {
+	// Ready is true once the scan started, and while it is not paused.
+	Ready bool `json:"ready"`
+	// Healthy is false if the incremental scanner made no progress for the stall timeout.
+	Healthy bool `json:"healthy"`
+	Paused  bool `json:"paused"`
+
+	// IncrementalScanRunning is false for point in time and non-continuous scans.
+	IncrementalScanRunning bool      `json:"incremental_scan_running"`
+	IncrementalBlockHeight uint64    `json:"incremental_block_height"`
+	IncrementalProgressAt  time.Time `json:"incremental_progress_at"`
+
+	// FullScan is the progress of the running full scan, if there is one.
+	FullScan *FullScanProgress `json:"full_scan,omitempty"`
+	// Queues are the internal queues of the scan, in the order of the pipeline.
+	Queues []QueueStats `json:"queues"`
+
+	AddressesScanned uint64        `json:"addresses_scanned"`
+	BatchesExecuted  uint64        `json:"batches_executed"`
+	Retries          uint64        `json:"retries"`
+	ScriptErrors     uint64        `json:"script_errors"`
+	FailedBatches    int           `json:"failed_batches"`
+	HandlerErrors    uint64        `json:"handler_errors"`
+	Uptime           time.Duration `json:"uptime"`
+}
+
+// statusServer serves the health and the status of the scan over HTTP:
+// /healthz for liveness probes, /readyz for readiness probes, and /status with the ScanStatus as JSON.
+type statusServer struct {
+	*ComponentBase
+
+	addr         string
+	stallTimeout time.Duration
+	// status returns the current status, without the health.
+	status func() ScanStatus
+
+	mu             sync.Mutex
+	started        bool
+	progressHeight uint64
+	progressAt     time.Time
+}
+
+var _ Component = (*statusServer)(nil)
+
+func newStatusServer(
+	addr string,
+	stallTimeout time.Duration,
+	status func() ScanStatus,
+	logger zerolog.Logger,
+) *statusServer {
+	s := &statusServer{
+		addr:         addr,
+		stallTimeout: stallTimeout,
+		status:       status,
+	}
+	s.ComponentBase = NewComponentWithStart(
+		"status_server",
+		s.start,
+		logger,
+	)
+	return s
+}
+
+func (s *statusServer) start(ctx context.Context) {
+	s.mu.Lock()
+	s.started = true
+	s.progressAt = time.Now()
+	s.mu.Unlock()
+
+	server := &http.Server{
+		Addr:              s.addr,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	s.Logger.Info().
+		Str("addr", s.addr).
+		Msg("serving /healthz, /readyz and /status")
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.Logger.Error().
+				Err(err).
+				Msg("status server error")
+		}
+	}()
+
+	go func() {
+		interval := statusServerCheckInterval
+		if s.stallTimeout > 0 && s.stallTimeout/10 < interval {
+			interval = s.stallTimeout / 10
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				if err := server.Close(); err != nil {
+					s.Logger.Warn().
+						Err(err).
+						Msg("error while closing status server")
+				}
+				s.Finish(ctx.Err())
+				return
+			case <-ticker.C:
+				s.checkProgress()
+			}
+		}
+	}()
+}
+
+func (s *statusServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		s.writeCheck(w, s.currentStatus().Healthy)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		s.writeCheck(w, s.currentStatus().Ready)
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s.currentStatus()); err != nil {
+			s.Logger.Debug().
+				Err(err).
+				Msg("failed to write status")
+		}
+	})
+	return mux
+}
+
+func (s *statusServer) writeCheck(w http.ResponseWriter, ok bool) {
+	if !ok {
+		http.Error(w, "not ok", http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// checkProgress records when the block height of the incremental scanner last changed.
+// A paused scan is not expected to make progress, so it does not stall.
+func (s *statusServer) checkProgress() {
+	status := s.status()
+	height := status.IncrementalBlockHeight
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if height != s.progressHeight || status.Paused {
+		s.progressHeight = height
+		s.progressAt = time.Now()
+	}
+}
+
+func (s *statusServer) currentStatus() ScanStatus {
+	status := s.status()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status.Ready = s.started && !status.Paused
+	status.IncrementalProgressAt = s.progressAt
+	status.Healthy = !status.IncrementalScanRunning ||
+		status.Paused ||
+		s.stallTimeout <= 0 ||
+		time.Since(s.progressAt) < s.stallTimeout
+	return status
+}
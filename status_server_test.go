@@ -0,0 +1,77 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusServer(t *testing.T) {
+	height := atomic.Uint64{}
+	paused := atomic.Bool{}
+	s := newStatusServer("127.0.0.1:0", 200*time.Millisecond, func() ScanStatus {
+		return ScanStatus{
+			Paused:                 paused.Load(),
+			IncrementalScanRunning: true,
+			IncrementalBlockHeight: height.Load(),
+		}
+	}, zerolog.Nop())
+	handler := s.handler()
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	// not started yet
+	require.Equal(t, http.StatusServiceUnavailable, get("/readyz").Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	<-s.Start(ctx)
+	require.Equal(t, http.StatusOK, get("/readyz").Code)
+	require.Equal(t, http.StatusOK, get("/healthz").Code)
+
+	// the incremental scanner makes progress
+	for i := 0; i < 3; i++ {
+		height.Add(1)
+		time.Sleep(150 * time.Millisecond)
+	}
+	require.Equal(t, http.StatusOK, get("/healthz").Code)
+
+	// the incremental scanner stalls
+	require.Eventually(t, func() bool {
+		return get("/healthz").Code == http.StatusServiceUnavailable
+	}, 5*time.Second, 50*time.Millisecond)
+
+	var status ScanStatus
+	require.NoError(t, json.Unmarshal(get("/status").Body.Bytes(), &status))
+	require.Equal(t, uint64(3), status.IncrementalBlockHeight)
+	require.False(t, status.Healthy)
+
+	// a paused scan is not stalled, but it is not ready
+	paused.Store(true)
+	require.Equal(t, http.StatusOK, get("/healthz").Code)
+	require.Equal(t, http.StatusServiceUnavailable, get("/readyz").Code)
+}
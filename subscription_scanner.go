@@ -0,0 +1,173 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package scanner
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/onflow/flow-batch-scan/candidates"
+)
+
+// IncrementalScannerMode selects how the incremental scanner follows new blocks.
+type IncrementalScannerMode int
+
+const (
+	// IncrementalScannerPolling polls for the latest block every IncrementalScanInterval,
+	// and runs the CandidateScanners on the new block range. This is the default.
+	IncrementalScannerPolling IncrementalScannerMode = iota
+	// IncrementalScannerSubscription subscribes to the SubscriptionEvents of every new block,
+	// and gets the candidates from those events. The CandidateScanners are not used.
+	// This has lower latency and makes fewer requests than polling,
+	// but the access node needs to have the execution data API enabled.
+	IncrementalScannerSubscription
+)
+
+const SubscriptionScannerName = "subscription_scanner"
+
+// errEventStreamClosed is the error of an event stream the access node closed.
+var errEventStreamClosed = errors.New("event stream closed")
+
+// runSubscription follows new blocks using an event subscription.
+// If the subscription fails with a transient error it is restarted after the last handled block,
+// other errors, e.g. of events that can not be decoded, fail the scan like in polling mode.
+func (r *IncrementalScanner) runSubscription(ctx context.Context) {
+	for {
+		err := r.followSubscription(ctx)
+		if ctx.Err() != nil {
+			r.Finish(ctx.Err())
+			return
+		}
+		if !isTransientSubscriptionError(err) {
+			r.Finish(err)
+			return
+		}
+		r.Logger.Warn().
+			Err(err).
+			Uint64("latest_block", r.latestBlock).
+			Msg("event subscription failed, resubscribing")
+
+		select {
+		case <-ctx.Done():
+			r.Finish(ctx.Err())
+			return
+		case <-time.After(r.interval()):
+		}
+	}
+}
+
+// isTransientSubscriptionError returns true if the subscription can be restarted after the error:
+// a closed stream, or an access node that was unavailable or rate limited.
+func isTransientSubscriptionError(err error) bool {
+	if errors.Is(err, errEventStreamClosed) {
+		return true
+	}
+	class := ClassifyError(err)
+	return class == ErrTransient || class == ErrRateLimited
+}
+
+func (r *IncrementalScanner) followSubscription(ctx context.Context) error {
+	if r.latestBlock == 0 {
+		header, err := r.client.GetLatestBlockHeader(ctx, true)
+		if err != nil {
+			return err
+		}
+		r.latestBlock = header.Height
+		r.requestFullScan <- r.latestBlock
+	}
+
+	eventTypes := make([]string, 0, len(r.SubscriptionEvents))
+	for eventType := range r.SubscriptionEvents {
+		eventTypes = append(eventTypes, eventType)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events, errs, err := r.client.SubscribeEvents(ctx, r.latestBlock+1, eventTypes)
+	if err != nil {
+		return err
+	}
+	r.Logger.Info().
+		Uint64("start", r.latestBlock+1).
+		Strs("event_types", eventTypes).
+		Msg("subscribed to events")
+
+	// the progress is saved every scan interval, like in polling mode
+	progressInterval := r.interval()
+	progress := time.NewTicker(progressInterval)
+	defer progress.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err, ok := <-errs:
+			if !ok {
+				return errEventStreamClosed
+			}
+			return err
+		case blockEvents, ok := <-events:
+			if !ok {
+				return errEventStreamClosed
+			}
+			if blockEvents.Height <= r.latestBlock {
+				continue
+			}
+			if err := r.pause.wait(ctx); err != nil {
+				return err
+			}
+			r.reporter.ReportIncrementalBlockDiff(blockEvents.Height - r.latestBlock)
+
+			candidatesResult := candidates.CandidatesFromBlockEvents(
+				blockEvents,
+				r.SubscriptionEvents,
+				SubscriptionScannerName,
+			)
+			if candidatesResult.Err() != nil {
+				return newBlockRangeError(r.latestBlock+1, blockEvents.Height, candidatesResult.Err())
+			}
+			r.stats.candidatesFound(candidatesResult, 0)
+			r.handleSubscribedCandidates(ctx, candidatesResult, r.latestBlock+1, blockEvents.Height)
+			r.latestBlock = blockEvents.Height
+		case done := <-r.flushRequests:
+			r.flush(done)
+		case <-progress.C:
+			r.saveProgress(ctx)
+			if interval := r.interval(); interval != progressInterval {
+				progressInterval = interval
+				progress.Reset(interval)
+			}
+		}
+	}
+}
+
+// handleSubscribedCandidates handles the candidates of the streamed blocks in a span of the block range.
+func (r *IncrementalScanner) handleSubscribedCandidates(
+	ctx context.Context,
+	candidatesResult candidates.CandidatesResult,
+	start uint64,
+	end uint64,
+) {
+	ctx, span := startSpan(ctx, r.tracer, "scan_block_range",
+		attribute.Int64("start_height", int64(start)),
+		attribute.Int64("end_height", int64(end)),
+		attribute.Int("candidates", len(candidatesResult.Addresses)),
+	)
+	defer span.End()
+	r.handleCandidates(ctx, candidatesResult, start, end)
+}
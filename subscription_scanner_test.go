@@ -0,0 +1,73 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/onflow/flow-batch-scan/client"
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+)
+
+func TestIsTransientSubscriptionError(t *testing.T) {
+	require.True(t, isTransientSubscriptionError(errEventStreamClosed))
+	require.True(t, isTransientSubscriptionError(status.Error(codes.Unavailable, "connection reset")))
+	require.True(t, isTransientSubscriptionError(status.Error(codes.ResourceExhausted, "rate limited")))
+	require.False(t, isTransientSubscriptionError(status.Error(codes.Unimplemented, "execution data API disabled")))
+	require.False(t, isTransientSubscriptionError(
+		newBlockRangeError(10, 10, fmt.Errorf("could not decode event"))))
+}
+
+func TestIncrementalScanner_SubscriptionFailsOnPermanentErrors(t *testing.T) {
+	c := clienttest.New()
+	c.AddBlocks(1, 10)
+	c.SetError(client.MethodSubscribeEvents, status.Error(codes.Unimplemented, "execution data API disabled"))
+
+	config := DefaultIncrementalScannerConfig()
+	config.IncrementalScannerMode = IncrementalScannerSubscription
+	config.IncrementalStartHeight = 5
+	config.SubscriptionEvents = map[string]func(event cadence.Event) ([]flow.Address, error){
+		"A.0000000000000001.Contract.Event": func(cadence.Event) ([]flow.Address, error) { return nil, nil },
+	}
+	scanner := NewIncrementalScanner(
+		c,
+		make(chan AddressBatch),
+		make(chan uint64),
+		100,
+		config,
+		NoOpStatusReporter{},
+		zerolog.Nop(),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	scanner.Start(ctx)
+	select {
+	case <-scanner.Done():
+	case <-ctx.Done():
+		t.Fatal("the scanner kept restarting the subscription")
+	}
+	require.Equal(t, codes.Unimplemented, status.Code(scanner.Err()))
+}
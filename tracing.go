@@ -0,0 +1,68 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer of the scan spans.
+const TracerName = "github.com/onflow/flow-batch-scan"
+
+// newTracer returns the tracer of the scan. Without a provider, no spans are recorded.
+func newTracer(provider trace.TracerProvider) trace.Tracer {
+	if provider == nil {
+		provider = trace.NewNoopTracerProvider()
+	}
+	return provider.Tracer(TracerName)
+}
+
+// startSpan starts a span with the tracer. The tracer can be nil, e.g. for components that were not wired to a scan.
+func startSpan(
+	ctx context.Context,
+	tracer trace.Tracer,
+	name string,
+	attributes ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	if tracer == nil {
+		tracer = newTracer(nil)
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// endSpan ends the span, and marks it as failed if err is set.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// batchAttributes describe a batch on its spans.
+func batchAttributes(batch AddressBatch) []attribute.KeyValue {
+	attributes := []attribute.KeyValue{
+		attribute.Int64("block_height", int64(batch.BlockHeight)),
+		attribute.Int("addresses", len(batch.Addresses)),
+	}
+	if batch.ScriptName != "" {
+		attributes = append(attributes, attribute.String("script_name", batch.ScriptName))
+	}
+	return attributes
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/onflow/flow-batch-scan/client/clienttest"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := newTracer(provider)
+
+	c := clienttest.New()
+	c.AddBlock(10)
+	c.HandleScripts(func(uint64, []byte, []cadence.Value) (cadence.Value, error) {
+		return cadence.NewArray(nil), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	batches := make(chan AddressBatch, 1)
+	results := make(chan ProcessedAddressBatch, 1)
+	runner := NewScriptRunner(c, batches, results, DefaultScriptRunnerConfig(), zerolog.Nop())
+	runner.tracer = tracer
+	processor := NewScriptResultProcessor(results, batches, NoOpScriptResultHandler{}, DefaultScriptResultProcessorConfig(), zerolog.Nop())
+	processor.tracer = tracer
+	<-runner.Start(ctx)
+	<-processor.Start(ctx)
+
+	rangeCtx, rangeSpan := startSpan(ctx, tracer, "scan_block_range")
+	done := make(chan struct{})
+	batch := NewAddressBatch([]flow.Address{flow.HexToAddress("01")}, 10, func() { close(done) }, nil)
+	batch.spanContext = trace.SpanContextFromContext(rangeCtx)
+	rangeSpan.End()
+	batches <- batch
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "batch was not handled")
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	require.Contains(t, spans, "execute_script")
+	require.Contains(t, spans, "handle_result")
+	require.Equal(t, spans["scan_block_range"].SpanContext().SpanID(), spans["execute_script"].Parent().SpanID())
+	require.Equal(t, spans["execute_script"].SpanContext().SpanID(), spans["handle_result"].Parent().SpanID())
+	require.Equal(t, spans["scan_block_range"].SpanContext().TraceID(), spans["handle_result"].SpanContext().TraceID())
+}
@@ -0,0 +1,172 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+)
+
+// tuning are the settings that can be changed while the scan is running,
+// e.g. to tune the throughput of a continuous scan without restarting it and losing its state.
+type tuning struct {
+	mu                      sync.Mutex
+	batchSize               int
+	minBatchSize            int
+	incrementalScanInterval time.Duration
+	// chainID is the configured chain ID, or the chain ID detected from the access node.
+	chainID flow.ChainID
+	// batchSizeController is the batch size controller of the running scan.
+	batchSizeController *batchSizeController
+
+	logLevel *levelHook
+}
+
+func newTuning(config Config) *tuning {
+	return &tuning{
+		batchSize:               config.BatchSize,
+		minBatchSize:            config.MinBatchSize,
+		incrementalScanInterval: config.IncrementalScanInterval,
+		chainID:                 config.ChainID,
+		logLevel:                newLevelHook(config.Logger.GetLevel()),
+	}
+}
+
+// newBatchSizeController creates the batch size controller of a scan.
+// A batch size that is not adaptive only changes with Scanner.SetBatchSize.
+func (t *tuning) newBatchSizeController(adaptive bool, reporter StatusReporter) *batchSizeController {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.batchSizeController = newBatchSizeController(t.batchSize, t.minBatchSize, adaptive, reporter)
+	return t.batchSizeController
+}
+
+// apply sets the tuned settings in the config.
+func (t *tuning) apply(config *Config) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	config.BatchSize = t.batchSize
+	config.MinBatchSize = t.minBatchSize
+	config.IncrementalScanInterval = t.incrementalScanInterval
+	config.ChainID = t.chainID
+}
+
+func (t *tuning) getChainID() flow.ChainID {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.chainID
+}
+
+func (t *tuning) setChainID(chainID flow.ChainID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.chainID = chainID
+}
+
+func (t *tuning) getBatchSize() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.batchSize
+}
+
+func (t *tuning) getIncrementalScanInterval() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.incrementalScanInterval
+}
+
+// SetBatchSize changes the batch size, also of the running scan.
+// With AdaptiveBatchSize the batch size adapts from the new batch size.
+func (scanner *Scanner) SetBatchSize(batchSize int) error {
+	if batchSize <= 0 {
+		return fmt.Errorf("the batch size must be positive, got %d", batchSize)
+	}
+	t := scanner.tuning
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.batchSize = batchSize
+	if t.minBatchSize > batchSize {
+		t.minBatchSize = batchSize
+	}
+	if t.batchSizeController != nil {
+		t.batchSizeController.resize(batchSize)
+	}
+	scanner.logger.Info().
+		Int("batch_size", batchSize).
+		Msg("Changed the batch size")
+	return nil
+}
+
+// SetIncrementalScanInterval changes how often the incremental scanner checks for new blocks,
+// also of the running scan. The new interval applies after the current wait.
+func (scanner *Scanner) SetIncrementalScanInterval(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("the incremental scan interval must be positive, got %s", interval)
+	}
+	t := scanner.tuning
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.incrementalScanInterval = interval
+	scanner.logger.Info().
+		Dur("interval", interval).
+		Msg("Changed the incremental scan interval")
+	return nil
+}
+
+// SetLogLevel changes the level of the scan logger. A disabled logger stays disabled.
+// The global level of zerolog still applies, see zerolog.SetGlobalLevel.
+func (scanner *Scanner) SetLogLevel(level zerolog.Level) {
+	scanner.tuning.logLevel.set(level)
+	scanner.logger.Info().
+		Str("level", level.String()).
+		Msg("Changed the log level")
+}
+
+// levelHook discards the log events below its level, so the level can change while the logger is in use.
+type levelHook struct {
+	level atomic.Int32
+}
+
+var _ zerolog.Hook = (*levelHook)(nil)
+
+func newLevelHook(level zerolog.Level) *levelHook {
+	h := &levelHook{}
+	h.set(level)
+	return h
+}
+
+func (h *levelHook) set(level zerolog.Level) {
+	h.level.Store(int32(level))
+}
+
+func (h *levelHook) Run(e *zerolog.Event, level zerolog.Level, _ string) {
+	if level < zerolog.Level(h.level.Load()) {
+		e.Discard()
+	}
+}
+
+// hook returns the logger with a level that can be changed by the hook.
+// The logger logs everything, the hook discards the events below its level.
+func (h *levelHook) hook(logger zerolog.Logger) zerolog.Logger {
+	if logger.GetLevel() == zerolog.Disabled {
+		return logger
+	}
+	return logger.Level(zerolog.TraceLevel).Hook(h)
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRUSet is a set that holds at most size keys. When it is full, adding a key evicts the least recently added or
+// seen key. It is safe for concurrent use.
+type LRUSet[K comparable] struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[K]*list.Element
+}
+
+// NewLRUSet returns an LRUSet that holds at most size keys. A size below 1 is treated as 1.
+func NewLRUSet[K comparable](size int) *LRUSet[K] {
+	if size < 1 {
+		size = 1
+	}
+	return &LRUSet[K]{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[K]*list.Element, size),
+	}
+}
+
+// Add adds the key to the set, and returns true if it was not in the set yet.
+// If the key was already in the set, it is marked as recently seen.
+func (s *LRUSet[K]) Add(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		s.order.MoveToFront(element)
+		return false
+	}
+	s.entries[key] = s.order.PushFront(key)
+	if s.order.Len() > s.size {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(K))
+	}
+	return true
+}
+
+// Contains returns true if the key is in the set, without marking it as recently seen.
+func (s *LRUSet[K]) Contains(key K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.entries[key]
+	return ok
+}
+
+// Len returns the number of keys in the set.
+func (s *LRUSet[K]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
@@ -0,0 +1,41 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/utils"
+)
+
+func TestLRUSet_Add(t *testing.T) {
+	s := utils.NewLRUSet[int](2)
+
+	require.True(t, s.Add(1))
+	require.True(t, s.Add(2))
+	require.False(t, s.Add(1))
+
+	// 2 is the least recently seen key, so it is evicted
+	require.True(t, s.Add(3))
+	require.Equal(t, 2, s.Len())
+	require.True(t, s.Contains(1))
+	require.False(t, s.Contains(2))
+	require.True(t, s.Contains(3))
+
+	require.True(t, s.Add(2))
+	require.False(t, s.Contains(1))
+}
@@ -0,0 +1,68 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"sync"
+)
+
+// DynamicSemaphore is a semaphore whose limit can change over time.
+// The limit is checked every time a slot is acquired. If the limit is lowered
+// the slots that are already in use are not revoked.
+type DynamicSemaphore struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	inUse int
+	limit func() int
+}
+
+// NewDynamicSemaphore creates a new semaphore. The limit function should always return at least 1.
+func NewDynamicSemaphore(limit func() int) *DynamicSemaphore {
+	s := &DynamicSemaphore{
+		limit: limit,
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// Acquire blocks until a slot is available.
+func (s *DynamicSemaphore) Acquire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.inUse >= s.limit() {
+		s.cond.Wait()
+	}
+	s.inUse++
+}
+
+// Release frees a previously acquired slot.
+func (s *DynamicSemaphore) Release() {
+	s.mu.Lock()
+	s.inUse--
+	s.mu.Unlock()
+	s.cond.Broadcast()
+}
+
+// InUse returns the number of slots currently acquired.
+func (s *DynamicSemaphore) InUse() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inUse
+}
+
+// Limit returns the current limit.
+func (s *DynamicSemaphore) Limit() int {
+	return s.limit()
+}
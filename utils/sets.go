@@ -23,3 +23,19 @@ func MergeInto[K comparable, V any](dest map[K]V, from map[K]V) map[K]V {
 	}
 	return dest
 }
+
+// CloneSlice returns a copy of the slice, or nil if it is nil.
+func CloneSlice[T any](s []T) []T {
+	if s == nil {
+		return nil
+	}
+	return append(make([]T, 0, len(s)), s...)
+}
+
+// CloneMap returns a copy of the map, or nil if it is nil.
+func CloneMap[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return nil
+	}
+	return MergeInto(make(map[K]V, len(m)), m)
+}
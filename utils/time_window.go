@@ -0,0 +1,48 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"time"
+)
+
+// TimeOfDayWindow is a window of time that repeats every day (in UTC).
+// Start and End are offsets from midnight. Start is inclusive, End is exclusive.
+// If End is before Start, the window wraps around midnight (e.g. 22:00-06:00).
+type TimeOfDayWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+func NewTimeOfDayWindow(startHour, endHour int) TimeOfDayWindow {
+	return TimeOfDayWindow{
+		Start: time.Duration(startHour) * time.Hour,
+		End:   time.Duration(endHour) * time.Hour,
+	}
+}
+
+// Contains returns true if the time of day of t is within the window.
+func (w TimeOfDayWindow) Contains(t time.Time) bool {
+	t = t.UTC()
+	offset := time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second +
+		time.Duration(t.Nanosecond())
+
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
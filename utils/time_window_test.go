@@ -0,0 +1,56 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/onflow/flow-batch-scan/utils"
+)
+
+func TestTimeOfDayWindow_Contains(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2023, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	t.Run("window within a day", func(t *testing.T) {
+		w := utils.NewTimeOfDayWindow(2, 6)
+
+		require.False(t, w.Contains(at(1, 59)))
+		require.True(t, w.Contains(at(2, 0)))
+		require.True(t, w.Contains(at(5, 59)))
+		require.False(t, w.Contains(at(6, 0)))
+	})
+
+	t.Run("window wrapping around midnight", func(t *testing.T) {
+		w := utils.NewTimeOfDayWindow(22, 6)
+
+		require.True(t, w.Contains(at(23, 0)))
+		require.True(t, w.Contains(at(0, 0)))
+		require.True(t, w.Contains(at(5, 59)))
+		require.False(t, w.Contains(at(6, 0)))
+		require.False(t, w.Contains(at(12, 0)))
+	})
+
+	t.Run("time is compared in UTC", func(t *testing.T) {
+		w := utils.NewTimeOfDayWindow(2, 6)
+		zone := time.FixedZone("UTC+2", 2*60*60)
+
+		require.True(t, w.Contains(time.Date(2023, 1, 1, 6, 0, 0, 0, zone)))
+	})
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// The pipeline stages watched by the watchdog.
+const (
+	IncrementalScannerStage = "incremental_scanner"
+	FullScanStage           = "full_scan"
+)
+
+// watchedStage is a pipeline stage that records heartbeats while it makes progress.
+type watchedStage struct {
+	name string
+	// lastHeartbeat returns the time of the last heartbeat,
+	// and false if the stage is not expected to make progress, e.g. because it is paused or not running.
+	lastHeartbeat func() (time.Time, bool)
+}
+
+// watchdog detects pipeline stages that made no progress for the stall timeout.
+// Stalls are logged and passed to the OnStall hook, and fail the scan with ErrStalled if failOnStall is set.
+type watchdog struct {
+	*ComponentBase
+
+	stallTimeout time.Duration
+	failOnStall  bool
+	stages       []watchedStage
+	hooks        ScanHooks
+
+	// stalled are the stages that are currently stalled, so each stall is only reported once.
+	stalled map[string]bool
+	// inactiveAt is when the stages were last seen inactive.
+	// Stages are not expected to make progress before that.
+	inactiveAt map[string]time.Time
+}
+
+var _ Component = (*watchdog)(nil)
+
+func newWatchdog(
+	stallTimeout time.Duration,
+	failOnStall bool,
+	stages []watchedStage,
+	hooks ScanHooks,
+	logger zerolog.Logger,
+) *watchdog {
+	w := &watchdog{
+		stallTimeout: stallTimeout,
+		failOnStall:  failOnStall,
+		stages:       stages,
+		hooks:        hooks,
+		stalled:      make(map[string]bool),
+		inactiveAt:   make(map[string]time.Time),
+	}
+	w.ComponentBase = NewComponentWithStart(
+		"watchdog",
+		w.start,
+		logger,
+	)
+	return w
+}
+
+func (w *watchdog) start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.checkInterval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				w.Finish(ctx.Err())
+				return
+			case <-ticker.C:
+				if err := w.check(time.Now()); err != nil {
+					w.Finish(err)
+					return
+				}
+			}
+		}
+	}()
+}
+
+// checkInterval is a tenth of the stall timeout, so stalls are detected at most 10% late.
+func (w *watchdog) checkInterval() time.Duration {
+	interval := w.stallTimeout / 10
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+	return interval
+}
+
+// check reports the stages that made no progress for the stall timeout.
+// It returns ErrStalled if a stage stalled and failOnStall is set.
+func (w *watchdog) check(now time.Time) error {
+	for _, stage := range w.stages {
+		last, active := stage.lastHeartbeat()
+		if !active {
+			w.inactiveAt[stage.name] = now
+		}
+		if inactiveAt := w.inactiveAt[stage.name]; last.Before(inactiveAt) {
+			last = inactiveAt
+		}
+		since := now.Sub(last)
+		if since < w.stallTimeout {
+			if w.stalled[stage.name] {
+				w.Logger.Info().
+					Str("stage", stage.name).
+					Msg("stage is making progress again")
+			}
+			delete(w.stalled, stage.name)
+			continue
+		}
+		if w.stalled[stage.name] {
+			continue
+		}
+		w.stalled[stage.name] = true
+
+		w.Logger.Error().
+			Str("stage", stage.name).
+			Dur("since_last_progress", since).
+			Dur("stall_timeout", w.stallTimeout).
+			Msg("stage stalled")
+		w.hooks.stalled(stage.name, since)
+		if w.failOnStall {
+			return fmt.Errorf("%w: %s made no progress for %s", ErrStalled, stage.name, since)
+		}
+	}
+	return nil
+}
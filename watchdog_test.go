@@ -0,0 +1,84 @@
+// Copyright 2023 Dapper Labs, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchdog_Check(t *testing.T) {
+	start := time.Now()
+	last := start
+	active := true
+	var stalls []string
+	w := newWatchdog(
+		time.Minute,
+		false,
+		[]watchedStage{{
+			name: IncrementalScannerStage,
+			lastHeartbeat: func() (time.Time, bool) {
+				return last, active
+			},
+		}},
+		ScanHooks{OnStall: func(stage string, _ time.Duration) {
+			stalls = append(stalls, stage)
+		}},
+		zerolog.Nop(),
+	)
+
+	require.NoError(t, w.check(start.Add(30*time.Second)))
+	require.Empty(t, stalls)
+
+	// stalls are reported once
+	require.NoError(t, w.check(start.Add(2*time.Minute)))
+	require.NoError(t, w.check(start.Add(3*time.Minute)))
+	require.Equal(t, []string{IncrementalScannerStage}, stalls)
+
+	// progress clears the stall
+	last = start.Add(3 * time.Minute)
+	require.NoError(t, w.check(start.Add(3*time.Minute)))
+
+	// inactive stages do not stall, and do not stall right after they become active again
+	active = false
+	require.NoError(t, w.check(start.Add(10*time.Minute)))
+	active = true
+	require.NoError(t, w.check(start.Add(10*time.Minute+30*time.Second)))
+	require.Len(t, stalls, 1)
+
+	require.NoError(t, w.check(start.Add(12*time.Minute)))
+	require.Len(t, stalls, 2)
+}
+
+func TestWatchdog_FailOnStall(t *testing.T) {
+	start := time.Now()
+	w := newWatchdog(
+		time.Minute,
+		true,
+		[]watchedStage{{
+			name: FullScanStage,
+			lastHeartbeat: func() (time.Time, bool) {
+				return start, true
+			},
+		}},
+		ScanHooks{},
+		zerolog.Nop(),
+	)
+
+	require.ErrorIs(t, w.check(start.Add(2*time.Minute)), ErrStalled)
+}